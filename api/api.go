@@ -2,8 +2,9 @@
 package api
 
 import (
-	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -23,8 +24,17 @@ import (
 	"github.com/micro/go-api/server"
 	"github.com/micro/go-log"
 	"github.com/micro/go-micro"
+	"github.com/micro/micro/internal/auth"
+	"github.com/micro/micro/internal/bodylimit"
+	"github.com/micro/micro/internal/certs"
+	"github.com/micro/micro/internal/compress"
+	"github.com/micro/micro/internal/cors"
+	"github.com/micro/micro/internal/grpcweb"
 	"github.com/micro/micro/internal/handler"
 	"github.com/micro/micro/internal/helper"
+	"github.com/micro/micro/internal/openapi"
+	"github.com/micro/micro/internal/ratelimit"
+	"github.com/micro/micro/internal/requestid"
 	"github.com/micro/micro/internal/stats"
 	"github.com/micro/micro/plugin"
 )
@@ -66,19 +76,47 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	// Init API
 	var opts []server.Option
 
-	if ctx.GlobalBool("enable_acme") {
-		hosts := helper.ACMEHosts(ctx)
-		opts = append(opts, server.EnableACME(true))
-		opts = append(opts, server.ACMEHosts(hosts...))
-	} else if ctx.GlobalBool("enable_tls") {
-		config, err := helper.TLSConfig(ctx)
+	enableACME := ctx.GlobalBool("enable_acme")
+	enableTLS := ctx.GlobalBool("enable_tls")
+
+	if enableACME || enableTLS {
+		copts := certs.Options{
+			ACME:    enableACME,
+			Address: Address,
+		}
+		if enableACME {
+			copts.Hosts = helper.ACMEHosts(ctx)
+			copts.CacheDir = ctx.String("acme_cache_dir")
+			copts.Email = ctx.String("acme_email")
+			copts.CA = ctx.String("acme_ca")
+		}
+		if enableTLS {
+			copts.CertFile = ctx.GlobalString("tls_cert_file")
+			copts.KeyFile = ctx.GlobalString("tls_key_file")
+			copts.ClientCAFile = ctx.GlobalString("tls_client_ca_file")
+		}
+
+		c, err := certs.New(copts)
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			log.Fatal(err)
 		}
 
 		opts = append(opts, server.EnableTLS(true))
-		opts = append(opts, server.TLSConfig(config))
+		opts = append(opts, server.TLSConfig(c.TLSConfig()))
+
+		// ACME challenges and redirects to HTTPS are served over plain HTTP
+		if redirect := ctx.Bool("http_redirect"); enableACME || redirect {
+			l, err := net.Listen("tcp", ctx.String("http_address"))
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Logf("Serving plain HTTP on %s", l.Addr().String())
+			go http.Serve(l, c.HTTPHandler(redirect))
+			defer l.Close()
+		}
+
+		// reload the key pair on SIGHUP
+		defer c.Notify()()
 	}
 
 	// create the router
@@ -105,10 +143,32 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	// initialise service
 	service := micro.NewService(srvOpts...)
 
-	// register rpc handler
+	// register rpc handler, its uploads are as large as bodies
+	if n := ctx.GlobalInt("api_max_body_size"); n > 0 {
+		handler.MaxUploadSize = int64(n)
+	}
 	log.Logf("Registering RPC Handler at %s", RPCPath)
 	r.HandleFunc(RPCPath, handler.RPC)
 
+	// document the endpoints of the services routed to
+	if ctx.Bool("enable_openapi") {
+		log.Logf("Registering OpenAPI Documents at /openapi.json and /openapi.yaml")
+		doc := openapi.New(openapi.Options{
+			Registry:  service.Options().Registry,
+			Namespace: Namespace,
+			Handler:   Handler,
+		})
+		if err := doc.Start(); err != nil {
+			log.Fatal(err)
+		}
+		defer doc.Stop()
+		r.HandleFunc("/openapi.json", doc.JSONHandler)
+		r.HandleFunc("/openapi.yaml", doc.YAMLHandler)
+	}
+
+	// of the streams of the rpc and meta handlers
+	handler.Heartbeat = ctx.Duration("stream_heartbeat")
+
 	// resolver options
 	ropts := []resolver.Option{
 		resolver.WithNamespace(Namespace),
@@ -141,7 +201,7 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			ahandler.WithRouter(rt),
 			ahandler.WithService(service),
 		)
-		r.PathPrefix(APIPath).Handler(rp)
+		r.PathPrefix(APIPath).Handler(handler.Stream(service, rt, rp))
 	case "api":
 		log.Logf("Registering API Request Handler at %s", APIPath)
 		rt := router.NewRouter(
@@ -178,11 +238,7 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			router.WithResolver(rr),
 			router.WithRegistry(service.Options().Registry),
 		)
-		ht := ahttp.NewHandler(
-			ahandler.WithNamespace(Namespace),
-			ahandler.WithRouter(rt),
-			ahandler.WithService(service),
-		)
+		ht := handler.HTTP(service, rt, ctx.Duration("websocket_timeout"))
 		r.PathPrefix(ProxyPath).Handler(ht)
 	case "web":
 		log.Logf("Registering API Web Handler at %s", APIPath)
@@ -198,13 +254,20 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 			ahandler.WithService(service),
 		)
 		r.PathPrefix(APIPath).Handler(w)
+	case "grpcweb":
+		log.Logf("Registering API gRPC-Web Handler at %s", APIPath)
+		gw := grpcweb.New(grpcweb.Options{
+			Selector: service.Client().Options().Selector,
+		})
+		defer gw.Close()
+		r.PathPrefix(APIPath).Handler(gw)
 	default:
 		log.Logf("Registering API Default Handler at %s", APIPath)
 		rt := router.NewRouter(
 			router.WithNamespace(Namespace),
 			router.WithRegistry(service.Options().Registry),
 		)
-		r.PathPrefix(APIPath).Handler(handler.Meta(service, rt))
+		r.PathPrefix(APIPath).Handler(handler.Meta(service, rt, ctx.Duration("websocket_timeout")))
 	}
 
 	// reverse wrap handler
@@ -213,6 +276,105 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 		h = plugins[i-1].Handler()(h)
 	}
 
+	// authenticate requests by their token
+	switch ctx.String("auth") {
+	case "":
+	case "jwt":
+		a, err := auth.New(auth.Options{
+			JWKSURL:  ctx.String("auth_jwks_url"),
+			KeyFile:  ctx.String("auth_public_key"),
+			Refresh:  ctx.Duration("auth_jwks_refresh"),
+			Issuer:   ctx.String("auth_issuer"),
+			Audience: ctx.String("auth_audience"),
+			Skew:     ctx.Duration("auth_clock_skew"),
+			Public:   split(ctx.String("auth_public_paths")),
+			Id:       Name,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Logf("Authenticating requests with JWTs")
+		h = a.Handler(h)
+	default:
+		log.Fatalf("Unknown auth %s, expected jwt", ctx.String("auth"))
+	}
+
+	// limit the requests of each client
+	if rps := ctx.Float64("rate_limit"); rps > 0 {
+		l := ratelimit.New(ratelimit.Options{
+			Rate:   rps,
+			Burst:  ctx.Int("rate_limit_burst"),
+			Header: ctx.String("rate_limit_header"),
+			Id:     Name,
+		})
+		log.Logf("Limiting clients to %v requests per second", rps)
+		h = l.Handler(h)
+	}
+
+	// limit the size of request bodies, those proxied are streamed to
+	// services so may be larger
+	maxBody := ctx.GlobalInt("api_max_body_size")
+	switch Handler {
+	case "http", "proxy", "web":
+		maxBody = ctx.GlobalInt("api_max_proxy_body_size")
+	}
+	if maxBody > 0 {
+		h = bodylimit.New(bodylimit.Options{
+			Max: int64(maxBody),
+			Id:  Name,
+		}).Handler(h)
+	}
+
+	// answer cross origin requests before anything else sees them
+	if origins := split(ctx.String("cors_allowed_origins")); len(origins) > 0 {
+		headers := split(ctx.String("cors_allowed_headers"))
+		exposed := split(ctx.String("cors_exposed_headers"))
+
+		// scripts may read the ID of their requests
+		if hdr := ctx.String("request_id_header"); len(hdr) > 0 {
+			exposed = append(exposed, hdr)
+		}
+
+		// grpc-web clients send and read headers of their own
+		if Handler == "grpcweb" {
+			headers = append(headers, grpcweb.Headers...)
+			exposed = append(exposed, grpcweb.ExposedHeaders...)
+		}
+
+		c, err := cors.New(cors.Options{
+			Origins:     origins,
+			Methods:     split(ctx.String("cors_allowed_methods")),
+			Headers:     headers,
+			Exposed:     exposed,
+			MaxAge:      ctx.Duration("cors_max_age"),
+			Credentials: ctx.Bool("cors_allow_credentials"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Logf("Allowing cross origin requests from %s", strings.Join(origins, ", "))
+		h = c.Handler(h)
+	}
+
+	// compress responses for clients which accept it
+	if !ctx.Bool("disable_compression") {
+		c, err := compress.New(compress.Options{
+			MinSize: ctx.Int("compression_min_size"),
+			Types:   split(ctx.String("compression_types")),
+			Deflate: ctx.Bool("compression_deflate"),
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		h = c.Handler(h)
+	}
+
+	// trace requests by their ID, from the edge through services
+	h = requestid.New(requestid.Options{
+		Header: ctx.String("request_id_header"),
+		Log:    ctx.Bool("access_log"),
+	}).Handler(h)
+
 	// create the server
 	api := server.NewServer(Address)
 	api.Init(opts...)
@@ -234,6 +396,17 @@ func run(ctx *cli.Context, srvOpts ...micro.Option) {
 	}
 }
 
+// split returns the values of the comma separated list
+func split(list string) []string {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func Commands(options ...micro.Option) []cli.Command {
 	command := cli.Command{
 		Name:  "api",
@@ -249,7 +422,7 @@ func Commands(options ...micro.Option) []cli.Command {
 			},
 			cli.StringFlag{
 				Name:   "handler",
-				Usage:  "Specify the request handler to be used for mapping HTTP requests to services; {api, event, http, rpc}",
+				Usage:  "Specify the request handler to be used for mapping HTTP requests to services; {api, event, http, rpc, grpcweb}",
 				EnvVar: "MICRO_API_HANDLER",
 			},
 			cli.StringFlag{
@@ -262,6 +435,172 @@ func Commands(options ...micro.Option) []cli.Command {
 				Usage:  "Set the hostname resolver used by the API {host, path, grpc}",
 				EnvVar: "MICRO_API_RESOLVER",
 			},
+			cli.DurationFlag{
+				Name:   "websocket_timeout",
+				Usage:  "How long either direction of a websocket proxied by the http handler may be idle before it's closed e.g. 5m, 0 never",
+				EnvVar: "MICRO_API_WEBSOCKET_TIMEOUT",
+			},
+			cli.StringFlag{
+				Name:   "acme_cache_dir",
+				Usage:  "Directory certificates issued by ACME are kept in",
+				EnvVar: "MICRO_API_ACME_CACHE_DIR",
+				Value:  certs.DefaultCacheDir,
+			},
+			cli.StringFlag{
+				Name:   "acme_email",
+				Usage:  "Email the ACME CA may send notices about certificates to",
+				EnvVar: "MICRO_API_ACME_EMAIL",
+			},
+			cli.StringFlag{
+				Name:   "acme_ca",
+				Usage:  "Directory URL of the ACME CA certificates are issued by",
+				EnvVar: "MICRO_API_ACME_CA",
+				Value:  certs.DefaultCA,
+			},
+			cli.StringFlag{
+				Name:   "http_address",
+				Usage:  "Address plain HTTP is served on with TLS enabled, for ACME challenges and redirects to HTTPS",
+				EnvVar: "MICRO_API_HTTP_ADDRESS",
+				Value:  ":80",
+			},
+			cli.BoolFlag{
+				Name:   "http_redirect",
+				Usage:  "Redirect plain HTTP requests to HTTPS with TLS enabled",
+				EnvVar: "MICRO_API_HTTP_REDIRECT",
+			},
+			cli.StringFlag{
+				Name:   "auth",
+				Usage:  "Authenticate requests; {jwt} validates a bearer JWT. Empty disables",
+				EnvVar: "MICRO_API_AUTH",
+			},
+			cli.StringFlag{
+				Name:   "auth_jwks_url",
+				Usage:  "URL of the JWKS tokens are signed with e.g. https://example.com/.well-known/jwks.json",
+				EnvVar: "MICRO_API_AUTH_JWKS_URL",
+			},
+			cli.DurationFlag{
+				Name:   "auth_jwks_refresh",
+				Usage:  "How often the JWKS is fetched, sooner for tokens of unknown keys",
+				EnvVar: "MICRO_API_AUTH_JWKS_REFRESH",
+				Value:  auth.DefaultRefresh,
+			},
+			cli.StringFlag{
+				Name:   "auth_public_key",
+				Usage:  "File of the PEM encoded public key or certificate tokens are signed with, instead of a JWKS",
+				EnvVar: "MICRO_API_AUTH_PUBLIC_KEY",
+			},
+			cli.StringFlag{
+				Name:   "auth_issuer",
+				Usage:  "Issuer tokens must be issued by",
+				EnvVar: "MICRO_API_AUTH_ISSUER",
+			},
+			cli.StringFlag{
+				Name:   "auth_audience",
+				Usage:  "Audience tokens must be for",
+				EnvVar: "MICRO_API_AUTH_AUDIENCE",
+			},
+			cli.DurationFlag{
+				Name:   "auth_clock_skew",
+				Usage:  "Clock skew tolerated checking when tokens expire or become valid",
+				EnvVar: "MICRO_API_AUTH_CLOCK_SKEW",
+				Value:  auth.DefaultSkew,
+			},
+			cli.StringFlag{
+				Name:   "auth_public_paths",
+				Usage:  "Comma separated paths requests to, or beneath, aren't authenticated e.g. /health,/login",
+				EnvVar: "MICRO_API_AUTH_PUBLIC_PATHS",
+			},
+			cli.Float64Flag{
+				Name:   "rate_limit",
+				Usage:  "Requests per second each client may make, 0 disables rate limiting",
+				EnvVar: "MICRO_API_RATE_LIMIT",
+			},
+			cli.IntFlag{
+				Name:   "rate_limit_burst",
+				Usage:  "Requests a client may make at once above the rate, defaults to the rate",
+				EnvVar: "MICRO_API_RATE_LIMIT_BURST",
+			},
+			cli.StringFlag{
+				Name:   "rate_limit_header",
+				Usage:  "Header clients are limited by rather than their IP e.g. X-Api-Key",
+				EnvVar: "MICRO_API_RATE_LIMIT_HEADER",
+			},
+			cli.StringFlag{
+				Name:   "cors_allowed_origins",
+				Usage:  "Comma separated origins allowed to make cross origin requests e.g. https://example.com,https://*.example.com or * for any. Empty disables CORS",
+				EnvVar: "MICRO_API_CORS_ALLOWED_ORIGINS",
+			},
+			cli.StringFlag{
+				Name:   "cors_allowed_methods",
+				Usage:  "Comma separated methods cross origin requests may use",
+				EnvVar: "MICRO_API_CORS_ALLOWED_METHODS",
+				Value:  strings.Join(cors.DefaultMethods, ","),
+			},
+			cli.StringFlag{
+				Name:   "cors_allowed_headers",
+				Usage:  "Comma separated headers cross origin requests may send or * for any",
+				EnvVar: "MICRO_API_CORS_ALLOWED_HEADERS",
+				Value:  strings.Join(cors.DefaultHeaders, ","),
+			},
+			cli.StringFlag{
+				Name:   "cors_exposed_headers",
+				Usage:  "Comma separated response headers cross origin requests may read",
+				EnvVar: "MICRO_API_CORS_EXPOSED_HEADERS",
+			},
+			cli.DurationFlag{
+				Name:   "cors_max_age",
+				Usage:  "How long browsers may cache preflight responses e.g. 10m",
+				EnvVar: "MICRO_API_CORS_MAX_AGE",
+			},
+			cli.BoolFlag{
+				Name:   "cors_allow_credentials",
+				Usage:  "Allow cross origin requests to send cookies and auth, not allowed for any origin",
+				EnvVar: "MICRO_API_CORS_ALLOW_CREDENTIALS",
+			},
+			cli.BoolFlag{
+				Name:   "disable_compression",
+				Usage:  "Disable compressing responses with gzip",
+				EnvVar: "MICRO_API_DISABLE_COMPRESSION",
+			},
+			cli.IntFlag{
+				Name:   "compression_min_size",
+				Usage:  "Smallest size in bytes of responses compressed",
+				EnvVar: "MICRO_API_COMPRESSION_MIN_SIZE",
+				Value:  compress.DefaultMinSize,
+			},
+			cli.StringFlag{
+				Name:   "compression_types",
+				Usage:  "Comma separated content types of responses compressed e.g. application/json,text/*",
+				EnvVar: "MICRO_API_COMPRESSION_TYPES",
+				Value:  strings.Join(compress.DefaultTypes, ","),
+			},
+			cli.BoolFlag{
+				Name:   "compression_deflate",
+				Usage:  "Compress responses with deflate for clients which don't accept gzip",
+				EnvVar: "MICRO_API_COMPRESSION_DEFLATE",
+			},
+			cli.DurationFlag{
+				Name:   "stream_heartbeat",
+				Usage:  "How often idle event streams of streaming endpoints are sent a comment, so proxies don't close them e.g. 30s, 0 never",
+				EnvVar: "MICRO_API_STREAM_HEARTBEAT",
+				Value:  handler.Heartbeat,
+			},
+			cli.StringFlag{
+				Name:   "request_id_header",
+				Usage:  "Header of the ID of requests passed to services and returned to clients e.g. X-Correlation-Id",
+				EnvVar: "MICRO_API_REQUEST_ID_HEADER",
+				Value:  requestid.DefaultHeader,
+			},
+			cli.BoolFlag{
+				Name:   "access_log",
+				Usage:  "Log each request with its ID",
+				EnvVar: "MICRO_API_ACCESS_LOG",
+			},
+			cli.BoolFlag{
+				Name:   "enable_openapi",
+				Usage:  "Serve an OpenAPI document of the services routed to at /openapi.json and /openapi.yaml",
+				EnvVar: "MICRO_API_ENABLE_OPENAPI",
+			},
 		},
 	}
 
@@ -0,0 +1,252 @@
+package bot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/input"
+)
+
+// identifier is implemented by conns which identify the sender of an
+// event by more than the user in Event.From. The principal is the name
+// of the input followed by its identity of the sender e.g.
+// "irc:nick!user@host".
+type identifier interface {
+	Principal(ev input.Event) string
+}
+
+// aclRule allows or denies the principals matching any of its patterns
+// to run the commands matching its command pattern
+type aclRule struct {
+	allow      bool
+	command    string
+	principals []string
+}
+
+// acl decides who may run a command. A deny matching the command and
+// principal always wins. Commands with allow rules, or restricted ones,
+// may only be run by the principals they allow, others by anyone unless
+// denyAll is set.
+type acl struct {
+	rules   []aclRule
+	denyAll bool
+	// commands only run by principals explicitly allowed to
+	restricted map[string]bool
+}
+
+// permissionError is returned when the principal may not run a command
+type permissionError struct {
+	name      string
+	principal string
+}
+
+func (p permissionError) Error() string {
+	if len(p.principal) == 0 {
+		return fmt.Sprintf("permission denied: unknown users may not run '%s'", p.name)
+	}
+	return fmt.Sprintf("permission denied: %s may not run '%s'", p.principal, p.name)
+}
+
+// wildcard returns true if s matches the pattern, * matching any
+// characters
+func wildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, p)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(p):]
+	}
+
+	return strings.HasSuffix(s, last)
+}
+
+// parseACL parses rules of the form "allow <command> = <principal> ..."
+// or "deny <command> = <principal> ...", one per line or separated by
+// semicolons. Lines starting with # are comments.
+func parseACL(r io.Reader) ([]aclRule, error) {
+	var rules []aclRule
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+
+	for scanner.Scan() {
+		n++
+
+		for _, line := range strings.Split(scanner.Text(), ";") {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			parts := strings.SplitN(line, "=", 2)
+			fields := strings.Fields(parts[0])
+			if len(parts) != 2 || len(fields) < 2 {
+				return nil, fmt.Errorf("acl line %d: expected allow|deny <command> = <principal> ... got %q", n, line)
+			}
+
+			var rule aclRule
+			switch fields[0] {
+			case "allow":
+				rule.allow = true
+			case "deny":
+			default:
+				return nil, fmt.Errorf("acl line %d: expected allow or deny got %q", n, fields[0])
+			}
+
+			// commands are matched by their normalized namespaced name
+			rule.command = strings.ToLower(strings.Join(fields[1:], " "))
+			rule.principals = strings.Fields(strings.Replace(parts[1], ",", " ", -1))
+
+			if len(rule.principals) == 0 {
+				return nil, fmt.Errorf("acl line %d: no principals for %s", n, rule.command)
+			}
+
+			rules = append(rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// loadACL returns the acl of the acl, acl_file and acl_default flags
+func loadACL(ctx *cli.Context) (*acl, error) {
+	a := &acl{}
+
+	switch mode := ctx.String("acl_default"); mode {
+	case "", "allow":
+	case "deny":
+		a.denyAll = true
+	default:
+		return nil, fmt.Errorf("acl_default must be allow or deny got %q", mode)
+	}
+
+	if path := ctx.String("acl_file"); len(path) > 0 {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		rules, err := parseACL(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		a.rules = append(a.rules, rules...)
+	}
+
+	rules, err := parseACL(strings.NewReader(ctx.String("acl")))
+	if err != nil {
+		return nil, err
+	}
+	a.rules = append(a.rules, rules...)
+
+	return a, nil
+}
+
+// check returns a permissionError if the principal may not run the
+// command of the namespaced name. Unknown principals, which are empty,
+// match no rule.
+func (a *acl) check(name, principal string) error {
+	if a == nil {
+		return nil
+	}
+
+	listed := a.restricted[name]
+	allowed := false
+
+	for _, r := range a.rules {
+		if !wildcard(r.command, name) {
+			continue
+		}
+
+		// restricted commands must be allowed by name
+		if r.allow && a.restricted[name] && r.command != name {
+			continue
+		}
+
+		if r.allow {
+			listed = true
+		}
+
+		if len(principal) == 0 {
+			continue
+		}
+
+		for _, p := range r.principals {
+			if !wildcard(p, principal) {
+				continue
+			}
+			if !r.allow {
+				return permissionError{name, principal}
+			}
+			allowed = true
+		}
+	}
+
+	if allowed || (!listed && !a.denyAll) {
+		return nil
+	}
+
+	return permissionError{name, principal}
+}
+
+// restrict lets only the principals allowed by rules naming the
+// commands run them
+func (a *acl) restrict(names ...string) {
+	if a.restricted == nil {
+		a.restricted = make(map[string]bool)
+	}
+	for _, name := range names {
+		a.restricted[name] = true
+	}
+}
+
+// allows returns true if a rule allows someone to run the command by
+// name, as restricted commands must be
+func (a *acl) allows(name string) bool {
+	for _, r := range a.rules {
+		if r.allow && r.command == name {
+			return true
+		}
+	}
+	return false
+}
+
+// principal returns the principal of the sender of ev, empty if unknown
+func principal(c input.Conn, ev input.Event) string {
+	var name string
+	if sc, ok := c.(*serialConn); ok {
+		name, c = sc.input, sc.Conn
+	}
+
+	if i, ok := c.(identifier); ok {
+		return i.Principal(ev)
+	}
+
+	user, _ := requester(ev)
+	if len(name) == 0 || len(user) == 0 {
+		return ""
+	}
+
+	return name + ":" + user
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestWildcard(t *testing.T) {
+	testData := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "slack:U123", true},
+		{"*", "", true},
+		{"slack:*", "slack:U123", true},
+		{"slack:*", "irc:john", false},
+		{"slack:U123", "slack:U123", true},
+		{"slack:U123", "slack:U1234", false},
+		{"irc:john!*@host", "irc:john!j@host", true},
+		{"irc:john!*@host", "irc:john!j@other", false},
+		{"irc:*!*@host", "irc:jane!j@host", true},
+		{"registry *", "registry list", true},
+		{"registry *", "k8s list", false},
+	}
+
+	for _, d := range testData {
+		if m := wildcard(d.pattern, d.s); m != d.match {
+			t.Fatalf("%q %q: expected %t got %t", d.pattern, d.s, d.match, m)
+		}
+	}
+}
+
+func TestParseACL(t *testing.T) {
+	rules, err := parseACL(strings.NewReader("# ops only\nallow Deploy = slack:U1, irc:*\n\ndeny registry list = slack:U2; allow * = *"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 3 {
+		t.Fatalf("expected 3 rules got %+v", rules)
+	}
+	if r := rules[0]; !r.allow || r.command != "deploy" || strings.Join(r.principals, " ") != "slack:U1 irc:*" {
+		t.Fatalf("unexpected rule %+v", r)
+	}
+	if r := rules[1]; r.allow || r.command != "registry list" || strings.Join(r.principals, " ") != "slack:U2" {
+		t.Fatalf("unexpected rule %+v", r)
+	}
+
+	for _, text := range []string{
+		"allow deploy",
+		"permit deploy = slack:U1",
+		"allow = slack:U1",
+		"deny deploy =",
+	} {
+		if _, err := parseACL(strings.NewReader(text)); err == nil {
+			t.Fatalf("%q: expected an error", text)
+		}
+	}
+}
+
+func TestACL(t *testing.T) {
+	rules, err := parseACL(strings.NewReader(`
+allow deploy = slack:U1 slack:U2 irc:*!*@ops.example.com
+deny deploy = slack:U2
+allow * = slack:*
+deny * = slack:U9
+allow registry * = telegram:42
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		denyAll   bool
+		name      string
+		principal string
+		allowed   bool
+	}{
+		{false, "deploy", "slack:U1", true},
+		{false, "deploy", "irc:john!j@ops.example.com", true},
+		// an explicit deny beats the allow of the command and wildcard
+		{false, "deploy", "slack:U2", false},
+		{false, "echo", "slack:U9", false},
+		{false, "deploy", "slack:U9", false},
+		// wildcard allows
+		{false, "echo", "slack:U3", true},
+		{false, "registry list", "telegram:42", true},
+		// commands with allow rules only let those they allow run them
+		{false, "echo", "irc:john!j@ops.example.com", false},
+		{false, "registry list", "telegram:43", false},
+		// unknown principals match no rule
+		{false, "echo", "", false},
+		{true, "echo", "", false},
+		{true, "deploy", "slack:U1", true},
+	}
+
+	for _, d := range testData {
+		a := &acl{rules: rules, denyAll: d.denyAll}
+		if err := a.check(d.name, d.principal); (err == nil) != d.allowed {
+			t.Fatalf("%s %q deny all %t: expected allowed %t got %v", d.name, d.principal, d.denyAll, d.allowed, err)
+		}
+	}
+
+	// without rules the default mode decides
+	for _, d := range []struct {
+		denyAll   bool
+		principal string
+		allowed   bool
+	}{
+		{false, "slack:U1", true},
+		{false, "", true},
+		{true, "slack:U1", false},
+		{true, "", false},
+	} {
+		a := &acl{denyAll: d.denyAll}
+		if err := a.check("echo", d.principal); (err == nil) != d.allowed {
+			t.Fatalf("%q deny all %t: expected allowed %t got %v", d.principal, d.denyAll, d.allowed, err)
+		}
+	}
+}
+
+func TestLoadACL(t *testing.T) {
+	f, err := ioutil.TempFile("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("allow deploy = slack:U1\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "deny deploy = slack:U2", "")
+	flagSet.String("acl_file", f.Name(), "")
+	flagSet.String("acl_default", "deny", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	a, err := loadACL(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.rules) != 2 || !a.denyAll {
+		t.Fatalf("unexpected acl %+v", a)
+	}
+
+	flagSet.Set("acl_default", "maybe")
+	if _, err := loadACL(ctx); err == nil {
+		t.Fatal("expected an error for an unknown default")
+	}
+}
+
+func TestProcessACL(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "allow deploy = slack:U1; deny * = slack:U9", "")
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^deploy ": command.NewCommand("deploy", "deploy [service]", "deploys", func(args ...string) ([]byte, error) {
+			return []byte("deployed"), nil
+		}),
+		"^echo ": command.NewCommand("echo", "echo [text]", "echoes", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	c := &serialConn{Conn: io, input: "slack"}
+
+	testData := []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C1:U1", "deploy api", "deployed"},
+		{"C1:U2", "deploy api", "permission denied: slack:U2 may not run 'deploy'"},
+		{"C1:U2", "echo hi", "hi"},
+		{"C1:U9", "echo hi", "permission denied: slack:U9 may not run 'echo'"},
+		{"", "deploy api", "permission denied: unknown users may not run 'deploy'"},
+		// unknown commands are reported as before
+		{"C1:U9", "nope", "unknown command 'nope'"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: d.from, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if !strings.HasPrefix(string(ev.Data), d.expect) {
+				t.Fatalf("%s %q: expected %q got %q", d.from, d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%s %q: expected a response", d.from, d.text)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/command"
+)
+
+// aliasIndex maps the normalized aliases of the commands to the words of
+// the namespaced name they stand for. An alias which is also the name of a command
+// or is claimed by two commands is an error.
+func aliasIndex(commands map[string]command.Command) (map[string][]string, error) {
+	var patterns []string
+	for pattern := range commands {
+		patterns = append(patterns, pattern)
+	}
+	// check in a stable order so the same collision is always reported
+	sort.Strings(patterns)
+
+	names := make(map[string]string)
+	for _, pattern := range patterns {
+		cmd := commands[pattern]
+		if fields := strings.Fields(cmd.String()); len(fields) > 0 {
+			names[normalize(fields[0])] = cmd.String()
+		}
+	}
+
+	index := make(map[string][]string)
+	owners := make(map[string]string)
+
+	for _, pattern := range patterns {
+		cmd := commands[pattern]
+
+		for _, alias := range command.Aliases(cmd) {
+			a := normalize(alias)
+			if len(a) == 0 || strings.ContainsAny(a, " \t\n") {
+				return nil, fmt.Errorf("alias %q of command %s must be a single word", alias, cmd.String())
+			}
+			if name, ok := names[a]; ok {
+				return nil, fmt.Errorf("alias %q of command %s collides with command %s", alias, cmd.String(), name)
+			}
+			if owner, ok := owners[a]; ok {
+				// the same command listed under several patterns
+				if owner == cmd.String() {
+					continue
+				}
+				return nil, fmt.Errorf("alias %q of command %s is already an alias of command %s", alias, cmd.String(), owner)
+			}
+
+			owners[a] = cmd.String()
+			// grouped commands are run by their namespaced name
+			index[a] = strings.Fields(command.FullName(cmd))
+		}
+	}
+
+	return index, nil
+}
+
+// reindex sets the alias index of the commands, keeping the last one if
+// their aliases collide. The lock must be held.
+func (b *bot) reindex(commands map[string]command.Command) {
+	aliases, err := aliasIndex(commands)
+	if err != nil {
+		log.Logf("[bot] ignoring command aliases: %v", err)
+		return
+	}
+	b.aliases = aliases
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestAliasIndex(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	cmd := func(name string, aliases ...string) command.Command {
+		return command.WithAliases(command.NewCommand(name, name, name, exec), aliases...)
+	}
+
+	testData := []struct {
+		commands map[string]command.Command
+		err      string
+	}{
+		{map[string]command.Command{
+			"^health ": cmd("health", "hc", "Status"),
+			"^list ":   cmd("list", "ls"),
+		}, ""},
+		// the same command under several patterns
+		{map[string]command.Command{
+			"^list ":     cmd("list", "ls"),
+			"^services$": cmd("list", "ls"),
+		}, ""},
+		{map[string]command.Command{
+			"^health ": cmd("health", "list"),
+			"^list ":   cmd("list"),
+		}, `alias "list" of command health collides with command list`},
+		{map[string]command.Command{
+			"^health ": cmd("health", "Ping?"),
+			"^ping$":   cmd("ping"),
+		}, `alias "Ping?" of command health collides with command ping`},
+		{map[string]command.Command{
+			"^health ": cmd("health", "h"),
+			"^help$":   cmd("help", "h"),
+		}, `alias "h" of command help is already an alias of command health`},
+		{map[string]command.Command{
+			"^health ": cmd("health", "h c"),
+		}, `alias "h c" of command health must be a single word`},
+	}
+
+	for _, d := range testData {
+		index, err := aliasIndex(d.commands)
+		if len(d.err) == 0 {
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != d.err {
+			t.Fatalf("expected %q got %v %v", d.err, err, index)
+		}
+	}
+
+	index, err := aliasIndex(testData[0].commands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := index["status"]; len(name) != 1 || name[0] != "health" {
+		t.Fatalf("unexpected index %v", index)
+	}
+}
+
+func TestProcessAliases(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^health ": command.WithAliases(command.NewCommandWithArgs("health", "checks a service", []command.Arg{
+			{Name: "service", Required: true},
+		}, func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args, " ")), nil
+		}), "hc"),
+		"^(the )?three laws$": command.WithAliases(command.NewCommand("the three laws", "the three laws", "returns the laws", func(args ...string) ([]byte, error) {
+			return []byte("laws"), nil
+		}), "laws"),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		// the command sees its canonical name
+		{"hc api", "health api"},
+		{"HC? api", "health api"},
+		{"hc", "usage: health <service>"},
+		{"laws", "laws"},
+		{"help", "health <service> (hc)                        checks a service"},
+		{"help hc", "checks a service\nusage      health <service>\naliases    hc\n<service>"},
+		{"hx", "unknown command 'hx'"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if !strings.Contains(string(ev.Data), d.expect) {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", d.text)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// announcementsPattern matches announcements alone or followed by a
+// subcommand
+const announcementsPattern = "^announcements( |$)"
+
+var (
+	// DefaultAnnounceWindow is how long the changes to a service are
+	// collected before they're announced as one
+	DefaultAnnounceWindow = 30 * time.Second
+	// AnnounceBackoff is how long the first attempt to watch the
+	// registry again waits, doubling each time up to AnnounceMaxBackoff
+	AnnounceBackoff = time.Second
+	// AnnounceMaxBackoff is the longest wait between attempts to watch
+	// the registry
+	AnnounceMaxBackoff = time.Minute
+)
+
+// nodeSet is the ids of the nodes of a service by version
+type nodeSet map[string]map[string]bool
+
+func newNodeSet(versions []*registry.Service) nodeSet {
+	s := make(nodeSet)
+	for _, v := range versions {
+		if len(v.Nodes) == 0 {
+			continue
+		}
+		if s[v.Version] == nil {
+			s[v.Version] = make(map[string]bool)
+		}
+		for _, n := range v.Nodes {
+			s[v.Version][n.Id] = true
+		}
+	}
+	return s
+}
+
+func (s nodeSet) nodes() map[string]bool {
+	nodes := make(map[string]bool)
+	for _, ids := range s {
+		for id := range ids {
+			nodes[id] = true
+		}
+	}
+	return nodes
+}
+
+func (s nodeSet) versions() []string {
+	var versions []string
+	for v := range s {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func versionName(v string) string {
+	if len(v) > 0 && v[0] >= '0' && v[0] <= '9' {
+		return "v" + v
+	}
+	return v
+}
+
+// describe returns what happened to the service with its nodes e.g.
+// "service foo v1.2 registered, 3 nodes"
+func describe(name, verb string, s nodeSet) string {
+	versions := s.versions()
+	if len(versions) == 1 {
+		return fmt.Sprintf("service %s %s %s, %s", name, versionName(versions[0]), verb, plural(len(s[versions[0]]), "node"))
+	}
+
+	parts := make([]string, 0, len(versions))
+	for _, v := range versions {
+		parts = append(parts, versionName(v)+" "+plural(len(s[v]), "node"))
+	}
+	return fmt.Sprintf("service %s %s, %s", name, verb, strings.Join(parts, ", "))
+}
+
+// announcement returns the message announcing the service going from
+// before to after, empty if nothing changed. Nil is a service which
+// isn't registered.
+func announcement(name string, before, after nodeSet) string {
+	switch {
+	case len(before) == 0 && len(after) == 0:
+		return ""
+	case len(after) == 0:
+		return fmt.Sprintf("service %s deregistered", name)
+	case len(before) == 0:
+		return describe(name, "registered", after)
+	}
+
+	was, now := before.nodes(), after.nodes()
+
+	var added, removed int
+	for id := range now {
+		if !was[id] {
+			added++
+		}
+	}
+	for id := range was {
+		if !now[id] {
+			removed++
+		}
+	}
+
+	// versions moving between the same nodes change too
+	same := len(before) == len(after)
+	for v, ids := range after {
+		if len(before[v]) != len(ids) {
+			same = false
+		}
+	}
+	if added == 0 && removed == 0 && same {
+		return ""
+	}
+
+	var changes []string
+	if added > 0 {
+		changes = append(changes, fmt.Sprintf("+%d", added))
+	}
+	if removed > 0 {
+		changes = append(changes, fmt.Sprintf("-%d", removed))
+	}
+
+	text := describe(name, "updated", after)
+	if len(changes) > 0 {
+		text += " (" + strings.Join(changes, " ") + ")"
+	}
+	return text
+}
+
+// announceTarget is a channel of an input the announcements are posted to
+type announceTarget struct {
+	input   string
+	channel string
+}
+
+func (t announceTarget) String() string {
+	return t.input + ":" + t.channel
+}
+
+// announcer watches the registry posting the services registered and
+// deregistered to the channels. The changes to a service within the
+// window are announced together.
+type announcer struct {
+	bot     *bot
+	reg     registry.Registry
+	window  time.Duration
+	targets []announceTarget
+
+	sync.Mutex
+	paused map[announceTarget]bool
+	// the nodes of the services last announced
+	known   map[string]nodeSet
+	pending map[string]*time.Timer
+	closed  bool
+
+	// the watch and pending announcements
+	wg sync.WaitGroup
+}
+
+// loadAnnouncer returns the announcer posting to the channels of the
+// announce flag, nil if it isn't set
+func loadAnnouncer(ctx *cli.Context, b *bot, reg registry.Registry) (*announcer, error) {
+	channels := ctx.StringSlice("announce")
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	a := &announcer{
+		bot:     b,
+		reg:     reg,
+		window:  ctx.Duration("announce_window"),
+		paused:  make(map[announceTarget]bool),
+		known:   make(map[string]nodeSet),
+		pending: make(map[string]*time.Timer),
+	}
+	if a.window <= 0 {
+		a.window = DefaultAnnounceWindow
+	}
+
+	for _, c := range channels {
+		parts := strings.SplitN(c, ":", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("announce channel %q must be of the form <input>:<channel>", c)
+		}
+		a.targets = append(a.targets, announceTarget{parts[0], parts[1]})
+	}
+
+	return a, nil
+}
+
+// start watches the registry until the bot stops
+func (a *announcer) start() {
+	a.wg.Add(1)
+	go a.run()
+}
+
+// run watches the registry, watching it again with backoff whenever the
+// watch fails
+func (a *announcer) run() {
+	defer a.wg.Done()
+
+	backoff := AnnounceBackoff
+	synced := false
+
+	for {
+		w, err := a.reg.Watch()
+		if err == nil {
+			// changes missed while the watch was down are announced
+			a.sync(synced)
+			synced = true
+
+			var watched bool
+			watched, err = a.watch(w)
+			if watched {
+				backoff = AnnounceBackoff
+			}
+		}
+
+		select {
+		case <-a.bot.exit:
+			return
+		default:
+		}
+
+		log.Logf("[bot] watching registry %s again in %v: %v", a.reg.String(), backoff, err)
+
+		select {
+		case <-a.bot.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > AnnounceMaxBackoff {
+			backoff = AnnounceMaxBackoff
+		}
+	}
+}
+
+// watch passes on the services changing until the watch fails or the
+// bot stops, returning whether any change was seen
+func (a *announcer) watch(w registry.Watcher) (bool, error) {
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		select {
+		case <-a.bot.exit:
+		case <-done:
+		}
+		w.Stop()
+	}()
+
+	var watched bool
+
+	for {
+		res, err := w.Next()
+		if err != nil {
+			return watched, err
+		}
+		watched = true
+
+		if res.Service != nil {
+			a.changed(res.Service.Name)
+		}
+	}
+}
+
+// sync gets every service registered, announcing their changes if set
+// or otherwise taking them as they are
+func (a *announcer) sync(announce bool) {
+	var services []*registry.Service
+	err := queryRegistry(context.Background(), a.reg, func() error {
+		var err error
+		services, err = a.reg.ListServices()
+		return err
+	})
+	if err != nil {
+		log.Logf("[bot] error listing services to announce: %v", err)
+		return
+	}
+
+	names := make(map[string]bool)
+	for _, s := range services {
+		names[s.Name] = true
+	}
+
+	a.Lock()
+	for name := range a.known {
+		names[name] = true
+	}
+	a.Unlock()
+
+	for name := range names {
+		if announce {
+			a.changed(name)
+			continue
+		}
+
+		if nodes, err := a.nodes(name); err == nil {
+			a.Lock()
+			a.known[name] = nodes
+			a.Unlock()
+		}
+	}
+}
+
+// nodes returns the nodes of the service, none if it isn't registered
+func (a *announcer) nodes(name string) (nodeSet, error) {
+	var versions []*registry.Service
+	err := queryRegistry(context.Background(), a.reg, func() error {
+		var err error
+		versions, err = a.reg.GetService(name)
+		return err
+	})
+	if err == registry.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newNodeSet(versions), nil
+}
+
+// changed announces the service once the window has passed since the
+// first change to it which isn't announced
+func (a *announcer) changed(name string) {
+	a.Lock()
+	defer a.Unlock()
+
+	if a.closed || a.pending[name] != nil {
+		return
+	}
+
+	a.wg.Add(1)
+	a.pending[name] = time.AfterFunc(a.window, func() {
+		defer a.wg.Done()
+		a.settle(name)
+	})
+}
+
+// settle announces how the service changed since last announced
+func (a *announcer) settle(name string) {
+	a.Lock()
+	delete(a.pending, name)
+	a.Unlock()
+
+	nodes, err := a.nodes(name)
+	if err != nil {
+		// it's announced with the next change
+		log.Logf("[bot] error getting service %s to announce: %v", name, err)
+		return
+	}
+
+	a.Lock()
+	text := announcement(name, a.known[name], nodes)
+	if len(nodes) > 0 {
+		a.known[name] = nodes
+	} else {
+		delete(a.known, name)
+	}
+	var targets []announceTarget
+	for _, t := range a.targets {
+		if !a.paused[t] {
+			targets = append(targets, t)
+		}
+	}
+	closed := a.closed
+	a.Unlock()
+
+	if len(text) == 0 || closed {
+		return
+	}
+
+	for _, t := range targets {
+		a.post(t, text)
+	}
+}
+
+func (a *announcer) post(t announceTarget, text string) {
+	c, ok := a.bot.conn(t.input)
+	if !ok {
+		log.Logf("[bot] not announcing %q to %s, input isn't connected", text, t)
+		return
+	}
+
+	// posted to the channel rather than anyone in it
+	ev := input.Event{
+		Type: input.TextEvent,
+		From: t.channel + ":",
+		Meta: map[string]interface{}{},
+	}
+
+	if err := respond(c, ev, []byte(text)); err != nil {
+		log.Logf("[bot] error announcing to %s: %v", t, err)
+	}
+}
+
+// close drops the announcements pending and waits for the watch to
+// stop once the bot exits
+func (a *announcer) close() {
+	a.Lock()
+	a.closed = true
+	for name, t := range a.pending {
+		if t.Stop() {
+			a.wg.Done()
+		}
+		delete(a.pending, name)
+	}
+	a.Unlock()
+
+	a.wg.Wait()
+}
+
+// target returns the channel named by the arg, here being the channel
+// the command was sent in
+func (a *announcer) target(ctx context.Context, arg string) (announceTarget, error) {
+	t := announceTarget{command.Input(ctx), command.Channel(ctx)}
+	if arg != "here" {
+		parts := strings.SplitN(arg, ":", 2)
+		if len(parts) != 2 {
+			return t, fmt.Errorf("channel %q must be <input>:<channel> or here", arg)
+		}
+		t = announceTarget{parts[0], parts[1]}
+	}
+
+	for _, at := range a.targets {
+		if at == t {
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("announcements aren't posted to %s", t)
+}
+
+// announcementsCommand lists, pauses and resumes the announcements of
+// the channels
+func announcementsCommand(a *announcer) command.Command {
+	usage := "announcements [pause|resume [here|<input>:<channel>]]"
+	desc := "Lists the channels services registering and deregistering are announced to, or pauses or resumes announcing to one"
+
+	return command.NewContextCommand("announcements", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) == 1 {
+			a.Lock()
+			defer a.Unlock()
+
+			rows := [][]string{{"CHANNEL", "STATUS"}}
+			for _, t := range a.targets {
+				status := "announcing"
+				if a.paused[t] {
+					status = "paused"
+				}
+				rows = append(rows, []string{t.String(), status})
+			}
+
+			return command.Reply(ctx, &command.Response{
+				Text: fmt.Sprintf("services changing are announced after %v", a.window),
+				Code: table(rows),
+			}), nil
+		}
+
+		if len(args) > 3 || (args[1] != "pause" && args[1] != "resume") {
+			return nil, errors.New("usage: " + usage)
+		}
+
+		arg := "here"
+		if len(args) == 3 {
+			arg = args[2]
+		}
+
+		t, err := a.target(ctx, arg)
+		if err != nil {
+			return nil, err
+		}
+
+		a.Lock()
+		defer a.Unlock()
+
+		if args[1] == "pause" {
+			a.paused[t] = true
+			return []byte("paused announcements to " + t.String()), nil
+		}
+
+		delete(a.paused, t)
+		return []byte("resumed announcements to " + t.String()), nil
+	})
+}
@@ -0,0 +1,205 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// flakyRegistry fails to watch while down and drops the watch on demand
+type flakyRegistry struct {
+	registry.Registry
+
+	sync.Mutex
+	down    bool
+	watches int
+	watcher registry.Watcher
+}
+
+func (f *flakyRegistry) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+
+	w, err := f.Registry.Watch(opts...)
+	if err != nil {
+		return nil, err
+	}
+	f.watches++
+	f.watcher = w
+	return w, nil
+}
+
+// drop stops the watch and fails watching again until up
+func (f *flakyRegistry) drop() {
+	f.Lock()
+	defer f.Unlock()
+	f.down = true
+	f.watcher.Stop()
+}
+
+func (f *flakyRegistry) up() {
+	f.Lock()
+	defer f.Unlock()
+	f.down = false
+}
+
+func (f *flakyRegistry) watching() int {
+	f.Lock()
+	defer f.Unlock()
+	return f.watches
+}
+
+func TestAnnouncements(t *testing.T) {
+	backoff := AnnounceBackoff
+	AnnounceBackoff = 10 * time.Millisecond
+	defer func() { AnnounceBackoff = backoff }()
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.Var(&cli.StringSlice{"test:C0OPS"}, "announce", "")
+	flagSet.Duration("announce_window", 100*time.Millisecond, "")
+	flagSet.String("acl", "allow announcements = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	reg := &flakyRegistry{Registry: memory.NewRegistry()}
+
+	// registered before the bot starts so not announced
+	idle := &registry.Service{Name: "go.micro.srv.idle", Version: "latest", Nodes: []*registry.Node{{Id: "idle-1"}}}
+	reg.Register(idle)
+
+	service := micro.NewService(
+		micro.Registry(reg),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, map[string]command.Command{}, service)
+	c := &serialConn{Conn: io, input: "test"}
+	bot.conns["test"] = c
+
+	// waits for the watch to be established
+	watched := func(n int) {
+		for i := 0; reg.watching() < n; i++ {
+			if i == 100 {
+				t.Fatalf("expected %d watches got %d", n, reg.watching())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		// and the services synced
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	announced := func(expect string) {
+		select {
+		case ev := <-io.send:
+			if ev.To != "C0OPS:" || string(ev.Data) != expect {
+				t.Fatalf("expected %q got %q to %s", expect, string(ev.Data), ev.To)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q to be announced", expect)
+		}
+	}
+
+	nothing := func() {
+		select {
+		case ev := <-io.send:
+			t.Fatalf("unexpected announcement %q", string(ev.Data))
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	bot.announcer.start()
+	watched(1)
+
+	node := func(id string) *registry.Node {
+		return &registry.Node{Id: id, Address: "10.0.0.1", Port: 8080}
+	}
+	greeter := func(nodes ...*registry.Node) *registry.Service {
+		return &registry.Service{Name: "go.micro.srv.greeter", Version: "1.2", Nodes: nodes}
+	}
+
+	reg.Register(greeter(node("greeter-1"), node("greeter-2"), node("greeter-3")))
+	announced("service go.micro.srv.greeter v1.2 registered, 3 nodes")
+
+	// a node churning is announced once
+	for i := 0; i < 5; i++ {
+		reg.Deregister(greeter(node("greeter-3")))
+		reg.Register(greeter(node("greeter-3")))
+	}
+	reg.Deregister(greeter(node("greeter-3")))
+	reg.Register(greeter(node("greeter-4")))
+	announced("service go.micro.srv.greeter v1.2 updated, 3 nodes (+1 -1)")
+
+	// registering again as services do to stay registered isn't news
+	reg.Register(greeter(node("greeter-1")))
+	nothing()
+
+	// changes while the watch is down are announced once it's back
+	reg.drop()
+	reg.Deregister(greeter(node("greeter-1"), node("greeter-2"), node("greeter-4")))
+	time.Sleep(50 * time.Millisecond)
+	reg.up()
+	watched(2)
+	announced("service go.micro.srv.greeter deregistered")
+
+	for _, d := range []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C0OPS:U2", "announcements pause", "permission denied: test:U2 may not run 'announcements'"},
+		{"C0DEV:U1", "announcements pause", "error executing cmd: announcements aren't posted to test:C0DEV"},
+		{"C0OPS:U1", "announcements pause", "paused announcements to test:C0OPS"},
+		{"C0OPS:U1", "announcements", "services changing are announced after 100ms\nCHANNEL     STATUS\ntest:C0OPS  paused"},
+	} {
+		if rsp := send(d.from, d.text); rsp != d.expect {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, rsp)
+		}
+	}
+
+	reg.Register(greeter(node("greeter-5")))
+	nothing()
+
+	if rsp := send("C0DEV:U1", "announcements resume test:C0OPS"); rsp != "resumed announcements to test:C0OPS" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	reg.Deregister(idle)
+	announced("service go.micro.srv.idle deregistered")
+
+	// the watch stops with the bot
+	reg.Register(idle)
+	if err := bot.stop(); err != nil {
+		t.Fatal(err)
+	}
+	nothing()
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/audit"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+const (
+	// auditPattern matches audit alone or followed by filters
+	auditPattern = "^audit( |$)"
+	// records listed by audit unless limited otherwise
+	auditLimit = 20
+)
+
+// set by SetAuditStore, used in place of audit_file
+var auditStore audit.Store
+
+// SetAuditStore sets the store commands are recorded in, in place of
+// the audit_file. It must be called before the bot is run.
+func SetAuditStore(s audit.Store) {
+	auditStore = s
+}
+
+// loadAudit returns the store set with SetAuditStore or the audit_file
+// flag, nil if neither is set
+func loadAudit(ctx *cli.Context) (audit.Store, error) {
+	if auditStore != nil {
+		return auditStore, nil
+	}
+
+	path := ctx.String("audit_file")
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	return audit.NewFileStore(path)
+}
+
+// record counts the command and writes it to the audit store. Failing
+// to write the record doesn't fail the command.
+func (b *bot) record(c input.Conn, ev input.Event, name string, args []string, start time.Time, size int, err error) {
+	var in string
+	if sc, ok := c.(*serialConn); ok {
+		in = sc.input
+	}
+
+	b.metrics.observe(in, name, start, err)
+
+	if b.audit == nil {
+		return
+	}
+	_, channel := requester(ev)
+
+	r := audit.Record{
+		Input:     in,
+		Principal: principal(c, ev),
+		Channel:   channel,
+		Command:   name,
+		Args:      args,
+		Size:      size,
+		Start:     start,
+		End:       time.Now(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if err := b.audit.Write(r); err != nil {
+		log.Logf("[bot] error writing audit record for %s: %v", name, err)
+	}
+}
+
+// parseTime parses a time as a duration ago, a date or RFC3339
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use a duration ago e.g. 24h, a date e.g. 2006-01-02 or RFC3339", s)
+}
+
+// parseFilter parses filters of the form key=value
+func parseFilter(args []string, now time.Time) (audit.Filter, error) {
+	f := audit.Filter{Limit: auditLimit}
+
+	for _, a := range args {
+		parts := strings.SplitN(a, "=", 2)
+		if len(parts) != 2 || len(parts[1]) == 0 {
+			return f, fmt.Errorf("invalid filter %q, filters are key=value", a)
+		}
+
+		var err error
+
+		switch v := parts[1]; parts[0] {
+		case "user":
+			f.Principal = v
+		case "command":
+			f.Command = v
+		case "contains":
+			f.Contains = v
+		case "since":
+			f.Since, err = parseTime(v, now)
+		case "until":
+			f.Until, err = parseTime(v, now)
+		case "limit":
+			f.Limit, err = strconv.Atoi(v)
+			if err == nil && f.Limit <= 0 {
+				err = fmt.Errorf("limit must be positive")
+			}
+		default:
+			err = fmt.Errorf("unknown filter %q", parts[0])
+		}
+
+		if err != nil {
+			return f, err
+		}
+	}
+
+	return f, nil
+}
+
+// auditCommand returns the command searching the store
+func auditCommand(store audit.Store) command.Command {
+	usage := "audit [user=<principal>] [command=<name>] [contains=<text>] [since=<time>] [until=<time>] [limit=<n>]"
+	desc := "Returns the commands run matching the filters, latest first"
+
+	return command.NewContextCommand("audit", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		f, err := parseFilter(args[1:], time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := store.Search(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(records) == 0 {
+			return []byte("no commands found"), nil
+		}
+
+		var lines []string
+		for _, r := range records {
+			who := r.Principal
+			if len(who) == 0 {
+				who = "unknown"
+			}
+
+			result := "ok"
+			if len(r.Error) > 0 {
+				result = "error: " + r.Error
+			}
+
+			lines = append(lines, fmt.Sprintf("%s  %s  %s  %s", r.Start.UTC().Format(time.RFC3339), who, strings.Join(r.Args, " "), result))
+		}
+
+		return command.Reply(ctx, &command.Response{
+			Text: fmt.Sprintf("%d commands", len(records)),
+			Code: strings.Join(lines, "\n"),
+		}), nil
+	})
+}
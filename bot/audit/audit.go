@@ -0,0 +1,85 @@
+// Package audit records the commands run through the bot so they can be
+// searched later
+package audit
+
+import (
+	"strings"
+	"time"
+)
+
+// Record is a command run through the bot
+type Record struct {
+	// name of the input the command came from
+	Input string `json:"input"`
+	// who ran it e.g. slack:U123, empty if unknown
+	Principal string `json:"principal,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	// namespaced name of the command e.g. registry list
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// bytes in the result
+	Size  int       `json:"size"`
+	Error string    `json:"error,omitempty"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Filter selects records. Empty fields match any record.
+type Filter struct {
+	Principal string
+	// namespaced name of the command
+	Command string
+	// text in the args
+	Contains string
+	// started at or after
+	Since time.Time
+	// started before
+	Until time.Time
+	// most records returned, 0 for all
+	Limit int
+}
+
+// Store writes and searches records
+type Store interface {
+	Write(Record) error
+	// Search returns the records matching the filter, latest first
+	Search(Filter) ([]Record, error)
+}
+
+// Match returns true if the record is selected by the filter
+func (f Filter) Match(r Record) bool {
+	if len(f.Principal) > 0 && f.Principal != r.Principal {
+		return false
+	}
+	if len(f.Command) > 0 && !strings.EqualFold(f.Command, r.Command) {
+		return false
+	}
+	if len(f.Contains) > 0 && !strings.Contains(strings.Join(r.Args, " "), f.Contains) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Start.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// latest returns the records in order written matching the filter,
+// latest first
+func latest(records []Record, f Filter) []Record {
+	var matched []Record
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if !f.Match(records[i]) {
+			continue
+		}
+		matched = append(matched, records[i])
+		if f.Limit > 0 && len(matched) == f.Limit {
+			break
+		}
+	}
+
+	return matched
+}
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilter(t *testing.T) {
+	start := time.Date(2026, 10, 6, 10, 0, 0, 0, time.UTC)
+
+	r := Record{
+		Principal: "slack:U1",
+		Command:   "deregister",
+		Args:      []string{"deregister", "service", "go.micro.srv.x"},
+		Start:     start,
+	}
+
+	testData := []struct {
+		filter Filter
+		match  bool
+	}{
+		{Filter{}, true},
+		{Filter{Principal: "slack:U1"}, true},
+		{Filter{Principal: "slack:U2"}, false},
+		{Filter{Command: "Deregister"}, true},
+		{Filter{Command: "register"}, false},
+		{Filter{Contains: "go.micro.srv.x"}, true},
+		{Filter{Contains: "go.micro.srv.y"}, false},
+		{Filter{Since: start}, true},
+		{Filter{Since: start.Add(time.Second)}, false},
+		{Filter{Until: start.Add(time.Second)}, true},
+		{Filter{Until: start}, false},
+		{Filter{Principal: "slack:U1", Since: start.Add(-time.Hour), Until: start.Add(time.Hour)}, true},
+	}
+
+	for _, d := range testData {
+		if m := d.filter.Match(r); m != d.match {
+			t.Fatalf("%+v: expected %t got %t", d.filter, d.match, m)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file, err := NewFileStore(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 6, 10, 0, 0, 0, time.UTC)
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "file": file} {
+		for i, user := range []string{"slack:U1", "slack:U2", "slack:U1", "irc:john!j@h"} {
+			r := Record{
+				Input:     strings.Split(user, ":")[0],
+				Principal: user,
+				Command:   "echo",
+				Args:      []string{"echo", string('a' + rune(i))},
+				Size:      1,
+				Start:     start.Add(time.Duration(i) * time.Hour),
+				End:       start.Add(time.Duration(i)*time.Hour + time.Second),
+			}
+			if i == 3 {
+				r.Error = "failed"
+			}
+			if err := store.Write(r); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		testData := []struct {
+			filter Filter
+			args   string
+		}{
+			// latest first
+			{Filter{}, "d c b a"},
+			{Filter{Limit: 2}, "d c"},
+			{Filter{Principal: "slack:U1"}, "c a"},
+			{Filter{Principal: "slack:U1", Limit: 1}, "c"},
+			{Filter{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour)}, "c b"},
+			{Filter{Command: "list"}, ""},
+		}
+
+		for _, d := range testData {
+			records, err := store.Search(d.filter)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			var args []string
+			for _, r := range records {
+				args = append(args, r.Args[1])
+			}
+
+			if got := strings.Join(args, " "); got != d.args {
+				t.Fatalf("%s %+v: expected %q got %q", name, d.filter, d.args, got)
+			}
+		}
+
+		records, _ := store.Search(Filter{Limit: 1})
+		if r := records[0]; r.Input != "irc" || r.Error != "failed" || r.Size != 1 || !r.End.Equal(start.Add(3*time.Hour+time.Second)) {
+			t.Fatalf("%s: unexpected record %+v", name, r)
+		}
+	}
+
+	// the file is appended to by new stores
+	reopened, err := NewFileStore(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records, err := reopened.Search(Filter{}); err != nil || len(records) != 4 {
+		t.Fatalf("expected 4 records got %d %v", len(records), err)
+	}
+
+	if _, err := NewFileStore(filepath.Join(dir, "missing", "audit.log")); err == nil {
+		t.Fatal("expected an error for a missing directory")
+	}
+}
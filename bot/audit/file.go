@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// fileStore appends records to a file as JSON lines. The file is opened
+// for each write so it can be rotated.
+type fileStore struct {
+	sync.Mutex
+	path string
+}
+
+// NewFileStore returns a store of JSON lines in the file at path,
+// created if it doesn't exist
+func NewFileStore(path string) (Store, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	return &fileStore{path: path}, nil
+}
+
+func (s *fileStore) Write(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *fileStore) Search(filter Filter) ([]Record, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+
+	scanner := bufio.NewScanner(f)
+	// args can be long
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	n := 0
+	for scanner.Scan() {
+		n++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%s line %d: %v", s.path, n, err)
+		}
+
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return latest(records, Filter{Limit: filter.Limit}), nil
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"sync"
+)
+
+type memoryStore struct {
+	sync.RWMutex
+	records []Record
+}
+
+// NewMemoryStore returns a store keeping records in memory
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (m *memoryStore) Write(r Record) error {
+	m.Lock()
+	defer m.Unlock()
+	m.records = append(m.records, r)
+	return nil
+}
+
+func (m *memoryStore) Search(f Filter) ([]Record, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return latest(m.records, f), nil
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/audit"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// failingStore can't write records
+type failingStore struct {
+	audit.Store
+}
+
+func (f failingStore) Write(audit.Record) error {
+	return errors.New("disk full")
+}
+
+func TestParseFilter(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	f, err := parseFilter([]string{"user=slack:U1", "command=deregister", "contains=go.micro.srv.x", "since=24h", "until=2026-10-14", "limit=5"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Principal != "slack:U1" || f.Command != "deregister" || f.Contains != "go.micro.srv.x" || f.Limit != 5 {
+		t.Fatalf("unexpected filter %+v", f)
+	}
+	if !f.Since.Equal(now.Add(-24*time.Hour)) || !f.Until.Equal(time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected times %+v", f)
+	}
+
+	if f, _ := parseFilter(nil, now); f.Limit != auditLimit {
+		t.Fatalf("expected the default limit got %d", f.Limit)
+	}
+
+	if f, err := parseFilter([]string{"since=2026-10-13T10:00:00Z"}, now); err != nil || !f.Since.Equal(time.Date(2026, 10, 13, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected since %v %v", f.Since, err)
+	}
+
+	for _, args := range [][]string{{"user"}, {"user="}, {"who=me"}, {"since=tuesday"}, {"limit=0"}} {
+		if _, err := parseFilter(args, now); err == nil {
+			t.Fatalf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestProcessAudit(t *testing.T) {
+	store := audit.NewMemoryStore()
+	SetAuditStore(store)
+	defer SetAuditStore(nil)
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "allow audit = slack:U1; allow * = slack:*", "")
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^echo ": command.NewCommand("echo", "echo [text]", "echoes", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+		"^fail$": command.NewCommand("fail", "fail", "fails", func(args ...string) ([]byte, error) {
+			return nil, errors.New("broken")
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	c := &serialConn{Conn: io, input: "slack"}
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	send("C1:U2", "echo hello")
+	send("C1:U2", "fail")
+	send("C2:U1", "echo ops")
+
+	records, err := store.Search(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records got %+v", records)
+	}
+
+	r := records[2]
+	if r.Input != "slack" || r.Principal != "slack:U2" || r.Channel != "C1" || r.Command != "echo" ||
+		strings.Join(r.Args, " ") != "echo hello" || r.Size != 5 || len(r.Error) > 0 || r.End.Before(r.Start) {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if r := records[1]; r.Command != "fail" || r.Error != "broken" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	// only admins allowed by name see the history, not wildcards
+	if rsp := send("C1:U2", "audit"); rsp != "permission denied: slack:U2 may not run 'audit'" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	rsp := send("C2:U1", "audit user=slack:U2")
+	if !strings.HasPrefix(rsp, "2 commands\n") || !strings.Contains(rsp, "slack:U2  fail  error: broken\n") || !strings.HasSuffix(rsp, "slack:U2  echo hello  ok") {
+		t.Fatalf("unexpected audit %q", rsp)
+	}
+
+	if rsp := send("C2:U1", "audit user=slack:U9"); rsp != "no commands found" {
+		t.Fatalf("unexpected audit %q", rsp)
+	}
+	if rsp := send("C2:U1", "audit since=tuesday"); !strings.Contains(rsp, "invalid time") {
+		t.Fatalf("unexpected audit %q", rsp)
+	}
+
+	// failing to record doesn't fail the command
+	SetAuditStore(failingStore{store})
+	bot = newBot(ctx, nil, commands, service)
+
+	if rsp := send("C1:U2", "echo still"); rsp != "still" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+}
@@ -3,9 +3,10 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"regexp"
+	"runtime/debug"
 	"sort"
 	"strings"
 	"sync"
@@ -14,14 +15,30 @@ import (
 	"github.com/micro/cli"
 	"github.com/micro/go-micro"
 
-	"github.com/micro/go-bot/command"
-	"github.com/micro/go-bot/input"
 	_ "github.com/micro/go-bot/input/hipchat"
-	_ "github.com/micro/go-bot/input/slack"
 	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/audit"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+	_ "github.com/micro/micro/bot/input/broker"
+	_ "github.com/micro/micro/bot/input/console"
+	_ "github.com/micro/micro/bot/input/discord"
+	_ "github.com/micro/micro/bot/input/http"
+	_ "github.com/micro/micro/bot/input/irc"
+	_ "github.com/micro/micro/bot/input/matrix"
+	_ "github.com/micro/micro/bot/input/mattermost"
+	_ "github.com/micro/micro/bot/input/mqtt"
+	_ "github.com/micro/micro/bot/input/rocketchat"
+	_ "github.com/micro/micro/bot/input/slack"
+	_ "github.com/micro/micro/bot/input/teams"
+	_ "github.com/micro/micro/bot/input/telegram"
+	"github.com/micro/micro/bot/input/tokenize"
+	_ "github.com/micro/micro/bot/input/twilio"
+	_ "github.com/micro/micro/bot/input/xmpp"
 	botc "github.com/micro/micro/internal/command/bot"
 
-	proto "github.com/micro/go-bot/proto"
+	proto "github.com/micro/micro/bot/proto"
 )
 
 type bot struct {
@@ -30,9 +47,67 @@ type bot struct {
 	service micro.Service
 
 	sync.RWMutex
-	inputs   map[string]input.Input
+	// inputs known by name, those in loops are running
+	inputs map[string]input.Input
+	loops  map[string]*inputLoop
+	// serializes starting and stopping inputs
+	toggle sync.Mutex
+	// commands registered rather than advertised by services
+	static   map[string]command.Command
 	commands map[string]command.Command
 	services map[string]string
+	// commands services registered over rpc
+	remote *registrations
+	// commands the services advertise in their endpoints' metadata
+	advertised map[string]command.Command
+	// normalized command name, prefixed by its group if it has one, to
+	// the pattern
+	names map[string]string
+	// normalized name of grouped commands to the groups having it
+	groups map[string][]string
+	// normalized alias to the words of the command name
+	aliases map[string][]string
+	// who may run the commands
+	acl *acl
+	// how often users may run commands
+	limits *rateLimiter
+	// where the commands run are recorded, may be nil
+	audit audit.Store
+	// runs commands on a schedule, may be nil
+	schedules *scheduler
+	// conversations waiting for answers to prompts
+	sessions *sessions
+	// topics of the broker relayed to channels
+	relays *relays
+	// posts services changing to channels, may be nil
+	announcer *announcer
+	// conns of the running inputs by name
+	conns map[string]input.Conn
+	// counts the commands executed and events received
+	metrics *metrics
+
+	// bounds the commands executing at once
+	workers chan bool
+	wg      sync.WaitGroup
+	// default deadline for executing a command
+	timeout time.Duration
+	// commands execute with a context derived from base, cancelled
+	// once they're abandoned on stop
+	base   context.Context
+	cancel context.CancelFunc
+}
+
+// limiter is implemented by conns which limit the characters sent in a
+// message
+type limiter interface {
+	MessageLimit() int
+}
+
+// notifier is implemented by conns which let users know when a
+// command is running. The returned func is called with the result
+// once execution completes.
+type notifier interface {
+	Notify(ev input.Event) func(error)
 }
 
 var (
@@ -40,6 +115,12 @@ var (
 	Name = "go.micro.bot"
 	// Namespace for commands
 	Namespace = "go.micro.bot"
+	// Default number of commands executed concurrently
+	DefaultWorkers = 10
+	// How long stop waits for executing commands by default
+	StopTimeout = 10 * time.Second
+	// Default deadline for executing a command
+	DefaultTimeout = 30 * time.Second
 	// map pattern:command
 	commands = map[string]func(*cli.Context) command.Command{
 		"^echo ":                             botc.Echo,
@@ -48,51 +129,230 @@ var (
 		"^ping$":                             botc.Ping,
 		"^list ":                             botc.List,
 		"^get ":                              botc.Get,
-		"^health ":                           botc.Health,
-		"^call ":                             botc.Call,
-		"^query ":                            botc.Call,
 		"^register ":                         botc.Register,
 		"^deregister ":                       botc.Deregister,
 		"^(the )?three laws( of robotics)?$": botc.ThreeLaws,
 	}
 )
 
-func help(commands map[string]command.Command, serviceCommands []string) command.Command {
-	usage := "help"
-	desc := "Displays help for all known commands"
+func newBot(ctx *cli.Context, inputs map[string]input.Input, commands map[string]command.Command, service micro.Service) *bot {
+	if inputs == nil {
+		inputs = make(map[string]input.Input)
+	}
 
-	var cmds []command.Command
+	b := &bot{
+		ctx:      ctx,
+		exit:     make(chan bool),
+		service:  service,
+		inputs:   inputs,
+		loops:    make(map[string]*inputLoop),
+		services: make(map[string]string),
+		conns:    make(map[string]input.Conn),
+		metrics:  newMetrics(),
+		remote:   newRegistrations(),
+	}
 
-	for _, cmd := range commands {
-		cmds = append(cmds, cmd)
+	// inputs reply from the catalog too, so it's shared
+	catalog, err := loadCatalog(ctx)
+	if err != nil {
+		log.Logf("[bot] replying in English: %v", err)
+		catalog = i18n.New()
 	}
+	i18n.Use(catalog)
 
-	sort.Sort(sortedCommands{cmds})
+	// fail closed, run rejects the same flags on startup
+	rules, err := loadACL(ctx)
+	if err != nil {
+		log.Logf("[bot] denying all commands: %v", err)
+		rules = &acl{denyAll: true}
+	}
+	// only admins allowed by the acl see the history, schedule, start
+	// or stop inputs, call or publish to anything and pause announcements
+	rules.restrict("audit", "schedule", "inputs", "call", "publish", "announcements")
 
-	return command.NewCommand("help", usage, desc, func(args ...string) ([]byte, error) {
-		response := []string{"\n"}
-		for _, cmd := range cmds {
-			response = append(response, fmt.Sprintf("%s - %s", cmd.Usage(), cmd.Description()))
+	store, err := loadAudit(ctx)
+	if err != nil {
+		log.Logf("[bot] not auditing commands: %v", err)
+	}
+	if store != nil {
+		commands[auditPattern] = auditCommand(store)
+	}
+
+	schedules, err := loadScheduler(ctx)
+	if err != nil {
+		log.Logf("[bot] not scheduling commands: %v", err)
+	}
+	if schedules != nil {
+		commands[schedulePattern] = scheduleCommand(schedules)
+	}
+
+	announcer, err := loadAnnouncer(ctx, b, service.Client().Options().Registry)
+	if err != nil {
+		log.Logf("[bot] not announcing services: %v", err)
+	}
+	if announcer != nil {
+		commands[announcementsPattern] = announcementsCommand(announcer)
+		b.announcer = announcer
+	}
+
+	// there's no one to run it otherwise
+	if rules.allows("inputs") {
+		commands[inputsPattern] = inputsCommand(b)
+	}
+
+	reg := service.Client().Options().Registry
+	b.relays = newRelays(b, service.Client().Options().Broker)
+
+	given := make(map[string]bool)
+	for _, cmd := range commands {
+		given[command.FullName(cmd)] = true
+	}
+
+	// built in unless replaced by one of the commands given, filters for
+	// the output of commands piped to them and what's registered
+	for pattern, cmd := range map[string]command.Command{
+		grepPattern:     grepCommand(),
+		headPattern:     headCommand(),
+		countPattern:    countCommand(),
+		servicesPattern: servicesCommand(reg),
+		servicePattern:  serviceCommand(reg),
+		healthPattern:   healthCommand(reg, service.Client()),
+		// query is what call was known as
+		callPattern:          command.WithAliases(callCommand(service.Client(), ctx.Duration("call_timeout")), "query"),
+		publishPattern:       publishCommand(service.Client()),
+		subscribePattern:     subscribeCommand(b.relays),
+		unsubscribePattern:   unsubscribeCommand(b.relays),
+		subscriptionsPattern: subscriptionsCommand(b.relays),
+	} {
+		if !given[command.FullName(cmd)] {
+			commands[pattern] = cmd
 		}
-		response = append(response, serviceCommands...)
-		return []byte(strings.Join(response, "\n")), nil
+	}
+
+	commands[helpPattern] = help(commands, nil)
+
+	// every input runs commands through the registered wrappers
+	for pattern, cmd := range commands {
+		commands[pattern] = command.Wrap(cmd)
+	}
+
+	names, groups := nameIndex(commands)
+
+	limits, err := loadRateLimiter(ctx)
+	if err != nil {
+		log.Logf("[bot] ignoring rate limits: %v", err)
+	}
+
+	workers := ctx.Int("workers")
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	timeout := ctx.Duration("command_timeout")
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	b.static = commands
+	b.commands = commands
+	b.names = names
+	b.groups = groups
+	b.reindex(commands)
+	b.acl = rules
+	b.limits = limits
+	b.audit = store
+	b.workers = make(chan bool, workers)
+	b.timeout = timeout
+	b.sessions = newSessions(ctx.Duration("session_timeout"))
+	b.base, b.cancel = context.WithCancel(context.Background())
+
+	if schedules != nil {
+		schedules.bot = b
+		b.schedules = schedules
+	}
+
+	return b
+}
+
+// conn returns the conn of the running input
+func (b *bot) conn(name string) (input.Conn, bool) {
+	b.RLock()
+	defer b.RUnlock()
+	c, ok := b.conns[name]
+	return c, ok
+}
+
+// serialConn serializes sends since conns needn't support concurrent use
+type serialConn struct {
+	input.Conn
+	sync.Mutex
+	// name of the input
+	input string
+
+	// closed once whether by the loop or the input stopping
+	once sync.Once
+	err  error
+
+	// commands received from the conn still executing
+	busy sync.WaitGroup
+}
+
+func (s *serialConn) Send(ev *input.Event) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.Conn.Send(ev)
+}
+
+func (s *serialConn) Close() error {
+	s.once.Do(func() {
+		s.err = s.Conn.Close()
 	})
+	return s.err
 }
 
-func newBot(ctx *cli.Context, inputs map[string]input.Input, commands map[string]command.Command, service micro.Service) *bot {
-	commands["^help$"] = help(commands, nil)
+// drain waits for the commands received from the conn to reply up to
+// the timeout, returning false if they didn't
+func (s *serialConn) drain(timeout time.Duration) bool {
+	done := make(chan bool)
 
-	return &bot{
-		ctx:      ctx,
-		exit:     make(chan bool),
-		service:  service,
-		commands: commands,
-		inputs:   inputs,
-		services: make(map[string]string),
+	go func() {
+		s.busy.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
-func (b *bot) loop(io input.Input) {
+func (s *serialConn) Notify(ev input.Event) func(error) {
+	return notify(s.Conn, ev)
+}
+
+func (s *serialConn) Progress(ev input.Event) (func([]string), time.Duration) {
+	p, ok := s.Conn.(progresser)
+	if !ok {
+		return nil, 0
+	}
+
+	update, interval := p.Progress(ev)
+	if update == nil {
+		return nil, 0
+	}
+
+	return func(lines []string) {
+		s.Lock()
+		defer s.Unlock()
+		update(lines)
+	}, interval
+}
+
+func (b *bot) loop(io input.Input, l *inputLoop) {
+	defer close(l.done)
+
 	log.Logf("[bot][loop] starting %s", io.String())
 
 	for {
@@ -100,56 +360,331 @@ func (b *bot) loop(io input.Input) {
 		case <-b.exit:
 			log.Logf("[bot][loop] exiting %s", io.String())
 			return
+		case <-l.exit:
+			log.Logf("[bot][loop] exiting %s", io.String())
+			return
 		default:
-			if err := b.run(io); err != nil {
+			if err := b.run(io, l.exit); err != nil {
 				log.Logf("[bot][loop] error %v", err)
-				time.Sleep(time.Second)
+				// reconnect after a second unless stopped
+				select {
+				case <-b.exit:
+				case <-l.exit:
+				case <-time.After(time.Second):
+				}
 			}
 		}
 	}
 }
 
-func (b *bot) process(c input.Conn, ev input.Event) error {
-	args := strings.Split(string(ev.Data), " ")
-	if len(args) == 0 {
-		return nil
+// notify tells the conn a command is executing for ev if it supports it
+func notify(c input.Conn, ev input.Event) func(error) {
+	if n, ok := c.(notifier); ok {
+		return n.Notify(ev)
 	}
+	return func(error) {}
+}
 
-	b.RLock()
-	defer b.RUnlock()
+// normalize lowercases the command name and strips trailing punctuation
+// so "Help?" matches the same command as "help"
+func normalize(name string) string {
+	return strings.TrimRight(strings.ToLower(name), "?!.")
+}
+
+// lookup returns the command of the namespaced name matching data.
+// Patterns which don't start with their command's name are matched as
+// a fallback.
+func (b *bot) lookup(name string, data []byte) (command.Command, bool) {
+	if pattern, ok := b.names[name]; ok {
+		if match(pattern, b.commands[pattern], data) {
+			return b.commands[pattern], true
+		}
+	}
 
-	// try built in command
 	for pattern, cmd := range b.commands {
-		// skip if it doesn't match
-		if m, err := regexp.Match(pattern, ev.Data); err != nil || !m {
-			continue
+		if match(pattern, cmd, data) {
+			return cmd, true
 		}
+	}
 
-		// matched, exec command
-		rsp, err := cmd.Exec(args...)
-		if err != nil {
-			rsp = []byte("error executing cmd: " + err.Error())
+	return nil, false
+}
+
+// unknown returns the response for a command which doesn't exist
+func (b *bot) unknown(o origin, name string) []byte {
+	var names []string
+	for n := range b.names {
+		names = append(names, n)
+	}
+	for n := range b.groups {
+		names = append(names, n)
+	}
+	for service := range b.services {
+		names = append(names, strings.TrimPrefix(service, Namespace+"."))
+	}
+
+	return unknownCommand(o, name, names)
+}
+
+// unknownCommand returns the response for a command which doesn't
+// exist, suggesting those of the names close to it
+func unknownCommand(o origin, name string, names []string) []byte {
+	if s := suggest(name, names, 3); len(s) > 0 {
+		return o.say(i18n.UnknownCommandSuggest, i18n.Params{"name": name, "suggestions": strings.Join(s, ", ")})
+	}
+
+	return o.say(i18n.UnknownCommand, i18n.Params{"name": name})
+}
+
+// timeoutError is returned when a command exceeds its deadline
+type timeoutError struct {
+	name    string
+	timeout time.Duration
+}
+
+func (t timeoutError) Error() string {
+	return fmt.Sprintf("command '%s' timed out after %v", t.name, t.timeout)
+}
+
+// crashError is returned when a command panics
+type crashError struct {
+	name  string
+	value interface{}
+}
+
+func (c crashError) Error() string {
+	return fmt.Sprintf("command '%s' crashed: %v", c.name, c.value)
+}
+
+// maxStack is how much of a panic's stack trace is logged
+const maxStack = 4096
+
+// stack returns the current goroutine's stack trace truncated to maxStack
+func stack() []byte {
+	s := debug.Stack()
+	if len(s) <= maxStack {
+		return s
+	}
+	return append(s[:maxStack], "\n..."...)
+}
+
+// commandTimeout returns the deadline for the named command. The default
+// can be overridden per command e.g MICRO_BOT_COMMAND_TIMEOUT_DEREGISTER=2m
+func (b *bot) commandTimeout(name string) time.Duration {
+	key := "MICRO_BOT_COMMAND_TIMEOUT_" + strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
 		}
+		return '_'
+	}, strings.ToUpper(name))
 
-		// send response
-		return c.Send(&input.Event{
-			Meta: ev.Meta,
-			From: ev.To,
-			To:   ev.From,
-			Type: input.TextEvent,
-			Data: rsp,
-		})
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return b.timeout
 	}
 
-	// no built in match
-	// try service commands
-	service := Namespace + "." + args[0]
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Logf("[bot] invalid timeout %s=%s, using %v", key, v, b.timeout)
+		return b.timeout
+	}
+
+	return d
+}
+
+// requester returns the user and channel of the event. Inputs set From
+// to channel:user, or the user alone for direct messages.
+func requester(ev input.Event) (string, string) {
+	if i := strings.LastIndex(ev.From, ":"); i >= 0 {
+		return ev.From[i+1:], ev.From[:i]
+	}
+	return ev.From, ""
+}
+
+// execContext returns the context commands for ev execute with
+func (b *bot) execContext(c input.Conn, ev input.Event) context.Context {
+	var name string
+	if sc, ok := c.(*serialConn); ok {
+		name, c = sc.input, sc.Conn
+	}
+
+	user, channel := requester(ev)
+	ctx := command.NewContext(b.base, name, user, channel)
+
+	if l, ok := c.(limiter); ok {
+		ctx = command.WithMessageLimit(ctx, l.MessageLimit())
+	}
+
+	return ctx
+}
+
+// execute runs the command returning a timeoutError if it doesn't
+// complete in time. The context is cancelled and a late result is
+// discarded.
+func execute(ctx context.Context, cmd command.Command, name string, timeout time.Duration, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		rsp []byte
+		err error
+	}
+
+	// buffered so an abandoned command doesn't block
+	ch := make(chan result, 1)
+
+	go func() {
+		// a panicking command fails rather than taking down the bot
+		defer func() {
+			if r := recover(); r != nil {
+				log.Logf("[bot] command %s crashed: %v\n%s", name, r, stack())
+				ch <- result{nil, crashError{name, r}}
+			}
+		}()
+
+		rsp, err := command.ExecContext(ctx, cmd, args...)
+		ch <- result{rsp, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.rsp, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, timeoutError{name, timeout}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// errorResponse returns the reply for a failed command
+func errorResponse(o origin, err error) []byte {
+	switch e := err.(type) {
+	case timeoutError:
+		return o.say(i18n.CommandTimeout, i18n.Params{"name": e.name, "timeout": e.timeout})
+	case crashError:
+		return o.say(i18n.CommandCrashed, i18n.Params{"name": e.name, "error": e.value})
+	case unavailableError:
+		return o.say(i18n.CommandUnavailable, i18n.Params{"name": e.name})
+	case permissionError:
+		if len(e.principal) == 0 {
+			return o.say(i18n.PermissionDeniedUnknown, i18n.Params{"name": e.name})
+		}
+		return o.say(i18n.PermissionDenied, i18n.Params{"name": e.name, "principal": e.principal})
+	}
+	return o.say(i18n.ErrorExecuting, i18n.Params{"error": err.Error()})
+}
+
+// respond sends data in reply to ev
+func respond(c input.Conn, ev input.Event, data []byte) error {
+	return c.Send(&input.Event{
+		Meta: ev.Meta,
+		From: ev.To,
+		To:   ev.From,
+		Type: input.TextEvent,
+		Data: data,
+	})
+}
+
+// respondRich sends the response of a rich command in reply to ev. data
+// is the response rendered as plain text.
+func respondRich(c input.Conn, ev input.Event, data []byte, rsp *command.Response) error {
+	meta := make(map[string]interface{}, len(ev.Meta)+1)
+	for k, v := range ev.Meta {
+		meta[k] = v
+	}
+	meta[command.MetaResponse] = rsp
 
-	// is there a service for the command?
-	if _, ok := b.services[service]; !ok {
+	ev.Meta = meta
+	return respond(c, ev, data)
+}
+
+func (b *bot) process(c input.Conn, ev input.Event) error {
+	cmds, err := tokenize.SplitPipeline(string(ev.Data))
+	if err != nil {
+		return respond(c, ev, originOf(c, ev).say(i18n.ErrorParsing, i18n.Params{"error": err.Error()}))
+	}
+	if len(cmds) == 0 {
 		return nil
 	}
 
+	// an answer to a prompt goes back to the command which asked, pipes
+	// and all
+	if key, ok := sessionKeyOf(c, ev); ok {
+		if sess := b.sessions.take(key); sess != nil {
+			args, _ := tokenize.Split(string(ev.Data))
+			return b.resume(c, ev, sess, args)
+		}
+	}
+
+	if len(cmds) > 1 {
+		return b.pipe(c, ev, cmds)
+	}
+
+	return b.runCommand(c, ev, cmds[0])
+}
+
+// runCommand runs the command of the args, built in or a service, and
+// replies with the response
+func (b *bot) runCommand(c input.Conn, ev input.Event, args []string) error {
+	args[0] = normalize(args[0])
+
+	// copy out what's needed so commands run without the lock
+	b.RLock()
+
+	// aliases run the command they stand for
+	if name, ok := b.aliases[args[0]]; ok {
+		args = append(append([]string{}, name...), args[1:]...)
+	}
+
+	// the group is dropped from the args, commands see their name first
+	name, args, options := b.resolve(args)
+	if len(options) > 0 {
+		b.RUnlock()
+		return respond(c, ev, ambiguousCommand(originOf(c, ev), args[0], options))
+	}
+
+	data := []byte(strings.Join(args, " "))
+	service := Namespace + "." + args[0]
+
+	cmd, ok := b.lookup(name, data)
+	_, isService := b.services[service]
+
+	var reply []byte
+	if !ok && !isService {
+		// known command used incorrectly
+		if pattern, known := b.names[name]; known {
+			reply = []byte("usage: " + command.Usage(b.commands[pattern]))
+		} else {
+			reply = b.unknown(originOf(c, ev), args[0])
+		}
+	}
+	b.RUnlock()
+
+	timeout := b.commandTimeout(args[0])
+
+	// the same checks whichever input the command came from
+	if ok || isService {
+		if admitted, err := b.admit(c, ev, name); !admitted {
+			return err
+		}
+	}
+
+	// try built in command
+	if ok {
+		// missing required args, the command isn't run
+		if err := command.Validate(cmd, args); err != nil {
+			return respond(c, ev, []byte(err.Error()))
+		}
+
+		return b.invoke(c, ev, cmd, name, args, nil)
+	}
+
+	// no built in match or service for the command
+	if !isService {
+		return respond(c, ev, reply)
+	}
+
 	// make service request
 	req := b.service.Client().NewRequest(service, "Command.Exec", &proto.ExecRequest{
 		Args: args,
@@ -158,47 +693,177 @@ func (b *bot) process(c input.Conn, ev input.Event) error {
 
 	var response []byte
 
+	ctx, cancel := context.WithTimeout(b.base, timeout)
+	defer cancel()
+
 	// call service
-	if err := b.service.Client().Call(context.Background(), req, rsp); err != nil {
-		response = []byte("error executing cmd: " + err.Error())
-	} else if len(rsp.Error) > 0 {
-		response = []byte("error executing cmd: " + rsp.Error)
+	start := time.Now()
+	done := notify(c, ev)
+	err := b.service.Client().Call(ctx, req, rsp)
+	if ctx.Err() == context.DeadlineExceeded {
+		err = timeoutError{args[0], timeout}
+	} else if err == nil && len(rsp.Error) > 0 {
+		err = errors.New(rsp.Error)
+	}
+	done(err)
+	b.record(c, ev, name, args, start, len(rsp.Result), err)
+
+	if err != nil {
+		response = errorResponse(originOf(c, ev), err)
 	} else {
 		response = rsp.Result
 	}
 
 	// send response
-	return c.Send(&input.Event{
-		Meta: ev.Meta,
-		From: ev.To,
-		To:   ev.From,
-		Type: input.TextEvent,
-		Data: response,
-	})
+	return respond(c, ev, response)
 }
 
-func (b *bot) run(io input.Input) error {
+// admit returns false if the sender may not run the command of the
+// namespaced name now, having replied if a reply is due
+func (b *bot) admit(c input.Conn, ev input.Event, name string) (bool, error) {
+	user := principal(c, ev)
+	if err := b.acl.check(name, user); err != nil {
+		return false, respond(c, ev, errorResponse(originOf(c, ev), err))
+	}
+
+	// unknown users are limited by where they're messaging from
+	if len(user) == 0 {
+		user = ev.From
+	}
+	if allowed, wait, reply := b.limits.take(user, name); !allowed {
+		if !reply {
+			return false, nil
+		}
+		return false, respond(c, ev, slowDown(originOf(c, ev), wait))
+	}
+
+	return true, nil
+}
+
+// invoke executes the built in command and replies with the response.
+// A command resuming a session is executed with its state. One which
+// prompts claims the sender's next message for its session.
+func (b *bot) invoke(c input.Conn, ev input.Event, cmd command.Command, name string, args []string, resumed *session) error {
+	var state interface{}
+	if resumed != nil {
+		state = resumed.state
+	}
+
+	// matched, exec command
+	ctx, prompted := command.WithSession(b.execContext(c, ev), state, resumed != nil)
+	ctx, rich := command.Capture(ctx)
+	// answers to prompts aren't the command's text
+	if resumed == nil && len(ev.Data) > 0 {
+		ctx = command.WithText(ctx, string(ev.Data))
+	}
+
+	// pass on progress to conns which show it
+	p := newProgress(c, ev)
+	if p != nil {
+		ctx = command.WithProgress(ctx, p.report)
+	}
+
+	start := time.Now()
+	done := notify(c, ev)
+	rsp, err := execute(ctx, cmd, args[0], b.commandTimeout(args[0]), args...)
+	if p != nil {
+		p.close()
+	}
+	done(err)
+	b.record(c, ev, name, args, start, len(rsp), err)
+	if err != nil {
+		return respond(c, ev, errorResponse(originOf(c, ev), err))
+	}
+
+	if next, ok := prompted(); ok {
+		if key, known := sessionKeyOf(c, ev); known {
+			b.sessions.claim(key, cmd, name, next)
+		}
+	}
+
+	// send response
+	if r := rich(); r != nil {
+		return respondRich(c, ev, rsp, r)
+	}
+	return respond(c, ev, rsp)
+}
+
+// resume passes the answer to a prompt back to the command which asked
+// unless it's to cancel
+func (b *bot) resume(c input.Conn, ev input.Event, sess *session, answer []string) error {
+	if len(answer) == 1 && normalize(answer[0]) == cancelSession {
+		// the answer is handled though nothing's run
+		notify(c, ev)(nil)
+		return respond(c, ev, originOf(c, ev).say(i18n.Cancelled, i18n.Params{"name": sess.name}))
+	}
+
+	if admitted, err := b.admit(c, ev, sess.name); !admitted {
+		return err
+	}
+
+	// commands see their name first as when started
+	args := append(strings.Fields(strings.ToLower(sess.cmd.String())), answer...)
+
+	return b.invoke(c, ev, sess.cmd, sess.name, args, sess)
+}
+
+// run receives commands from the input until the bot exits or the
+// input is stopped by closing exit
+func (b *bot) run(io input.Input, exit chan bool) error {
 	log.Logf("[bot][loop] connecting to %s", io.String())
 
-	c, err := io.Stream()
+	conn, err := io.Stream()
 	if err != nil {
 		return err
 	}
 
+	// commands reply concurrently
+	c := &serialConn{Conn: conn, input: io.String()}
+	defer b.metrics.connect(io.String())()
+
+	// scheduled commands reply through the conn
+	b.Lock()
+	b.conns[io.String()] = c
+	b.Unlock()
+
+	defer func() {
+		b.Lock()
+		if b.conns[io.String()] == c {
+			delete(b.conns, io.String())
+		}
+		b.Unlock()
+	}()
+
 	for {
 		select {
 		case <-b.exit:
 			log.Logf("[bot][loop] closing %s", io.String())
+			c.drain(b.stopTimeout())
+			return c.Close()
+		case <-exit:
+			log.Logf("[bot][loop] closing %s", io.String())
+			c.drain(b.stopTimeout())
 			return c.Close()
 		default:
 			var recvEv input.Event
 			// receive input
 			if err := c.Recv(&recvEv); err != nil {
+				c.Close()
 				return err
 			}
+			b.metrics.receive(io.String())
 
-			// only process TextEvent
-			if recvEv.Type != input.TextEvent {
+			// events received while stopping aren't processed
+			select {
+			case <-b.exit:
+				continue
+			case <-exit:
+				continue
+			default:
+			}
+
+			// only process TextEvent, the type of events without one
+			if input.TypeOf(&recvEv) != input.TextEvent {
 				continue
 			}
 
@@ -206,45 +871,197 @@ func (b *bot) run(io input.Input) error {
 				continue
 			}
 
-			if err := b.process(c, recvEv); err != nil {
-				return err
+			b.dispatch(c, recvEv)
+		}
+	}
+}
+
+// dispatch processes the event on a worker, blocking while all are
+// busy. The conn drains the commands it dispatched before it's closed.
+func (b *bot) dispatch(c input.Conn, ev input.Event) {
+	sc, ok := c.(*serialConn)
+	if ok {
+		sc.busy.Add(1)
+	}
+
+	run := b.work(ev.From, func() {
+		if ok {
+			defer sc.busy.Done()
+		}
+		if err := b.process(c, ev); err != nil {
+			log.Logf("[bot][loop] error processing %s: %v", ev.From, err)
+		}
+	})
+
+	if ok && !run {
+		sc.busy.Done()
+	}
+}
+
+// work runs fn processing a command from the sender on a worker,
+// blocking while all are busy. It returns false if the bot exits first
+// and fn isn't run.
+func (b *bot) work(from string, fn func()) bool {
+	select {
+	case <-b.exit:
+		return false
+	case b.workers <- true:
+	}
+
+	b.wg.Add(1)
+
+	go func() {
+		defer func() {
+			<-b.workers
+			b.wg.Done()
+		}()
+
+		// a panic outside of a command mustn't take down the bot either
+		defer func() {
+			if r := recover(); r != nil {
+				log.Logf("[bot][loop] panic processing %s: %v\n%s", from, r, stack())
 			}
+		}()
+
+		fn()
+	}()
+
+	return true
+}
+
+// stopTimeout returns how long stopping waits for executing commands
+func (b *bot) stopTimeout() time.Duration {
+	if d := b.ctx.Duration("stop_timeout"); d > 0 {
+		return d
+	}
+	return StopTimeout
+}
+
+// wait waits for executing commands to finish up to the timeout,
+// cancelling those which don't
+func (b *bot) wait(timeout time.Duration) {
+	done := make(chan bool)
+
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Logf("[bot] timed out waiting for commands to finish")
+		// stop those still executing
+		b.cancel()
+	}
+}
+
+// drain waits for the commands received from the running inputs to
+// reply up to the timeout, returning the inputs which didn't in time
+func (b *bot) drain(timeout time.Duration) []string {
+	b.RLock()
+	conns := make(map[string]*serialConn, len(b.conns))
+	for name, c := range b.conns {
+		if sc, ok := c.(*serialConn); ok {
+			conns[name] = sc
 		}
 	}
+	b.RUnlock()
+
+	var (
+		mtx      sync.Mutex
+		wg       sync.WaitGroup
+		timedOut []string
+	)
+
+	for name, c := range conns {
+		wg.Add(1)
+		go func(name string, c *serialConn) {
+			defer wg.Done()
+			if c.drain(timeout) {
+				return
+			}
+			mtx.Lock()
+			timedOut = append(timedOut, name)
+			mtx.Unlock()
+		}(name, c)
+	}
+
+	wg.Wait()
+	sort.Strings(timedOut)
+
+	return timedOut
 }
 
 func (b *bot) start() error {
 	log.Log("[bot] starting")
 
-	// Start inputs
-	for _, io := range b.inputs {
-		log.Logf("[bot] starting input %s", io.String())
+	b.RLock()
+	var names []string
+	for name := range b.inputs {
+		names = append(names, name)
+	}
+	b.RUnlock()
 
-		if err := io.Init(b.ctx); err != nil {
+	// Start inputs
+	for _, name := range names {
+		if err := b.startInput(name); err != nil {
 			return err
 		}
+	}
 
-		if err := io.Start(); err != nil {
-			return err
+	if addr := b.ctx.String("bot_metrics_address"); len(addr) > 0 {
+		if err := b.metrics.serve(addr); err != nil {
+			return fmt.Errorf("error serving metrics: %v", err)
 		}
-
-		go b.loop(io)
 	}
 
 	// start watcher
 	go b.watch()
 
+	if b.schedules != nil {
+		go b.schedules.run()
+	}
+
+	if b.announcer != nil {
+		b.announcer.start()
+	}
+
 	return nil
 }
 
 func (b *bot) stop() error {
 	log.Log("[bot] stopping")
+	// inputs stop receiving once the bot exits
 	close(b.exit)
 
+	// let executing commands reply, those of every input at once
+	timeout := b.stopTimeout()
+	deadline := time.Now().Add(timeout)
+
+	if names := b.drain(timeout); len(names) > 0 {
+		log.Logf("[bot] timed out draining the commands of inputs %s", strings.Join(names, ", "))
+	}
+	b.wait(deadline.Sub(time.Now()))
+
+	b.metrics.close()
+
+	// nothing's relayed or announced to the inputs once they're stopped
+	b.relays.close()
+	if b.announcer != nil {
+		b.announcer.close()
+	}
+
+	b.RLock()
+	var names []string
+	for name := range b.loops {
+		names = append(names, name)
+	}
+	b.RUnlock()
+
 	// Stop inputs
-	for _, io := range b.inputs {
-		log.Logf("[bot] stopping input %s", io.String())
-		if err := io.Stop(); err != nil {
+	for _, name := range names {
+		if err := b.stopInput(name); err != nil {
 			log.Logf("[bot] %v", err)
 		}
 	}
@@ -252,16 +1069,72 @@ func (b *bot) stop() error {
 	return nil
 }
 
-func (b *bot) watch() {
-	commands := map[string]command.Command{}
-	services := map[string]string{}
+// refresh takes the commands the services advertise, suspending those
+// registered by services which are gone, and rebuilds the commands
+func (b *bot) refresh(services map[string]string, d discovery) {
+	// held throughout so commands added by inputs starting aren't lost
+	b.Lock()
+	defer b.Unlock()
 
-	// copy commands
-	b.RLock()
-	for k, v := range b.commands {
-		commands[k] = v
+	b.remote.check(func(service string) bool {
+		_, ok := d[service]
+		return ok
+	})
+
+	advertised := make(map[string]command.Command)
+	for pattern, cmd := range d.commands(b.service.Client()) {
+		advertised[pattern] = command.Wrap(cmd)
+	}
+
+	// the watcher keeps updating the services it passed in
+	helps := make(map[string]string, len(services))
+	for service, h := range services {
+		helps[service] = h
+	}
+
+	b.advertised = advertised
+	b.services = helps
+	b.rebuild()
+}
+
+// rebuild replaces the commands with the static ones, those registered
+// and those advertised, each of which mustn't shadow those before it,
+// and rebuilds help. The bot must be locked.
+func (b *bot) rebuild() {
+	commands := make(map[string]command.Command, len(b.static))
+	registered := make(map[string]bool)
+
+	for pattern, cmd := range b.static {
+		commands[pattern] = cmd
+		registered[command.FullName(cmd)] = true
+	}
+
+	for pattern, cmd := range b.remote.commands() {
+		if _, ok := commands[pattern]; ok || registered[command.FullName(cmd)] {
+			log.Logf("[bot] ignoring registered command %s, already registered", command.FullName(cmd))
+			continue
+		}
+		commands[pattern] = cmd
+		registered[command.FullName(cmd)] = true
+	}
+
+	for pattern, cmd := range b.advertised {
+		if _, ok := commands[pattern]; ok || registered[command.FullName(cmd)] {
+			log.Logf("[bot] ignoring advertised command %s, already registered", command.FullName(cmd))
+			continue
+		}
+		commands[pattern] = cmd
 	}
-	b.RUnlock()
+
+	commands[helpPattern] = command.Wrap(help(commands, b.services))
+	b.commands = commands
+	b.names, b.groups = nameIndex(commands)
+	b.reindex(commands)
+}
+
+func (b *bot) watch() {
+	services := map[string]string{}
+	discovered := discovery{}
 
 	// getHelp retries usage and description from bot service commands
 	getHelp := func(service string) (string, error) {
@@ -286,30 +1159,33 @@ func (b *bot) watch() {
 		return fmt.Sprintf("%s - %s", rsp.Usage, rsp.Description), nil
 	}
 
-	serviceList, err := b.service.Client().Options().Registry.ListServices()
+	reg := b.service.Client().Options().Registry
+
+	serviceList, err := reg.ListServices()
 	if err != nil {
 		// log error?
 		return
 	}
 
-	var serviceCommands []string
-
 	// create service commands
 	for _, service := range serviceList {
+		// listed services needn't have their endpoints
+		if versions, err := reg.GetService(service.Name); err == nil {
+			for _, v := range versions {
+				discovered.update(v)
+			}
+		}
+
 		h, err := getHelp(service.Name)
 		if err != nil {
 			continue
 		}
 		services[service.Name] = h
-		serviceCommands = append(serviceCommands, h)
 	}
 
-	b.Lock()
-	b.commands["^help$"] = help(commands, serviceCommands)
-	b.services = services
-	b.Unlock()
+	b.refresh(services, discovered)
 
-	w, err := b.service.Client().Options().Registry.Watch()
+	w, err := reg.Watch()
 	if err != nil {
 		// log error?
 		return
@@ -328,24 +1204,19 @@ func (b *bot) watch() {
 		}
 
 		if res.Action == "delete" {
-			delete(services, res.Service.Name)
+			discovered.remove(res.Service)
+			// other versions may still be running
+			if _, ok := discovered[res.Service.Name]; !ok {
+				delete(services, res.Service.Name)
+			}
 		} else {
-			h, err := getHelp(res.Service.Name)
-			if err != nil {
-				continue
+			discovered.update(res.Service)
+			if h, err := getHelp(res.Service.Name); err == nil {
+				services[res.Service.Name] = h
 			}
-			services[res.Service.Name] = h
 		}
 
-		var serviceCommands []string
-		for _, v := range services {
-			serviceCommands = append(serviceCommands, v)
-		}
-
-		b.Lock()
-		b.commands["^help$"] = help(commands, serviceCommands)
-		b.services = services
-		b.Unlock()
+		b.refresh(services, discovered)
 	}
 }
 
@@ -363,6 +1234,11 @@ func run(ctx *cli.Context) {
 		Namespace = ctx.String("namespace")
 	}
 
+	// plugins register inputs and commands as those built in do
+	if err := loadPlugins(ctx); err != nil {
+		log.Fatalf("[bot] %v", err)
+	}
+
 	// Parse flags
 	if len(ctx.String("inputs")) == 0 {
 		log.Fatal("[bot] no inputs specified")
@@ -375,27 +1251,69 @@ func run(ctx *cli.Context) {
 
 	ios := make(map[string]input.Input)
 	cmds := make(map[string]command.Command)
+	names := make(map[string]bool)
 
 	// create built in commands
 	for pattern, cmd := range commands {
-		cmds[pattern] = cmd(ctx)
+		c := cmd(ctx)
+		cmds[command.Pattern(command.Group(c), pattern)] = c
+		names[command.FullName(c)] = true
 	}
 
-	// take other commands
-	for pattern, cmd := range command.Commands {
+	// take other commands, those with a group are namespaced by it
+	add := func(pattern string, cmd command.Command) {
 		if c, ok := cmds[pattern]; ok {
 			log.Logf("[bot] command %s already registered for pattern %s\n", c.String(), pattern)
-			continue
+			return
+		}
+		// names are matched case insensitively within their group
+		name := command.FullName(cmd)
+		if names[name] {
+			log.Logf("[bot] command %s already registered\n", name)
+			return
 		}
 		// register command
 		cmds[pattern] = cmd
+		names[name] = true
+	}
+
+	for pattern, cmd := range command.Commands {
+		add(command.Pattern(command.Group(cmd), pattern), cmd)
+	}
+	for pattern, cmd := range command.Registered() {
+		add(pattern, cmd)
+	}
+
+	// aliases mustn't shadow commands or each other
+	if _, err := aliasIndex(cmds); err != nil {
+		log.Fatalf("[bot] %v", err)
+	}
+
+	if _, err := loadACL(ctx); err != nil {
+		log.Fatalf("[bot] %v", err)
+	}
+
+	if _, err := loadCatalog(ctx); err != nil {
+		log.Fatalf("[bot] %v", err)
+	}
+
+	if _, err := loadRateLimiter(ctx); err != nil {
+		log.Fatalf("[bot] %v", err)
+	}
+
+	if _, err := loadAudit(ctx); err != nil {
+		log.Fatalf("[bot] error opening audit file: %v", err)
+	}
+
+	if _, err := loadScheduler(ctx); err != nil {
+		log.Fatalf("[bot] %v", err)
 	}
 
 	// Parse inputs
 	for _, io := range inputs {
-		i, ok := input.Inputs[io]
+		i, ok := input.Lookup(io)
 		if !ok {
-			log.Logf("[bot] input %s not found\n", i)
+			log.Logf("[bot] input %s not found\n", io)
 			os.Exit(1)
 		}
 		ios[io] = i
@@ -415,6 +1333,9 @@ func run(ctx *cli.Context) {
 	// Start bot
 	b := newBot(ctx, ios, cmds, service)
 
+	// services execute and register commands through the bot's
+	proto.RegisterCommandHandler(service.Server(), &rpcHandler{b})
+
 	if err := b.start(); err != nil {
 		log.Logf("error starting bot %v", err)
 		os.Exit(1)
@@ -435,18 +1356,133 @@ func Commands() []cli.Command {
 	flags := []cli.Flag{
 		cli.StringFlag{
 			Name:  "inputs",
-			Usage: "Inputs to load on startup",
+			Usage: "Inputs to load on startup, others can be started with the inputs command",
 		},
 		cli.StringFlag{
 			Name:   "namespace",
 			Usage:  "Set the namespace used by the bot to find commands e.g. com.example.bot",
 			EnvVar: "MICRO_BOT_NAMESPACE",
 		},
+		cli.IntFlag{
+			Name:   "workers",
+			Usage:  "Number of commands executed concurrently",
+			EnvVar: "MICRO_BOT_WORKERS",
+			Value:  DefaultWorkers,
+		},
+		cli.DurationFlag{
+			Name:   "command_timeout",
+			Usage:  "Deadline for executing a command. Override per command with MICRO_BOT_COMMAND_TIMEOUT_<NAME>",
+			EnvVar: "MICRO_BOT_COMMAND_TIMEOUT",
+			Value:  DefaultTimeout,
+		},
+		cli.DurationFlag{
+			Name:   "stop_timeout",
+			Usage:  "Grace period for executing commands to reply when stopping the bot or an input, those still executing are cancelled",
+			EnvVar: "MICRO_BOT_STOP_TIMEOUT",
+			Value:  StopTimeout,
+		},
+		cli.StringFlag{
+			Name:   "acl",
+			Usage:  "Rules of who may run commands e.g. \"allow deploy = slack:U123 irc:*; deny * = slack:U456\"",
+			EnvVar: "MICRO_BOT_ACL",
+		},
+		cli.StringFlag{
+			Name:   "acl_file",
+			Usage:  "File of acl rules, one per line",
+			EnvVar: "MICRO_BOT_ACL_FILE",
+		},
+		cli.StringFlag{
+			Name:   "acl_default",
+			Usage:  "Whether commands without allow rules may be run by anyone, allow or deny",
+			EnvVar: "MICRO_BOT_ACL_DEFAULT",
+			Value:  "allow",
+		},
+		cli.StringFlag{
+			Name:   "rate_limit",
+			Usage:  "Most commands a user may run in a period e.g. 5/1m, unlimited if empty",
+			EnvVar: "MICRO_BOT_RATE_LIMIT",
+		},
+		cli.StringFlag{
+			Name:   "rate_limit_commands",
+			Usage:  "Rate limits of commands overriding rate_limit e.g. deploy=1/5m,call=10/1m",
+			EnvVar: "MICRO_BOT_RATE_LIMIT_COMMANDS",
+		},
+		cli.DurationFlag{
+			Name:   "session_timeout",
+			Usage:  "How long a command asking a follow up question waits for the answer",
+			EnvVar: "MICRO_BOT_SESSION_TIMEOUT",
+			Value:  DefaultSessionTimeout,
+		},
+		cli.StringSliceFlag{
+			Name:   "announce",
+			Usage:  "Channel to announce services registering and deregistering in as <input>:<channel>, may be repeated",
+			EnvVar: "MICRO_BOT_ANNOUNCE",
+		},
+		cli.DurationFlag{
+			Name:   "announce_window",
+			Usage:  "How long the changes to a service are collected before they're announced together",
+			EnvVar: "MICRO_BOT_ANNOUNCE_WINDOW",
+			Value:  DefaultAnnounceWindow,
+		},
+		cli.DurationFlag{
+			Name:   "call_timeout",
+			Usage:  "How long the call command waits for the service to answer",
+			EnvVar: "MICRO_BOT_CALL_TIMEOUT",
+			Value:  DefaultCallTimeout,
+		},
+		cli.StringFlag{
+			Name:   "bot_language",
+			Usage:  "Language the bot replies in e.g. es, English if it has no messages in it",
+			EnvVar: "MICRO_BOT_LANGUAGE",
+			Value:  i18n.English,
+		},
+		cli.StringFlag{
+			Name:   "language_file",
+			Usage:  "JSON file of messages by language and key, adding to or replacing those built in",
+			EnvVar: "MICRO_BOT_LANGUAGE_FILE",
+		},
+		cli.StringSliceFlag{
+			Name:   "channel_language",
+			Usage:  "Language of a channel overriding bot_language as <input>:<channel>=<language>, may be repeated",
+			EnvVar: "MICRO_BOT_CHANNEL_LANGUAGE",
+		},
+		cli.StringFlag{
+			Name:   "bot_metrics_address",
+			Usage:  "Address to serve prometheus metrics of the commands executed on e.g. :9100, not served if empty",
+			EnvVar: "MICRO_BOT_METRICS_ADDRESS",
+		},
+		cli.StringFlag{
+			Name:   "bot_plugins",
+			Usage:  "Directory of plugins built with -buildmode=plugin exporting inputs and commands to load on startup",
+			EnvVar: "MICRO_BOT_PLUGINS",
+		},
+		cli.BoolFlag{
+			Name:   "bot_plugins_strict",
+			Usage:  "Exit if a plugin fails to load rather than skipping it",
+			EnvVar: "MICRO_BOT_PLUGINS_STRICT",
+		},
+		cli.StringFlag{
+			Name:   "audit_file",
+			Usage:  "File the commands run are recorded to as JSON lines, searched with the audit command",
+			EnvVar: "MICRO_BOT_AUDIT_FILE",
+		},
+		cli.StringSliceFlag{
+			Name:   "schedule",
+			Usage:  "Run a command on a schedule posting the reply to a channel e.g. \"0 9 * * * slack:C0OPS health all\"",
+			EnvVar: "MICRO_BOT_SCHEDULE",
+		},
+		cli.StringFlag{
+			Name:   "schedule_file",
+			Usage:  "File the schedules added with the schedule command are kept in",
+			EnvVar: "MICRO_BOT_SCHEDULE_FILE",
+		},
 	}
 
 	// setup input flags
-	for _, input := range input.Inputs {
-		flags = append(flags, input.Flags()...)
+	for _, name := range input.List() {
+		if i, ok := input.Lookup(name); ok {
+			flags = append(flags, i.Flags()...)
+		}
 	}
 
 	command := cli.Command{
@@ -1,15 +1,17 @@
 package bot
 
 import (
+	"context"
 	"errors"
 	"flag"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/micro/cli"
-	"github.com/micro/go-bot/command"
-	"github.com/micro/go-bot/input"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
 
 	"github.com/micro/go-micro"
 	"github.com/micro/go-micro/registry/memory"
@@ -138,3 +140,772 @@ func TestBot(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	testData := map[string]string{
+		"help":   "help",
+		"Help":   "help",
+		"HELP?":  "help",
+		"help!":  "help",
+		"ping.":  "ping",
+		"what?!": "what",
+	}
+
+	for name, expect := range testData {
+		if got := normalize(name); got != expect {
+			t.Fatalf("expected %s to normalize to %s got %s", name, expect, got)
+		}
+	}
+}
+
+func TestProcessNormalize(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+		"^echo ": command.NewCommand("echo", "echo [text]", "returns text", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], ",")), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := map[string]string{
+		"ping":                   "pong",
+		"Ping":                   "pong",
+		"PING?":                  "pong",
+		"ping!":                  "pong",
+		"  ping  ":               "pong",
+		"Echo   a    b ":         "a,b",
+		`echo "my service" 8080`: "my service,8080",
+		`echo "oops`:             "error parsing command: unbalanced double quote at position 6",
+	}
+
+	for text, expect := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != expect {
+				t.Fatalf("%q: expected %q got %q", text, expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+	}
+}
+
+func TestProcessUnknown(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+		"^echo ": command.NewCommand("echo", "echo [text]", "returns text", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+		"^(the )?three laws$": command.NewCommand("the three laws", "the three laws", "returns the three laws", func(args ...string) ([]byte, error) {
+			return []byte("laws"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := map[string]string{
+		"the three laws": "laws",
+		"three laws":     "laws",
+		"pnig":           "unknown command 'pnig', did you mean ping? run help for a list of commands",
+		"xyzzy":          "unknown command 'xyzzy', run help for a list of commands",
+		"echo":           "usage: echo [text]",
+	}
+
+	for text, expect := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != expect {
+				t.Fatalf("%q: expected %q got %q", text, expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	var executed int
+
+	commands := map[string]command.Command{
+		"^deploy": command.NewCommandWithArgs("deploy", "deploys a service", []command.Arg{
+			{Name: "service", Required: true, Description: "the service to deploy"},
+			{Name: "version"},
+		}, func(args ...string) ([]byte, error) {
+			executed++
+			return []byte("deployed " + strings.Join(args[1:], " ")), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		{"deploy", "missing service\nusage: deploy <service> [version]"},
+		{"deploy api", "deployed api"},
+		{"deploy api 1.0", "deployed api 1.0"},
+		{"help", "deploy <service> [version]                   deploys a service"},
+		{"help deploy", "usage      deploy <service> [version]\n<service>  the service to deploy\n[version]"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if !strings.Contains(string(ev.Data), d.expect) {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", d.text)
+		}
+	}
+
+	if executed != 2 {
+		t.Fatalf("expected deploy to be executed twice got %d", executed)
+	}
+}
+
+func TestProcessWrappers(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	// only guards its own command, wrappers apply to every bot
+	command.Use(command.WrapExec(func(c command.Command, exec func(args ...string) ([]byte, error), args ...string) ([]byte, error) {
+		if c.String() == "guarded" && len(args) < 2 {
+			return nil, errors.New("not authorized")
+		}
+		return exec(args...)
+	}))
+
+	commands := map[string]command.Command{
+		"^guarded": command.NewCommand("guarded", "guarded [token]", "needs a token", func(args ...string) ([]byte, error) {
+			return []byte("ran"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := map[string]string{
+		"guarded":       "error executing cmd: not authorized",
+		"guarded token": "ran",
+	}
+
+	for text, expect := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != expect {
+				t.Fatalf("%q: expected %q got %q", text, expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 2),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	release := make(chan bool)
+
+	commands := map[string]command.Command{
+		"^slow$": command.NewCommand("slow", "slow", "waits to be released", func(args ...string) ([]byte, error) {
+			<-release
+			return []byte("done"), nil
+		}),
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, commands, service)
+
+	if err := bot.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, text := range []string{"slow", "ping"} {
+		select {
+		case io.recv <- &input.Event{Type: input.TextEvent, Data: []byte(text)}:
+		case <-time.After(time.Second):
+			t.Fatal("timed out sending event")
+		}
+	}
+
+	// ping isn't held up by the slow command
+	select {
+	case ev := <-io.send:
+		if string(ev.Data) != "pong" {
+			t.Fatalf("expected pong got %q", string(ev.Data))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out receiving event")
+	}
+
+	stopped := make(chan bool)
+	go func() {
+		bot.stop()
+		close(stopped)
+	}()
+
+	// stop waits for the slow command
+	select {
+	case <-stopped:
+		t.Fatal("stopped before the command finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timed out stopping")
+	}
+
+	select {
+	case ev := <-io.send:
+		if string(ev.Data) != "done" {
+			t.Fatalf("expected done got %q", string(ev.Data))
+		}
+	default:
+		t.Fatal("expected the slow command to reply")
+	}
+}
+
+func TestProcessTimeout(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	release := make(chan bool)
+	defer close(release)
+
+	commands := map[string]command.Command{
+		"^hang$": command.NewCommand("hang", "hang", "never returns", func(args ...string) ([]byte, error) {
+			<-release
+			return []byte("late"), nil
+		}),
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+		"^panic$": command.NewCommand("panic", "panic", "panics", func(args ...string) ([]byte, error) {
+			panic("oops")
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	bot.timeout = 50 * time.Millisecond
+
+	os.Setenv("MICRO_BOT_COMMAND_TIMEOUT_PING", "1s")
+	defer os.Unsetenv("MICRO_BOT_COMMAND_TIMEOUT_PING")
+
+	if d := bot.commandTimeout("ping"); d != time.Second {
+		t.Fatalf("expected ping timeout 1s got %v", d)
+	}
+
+	testData := map[string]string{
+		"hang":  "command 'hang' timed out after 50ms",
+		"ping":  "pong",
+		"panic": "command 'panic' crashed: oops",
+	}
+
+	for text, expect := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != expect {
+				t.Fatalf("%q: expected %q got %q", text, expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+	}
+}
+
+// panicConn panics sending replies to events from "crash"
+type panicConn struct {
+	*testInput
+}
+
+func (p *panicConn) Send(event *input.Event) error {
+	if event.To == "crash" {
+		panic("send failed")
+	}
+	return p.testInput.Send(event)
+}
+
+func TestDispatchPanic(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+		"^panic$": command.NewCommand("panic", "panic", "panics", func(args ...string) ([]byte, error) {
+			var m map[string]string
+			m["oops"] = "crash"
+			return nil, nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	conn := &panicConn{io}
+
+	testData := []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"user", "panic", "command 'panic' crashed: assignment to entry in nil map"},
+		// the reply panics, nothing is sent
+		{"crash", "ping", ""},
+		{"user", "ping", "pong"},
+	}
+
+	for _, d := range testData {
+		bot.dispatch(conn, input.Event{From: d.from, Type: input.TextEvent, Data: []byte(d.text)})
+		bot.wg.Wait()
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != d.expect {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			if len(d.expect) > 0 {
+				t.Fatalf("%q: expected a response", d.text)
+			}
+		}
+	}
+}
+
+func TestProcessCancel(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	stopped := make(chan error, 1)
+
+	commands := map[string]command.Command{
+		// works until cancelled
+		"^work$": command.NewContextCommand("work", "work", "works until told to stop", func(ctx context.Context, args ...string) ([]byte, error) {
+			for {
+				select {
+				case <-ctx.Done():
+					stopped <- ctx.Err()
+					return nil, ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+			}
+		}),
+		"^whoami$": command.NewContextCommand("whoami", "whoami", "returns the requester", func(ctx context.Context, args ...string) ([]byte, error) {
+			return []byte(command.Input(ctx) + " " + command.User(ctx) + " " + command.Channel(ctx)), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	bot.timeout = 50 * time.Millisecond
+	conn := &serialConn{Conn: io, input: "test"}
+
+	testData := []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C0CHAN:U0USER", "whoami", "test U0USER C0CHAN"},
+		{"U0USER", "whoami", "test U0USER "},
+		{"U0USER", "work", "command 'work' timed out after 50ms"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(conn, input.Event{From: d.from, Type: input.TextEvent, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != d.expect {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", d.text)
+		}
+	}
+
+	// the timed out command was stopped rather than abandoned
+	select {
+	case err := <-stopped:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected the deadline to be exceeded got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the command to stop")
+	}
+
+	// commands still executing when stop gives up are cancelled
+	timeout := StopTimeout
+	StopTimeout = 50 * time.Millisecond
+	defer func() { StopTimeout = timeout }()
+
+	bot.timeout = time.Minute
+	bot.dispatch(conn, input.Event{Type: input.TextEvent, Data: []byte("work")})
+
+	if err := bot.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Fatalf("expected the command to be cancelled got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the command to stop")
+	}
+}
+
+func TestStopDrain(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.Duration("stop_timeout", time.Second, "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	newInput := func() *testInput {
+		return &testInput{
+			send: make(chan *input.Event, 1),
+			recv: make(chan *input.Event),
+			exit: make(chan bool),
+		}
+	}
+
+	test, other := newInput(), newInput()
+	started := make(chan bool, 2)
+	release := make(chan bool)
+
+	commands := map[string]command.Command{
+		"^slow$": command.NewCommand("slow", "slow", "replies after a while", func(args ...string) ([]byte, error) {
+			started <- true
+			time.Sleep(100 * time.Millisecond)
+			return []byte("done"), nil
+		}),
+		"^stuck$": command.NewContextCommand("stuck", "stuck", "replies once released", func(ctx context.Context, args ...string) ([]byte, error) {
+			started <- true
+			select {
+			case <-release:
+				return []byte("released"), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{
+		"test":  test,
+		"other": &namedInput{other, "other"},
+	}, commands, service)
+	bot.timeout = time.Minute
+
+	if err := bot.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(io *testInput, text string) {
+		select {
+		case io.recv <- &input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte(text)}:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out sending %q", text)
+		}
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatalf("%q didn't start", text)
+		}
+	}
+
+	// stopping an input whose commands don't finish gives up on them
+	send(other, "stuck")
+	if err := bot.stopInput("other"); err == nil || err.Error() != "timed out waiting for the commands of input other to finish" {
+		t.Fatalf("expected to time out draining got %v", err)
+	}
+	close(release)
+
+	// the commands executing as the bot stops still reply
+	send(test, "slow")
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- bot.stop()
+	}()
+
+	select {
+	case ev := <-test.send:
+		if string(ev.Data) != "done" {
+			t.Fatalf("expected done got %q", ev.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the response within the grace period")
+	}
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out stopping")
+	}
+}
+
+func TestProcessRich(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^status$": command.NewRichCommand("status", "status", "returns the status", func(args ...string) (*command.Response, error) {
+			return &command.Response{Text: "ok", Fields: []command.Field{{Key: "uptime", Value: "1h"}}}, nil
+		}),
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	meta := map[string]interface{}{"reply": "status"}
+
+	if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte("status"), Meta: meta}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := <-io.send
+	rsp, ok := ev.Meta[command.MetaResponse].(*command.Response)
+	if !ok || rsp.Text != "ok" || string(ev.Data) != "ok\nuptime  1h" || ev.Meta["reply"] != "status" {
+		t.Fatalf("unexpected reply %q %+v", string(ev.Data), ev.Meta)
+	}
+
+	// the received event is left as it was
+	if _, ok := meta[command.MetaResponse]; ok {
+		t.Fatal("unexpected response in the received meta")
+	}
+
+	if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte("ping"), Meta: meta}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := <-io.send; string(ev.Data) != "pong" || ev.Meta[command.MetaResponse] != nil {
+		t.Fatalf("unexpected reply %q %+v", string(ev.Data), ev.Meta)
+	}
+}
+
+// progressConn records the progress shown for commands
+type progressConn struct {
+	*testInput
+	updates chan []string
+}
+
+func (p *progressConn) Progress(ev input.Event) (func([]string), time.Duration) {
+	return func(lines []string) {
+		p.updates <- lines
+	}, 50 * time.Millisecond
+}
+
+func TestProcessProgress(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	steps := []string{"building api", "pushing image", "rolling out 1/3", "rolling out 2/3", "rolling out 3/3"}
+
+	commands := map[string]command.Command{
+		"^deploy ": command.NewStreamCommand("deploy", "deploy [service]", "deploys a service", func(ctx context.Context, progress func(string), args ...string) ([]byte, error) {
+			for _, step := range steps {
+				progress(step)
+				time.Sleep(5 * time.Millisecond)
+			}
+			return []byte("deployed " + args[1]), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	conn := &serialConn{Conn: &progressConn{io, make(chan []string, len(steps))}}
+
+	if err := bot.process(conn, input.Event{Type: input.TextEvent, Data: []byte("deploy api")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := <-io.send; string(ev.Data) != "deployed api" {
+		t.Fatalf("unexpected reply %q", string(ev.Data))
+	}
+
+	// every step is shown before the reply, batched within the interval
+	updates := conn.Conn.(*progressConn).updates
+	close(updates)
+
+	var lines []string
+	var n int
+	for u := range updates {
+		lines = append(lines, u...)
+		n++
+	}
+
+	if strings.Join(lines, ",") != strings.Join(steps, ",") {
+		t.Fatalf("expected %v got %v", steps, lines)
+	}
+	if n >= len(steps) {
+		t.Fatalf("expected the %d steps to be batched got %d updates", len(steps), n)
+	}
+
+	// nothing is shown without a conn which supports it
+	if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte("deploy web")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := <-io.send; string(ev.Data) != "deployed web" {
+		t.Fatalf("unexpected reply %q", string(ev.Data))
+	}
+}
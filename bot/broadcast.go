@@ -0,0 +1,65 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// sendConn sends through the conn of a running input. It receives
+// nothing since the input's loop does and closing it leaves the input's
+// conn open.
+type sendConn struct {
+	input.Conn
+	exit chan bool
+	once sync.Once
+}
+
+func (s *sendConn) Recv(*input.Event) error {
+	<-s.exit
+	return errors.New("connection closed")
+}
+
+func (s *sendConn) Close() error {
+	s.once.Do(func() {
+		close(s.exit)
+	})
+	return nil
+}
+
+// broadcast returns a conn sending to a destination of each of the
+// running inputs keyed by name e.g. {"slack": "C0OPS", "telegram":
+// "12345"}. Every running input is sent to the event's To if to is nil.
+func (b *bot) broadcast(to map[string]string) (*input.MultiConn, error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	names := make(map[string]bool)
+	for name := range to {
+		names[name] = true
+	}
+	if to == nil {
+		for name := range b.loops {
+			names[name] = true
+		}
+	}
+
+	conns := make(map[string]input.Conn, len(names))
+
+	for name := range names {
+		var c input.Conn
+		// conns are keyed by what the input calls itself
+		io, ok := b.inputs[name]
+		if ok {
+			c, ok = b.conns[io.String()]
+		}
+		if !ok {
+			return nil, fmt.Errorf("input %s isn't running", name)
+		}
+		conns[name] = &sendConn{Conn: c, exit: make(chan bool)}
+	}
+
+	return input.NewMultiConn(conns, to), nil
+}
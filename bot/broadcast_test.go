@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestBroadcast(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, map[string]command.Command{}, service)
+
+	if _, err := bot.broadcast(map[string]string{"test": "C0OPS"}); err == nil {
+		t.Fatal("expected an error for an input which isn't running")
+	}
+
+	if err := bot.startInput("test"); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.stop()
+
+	// the loop registers the conn once connected
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := bot.conn("test"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the input to connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := bot.broadcast(map[string]string{"test": "C0OPS", "nope": "#ops"}); err == nil {
+		t.Fatal("expected an error for an unknown input")
+	}
+
+	m, err := bot.broadcast(map[string]string{"test": "C0OPS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Send(&input.Event{Type: input.TextEvent, Data: []byte("deployed")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-io.send:
+		if ev.To != "C0OPS" || string(ev.Data) != "deployed" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the broadcast")
+	}
+
+	// the input keeps running
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case io.recv <- &input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte("help")}:
+	case <-time.After(time.Second):
+		t.Fatal("expected the input to still be receiving")
+	}
+
+	select {
+	case ev := <-io.send:
+		if ev.To != "C0:U1" {
+			t.Fatalf("unexpected reply %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a reply")
+	}
+}
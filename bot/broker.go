@@ -0,0 +1,397 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro/broker"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+const (
+	// publishPattern matches publish followed by the topic and message
+	publishPattern = "^publish "
+	// subscribePattern matches subscribe followed by the topic
+	subscribePattern = "^subscribe "
+	// unsubscribePattern matches unsubscribe followed by the topic
+	unsubscribePattern = "^unsubscribe "
+	// subscriptionsPattern matches subscriptions alone
+	subscriptionsPattern = "^subscriptions$"
+)
+
+var (
+	// RelayInterval is the least time between the messages relayed
+	// from a topic to a channel, those arriving in between are relayed
+	// together
+	RelayInterval = 5 * time.Second
+	// RelayBatch is the most messages of a topic relayed at once, more
+	// arriving in the interval are dropped
+	RelayBatch = 10
+)
+
+// relayKey is the channel of an input messages of a topic are relayed to
+type relayKey struct {
+	input   string
+	channel string
+	topic   string
+}
+
+// subscription relays the messages of a topic to a channel
+type subscription struct {
+	relayKey
+	// the channel is the user of a direct message
+	direct bool
+	since  time.Time
+	sub    broker.Subscriber
+
+	sync.Mutex
+	pending  []string
+	dropped  int
+	received int
+
+	wake chan bool
+	exit chan bool
+	done chan bool
+}
+
+// handle queues the message to be relayed. The broker may be blocked
+// until it returns so it never waits on the channel.
+func (s *subscription) handle(p broker.Publication) error {
+	s.Lock()
+	s.received++
+	if len(s.pending) < RelayBatch {
+		s.pending = append(s.pending, formatMessage(p.Message()))
+	} else {
+		s.dropped++
+	}
+	s.Unlock()
+
+	select {
+	case s.wake <- true:
+	default:
+	}
+
+	return nil
+}
+
+// relays are the subscriptions of the channels to broker topics. The
+// conn of the input is looked up for each relay so subscriptions
+// outlive it reconnecting.
+type relays struct {
+	bot    *bot
+	broker broker.Broker
+
+	sync.Mutex
+	subs   map[relayKey]*subscription
+	closed bool
+}
+
+func newRelays(b *bot, br broker.Broker) *relays {
+	return &relays{
+		bot:    b,
+		broker: br,
+		subs:   make(map[relayKey]*subscription),
+	}
+}
+
+// formatMessage returns the body of the message as it's relayed, JSON
+// indented and anything else which isn't text summarised
+func formatMessage(m *broker.Message) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, m.Body, "", "  "); err == nil {
+		return out.String()
+	}
+	if utf8.Valid(m.Body) {
+		return string(m.Body)
+	}
+	ct := m.Header["Content-Type"]
+	if len(ct) == 0 {
+		ct = "unknown content type"
+	}
+	return fmt.Sprintf("%d bytes of %s", len(m.Body), ct)
+}
+
+// relayTo returns where the command executing with ctx relays messages
+func relayTo(ctx context.Context, topic string) (relayKey, bool) {
+	key := relayKey{input: command.Input(ctx), channel: command.Channel(ctx), topic: topic}
+	if len(key.channel) > 0 {
+		return key, false
+	}
+	// direct messages are relayed to the user
+	key.channel = command.User(ctx)
+	return key, true
+}
+
+func (r *relays) subscribe(key relayKey, direct bool) (bool, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.closed {
+		return false, errors.New("the bot is stopping")
+	}
+	if _, ok := r.subs[key]; ok {
+		return false, nil
+	}
+
+	if err := r.broker.Connect(); err != nil {
+		return false, fmt.Errorf("error connecting to the broker: %v", err)
+	}
+
+	s := &subscription{
+		relayKey: key,
+		direct:   direct,
+		since:    time.Now(),
+		wake:     make(chan bool, 1),
+		exit:     make(chan bool),
+		done:     make(chan bool),
+	}
+
+	sub, err := r.broker.Subscribe(key.topic, s.handle)
+	if err != nil {
+		return false, fmt.Errorf("error subscribing to %s: %v", key.topic, err)
+	}
+	s.sub = sub
+
+	r.subs[key] = s
+	go r.relay(s)
+
+	return true, nil
+}
+
+func (r *relays) unsubscribe(key relayKey) bool {
+	r.Lock()
+	s, ok := r.subs[key]
+	delete(r.subs, key)
+	r.Unlock()
+
+	if ok {
+		r.stop(s)
+	}
+	return ok
+}
+
+// stop unsubscribes from the topic and waits for the relay to finish
+func (r *relays) stop(s *subscription) {
+	if err := s.sub.Unsubscribe(); err != nil {
+		log.Logf("[bot] error unsubscribing from %s: %v", s.topic, err)
+	}
+	close(s.exit)
+	<-s.done
+}
+
+// list returns the subscriptions of the channel by topic
+func (r *relays) list(input, channel string) []*subscription {
+	r.Lock()
+	defer r.Unlock()
+
+	var subs []*subscription
+	for key, s := range r.subs {
+		if key.input == input && key.channel == channel {
+			subs = append(subs, s)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].topic < subs[j].topic
+	})
+	return subs
+}
+
+// close stops every subscription, none are made after
+func (r *relays) close() {
+	r.Lock()
+	r.closed = true
+	subs := r.subs
+	r.subs = make(map[relayKey]*subscription)
+	r.Unlock()
+
+	for _, s := range subs {
+		r.stop(s)
+	}
+}
+
+// relay sends the messages of the subscription to its channel as they
+// arrive, at most once per RelayInterval
+func (r *relays) relay(s *subscription) {
+	defer close(s.done)
+
+	for {
+		s.Lock()
+		waiting := len(s.pending) == 0 && s.dropped == 0
+		s.Unlock()
+
+		// those not sent while the input was down are retried
+		if waiting {
+			select {
+			case <-s.exit:
+				return
+			case <-s.wake:
+			}
+		}
+
+		r.flush(s)
+
+		select {
+		case <-s.exit:
+			return
+		case <-time.After(RelayInterval):
+		}
+	}
+}
+
+// flush sends the messages queued unless the input isn't connected
+func (r *relays) flush(s *subscription) {
+	c, ok := r.bot.conn(s.input)
+	if !ok {
+		return
+	}
+
+	s.Lock()
+	messages, dropped := s.pending, s.dropped
+	s.pending, s.dropped = nil, 0
+	s.Unlock()
+
+	if len(messages) == 0 && dropped == 0 {
+		return
+	}
+
+	text := s.topic
+	if len(messages) > 1 {
+		text = fmt.Sprintf("%s: %d messages", s.topic, len(messages))
+	}
+	if dropped > 0 {
+		text += fmt.Sprintf(" (%d more dropped)", dropped)
+	}
+	rsp := &command.Response{
+		Text: text,
+		Code: strings.Join(messages, "\n\n"),
+	}
+
+	// replies to channels go to nobody in particular
+	from := s.channel + ":"
+	if s.direct {
+		from = s.channel
+	}
+	ev := input.Event{
+		Type: input.TextEvent,
+		From: from,
+		Meta: map[string]interface{}{},
+	}
+
+	if err := respondRich(c, ev, command.Render(rsp), rsp); err != nil {
+		log.Logf("[bot] error relaying %s to %s:%s: %v", s.topic, s.input, s.channel, err)
+	}
+}
+
+// publishCommand publishes a JSON message to a topic of the broker
+func publishCommand(c client.Client) command.Command {
+	usage := "publish <topic> <message>"
+	desc := "Publishes the JSON message to the topic of the broker"
+	args := []command.Arg{
+		{Name: "topic", Required: true, Description: "topic to publish to e.g. go.micro.srv.greeter.events"},
+		{Name: "message", Required: true, Description: `JSON message e.g. {"name": "john"}`},
+	}
+
+	return command.NewContextCommandWithArgs("publish", desc, args, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) < 3 {
+			return nil, errors.New("usage: " + usage)
+		}
+		topic := args[1]
+
+		body, err := jsonArg(ctx, args, 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message: %v", err)
+		}
+
+		msg := c.NewMessage(topic, &body, func(o *client.MessageOptions) {
+			o.ContentType = "application/json"
+		})
+		if err := c.Publish(ctx, msg); err != nil {
+			return nil, fmt.Errorf("error publishing to %s: %v", topic, err)
+		}
+
+		return []byte("published to " + topic), nil
+	})
+}
+
+// subscribeCommand relays the messages of a topic to the channel
+func subscribeCommand(r *relays) command.Command {
+	desc := "Relays the messages published to the topic here"
+	args := []command.Arg{
+		{Name: "topic", Required: true, Description: "topic to subscribe to e.g. go.micro.srv.greeter.events"},
+	}
+
+	return command.NewContextCommandWithArgs("subscribe", desc, args, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) != 2 {
+			return nil, errors.New("usage: subscribe <topic>")
+		}
+
+		key, direct := relayTo(ctx, args[1])
+		ok, err := r.subscribe(key, direct)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return []byte("already subscribed to " + key.topic + " here"), nil
+		}
+
+		return []byte("subscribed to " + key.topic + ", its messages are relayed here"), nil
+	})
+}
+
+// unsubscribeCommand stops relaying the messages of a topic
+func unsubscribeCommand(r *relays) command.Command {
+	desc := "Stops relaying the messages published to the topic here"
+	args := []command.Arg{
+		{Name: "topic", Required: true, Description: "topic subscribed to"},
+	}
+
+	return command.NewContextCommandWithArgs("unsubscribe", desc, args, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) != 2 {
+			return nil, errors.New("usage: unsubscribe <topic>")
+		}
+
+		key, _ := relayTo(ctx, args[1])
+		if !r.unsubscribe(key) {
+			return nil, fmt.Errorf("not subscribed to %s here", key.topic)
+		}
+
+		return []byte("unsubscribed from " + key.topic), nil
+	})
+}
+
+// subscriptionsCommand lists the topics relayed to the channel
+func subscriptionsCommand(r *relays) command.Command {
+	return command.NewContextCommand("subscriptions", "subscriptions", "Lists the topics whose messages are relayed here", func(ctx context.Context, args ...string) ([]byte, error) {
+		key, _ := relayTo(ctx, "")
+
+		subs := r.list(key.input, key.channel)
+		if len(subs) == 0 {
+			return []byte("no subscriptions here"), nil
+		}
+
+		rows := [][]string{{"TOPIC", "SINCE", "RECEIVED"}}
+		for _, s := range subs {
+			s.Lock()
+			received := s.received
+			s.Unlock()
+			rows = append(rows, []string{s.topic, s.since.UTC().Format(time.RFC3339), strconv.Itoa(received)})
+		}
+
+		return command.Reply(ctx, &command.Response{
+			Text: fmt.Sprintf("%d subscriptions here", len(subs)),
+			Code: table(rows),
+		}), nil
+	})
+}
@@ -0,0 +1,181 @@
+package bot
+
+import (
+	"flag"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/broker"
+	bmemory "github.com/micro/go-micro/broker/memory"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestRelays(t *testing.T) {
+	interval := RelayInterval
+	RelayInterval = 100 * time.Millisecond
+	defer func() { RelayInterval = interval }()
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "allow publish = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	br := bmemory.NewBroker()
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+		micro.Broker(br),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, map[string]command.Command{}, service)
+	c := &serialConn{Conn: io, input: "test"}
+	bot.conns["test"] = c
+
+	recv := func(io *testInput) *input.Event {
+		select {
+		case ev := <-io.send:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("expected a message")
+		}
+		return nil
+	}
+
+	nothing := func(io *testInput) {
+		select {
+		case ev := <-io.send:
+			t.Fatalf("unexpected message %q", string(ev.Data))
+		case <-time.After(3 * RelayInterval):
+		}
+	}
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		return string(recv(io).Data)
+	}
+
+	topic := "go.micro.srv.greeter.events"
+
+	testData := []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C0:U1", "subscribe " + topic, "subscribed to " + topic + ", its messages are relayed here"},
+		{"C0:U2", "subscribe " + topic, "already subscribed to " + topic + " here"},
+		{"C1:U1", "subscriptions", "no subscriptions here"},
+		{"C0:U2", "publish " + topic + ` {"name": "john"}`, "permission denied: test:U2 may not run 'publish'"},
+		{"C0:U1", "unsubscribe go.micro.srv.nope", "error executing cmd: not subscribed to go.micro.srv.nope here"},
+	}
+
+	for _, d := range testData {
+		if rsp := send(d.from, d.text); rsp != d.expect {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, rsp)
+		}
+	}
+
+	if rsp := send("C0:U2", "subscriptions"); !regexp.MustCompile(`^1 subscriptions here\nTOPIC +SINCE +RECEIVED\n` + topic + ` +\S+ +0$`).MatchString(rsp) {
+		t.Fatalf("unexpected subscriptions %q", rsp)
+	}
+
+	// the message is relayed to the channel along with the reply
+	if err := bot.process(c, input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte("publish " + topic + ` {"name": "john"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		ev := recv(io)
+		got[ev.To] = string(ev.Data)
+	}
+	if got["C0:U1"] != "published to "+topic || got["C0:"] != topic+"\n{\n  \"name\": \"john\"\n}" {
+		t.Fatalf("unexpected messages %q", got)
+	}
+
+	// a busy topic is relayed in batches, the excess dropped
+	for i := 0; i < 15; i++ {
+		if err := br.Publish(topic, &broker.Message{Body: []byte(strconv.Itoa(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	batch := regexp.MustCompile(`^` + topic + `(: (\d+) messages)?( \((\d+) more dropped\))?\n`)
+
+	relayed, dropped := 0, 0
+	for relays := 0; relayed+dropped < 15; relays++ {
+		if relays == 2 {
+			t.Fatalf("expected at most 2 relays got %d relayed %d dropped", relayed, dropped)
+		}
+
+		m := batch.FindStringSubmatch(string(recv(io).Data))
+		if m == nil {
+			t.Fatal("unexpected relay")
+		}
+
+		n := 1
+		if len(m[2]) > 0 {
+			n, _ = strconv.Atoi(m[2])
+		}
+		d, _ := strconv.Atoi(m[4])
+		relayed, dropped = relayed+n, dropped+d
+	}
+	if relayed+dropped != 15 || dropped == 0 || relayed > RelayBatch+1 {
+		t.Fatalf("unexpected relays %d relayed %d dropped", relayed, dropped)
+	}
+
+	// messages wait for the input to reconnect
+	bot.Lock()
+	delete(bot.conns, "test")
+	bot.Unlock()
+
+	if err := br.Publish(topic, &broker.Message{Body: []byte(`"hello"`)}); err != nil {
+		t.Fatal(err)
+	}
+	nothing(io)
+
+	reconnected := &testInput{send: make(chan *input.Event, 10)}
+	bot.Lock()
+	bot.conns["test"] = &serialConn{Conn: reconnected, input: "test"}
+	bot.Unlock()
+
+	if ev := recv(reconnected); string(ev.Data) != topic+"\n\"hello\"" {
+		t.Fatalf("unexpected relay %q", string(ev.Data))
+	}
+
+	bot.Lock()
+	bot.conns["test"] = c
+	bot.Unlock()
+
+	if rsp := send("C0:U1", "unsubscribe "+topic); rsp != "unsubscribed from "+topic {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	br.Publish(topic, &broker.Message{Body: []byte(`{}`)})
+	nothing(io)
+
+	// stopping ends every subscription
+	if rsp := send("C0:U1", "subscribe "+topic); rsp != "subscribed to "+topic+", its messages are relayed here" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if err := bot.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	br.Publish(topic, &broker.Message{Body: []byte(`{}`)})
+	nothing(io)
+
+	if _, err := bot.relays.subscribe(relayKey{input: "test", channel: "C0", topic: topic}, false); err == nil {
+		t.Fatal("expected subscribing to fail once stopped")
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	merrors "github.com/micro/go-micro/errors"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input/tokenize"
+)
+
+// callPattern matches call followed by the service and endpoint
+const callPattern = "^call "
+
+// DefaultCallTimeout is how long call waits for the service to answer
+var DefaultCallTimeout = 10 * time.Second
+
+// jsonArg returns the JSON following the first n args, from the text
+// the command was sent as if splitting it into args lost its quotes.
+// It's nil if there's nothing after them.
+func jsonArg(ctx context.Context, args []string, n int) (json.RawMessage, error) {
+	if len(args) <= n {
+		return nil, nil
+	}
+
+	body := strings.Join(args[n:], " ")
+	if !json.Valid([]byte(body)) {
+		cut, rest, err := tokenize.Cut(command.Text(ctx), n)
+		if err == nil && len(cut) == n && sameArgs(cut[1:], args[1:n]) && json.Valid([]byte(rest)) {
+			body = rest
+		}
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(body), nil
+}
+
+func sameArgs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// callError tells errors calling the service apart from those it
+// returned itself
+func callError(service, endpoint string, timeout time.Duration, err error) error {
+	e := merrors.Parse(err.Error())
+
+	// the client couldn't send the request or read the response
+	if strings.HasPrefix(e.Id, "go.micro.client") {
+		if e.Code == 408 {
+			return fmt.Errorf("%s %s didn't answer within %v", service, endpoint, timeout)
+		}
+		return fmt.Errorf("error calling %s %s: %s", service, endpoint, e.Detail)
+	}
+
+	if e.Code > 0 && len(e.Status) > 0 {
+		return fmt.Errorf("%s %s returned %d %s: %s", service, endpoint, e.Code, e.Status, e.Detail)
+	}
+	return fmt.Errorf("%s %s returned an error: %s", service, endpoint, e.Detail)
+}
+
+// callCommand calls an endpoint of a service with a JSON body,
+// replying with the JSON response
+func callCommand(c client.Client, timeout time.Duration) command.Command {
+	if timeout <= 0 {
+		timeout = DefaultCallTimeout
+	}
+
+	usage := "call <service> <endpoint> [request]"
+	desc := "Calls the endpoint of a service with the JSON request, {} by default, and returns the response"
+	args := []command.Arg{
+		{Name: "service", Required: true, Description: "name of the service e.g. go.micro.srv.greeter"},
+		{Name: "endpoint", Required: true, Description: "endpoint to call e.g. Say.Hello"},
+		{Name: "request", Description: `JSON request e.g. {"name": "john"}`},
+	}
+
+	return command.NewContextCommandWithArgs("call", desc, args, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) < 3 {
+			return nil, errors.New("usage: " + usage)
+		}
+		service, endpoint := args[1], args[2]
+
+		body, err := jsonArg(ctx, args, 3)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request body: %v", err)
+		}
+		if body == nil {
+			body = json.RawMessage(`{}`)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		var rsp json.RawMessage
+		req := c.NewRequest(service, endpoint, &body, client.WithContentType("application/json"))
+		if err := c.Call(ctx, req, &rsp); err != nil {
+			// the command was cancelled rather than the call timing out
+			if ctx.Err() == context.Canceled {
+				return nil, ctx.Err()
+			}
+			return nil, callError(service, endpoint, timeout, err)
+		}
+
+		if len(rsp) == 0 {
+			rsp = json.RawMessage(`{}`)
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, rsp, "", "  "); err != nil {
+			return nil, fmt.Errorf("invalid response from %s %s: %v", service, endpoint, err)
+		}
+
+		return command.Reply(ctx, &command.Response{
+			Text: service + " " + endpoint,
+			Code: out.String(),
+		}), nil
+	})
+}
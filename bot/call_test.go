@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// callClient answers calls to Say.Hello, failing as told
+type callClient struct {
+	client.Client
+
+	body        string
+	contentType string
+	rsp         string
+	err         error
+	// never answers until the call is done
+	hang bool
+}
+
+func (c *callClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	body, _ := json.Marshal(req.Body())
+	c.body, c.contentType = string(body), req.ContentType()
+
+	if c.hang {
+		<-ctx.Done()
+		return errors.Timeout("go.micro.client", "%v", ctx.Err())
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if req.Service() != "go.micro.srv.greeter" || req.Method() != "Say.Hello" {
+		return errors.InternalServerError("go.micro.client", "service %s: not found", req.Service())
+	}
+
+	*rsp.(*json.RawMessage) = json.RawMessage(c.rsp)
+	return nil
+}
+
+func TestCallCommand(t *testing.T) {
+	c := &callClient{Client: client.NewClient(), rsp: `{"msg":"hello john","count":1}`}
+	call := callCommand(c, 50*time.Millisecond)
+
+	// run as the text was sent
+	exec := func(text string) (string, *command.Response, error) {
+		args := strings.Fields(text)
+		ctx, rich := command.Capture(command.WithText(context.Background(), text))
+		rsp, err := command.ExecContext(ctx, call, args...)
+		return string(rsp), rich(), err
+	}
+
+	// splitting strips the quotes of the body which are kept from the text
+	msg := "{\n  \"msg\": \"hello john\",\n  \"count\": 1\n}"
+	rsp, rich, err := exec(`call go.micro.srv.greeter Say.Hello {"name": "john"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "go.micro.srv.greeter Say.Hello\n" + msg; rsp != expect {
+		t.Fatalf("expected %q got %q", expect, rsp)
+	}
+	if rich == nil || rich.Code != msg {
+		t.Fatalf("expected the response in a code block got %+v", rich)
+	}
+	if c.body != `{"name":"john"}` || c.contentType != "application/json" {
+		t.Fatalf("unexpected request %s %s", c.contentType, c.body)
+	}
+
+	// the body is empty by default
+	if _, _, err := execRegistry(call, "call", "go.micro.srv.greeter", "Say.Hello"); err != nil || c.body != "{}" {
+		t.Fatalf("expected an empty request got %s %v", c.body, err)
+	}
+
+	// quoted bodies are already whole
+	if _, _, err := execRegistry(call, "call", "go.micro.srv.greeter", "Say.Hello", `{"name": "jane"}`); err != nil || c.body != `{"name":"jane"}` {
+		t.Fatalf("unexpected request %s %v", c.body, err)
+	}
+
+	testData := []struct {
+		text string
+		err  error
+		hang bool
+		rsp  string
+	}{
+		{text: `call go.micro.srv.greeter Say.Hello {"name": }`, rsp: "invalid request body: invalid character '}' looking for beginning of value"},
+		{text: `call go.micro.srv.greeter`, rsp: "usage: call <service> <endpoint> [request]"},
+		{text: `call go.micro.srv.nope Say.Hello`, rsp: "error calling go.micro.srv.nope Say.Hello: service go.micro.srv.nope: not found"},
+		{text: `call go.micro.srv.greeter Say.Hello`, err: errors.InternalServerError("go.micro.client.transport", "connection refused"), rsp: "error calling go.micro.srv.greeter Say.Hello: connection refused"},
+		{text: `call go.micro.srv.greeter Say.Hello`, hang: true, rsp: "go.micro.srv.greeter Say.Hello didn't answer within 50ms"},
+		// errors returned by the service
+		{text: `call go.micro.srv.greeter Say.Hello`, err: errors.BadRequest("go.micro.srv.greeter", "name required"), rsp: "go.micro.srv.greeter Say.Hello returned 400 Bad Request: name required"},
+		{text: `call go.micro.srv.greeter Say.Hello`, err: fmt.Errorf("no greeting"), rsp: "go.micro.srv.greeter Say.Hello returned an error: no greeting"},
+	}
+
+	for _, d := range testData {
+		c.err, c.hang = d.err, d.hang
+		if _, _, err := exec(d.text); err == nil || err.Error() != d.rsp {
+			t.Fatalf("%q: expected error %q got %v", d.text, d.rsp, err)
+		}
+	}
+}
+
+func TestCallACL(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "allow call = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	c := &callClient{Client: client.NewClient(), rsp: `{"msg":"hello john"}`}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+		micro.Client(c),
+	)
+
+	bot := newBot(ctx, nil, map[string]command.Command{}, service)
+	conn := &serialConn{Conn: io, input: "test"}
+
+	send := func(from, text string) string {
+		if err := bot.process(conn, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		default:
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	// only those allowed may call anything
+	text := `call go.micro.srv.greeter Say.Hello {"name": "john"}`
+	if rsp := send("C0:U1", text); rsp != "go.micro.srv.greeter Say.Hello\n{\n  \"msg\": \"hello john\"\n}" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U2", text); rsp != "permission denied: test:U2 may not run 'call'" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// query is still restricted as call
+	text = `query go.micro.srv.greeter Say.Hello {"name": "john"}`
+	if rsp := send("C0:U1", text); rsp != "go.micro.srv.greeter Say.Hello\n{\n  \"msg\": \"hello john\"\n}" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U2", text); rsp != "permission denied: test:U2 may not run 'call'" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+}
@@ -0,0 +1,210 @@
+// Package command extends go-bot commands with argument specs, aliases,
+// execution with a context, rich responses and progress. The bot checks
+// the args of commands which have them before they're executed.
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/micro/go-bot/command"
+)
+
+type Command = command.Command
+
+var (
+	// Commands keyed by golang/regexp patterns
+	Commands = command.Commands
+)
+
+// Arg describes an argument of a command
+type Arg struct {
+	Name        string
+	Required    bool
+	Description string
+}
+
+// ArgsCommand is implemented by commands specifying their arguments.
+// Those missing required args aren't executed, the bot replies with
+// the usage instead.
+type ArgsCommand interface {
+	Command
+	Args() []Arg
+}
+
+// AliasCommand is implemented by commands which can also be run by
+// other names, such as hc for health
+type AliasCommand interface {
+	Command
+	Aliases() []string
+}
+
+// GroupCommand is implemented by commands listed under a group in help
+type GroupCommand interface {
+	Command
+	Group() string
+}
+
+// cmd extends a command with args, aliases, a group or execution with
+// a context
+type cmd struct {
+	Command
+	args    []Arg
+	aliases []string
+	group   string
+	exec    func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+func (c *cmd) Args() []Arg {
+	return c.args
+}
+
+func (c *cmd) Aliases() []string {
+	return c.aliases
+}
+
+func (c *cmd) Group() string {
+	return c.group
+}
+
+func (c *cmd) Exec(args ...string) ([]byte, error) {
+	return c.ExecContext(context.Background(), args...)
+}
+
+func (c *cmd) ExecContext(ctx context.Context, args ...string) ([]byte, error) {
+	if c.exec != nil {
+		return c.exec(ctx, args...)
+	}
+	return ExecContext(ctx, c.Command, args...)
+}
+
+func argsOf(c Command) []Arg {
+	if ac, ok := c.(ArgsCommand); ok {
+		return ac.Args()
+	}
+	return nil
+}
+
+// extend returns c with the args, aliases and group of spec, which c
+// may have lost when wrapped
+func extend(c, spec Command) Command {
+	if c == spec {
+		return c
+	}
+
+	a, al, g := argsOf(spec), Aliases(spec), Group(spec)
+	if len(a) == 0 && len(al) == 0 && len(g) == 0 {
+		return c
+	}
+
+	return &cmd{Command: c, args: a, aliases: al, group: g}
+}
+
+// ext returns a copy of c's extensions to modify
+func ext(c Command) *cmd {
+	if x, ok := c.(*cmd); ok {
+		e := *x
+		return &e
+	}
+	return &cmd{Command: c, args: argsOf(c), aliases: Aliases(c), group: Group(c)}
+}
+
+// NewCommand helps quickly create a new command
+func NewCommand(name, usage, description string, exec func(args ...string) ([]byte, error)) Command {
+	return command.NewCommand(name, usage, description, exec)
+}
+
+// NewCommandWithArgs creates a command whose usage is generated from
+// its args. Required args must come before optional ones.
+func NewCommandWithArgs(name, description string, args []Arg, exec func(args ...string) ([]byte, error)) Command {
+	return &cmd{
+		Command: command.NewCommand(name, usage(name, args), description, exec),
+		args:    args,
+	}
+}
+
+func usage(name string, args []Arg) string {
+	parts := []string{name}
+	for _, a := range args {
+		if a.Required {
+			parts = append(parts, "<"+a.Name+">")
+		} else {
+			parts = append(parts, "["+a.Name+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// WithAliases returns the command runnable by the aliases too
+func WithAliases(c Command, aliases ...string) Command {
+	e := ext(c)
+	e.aliases = aliases
+	return e
+}
+
+// Aliases returns the aliases of the command
+func Aliases(c Command) []string {
+	if ac, ok := c.(AliasCommand); ok {
+		return ac.Aliases()
+	}
+	return nil
+}
+
+// WithGroup returns the command listed under the group in help
+func WithGroup(c Command, group string) Command {
+	e := ext(c)
+	e.group = group
+	return e
+}
+
+// Group returns the group of the command, empty if it has none
+func Group(c Command) string {
+	if gc, ok := c.(GroupCommand); ok {
+		return gc.Group()
+	}
+	return ""
+}
+
+// Usage returns the usage of the command, generated from its args if
+// it has them
+func Usage(c Command) string {
+	if a := argsOf(c); len(a) > 0 {
+		return usage(c.String(), a)
+	}
+	return c.Usage()
+}
+
+// Help returns the usage, aliases and description of the command
+// followed by a line describing each arg
+func Help(c Command) string {
+	u := Usage(c)
+	if aliases := Aliases(c); len(aliases) > 0 {
+		u += " (aliases: " + strings.Join(aliases, ", ") + ")"
+	}
+
+	lines := []string{fmt.Sprintf("%s - %s", u, c.Description())}
+
+	for _, a := range argsOf(c) {
+		if len(a.Description) > 0 {
+			lines = append(lines, fmt.Sprintf("    %s - %s", a.Name, a.Description))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Validate returns an error naming the first missing required arg.
+// args are those the command is executed with, starting with the
+// words of its name.
+func Validate(c Command, args []string) error {
+	given := len(args) - len(strings.Fields(c.String()))
+
+	for i, a := range argsOf(c) {
+		if a.Required && i >= given {
+			return fmt.Errorf("missing %s\nusage: %s", a.Name, Usage(c))
+		}
+	}
+
+	return nil
+}
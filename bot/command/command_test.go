@@ -0,0 +1,111 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	deploy := NewCommandWithArgs("deploy", "Deploys a service", []Arg{
+		{Name: "service", Required: true, Description: "name of the service"},
+		{Name: "version", Required: true},
+		{Name: "region", Description: "defaults to all regions"},
+	}, exec)
+
+	alias := NewCommandWithArgs("alias add", "Adds an alias", []Arg{
+		{Name: "name", Required: true},
+	}, exec)
+
+	plain := NewCommand("echo", "echo [text]", "Returns the [text]", exec)
+
+	testData := []struct {
+		cmd  Command
+		args []string
+		err  string
+	}{
+		{deploy, []string{"deploy", "api", "1.0"}, ""},
+		{deploy, []string{"deploy", "api", "1.0", "eu"}, ""},
+		{deploy, []string{"deploy", "api"}, "missing version\nusage: deploy <service> <version> [region]"},
+		{deploy, []string{"deploy"}, "missing service\nusage: deploy <service> <version> [region]"},
+		// the words of the name aren't args
+		{alias, []string{"alias", "add", "d"}, ""},
+		{alias, []string{"alias", "add"}, "missing name\nusage: alias add <name>"},
+		// commands without args aren't checked
+		{plain, []string{"echo"}, ""},
+	}
+
+	for _, d := range testData {
+		err := Validate(d.cmd, d.args)
+		if len(d.err) == 0 && err != nil {
+			t.Fatalf("%v: unexpected error %v", d.args, err)
+		}
+		if len(d.err) > 0 && (err == nil || err.Error() != d.err) {
+			t.Fatalf("%v: expected %q got %v", d.args, d.err, err)
+		}
+	}
+}
+
+func TestUsage(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	deploy := NewCommandWithArgs("deploy", "Deploys a service", []Arg{
+		{Name: "service", Required: true, Description: "name of the service"},
+		{Name: "region", Description: "defaults to all regions"},
+	}, exec)
+
+	if u := deploy.Usage(); u != "deploy <service> [region]" {
+		t.Fatalf("unexpected usage %q", u)
+	}
+
+	if u := Usage(deploy); u != "deploy <service> [region]" {
+		t.Fatalf("unexpected usage %q", u)
+	}
+
+	expect := "deploy <service> [region] - Deploys a service\n    service - name of the service\n    region - defaults to all regions"
+	if h := Help(deploy); h != expect {
+		t.Fatalf("expected %q got %q", expect, h)
+	}
+
+	plain := NewCommand("echo", "echo [text]", "Returns the [text]", exec)
+
+	if h := Help(plain); h != "echo [text] - Returns the [text]" {
+		t.Fatalf("unexpected help %q", h)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	health := WithAliases(NewCommandWithArgs("health", "Returns the health of a service", []Arg{
+		{Name: "service", Required: true},
+	}, exec), "hc", "status")
+
+	if a := Aliases(health); len(a) != 2 || a[0] != "hc" || a[1] != "status" {
+		t.Fatalf("unexpected aliases %v", a)
+	}
+
+	// the args are kept
+	if u := Usage(health); u != "health <service>" {
+		t.Fatalf("unexpected usage %q", u)
+	}
+
+	expect := "health <service> (aliases: hc, status) - Returns the health of a service"
+	if h := Help(health); h != expect {
+		t.Fatalf("expected %q got %q", expect, h)
+	}
+
+	// wrapping keeps both the args and aliases
+	defer func() { wrappers = nil }()
+	Use(LogWrapper)
+
+	wrapped := Wrap(health)
+	if a := Aliases(wrapped); len(a) != 2 || Usage(wrapped) != "health <service>" {
+		t.Fatalf("unexpected wrapped command %v %q", a, Usage(wrapped))
+	}
+
+	plain := NewCommand("echo", "echo [text]", "Returns the [text]", exec)
+	if a := Aliases(plain); len(a) != 0 {
+		t.Fatalf("unexpected aliases %v", a)
+	}
+}
@@ -0,0 +1,118 @@
+package command
+
+import (
+	"context"
+)
+
+// ContextKey is the type of the keys the bot sets on the context
+// commands are executed with
+type ContextKey string
+
+const (
+	// InputKey is the name of the input the command came from
+	InputKey ContextKey = "input"
+	// UserKey is the user who sent the command
+	UserKey ContextKey = "user"
+	// ChannelKey is the channel the command was sent in, empty when
+	// sent directly to the bot
+	ChannelKey ContextKey = "channel"
+	// MessageLimitKey is the most characters the input sends in a
+	// message, unset if it has no limit
+	MessageLimitKey ContextKey = "message_limit"
+	// TextKey is the text the command was sent as, unset when it wasn't
+	// sent on its own such as in a pipeline
+	TextKey ContextKey = "text"
+)
+
+// ContextCommand is implemented by commands which stop when the context
+// is done. The bot cancels it when the command times out or is
+// abandoned on shutdown.
+type ContextCommand interface {
+	Command
+	ExecContext(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// NewContext returns ctx carrying the input and requester of a command
+func NewContext(ctx context.Context, input, user, channel string) context.Context {
+	ctx = context.WithValue(ctx, InputKey, input)
+	ctx = context.WithValue(ctx, UserKey, user)
+	return context.WithValue(ctx, ChannelKey, channel)
+}
+
+// WithMessageLimit returns ctx carrying the message limit of the input
+func WithMessageLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, MessageLimitKey, limit)
+}
+
+// MessageLimit returns the most characters the input sends in a
+// message, 0 if there's no limit
+func MessageLimit(ctx context.Context) int {
+	v, _ := ctx.Value(MessageLimitKey).(int)
+	return v
+}
+
+// WithText returns ctx carrying the text the command was sent as
+func WithText(ctx context.Context, text string) context.Context {
+	return context.WithValue(ctx, TextKey, text)
+}
+
+// Text returns the text the command was sent as before it was split
+// into args, empty if it's not known
+func Text(ctx context.Context) string {
+	return value(ctx, TextKey)
+}
+
+func value(ctx context.Context, key ContextKey) string {
+	v, _ := ctx.Value(key).(string)
+	return v
+}
+
+// Input returns the name of the input the command came from
+func Input(ctx context.Context) string {
+	return value(ctx, InputKey)
+}
+
+// User returns the user who sent the command
+func User(ctx context.Context) string {
+	return value(ctx, UserKey)
+}
+
+// Channel returns the channel the command was sent in
+func Channel(ctx context.Context) string {
+	return value(ctx, ChannelKey)
+}
+
+// NewContextCommand creates a command executed with the context. Exec
+// runs it with a background context.
+func NewContextCommand(name, usage, description string, exec func(ctx context.Context, args ...string) ([]byte, error)) Command {
+	return &cmd{
+		Command: NewCommand(name, usage, description, func(args ...string) ([]byte, error) {
+			return exec(context.Background(), args...)
+		}),
+		exec: exec,
+	}
+}
+
+// NewContextCommandWithArgs creates a command executed with the context
+// whose usage is generated from its args
+func NewContextCommandWithArgs(name, description string, args []Arg, exec func(ctx context.Context, args ...string) ([]byte, error)) Command {
+	c := NewContextCommand(name, usage(name, args), description, exec).(*cmd)
+	c.args = args
+	return c
+}
+
+// ExecContext executes the command with ctx if it supports it, otherwise
+// with Exec. The response of rich commands is recorded in ctx and the
+// progress of streaming commands reported to it.
+func ExecContext(ctx context.Context, c Command, args ...string) ([]byte, error) {
+	if rc, ok := c.(RichCommand); ok {
+		return execRich(ctx, rc, args...)
+	}
+	if sc, ok := c.(StreamCommand); ok {
+		return sc.ExecStream(ctx, func(line string) { Progress(ctx, line) }, args...)
+	}
+	if cc, ok := c.(ContextCommand); ok {
+		return cc.ExecContext(ctx, args...)
+	}
+	return c.Exec(args...)
+}
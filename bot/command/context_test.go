@@ -0,0 +1,90 @@
+package command
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExecContext(t *testing.T) {
+	whoami := func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte(Input(ctx) + " " + User(ctx) + " " + Channel(ctx)), nil
+	}
+
+	ctx := NewContext(context.Background(), "slack", "U0USER", "C0CHAN")
+
+	testData := []struct {
+		cmd    Command
+		expect string
+	}{
+		{NewContextCommand("whoami", "whoami", "Returns the requester", whoami), "slack U0USER C0CHAN"},
+		{NewContextCommandWithArgs("whoami", "Returns the requester", []Arg{{Name: "verbose"}}, whoami), "slack U0USER C0CHAN"},
+		// plain commands are executed with Exec
+		{NewCommand("ping", "ping", "Returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}), "pong"},
+	}
+
+	for _, d := range testData {
+		rsp, err := ExecContext(ctx, d.cmd, "whoami")
+		if err != nil || string(rsp) != d.expect {
+			t.Fatalf("%s: expected %q got %q %v", d.cmd.String(), d.expect, rsp, err)
+		}
+	}
+
+	// Exec has no requester
+	if rsp, err := testData[0].cmd.Exec("whoami"); err != nil || string(rsp) != "  " {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	if u := Usage(testData[1].cmd); u != "whoami [verbose]" {
+		t.Fatalf("unexpected usage %q", u)
+	}
+
+	// wrappers see the context and pass it on
+	defer func() { wrappers = nil }()
+
+	var user string
+	Use(WrapExecContext(func(ctx context.Context, c Command, exec func(ctx context.Context, args ...string) ([]byte, error), args ...string) ([]byte, error) {
+		user = User(ctx)
+		return exec(ctx, args...)
+	}), LogWrapper)
+
+	rsp, err := ExecContext(ctx, Wrap(testData[0].cmd), "whoami")
+	if err != nil || string(rsp) != "slack U0USER C0CHAN" || user != "U0USER" {
+		t.Fatalf("unexpected response %q %v seen by %q", rsp, err, user)
+	}
+}
+
+func TestExecStream(t *testing.T) {
+	deploy := NewStreamCommand("deploy", "deploy [service]", "Deploys a service", func(ctx context.Context, progress func(string), args ...string) ([]byte, error) {
+		progress("building " + args[1])
+		progress("rolling out " + args[1])
+		return []byte("deployed " + args[1]), nil
+	})
+
+	var lines []string
+	ctx := WithProgress(context.Background(), func(line string) {
+		lines = append(lines, line)
+	})
+
+	defer func() { wrappers = nil }()
+	Use(LogWrapper)
+
+	for _, c := range []Command{deploy, Wrap(deploy), WithAliases(deploy, "ship")} {
+		lines = nil
+
+		rsp, err := ExecContext(ctx, c, "deploy", "api")
+		if err != nil || string(rsp) != "deployed api" {
+			t.Fatalf("unexpected response %q %v", rsp, err)
+		}
+		if len(lines) != 2 || lines[0] != "building api" || lines[1] != "rolling out api" {
+			t.Fatalf("unexpected progress %v", lines)
+		}
+	}
+
+	// progress is discarded without a listener
+	if rsp, err := deploy.Exec("deploy", "api"); err != nil || string(rsp) != "deployed api" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+	Progress(context.Background(), "ignored")
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"context"
+
+	"github.com/micro/go-bot/command"
+)
+
+// StreamCommand is implemented by long running commands which report
+// their progress while they execute. The returned output still
+// completes the command.
+type StreamCommand interface {
+	Command
+	ExecStream(ctx context.Context, progress func(line string), args ...string) ([]byte, error)
+}
+
+type streamCmd struct {
+	Command
+	exec func(ctx context.Context, progress func(line string), args ...string) ([]byte, error)
+}
+
+func (c *streamCmd) ExecStream(ctx context.Context, progress func(line string), args ...string) ([]byte, error) {
+	return c.exec(ctx, progress, args...)
+}
+
+// NewStreamCommand creates a command reporting its progress. Exec runs
+// it with a background context, discarding the progress.
+func NewStreamCommand(name, usage, description string, exec func(ctx context.Context, progress func(line string), args ...string) ([]byte, error)) Command {
+	return &streamCmd{
+		Command: command.NewCommand(name, usage, description, func(args ...string) ([]byte, error) {
+			return exec(context.Background(), func(string) {}, args...)
+		}),
+		exec: exec,
+	}
+}
+
+type progressKey struct{}
+
+// WithProgress returns ctx passing the progress reported by commands
+// executed with it to fn
+func WithProgress(ctx context.Context, fn func(line string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// Progress reports a line of progress of the command executing with
+// ctx. It's discarded if nothing is listening.
+func Progress(ctx context.Context, line string) {
+	if fn, ok := ctx.Value(progressKey{}).(func(string)); ok {
+		fn(line)
+	}
+}
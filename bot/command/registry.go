@@ -0,0 +1,77 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	regMtx sync.RWMutex
+	// commands registered with Register keyed by namespaced pattern
+	registered = map[string]Command{}
+)
+
+// Register adds a command matched by the golang/regexp pattern within
+// the group. It's run as "group name" or by its name alone when no
+// other group has a command of that name. An empty group registers the
+// command ungrouped. Register panics if a command of the same namespaced
+// name is already registered.
+func Register(group, pattern string, c Command) {
+	if c == nil {
+		panic("command: Register command is nil")
+	}
+
+	if strings.ContainsAny(group, " \t\n") {
+		panic(fmt.Sprintf("command: Register group %q must be a single word", group))
+	}
+
+	if len(group) > 0 {
+		c = WithGroup(c, group)
+	}
+
+	regMtx.Lock()
+	defer regMtx.Unlock()
+
+	name := FullName(c)
+	for _, r := range registered {
+		if FullName(r) == name {
+			panic(fmt.Sprintf("command: Register called twice for %s", name))
+		}
+	}
+
+	registered[Pattern(group, pattern)] = c
+}
+
+// Registered returns the commands added with Register keyed by their
+// namespaced pattern
+func Registered() map[string]Command {
+	regMtx.RLock()
+	defer regMtx.RUnlock()
+
+	commands := make(map[string]Command, len(registered))
+	for pattern, c := range registered {
+		commands[pattern] = c
+	}
+	return commands
+}
+
+// Pattern returns the pattern matching the command text prefixed by the
+// group, the pattern itself if the group is empty
+func Pattern(group, pattern string) string {
+	if len(group) == 0 {
+		return pattern
+	}
+	return "^" + regexp.QuoteMeta(strings.ToLower(group)) + " (?:" + strings.TrimPrefix(pattern, "^") + ")"
+}
+
+// FullName returns the lowercase name of the command prefixed by its
+// group, such as "registry list"
+func FullName(c Command) string {
+	name := strings.ToLower(c.String())
+	if g := Group(c); len(g) > 0 {
+		return strings.ToLower(g) + " " + name
+	}
+	return name
+}
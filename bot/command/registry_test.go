@@ -0,0 +1,77 @@
+package command
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	defer func() {
+		regMtx.Lock()
+		registered = map[string]Command{}
+		regMtx.Unlock()
+	}()
+
+	Register("registry", "^list ", NewCommand("list", "list", "Lists services", exec))
+	Register("k8s", "^list ", NewCommand("list", "list", "Lists pods", exec))
+	Register("", "^echo ", NewCommand("echo", "echo", "Returns the text", exec))
+
+	commands := Registered()
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands got %v", commands)
+	}
+
+	c, ok := commands["^registry (?:list )"]
+	if !ok || Group(c) != "registry" || FullName(c) != "registry list" {
+		t.Fatalf("expected registry list got %v", commands)
+	}
+	if c, ok := commands["^echo "]; !ok || FullName(c) != "echo" {
+		t.Fatalf("expected echo got %v", commands)
+	}
+
+	panics := func(f func()) (ok bool) {
+		defer func() { ok = recover() != nil }()
+		f()
+		return false
+	}
+
+	// duplicates are detected on the namespaced name
+	if !panics(func() { Register("Registry", "^ls ", NewCommand("List", "list", "Lists services", exec)) }) {
+		t.Fatal("expected a duplicate in a group to panic")
+	}
+	if !panics(func() { Register("", "^print ", NewCommand("echo", "echo", "Returns the text", exec)) }) {
+		t.Fatal("expected a duplicate without a group to panic")
+	}
+	if !panics(func() { Register("my group", "^list ", NewCommand("list", "list", "Lists", exec)) }) {
+		t.Fatal("expected a group of several words to panic")
+	}
+	if !panics(func() { Register("ops", "^list ", nil) }) {
+		t.Fatal("expected a nil command to panic")
+	}
+}
+
+func TestPattern(t *testing.T) {
+	testData := []struct {
+		group   string
+		pattern string
+		text    string
+		match   bool
+	}{
+		{"", "^list ", "list services", true},
+		{"registry", "^list ", "registry list services", true},
+		{"registry", "^list ", "list services", false},
+		{"Registry", "^list ", "registry list services", true},
+		{"k8s", "^list ", "registry list services", false},
+		{"a.b", "^list", "axb list", false},
+		{"ops", "^(deploy|ship) ", "ops ship api", true},
+	}
+
+	for _, d := range testData {
+		p := Pattern(d.group, d.pattern)
+		if m := regexp.MustCompile(p).MatchString(d.text); m != d.match {
+			t.Fatalf("%s %s: expected %q to match %t", d.group, d.pattern, d.text, d.match)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-bot/command"
+)
+
+// MetaResponse is the meta key of the *Response on replies to rich
+// commands. Inputs which can render it natively do so, the others send
+// the event data which is the response rendered as plain text.
+const MetaResponse = "command_response"
+
+// Level is the severity of a response
+type Level string
+
+const (
+	LevelInfo Level = "info"
+	// LevelWarn responses are prefixed "warning: " as plain text
+	LevelWarn Level = "warn"
+	// LevelError responses are prefixed "error: " as plain text
+	LevelError Level = "error"
+)
+
+// Field is a key value pair shown in a response
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Response is structured command output which inputs render for the
+// chat system they're on
+type Response struct {
+	Text string
+	// Code is preformatted text such as logs or JSON
+	Code   string
+	Fields []Field
+	// Level defaults to info
+	Level Level
+}
+
+// RichCommand is implemented by commands returning a Response. Exec
+// returns it rendered as plain text.
+type RichCommand interface {
+	Command
+	ExecRich(args ...string) (*Response, error)
+}
+
+type richCmd struct {
+	Command
+	exec func(args ...string) (*Response, error)
+}
+
+func (c *richCmd) ExecRich(args ...string) (*Response, error) {
+	return c.exec(args...)
+}
+
+// NewRichCommand creates a command returning a Response
+func NewRichCommand(name, usage, description string, exec func(args ...string) (*Response, error)) Command {
+	return &richCmd{
+		Command: command.NewCommand(name, usage, description, func(args ...string) ([]byte, error) {
+			rsp, err := exec(args...)
+			if err != nil {
+				return nil, err
+			}
+			return Render(rsp), nil
+		}),
+		exec: exec,
+	}
+}
+
+// String returns the response as plain text. Fields are aligned in a
+// column after the text and code follows them.
+func (r *Response) String() string {
+	var parts []string
+
+	text := r.Text
+	switch r.Level {
+	case LevelWarn:
+		text = "warning: " + text
+	case LevelError:
+		text = "error: " + text
+	}
+	if len(strings.TrimSpace(text)) > 0 {
+		parts = append(parts, strings.TrimSpace(text))
+	}
+
+	width := 0
+	for _, f := range r.Fields {
+		if len(f.Key) > width {
+			width = len(f.Key)
+		}
+	}
+
+	if len(r.Fields) > 0 {
+		var lines []string
+		pad := strings.Repeat(" ", width+2)
+
+		for _, f := range r.Fields {
+			// continuation lines of a value stay in its column
+			value := strings.Replace(strings.Trim(f.Value, "\n"), "\n", "\n"+pad, -1)
+			lines = append(lines, strings.TrimRight(f.Key+strings.Repeat(" ", width+2-len(f.Key))+value, " "))
+		}
+
+		parts = append(parts, strings.Join(lines, "\n"))
+	}
+
+	if code := strings.Trim(r.Code, "\n"); len(code) > 0 {
+		parts = append(parts, code)
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// Render returns the response as plain text for inputs which can't
+// render it natively
+func Render(r *Response) []byte {
+	if r == nil {
+		return nil
+	}
+	return []byte(r.String())
+}
+
+type responseKey struct{}
+
+// recorder holds the response of the rich command executed with it.
+// The command may still be running when it's read.
+type recorder struct {
+	sync.Mutex
+	rsp *Response
+}
+
+// Capture returns a context recording the response of a rich command
+// executed with it, and a func returning the response once it has
+func Capture(ctx context.Context) (context.Context, func() *Response) {
+	r := &recorder{}
+
+	return context.WithValue(ctx, responseKey{}, r), func() *Response {
+		r.Lock()
+		defer r.Unlock()
+		return r.rsp
+	}
+}
+
+// Reply records the response of the command executing with ctx and
+// returns it rendered as plain text. Context commands return it to
+// reply with a rich response.
+func Reply(ctx context.Context, rsp *Response) []byte {
+	if r, ok := ctx.Value(responseKey{}).(*recorder); ok {
+		r.Lock()
+		r.rsp = rsp
+		r.Unlock()
+	}
+
+	return Render(rsp)
+}
+
+// execRich executes the rich command, recording the response in ctx
+func execRich(ctx context.Context, c RichCommand, args ...string) ([]byte, error) {
+	rsp, err := c.ExecRich(args...)
+	if err != nil {
+		return nil, err
+	}
+	return Reply(ctx, rsp), nil
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	testData := []struct {
+		rsp    *Response
+		expect string
+	}{
+		{&Response{Text: "pong"}, "pong"},
+		{&Response{Text: "disk almost full", Level: LevelWarn}, "warning: disk almost full"},
+		{&Response{Text: "1 node down", Level: LevelError}, "error: 1 node down"},
+		{&Response{
+			Text: "services",
+			Fields: []Field{
+				{Key: "go.micro.srv.greeter", Value: "1 node"},
+				{Key: "go.micro.api", Value: "2 nodes\nv1, v2"},
+			},
+		}, "services\ngo.micro.srv.greeter  1 node\ngo.micro.api          2 nodes\n                      v1, v2"},
+		{&Response{Code: "\n{\n\t\"a\": 1\n}\n"}, "{\n\t\"a\": 1\n}"},
+		{&Response{Text: "logs", Fields: []Field{{Key: "lines", Value: "2"}}, Code: "a\nb"}, "logs\nlines  2\na\nb"},
+		{&Response{}, ""},
+	}
+
+	for _, d := range testData {
+		if s := string(Render(d.rsp)); s != d.expect {
+			t.Fatalf("%+v: expected %q got %q", d.rsp, d.expect, s)
+		}
+	}
+
+	if Render(nil) != nil {
+		t.Fatal("expected nothing for a nil response")
+	}
+}
+
+func TestExecRich(t *testing.T) {
+	status := NewRichCommand("status", "status", "Returns the status", func(args ...string) (*Response, error) {
+		if len(args) > 1 {
+			return nil, errors.New("unexpected args")
+		}
+		return &Response{Text: "ok", Fields: []Field{{Key: "uptime", Value: "1h"}}}, nil
+	})
+
+	// plain Exec gets the rendered response
+	rsp, err := status.Exec("status")
+	if err != nil || string(rsp) != "ok\nuptime  1h" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	// executing with a context records the response, including through
+	// wrappers and aliases
+	defer func() { wrappers = nil }()
+	Use(LogWrapper)
+
+	for _, c := range []Command{status, Wrap(status), WithAliases(status, "st")} {
+		ctx, rich := Capture(context.Background())
+
+		rsp, err := ExecContext(ctx, c, "status")
+		if err != nil || string(rsp) != "ok\nuptime  1h" {
+			t.Fatalf("unexpected response %q %v", rsp, err)
+		}
+		if r := rich(); r == nil || r.Text != "ok" || len(r.Fields) != 1 {
+			t.Fatalf("unexpected rich response %+v", r)
+		}
+	}
+
+	ctx, rich := Capture(context.Background())
+	if _, err := ExecContext(ctx, status, "status", "now"); err == nil || rich() != nil {
+		t.Fatalf("expected an error and no response got %v %+v", err, rich())
+	}
+
+	// plain commands record nothing
+	ping := NewCommand("ping", "ping", "Returns pong", func(args ...string) ([]byte, error) {
+		return []byte("pong"), nil
+	})
+
+	ctx, rich = Capture(context.Background())
+	if rsp, err := ExecContext(ctx, ping, "ping"); err != nil || string(rsp) != "pong" || rich() != nil {
+		t.Fatalf("unexpected response %q %v %+v", rsp, err, rich())
+	}
+}
@@ -0,0 +1,59 @@
+package command
+
+import (
+	"context"
+	"sync"
+)
+
+type sessionKey struct{}
+
+// session is the conversation a command executing with a context is
+// having with the user
+type session struct {
+	// state of the prompt answered, nil when the command is started
+	state   interface{}
+	resumed bool
+
+	sync.Mutex
+	prompted bool
+	next     interface{}
+}
+
+// WithSession returns a context for a command started, or resumed with
+// the state of the prompt it last replied with, and a func returning
+// the state of the prompt it replies with this time if it does
+func WithSession(ctx context.Context, state interface{}, resumed bool) (context.Context, func() (interface{}, bool)) {
+	s := &session{state: state, resumed: resumed}
+
+	return context.WithValue(ctx, sessionKey{}, s), func() (interface{}, bool) {
+		s.Lock()
+		defer s.Unlock()
+		return s.next, s.prompted
+	}
+}
+
+// Prompt asks the user a follow up question, claiming their next
+// message in the channel. The command is executed with it as args in
+// place of the words after its name, and SessionState returns the state
+// given here. Commands return it to reply with the question. A command
+// which doesn't prompt again ends the conversation.
+func Prompt(ctx context.Context, question string, state interface{}) []byte {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.Lock()
+		s.prompted = true
+		s.next = state
+		s.Unlock()
+	}
+
+	return []byte(question)
+}
+
+// SessionState returns the state of the prompt the command is answering,
+// false if it was started rather than resumed by an answer
+func SessionState(ctx context.Context) (interface{}, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok || !s.resumed {
+		return nil, false
+	}
+	return s.state, true
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	// started outside a session prompting does nothing
+	if rsp := Prompt(context.Background(), "name?", 1); string(rsp) != "name?" {
+		t.Fatalf("unexpected prompt %q", rsp)
+	}
+	if _, ok := SessionState(context.Background()); ok {
+		t.Fatal("expected no session state")
+	}
+
+	ctx, prompted := WithSession(context.Background(), nil, false)
+	if _, ok := SessionState(ctx); ok {
+		t.Fatal("expected no state for a command started")
+	}
+	if _, ok := prompted(); ok {
+		t.Fatal("expected no prompt")
+	}
+
+	Prompt(ctx, "name?", "step 1")
+	if state, ok := prompted(); !ok || state != "step 1" {
+		t.Fatalf("expected the prompt's state got %v %v", state, ok)
+	}
+
+	// resumed with the state of the prompt answered
+	ctx, prompted = WithSession(context.Background(), "step 1", true)
+	if state, ok := SessionState(ctx); !ok || state != "step 1" {
+		t.Fatalf("expected the prompt's state got %v %v", state, ok)
+	}
+	if _, ok := prompted(); ok {
+		t.Fatal("expected no prompt")
+	}
+
+	// a nil state still resumes
+	ctx, _ = WithSession(context.Background(), nil, true)
+	if state, ok := SessionState(ctx); !ok || state != nil {
+		t.Fatalf("expected a nil state got %v %v", state, ok)
+	}
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+// Wrapper adds behaviour to a command such as logging or authorization.
+// A wrapper can stop the command running by returning an error from
+// Exec, which is reported like any other error.
+type Wrapper func(Command) Command
+
+var (
+	mtx      sync.RWMutex
+	wrappers []Wrapper
+)
+
+// wrapper is a command whose Exec and ExecContext are replaced
+type wrapper struct {
+	Command
+	exec func(ctx context.Context, args ...string) ([]byte, error)
+}
+
+func (w *wrapper) Exec(args ...string) ([]byte, error) {
+	return w.exec(context.Background(), args...)
+}
+
+func (w *wrapper) ExecContext(ctx context.Context, args ...string) ([]byte, error) {
+	return w.exec(ctx, args...)
+}
+
+// Use registers wrappers the bot applies to every command. The first
+// registered is the outermost, running first.
+func Use(w ...Wrapper) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	wrappers = append(wrappers, w...)
+}
+
+// Wrap returns the command wrapped by the registered wrappers. The
+// args and aliases of commands which have them are kept.
+func Wrap(c Command) Command {
+	mtx.RLock()
+	ws := wrappers
+	mtx.RUnlock()
+
+	wrapped := c
+	for i := len(ws) - 1; i >= 0; i-- {
+		wrapped = ws[i](wrapped)
+	}
+
+	return extend(wrapped, c)
+}
+
+// WrapExec returns a wrapper which runs fn in place of Exec. fn calls
+// exec to run the command.
+func WrapExec(fn func(c Command, exec func(args ...string) ([]byte, error), args ...string) ([]byte, error)) Wrapper {
+	return WrapExecContext(func(ctx context.Context, c Command, exec func(ctx context.Context, args ...string) ([]byte, error), args ...string) ([]byte, error) {
+		return fn(c, func(args ...string) ([]byte, error) {
+			return exec(ctx, args...)
+		}, args...)
+	})
+}
+
+// WrapExecContext returns a wrapper which runs fn in place of
+// ExecContext. fn calls exec to run the command with the context.
+func WrapExecContext(fn func(ctx context.Context, c Command, exec func(ctx context.Context, args ...string) ([]byte, error), args ...string) ([]byte, error)) Wrapper {
+	return func(c Command) Command {
+		exec := func(ctx context.Context, args ...string) ([]byte, error) {
+			return ExecContext(ctx, c, args...)
+		}
+
+		return &wrapper{
+			Command: c,
+			exec: func(ctx context.Context, args ...string) ([]byte, error) {
+				return fn(ctx, c, exec, args...)
+			},
+		}
+	}
+}
+
+// LogWrapper logs each command executed, how long it took and its error
+func LogWrapper(c Command) Command {
+	return WrapExec(func(c Command, exec func(args ...string) ([]byte, error), args ...string) ([]byte, error) {
+		start := time.Now()
+		rsp, err := exec(args...)
+
+		if err != nil {
+			log.Logf("[command] %s %q failed after %v: %v", c.String(), strings.Join(args, " "), time.Since(start), err)
+		} else {
+			log.Logf("[command] %s %q took %v", c.String(), strings.Join(args, " "), time.Since(start))
+		}
+
+		return rsp, err
+	})(c)
+}
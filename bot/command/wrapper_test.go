@@ -0,0 +1,76 @@
+package command
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	defer func() { wrappers = nil }()
+
+	var calls []string
+
+	trace := func(name string) Wrapper {
+		return WrapExec(func(c Command, exec func(args ...string) ([]byte, error), args ...string) ([]byte, error) {
+			calls = append(calls, name+" before")
+			rsp, err := exec(args...)
+			calls = append(calls, name+" after")
+			return rsp, err
+		})
+	}
+
+	deny := WrapExec(func(c Command, exec func(args ...string) ([]byte, error), args ...string) ([]byte, error) {
+		if len(args) > 1 && args[1] == "prod" {
+			calls = append(calls, "denied")
+			return nil, errors.New("not authorized to deploy to prod")
+		}
+		return exec(args...)
+	})
+
+	Use(trace("outer"), trace("inner"))
+	Use(deny, LogWrapper)
+
+	cmd := Wrap(NewCommandWithArgs("deploy", "deploys", []Arg{{Name: "env", Required: true}}, func(args ...string) ([]byte, error) {
+		calls = append(calls, "exec")
+		return []byte("deployed " + args[1]), nil
+	}))
+
+	if cmd.String() != "deploy" || cmd.Usage() != "deploy <env>" {
+		t.Fatalf("unexpected command %s %s", cmd.String(), cmd.Usage())
+	}
+
+	// the args survive wrapping
+	if err := Validate(cmd, []string{"deploy"}); err == nil {
+		t.Fatal("expected the args to be validated")
+	}
+
+	rsp, err := cmd.Exec("deploy", "staging")
+	if err != nil || string(rsp) != "deployed staging" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	if expect := "outer before,inner before,exec,inner after,outer after"; strings.Join(calls, ",") != expect {
+		t.Fatalf("expected %s got %s", expect, strings.Join(calls, ","))
+	}
+
+	calls = nil
+
+	if _, err := cmd.Exec("deploy", "prod"); err == nil || err.Error() != "not authorized to deploy to prod" {
+		t.Fatalf("expected the wrapper to refuse got %v", err)
+	}
+
+	if expect := "outer before,inner before,denied,inner after,outer after"; strings.Join(calls, ",") != expect {
+		t.Fatalf("expected %s got %s", expect, strings.Join(calls, ","))
+	}
+}
+
+func TestWrapNone(t *testing.T) {
+	cmd := NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+		return []byte("pong"), nil
+	})
+
+	if Wrap(cmd) != cmd {
+		t.Fatal("expected the command unchanged without wrappers")
+	}
+}
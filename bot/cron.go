@@ -0,0 +1,173 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron descriptors and the expressions they stand for
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSpec is when a cron expression of minute, hour, day of month,
+// month and day of week fires. Each field is a set of bits.
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// a restricted day of month or week matches either of them
+	domAny, dowAny bool
+}
+
+// parseCronField parses a field of comma separated values, ranges and
+// steps such as "*/15", "1-5" or "0,30" within min and max
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(r[0])
+			hi, err2 = strconv.Atoi(r[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = n, n
+			// a step from a value runs to the max
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << uint(n)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCron parses a cron expression of five fields or a descriptor
+// such as @daily
+func parseCron(expr string) (*cronSpec, error) {
+	if d, ok := cronDescriptors[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields: minute hour day month weekday", expr)
+	}
+
+	c := &cronSpec{
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+
+	bounds := []struct {
+		bits     *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		// 0 and 7 are both sunday
+		{&c.dow, 0, 7},
+	}
+
+	for i, b := range bounds {
+		bits, err := parseCronField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		*b.bits = bits
+	}
+
+	if c.dow&(1<<7) > 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// day returns true if the spec fires on the day of t
+func (c *cronSpec) day(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<uint(t.Day())) > 0
+	dow := c.dow&(1<<uint(t.Weekday())) > 0
+
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dow
+	case c.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t the spec fires, zero if it
+// doesn't within five years such as on the 30th of February
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for i := 0; i < 5*366; i++ {
+		if c.day(t) {
+			for h := t.Hour(); h < 24; h++ {
+				if c.hour&(1<<uint(h)) == 0 {
+					continue
+				}
+
+				m := 0
+				if h == t.Hour() {
+					m = t.Minute()
+				}
+
+				for ; m < 60; m++ {
+					if c.minute&(1<<uint(m)) > 0 {
+						return time.Date(t.Year(), t.Month(), t.Day(), h, m, 0, 0, t.Location())
+					}
+				}
+			}
+		}
+
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 9-17 * * 1-5",
+		"0,30 9 1 1,6 0",
+		"5/10 * * * 7",
+		"@daily",
+		"@Hourly",
+	} {
+		if _, err := parseCron(expr); err != nil {
+			t.Fatalf("%q: %v", expr, err)
+		}
+	}
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@sometimes",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Fatalf("%q: expected an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// a wednesday
+	now := time.Date(2026, 10, 14, 9, 30, 20, 0, time.UTC)
+
+	testData := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 14, 9, 31, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 9 * * *", time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 14, 9, 45, 0, 0, time.UTC)},
+		{"0 */6 * * *", time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// either the day of the month or the week
+		{"0 9 20 * 5", time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)},
+		// never
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, d := range testData {
+		c, err := parseCron(d.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := c.next(now); !next.Equal(d.next) {
+			t.Fatalf("%q: expected %v got %v", d.expr, d.next, next)
+		}
+	}
+}
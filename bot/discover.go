@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/micro/bot/command"
+)
+
+var (
+	// MetaCommand is the endpoint metadata advertising a bot command by
+	// its usage e.g. "weather <city> [units]"
+	MetaCommand = "bot-command"
+	// MetaDescription is the endpoint metadata describing the command
+	MetaDescription = "bot-description"
+	// MetaGroup is the endpoint metadata with the group of the command
+	MetaGroup = "bot-group"
+)
+
+// discovery tracks the versions of the services with their nodes so
+// commands are only dropped once no node advertises them
+type discovery map[string]map[string]*registry.Service
+
+// update adds the nodes and takes the endpoints of the service version
+func (d discovery) update(s *registry.Service) {
+	versions, ok := d[s.Name]
+	if !ok {
+		versions = make(map[string]*registry.Service)
+		d[s.Name] = versions
+	}
+
+	v := &registry.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Endpoints: s.Endpoints,
+	}
+
+	seen := make(map[string]bool)
+	for _, n := range s.Nodes {
+		seen[n.Id] = true
+		v.Nodes = append(v.Nodes, n)
+	}
+	if old, ok := versions[s.Version]; ok {
+		for _, n := range old.Nodes {
+			if !seen[n.Id] {
+				v.Nodes = append(v.Nodes, n)
+			}
+		}
+	}
+
+	versions[s.Version] = v
+}
+
+// remove drops the nodes of the service version, and the version once
+// it has none left
+func (d discovery) remove(s *registry.Service) {
+	versions, ok := d[s.Name]
+	if !ok {
+		return
+	}
+
+	old, ok := versions[s.Version]
+	if !ok {
+		return
+	}
+
+	gone := make(map[string]bool)
+	for _, n := range s.Nodes {
+		gone[n.Id] = true
+	}
+
+	var nodes []*registry.Node
+	for _, n := range old.Nodes {
+		if !gone[n.Id] {
+			nodes = append(nodes, n)
+		}
+	}
+
+	// a delete without nodes is of the whole version
+	if len(nodes) == 0 || len(s.Nodes) == 0 {
+		delete(versions, s.Version)
+	} else {
+		old.Nodes = nodes
+	}
+
+	if len(versions) == 0 {
+		delete(d, s.Name)
+	}
+}
+
+// commands returns the commands advertised by the endpoints of the
+// services keyed by namespaced pattern. Commands of the same name are
+// taken from the first service and version in order.
+func (d discovery) commands(c client.Client) map[string]command.Command {
+	var names []string
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	commands := make(map[string]command.Command)
+	owners := make(map[string]string)
+
+	for _, name := range names {
+		var versions []string
+		for version := range d[name] {
+			versions = append(versions, version)
+		}
+		sort.Strings(versions)
+
+		for _, version := range versions {
+			for _, ep := range d[name][version].Endpoints {
+				pattern, cmd, ok := remoteCommand(c, name, ep)
+				if !ok {
+					continue
+				}
+
+				full := command.FullName(cmd)
+				if owner, ok := owners[full]; ok {
+					if owner != name {
+						log.Logf("[bot] ignoring command %s of %s, already advertised by %s", full, name, owner)
+					}
+					continue
+				}
+
+				owners[full] = name
+				commands[pattern] = cmd
+			}
+		}
+	}
+
+	return commands
+}
+
+// parseUsage splits the usage into the words of the command name and
+// its args, "<arg>" being required and "[arg]" optional
+func parseUsage(usage string) (string, []command.Arg) {
+	var name []string
+	var args []command.Arg
+
+	for _, f := range strings.Fields(usage) {
+		switch {
+		case len(f) > 2 && strings.HasPrefix(f, "<") && strings.HasSuffix(f, ">"):
+			args = append(args, command.Arg{Name: f[1 : len(f)-1], Required: true})
+		case len(f) > 2 && strings.HasPrefix(f, "[") && strings.HasSuffix(f, "]"):
+			args = append(args, command.Arg{Name: f[1 : len(f)-1]})
+		case len(args) == 0:
+			name = append(name, f)
+		}
+	}
+
+	return strings.Join(name, " "), args
+}
+
+// remoteCommand returns the command the endpoint advertises in its
+// metadata, false if it doesn't
+func remoteCommand(c client.Client, service string, ep *registry.Endpoint) (string, command.Command, bool) {
+	usage := strings.TrimSpace(ep.Metadata[MetaCommand])
+	if len(usage) == 0 {
+		return "", nil, false
+	}
+
+	name, args := parseUsage(usage)
+	if len(name) == 0 {
+		log.Logf("[bot] ignoring command %q of %s.%s without a name", usage, service, ep.Name)
+		return "", nil, false
+	}
+
+	desc := ep.Metadata[MetaDescription]
+	if len(desc) == 0 {
+		desc = fmt.Sprintf("Calls %s.%s", service, ep.Name)
+	}
+
+	exec := func(ctx context.Context, a ...string) ([]byte, error) {
+		return callEndpoint(ctx, c, service, ep.Name, len(strings.Fields(name)), args, a)
+	}
+
+	var cmd command.Command
+	if len(args) > 0 {
+		cmd = command.NewContextCommandWithArgs(name, desc, args, exec)
+	} else {
+		cmd = command.NewContextCommand(name, usage, desc, exec)
+	}
+
+	group := strings.ToLower(ep.Metadata[MetaGroup])
+	if strings.ContainsAny(group, " \t\n") {
+		log.Logf("[bot] ignoring group %q of command %s, it must be a single word", group, name)
+		group = ""
+	}
+	if len(group) > 0 {
+		cmd = command.WithGroup(cmd, group)
+	}
+
+	pattern := "^" + regexp.QuoteMeta(strings.ToLower(name)) + "( |$)"
+
+	return command.Pattern(group, pattern), cmd, true
+}
+
+// callEndpoint calls the endpoint with the args following the words of
+// the command name keyed by the name of the arg, the last taking the
+// rest of them. The response is sent as text.
+func callEndpoint(ctx context.Context, c client.Client, service, endpoint string, skip int, args []command.Arg, values []string) ([]byte, error) {
+	if len(values) > skip {
+		values = values[skip:]
+	} else {
+		values = nil
+	}
+
+	request := make(map[string]interface{})
+	for i, a := range args {
+		if i >= len(values) {
+			break
+		}
+		if i == len(args)-1 {
+			request[a.Name] = strings.Join(values[i:], " ")
+			break
+		}
+		request[a.Name] = values[i]
+	}
+
+	var response json.RawMessage
+
+	req := c.NewRequest(service, endpoint, request, client.WithContentType("application/json"))
+	if err := c.Call(ctx, req, &response); err != nil {
+		return nil, fmt.Errorf("error calling %s.%s: %v", service, endpoint, err)
+	}
+
+	// plain strings are replies of their own
+	var text string
+	if err := json.Unmarshal(response, &text); err == nil {
+		return []byte(text), nil
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, response, "", "\t"); err != nil {
+		return nil, err
+	}
+
+	return command.Reply(ctx, &command.Response{Code: out.String()}), nil
+}
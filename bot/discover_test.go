@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/client/mock"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// stubClient answers calls to the weather service with the request it
+// was sent
+type stubClient struct {
+	client.Client
+
+	sync.Mutex
+	requests []string
+}
+
+func (s *stubClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	raw, ok := rsp.(*json.RawMessage)
+	if !ok || req.Service() != "go.micro.srv.weather" {
+		return errors.New("not found")
+	}
+
+	b, err := json.Marshal(req.Body())
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.requests = append(s.requests, req.Endpoint()+" "+string(b))
+	s.Unlock()
+
+	switch req.Endpoint() {
+	case "Weather.Get":
+		*raw = json.RawMessage(`"sunny"`)
+	default:
+		*raw = json.RawMessage(`{"days":[1,2]}`)
+	}
+
+	return nil
+}
+
+func TestParseUsage(t *testing.T) {
+	testData := []struct {
+		usage string
+		name  string
+		args  string
+	}{
+		{"weather <city> [units]", "weather", "<city> [units]"},
+		{"list pods [namespace]", "list pods", "[namespace]"},
+		{"uptime", "uptime", ""},
+		{"<city>", "", "<city>"},
+		{"weather <city> in [units]", "weather", "<city> [units]"},
+	}
+
+	for _, d := range testData {
+		name, args := parseUsage(d.usage)
+
+		var got []string
+		for _, a := range args {
+			if a.Required {
+				got = append(got, "<"+a.Name+">")
+			} else {
+				got = append(got, "["+a.Name+"]")
+			}
+		}
+
+		if name != d.name || strings.Join(got, " ") != d.args {
+			t.Fatalf("%q: expected %q %q got %q %q", d.usage, d.name, d.args, name, strings.Join(got, " "))
+		}
+	}
+}
+
+func TestDiscoverCommands(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	reg := memory.NewRegistry()
+	stub := &stubClient{Client: mock.NewClient(client.Registry(reg))}
+
+	service := micro.NewService(
+		micro.Client(stub),
+	)
+
+	commands := map[string]command.Command{
+		"^echo ": command.NewCommand("echo", "echo [text]", "Returns the [text]", func(args ...string) ([]byte, error) {
+			return []byte("static " + strings.Join(args[1:], " ")), nil
+		}),
+	}
+
+	bot := newBot(ctx, nil, commands, service)
+
+	weather := func(node, usage string) *registry.Service {
+		return &registry.Service{
+			Name:    "go.micro.srv.weather",
+			Version: "1.0.0",
+			Nodes:   []*registry.Node{{Id: node, Address: "localhost", Port: 8080}},
+			Endpoints: []*registry.Endpoint{
+				{Name: "Weather.Get", Metadata: map[string]string{
+					MetaCommand:     usage,
+					MetaDescription: "Returns the weather",
+				}},
+				{Name: "Weather.Forecast", Metadata: map[string]string{
+					MetaCommand: "forecast <city>",
+					MetaGroup:   "weather",
+				}},
+				// static commands take precedence
+				{Name: "Weather.Echo", Metadata: map[string]string{
+					MetaCommand: "echo <text>",
+				}},
+				{Name: "Weather.Internal"},
+			},
+		}
+	}
+
+	// registered before the bot starts watching
+	if err := reg.Register(weather("n1", "weather <city> [units]")); err != nil {
+		t.Fatal(err)
+	}
+
+	go bot.watch()
+	defer close(bot.exit)
+
+	send := func(text string) string {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	// the registry is watched asynchronously
+	eventually := func(text, expect string) {
+		var rsp string
+		for i := 0; i < 100; i++ {
+			if rsp = send(text); strings.HasPrefix(rsp, expect) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%q: expected %q got %q", text, expect, rsp)
+	}
+
+	eventually("weather london metric", "sunny")
+	eventually("weather new york", "sunny")
+	// the last arg takes the rest of them
+	eventually("forecast new york", "{")
+	eventually("weather", "missing city\nusage: weather <city> [units]")
+	eventually("forecast paris", "{\n\t\"days\": [\n\t\t1,\n\t\t2\n\t]\n}")
+	eventually("weather forecast paris", "{\n\t\"days\"")
+	eventually("echo hi", "static hi")
+	eventually("help weather", "Returns the weather\nusage    weather <city> [units]\n<city>")
+
+	stub.Lock()
+	requests := strings.Join(stub.requests, "\n")
+	stub.Unlock()
+
+	for _, r := range []string{
+		`Weather.Get {"city":"london","units":"metric"}`,
+		`Weather.Get {"city":"new","units":"york"}`,
+		`Weather.Forecast {"city":"new york"}`,
+		`Weather.Forecast {"city":"paris"}`,
+	} {
+		if !strings.Contains(requests, r) {
+			t.Fatalf("expected request %s got %s", r, requests)
+		}
+	}
+	if strings.Contains(requests, "Weather.Echo") {
+		t.Fatalf("unexpected call of the shadowed command %s", requests)
+	}
+
+	// another node updates the endpoints
+	if err := reg.Register(weather("n2", "weather <city> [days]")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("help weather", "Returns the weather\nusage   weather <city> [days]")
+
+	// the commands stay while a node advertises them
+	if err := reg.Deregister(weather("n1", "")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	eventually("weather london", "sunny")
+
+	if err := reg.Deregister(weather("n2", "")); err != nil {
+		t.Fatal(err)
+	}
+	eventually("weather london", "unknown command 'weather'")
+	eventually("forecast paris", "unknown command 'forecast'")
+	eventually("echo hi", "static hi")
+}
@@ -0,0 +1,76 @@
+// Register is a service registering a bot command on startup. The bot
+// executes it by calling Uptime.Exec, and suspends it while the service
+// isn't running.
+//
+//	micro bot --inputs=slack --slack_token=...
+//	go run bot/examples/register/main.go
+//
+// Then "uptime" or "uptime seconds" in slack replies with its uptime.
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro"
+	proto "github.com/micro/micro/bot/proto"
+)
+
+type Uptime struct {
+	started time.Time
+}
+
+// Exec is passed the args of the command, the first being its name
+func (u *Uptime) Exec(ctx context.Context, req *proto.ExecRequest, rsp *proto.ExecResponse) error {
+	up := time.Since(u.started)
+
+	switch {
+	case len(req.Args) < 2:
+		rsp.Result = []byte(fmt.Sprintf("up %v", up.Round(time.Second)))
+	case req.Args[1] == "seconds":
+		rsp.Result = []byte(fmt.Sprintf("%d", int(up.Seconds())))
+	default:
+		rsp.Error = "usage: uptime [seconds]"
+	}
+
+	return nil
+}
+
+func main() {
+	var bot proto.CommandService
+
+	register := func() error {
+		_, err := bot.Register(context.Background(), &proto.RegisterRequest{
+			Name:        "uptime",
+			Usage:       "uptime [seconds]",
+			Description: "Returns how long the example service has been running",
+			Service:     "go.micro.srv.uptime",
+			Endpoint:    "Uptime.Exec",
+		})
+		return err
+	}
+
+	deregister := func() error {
+		_, err := bot.Deregister(context.Background(), &proto.DeregisterRequest{Name: "uptime"})
+		return err
+	}
+
+	service := micro.NewService(
+		micro.Name("go.micro.srv.uptime"),
+		// registered with the bot once it's registered itself so the
+		// command isn't suspended
+		micro.AfterStart(register),
+		micro.BeforeStop(deregister),
+	)
+	service.Init()
+
+	bot = proto.NewCommandService("go.micro.bot", service.Client())
+
+	service.Server().Handle(service.Server().NewHandler(&Uptime{started: time.Now()}))
+
+	if err := service.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
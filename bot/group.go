@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/i18n"
+)
+
+// nameIndex indexes the commands by the normalized first word of their
+// name, prefixed by their group if they have one. Grouped names are
+// also indexed by the groups having them.
+func nameIndex(commands map[string]command.Command) (map[string]string, map[string][]string) {
+	names := make(map[string]string)
+	groups := make(map[string][]string)
+
+	for pattern, cmd := range commands {
+		fields := strings.Fields(cmd.String())
+		if len(fields) == 0 {
+			continue
+		}
+
+		name := normalize(fields[0])
+
+		group := strings.ToLower(command.Group(cmd))
+		if len(group) == 0 {
+			names[name] = pattern
+			continue
+		}
+
+		// commands under several patterns have the group once
+		if _, ok := names[group+" "+name]; !ok {
+			groups[name] = append(groups[name], group)
+		}
+		names[group+" "+name] = pattern
+	}
+
+	for _, g := range groups {
+		sort.Strings(g)
+	}
+
+	return names, groups
+}
+
+// match returns true if the command's pattern matches data. Patterns of
+// grouped commands are matched with data prefixed by the group.
+func match(pattern string, cmd command.Command, data []byte) bool {
+	if g := command.Group(cmd); len(g) > 0 {
+		data = append([]byte(strings.ToLower(g)+" "), data...)
+	}
+	m, err := regexp.Match(pattern, data)
+	return err == nil && m
+}
+
+// resolve returns the namespaced name of the command the args run, such
+// as "registry list", and the args without the group. A grouped command
+// can be run by its name alone if no ungrouped or other grouped command
+// has it, otherwise the namespaced names it could be are returned.
+func (b *bot) resolve(args []string) (string, []string, []string) {
+	if len(args) > 1 {
+		name := args[0] + " " + normalize(args[1])
+		if _, ok := b.names[name]; ok {
+			rest := append([]string{}, args[1:]...)
+			rest[0] = normalize(rest[0])
+			return name, rest, nil
+		}
+	}
+
+	if _, ok := b.names[args[0]]; ok {
+		return args[0], args, nil
+	}
+
+	switch groups := b.groups[args[0]]; len(groups) {
+	case 0:
+		return args[0], args, nil
+	case 1:
+		return groups[0] + " " + args[0], args, nil
+	default:
+		var options []string
+		for _, g := range groups {
+			options = append(options, g+" "+args[0])
+		}
+		return "", args, options
+	}
+}
+
+// ambiguousCommand returns the response for a name which is a command
+// in several groups
+func ambiguousCommand(o origin, name string, options []string) []byte {
+	return o.say(i18n.AmbiguousCommand, i18n.Params{"name": name, "options": strings.Join(options, ", ")})
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestNameIndex(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	commands := map[string]command.Command{
+		"^registry (?:list )":  command.WithGroup(command.NewCommand("list services", "list services", "", exec), "registry"),
+		"^registry (?:ls )":    command.WithGroup(command.NewCommand("list services", "list services", "", exec), "registry"),
+		"^k8s (?:list )":       command.WithGroup(command.NewCommand("list", "list", "", exec), "K8s"),
+		"^registry (?:get )":   command.WithGroup(command.NewCommand("get service", "get service", "", exec), "registry"),
+		"^echo ":               command.NewCommand("echo", "echo", "", exec),
+		"^general (?:health )": command.WithGroup(command.NewCommand("health", "health", "", exec), "general"),
+	}
+
+	names, groups := nameIndex(commands)
+
+	for _, name := range []string{"registry list", "k8s list", "registry get", "echo", "general health"} {
+		if _, ok := names[name]; !ok {
+			t.Fatalf("expected %q in %v", name, names)
+		}
+	}
+	if _, ok := names["list"]; ok {
+		t.Fatalf("unexpected ungrouped list in %v", names)
+	}
+
+	if g := strings.Join(groups["list"], ","); g != "k8s,registry" {
+		t.Fatalf("expected the groups of list got %q", g)
+	}
+	if g := strings.Join(groups["get"], ","); g != "registry" {
+		t.Fatalf("expected the groups of get got %q", g)
+	}
+}
+
+func TestProcessGroups(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	echo := func(group string) func(args ...string) ([]byte, error) {
+		return func(args ...string) ([]byte, error) {
+			return []byte(group + ": " + strings.Join(args, " ")), nil
+		}
+	}
+
+	commands := map[string]command.Command{
+		command.Pattern("registry", "^list "): command.WithGroup(command.NewCommand("list", "list [service]", "lists services", echo("registry")), "registry"),
+		command.Pattern("k8s", "^list "):      command.WithGroup(command.NewCommand("list", "list [pod]", "lists pods", echo("k8s")), "k8s"),
+		command.Pattern("k8s", "^logs "): command.WithGroup(command.NewCommandWithArgs("logs", "shows logs", []command.Arg{
+			{Name: "pod", Required: true},
+		}, echo("k8s")), "k8s"),
+		command.Pattern("registry", "^status$"): command.WithGroup(command.NewCommand("status", "status", "registry status", echo("registry")), "registry"),
+		"^status$":                              command.NewCommand("status", "status", "bot status", echo("bot")),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		// the two token name is tried first, the command sees its name
+		{"registry list api", "registry: list api"},
+		{"registry list", "usage: list [service]"},
+		{"k8s list api", "k8s: list api"},
+		{"K8s List? api", "k8s: list api"},
+		// a name in a single group runs without it
+		{"logs api", "k8s: logs api"},
+		{"k8s logs", "usage: logs <pod>"},
+		// ungrouped commands take precedence over grouped ones
+		{"status", "bot: status"},
+		{"registry status", "registry: status"},
+		{"list", "'list' is a command in several groups, run one of k8s list, registry list"},
+		{"lsit", "unknown command 'lsit', did you mean list?"},
+		{"help k8s list", "lists pods\nusage  list [pod]\ngroup  k8s"},
+		{"help list", "'list' is a command in several groups, run one of k8s list, registry list"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(io, input.Event{Type: input.TextEvent, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if !strings.HasPrefix(string(ev.Data), d.expect) {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", d.text)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/registry"
+	proto "github.com/micro/go-micro/server/debug/proto"
+	"github.com/micro/micro/bot/command"
+)
+
+// healthPattern matches health alone or followed by services
+const healthPattern = "^health( |$)"
+
+var (
+	// NodeHealthTimeout is how long a node has to answer a health check
+	NodeHealthTimeout = 2 * time.Second
+	// HealthConcurrency is the most nodes checked at once
+	HealthConcurrency = 20
+)
+
+// nodeHealth is the result of checking the health of a node
+type nodeHealth struct {
+	service string
+	version string
+	node    *registry.Node
+	latency time.Duration
+	rsp     *proto.HealthResponse
+	err     error
+}
+
+func (n *nodeHealth) healthy() bool {
+	return n.err == nil && n.rsp.Status == "ok"
+}
+
+// reason returns why the node isn't healthy
+func (n *nodeHealth) reason() string {
+	if n.err != nil {
+		return n.err.Error()
+	}
+	return fmt.Sprintf("status %q", n.rsp.Status)
+}
+
+func nodeAddress(n *registry.Node) string {
+	if n.Port > 0 {
+		return fmt.Sprintf("%s:%d", n.Address, n.Port)
+	}
+	return n.Address
+}
+
+// checkNode calls the node's Debug.Health endpoint, giving up after
+// NodeHealthTimeout whether or not the client does
+func checkNode(ctx context.Context, c client.Client, h *nodeHealth) {
+	ctx, cancel := context.WithTimeout(ctx, NodeHealthTimeout)
+	defer cancel()
+
+	req := c.NewRequest(h.service, "Debug.Health", &proto.HealthRequest{})
+	rsp := &proto.HealthResponse{}
+
+	done := make(chan error, 1)
+	start := time.Now()
+
+	go func() {
+		done <- c.Call(ctx, req, rsp, client.WithAddress(nodeAddress(h.node)))
+	}()
+
+	select {
+	case err := <-done:
+		h.latency = time.Since(start)
+		h.rsp, h.err = rsp, err
+	case <-ctx.Done():
+		h.latency = time.Since(start)
+		h.err = fmt.Errorf("timed out after %v", NodeHealthTimeout)
+	}
+}
+
+// checkNodes checks the health of the nodes concurrently, at most
+// HealthConcurrency at once
+func checkNodes(ctx context.Context, c client.Client, nodes []*nodeHealth) {
+	n := HealthConcurrency
+	if n <= 0 {
+		n = 1
+	}
+	sem := make(chan bool, n)
+
+	var wg sync.WaitGroup
+
+	for _, h := range nodes {
+		wg.Add(1)
+		sem <- true
+
+		go func(h *nodeHealth) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			checkNode(ctx, c, h)
+		}(h)
+	}
+
+	wg.Wait()
+}
+
+// healthCommand checks the health of every node of the services
+func healthCommand(reg registry.Registry, c client.Client) command.Command {
+	usage := "health [service...] [--verbose]"
+	desc := "Checks the health of each node of the services, or of everything registered, --verbose shows their answers"
+
+	return command.NewContextCommand("health", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		var names []string
+		var verbose bool
+
+		for _, arg := range args[1:] {
+			switch arg {
+			case "--verbose", "-v":
+				verbose = true
+			default:
+				if strings.HasPrefix(arg, "-") {
+					return nil, fmt.Errorf("unknown flag %s\nusage: %s", arg, usage)
+				}
+				names = append(names, arg)
+			}
+		}
+
+		// everything registered
+		all := len(names) == 0
+		if all {
+			var services []*registry.Service
+			err := queryRegistry(ctx, reg, func() error {
+				var err error
+				services, err = reg.ListServices()
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			seen := make(map[string]bool)
+			for _, s := range services {
+				if !seen[s.Name] {
+					seen[s.Name] = true
+					names = append(names, s.Name)
+				}
+			}
+			if len(names) == 0 {
+				return []byte("no services registered"), nil
+			}
+		}
+
+		var nodes []*nodeHealth
+
+		for _, name := range names {
+			var versions []*registry.Service
+			err := queryRegistry(ctx, reg, func() error {
+				var err error
+				versions, err = reg.GetService(name)
+				return err
+			})
+			// listed services may go before they're looked up
+			if err == registry.ErrNotFound && all {
+				continue
+			}
+			if err == registry.ErrNotFound {
+				return nil, fmt.Errorf("service %s not found", name)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			for _, s := range versions {
+				for _, n := range s.Nodes {
+					nodes = append(nodes, &nodeHealth{service: name, version: s.Version, node: n})
+				}
+			}
+		}
+
+		if len(nodes) == 0 {
+			return []byte("no nodes registered for " + strings.Join(names, ", ")), nil
+		}
+
+		checkNodes(ctx, c, nodes)
+
+		// the command timed out or was cancelled
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		sort.Slice(nodes, func(i, j int) bool {
+			a, b := nodes[i], nodes[j]
+			if a.service != b.service {
+				return a.service < b.service
+			}
+			if a.version != b.version {
+				return a.version < b.version
+			}
+			return a.node.Id < b.node.Id
+		})
+
+		header := []string{"NODE", "VERSION", "ADDRESS", "STATUS", "LATENCY"}
+		multi := len(names) > 1
+		if multi {
+			header = append([]string{"SERVICE"}, header...)
+		}
+		rows := [][]string{header}
+
+		var healthy int
+		var failed []command.Field
+		var payloads []string
+
+		for _, h := range nodes {
+			status := "ok"
+			if h.healthy() {
+				healthy++
+			} else {
+				status = "failed"
+				failed = append(failed, command.Field{Key: h.node.Id, Value: h.reason()})
+			}
+
+			row := []string{h.node.Id, h.version, nodeAddress(h.node), status, h.latency.Round(time.Millisecond).String()}
+			if multi {
+				row = append([]string{h.service}, row...)
+			}
+			rows = append(rows, row)
+
+			if verbose && h.err == nil {
+				b, _ := json.Marshal(h.rsp)
+				payloads = append(payloads, h.node.Id+"  "+string(b))
+			}
+		}
+
+		rsp := &command.Response{
+			Text:   fmt.Sprintf("%d/%d nodes healthy", healthy, len(nodes)),
+			Code:   table(rows),
+			Fields: failed,
+		}
+		if !multi {
+			rsp.Text = names[0] + ": " + rsp.Text
+		}
+
+		switch {
+		case healthy == 0:
+			rsp.Level = command.LevelError
+		case healthy < len(nodes):
+			rsp.Level = command.LevelWarn
+		}
+
+		if len(payloads) > 0 {
+			rsp.Code += "\n\n" + strings.Join(payloads, "\n")
+		}
+
+		return command.Reply(ctx, rsp), nil
+	})
+}
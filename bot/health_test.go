@@ -0,0 +1,213 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	proto "github.com/micro/go-micro/server/debug/proto"
+)
+
+// healthClient answers health checks by the address of the node
+type healthClient struct {
+	client.Client
+
+	sync.Mutex
+	status map[string]string
+	errs   map[string]error
+	// addresses which never answer
+	hang map[string]bool
+	// most checks running at once
+	running, most int
+}
+
+func (h *healthClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	var o client.CallOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	h.Lock()
+	h.running++
+	if h.running > h.most {
+		h.most = h.running
+	}
+	status, err, hang := h.status[o.Address], h.errs[o.Address], h.hang[o.Address]
+	h.Unlock()
+
+	defer func() {
+		h.Lock()
+		h.running--
+		h.Unlock()
+	}()
+
+	if req.Service() != "go.micro.srv.greeter" || req.Method() != "Debug.Health" {
+		return errors.New("unexpected request " + req.Service() + " " + req.Method())
+	}
+
+	if hang {
+		// ignores the context like a stuck client
+		time.Sleep(time.Second)
+		return nil
+	}
+
+	// checked concurrently
+	time.Sleep(20 * time.Millisecond)
+
+	if err != nil {
+		return err
+	}
+	rsp.(*proto.HealthResponse).Status = status
+	return nil
+}
+
+// latency replaces the latencies in the output which vary
+var latency = regexp.MustCompile(`[0-9.]+m?s\b`)
+
+func TestHealthCommand(t *testing.T) {
+	timeout := NodeHealthTimeout
+	NodeHealthTimeout = 100 * time.Millisecond
+	defer func() { NodeHealthTimeout = timeout }()
+
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:    "go.micro.srv.greeter",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{Id: "greeter-1", Address: "10.0.0.1", Port: 8080},
+			{Id: "greeter-2", Address: "10.0.0.2", Port: 8080},
+			{Id: "greeter-3", Address: "10.0.0.3", Port: 8080},
+			{Id: "greeter-4", Address: "10.0.0.4", Port: 8080},
+		},
+	})
+	reg.Register(&registry.Service{Name: "go.micro.srv.idle", Version: "latest"})
+
+	c := &healthClient{
+		Client: client.NewClient(),
+		status: map[string]string{
+			"10.0.0.1:8080": "ok",
+			"10.0.0.2:8080": "ok",
+			"10.0.0.3:8080": "ok",
+		},
+		errs: map[string]error{
+			"10.0.0.4:8080": errors.New("connection refused"),
+		},
+	}
+
+	health := healthCommand(reg, c)
+
+	rsp, rich, err := execRegistry(health, "health", "go.micro.srv.greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := strings.Join([]string{
+		"warning: go.micro.srv.greeter: 3/4 nodes healthy",
+		"greeter-4  connection refused",
+		"NODE       VERSION  ADDRESS        STATUS  LATENCY",
+		"greeter-1  1.0.0    10.0.0.1:8080  ok      X",
+		"greeter-2  1.0.0    10.0.0.2:8080  ok      X",
+		"greeter-3  1.0.0    10.0.0.3:8080  ok      X",
+		"greeter-4  1.0.0    10.0.0.4:8080  failed  X",
+	}, "\n")
+	if got := latency.ReplaceAllString(rsp, "X"); got != expect {
+		t.Fatalf("expected %q got %q", expect, got)
+	}
+	if rich == nil || len(rich.Fields) != 1 || !strings.HasPrefix(rich.Code, "NODE") {
+		t.Fatalf("expected the nodes in a code block got %+v", rich)
+	}
+
+	// the nodes were checked at once
+	if c.most < 2 {
+		t.Fatalf("expected concurrent checks got at most %d", c.most)
+	}
+
+	// the payloads are included with --verbose
+	rsp, _, err = execRegistry(health, "health", "go.micro.srv.greeter", "--verbose")
+	if err != nil || !strings.Contains(rsp, `greeter-1  {"status":"ok"}`) || strings.Contains(rsp, `greeter-4  {`) {
+		t.Fatalf("expected the payloads got %q %v", rsp, err)
+	}
+
+	// stuck nodes don't hold up the others
+	c.Lock()
+	c.hang = map[string]bool{"10.0.0.2:8080": true}
+	c.status["10.0.0.4:8080"] = "degraded"
+	delete(c.errs, "10.0.0.4:8080")
+	c.Unlock()
+
+	start := time.Now()
+	rsp, _, err = execRegistry(health, "health", "go.micro.srv.greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > NodeHealthTimeout+500*time.Millisecond {
+		t.Fatalf("took %v to check", d)
+	}
+	for _, s := range []string{
+		"warning: go.micro.srv.greeter: 2/4 nodes healthy",
+		"greeter-2  timed out after 100ms",
+		`greeter-4  status "degraded"`,
+	} {
+		if !strings.Contains(rsp, s) {
+			t.Fatalf("expected %q in %q", s, rsp)
+		}
+	}
+
+	// everything registered is checked without a service
+	rsp, _, err = execRegistry(health, "health")
+	if err != nil || !strings.HasPrefix(rsp, "warning: 2/4 nodes healthy") || !strings.Contains(rsp, "\nSERVICE  ") {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	rsp, _, err = execRegistry(health, "health", "go.micro.srv.idle")
+	if err != nil || rsp != "no nodes registered for go.micro.srv.idle" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	for _, d := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"health", "go.micro.srv.nope"}, "service go.micro.srv.nope not found"},
+		{[]string{"health", "--all"}, "unknown flag --all\nusage: health [service...] [--verbose]"},
+	} {
+		if _, _, err := execRegistry(health, d.args...); err == nil || err.Error() != d.err {
+			t.Fatalf("%q: expected error %q got %v", d.args, d.err, err)
+		}
+	}
+}
+
+func TestHealthUnhealthy(t *testing.T) {
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:    "go.micro.srv.greeter",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: "greeter-1", Address: "10.0.0.1", Port: 8080}},
+	})
+
+	c := &healthClient{
+		Client: client.NewClient(),
+		errs:   map[string]error{"10.0.0.1:8080": errors.New("connection refused")},
+	}
+
+	_, rich, err := execRegistry(healthCommand(reg, c), "health", "go.micro.srv.greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rich == nil || rich.Level != "error" || rich.Text != "go.micro.srv.greeter: 0/1 nodes healthy" {
+		t.Fatalf("expected an error response got %+v", rich)
+	}
+
+	// a registry which can't be reached is reported
+	_, _, err = execRegistry(healthCommand(&brokenRegistry{err: errors.New("no route to host")}, c), "health")
+	if err == nil || err.Error() != "registry consul unavailable: no route to host" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
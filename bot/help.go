@@ -0,0 +1,267 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/micro/micro/bot/command"
+)
+
+// helpPattern matches help alone or followed by a command or page
+const helpPattern = "^help( |$)"
+
+var (
+	// HelpPageSize is the most commands listed on a page of help
+	HelpPageSize = 20
+	// room left on a page of help for the header and formatting
+	helpOverhead = len(helpHeader(999, 999)) + 16
+)
+
+// helpEntry is a command listed in help
+type helpEntry struct {
+	group       string
+	usage       string
+	description string
+}
+
+// helpHeader returns the text shown above a page of help
+func helpHeader(page, pages int) string {
+	text := "commands, run help [command] for details"
+	if pages > 1 {
+		text += fmt.Sprintf(" (page %d of %d, run help [page] for more)", page, pages)
+	}
+	return text
+}
+
+// paginate splits the entries into pages of at most size entries and
+// limit characters. Each page starts with the group of its first entry.
+// A limit of 0 is unlimited.
+func paginate(entries []helpEntry, limit, size int) [][]string {
+	width := 0
+	for _, e := range entries {
+		if len(e.usage) > width {
+			width = len(e.usage)
+		}
+	}
+
+	var pages [][]string
+	var page []string
+	var group string
+	var n, length int
+
+	for _, e := range entries {
+		line := "  " + e.usage + strings.Repeat(" ", width-len(e.usage)) + "  " + e.description
+
+		lines := []string{line}
+		if len(page) == 0 || e.group != group {
+			lines = []string{e.group, line}
+		}
+
+		// each line is followed by a newline
+		add := len(strings.Join(lines, "\n")) + 1
+
+		if len(page) > 0 && (n >= size || (limit > 0 && length+add > limit)) {
+			pages = append(pages, page)
+			page, n, length = nil, 0, 0
+
+			lines = []string{e.group, line}
+			add = len(strings.Join(lines, "\n")) + 1
+		}
+
+		page = append(page, lines...)
+		group = e.group
+		length += add
+		n++
+	}
+
+	if len(page) > 0 {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+// help returns the help command listing the commands and the services
+// keyed by name with their "usage - description"
+func help(commands map[string]command.Command, services map[string]string) command.Command {
+	usage := "help [command|page]"
+	desc := "Displays help for all known commands or the usage of one"
+
+	// commands registered under several patterns are listed once
+	seen := map[string]bool{"help": true}
+	var cmds []command.Command
+
+	for _, cmd := range commands {
+		if seen[command.FullName(cmd)] {
+			continue
+		}
+		seen[command.FullName(cmd)] = true
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Sort(sortedCommands{cmds})
+
+	// index commands by name, the first word of it and their aliases,
+	// with and without their group
+	index := make(map[string][]command.Command)
+	var names []string
+
+	add := func(name string, cmd command.Command) {
+		for _, c := range index[name] {
+			if c == cmd {
+				return
+			}
+		}
+		index[name] = append(index[name], cmd)
+		names = append(names, name)
+	}
+
+	for _, cmd := range cmds {
+		var keys []string
+
+		name := strings.ToLower(cmd.String())
+		keys = append(keys, name)
+		if fields := strings.Fields(name); len(fields) > 0 {
+			keys = append(keys, normalize(fields[0]))
+		}
+
+		for _, key := range keys {
+			add(key, cmd)
+			if g := strings.ToLower(command.Group(cmd)); len(g) > 0 {
+				add(g+" "+key, cmd)
+			}
+		}
+
+		for _, alias := range command.Aliases(cmd) {
+			add(normalize(alias), cmd)
+		}
+	}
+
+	entries := []helpEntry{{"general", usage, desc}}
+
+	for _, cmd := range cmds {
+		group := command.Group(cmd)
+		if len(group) == 0 {
+			group = "other"
+		}
+
+		u := command.Usage(cmd)
+		if aliases := command.Aliases(cmd); len(aliases) > 0 {
+			u += " (" + strings.Join(aliases, ", ") + ")"
+		}
+
+		entries = append(entries, helpEntry{group, u, cmd.Description()})
+	}
+
+	// the watcher keeps updating the services it passed in
+	helps := make(map[string]string, len(services))
+	var serviceNames []string
+
+	for service, h := range services {
+		helps[service] = h
+		serviceNames = append(serviceNames, service)
+	}
+	sort.Strings(serviceNames)
+
+	for _, service := range serviceNames {
+		parts := strings.SplitN(helps[service], " - ", 2)
+		if len(parts) < 2 {
+			parts = append(parts, "")
+		}
+		entries = append(entries, helpEntry{"services", parts[0], parts[1]})
+		names = append(names, strings.TrimPrefix(service, Namespace+"."))
+	}
+
+	// list groups in order, keeping the commands sorted within them
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].group < entries[j].group
+	})
+
+	detail := func(ctx context.Context, name string) []byte {
+		switch cmds := index[name]; len(cmds) {
+		case 0:
+		case 1:
+			return command.Reply(ctx, helpDetail(cmds[0]))
+		default:
+			var options []string
+			for _, cmd := range cmds {
+				options = append(options, command.FullName(cmd))
+			}
+			sort.Strings(options)
+			return ambiguousCommand(originOfContext(ctx), name, options)
+		}
+
+		if h, ok := helps[Namespace+"."+name]; ok {
+			parts := strings.SplitN(h, " - ", 2)
+			rsp := &command.Response{Fields: []command.Field{{Key: "usage", Value: parts[0]}}}
+			if len(parts) > 1 {
+				rsp.Text = parts[1]
+			}
+			return command.Reply(ctx, rsp)
+		}
+
+		return unknownCommand(originOfContext(ctx), name, names)
+	}
+
+	return command.NewContextCommand("help", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		page := 1
+
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || len(args) > 2 {
+				return detail(ctx, normalize(strings.ToLower(strings.Join(args[1:], " ")))), nil
+			}
+			page = n
+		}
+
+		limit := command.MessageLimit(ctx)
+		if limit > 0 {
+			limit -= helpOverhead
+			if limit <= 0 {
+				limit = 1
+			}
+		}
+
+		pages := paginate(entries, limit, HelpPageSize)
+
+		if page < 1 || page > len(pages) {
+			return []byte(fmt.Sprintf("no page %d of help, there are %d", page, len(pages))), nil
+		}
+
+		return command.Reply(ctx, &command.Response{
+			Text: helpHeader(page, len(pages)),
+			Code: strings.Join(pages[page-1], "\n"),
+		}), nil
+	})
+}
+
+// helpDetail returns the detailed usage of the command
+func helpDetail(cmd command.Command) *command.Response {
+	rsp := &command.Response{
+		Text:   cmd.Description(),
+		Fields: []command.Field{{Key: "usage", Value: command.Usage(cmd)}},
+	}
+
+	if aliases := command.Aliases(cmd); len(aliases) > 0 {
+		rsp.Fields = append(rsp.Fields, command.Field{Key: "aliases", Value: strings.Join(aliases, ", ")})
+	}
+
+	if g := command.Group(cmd); len(g) > 0 {
+		rsp.Fields = append(rsp.Fields, command.Field{Key: "group", Value: g})
+	}
+
+	if ac, ok := cmd.(command.ArgsCommand); ok {
+		for _, a := range ac.Args() {
+			key := "[" + a.Name + "]"
+			if a.Required {
+				key = "<" + a.Name + ">"
+			}
+			rsp.Fields = append(rsp.Fields, command.Field{Key: key, Value: a.Description})
+		}
+	}
+
+	return rsp
+}
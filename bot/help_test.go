@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/micro/micro/bot/command"
+)
+
+func TestPaginate(t *testing.T) {
+	entries := []helpEntry{
+		{"general", "echo [text]", "Returns the [text]"},
+		{"general", "ping", "Returns pong"},
+		{"registry", "list services", "Returns a list of services"},
+	}
+
+	// the lines and the newline after each
+	first := len("general\n  echo [text]    Returns the [text]\n  ping           Returns pong\n")
+	all := first + len("registry\n  list services  Returns a list of services\n")
+
+	testData := []struct {
+		limit int
+		size  int
+		pages []string
+	}{
+		{0, 10, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong\nregistry\n  list services  Returns a list of services"}},
+		// exactly fits
+		{all, 10, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong\nregistry\n  list services  Returns a list of services"}},
+		// a character too many
+		{all - 1, 10, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong", "registry\n  list services  Returns a list of services"}},
+		{first, 10, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong", "registry\n  list services  Returns a list of services"}},
+		// pages continuing a group repeat it
+		{first - 1, 10, []string{"general\n  echo [text]    Returns the [text]", "general\n  ping           Returns pong", "registry\n  list services  Returns a list of services"}},
+		{0, 3, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong\nregistry\n  list services  Returns a list of services"}},
+		{0, 2, []string{"general\n  echo [text]    Returns the [text]\n  ping           Returns pong", "registry\n  list services  Returns a list of services"}},
+		// entries too long for a page get one of their own
+		{1, 10, []string{"general\n  echo [text]    Returns the [text]", "general\n  ping           Returns pong", "registry\n  list services  Returns a list of services"}},
+	}
+
+	for _, d := range testData {
+		pages := paginate(entries, d.limit, d.size)
+
+		var got []string
+		for _, p := range pages {
+			got = append(got, strings.Join(p, "\n"))
+		}
+
+		if strings.Join(got, "|") != strings.Join(d.pages, "|") {
+			t.Fatalf("limit %d size %d: expected %q got %q", d.limit, d.size, d.pages, got)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	exec := func(args ...string) ([]byte, error) { return nil, nil }
+
+	commands := map[string]command.Command{
+		"^deploy ": command.WithGroup(command.NewCommandWithArgs("deploy", "Deploys a service", []command.Arg{
+			{Name: "service", Required: true, Description: "name of the service"},
+			{Name: "version", Description: "defaults to latest"},
+		}, exec), "ops"),
+		"^health ": command.WithAliases(command.NewCommand("health", "health [service]", "Returns health of a service", exec), "hc"),
+		"^query ":  command.NewCommand("call", "call [service] [endpoint]", "Calls a service", exec),
+		"^call ":   command.NewCommand("call", "call [service] [endpoint]", "Calls a service", exec),
+	}
+
+	services := map[string]string{
+		Namespace + ".greeter": "greeter [name] - Greets the name",
+	}
+
+	h := help(commands, services)
+
+	run := func(ctx context.Context, args ...string) (string, *command.Response) {
+		ctx, rich := command.Capture(ctx)
+		rsp, err := command.ExecContext(ctx, h, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(rsp), rich()
+	}
+
+	rsp, rich := run(context.Background(), "help")
+
+	// the usage column lines up across groups
+	expect := "commands, run help [command] for details\n" +
+		"general\n" +
+		"  help [command|page]         Displays help for all known commands or the usage of one\n" +
+		"ops\n" +
+		"  deploy <service> [version]  Deploys a service\n" +
+		"other\n" +
+		"  call [service] [endpoint]   Calls a service\n" +
+		"  health [service] (hc)       Returns health of a service\n" +
+		"services\n" +
+		"  greeter [name]              Greets the name"
+
+	if rsp != expect {
+		t.Fatalf("expected %q got %q", expect, rsp)
+	}
+	if rich == nil || !strings.HasPrefix(rich.Code, "general\n") {
+		t.Fatalf("expected the list as code got %+v", rich)
+	}
+
+	testData := []struct {
+		args   []string
+		expect string
+	}{
+		{[]string{"help", "deploy"}, "Deploys a service\nusage      deploy <service> [version]\ngroup      ops\n<service>  name of the service\n[version]  defaults to latest"},
+		{[]string{"help", "Deploy?"}, "Deploys a service\nusage      deploy <service> [version]"},
+		{[]string{"help", "ops", "deploy"}, "Deploys a service\nusage      deploy <service> [version]\ngroup      ops"},
+		{[]string{"help", "hc"}, "Returns health of a service\nusage    health [service]\naliases  hc"},
+		{[]string{"help", "greeter"}, "Greets the name\nusage  greeter [name]"},
+		{[]string{"help", "deplyo"}, "unknown command 'deplyo', did you mean deploy? run help for a list of commands"},
+		{[]string{"help", "greter"}, "unknown command 'greter', did you mean greeter? run help for a list of commands"},
+		{[]string{"help", "nope"}, "unknown command 'nope', run help for a list of commands"},
+		{[]string{"help", "2"}, "no page 2 of help, there are 1"},
+		{[]string{"help", "0"}, "no page 0 of help, there are 1"},
+	}
+
+	for _, d := range testData {
+		if rsp, _ := run(context.Background(), d.args...); !strings.HasPrefix(rsp, d.expect) {
+			t.Fatalf("%v: expected %q got %q", d.args, d.expect, rsp)
+		}
+	}
+
+	// pages fit the message limit of the input
+	ctx := command.WithMessageLimit(context.Background(), helpOverhead+150)
+
+	rsp, _ = run(ctx, "help")
+	if !strings.HasPrefix(rsp, "commands, run help [command] for details (page 1 of 3, run help [page] for more)\ngeneral\n") {
+		t.Fatalf("unexpected first page %q", rsp)
+	}
+
+	var listed string
+	for page := 1; page <= 3; page++ {
+		rsp, rich := run(ctx, "help", fmt.Sprint(page))
+		if rich == nil || len(rich.Code)+1 > 150 || len(rsp) > helpOverhead+150 {
+			t.Fatalf("page %d: unexpected page %q", page, rsp)
+		}
+		listed += rich.Code + "\n"
+	}
+
+	for _, u := range []string{"help [command|page]", "deploy <service>", "call [service]", "health [service] (hc)", "greeter [name]"} {
+		if !strings.Contains(listed, u) {
+			t.Fatalf("expected %q on a page got %q", u, listed)
+		}
+	}
+}
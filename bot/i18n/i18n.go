@@ -0,0 +1,312 @@
+// Package i18n is the catalog of the messages the bot and its inputs
+// reply with, in each language they're translated to
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Key identifies a message of the catalog
+type Key string
+
+const (
+	// ErrorExecuting is the reply to a command which failed, {error}
+	ErrorExecuting Key = "error_executing"
+	// ErrorParsing is the reply to text which can't be split into
+	// args, {error}
+	ErrorParsing Key = "error_parsing"
+	// UnknownCommand is the reply to a command which doesn't exist,
+	// {name}
+	UnknownCommand Key = "unknown_command"
+	// UnknownCommandSuggest is UnknownCommand with the commands close
+	// to it, {name} and {suggestions}
+	UnknownCommandSuggest Key = "unknown_command_suggest"
+	// AmbiguousCommand is the reply to a command of several groups run
+	// without one, {name} and {options}
+	AmbiguousCommand Key = "ambiguous_command"
+	// CommandTimeout is the reply to a command exceeding its deadline,
+	// {name} and {timeout}
+	CommandTimeout Key = "command_timeout"
+	// CommandUnavailable is the reply to a command registered by a
+	// service which isn't running, {name}
+	CommandUnavailable Key = "command_unavailable"
+	// CommandCrashed is the reply to a command which panicked, {name}
+	// and {error}
+	CommandCrashed Key = "command_crashed"
+	// PermissionDenied is the reply to a command the sender may not
+	// run, {name} and {principal}
+	PermissionDenied Key = "permission_denied"
+	// PermissionDeniedUnknown is PermissionDenied for a sender who
+	// isn't known, {name}
+	PermissionDeniedUnknown Key = "permission_denied_unknown"
+	// SlowDown is the reply to a sender over the rate limit, {wait}
+	SlowDown Key = "slow_down"
+	// PipelineFailed is the reply to a pipeline a command of failed,
+	// {position}, {name} and {reason}
+	PipelineFailed Key = "pipeline_failed"
+	// PipelineNotRun is the reason given for a command of a pipeline
+	// which wasn't run
+	PipelineNotRun Key = "pipeline_not_run"
+	// Cancelled is the reply to cancelling a command's prompt, {name}
+	Cancelled Key = "cancelled"
+	// RequiresAdmin is the reply to an admin command from anyone else,
+	// {name}
+	RequiresAdmin Key = "requires_admin"
+	// RequiresGroup is the reply to a command restricted to the members
+	// of groups, {name} and {groups}
+	RequiresGroup Key = "requires_group"
+	// NotInChannel is the reply to a command which isn't allowed in the
+	// channel, {name}
+	NotInChannel Key = "not_in_channel"
+	// OutputAttached replaces output uploaded as a file, {size}
+	OutputAttached Key = "output_attached"
+	// ConfirmFailed is the reply when confirming a command couldn't be
+	// asked for, {command}
+	ConfirmFailed Key = "confirm_failed"
+)
+
+// English is the language messages fall back to
+const English = "en"
+
+// Params are substituted for the placeholders of a message such as
+// {name}
+type Params map[string]interface{}
+
+// Catalog is the messages of each language by key. Messages missing
+// from a language are taken from English.
+type Catalog struct {
+	sync.RWMutex
+	messages map[string]map[Key]string
+	language string
+	// language of channels by input:channel
+	channels map[string]string
+}
+
+// New returns a catalog of the built in languages replying in English
+func New() *Catalog {
+	c := &Catalog{
+		messages: make(map[string]map[Key]string),
+		language: English,
+		channels: make(map[string]string),
+	}
+	for lang, messages := range builtin {
+		c.messages[lang] = make(map[Key]string, len(messages))
+		for key, text := range messages {
+			c.messages[lang][key] = text
+		}
+	}
+	return c
+}
+
+// Load adds the messages of a JSON object of languages, each an object
+// of messages by key, replacing those already in the catalog
+func (c *Catalog) Load(r io.Reader) error {
+	var languages map[string]map[Key]string
+	if err := json.NewDecoder(r).Decode(&languages); err != nil {
+		return err
+	}
+
+	for lang, messages := range languages {
+		if len(lang) == 0 {
+			return fmt.Errorf("missing language")
+		}
+		for key := range messages {
+			if _, ok := builtin[English][key]; !ok {
+				return fmt.Errorf("%s: unknown message %q", lang, key)
+			}
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for lang, messages := range languages {
+		lang = normalize(lang)
+		if c.messages[lang] == nil {
+			c.messages[lang] = make(map[Key]string)
+		}
+		for key, text := range messages {
+			// empty messages are as good as missing
+			if len(text) > 0 {
+				c.messages[lang][key] = text
+			}
+		}
+	}
+
+	return nil
+}
+
+// LoadFile loads the messages of the file as Load does
+func (c *Catalog) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := c.Load(f); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+func normalize(lang string) string {
+	return strings.Replace(strings.ToLower(strings.TrimSpace(lang)), "_", "-", -1)
+}
+
+// match returns the language of the catalog closest to lang, e.g. es
+// for es-MX, empty if there's none
+func (c *Catalog) match(lang string) string {
+	lang = normalize(lang)
+	if _, ok := c.messages[lang]; ok {
+		return lang
+	}
+	if i := strings.Index(lang, "-"); i > 0 {
+		if _, ok := c.messages[lang[:i]]; ok {
+			return lang[:i]
+		}
+	}
+	return ""
+}
+
+// Languages returns the languages of the catalog
+func (c *Catalog) Languages() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	var langs []string
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SetLanguage sets the language replies are in unless the channel's
+// is set
+func (c *Catalog) SetLanguage(lang string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	l := c.match(lang)
+	if len(l) == 0 {
+		return fmt.Errorf("no messages in language %q", lang)
+	}
+	c.language = l
+	return nil
+}
+
+// SetChannelLanguage sets the language of replies in the channel of
+// the input
+func (c *Catalog) SetChannelLanguage(input, channel, lang string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	l := c.match(lang)
+	if len(l) == 0 {
+		return fmt.Errorf("no messages in language %q", lang)
+	}
+	c.channels[input+":"+channel] = l
+	return nil
+}
+
+// Language returns the language of replies in the channel of the input
+func (c *Catalog) Language(input, channel string) string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if l, ok := c.channels[input+":"+channel]; ok {
+		return l
+	}
+	return c.language
+}
+
+// Format returns the message in the language with the params
+// substituted. It falls back to English, and to the key if it isn't
+// known at all.
+func (c *Catalog) Format(lang string, key Key, params Params) string {
+	c.RLock()
+	text := c.messages[c.match(lang)][key]
+	if len(text) == 0 {
+		text = c.messages[English][key]
+	}
+	c.RUnlock()
+
+	if len(text) == 0 {
+		text = string(key)
+	}
+
+	return substitute(text, params)
+}
+
+// Text returns the message in the language of the channel of the input
+func (c *Catalog) Text(input, channel string, key Key, params Params) string {
+	return c.Format(c.Language(input, channel), key, params)
+}
+
+// substitute replaces the placeholders of the params, leaving those of
+// params which aren't given as they are
+func substitute(text string, params Params) string {
+	if len(params) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "{")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(text[i:], "}")
+		if j < 0 {
+			break
+		}
+
+		name := text[i+1 : i+j]
+		v, ok := params[name]
+		if !ok {
+			b.WriteString(text[:i+j+1])
+			text = text[i+j+1:]
+			continue
+		}
+
+		b.WriteString(text[:i])
+		fmt.Fprint(&b, v)
+		text = text[i+j+1:]
+	}
+	b.WriteString(text)
+
+	return b.String()
+}
+
+var (
+	mtx sync.RWMutex
+	// the catalog the package funcs use
+	catalog = New()
+)
+
+// Use sets the catalog the bot and its inputs reply from
+func Use(c *Catalog) {
+	mtx.Lock()
+	catalog = c
+	mtx.Unlock()
+}
+
+// Default returns the catalog the bot and its inputs reply from
+func Default() *Catalog {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return catalog
+}
+
+// Text returns the message of the default catalog in the language of
+// the channel of the input
+func Text(input, channel string, key Key, params Params) string {
+	return Default().Text(input, channel, key, params)
+}
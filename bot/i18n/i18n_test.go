@@ -0,0 +1,155 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	c := New()
+
+	testData := []struct {
+		lang   string
+		key    Key
+		params Params
+		expect string
+	}{
+		{"en", UnknownCommand, Params{"name": "deploy"}, "unknown command 'deploy', run help for a list of commands"},
+		{"es", UnknownCommand, Params{"name": "deploy"}, "comando desconocido 'deploy', ejecuta help para ver la lista de comandos"},
+		{"es-MX", Cancelled, Params{"name": "deploy"}, "deploy cancelado"},
+		{"ES_mx", Cancelled, Params{"name": "deploy"}, "deploy cancelado"},
+		// unknown languages are English
+		{"fr", Cancelled, Params{"name": "deploy"}, "cancelled deploy"},
+		{"", Cancelled, Params{"name": "deploy"}, "cancelled deploy"},
+		// params missing are left as they are, values aren't substituted
+		{"en", PipelineFailed, Params{"position": 2, "name": "{reason}"}, "pipeline failed at command 2 ({reason}): {reason}"},
+		{"en", PipelineNotRun, nil, "not run"},
+		// unknown keys are never empty
+		{"en", Key("nope"), nil, "nope"},
+	}
+
+	for _, d := range testData {
+		if got := c.Format(d.lang, d.key, d.params); got != d.expect {
+			t.Fatalf("%s %s: expected %q got %q", d.lang, d.key, d.expect, got)
+		}
+	}
+
+	// every language has its messages in English
+	for lang, messages := range builtin {
+		for key := range messages {
+			if len(builtin[English][key]) == 0 {
+				t.Fatalf("%s: %s has no English message", lang, key)
+			}
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	c := New()
+
+	if err := c.SetLanguage("es"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetChannelLanguage("slack", "C0EN", "en-GB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Text("slack", "C0OPS", SlowDown, Params{"wait": "1s"}); got != "más despacio, inténtalo de nuevo en 1s" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	if got := c.Text("slack", "C0EN", SlowDown, Params{"wait": "1s"}); got != "slow down, try again in 1s" {
+		t.Fatalf("unexpected message %q", got)
+	}
+
+	for _, err := range []error{c.SetLanguage("xx"), c.SetChannelLanguage("slack", "C0", "xx")} {
+		if err == nil || err.Error() != `no messages in language "xx"` {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if l := c.Language("slack", "C0"); l != "es" {
+		t.Fatalf("expected the language to be kept got %s", l)
+	}
+
+	// the package funcs use the default catalog
+	defer Use(Default())
+	Use(c)
+	if got := Text("slack", "C0", Cancelled, Params{"name": "deploy"}); got != "deploy cancelado" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	c := New()
+
+	err := c.Load(strings.NewReader(`{
+		"fr": {"cancelled": "{name} annulé", "slow_down": ""},
+		"es": {"cancelled": "se canceló {name}"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Format("fr", Cancelled, Params{"name": "deploy"}); got != "deploy annulé" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	// missing translations fall back to English
+	if got := c.Format("fr", SlowDown, Params{"wait": "1s"}); got != "slow down, try again in 1s" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	// those loaded replace the built in
+	if got := c.Format("es", Cancelled, Params{"name": "deploy"}); got != "se canceló deploy" {
+		t.Fatalf("unexpected message %q", got)
+	}
+	if langs := strings.Join(c.Languages(), ","); langs != "en,es,fr" {
+		t.Fatalf("unexpected languages %s", langs)
+	}
+
+	for _, d := range []struct {
+		data string
+		err  string
+	}{
+		{`{"fr": {"cancelled": "annulé"`, "unexpected EOF"},
+		{`{"fr": {"cancelled": 1}}`, "json: cannot unmarshal number"},
+		{`{"fr": {"canceled": "annulé"}}`, `fr: unknown message "canceled"`},
+		{`{"": {"cancelled": "annulé"}}`, "missing language"},
+	} {
+		c := New()
+		if err := c.Load(strings.NewReader(d.data)); err == nil || !strings.HasPrefix(err.Error(), d.err) {
+			t.Fatalf("%s: expected error %q got %v", d.data, d.err, err)
+		}
+		// nothing is loaded from a bad catalog
+		if len(c.Languages()) != 2 {
+			t.Fatalf("%s: unexpected languages %v", d.data, c.Languages())
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"de": {"cancelled": }`)
+	f.Close()
+
+	c := New()
+	if err := c.LoadFile(f.Name()); err == nil || !strings.HasPrefix(err.Error(), f.Name()+": invalid character") {
+		t.Fatalf("expected the file in the error got %v", err)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte(`{"de": {"cancelled": "{name} abgebrochen"}}`), 0600)
+	if err := c.LoadFile(f.Name()); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Format("de-AT", Cancelled, Params{"name": "deploy"}); got != "deploy abgebrochen" {
+		t.Fatalf("unexpected message %q", got)
+	}
+
+	if err := c.LoadFile(f.Name() + ".nope"); !os.IsNotExist(err) {
+		t.Fatalf("expected not exist got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package i18n
+
+// builtin are the messages of the languages the bot ships with. Every
+// key must have an English message.
+var builtin = map[string]map[Key]string{
+	English: {
+		ErrorExecuting:          "error executing cmd: {error}",
+		ErrorParsing:            "error parsing command: {error}",
+		UnknownCommand:          "unknown command '{name}', run help for a list of commands",
+		UnknownCommandSuggest:   "unknown command '{name}', did you mean {suggestions}? run help for a list of commands",
+		AmbiguousCommand:        "'{name}' is a command in several groups, run one of {options}",
+		CommandTimeout:          "command '{name}' timed out after {timeout}",
+		CommandUnavailable:      "command '{name}' temporarily unavailable",
+		CommandCrashed:          "command '{name}' crashed: {error}",
+		PermissionDenied:        "permission denied: {principal} may not run '{name}'",
+		PermissionDeniedUnknown: "permission denied: unknown users may not run '{name}'",
+		SlowDown:                "slow down, try again in {wait}",
+		PipelineFailed:          "pipeline failed at command {position} ({name}): {reason}",
+		PipelineNotRun:          "not run",
+		Cancelled:               "cancelled {name}",
+		RequiresAdmin:           "permission denied: command '{name}' requires admin",
+		RequiresGroup:           "sorry, command '{name}' can only be run by members of {groups}",
+		NotInChannel:            "command '{name}' is not allowed in this channel",
+		OutputAttached:          "output attached ({size} bytes)",
+		ConfirmFailed:           "could not request confirmation for {command}",
+	},
+	"es": {
+		ErrorExecuting:          "error al ejecutar el comando: {error}",
+		ErrorParsing:            "error al interpretar el comando: {error}",
+		UnknownCommand:          "comando desconocido '{name}', ejecuta help para ver la lista de comandos",
+		UnknownCommandSuggest:   "comando desconocido '{name}', ¿quisiste decir {suggestions}? ejecuta help para ver la lista de comandos",
+		AmbiguousCommand:        "'{name}' es un comando de varios grupos, ejecuta uno de {options}",
+		CommandTimeout:          "el comando '{name}' excedió el tiempo límite de {timeout}",
+		CommandUnavailable:      "el comando '{name}' no está disponible temporalmente",
+		CommandCrashed:          "el comando '{name}' falló: {error}",
+		PermissionDenied:        "permiso denegado: {principal} no puede ejecutar '{name}'",
+		PermissionDeniedUnknown: "permiso denegado: los usuarios desconocidos no pueden ejecutar '{name}'",
+		SlowDown:                "más despacio, inténtalo de nuevo en {wait}",
+		PipelineFailed:          "la cadena falló en el comando {position} ({name}): {reason}",
+		PipelineNotRun:          "no ejecutado",
+		Cancelled:               "{name} cancelado",
+		RequiresAdmin:           "permiso denegado: el comando '{name}' requiere ser administrador",
+		RequiresGroup:           "lo siento, el comando '{name}' solo pueden ejecutarlo los miembros de {groups}",
+		NotInChannel:            "el comando '{name}' no está permitido en este canal",
+		OutputAttached:          "salida adjunta ({size} bytes)",
+		ConfirmFailed:           "no se pudo pedir confirmación para {command}",
+	},
+}
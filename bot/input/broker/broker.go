@@ -0,0 +1,193 @@
+// Package broker is an input for services to run bot commands. Commands
+// published to a topic on the micro broker are run and their output
+// published to the reply topic named in the message headers.
+package broker
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro/broker"
+	"github.com/micro/micro/bot/input"
+)
+
+// Headers of command messages and their replies
+const (
+	// HeaderReplyTo is the topic the reply is published to
+	HeaderReplyTo = "Micro-Bot-Reply-To"
+	// HeaderCorrelationID is copied from the command to its reply
+	HeaderCorrelationID = "Micro-Bot-Correlation-Id"
+	// HeaderFrom names the service sending the command
+	HeaderFrom = "Micro-Bot-From"
+	// HeaderError is set on replies when the command failed
+	HeaderError = "Micro-Bot-Error"
+)
+
+type brokerInput struct {
+	topic      string
+	replyTopic string
+	queue      string
+	// the broker to subscribe with, the service's unless set
+	broker broker.Broker
+
+	sync.Mutex
+	running  bool
+	exit     chan bool
+	requests chan *request
+	sub      broker.Subscriber
+}
+
+func init() {
+	input.Register("broker", NewInput())
+}
+
+func (p *brokerInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "broker_topic",
+			Usage: "Topic commands are published to",
+			Value: "micro.bot.commands",
+		},
+		cli.StringFlag{
+			Name:  "broker_reply_topic",
+			Usage: "Topic replies are published to when a command doesn't set the " + HeaderReplyTo + " header",
+		},
+		cli.StringFlag{
+			Name:  "broker_queue",
+			Usage: "Queue to subscribe with so only one of several bots runs each command",
+		},
+	}
+}
+
+func (p *brokerInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	topic := cfg.String("broker_topic", "micro.bot.commands")
+	if len(topic) == 0 {
+		return errors.New("missing broker topic")
+	}
+
+	p.topic = topic
+	p.replyTopic = cfg.String("broker_reply_topic", "")
+	p.queue = cfg.String("broker_queue", "")
+
+	return nil
+}
+
+// handler passes the commands to the conns
+func (p *brokerInput) handler(requests chan *request, exit chan bool) broker.Handler {
+	return func(pub broker.Publication) error {
+		msg := pub.Message()
+		if msg == nil || len(msg.Body) == 0 {
+			log.Logf("[broker] ignoring empty command on %s", pub.Topic())
+			return nil
+		}
+
+		r := &request{
+			topic:   pub.Topic(),
+			from:    msg.Header[HeaderFrom],
+			replyTo: msg.Header[HeaderReplyTo],
+			id:      msg.Header[HeaderCorrelationID],
+			text:    string(msg.Body),
+			broker:  p.broker,
+		}
+
+		if len(r.replyTo) == 0 {
+			r.replyTo = p.replyTopic
+		}
+
+		if len(r.from) == 0 {
+			r.from = "broker"
+		}
+
+		select {
+		case <-exit:
+			return errors.New("broker input stopped")
+		case requests <- r:
+		}
+
+		return nil
+	}
+}
+
+func (p *brokerInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.requests, p.exit), nil
+}
+
+func (p *brokerInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if p.broker == nil {
+		p.broker = broker.DefaultBroker
+	}
+
+	// shared with the service which connects it too
+	if err := p.broker.Connect(); err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+	requests := make(chan *request)
+
+	var opts []broker.SubscribeOption
+	if len(p.queue) > 0 {
+		opts = append(opts, broker.Queue(p.queue))
+	}
+
+	sub, err := p.broker.Subscribe(p.topic, p.handler(requests, exit), opts...)
+	if err != nil {
+		return err
+	}
+
+	log.Logf("[broker] subscribed to %s on the %s broker", p.topic, p.broker.String())
+
+	p.exit = exit
+	p.requests = requests
+	p.sub = sub
+	p.running = true
+
+	return nil
+}
+
+func (p *brokerInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	if err := p.sub.Unsubscribe(); err != nil {
+		log.Logf("[broker] error unsubscribing from %s: %v", p.topic, err)
+	}
+
+	p.sub = nil
+	p.running = false
+	return nil
+}
+
+func (p *brokerInput) String() string {
+	return "broker"
+}
+
+func NewInput() input.Input {
+	return &brokerInput{
+		topic: "micro.bot.commands",
+	}
+}
@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/broker"
+	"github.com/micro/go-micro/broker/memory"
+	"github.com/micro/micro/bot/input"
+)
+
+// serve answers commands as the bot does, "fail" returns an error and
+// anything else is echoed
+func serve(c input.Conn) {
+	n := c.(*brokerConn)
+
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+
+		done := n.Notify(ev)
+		text := string(ev.Data)
+
+		if text == "fail" {
+			done(errors.New("oops"))
+			c.Send(&input.Event{Meta: ev.Meta, Data: []byte("error executing cmd: oops")})
+			continue
+		}
+
+		done(nil)
+		c.Send(&input.Event{Meta: ev.Meta, Data: []byte(ev.From + " ran " + text)})
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := memory.NewBroker()
+
+	p := NewInput().(*brokerInput)
+	p.broker = b
+	p.replyTopic = "micro.bot.replies"
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go serve(c)
+
+	replies := make(chan *broker.Message, 10)
+	for _, topic := range []string{"go.micro.srv.deploy.replies", "micro.bot.replies"} {
+		topic := topic
+		_, err := b.Subscribe(topic, func(pub broker.Publication) error {
+			msg := pub.Message()
+			msg.Header["topic"] = topic
+			replies <- msg
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testData := []struct {
+		header map[string]string
+		body   string
+		topic  string
+		reply  string
+		err    string
+	}{
+		{
+			map[string]string{HeaderFrom: "go.micro.srv.deploy", HeaderReplyTo: "go.micro.srv.deploy.replies", HeaderCorrelationID: "1"},
+			"deploy api", "go.micro.srv.deploy.replies", "go.micro.srv.deploy ran deploy api", "",
+		},
+		// replies go to the default topic without a header
+		{map[string]string{HeaderCorrelationID: "2"}, "ping", "micro.bot.replies", "broker ran ping", ""},
+		{map[string]string{HeaderCorrelationID: "3"}, "fail", "micro.bot.replies", "error executing cmd: oops", "oops"},
+	}
+
+	for _, d := range testData {
+		if err := b.Publish("micro.bot.commands", &broker.Message{Header: d.header, Body: []byte(d.body)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-replies:
+			if msg.Header["topic"] != d.topic || string(msg.Body) != d.reply || msg.Header[HeaderError] != d.err {
+				t.Fatalf("%s: expected %s %q %q got %v %q", d.body, d.topic, d.reply, d.err, msg.Header, msg.Body)
+			}
+			if msg.Header[HeaderCorrelationID] != d.header[HeaderCorrelationID] {
+				t.Fatalf("%s: expected correlation id %s got %s", d.body, d.header[HeaderCorrelationID], msg.Header[HeaderCorrelationID])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: timed out waiting for the reply", d.body)
+		}
+	}
+
+	// empty commands are dropped
+	if err := b.Publish("micro.bot.commands", &broker.Message{Header: map[string]string{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-replies:
+		t.Fatalf("unexpected reply %q", msg.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.Stop()
+
+	// the memory broker unsubscribes asynchronously, if the handler is
+	// still called it returns an error
+	b.Publish("micro.bot.commands", &broker.Message{Header: map[string]string{}, Body: []byte("ping")})
+
+	select {
+	case msg := <-replies:
+		t.Fatalf("unexpected reply once stopped %q", msg.Body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
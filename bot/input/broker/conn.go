@@ -0,0 +1,136 @@
+package broker
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/go-micro/broker"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events
+const (
+	// MetaTopic is the topic the command was published to
+	MetaTopic = "broker_topic"
+	// MetaReplyTo is the topic the reply is published to, empty if the
+	// command isn't replied to
+	MetaReplyTo = "broker_reply_to"
+	// MetaCorrelationID is the correlation ID of the command
+	MetaCorrelationID = "broker_correlation_id"
+)
+
+// request is a command published to the topic
+type request struct {
+	topic   string
+	from    string
+	replyTo string
+	id      string
+	text    string
+	broker  broker.Broker
+
+	sync.Mutex
+	// the error the command returned
+	err error
+}
+
+// Satisfies the input.Conn interface
+type brokerConn struct {
+	requests <-chan *request
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(requests <-chan *request, exit chan bool) *brokerConn {
+	return &brokerConn{
+		requests: requests,
+		exit:     exit,
+		closed:   make(chan bool),
+	}
+}
+
+func (c *brokerConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *brokerConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-c.exit:
+		return errors.New("broker input stopped")
+	case <-c.closed:
+		return errors.New("connection closed")
+	case r := <-c.requests:
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = r.from
+		event.To = r.topic
+		event.Type = input.TextEvent
+		event.Data = []byte(r.text)
+		event.Meta["reply"] = r
+		event.Meta[MetaTopic] = r.topic
+		event.Meta[MetaReplyTo] = r.replyTo
+		event.Meta[MetaCorrelationID] = r.id
+
+		return nil
+	}
+}
+
+// Notify records the result of the command so the reply can say if it
+// failed
+func (c *brokerConn) Notify(event input.Event) func(error) {
+	r, ok := event.Meta["reply"].(*request)
+	if !ok {
+		return func(error) {}
+	}
+
+	return func(err error) {
+		r.Lock()
+		r.err = err
+		r.Unlock()
+	}
+}
+
+// Send publishes the output to the reply topic of the command
+func (c *brokerConn) Send(event *input.Event) error {
+	r, ok := event.Meta["reply"].(*request)
+	if !ok {
+		return errors.New("broker input can only reply to commands")
+	}
+
+	// the sender didn't want a reply
+	if len(r.replyTo) == 0 {
+		return nil
+	}
+
+	msg := &broker.Message{
+		Header: map[string]string{
+			HeaderCorrelationID: r.id,
+		},
+		Body: event.Data,
+	}
+
+	r.Lock()
+	if r.err != nil {
+		msg.Header[HeaderError] = r.err.Error()
+	}
+	r.Unlock()
+
+	if err := r.broker.Publish(r.replyTo, msg); err != nil {
+		log.Logf("[broker] error replying to %s: %v", r.replyTo, err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package input
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/cli"
+)
+
+// Config resolves the settings of an input, taking each from its flag
+// when set on the command line, then from the MICRO_<FLAG_NAME>
+// environment variable, then the default of the flag. The default
+// passed is used for flags the bot didn't parse, such as those of
+// inputs loaded from plugins.
+//
+// Values of environment variables which don't parse are reported by
+// Err naming the variable, the default being used in their place.
+type Config struct {
+	ctx *cli.Context
+	err error
+}
+
+// NewConfig returns the config of the flags of the context
+func NewConfig(ctx *cli.Context) *Config {
+	return &Config{ctx: ctx}
+}
+
+// EnvVar returns the environment variable the flag falls back to e.g.
+// MICRO_SLACK_TOKEN for slack_token
+func EnvVar(flag string) string {
+	return "MICRO_" + strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(flag))
+}
+
+// lookup returns the value of the flag's environment variable unless
+// the flag was set
+func (c *Config) lookup(name string) (string, bool) {
+	if c.ctx != nil && c.ctx.IsSet(name) {
+		return "", false
+	}
+	v, ok := os.LookupEnv(EnvVar(name))
+	if !ok || len(v) == 0 {
+		return "", false
+	}
+	return v, true
+}
+
+// defined returns whether the bot parsed the flag
+func (c *Config) defined(name string) bool {
+	return c.ctx != nil && c.ctx.Generic(name) != nil
+}
+
+// invalid records the first value which didn't parse
+func (c *Config) invalid(name, v, want string) {
+	if c.err == nil {
+		c.err = fmt.Errorf("invalid %s=%s, expected %s", EnvVar(name), v, want)
+	}
+}
+
+// String returns the string flag
+func (c *Config) String(name, def string) string {
+	if v, ok := c.lookup(name); ok {
+		return v
+	}
+	if c.defined(name) {
+		return c.ctx.String(name)
+	}
+	return def
+}
+
+// Bool returns the bool flag, true by default for a BoolTFlag
+func (c *Config) Bool(name string, def bool) bool {
+	if v, ok := c.lookup(name); ok {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+		c.invalid(name, v, "true or false")
+	}
+	if c.defined(name) {
+		return c.ctx.Bool(name)
+	}
+	return def
+}
+
+// Int returns the int flag
+func (c *Config) Int(name string, def int) int {
+	if v, ok := c.lookup(name); ok {
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+		c.invalid(name, v, "an integer")
+	}
+	if c.defined(name) {
+		return c.ctx.Int(name)
+	}
+	return def
+}
+
+// Duration returns the duration flag
+func (c *Config) Duration(name string, def time.Duration) time.Duration {
+	if v, ok := c.lookup(name); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		c.invalid(name, v, "a duration e.g. 30s")
+	}
+	if c.defined(name) {
+		return c.ctx.Duration(name)
+	}
+	return def
+}
+
+// StringSlice returns the string slice flag, the environment variable
+// being a comma separated list
+func (c *Config) StringSlice(name string, def []string) []string {
+	if v, ok := c.lookup(name); ok {
+		var list []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	if c.defined(name) {
+		return c.ctx.StringSlice(name)
+	}
+	return def
+}
+
+// Err returns the first environment variable which didn't parse
+func (c *Config) Err() error {
+	return c.err
+}
@@ -0,0 +1,134 @@
+package input
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+)
+
+func TestEnvVar(t *testing.T) {
+	for name, env := range map[string]string{
+		"slack_token":       "MICRO_SLACK_TOKEN",
+		"irc-nick":          "MICRO_IRC_NICK",
+		"matrix.homeserver": "MICRO_MATRIX_HOMESERVER",
+	} {
+		if v := EnvVar(name); v != env {
+			t.Fatalf("%s: expected %s got %s", name, env, v)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.String("test_set", "default", "")
+	set.String("test_string", "default", "")
+	set.Bool("test_bool", true, "")
+	set.Int("test_int", 3, "")
+	set.Duration("test_duration", time.Minute, "")
+	if err := set.Parse([]string{"--test_set=flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"MICRO_TEST_SET":       "env",
+		"MICRO_TEST_STRING":    "env",
+		"MICRO_TEST_BOOL":      "false",
+		"MICRO_TEST_INT":       "5",
+		"MICRO_TEST_DURATION":  "2s",
+		"MICRO_TEST_UNDEFINED": "env",
+	}
+
+	setenv := func(env map[string]string) {
+		for k, v := range env {
+			os.Setenv(k, v)
+		}
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	// the flags and their defaults without the env
+	c := NewConfig(ctx)
+	if v := c.String("test_set", "def"); v != "flag" {
+		t.Fatalf("expected the flag got %s", v)
+	}
+	if v := c.String("test_string", "def"); v != "default" {
+		t.Fatalf("expected the flag's default got %s", v)
+	}
+	if v := c.String("test_undefined", "def"); v != "def" {
+		t.Fatalf("expected the default got %s", v)
+	}
+	if v := c.Bool("test_bool", false); !v {
+		t.Fatal("expected the flag's default")
+	}
+
+	// the env is taken over defaults but not set flags
+	setenv(env)
+
+	c = NewConfig(ctx)
+	if v := c.String("test_set", "def"); v != "flag" {
+		t.Fatalf("expected the flag got %s", v)
+	}
+	if v := c.String("test_string", "def"); v != "env" {
+		t.Fatalf("expected the env got %s", v)
+	}
+	if v := c.String("test_undefined", "def"); v != "env" {
+		t.Fatalf("expected the env got %s", v)
+	}
+	if v := c.Bool("test_bool", true); v {
+		t.Fatal("expected the env")
+	}
+	if v := c.Int("test_int", 0); v != 5 {
+		t.Fatalf("expected the env got %d", v)
+	}
+	if v := c.Duration("test_duration", 0); v != 2*time.Second {
+		t.Fatalf("expected the env got %v", v)
+	}
+	if err := c.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// values which don't parse are reported, the first one
+	setenv(map[string]string{
+		"MICRO_TEST_BOOL":     "maybe",
+		"MICRO_TEST_INT":      "five",
+		"MICRO_TEST_DURATION": "2",
+	})
+
+	for _, d := range []struct {
+		get func(c *Config)
+		err string
+	}{
+		{func(c *Config) { c.Bool("test_bool", false) }, "invalid MICRO_TEST_BOOL=maybe, expected true or false"},
+		{func(c *Config) { c.Int("test_int", 0) }, "invalid MICRO_TEST_INT=five, expected an integer"},
+		{func(c *Config) { c.Duration("test_duration", 0) }, "invalid MICRO_TEST_DURATION=2, expected a duration e.g. 30s"},
+	} {
+		c := NewConfig(ctx)
+		d.get(c)
+		d.get(c)
+		if err := c.Err(); err == nil || err.Error() != d.err {
+			t.Fatalf("expected error %q got %v", d.err, err)
+		}
+	}
+
+	// lists are comma separated
+	os.Setenv("MICRO_TEST_LIST", "a, b,,c")
+	defer os.Unsetenv("MICRO_TEST_LIST")
+	if v := NewConfig(ctx).StringSlice("test_list", nil); strings.Join(v, "|") != "a|b|c" {
+		t.Fatalf("expected the env's list got %v", v)
+	}
+
+	// the flag's default is used in their place
+	c = NewConfig(ctx)
+	if v := c.Int("test_int", 0); v != 3 || c.Err() == nil {
+		t.Fatalf("expected the flag's default and an error got %d %v", v, c.Err())
+	}
+}
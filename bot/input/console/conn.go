@@ -0,0 +1,103 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// Satisfies the input.Conn interface
+type consoleConn struct {
+	lines <-chan *line
+	// closed when the input stops
+	exit chan bool
+	// where replies are written
+	out io.Writer
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(lines <-chan *line, exit chan bool, out io.Writer) *consoleConn {
+	return &consoleConn{
+		lines:  lines,
+		exit:   exit,
+		out:    out,
+		closed: make(chan bool),
+	}
+}
+
+func (c *consoleConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *consoleConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-c.exit:
+		return errors.New("console input stopped")
+	case <-c.closed:
+		return errors.New("connection closed")
+	case l := <-c.lines:
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = "console"
+		event.To = "bot"
+		event.Type = input.TextEvent
+		event.Data = []byte(l.text)
+		event.Meta["reply"] = l
+
+		return nil
+	}
+}
+
+// Notify records the command ran and its result so scripts can fail
+func (c *consoleConn) Notify(event input.Event) func(error) {
+	l, ok := event.Meta["reply"].(*line)
+	if !ok {
+		return func(error) {}
+	}
+
+	l.Lock()
+	l.executed = true
+	l.Unlock()
+
+	return func(err error) {
+		l.Lock()
+		l.err = err
+		l.Unlock()
+	}
+}
+
+// Progress prints the progress of a command as it's reported
+func (c *consoleConn) Progress(event input.Event) (func([]string), time.Duration) {
+	return func(lines []string) {
+		for _, l := range lines {
+			fmt.Fprintln(c.out, l)
+		}
+	}, 0
+}
+
+// Send prints the output, answering the line it's in reply to
+func (c *consoleConn) Send(event *input.Event) error {
+	fmt.Fprintln(c.out, strings.TrimRight(string(event.Data), "\n"))
+
+	if l, ok := event.Meta["reply"].(*line); ok {
+		l.answer()
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+// Package console is a console input for developing commands locally.
+// Commands are read from stdin, or a script, and their output printed
+// to stdout.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/input"
+)
+
+var (
+	// exit is called once stdin or the script is finished
+	exit = os.Exit
+	// where the console reads and writes
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+)
+
+// line is a command read from the console waiting to be answered
+type line struct {
+	text string
+
+	sync.Mutex
+	// set once the bot executes the command which isn't the case for
+	// unknown commands, bad usage or text which can't be parsed
+	executed bool
+	err      error
+
+	once sync.Once
+	done chan bool
+}
+
+// answer marks the line answered
+func (l *line) answer() {
+	l.once.Do(func() {
+		close(l.done)
+	})
+}
+
+// failed returns true if the command wasn't run or returned an error
+func (l *line) failed() bool {
+	l.Lock()
+	defer l.Unlock()
+	return !l.executed || l.err != nil
+}
+
+type consoleInput struct {
+	script string
+	prompt string
+
+	// the script read in place of the file or stdin, where its output
+	// is written and what's called once it's finished
+	in   io.Reader
+	out  io.Writer
+	quit func(int)
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	lines   chan *line
+	// where the conns write
+	w io.Writer
+}
+
+func init() {
+	input.Register("console", NewInput())
+}
+
+func (p *consoleInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "console_script",
+			Usage: "File of commands to run, one per line, exiting non-zero if any fail; stdin is read if empty",
+		},
+		cli.StringFlag{
+			Name:  "console_prompt",
+			Usage: "Prompt shown when reading commands from stdin",
+			Value: "> ",
+		},
+	}
+}
+
+func (p *consoleInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	p.script = cfg.String("console_script", "")
+	p.prompt = cfg.String("console_prompt", "> ")
+	return nil
+}
+
+// read feeds the lines of r to the conns one at a time, waiting for
+// each to be answered. Scripts exit non-zero if a command failed.
+func (p *consoleInput) read(r io.Reader, w io.Writer, exit func(int), scripted bool, lines chan *line, done chan bool) {
+	var run, failed int
+
+	s := bufio.NewScanner(r)
+
+	for {
+		if !scripted {
+			fmt.Fprint(w, p.prompt)
+		}
+
+		if !s.Scan() {
+			break
+		}
+
+		text := strings.TrimSpace(s.Text())
+
+		// blank lines and comments in scripts
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if scripted {
+			fmt.Fprintf(w, "%s%s\n", p.prompt, text)
+		}
+
+		l := &line{text: text, done: make(chan bool)}
+
+		select {
+		case <-done:
+			return
+		case lines <- l:
+		}
+
+		select {
+		case <-done:
+			return
+		case <-l.done:
+		}
+
+		run++
+		if l.failed() {
+			failed++
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		fmt.Fprintf(w, "error reading commands: %v\n", err)
+		exit(1)
+		return
+	}
+
+	if !scripted {
+		fmt.Fprintln(stdout)
+		exit(0)
+		return
+	}
+
+	fmt.Fprintf(w, "%d commands run, %d failed\n", run, failed)
+
+	if failed > 0 {
+		exit(1)
+		return
+	}
+
+	exit(0)
+}
+
+func (p *consoleInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.lines, p.exit, p.w), nil
+}
+
+func (p *consoleInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	r, w, quit := stdin, stdout, exit
+	scripted := len(p.script) > 0
+
+	if p.in != nil {
+		r, w, quit = p.in, p.out, p.quit
+		scripted = true
+	} else if scripted {
+		f, err := os.Open(p.script)
+		if err != nil {
+			return err
+		}
+		r = f
+	}
+
+	done := make(chan bool)
+	lines := make(chan *line)
+
+	go func() {
+		p.read(r, w, quit, scripted, lines, done)
+		if f, ok := r.(*os.File); ok && scripted {
+			f.Close()
+		}
+	}()
+
+	p.exit = done
+	p.lines = lines
+	p.w = w
+	p.running = true
+
+	return nil
+}
+
+func (p *consoleInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+	p.running = false
+	return nil
+}
+
+func (p *consoleInput) String() string {
+	return "console"
+}
+
+func NewInput() input.Input {
+	return &consoleInput{prompt: "> "}
+}
+
+// NewScriptInput returns a console input running the script of commands
+// read from r as it would a script file, its output written to w. done
+// is called with the exit code once the script is finished.
+func NewScriptInput(r io.Reader, w io.Writer, done func(code int)) input.Input {
+	return &consoleInput{
+		prompt: "> ",
+		in:     r,
+		out:    w,
+		quit:   done,
+	}
+}
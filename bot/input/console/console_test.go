@@ -0,0 +1,164 @@
+package console
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// syncBuffer is written by the input and read by the test
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+// serve answers commands as the bot does: "fail" errors, "unknown"
+// isn't a command and anything else is echoed
+func serve(c input.Conn) {
+	n := c.(*consoleConn)
+
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+
+		text := string(ev.Data)
+
+		switch text {
+		case "unknown":
+			c.Send(&input.Event{Meta: ev.Meta, Data: []byte("unknown command 'unknown'")})
+		case "fail":
+			done := n.Notify(ev)
+			done(errors.New("oops"))
+			c.Send(&input.Event{Meta: ev.Meta, Data: []byte("error executing cmd: oops")})
+		default:
+			done := n.Notify(ev)
+			done(nil)
+			c.Send(&input.Event{Meta: ev.Meta, Data: []byte(text + " ok\n")})
+		}
+	}
+}
+
+// run starts the input returning its output and exit code once it's
+// finished reading
+func run(t *testing.T, script, in string) (string, int) {
+	out := &syncBuffer{}
+	code := make(chan int, 1)
+
+	oldExit, oldIn, oldOut := exit, stdin, stdout
+	exit = func(c int) { code <- c }
+	stdin = strings.NewReader(in)
+	stdout = out
+	defer func() { exit, stdin, stdout = oldExit, oldIn, oldOut }()
+
+	p := NewInput().(*consoleInput)
+	p.script = script
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go serve(c)
+
+	select {
+	case c := <-code:
+		return out.String(), c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out, output so far %q", out.String())
+	}
+	return "", 0
+}
+
+func TestInteractive(t *testing.T) {
+	out, code := run(t, "", "ping\n\ndeploy api\n")
+
+	if code != 0 {
+		t.Fatalf("expected exit 0 got %d", code)
+	}
+
+	if expect := "> ping ok\n> > deploy api ok\n> \n"; out != expect {
+		t.Fatalf("expected %q got %q", expect, out)
+	}
+}
+
+func TestScript(t *testing.T) {
+	testData := []struct {
+		script string
+		code   int
+		output []string
+	}{
+		{"# smoke test\nping\n\ndeploy api\n", 0, []string{"> ping\nping ok\n", "> deploy api\ndeploy api ok\n", "2 commands run, 0 failed\n"}},
+		{"ping\nfail\nunknown\nping\n", 1, []string{"> fail\nerror executing cmd: oops\n", "> unknown\nunknown command 'unknown'\n", "4 commands run, 2 failed\n"}},
+	}
+
+	for _, d := range testData {
+		f, err := ioutil.TempFile("", "console")
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(d.script)
+		f.Close()
+
+		out, code := run(t, f.Name(), "")
+		os.Remove(f.Name())
+
+		if code != d.code {
+			t.Fatalf("%q: expected exit %d got %d", d.script, d.code, code)
+		}
+
+		for _, o := range d.output {
+			if !strings.Contains(out, o) {
+				t.Fatalf("%q: expected %q in output %q", d.script, o, out)
+			}
+		}
+	}
+
+	p := NewInput().(*consoleInput)
+	p.script = "/does/not/exist"
+	if err := p.Start(); err == nil {
+		t.Fatal("expected an error for a missing script")
+	}
+}
+
+func TestProgress(t *testing.T) {
+	out := &syncBuffer{}
+
+	update, interval := newConn(nil, nil, out).Progress(input.Event{})
+	if interval != 0 {
+		t.Fatalf("unexpected interval %v", interval)
+	}
+
+	update([]string{"building api", "pushing image"})
+	update([]string{"done"})
+
+	if expect := "building api\npushing image\ndone\n"; out.String() != expect {
+		t.Fatalf("expected %q got %q", expect, out.String())
+	}
+}
@@ -0,0 +1,168 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// apiURL is the REST endpoint, overridden in tests
+var apiURL = "https://discord.com/api/v10"
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot,omitempty"`
+}
+
+type message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	// empty for direct messages
+	GuildID  string `json:"guild_id,omitempty"`
+	Author   user   `json:"author"`
+	Content  string `json:"content"`
+	Mentions []user `json:"mentions,omitempty"`
+}
+
+type messageReference struct {
+	MessageID string `json:"message_id"`
+	// post even if the referenced message was deleted
+	FailIfNotExists bool `json:"fail_if_not_exists"`
+}
+
+type allowedMentions struct {
+	Parse []string `json:"parse"`
+	Users []string `json:"users,omitempty"`
+}
+
+type createMessage struct {
+	Content          string            `json:"content,omitempty"`
+	MessageReference *messageReference `json:"message_reference,omitempty"`
+	AllowedMentions  *allowedMentions  `json:"allowed_mentions,omitempty"`
+}
+
+// apiError is returned when the REST api refuses a request
+type apiError struct {
+	Status  int
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	// set when rate limited
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("discord error %d: %s", e.Status, e.Message)
+}
+
+// client calls the REST api
+type client struct {
+	token string
+	http  *http.Client
+}
+
+func newClient(token string) *client {
+	return &client{
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) do(method, path, contentType string, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, apiURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bot "+c.token)
+	req.Header.Set("User-Agent", "DiscordBot (https://github.com/micro/micro, 1.0)")
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		e := &apiError{Status: rsp.StatusCode}
+		json.NewDecoder(rsp.Body).Decode(e)
+		if len(e.Message) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		if rsp.StatusCode == http.StatusTooManyRequests {
+			if s, err := strconv.ParseFloat(rsp.Header.Get("Retry-After"), 64); err == nil {
+				e.RetryAfter = time.Duration(s * float64(time.Second))
+			}
+		}
+		return e
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
+
+func (c *client) me() (*user, error) {
+	var u user
+	if err := c.do("GET", "/users/@me", "", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// gateway returns the url of the gateway to connect to
+func (c *client) gateway() (string, error) {
+	var rsp struct {
+		URL string `json:"url"`
+	}
+	if err := c.do("GET", "/gateway/bot", "", nil, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.URL, nil
+}
+
+func (c *client) createMessage(channel string, m *createMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return c.do("POST", "/channels/"+channel+"/messages", "application/json", bytes.NewReader(b), nil)
+}
+
+// upload posts the message with data attached as a file
+func (c *client) upload(channel string, m *createMessage, name string, data []byte) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := w.WriteField("payload_json", string(b)); err != nil {
+		return err
+	}
+
+	f, err := w.CreateFormFile("files[0]", name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.do("POST", "/channels/"+channel+"/messages", w.FormDataContentType(), &body, nil)
+}
@@ -0,0 +1,235 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaChannel on
+// outgoing events falling back to Event.To.
+const (
+	// MetaGuild is the ID of the guild the message was sent in, empty for
+	// direct messages
+	MetaGuild = "discord_guild"
+	// MetaChannel is the ID of the channel the message was sent in
+	MetaChannel = "discord_channel"
+	// MetaUser is the ID of the user who sent the message
+	MetaUser = "discord_user"
+	// MetaMessageID is the ID of the message
+	MetaMessageID = "discord_message_id"
+)
+
+var (
+	// max length of a message
+	maxMessageSize = 2000
+	// output needing more messages than this is attached as a file
+	maxMessages = 3
+	// how many times a rate limited message is sent
+	sendAttempts = 3
+)
+
+// Satisfies the input.Conn interface
+type discordConn struct {
+	client *client
+	me     *user
+	prefix string
+	events <-chan *message
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, me *user, prefix string, events <-chan *message, exit chan bool) *discordConn {
+	return &discordConn{
+		client: c,
+		me:     me,
+		prefix: prefix,
+		events: events,
+		exit:   exit,
+		closed: make(chan bool),
+	}
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Guild messages must mention the bot or start with the prefix, direct
+// messages are always for the bot.
+func (c *discordConn) addressed(m *message) (string, bool) {
+	if m.Author.Bot || m.Author.ID == c.me.ID {
+		return "", false
+	}
+
+	text := strings.TrimSpace(m.Content)
+
+	// mentions use the nickname form if the bot has one in the guild
+	for _, mention := range []string{"<@" + c.me.ID + ">", "<@!" + c.me.ID + ">"} {
+		if strings.HasPrefix(text, mention) {
+			return strings.TrimLeft(text[len(mention):], " ,:"), true
+		}
+	}
+
+	if len(c.prefix) > 0 && strings.HasPrefix(text, c.prefix) {
+		return strings.TrimSpace(text[len(c.prefix):]), true
+	}
+
+	if len(m.GuildID) == 0 {
+		return text, true
+	}
+
+	return "", false
+}
+
+func (c *discordConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *discordConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.exit:
+			return errors.New("discord input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case m := <-c.events:
+			text, ok := c.addressed(m)
+			if !ok || len(text) == 0 {
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			event.From = m.ChannelID + ":" + m.Author.ID
+			event.To = c.me.ID
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = m
+			event.Meta[MetaGuild] = m.GuildID
+			event.Meta[MetaChannel] = m.ChannelID
+			event.Meta[MetaUser] = m.Author.ID
+			event.Meta[MetaMessageID] = m.ID
+
+			return nil
+		}
+	}
+}
+
+// send posts the message retrying while it's rate limited
+func (c *discordConn) send(post func() error) error {
+	for i := 1; ; i++ {
+		err := post()
+		aerr, ok := err.(*apiError)
+		if !ok || aerr.RetryAfter == 0 || i >= sendAttempts {
+			return err
+		}
+
+		select {
+		case <-c.exit:
+			return err
+		case <-time.After(aerr.RetryAfter):
+		}
+	}
+}
+
+// MessageLimit is the most characters sent in a message
+func (c *discordConn) MessageLimit() int {
+	return maxMessageSize
+}
+
+func (c *discordConn) Send(event *input.Event) error {
+	var channel, user string
+
+	// To is channel:user as set by Recv
+	parts := strings.Split(event.To, ":")
+	channel = parts[0]
+	if len(parts) == 2 {
+		user = parts[1]
+	}
+
+	if ch, ok := event.Meta[MetaChannel].(string); ok && len(ch) > 0 {
+		channel = ch
+		if u, ok := event.Meta[MetaUser].(string); ok {
+			user = u
+		}
+	}
+
+	if len(channel) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	var ref *messageReference
+	var guild bool
+
+	// answer the message we're replying to
+	if reply, ok := event.Meta["reply"].(*message); ok && reply.ChannelID == channel {
+		ref = &messageReference{MessageID: reply.ID}
+		guild = len(reply.GuildID) > 0
+	}
+
+	// mention the invoker in guilds, never anyone the output names
+	mentions := &allowedMentions{Parse: []string{}}
+
+	var prefix string
+	if guild && len(user) > 0 {
+		prefix = fmt.Sprintf("<@%s>: ", user)
+		mentions.Users = []string{user}
+	}
+
+	data := string(event.Data)
+	if len(data) == 0 {
+		data = "(no output)"
+	}
+
+	chunks := input.SplitMessage(data, maxMessageSize-utf8.RuneCountInString(prefix))
+
+	if len(chunks) > maxMessages {
+		m := &createMessage{
+			Content:          prefix + i18n.Text("discord", channel, i18n.OutputAttached, i18n.Params{"size": len(data)}),
+			MessageReference: ref,
+			AllowedMentions:  mentions,
+		}
+
+		err := c.send(func() error { return c.client.upload(channel, m, "output.txt", event.Data) })
+		if err == nil {
+			return nil
+		}
+
+		// fall back to sending messages
+		log.Logf("[discord] error attaching output in %s: %v", channel, err)
+	}
+
+	for i, text := range chunks {
+		m := &createMessage{
+			Content:         text,
+			AllowedMentions: mentions,
+		}
+		if i == 0 {
+			m.Content = prefix + text
+			m.MessageReference = ref
+		}
+
+		if err := c.send(func() error { return c.client.createMessage(channel, m) }); err != nil {
+			log.Logf("[discord] error sending message to %s: %v", channel, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package discord is a discord input for the bot. Messages are received
+// over the gateway and replies posted with the REST api.
+package discord
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// how long Stop waits for the gateway to disconnect
+var stopTimeout = 5 * time.Second
+
+type discordInput struct {
+	token  string
+	prefix string
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+	me      *user
+	gateway *gateway
+}
+
+func init() {
+	input.Register("discord", NewInput())
+}
+
+func (p *discordInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "discord_token",
+			Usage: "Discord bot token",
+		},
+		cli.StringFlag{
+			Name:  "discord_prefix",
+			Usage: "Prefix which triggers commands in guild channels without a mention",
+			Value: "!",
+		},
+	}
+}
+
+func (p *discordInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	token := cfg.String("discord_token", "")
+	if len(token) == 0 {
+		return errors.New("missing discord token")
+	}
+
+	p.token = token
+	p.prefix = cfg.String("discord_prefix", "!")
+
+	return nil
+}
+
+func (p *discordInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.me, p.prefix, p.gateway.events, p.exit), nil
+}
+
+func (p *discordInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.token) == 0 {
+		return errors.New("missing discord token")
+	}
+
+	c := newClient(p.token)
+
+	// fail fast on a bad token
+	me, err := c.me()
+	if err != nil {
+		return err
+	}
+
+	url, err := c.gateway()
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+
+	g := newGateway(p.token, url, exit)
+	go g.run()
+
+	p.client = c
+	p.me = me
+	p.exit = exit
+	p.gateway = g
+	p.running = true
+
+	return nil
+}
+
+func (p *discordInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	// disconnect cleanly so discord shows the bot offline
+	select {
+	case <-p.gateway.done:
+	case <-time.After(stopTimeout):
+		log.Logf("[discord] timed out waiting for the gateway to disconnect")
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *discordInput) String() string {
+	return "discord"
+}
+
+func NewInput() input.Input {
+	return &discordInput{}
+}
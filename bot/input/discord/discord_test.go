@@ -0,0 +1,324 @@
+package discord
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/micro/bot/input"
+)
+
+var testBot = &user{ID: "100", Username: "micro", Bot: true}
+
+// post is a message posted to the REST api
+type post struct {
+	channel string
+	message createMessage
+	file    string
+}
+
+// testServer is a fake discord serving the REST api and gateway. Set
+// apiURL to its URL to use it.
+type testServer struct {
+	*httptest.Server
+
+	upgrader websocket.Upgrader
+	posts    chan post
+	// identify and resume payloads received
+	sessions chan payload
+
+	sync.Mutex
+	ws  *websocket.Conn
+	seq int64
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		posts:    make(chan post, 10),
+		sessions: make(chan payload, 10),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "401: Unauthorized", "code": 0}`))
+			return
+		}
+		json.NewEncoder(w).Encode(testBot)
+	})
+	mux.HandleFunc("/gateway/bot", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"url": s.wsURL()})
+	})
+	mux.HandleFunc("/channels/", func(w http.ResponseWriter, r *http.Request) {
+		p := post{channel: strings.Split(strings.TrimPrefix(r.URL.Path, "/channels/"), "/")[0]}
+
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			json.Unmarshal([]byte(r.FormValue("payload_json")), &p.message)
+			if f, _, err := r.FormFile("files[0]"); err == nil {
+				b, _ := ioutil.ReadAll(f)
+				p.file = string(b)
+			}
+		} else {
+			json.NewDecoder(r.Body).Decode(&p.message)
+		}
+
+		s.posts <- p
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/ws/", s.serveWS)
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func (s *testServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + "/ws"
+}
+
+func (s *testServer) serveWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	s.Lock()
+	s.ws = ws
+	s.Unlock()
+
+	s.write(opHello, "", map[string]int{"heartbeat_interval": 45000})
+
+	for {
+		var p payload
+		if err := ws.ReadJSON(&p); err != nil {
+			return
+		}
+
+		switch p.Op {
+		case opIdentify:
+			s.sessions <- p
+			s.write(opDispatch, "READY", map[string]interface{}{
+				"session_id":         "session",
+				"resume_gateway_url": s.wsURL(),
+				"user":               testBot,
+			})
+		case opResume:
+			s.sessions <- p
+			s.write(opDispatch, "RESUMED", nil)
+		case opHeartbeat:
+			s.write(opHeartbeatAck, "", nil)
+		}
+	}
+}
+
+// write sends a payload over the gateway, dispatches are sequenced
+func (s *testServer) write(op int, t string, d interface{}) error {
+	s.Lock()
+	defer s.Unlock()
+
+	b, _ := json.Marshal(d)
+	p := payload{Op: op, T: t, D: b}
+	if op == opDispatch {
+		s.seq++
+		seq := s.seq
+		p.S = &seq
+	}
+
+	return s.ws.WriteJSON(p)
+}
+
+// startServer points the client at a fake discord
+func startServer() (*testServer, func()) {
+	s := newTestServer()
+
+	url := apiURL
+	apiURL = s.URL
+
+	return s, func() {
+		apiURL = url
+		s.Close()
+	}
+}
+
+func TestAddressed(t *testing.T) {
+	conn := newConn(nil, testBot, "!", nil, nil)
+
+	john := user{ID: "1", Username: "john"}
+
+	testData := []struct {
+		msg    message
+		expect string
+		ok     bool
+	}{
+		{message{Author: john, Content: "ping"}, "ping", true},
+		{message{Author: john, Content: "!ping"}, "ping", true},
+		{message{Author: john, GuildID: "G", Content: "ping"}, "", false},
+		{message{Author: john, GuildID: "G", Content: "!ping now"}, "ping now", true},
+		{message{Author: john, GuildID: "G", Content: "! ping"}, "ping", true},
+		{message{Author: john, GuildID: "G", Content: "<@100> ping"}, "ping", true},
+		{message{Author: john, GuildID: "G", Content: "<@!100>: ping"}, "ping", true},
+		{message{Author: john, GuildID: "G", Content: "<@101> ping"}, "", false},
+		{message{Author: user{ID: "2", Bot: true}, Content: "ping"}, "", false},
+		{message{Author: *testBot, Content: "ping"}, "", false},
+	}
+
+	for _, d := range testData {
+		text, ok := conn.addressed(&d.msg)
+		if ok != d.ok || text != d.expect {
+			t.Fatalf("%q: expected %q %v got %q %v", d.msg.Content, d.expect, d.ok, text, ok)
+		}
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	srv, stop := startServer()
+	defer stop()
+
+	backoff := reconnectBackoff
+	reconnectBackoff = 10 * time.Millisecond
+	defer func() { reconnectBackoff = backoff }()
+
+	io := NewInput().(*discordInput)
+	io.token = "token"
+	io.prefix = "!"
+
+	if err := io.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer io.Stop()
+
+	c, err := io.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	session := func(op int) payload {
+		select {
+		case p := <-srv.sessions:
+			if p.Op != op {
+				t.Fatalf("expected op %d got %d", op, p.Op)
+			}
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for op %d", op)
+		}
+		return payload{}
+	}
+
+	session(opIdentify)
+
+	recv := func(m message) input.Event {
+		if err := srv.write(opDispatch, "MESSAGE_CREATE", m); err != nil {
+			t.Fatal(err)
+		}
+
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+
+	john := user{ID: "1", Username: "john"}
+
+	ev := recv(message{ID: "m1", ChannelID: "C", GuildID: "G", Author: john, Content: "<@100> ping"})
+
+	if string(ev.Data) != "ping" || ev.From != "C:1" || ev.Meta[MetaGuild] != "G" || ev.Meta[MetaMessageID] != "m1" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	// replies mention the invoker and only them
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("pong @everyone")}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := <-srv.posts
+	if p.channel != "C" || p.message.Content != "<@1>: pong @everyone" || p.message.MessageReference.MessageID != "m1" {
+		t.Fatalf("unexpected post %+v", p)
+	}
+	if len(p.message.AllowedMentions.Parse) > 0 || strings.Join(p.message.AllowedMentions.Users, ",") != "1" {
+		t.Fatalf("unexpected mentions %+v", p.message.AllowedMentions)
+	}
+
+	// the session is resumed after a reconnect
+	srv.write(opReconnect, "", nil)
+
+	resume := session(opResume)
+
+	var d struct {
+		SessionID string `json:"session_id"`
+		Seq       int64  `json:"seq"`
+	}
+	json.Unmarshal(resume.D, &d)
+
+	if d.SessionID != "session" || d.Seq != 2 {
+		t.Fatalf("unexpected resume %+v", d)
+	}
+
+	// dms need no prefix or mention
+	ev = recv(message{ID: "m2", ChannelID: "D", Author: john, Content: "ping"})
+
+	// long output is split
+	maxSize := maxMessageSize
+	maxMessageSize = 10
+	defer func() { maxMessageSize = maxSize }()
+
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("0123456789abcdefghij")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{"0123456789", "abcdefghij"} {
+		p := <-srv.posts
+		if p.channel != "D" || p.message.Content != expect {
+			t.Fatalf("expected %q got %+v", expect, p)
+		}
+	}
+
+	// and attached once it needs too many messages
+	data := strings.Repeat("x", 100)
+
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte(data)}); err != nil {
+		t.Fatal(err)
+	}
+
+	p = <-srv.posts
+	if p.file != data || p.message.Content != "output attached (100 bytes)" {
+		t.Fatalf("unexpected post %+v", p)
+	}
+
+	// stop disconnects
+	if err := io.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-io.gateway.done:
+	default:
+		t.Fatal("expected the gateway to be disconnected")
+	}
+
+	if err := c.Recv(&ev); err == nil {
+		t.Fatal("expected an error once stopped")
+	}
+}
+
+func TestStartInvalidToken(t *testing.T) {
+	_, stop := startServer()
+	defer stop()
+
+	io := NewInput().(*discordInput)
+	io.token = "wrong"
+
+	if err := io.Start(); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Fatalf("expected unauthorized got %v", err)
+	}
+}
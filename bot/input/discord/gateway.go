@@ -0,0 +1,341 @@
+package discord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-log"
+)
+
+// gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// gateway intents the bot needs to see messages
+const (
+	intentGuildMessages  = 1 << 9
+	intentDirectMessages = 1 << 12
+	intentMessageContent = 1 << 15
+)
+
+var (
+	// how long to wait before reconnecting, doubled on each failure
+	reconnectBackoff = time.Second
+	// how long to wait for the gateway to say hello
+	helloTimeout = 30 * time.Second
+)
+
+type payload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d,omitempty"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+type outgoing struct {
+	Op int         `json:"op"`
+	D  interface{} `json:"d"`
+}
+
+// gateway maintains the websocket connection events are received on,
+// resuming the session after a disconnect so no events are missed
+type gateway struct {
+	token  string
+	url    string
+	dialer *websocket.Dialer
+
+	events chan *message
+	exit   chan bool
+	done   chan bool
+
+	// session state, only used by run
+	sessionID string
+	resumeURL string
+	// last sequence number received, also read by heartbeat
+	seq int64
+
+	sync.Mutex
+	ws *websocket.Conn
+}
+
+func newGateway(token, url string, exit chan bool) *gateway {
+	return &gateway{
+		token:  token,
+		url:    url,
+		dialer: websocket.DefaultDialer,
+		events: make(chan *message),
+		exit:   exit,
+		done:   make(chan bool),
+	}
+}
+
+// gatewayURL adds the version and encoding to the gateway url
+func gatewayURL(u string) string {
+	if strings.Contains(u, "?") {
+		return u
+	}
+	return strings.TrimSuffix(u, "/") + "/?v=10&encoding=json"
+}
+
+// run connects and reconnects with backoff until exit is closed
+func (g *gateway) run() {
+	defer close(g.done)
+
+	backoff := reconnectBackoff
+
+	for {
+		start := time.Now()
+		err := g.connect()
+
+		select {
+		case <-g.exit:
+			return
+		default:
+		}
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) > time.Minute {
+			backoff = reconnectBackoff
+		}
+
+		log.Logf("[discord] gateway connection lost: %v, reconnecting in %v", err, backoff)
+
+		select {
+		case <-g.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (g *gateway) send(op int, d interface{}) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.ws == nil {
+		return errors.New("not connected")
+	}
+
+	return g.ws.WriteJSON(outgoing{op, d})
+}
+
+// close closes the current connection, connect then returns
+func (g *gateway) close() {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.ws == nil {
+		return
+	}
+
+	g.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	g.ws.Close()
+}
+
+// connect runs a single gateway session, resuming the last one if any
+func (g *gateway) connect() error {
+	url := g.url
+	if len(g.sessionID) > 0 && len(g.resumeURL) > 0 {
+		url = g.resumeURL
+	}
+
+	ws, _, err := g.dialer.Dial(gatewayURL(url), nil)
+	if err != nil {
+		return err
+	}
+
+	g.Lock()
+	g.ws = ws
+	g.Unlock()
+
+	defer func() {
+		g.Lock()
+		g.ws.Close()
+		g.ws = nil
+		g.Unlock()
+	}()
+
+	// close the socket to unblock reads once exiting
+	closed := make(chan bool)
+	defer close(closed)
+
+	go func() {
+		select {
+		case <-g.exit:
+			g.close()
+		case <-closed:
+		}
+	}()
+
+	ws.SetReadDeadline(time.Now().Add(helloTimeout))
+
+	var hello struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	p, err := g.read(ws)
+	if err != nil {
+		return err
+	}
+	if p.Op != opHello {
+		return fmt.Errorf("expected hello got op %d", p.Op)
+	}
+	if err := json.Unmarshal(p.D, &hello); err != nil {
+		return err
+	}
+
+	ws.SetReadDeadline(time.Time{})
+
+	if len(g.sessionID) > 0 {
+		err = g.send(opResume, map[string]interface{}{
+			"token":      g.token,
+			"session_id": g.sessionID,
+			"seq":        atomic.LoadInt64(&g.seq),
+		})
+	} else {
+		err = g.send(opIdentify, map[string]interface{}{
+			"token":   g.token,
+			"intents": intentGuildMessages | intentDirectMessages | intentMessageContent,
+			"properties": map[string]string{
+				"os":      "linux",
+				"browser": "micro",
+				"device":  "micro",
+			},
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	acks := make(chan bool, 1)
+	go g.heartbeat(time.Duration(hello.HeartbeatInterval)*time.Millisecond, acks, closed)
+
+	for {
+		p, err := g.read(ws)
+		if err != nil {
+			return err
+		}
+
+		if p.S != nil {
+			atomic.StoreInt64(&g.seq, *p.S)
+		}
+
+		switch p.Op {
+		case opDispatch:
+			g.dispatch(p)
+		case opHeartbeat:
+			g.send(opHeartbeat, g.lastSeq())
+		case opHeartbeatAck:
+			select {
+			case acks <- true:
+			default:
+			}
+		case opReconnect:
+			return errors.New("reconnect requested")
+		case opInvalidSession:
+			var resumable bool
+			json.Unmarshal(p.D, &resumable)
+			if !resumable {
+				g.sessionID = ""
+				g.resumeURL = ""
+				atomic.StoreInt64(&g.seq, 0)
+			}
+			return errors.New("invalid session")
+		}
+	}
+}
+
+func (g *gateway) read(ws *websocket.Conn) (*payload, error) {
+	var p payload
+	if err := ws.ReadJSON(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// heartbeat beats on interval, closing the connection if a beat isn't
+// acknowledged before the next so it's resumed
+func (g *gateway) heartbeat(interval time.Duration, acks chan bool, closed chan bool) {
+	if interval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	acked := true
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-acks:
+			acked = true
+		case <-t.C:
+			if !acked {
+				log.Logf("[discord] heartbeat not acknowledged, reconnecting")
+				g.close()
+				return
+			}
+			acked = false
+			g.send(opHeartbeat, g.lastSeq())
+		}
+	}
+}
+
+// lastSeq returns the sequence number heartbeats carry, null if none
+// has been received
+func (g *gateway) lastSeq() interface{} {
+	if seq := atomic.LoadInt64(&g.seq); seq > 0 {
+		return seq
+	}
+	return nil
+}
+
+func (g *gateway) dispatch(p *payload) {
+	switch p.T {
+	case "READY":
+		var ready struct {
+			SessionID        string `json:"session_id"`
+			ResumeGatewayURL string `json:"resume_gateway_url"`
+			User             user   `json:"user"`
+		}
+		if err := json.Unmarshal(p.D, &ready); err != nil {
+			log.Logf("[discord] error decoding ready: %v", err)
+			return
+		}
+		g.sessionID = ready.SessionID
+		g.resumeURL = ready.ResumeGatewayURL
+		log.Logf("[discord] connected as %s", ready.User.Username)
+	case "RESUMED":
+		log.Logf("[discord] resumed session")
+	case "MESSAGE_CREATE":
+		var m message
+		if err := json.Unmarshal(p.D, &m); err != nil {
+			log.Logf("[discord] error decoding message: %v", err)
+			return
+		}
+
+		select {
+		case <-g.exit:
+		case g.events <- &m:
+		}
+	}
+}
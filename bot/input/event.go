@@ -0,0 +1,85 @@
+package input
+
+// The types of events other than text. Their payload describes what
+// happened rather than the Data of the event, which is left empty.
+const (
+	// a user joined a channel, the payload is a *Join
+	JoinEvent EventType = "join"
+	// a user left a channel, the payload is a *Leave
+	LeaveEvent EventType = "leave"
+	// a user reacted to a message, the payload is a *Reaction
+	ReactionEvent EventType = "reaction"
+	// a user edited a message, the payload is an *Edit
+	EditEvent EventType = "edit"
+)
+
+// MetaPayload is the key of the payload of an event other than text
+const MetaPayload = "payload"
+
+// Join is the payload of a JoinEvent
+type Join struct {
+	Channel string
+	User    string
+}
+
+// Leave is the payload of a LeaveEvent
+type Leave struct {
+	Channel string
+	User    string
+}
+
+// Reaction is the payload of a ReactionEvent
+type Reaction struct {
+	Channel string
+	User    string
+	// id of the message reacted to
+	Message string
+	// name of the reaction e.g. thumbsup
+	Emoji string
+	// the reaction was taken away
+	Removed bool
+}
+
+// Edit is the payload of an EditEvent
+type Edit struct {
+	Channel string
+	User    string
+	// id of the message edited
+	Message string
+	Text    string
+}
+
+// TypeOf returns the type of the event, text if it's not set so events
+// built before there were other types are still text
+func TypeOf(ev *Event) EventType {
+	if len(ev.Type) == 0 {
+		return TextEvent
+	}
+	return ev.Type
+}
+
+// NewEvent returns an event of the type with the payload, from the
+// user in the channel
+func NewEvent(t EventType, channel, user string, payload interface{}) Event {
+	from := user
+	if len(channel) > 0 {
+		from = channel + ":" + user
+	}
+
+	return Event{
+		Type: t,
+		From: from,
+		Meta: map[string]interface{}{
+			MetaPayload: payload,
+		},
+	}
+}
+
+// Payload returns the payload of an event other than text, nil if it
+// has none
+func Payload(ev *Event) interface{} {
+	if ev.Meta == nil {
+		return nil
+	}
+	return ev.Meta[MetaPayload]
+}
@@ -0,0 +1,30 @@
+package input
+
+import (
+	"testing"
+)
+
+func TestEvent(t *testing.T) {
+	// events built before there were other types are text
+	if typ := TypeOf(&Event{Data: []byte("ping")}); typ != TextEvent {
+		t.Fatalf("expected text got %s", typ)
+	}
+	if p := Payload(&Event{Type: TextEvent}); p != nil {
+		t.Fatalf("expected no payload got %v", p)
+	}
+
+	join := &Join{Channel: "C0OPS", User: "U1"}
+	ev := NewEvent(JoinEvent, "C0OPS", "U1", join)
+
+	if TypeOf(&ev) != JoinEvent || ev.From != "C0OPS:U1" || len(ev.Data) > 0 {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if p, ok := Payload(&ev).(*Join); !ok || p != join {
+		t.Fatalf("expected the join got %v", Payload(&ev))
+	}
+
+	// direct messages are from the user alone
+	if ev := NewEvent(ReactionEvent, "", "U1", &Reaction{User: "U1", Emoji: "tada"}); ev.From != "U1" {
+		t.Fatalf("expected from U1 got %s", ev.From)
+	}
+}
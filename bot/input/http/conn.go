@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// MetaRequestID is the meta key holding the ID of the request an event
+// was received in
+const MetaRequestID = "http_request_id"
+
+// used to post async replies
+var replyClient = &http.Client{Timeout: 30 * time.Second}
+
+// request is a command posted to the webhook waiting for its reply
+type request struct {
+	id       string
+	from     string
+	text     string
+	replyURL string
+
+	sync.Mutex
+	// the reply when answered in the response
+	done chan []byte
+	// set once the response has been sent without the reply
+	async   bool
+	replied bool
+}
+
+// reply is the JSON body of responses and async replies
+type reply struct {
+	ID   string `json:"id"`
+	To   string `json:"to,omitempty"`
+	Text string `json:"text"`
+}
+
+// answer delivers the reply in the response if it's still being waited
+// for, otherwise posts it to the reply url
+func (r *request) answer(data []byte) error {
+	r.Lock()
+	if r.replied {
+		r.Unlock()
+		return fmt.Errorf("request %s already answered", r.id)
+	}
+	r.replied = true
+	async := r.async
+	r.Unlock()
+
+	if !async {
+		r.done <- data
+		return nil
+	}
+
+	b, err := json.Marshal(reply{ID: r.id, To: r.from, Text: string(data)})
+	if err != nil {
+		return err
+	}
+
+	rsp, err := replyClient.Post(r.replyURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		return fmt.Errorf("reply url %s returned %s", r.replyURL, rsp.Status)
+	}
+
+	return nil
+}
+
+// detach switches the request to replying asynchronously, returning
+// false if it's already been answered
+func (r *request) detach() bool {
+	r.Lock()
+	defer r.Unlock()
+	if r.replied {
+		return false
+	}
+	r.async = true
+	return true
+}
+
+// Satisfies the input.Conn interface
+type httpConn struct {
+	requests <-chan *request
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(requests <-chan *request, exit chan bool) *httpConn {
+	return &httpConn{
+		requests: requests,
+		exit:     exit,
+		closed:   make(chan bool),
+	}
+}
+
+func (c *httpConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *httpConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-c.exit:
+		return errors.New("http input stopped")
+	case <-c.closed:
+		return errors.New("connection closed")
+	case r := <-c.requests:
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = r.from
+		event.To = "bot"
+		event.Type = input.TextEvent
+		event.Data = []byte(r.text)
+		event.Meta["reply"] = r
+		event.Meta[MetaRequestID] = r.id
+
+		return nil
+	}
+}
+
+func (c *httpConn) Send(event *input.Event) error {
+	r, ok := event.Meta["reply"].(*request)
+	if !ok {
+		// there's nowhere to send output not answering a request
+		return errors.New("http input can only reply to requests")
+	}
+
+	if err := r.answer(event.Data); err != nil {
+		log.Logf("[http] error replying to request %s: %v", r.id, err)
+		return err
+	}
+
+	return nil
+}
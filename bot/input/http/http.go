@@ -0,0 +1,280 @@
+// Package http is a generic webhook input for the bot. Commands are
+// posted as JSON and answered in the response or, when they take a
+// while, posted to a reply url.
+package http
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/tokenize"
+)
+
+// SecretHeader is the header requests authenticate with
+const SecretHeader = "X-Micro-Bot-Secret"
+
+// the largest body accepted
+var maxBodySize int64 = 1 << 20
+
+type httpInput struct {
+	address    string
+	secret     string
+	asyncAfter time.Duration
+
+	sync.Mutex
+	running  bool
+	exit     chan bool
+	requests chan *request
+	server   *http.Server
+	// where the webhook is listening, useful when the port is 0
+	addr net.Addr
+}
+
+// command is the JSON body of a request
+type command struct {
+	From     string `json:"from"`
+	Text     string `json:"text"`
+	ReplyURL string `json:"reply_url"`
+}
+
+func init() {
+	input.Register("http", NewInput())
+}
+
+func (p *httpInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "http_address",
+			Usage: "Address the webhook listens on",
+			Value: ":8090",
+		},
+		cli.StringFlag{
+			Name:  "http_secret",
+			Usage: "Shared secret requests must send in the " + SecretHeader + " header",
+		},
+		cli.DurationFlag{
+			Name:  "http_async_after",
+			Usage: "How long to wait for output before replying to the reply_url instead",
+			Value: 5 * time.Second,
+		},
+	}
+}
+
+func (p *httpInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	secret := cfg.String("http_secret", "")
+	// what the secret was read from before MICRO_HTTP_SECRET
+	if len(secret) == 0 {
+		secret = os.Getenv("MICRO_BOT_HTTP_SECRET")
+	}
+	if len(secret) == 0 {
+		return errors.New("missing http secret")
+	}
+
+	p.address = cfg.String("http_address", ":8090")
+	p.secret = secret
+	p.asyncAfter = cfg.Duration("http_async_after", 5*time.Second)
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err string) {
+	writeJSON(w, status, map[string]string{"error": err})
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// decode returns the command in the request body
+func decode(r *http.Request, w http.ResponseWriter) (*command, error) {
+	var c command
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&c); err != nil {
+		return nil, errors.New("invalid json body: " + err.Error())
+	}
+
+	// the bot splits the text the same way, an empty command would
+	// never be answered
+	args, err := tokenize.Split(c.Text)
+	if err != nil {
+		return nil, errors.New("invalid text: " + err.Error())
+	}
+
+	if len(args) == 0 {
+		return nil, errors.New("missing text")
+	}
+
+	if len(c.ReplyURL) > 0 {
+		u, err := url.Parse(c.ReplyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, errors.New("invalid reply_url, expected an http or https url")
+		}
+	}
+
+	if len(c.From) == 0 {
+		c.From = "http"
+	}
+
+	return &c, nil
+}
+
+// handler receives commands, answering in the response unless they run
+// longer than asyncAfter and a reply url was given
+func handler(secret string, asyncAfter time.Duration, requests chan *request, exit chan bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		got := r.Header.Get(SecretHeader)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		c, err := decode(r, w)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		req := &request{
+			id:       newID(),
+			from:     c.From,
+			text:     c.Text,
+			replyURL: c.ReplyURL,
+			done:     make(chan []byte, 1),
+		}
+
+		select {
+		case <-exit:
+			writeError(w, http.StatusServiceUnavailable, "stopped")
+			return
+		case <-r.Context().Done():
+			return
+		case requests <- req:
+		}
+
+		var async <-chan time.Time
+		if len(req.replyURL) > 0 {
+			async = time.After(asyncAfter)
+		}
+
+		select {
+		case data := <-req.done:
+			writeJSON(w, http.StatusOK, reply{ID: req.id, Text: string(data)})
+		case <-async:
+			if !req.detach() {
+				// answered just now
+				writeJSON(w, http.StatusOK, reply{ID: req.id, Text: string(<-req.done)})
+				return
+			}
+			writeJSON(w, http.StatusAccepted, map[string]string{"id": req.id, "status": "accepted"})
+		case <-exit:
+			writeError(w, http.StatusServiceUnavailable, "stopped")
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func (p *httpInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.requests, p.exit), nil
+}
+
+func (p *httpInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.secret) == 0 {
+		return errors.New("missing http secret")
+	}
+
+	l, err := net.Listen("tcp", p.address)
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+	requests := make(chan *request)
+
+	p.server = &http.Server{Handler: handler(p.secret, p.asyncAfter, requests, exit)}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Logf("[http] webhook server error: %v", err)
+		}
+	}(p.server)
+
+	log.Logf("[http] listening for commands on %s", l.Addr())
+
+	p.exit = exit
+	p.requests = requests
+	p.addr = l.Addr()
+	p.running = true
+
+	return nil
+}
+
+func (p *httpInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	if p.server != nil {
+		p.server.Close()
+		p.server = nil
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *httpInput) String() string {
+	return "http"
+}
+
+func NewInput() input.Input {
+	return &httpInput{}
+}
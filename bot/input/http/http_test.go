@@ -0,0 +1,210 @@
+package http
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/input"
+)
+
+func startInput(t *testing.T) *httpInput {
+	p := NewInput().(*httpInput)
+	p.address = "127.0.0.1:0"
+	p.secret = "secret"
+	p.asyncAfter = 50 * time.Millisecond
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func post(t *testing.T, p *httpInput, secret, body string) (int, map[string]string) {
+	req, _ := http.NewRequest("POST", "http://"+p.addr.String(), strings.NewReader(body))
+	req.Header.Set(SecretHeader, secret)
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if ct := rsp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a json response got %s", ct)
+	}
+
+	var v map[string]string
+	json.NewDecoder(rsp.Body).Decode(&v)
+	return rsp.StatusCode, v
+}
+
+// answer replies to each command with its text after the delay
+func answer(c input.Conn, delay time.Duration) {
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+
+		go func(ev input.Event) {
+			time.Sleep(delay)
+			c.Send(&input.Event{To: ev.From, Meta: ev.Meta, Type: input.TextEvent, Data: []byte(ev.From + " ran " + string(ev.Data))})
+		}(ev)
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	p := startInput(t)
+	defer p.Stop()
+
+	testData := []struct {
+		secret string
+		body   string
+		status int
+		err    string
+	}{
+		{"wrong", `{"text":"ping"}`, http.StatusUnauthorized, "unauthorized"},
+		{"", `{"text":"ping"}`, http.StatusUnauthorized, "unauthorized"},
+		{"secret", `{"text":`, http.StatusBadRequest, "invalid json body"},
+		{"secret", `{"from":"ci"}`, http.StatusBadRequest, "missing text"},
+		{"secret", `{"text":"  "}`, http.StatusBadRequest, "missing text"},
+		{"secret", `{"text":"echo \"hi"}`, http.StatusBadRequest, "unbalanced double quote"},
+		{"secret", `{"text":"ping","reply_url":"ftp://example.com"}`, http.StatusBadRequest, "invalid reply_url"},
+	}
+
+	for _, d := range testData {
+		status, rsp := post(t, p, d.secret, d.body)
+		if status != d.status || !strings.Contains(rsp["error"], d.err) {
+			t.Fatalf("%s: expected %d %q got %d %v", d.body, d.status, d.err, status, rsp)
+		}
+	}
+
+	rsp, err := http.Get("http://" + p.addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected got %d", rsp.StatusCode)
+	}
+}
+
+func TestSync(t *testing.T) {
+	p := startInput(t)
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c, 0)
+
+	testData := []struct {
+		body   string
+		expect string
+	}{
+		{`{"from":"ci","text":"deploy api"}`, "ci ran deploy api"},
+		{`{"text":"ping"}`, "http ran ping"},
+		// quick commands are answered in the response even with a reply url
+		{`{"from":"ci","text":"ping","reply_url":"http://127.0.0.1:1/reply"}`, "ci ran ping"},
+	}
+
+	for _, d := range testData {
+		status, rsp := post(t, p, "secret", d.body)
+		if status != http.StatusOK || rsp["text"] != d.expect || len(rsp["id"]) == 0 {
+			t.Fatalf("%s: expected %q got %d %v", d.body, d.expect, status, rsp)
+		}
+	}
+}
+
+func TestAsync(t *testing.T) {
+	replies := make(chan reply, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rep reply
+		json.NewDecoder(r.Body).Decode(&rep)
+		replies <- rep
+	}))
+	defer srv.Close()
+
+	p := startInput(t)
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c, 200*time.Millisecond)
+
+	status, rsp := post(t, p, "secret", `{"from":"ci","text":"slow","reply_url":"`+srv.URL+`"}`)
+	if status != http.StatusAccepted || rsp["status"] != "accepted" {
+		t.Fatalf("expected the request to be accepted got %d %v", status, rsp)
+	}
+
+	select {
+	case rep := <-replies:
+		if rep.ID != rsp["id"] || rep.To != "ci" || rep.Text != "ci ran slow" {
+			t.Fatalf("unexpected reply %+v", rep)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reply")
+	}
+
+	// slow commands without a reply url are waited for
+	status, rsp = post(t, p, "secret", `{"text":"slow"}`)
+	if status != http.StatusOK || rsp["text"] != "http ran slow" {
+		t.Fatalf("expected the output got %d %v", status, rsp)
+	}
+}
+
+func TestInitEnv(t *testing.T) {
+	p := NewInput().(*httpInput)
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range p.Flags() {
+		f.Apply(set)
+	}
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	env := []string{"MICRO_HTTP_SECRET", "MICRO_BOT_HTTP_SECRET", "MICRO_HTTP_ADDRESS", "MICRO_HTTP_ASYNC_AFTER"}
+	defer func() {
+		for _, k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	os.Setenv("MICRO_HTTP_SECRET", "env")
+	os.Setenv("MICRO_HTTP_ADDRESS", ":9000")
+	os.Setenv("MICRO_HTTP_ASYNC_AFTER", "1s")
+	if err := p.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.secret != "env" || p.address != ":9000" || p.asyncAfter != time.Second {
+		t.Fatalf("expected the env got %s %s %v", p.secret, p.address, p.asyncAfter)
+	}
+
+	// the variable the secret was read from before still is
+	os.Unsetenv("MICRO_HTTP_SECRET")
+	os.Setenv("MICRO_BOT_HTTP_SECRET", "old")
+	if err := p.Init(ctx); err != nil || p.secret != "old" {
+		t.Fatalf("expected the old env got %s %v", p.secret, err)
+	}
+
+	os.Setenv("MICRO_HTTP_ASYNC_AFTER", "soon")
+	if err := p.Init(ctx); err == nil || err.Error() != "invalid MICRO_HTTP_ASYNC_AFTER=soon, expected a duration e.g. 30s" {
+		t.Fatalf("expected the env var named got %v", err)
+	}
+}
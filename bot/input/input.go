@@ -0,0 +1,105 @@
+// Package input is the registry of bot inputs. The types are those of
+// go-bot so inputs written against either work with the bot.
+package input
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/micro/go-bot/input"
+)
+
+type (
+	EventType = input.EventType
+	Event     = input.Event
+	Input     = input.Input
+	Conn      = input.Conn
+)
+
+const (
+	TextEvent = input.TextEvent
+)
+
+var (
+	// Inputs keyed by name, it's read and written without locking.
+	//
+	// Deprecated: use Register, Deregister, Lookup and List. Inputs
+	// registered here are still found for now.
+	Inputs = input.Inputs
+
+	mtx    sync.RWMutex
+	inputs = map[string]Input{}
+)
+
+// Register makes the input available by name. It panics if an input of
+// the same name is already registered.
+func Register(name string, i Input) {
+	if i == nil {
+		panic("input: Register input is nil")
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	if _, ok := inputs[name]; ok {
+		panic(fmt.Sprintf("input: Register called twice for input %s", name))
+	}
+
+	if _, ok := Inputs[name]; ok {
+		panic(fmt.Sprintf("input: %s is already registered in input.Inputs", name))
+	}
+
+	inputs[name] = i
+	// for anything still reading the map
+	Inputs[name] = i
+}
+
+// Deregister removes the input, returning false if it wasn't registered
+func Deregister(name string) bool {
+	mtx.Lock()
+	defer mtx.Unlock()
+
+	_, ok := inputs[name]
+	if !ok {
+		_, ok = Inputs[name]
+	}
+
+	delete(inputs, name)
+	delete(Inputs, name)
+
+	return ok
+}
+
+// Lookup returns the input registered by name
+func Lookup(name string) (Input, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	if i, ok := inputs[name]; ok {
+		return i, true
+	}
+
+	i, ok := Inputs[name]
+	return i, ok
+}
+
+// List returns the names of the registered inputs in order
+func List() []string {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	names := make([]string, 0, len(Inputs))
+	for name := range Inputs {
+		names = append(names, name)
+	}
+
+	for name := range inputs {
+		if _, ok := Inputs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
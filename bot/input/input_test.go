@@ -0,0 +1,121 @@
+package input
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/micro/cli"
+)
+
+type testInput struct {
+	name string
+}
+
+func (t *testInput) Flags() []cli.Flag       { return nil }
+func (t *testInput) Init(*cli.Context) error { return nil }
+func (t *testInput) Stream() (Conn, error)   { return nil, nil }
+func (t *testInput) Start() error            { return nil }
+func (t *testInput) Stop() error             { return nil }
+func (t *testInput) String() string          { return t.name }
+
+// panics returns the value fn panicked with
+func panics(fn func()) (v interface{}) {
+	defer func() {
+		v = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	a := &testInput{"test-a"}
+	Register("test-a", a)
+	defer Deregister("test-a")
+
+	if i, ok := Lookup("test-a"); !ok || i != a {
+		t.Fatalf("expected test-a got %v %v", i, ok)
+	}
+
+	// still readable through the deprecated map
+	if Inputs["test-a"] != a {
+		t.Fatal("expected test-a in Inputs")
+	}
+
+	if v := panics(func() { Register("test-a", &testInput{"test-a"}) }); v == nil {
+		t.Fatal("expected registering twice to panic")
+	}
+
+	if v := panics(func() { Register("test-nil", nil) }); v == nil {
+		t.Fatal("expected registering nil to panic")
+	}
+
+	// inputs only in the deprecated map are found
+	b := &testInput{"test-b"}
+	Inputs["test-b"] = b
+
+	if i, ok := Lookup("test-b"); !ok || i != b {
+		t.Fatalf("expected test-b got %v %v", i, ok)
+	}
+
+	if v := panics(func() { Register("test-b", b) }); v == nil {
+		t.Fatal("expected registering an input in Inputs to panic")
+	}
+
+	names := List()
+	var found int
+	for i, name := range names {
+		if i > 0 && names[i-1] >= name {
+			t.Fatalf("expected sorted unique names got %v", names)
+		}
+		if name == "test-a" || name == "test-b" {
+			found++
+		}
+	}
+
+	if found != 2 {
+		t.Fatalf("expected test-a and test-b in %v", names)
+	}
+
+	for _, name := range []string{"test-a", "test-b"} {
+		if !Deregister(name) {
+			t.Fatalf("expected %s to be deregistered", name)
+		}
+		if _, ok := Lookup(name); ok {
+			t.Fatalf("expected %s to be gone", name)
+		}
+	}
+
+	if Deregister("test-a") {
+		t.Fatal("expected deregistering twice to return false")
+	}
+
+	// registering again after deregistering
+	Register("test-a", a)
+}
+
+func TestConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		name := fmt.Sprintf("test-%d", i)
+
+		go func() {
+			defer wg.Done()
+			Register(name, &testInput{name})
+			Lookup(name)
+			Deregister(name)
+		}()
+
+		go func() {
+			defer wg.Done()
+			for _, n := range List() {
+				Lookup(n)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,395 @@
+package irc
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long to wait before reconnecting, doubled on each failure
+	reconnectBackoff = time.Second
+	// how often channels the bot isn't in are rejoined
+	rejoinInterval = time.Minute
+	// the server is pinged when it's been quiet this long
+	pingInterval = 2 * time.Minute
+	// lines sent before pacing kicks in and the pace after
+	floodBurst    = 4
+	floodInterval = time.Second
+)
+
+// config is how the client connects
+type config struct {
+	server   string
+	tls      bool
+	nick     string
+	channels []string
+	password string
+}
+
+// client maintains the connection to the server, reconnecting with
+// backoff and keeping the bot in its channels
+type client struct {
+	config
+
+	events chan *message
+	// paced lines to send, kept while disconnected
+	out  chan string
+	exit chan bool
+	done chan bool
+
+	sync.Mutex
+	conn net.Conn
+	// the nick the server knows us by, the configured one may be taken
+	current string
+	joined  map[string]bool
+}
+
+func newClient(c config, exit chan bool) *client {
+	return &client{
+		config:  c,
+		events:  make(chan *message),
+		out:     make(chan string, 100),
+		exit:    exit,
+		done:    make(chan bool),
+		current: c.nick,
+		joined:  make(map[string]bool),
+	}
+}
+
+func (c *client) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+
+	if !c.tls {
+		return d.Dial("tcp", c.server)
+	}
+
+	host, _, err := net.SplitHostPort(c.server)
+	if err != nil {
+		return nil, err
+	}
+
+	return tls.DialWithDialer(d, "tcp", c.server, &tls.Config{ServerName: host})
+}
+
+// nickname returns the nick the server knows the bot by
+func (c *client) nickname() string {
+	c.Lock()
+	defer c.Unlock()
+	return c.current
+}
+
+// write sends a line immediately
+func (c *client) write(format string, args ...interface{}) error {
+	c.Lock()
+	conn := c.conn
+	c.Unlock()
+
+	if conn == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	line := fmt.Sprintf(format, args...)
+	// a line can't smuggle in another command
+	line = strings.NewReplacer("\r", "", "\n", " ").Replace(line)
+
+	conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := fmt.Fprintf(conn, "%s\r\n", line)
+	return err
+}
+
+// queue sends a line once earlier lines have been sent without flooding
+func (c *client) queue(line string) bool {
+	select {
+	case <-c.exit:
+		return false
+	case c.out <- line:
+		return true
+	default:
+		log.Logf("[irc] dropped line, %d lines already queued", cap(c.out))
+		return false
+	}
+}
+
+// run connects and reconnects with backoff until exit is closed. conn
+// is the first connection, dialed to check the server is reachable.
+func (c *client) run(conn net.Conn) {
+	defer close(c.done)
+
+	backoff := reconnectBackoff
+
+	for {
+		start := time.Now()
+
+		err := c.session(conn)
+		conn = nil
+
+		select {
+		case <-c.exit:
+			return
+		default:
+		}
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) > time.Minute {
+			backoff = reconnectBackoff
+		}
+
+		log.Logf("[irc] connection to %s lost: %v, reconnecting in %v", c.server, err, backoff)
+
+		select {
+		case <-c.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+
+		conn, err = c.dial()
+		if err != nil {
+			log.Logf("[irc] error connecting to %s: %v", c.server, err)
+			continue
+		}
+	}
+}
+
+// session registers on the connection and handles it until it fails
+func (c *client) session(conn net.Conn) error {
+	if conn == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	c.Lock()
+	c.conn = conn
+	c.current = c.nick
+	c.joined = make(map[string]bool)
+	c.Unlock()
+
+	closed := make(chan bool)
+	var wg sync.WaitGroup
+
+	defer func() {
+		close(closed)
+		conn.Close()
+		wg.Wait()
+		c.Lock()
+		c.conn = nil
+		c.Unlock()
+	}()
+
+	// unblock reads once exiting
+	go func() {
+		select {
+		case <-c.exit:
+			c.write("QUIT :shutting down")
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	c.write("NICK %s", c.nick)
+	c.write("USER %s 0 * :micro bot", c.nick)
+
+	registered := make(chan bool)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.pace(registered, closed)
+	}()
+	go func() {
+		defer wg.Done()
+		c.rejoin(registered, closed)
+	}()
+
+	r := bufio.NewReader(conn)
+
+	var partial string
+	var pinged bool
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(pingInterval))
+
+		line, err := r.ReadString('\n')
+		line = partial + line
+
+		if err != nil {
+			ne, ok := err.(net.Error)
+			if !ok || !ne.Timeout() || pinged {
+				return err
+			}
+
+			// ping a quiet server, failing if it doesn't answer in time
+			partial = line
+			pinged = true
+			if err := c.write("PING :%s", c.server); err != nil {
+				return err
+			}
+			continue
+		}
+
+		partial = ""
+		pinged = false
+
+		m, ok := parseMessage(line)
+		if !ok {
+			continue
+		}
+
+		if err := c.handle(m, registered); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) handle(m *message, registered chan bool) error {
+	switch m.Command {
+	case "PING":
+		return c.write("PONG :%s", m.Trailing())
+	case "001":
+		// welcome, we're registered
+		if len(m.Params) > 0 {
+			c.Lock()
+			c.current = m.Params[0]
+			c.Unlock()
+		}
+		if len(c.password) > 0 {
+			c.write("PRIVMSG NickServ :IDENTIFY %s", c.password)
+		}
+		c.join()
+		select {
+		case <-registered:
+		default:
+			close(registered)
+		}
+	case "433":
+		// nick in use, take the next one
+		c.Lock()
+		c.current += "_"
+		nick := c.current
+		c.Unlock()
+		return c.write("NICK %s", nick)
+	case "NICK":
+		if strings.EqualFold(m.Nick(), c.nickname()) {
+			c.Lock()
+			c.current = m.Trailing()
+			c.Unlock()
+		}
+	case "JOIN":
+		if strings.EqualFold(m.Nick(), c.nickname()) && len(m.Params) > 0 {
+			c.Lock()
+			c.joined[strings.ToLower(m.Params[0])] = true
+			c.Unlock()
+		}
+	case "PART":
+		if strings.EqualFold(m.Nick(), c.nickname()) && len(m.Params) > 0 {
+			c.left(m.Params[0])
+		}
+	case "KICK":
+		if len(m.Params) > 1 && strings.EqualFold(m.Params[1], c.nickname()) {
+			log.Logf("[irc] kicked from %s by %s", m.Params[0], m.Nick())
+			c.left(m.Params[0])
+		}
+	case "ERROR":
+		return fmt.Errorf("server error: %s", m.Trailing())
+	case "PRIVMSG":
+		select {
+		case <-c.exit:
+		case c.events <- m:
+		}
+	}
+
+	return nil
+}
+
+func (c *client) left(channel string) {
+	c.Lock()
+	delete(c.joined, strings.ToLower(channel))
+	c.Unlock()
+}
+
+// join joins the configured channels the bot isn't in
+func (c *client) join() {
+	c.Lock()
+	var missing []string
+	for _, ch := range c.channels {
+		if !c.joined[strings.ToLower(ch)] {
+			missing = append(missing, ch)
+		}
+	}
+	c.Unlock()
+
+	if len(missing) > 0 {
+		c.write("JOIN %s", strings.Join(missing, ","))
+	}
+}
+
+// rejoin periodically joins channels the bot was split or kicked from
+func (c *client) rejoin(registered, closed chan bool) {
+	select {
+	case <-registered:
+	case <-closed:
+		return
+	}
+
+	t := time.NewTicker(rejoinInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-t.C:
+			c.join()
+		}
+	}
+}
+
+// pace sends queued lines once registered, bursting up to floodBurst
+// lines then one every floodInterval so the server doesn't disconnect
+// the bot for flooding
+func (c *client) pace(registered, closed chan bool) {
+	select {
+	case <-registered:
+	case <-closed:
+		return
+	}
+
+	tokens := floodBurst
+	t := time.NewTicker(floodInterval)
+	defer t.Stop()
+
+	for {
+		if tokens == 0 {
+			select {
+			case <-closed:
+				return
+			case <-t.C:
+				tokens++
+			}
+			continue
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-t.C:
+			if tokens < floodBurst {
+				tokens++
+			}
+		case line := <-c.out:
+			if err := c.write("%s", line); err != nil {
+				log.Logf("[irc] error sending line: %v", err)
+				return
+			}
+			tokens--
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package irc
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events
+const (
+	// MetaChannel is the channel the message was sent in, empty for
+	// private messages
+	MetaChannel = "irc_channel"
+	// MetaNick is the nick of the sender
+	MetaNick = "irc_nick"
+)
+
+// maxLine is the most text sent in a line. Servers allow 512 bytes
+// including the sender prefix they add when relaying.
+var maxLine = 450
+
+// Satisfies the input.Conn interface
+type ircConn struct {
+	client *client
+	prefix string
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, prefix string) *ircConn {
+	return &ircConn{
+		client: c,
+		prefix: prefix,
+		closed: make(chan bool),
+	}
+}
+
+// isChannel returns true if the target is a channel rather than a nick
+func isChannel(target string) bool {
+	return len(target) > 0 && strings.ContainsAny(target[:1], "#&+!")
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Channel messages must start with "nick:" or the prefix, private
+// messages are always for the bot.
+func (c *ircConn) addressed(m *message) (string, bool) {
+	if len(m.Params) < 2 {
+		return "", false
+	}
+
+	text := strings.TrimSpace(m.Trailing())
+
+	// CTCP such as ACTION isn't a command
+	if strings.HasPrefix(text, "\x01") {
+		return "", false
+	}
+
+	nick := c.client.nickname()
+	if len(text) > len(nick) && strings.EqualFold(text[:len(nick)], nick) && strings.ContainsAny(text[len(nick):len(nick)+1], ",:") {
+		return strings.TrimLeft(text[len(nick):], " ,:"), true
+	}
+
+	if len(c.prefix) > 0 && strings.HasPrefix(text, c.prefix) {
+		return strings.TrimSpace(text[len(c.prefix):]), true
+	}
+
+	if !isChannel(m.Params[0]) {
+		return text, true
+	}
+
+	return "", false
+}
+
+func (c *ircConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *ircConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.client.exit:
+			return errors.New("irc input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case m := <-c.client.events:
+			text, ok := c.addressed(m)
+			if !ok || len(text) == 0 {
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			// replies to private messages go back to the sender
+			target := m.Params[0]
+			if isChannel(target) {
+				event.From = target + ":" + m.Nick()
+				event.Meta[MetaChannel] = target
+			} else {
+				event.From = m.Nick()
+				event.Meta[MetaChannel] = ""
+			}
+
+			event.To = c.client.nickname()
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = m
+			event.Meta[MetaNick] = m.Nick()
+
+			return nil
+		}
+	}
+}
+
+// Principal identifies the sender by nick!user@host since nicks can
+// be taken by anyone
+func (c *ircConn) Principal(event input.Event) string {
+	m, ok := event.Meta["reply"].(*message)
+	if !ok || len(m.Prefix) == 0 {
+		return ""
+	}
+	return "irc:" + m.Prefix
+}
+
+// Send queues the reply. To is channel:nick to answer in a channel or
+// nick for a private message.
+func (c *ircConn) Send(event *input.Event) error {
+	if len(event.To) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	target, prefix := event.To, ""
+	if parts := strings.SplitN(event.To, ":", 2); len(parts) == 2 {
+		target = parts[0]
+		prefix = parts[1] + ": "
+	}
+
+	// what fits once the server relays the line
+	max := maxLine - len(target) - len(prefix)
+
+	for _, line := range wrap(string(event.Data), max) {
+		if !c.client.queue("PRIVMSG " + target + " :" + prefix + line) {
+			return errors.New("irc send queue full")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+// Package irc is an IRC input for the bot
+package irc
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// how long Stop waits for the client to quit
+var stopTimeout = 5 * time.Second
+
+type ircInput struct {
+	config
+	prefix string
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+}
+
+func init() {
+	input.Register("irc", NewInput())
+}
+
+func (p *ircInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "irc_server",
+			Usage: "IRC server host:port",
+		},
+		cli.BoolFlag{
+			Name:  "irc_tls",
+			Usage: "Connect to the IRC server using TLS",
+		},
+		cli.StringFlag{
+			Name:  "irc_nick",
+			Usage: "IRC nick of the bot",
+			Value: "micro",
+		},
+		cli.StringFlag{
+			Name:  "irc_channels",
+			Usage: "Comma separated list of IRC channels to join e.g #micro,#ops",
+		},
+		cli.StringFlag{
+			Name:  "irc_nickserv_password",
+			Usage: "Password to identify with NickServ",
+		},
+		cli.StringFlag{
+			Name:  "irc_prefix",
+			Usage: "Prefix such as ! which triggers commands in channels without the nick",
+		},
+	}
+}
+
+// parseChannels splits the channel list adding a # where it's missing
+func parseChannels(s string) []string {
+	var channels []string
+
+	for _, ch := range strings.Split(s, ",") {
+		ch = strings.TrimSpace(ch)
+		if len(ch) == 0 {
+			continue
+		}
+		if !isChannel(ch) {
+			ch = "#" + ch
+		}
+		channels = append(channels, ch)
+	}
+
+	return channels
+}
+
+func (p *ircInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	server := cfg.String("irc_server", "")
+	nick := cfg.String("irc_nick", "micro")
+
+	if len(server) == 0 {
+		return errors.New("missing irc server")
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return errors.New("invalid irc server, expected host:port")
+	}
+
+	if len(nick) == 0 || strings.ContainsAny(nick, " ,*?!@:") {
+		return errors.New("invalid irc nick")
+	}
+
+	p.server = server
+	p.tls = cfg.Bool("irc_tls", false)
+	p.nick = nick
+	p.channels = parseChannels(cfg.String("irc_channels", ""))
+	p.password = cfg.String("irc_nickserv_password", "")
+	p.prefix = cfg.String("irc_prefix", "")
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *ircInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.prefix), nil
+}
+
+func (p *ircInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.server) == 0 || len(p.nick) == 0 {
+		return errors.New("missing irc configuration")
+	}
+
+	exit := make(chan bool)
+	c := newClient(p.config, exit)
+
+	// fail fast if the server can't be reached
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	go c.run(conn)
+
+	p.exit = exit
+	p.client = c
+	p.running = true
+
+	return nil
+}
+
+func (p *ircInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	select {
+	case <-p.client.done:
+	case <-time.After(stopTimeout):
+		log.Logf("[irc] timed out waiting to disconnect from %s", p.server)
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *ircInput) String() string {
+	return "irc"
+}
+
+func NewInput() input.Input {
+	return &ircInput{}
+}
@@ -0,0 +1,297 @@
+package irc
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// testServer is a fake IRC server handing out its connections
+type testServer struct {
+	net.Listener
+	conns chan *serverConn
+}
+
+type serverConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func newTestServer(t *testing.T) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{Listener: l, conns: make(chan *serverConn, 2)}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.conns <- &serverConn{c, bufio.NewReader(c)}
+		}
+	}()
+
+	return s
+}
+
+func (s *testServer) accept(t *testing.T) *serverConn {
+	select {
+	case c := <-s.conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a connection")
+	}
+	return nil
+}
+
+// expect reads lines until one starts with prefix
+func (c *serverConn) expect(t *testing.T, prefix string) string {
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", prefix, err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+}
+
+func (c *serverConn) send(format string, args ...interface{}) {
+	fmt.Fprintf(c, format+"\r\n", args...)
+}
+
+// register welcomes the bot returning the channels it joined
+func (c *serverConn) register(t *testing.T, nick string) string {
+	c.expect(t, "NICK ")
+	c.expect(t, "USER ")
+	c.send(":irc.test 001 %s :Welcome", nick)
+	return c.expect(t, "JOIN ")
+}
+
+func startIRC(t *testing.T, srv *testServer, prefix string) *ircInput {
+	io := NewInput().(*ircInput)
+	io.server = srv.Addr().String()
+	io.nick = "micro"
+	io.channels = []string{"#micro", "#ops"}
+	io.password = "s3cr3t"
+	io.prefix = prefix
+
+	if err := io.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return io
+}
+
+func TestParseChannels(t *testing.T) {
+	got := parseChannels(" #micro, ops,,&local ")
+	if strings.Join(got, ",") != "#micro,#ops,&local" {
+		t.Fatalf("unexpected channels %q", got)
+	}
+}
+
+func TestAddressed(t *testing.T) {
+	c := newClient(config{nick: "micro"}, nil)
+	conn := newConn(c, "!")
+
+	testData := []struct {
+		line   string
+		expect string
+		ok     bool
+	}{
+		{":john!j@h PRIVMSG #micro :micro: ping", "ping", true},
+		{":john!j@h PRIVMSG #micro :Micro, ping now", "ping now", true},
+		{":john!j@h PRIVMSG #micro :!ping", "ping", true},
+		{":john!j@h PRIVMSG #micro :ping", "", false},
+		{":john!j@h PRIVMSG #micro :microbot: ping", "", false},
+		{":john!j@h PRIVMSG #micro :micro ping", "", false},
+		{":john!j@h PRIVMSG micro :ping", "ping", true},
+		{":john!j@h PRIVMSG micro :\x01VERSION\x01", "", false},
+		{":john!j@h PRIVMSG #micro :\x01ACTION waves\x01", "", false},
+	}
+
+	for _, d := range testData {
+		m, _ := parseMessage(d.line)
+		text, ok := conn.addressed(m)
+		if ok != d.ok || text != d.expect {
+			t.Fatalf("%q: expected %q %v got %q %v", d.line, d.expect, d.ok, text, ok)
+		}
+	}
+}
+
+func TestIRC(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	io := startIRC(t, srv, "!")
+	defer io.Stop()
+
+	sc := srv.accept(t)
+
+	// the nick is taken
+	sc.expect(t, "NICK micro")
+	sc.expect(t, "USER ")
+	sc.send(":irc.test 433 * micro :Nickname is already in use")
+	sc.expect(t, "NICK micro_")
+	sc.send(":irc.test 001 micro_ :Welcome")
+
+	if line := sc.expect(t, "PRIVMSG NickServ"); line != "PRIVMSG NickServ :IDENTIFY s3cr3t" {
+		t.Fatalf("unexpected identify %q", line)
+	}
+	if line := sc.expect(t, "JOIN "); line != "JOIN #micro,#ops" {
+		t.Fatalf("unexpected join %q", line)
+	}
+
+	c, err := io.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	sc.send("PING :irc.test")
+	sc.expect(t, "PONG :irc.test")
+
+	testData := []struct {
+		line  string
+		from  string
+		reply string
+	}{
+		{":john!j@h PRIVMSG #micro :micro_: ping", "#micro:john", "PRIVMSG #micro :john: pong"},
+		{":john!j@h PRIVMSG #micro :!ping", "#micro:john", "PRIVMSG #micro :john: pong"},
+		{":john!j@h PRIVMSG micro_ :ping", "john", "PRIVMSG john :pong"},
+	}
+
+	for _, d := range testData {
+		sc.send(":john!j@h PRIVMSG #micro :not for the bot")
+		sc.send(d.line)
+
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if ev.From != d.from || string(ev.Data) != "ping" {
+			t.Fatalf("%q: unexpected event %+v", d.line, ev)
+		}
+
+		if p := c.(*ircConn).Principal(ev); p != "irc:john!j@h" {
+			t.Fatalf("%q: unexpected principal %q", d.line, p)
+		}
+
+		if err := c.Send(&input.Event{To: ev.From, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+			t.Fatal(err)
+		}
+
+		if line := sc.expect(t, "PRIVMSG "); line != d.reply {
+			t.Fatalf("%q: expected %q got %q", d.line, d.reply, line)
+		}
+	}
+}
+
+func TestFloodProtection(t *testing.T) {
+	burst, interval := floodBurst, floodInterval
+	floodBurst, floodInterval = 2, 50*time.Millisecond
+	defer func() { floodBurst, floodInterval = burst, interval }()
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	io := startIRC(t, srv, "")
+	defer io.Stop()
+
+	sc := srv.accept(t)
+	sc.register(t, "micro")
+
+	c, err := io.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+
+	// long output is wrapped to the line limit
+	text := strings.Repeat("x", maxLine) + "\n1\n2\n3"
+	if err := c.Send(&input.Event{To: "john", Type: input.TextEvent, Data: []byte(text)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for i := 0; i < 5; i++ {
+		lines = append(lines, sc.expect(t, "PRIVMSG john"))
+	}
+
+	for _, line := range lines {
+		if len(line) > maxLine+len("PRIVMSG  :") {
+			t.Fatalf("line too long: %d bytes", len(line))
+		}
+	}
+
+	if lines[4] != "PRIVMSG john :3" {
+		t.Fatalf("unexpected last line %q", lines[4])
+	}
+
+	// two lines are sent at once, the rest paced
+	if d := time.Since(start); d < 2*floodInterval {
+		t.Fatalf("expected the lines to be paced, sent in %v", d)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	backoff, rejoin := reconnectBackoff, rejoinInterval
+	reconnectBackoff, rejoinInterval = 10*time.Millisecond, 20*time.Millisecond
+	defer func() { reconnectBackoff, rejoinInterval = backoff, rejoin }()
+
+	srv := newTestServer(t)
+	defer srv.Close()
+
+	io := startIRC(t, srv, "")
+	defer io.Stop()
+
+	sc := srv.accept(t)
+	sc.register(t, "micro")
+	sc.send(":micro!m@h JOIN #micro")
+	sc.send(":micro!m@h JOIN #ops")
+
+	// wait for the joins to be seen
+	sc.send("PING :sync")
+	sc.expect(t, "PONG :sync")
+
+	// channels the bot is removed from are rejoined
+	sc.send(":op!o@h KICK #ops micro :out")
+
+	if line := sc.expect(t, "JOIN "); line != "JOIN #ops" {
+		t.Fatalf("expected to rejoin #ops got %q", line)
+	}
+
+	// and every channel after reconnecting
+	sc.Close()
+
+	sc = srv.accept(t)
+	if line := sc.register(t, "micro"); line != "JOIN #micro,#ops" {
+		t.Fatalf("unexpected join %q", line)
+	}
+
+	// stopping quits
+	done := make(chan bool)
+	go func() {
+		io.Stop()
+		close(done)
+	}()
+
+	sc.expect(t, "QUIT")
+	<-done
+}
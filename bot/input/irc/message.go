@@ -0,0 +1,109 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// message is a parsed IRC protocol line
+type message struct {
+	// nick!user@host or the server name
+	Prefix  string
+	Command string
+	Params  []string
+}
+
+// Nick returns the nick of the message's sender
+func (m *message) Nick() string {
+	if i := strings.Index(m.Prefix, "!"); i >= 0 {
+		return m.Prefix[:i]
+	}
+	return m.Prefix
+}
+
+// Trailing returns the last parameter, usually the text
+func (m *message) Trailing() string {
+	if len(m.Params) == 0 {
+		return ""
+	}
+	return m.Params[len(m.Params)-1]
+}
+
+// parseMessage parses a line such as ":nick!u@h PRIVMSG #chan :hello".
+// Message tags are dropped.
+func parseMessage(line string) (*message, bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, "@") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return nil, false
+		}
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+
+	m := &message{}
+
+	if strings.HasPrefix(line, ":") {
+		i := strings.Index(line, " ")
+		if i < 0 {
+			return nil, false
+		}
+		m.Prefix = line[1:i]
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+
+	for len(line) > 0 {
+		if strings.HasPrefix(line, ":") {
+			m.Params = append(m.Params, line[1:])
+			break
+		}
+
+		i := strings.Index(line, " ")
+		if i < 0 {
+			m.Params = append(m.Params, line)
+			break
+		}
+
+		m.Params = append(m.Params, line[:i])
+		line = strings.TrimLeft(line[i+1:], " ")
+	}
+
+	if len(m.Params) == 0 {
+		return nil, false
+	}
+
+	m.Command = strings.ToUpper(m.Params[0])
+	m.Params = m.Params[1:]
+
+	return m, true
+}
+
+// wrap breaks text into lines of at most max bytes, breaking on spaces
+// where possible and never within a rune. Empty lines are dropped since
+// they can't be sent.
+func wrap(text string, max int) []string {
+	var lines []string
+
+	for _, line := range strings.Split(strings.Replace(text, "\r", "", -1), "\n") {
+		for len(line) > max {
+			i := strings.LastIndex(line[:max+1], " ")
+			if i <= 0 {
+				// no space to break on, cut on a rune boundary
+				i = max
+				for i > 0 && !utf8.RuneStart(line[i]) {
+					i--
+				}
+			}
+
+			lines = append(lines, line[:i])
+			line = strings.TrimLeft(line[i:], " ")
+		}
+
+		if len(strings.TrimSpace(line)) > 0 {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,72 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	testData := []struct {
+		line    string
+		prefix  string
+		command string
+		params  []string
+		ok      bool
+	}{
+		{"PING :irc.example.com\r\n", "", "PING", []string{"irc.example.com"}, true},
+		{":john!j@host PRIVMSG #micro :micro: ping now", "john!j@host", "PRIVMSG", []string{"#micro", "micro: ping now"}, true},
+		{":irc.example.com 001 micro :Welcome", "irc.example.com", "001", []string{"micro", "Welcome"}, true},
+		{"@time=2019-01-01T00:00:00Z :john!j@host JOIN #micro", "john!j@host", "JOIN", []string{"#micro"}, true},
+		{":john!j@host  KICK  #micro micro :bye", "john!j@host", "KICK", []string{"#micro", "micro", "bye"}, true},
+		{":john!j@host privmsg micro ::)", "john!j@host", "PRIVMSG", []string{"micro", ":)"}, true},
+		{"", "", "", nil, false},
+		{":prefixonly", "", "", nil, false},
+		{"@tagsonly", "", "", nil, false},
+	}
+
+	for _, d := range testData {
+		m, ok := parseMessage(d.line)
+		if ok != d.ok {
+			t.Fatalf("%q: expected ok %v", d.line, d.ok)
+		}
+		if !ok {
+			continue
+		}
+		if m.Prefix != d.prefix || m.Command != d.command || strings.Join(m.Params, "|") != strings.Join(d.params, "|") {
+			t.Fatalf("%q: unexpected %+v", d.line, m)
+		}
+	}
+
+	m, _ := parseMessage(":john!j@host PRIVMSG #micro :hi")
+	if m.Nick() != "john" || m.Trailing() != "hi" {
+		t.Fatalf("unexpected nick %q or text %q", m.Nick(), m.Trailing())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	testData := []struct {
+		text   string
+		max    int
+		expect []string
+	}{
+		{"hello", 10, []string{"hello"}},
+		{"hello world", 5, []string{"hello", "world"}},
+		{"hello wide world", 10, []string{"hello wide", "world"}},
+		{"helloworld!", 5, []string{"hello", "world", "!"}},
+		{"one\r\ntwo\n\nthree", 10, []string{"one", "two", "three"}},
+		{"héllo", 2, []string{"h", "é", "ll", "o"}},
+		{"", 10, nil},
+	}
+
+	for _, d := range testData {
+		got := wrap(d.text, d.max)
+		if strings.Join(got, "|") != strings.Join(d.expect, "|") {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, got)
+		}
+		for _, line := range got {
+			if len(line) > d.max {
+				t.Fatalf("%q: line %q longer than %d", d.text, line, d.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package matrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// event is a room event
+type event struct {
+	Type    string  `json:"type"`
+	EventID string  `json:"event_id"`
+	Sender  string  `json:"sender"`
+	Content content `json:"content"`
+}
+
+type content struct {
+	MsgType       string `json:"msgtype,omitempty"`
+	Body          string `json:"body"`
+	Format        string `json:"format,omitempty"`
+	FormattedBody string `json:"formatted_body,omitempty"`
+	// set on edits and replies
+	RelatesTo *struct {
+		RelType string `json:"rel_type,omitempty"`
+	} `json:"m.relates_to,omitempty"`
+	// set on member events inviting to a direct chat
+	IsDirect bool `json:"is_direct,omitempty"`
+}
+
+type joinedRoom struct {
+	Summary struct {
+		JoinedMembers *int `json:"m.joined_member_count"`
+	} `json:"summary"`
+	Timeline struct {
+		Events []event `json:"events"`
+	} `json:"timeline"`
+}
+
+type invitedRoom struct {
+	InviteState struct {
+		Events []event `json:"events"`
+	} `json:"invite_state"`
+}
+
+type syncResponse struct {
+	NextBatch   string `json:"next_batch"`
+	AccountData struct {
+		Events []struct {
+			Type    string          `json:"type"`
+			Content json.RawMessage `json:"content"`
+		} `json:"events"`
+	} `json:"account_data"`
+	Rooms struct {
+		Join   map[string]joinedRoom  `json:"join"`
+		Invite map[string]invitedRoom `json:"invite"`
+	} `json:"rooms"`
+}
+
+// apiError is returned when the homeserver refuses a request
+type apiError struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"errcode"`
+	Message    string `json:"error"`
+	// set when rate limited
+	RetryAfterMs int64 `json:"retry_after_ms"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("matrix error %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// only the messages the bot answers are synced
+const syncFilter = `{"presence":{"types":[]},"room":{"timeline":{"types":["m.room.message"]},"ephemeral":{"types":[]}}}`
+
+// client calls the client-server api of a homeserver
+type client struct {
+	// first for atomic alignment
+	txn   int64
+	url   string
+	token string
+	http  *http.Client
+}
+
+func newClient(homeserver string) (*client, error) {
+	u, err := url.Parse(homeserver)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix homeserver: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid matrix homeserver %s: expected an http or https scheme", homeserver)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid matrix homeserver %s: missing host", homeserver)
+	}
+
+	return &client{
+		url: strings.TrimSuffix(homeserver, "/"),
+		// long enough for a sync to wait for events
+		http: &http.Client{Timeout: pollTimeout + 30*time.Second},
+		txn:  time.Now().UnixNano(),
+	}, nil
+}
+
+func (c *client) do(method, path string, body, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+"/_matrix/client/v3"+path, r)
+	if err != nil {
+		return err
+	}
+
+	if len(c.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		e := &apiError{StatusCode: rsp.StatusCode}
+		json.NewDecoder(rsp.Body).Decode(e)
+		if len(e.Code) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		return e
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
+
+// login exchanges the user's password for an access token
+func (c *client) login(user, password string) (string, error) {
+	var rsp struct {
+		AccessToken string `json:"access_token"`
+		UserID      string `json:"user_id"`
+	}
+
+	err := c.do("POST", "/login", map[string]interface{}{
+		"type":       "m.login.password",
+		"identifier": map[string]string{"type": "m.id.user", "user": user},
+		"password":   password,
+		// the device shows up in the bot's session list
+		"initial_device_display_name": "micro bot",
+	}, &rsp)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = rsp.AccessToken
+	return rsp.UserID, nil
+}
+
+func (c *client) whoami() (string, error) {
+	var rsp struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.do("GET", "/account/whoami", nil, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.UserID, nil
+}
+
+func (c *client) displayName(user string) (string, error) {
+	var rsp struct {
+		DisplayName string `json:"displayname"`
+	}
+	if err := c.do("GET", "/profile/"+url.PathEscape(user)+"/displayname", nil, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.DisplayName, nil
+}
+
+// join joins the room by ID or alias returning its ID
+func (c *client) join(room string) (string, error) {
+	var rsp struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := c.do("POST", "/join/"+url.PathEscape(room), struct{}{}, &rsp); err != nil {
+		return "", err
+	}
+	return rsp.RoomID, nil
+}
+
+func (c *client) sync(since string, timeout time.Duration) (*syncResponse, error) {
+	q := url.Values{}
+	q.Set("filter", syncFilter)
+	q.Set("timeout", strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	if len(since) > 0 {
+		q.Set("since", since)
+	}
+
+	var rsp syncResponse
+	if err := c.do("GET", "/sync?"+q.Encode(), nil, &rsp); err != nil {
+		return nil, err
+	}
+	return &rsp, nil
+}
+
+// send sends a message event to the room
+func (c *client) send(room string, msg *content) error {
+	// transaction IDs make retried sends idempotent
+	txn := strconv.FormatInt(atomic.AddInt64(&c.txn, 1), 10)
+	path := "/rooms/" + url.PathEscape(room) + "/send/m.room.message/" + txn
+	return c.do("PUT", path, msg, nil)
+}
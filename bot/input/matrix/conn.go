@@ -0,0 +1,203 @@
+package matrix
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaRoom on outgoing
+// events falling back to Event.To.
+const (
+	// MetaRoom is the ID of the room the message was sent in
+	MetaRoom = "matrix_room"
+	// MetaSender is the mxid of the user who sent the message
+	MetaSender = "matrix_sender"
+	// MetaEventID is the ID of the message event
+	MetaEventID = "matrix_event_id"
+)
+
+// max length of a reply, events are limited to 64KB including the
+// formatted body
+var maxMessageSize = 16000
+
+// Satisfies the input.Conn interface
+type matrixConn struct {
+	client *client
+	// mxid and display name of the bot
+	me          string
+	displayName string
+	events      <-chan *roomEvent
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, me, displayName string, events <-chan *roomEvent, exit chan bool) *matrixConn {
+	return &matrixConn{
+		client:      c,
+		me:          me,
+		displayName: displayName,
+		events:      events,
+		exit:        exit,
+		closed:      make(chan bool),
+	}
+}
+
+// names returns what users address the bot by, its display name, mxid
+// and the localpart of the mxid
+func (c *matrixConn) names() []string {
+	names := []string{c.me}
+	if len(c.displayName) > 0 {
+		names = append(names, c.displayName)
+	}
+	if i := strings.Index(c.me, ":"); i > 1 {
+		names = append(names, c.me[1:i])
+	}
+	return names
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Messages must start with the bot's name, as clients insert it when
+// mentioning, unless they're sent in a direct chat.
+func (c *matrixConn) addressed(ev *roomEvent) (string, bool) {
+	if ev.Type != "m.room.message" || ev.Content.MsgType != "m.text" {
+		return "", false
+	}
+
+	// edits repeat the command
+	if r := ev.Content.RelatesTo; r != nil && r.RelType == "m.replace" {
+		return "", false
+	}
+
+	text := strings.TrimSpace(ev.Content.Body)
+
+	for _, name := range c.names() {
+		if len(text) < len(name) || !strings.EqualFold(text[:len(name)], name) {
+			continue
+		}
+		rest := text[len(name):]
+		if len(rest) == 0 || strings.ContainsAny(rest[:1], " ,:") {
+			return strings.TrimLeft(rest, " ,:"), true
+		}
+	}
+
+	if ev.direct {
+		return text, true
+	}
+
+	return "", false
+}
+
+// format returns the notice for the text. Fenced and multi line output
+// is sent as code blocks so clients keep its layout.
+func format(text string) *content {
+	msg := &content{MsgType: "m.notice", Body: text}
+
+	if !strings.Contains(text, "\n") && !strings.Contains(text, "```") {
+		return msg
+	}
+
+	var b strings.Builder
+
+	if !strings.Contains(text, "```") {
+		b.WriteString("<pre><code>" + html.EscapeString(text) + "</code></pre>")
+	} else {
+		// odd parts are within fences
+		for i, part := range strings.Split(text, "```") {
+			if i%2 == 1 {
+				// drop the language on the opening fence
+				if j := strings.Index(part, "\n"); j >= 0 && !strings.Contains(part[:j], " ") {
+					part = part[j+1:]
+				}
+				b.WriteString("<pre><code>" + html.EscapeString(strings.TrimSuffix(part, "\n")) + "</code></pre>")
+				continue
+			}
+			b.WriteString(strings.Replace(html.EscapeString(strings.Trim(part, "\n")), "\n", "<br>", -1))
+		}
+	}
+
+	msg.Format = "org.matrix.custom.html"
+	msg.FormattedBody = b.String()
+
+	return msg
+}
+
+func (c *matrixConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *matrixConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.exit:
+			return errors.New("matrix input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case ev := <-c.events:
+			text, ok := c.addressed(ev)
+			if !ok || len(text) == 0 {
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			// room IDs and mxids contain colons so From is just the room
+			event.From = ev.room
+			event.To = c.me
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = ev
+			event.Meta[MetaRoom] = ev.room
+			event.Meta[MetaSender] = ev.Sender
+			event.Meta[MetaEventID] = ev.EventID
+
+			return nil
+		}
+	}
+}
+
+// MessageLimit is the most characters sent in a message
+func (c *matrixConn) MessageLimit() int {
+	return maxMessageSize
+}
+
+func (c *matrixConn) Send(event *input.Event) error {
+	room := event.To
+	if r, ok := event.Meta[MetaRoom].(string); ok && len(r) > 0 {
+		room = r
+	}
+
+	if len(room) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	data := string(event.Data)
+	if len(data) == 0 {
+		data = "(no output)"
+	}
+
+	for _, text := range input.SplitMessage(data, maxMessageSize) {
+		if err := c.client.send(room, format(text)); err != nil {
+			log.Logf("[matrix] error sending to %s: %v", room, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+// Package matrix is a matrix input for the bot. Messages are received
+// by syncing with the homeserver using the client-server api.
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+type matrixInput struct {
+	homeserver string
+	token      string
+	user       string
+	password   string
+	rooms      []string
+	autoJoin   bool
+	syncFile   string
+
+	sync.Mutex
+	running     bool
+	exit        chan bool
+	client      *client
+	me          string
+	displayName string
+	syncer      *syncer
+}
+
+func init() {
+	input.Register("matrix", NewInput())
+}
+
+func (p *matrixInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "matrix_homeserver",
+			Usage: "Matrix homeserver url e.g https://matrix.example.com",
+		},
+		cli.StringFlag{
+			Name:  "matrix_token",
+			Usage: "Matrix access token of the bot",
+		},
+		cli.StringFlag{
+			Name:  "matrix_user",
+			Usage: "Matrix user to log in as when no access token is given",
+		},
+		cli.StringFlag{
+			Name:  "matrix_password",
+			Usage: "Matrix password to log in with",
+		},
+		cli.StringFlag{
+			Name:  "matrix_rooms",
+			Usage: "Comma separated list of room IDs or aliases to join e.g #ops:example.com",
+		},
+		cli.BoolFlag{
+			Name:  "matrix_auto_join",
+			Usage: "Join rooms the bot is invited to",
+		},
+		cli.StringFlag{
+			Name:  "matrix_sync_file",
+			Usage: "File the sync position is kept in so restarts don't replay old commands",
+		},
+	}
+}
+
+func (p *matrixInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	homeserver := cfg.String("matrix_homeserver", "")
+	token := cfg.String("matrix_token", "")
+	user := cfg.String("matrix_user", "")
+	password := cfg.String("matrix_password", "")
+
+	if len(homeserver) == 0 {
+		return errors.New("missing matrix homeserver")
+	}
+
+	if len(token) == 0 && (len(user) == 0 || len(password) == 0) {
+		return errors.New("missing matrix token or user and password")
+	}
+
+	// fail on a bad url now rather than on start
+	if _, err := newClient(homeserver); err != nil {
+		return err
+	}
+
+	var rooms []string
+	for _, r := range strings.Split(cfg.String("matrix_rooms", ""), ",") {
+		if r = strings.TrimSpace(r); len(r) > 0 {
+			rooms = append(rooms, r)
+		}
+	}
+
+	p.homeserver = homeserver
+	p.token = token
+	p.user = user
+	p.password = password
+	p.rooms = rooms
+	p.autoJoin = cfg.Bool("matrix_auto_join", false)
+	p.syncFile = cfg.String("matrix_sync_file", "")
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *matrixInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.me, p.displayName, p.syncer.events, p.exit), nil
+}
+
+func (p *matrixInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	c, err := newClient(p.homeserver)
+	if err != nil {
+		return err
+	}
+
+	// fail fast on bad credentials
+	var me string
+	if len(p.token) > 0 {
+		c.token = p.token
+		me, err = c.whoami()
+	} else {
+		me, err = c.login(p.user, p.password)
+	}
+	if err != nil {
+		return err
+	}
+
+	displayName, err := c.displayName(me)
+	if err != nil {
+		log.Logf("[matrix] error getting display name of %s: %v", me, err)
+	}
+
+	for _, room := range p.rooms {
+		if _, err := c.join(room); err != nil {
+			return fmt.Errorf("error joining matrix room %s: %v", room, err)
+		}
+	}
+
+	exit := make(chan bool)
+	s := newSyncer(c, me, &batchStore{file: p.syncFile}, p.autoJoin, exit)
+
+	go s.run()
+
+	log.Logf("[matrix] connected to %s as %s", p.homeserver, me)
+
+	p.client = c
+	p.me = me
+	p.displayName = displayName
+	p.syncer = s
+	p.exit = exit
+	p.running = true
+
+	return nil
+}
+
+func (p *matrixInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+	p.running = false
+	return nil
+}
+
+func (p *matrixInput) String() string {
+	return "matrix"
+}
+
+func NewInput() input.Input {
+	return &matrixInput{}
+}
@@ -0,0 +1,312 @@
+package matrix
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// testServer is a homeserver whose syncs return the queued responses
+type testServer struct {
+	*httptest.Server
+
+	syncs  chan string
+	since  chan string
+	joined chan string
+	sent   chan sent
+}
+
+type sent struct {
+	room string
+	msg  content
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		syncs:  make(chan string, 10),
+		since:  make(chan string, 100),
+		joined: make(chan string, 10),
+		sent:   make(chan sent, 10),
+	}
+
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errcode":"M_UNKNOWN_TOKEN","error":"Invalid access token passed."}`))
+				return
+			}
+			h(w, r)
+		}
+	}
+
+	prefix := "/_matrix/client/v3"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(prefix+"/login", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Identifier struct {
+				User string `json:"user"`
+			} `json:"identifier"`
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Identifier.User != "micro" || body.Password != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errcode":"M_FORBIDDEN","error":"Invalid password"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"token","user_id":"@micro:test"}`))
+	})
+	mux.HandleFunc(prefix+"/account/whoami", authed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"user_id":"@micro:test"}`))
+	}))
+	mux.HandleFunc(prefix+"/profile/", authed(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"displayname":"Micro Bot"}`))
+	}))
+	mux.HandleFunc(prefix+"/join/", authed(func(w http.ResponseWriter, r *http.Request) {
+		room := strings.TrimPrefix(r.URL.Path, prefix+"/join/")
+		if room == "#missing:test" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errcode":"M_NOT_FOUND","error":"Room alias not found"}`))
+			return
+		}
+		s.joined <- room
+		json.NewEncoder(w).Encode(map[string]string{"room_id": "!" + strings.TrimLeft(room, "#!")})
+	}))
+	mux.HandleFunc(prefix+"/sync", authed(func(w http.ResponseWriter, r *http.Request) {
+		since := r.URL.Query().Get("since")
+		s.since <- since
+
+		select {
+		case body := <-s.syncs:
+			w.Write([]byte(body))
+		case <-time.After(50 * time.Millisecond):
+			json.NewEncoder(w).Encode(map[string]string{"next_batch": since})
+		}
+	}))
+	mux.HandleFunc(prefix+"/rooms/", authed(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix+"/rooms/"), "/")
+		var msg content
+		json.NewDecoder(r.Body).Decode(&msg)
+		s.sent <- sent{parts[0], msg}
+		w.Write([]byte(`{"event_id":"$reply"}`))
+	}))
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+func message(id, sender, body string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "m.room.message",
+		"event_id": id,
+		"sender":   sender,
+		"content":  map[string]string{"msgtype": "m.text", "body": body},
+	}
+}
+
+func syncBody(batch string, rooms map[string][]map[string]interface{}, extra map[string]interface{}) string {
+	join := make(map[string]interface{})
+	for id, events := range rooms {
+		join[id] = map[string]interface{}{"timeline": map[string]interface{}{"events": events}}
+	}
+
+	body := map[string]interface{}{"next_batch": batch, "rooms": map[string]interface{}{"join": join}}
+	for k, v := range extra {
+		body[k] = v
+	}
+
+	b, _ := json.Marshal(body)
+	return string(b)
+}
+
+func recv(t *testing.T, c input.Conn) input.Event {
+	done := make(chan input.Event)
+	go func() {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			t.Error(err)
+		}
+		done <- ev
+	}()
+
+	select {
+	case ev := <-done:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return input.Event{}
+}
+
+func TestMatrix(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "matrix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	syncFile := filepath.Join(dir, "next_batch")
+
+	p := NewInput().(*matrixInput)
+	p.homeserver = s.URL
+	p.user = "micro"
+	p.password = "wrong"
+	p.rooms = []string{"#ops:test"}
+	p.autoJoin = true
+	p.syncFile = syncFile
+
+	if err := p.Start(); err == nil || !strings.Contains(err.Error(), "M_FORBIDDEN") {
+		t.Fatalf("expected M_FORBIDDEN got %v", err)
+	}
+
+	// history returned by the first sync isn't run
+	s.syncs <- syncBody("b1", map[string][]map[string]interface{}{
+		"!ops:test": {message("$old", "@john:test", "micro: old")},
+	}, nil)
+
+	s.syncs <- syncBody("b2", map[string][]map[string]interface{}{
+		"!ops:test": {
+			message("$own", "@micro:test", "micro: mine"),
+			message("$chat", "@john:test", "morning"),
+			{
+				"type": "m.room.message", "event_id": "$edit", "sender": "@john:test",
+				"content": map[string]interface{}{"msgtype": "m.text", "body": "micro: ping", "m.relates_to": map[string]string{"rel_type": "m.replace"}},
+			},
+			{
+				"type": "m.room.message", "event_id": "$notice", "sender": "@other:test",
+				"content": map[string]string{"msgtype": "m.notice", "body": "micro: loop"},
+			},
+			message("$ping", "@john:test", "micro: ping"),
+			message("$status", "@john:test", "Micro Bot, status"),
+		},
+		"!dm:test": {message("$help", "@john:test", "help")},
+	}, map[string]interface{}{
+		"account_data": map[string]interface{}{"events": []interface{}{
+			map[string]interface{}{"type": "m.direct", "content": map[string][]string{"@john:test": {"!dm:test"}}},
+			map[string]interface{}{"type": "m.push_rules", "content": map[string]interface{}{"global": map[string]interface{}{}}},
+		}},
+	})
+
+	p.password = "secret"
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	if room := <-s.joined; room != "#ops:test" {
+		t.Fatalf("expected to join #ops:test got %s", room)
+	}
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	expect := map[string]string{"ping": "!ops:test", "status": "!ops:test", "help": "!dm:test"}
+
+	for i := 0; i < len(expect); i++ {
+		ev := recv(t, c)
+
+		room, ok := expect[string(ev.Data)]
+		if !ok || ev.From != room || ev.Meta[MetaSender] != "@john:test" {
+			t.Fatalf("unexpected event %q %+v", ev.Data, ev)
+		}
+	}
+
+	// multi line output is sent as a code block
+	if err := c.Send(&input.Event{To: "!ops:test", Type: input.TextEvent, Data: []byte("a < b\nc")}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := <-s.sent
+	if m.room != "!ops:test" || m.msg.MsgType != "m.notice" || m.msg.Body != "a < b\nc" || m.msg.FormattedBody != "<pre><code>a &lt; b\nc</code></pre>" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+
+	// invites are joined
+	s.syncs <- `{"next_batch":"b3","rooms":{"invite":{"!new:test":{"invite_state":{"events":[]}}}}}`
+
+	if room := <-s.joined; room != "!new:test" {
+		t.Fatalf("expected to join the invited room got %s", room)
+	}
+
+	p.Stop()
+
+	// drain the syncs made so far
+	time.Sleep(100 * time.Millisecond)
+	for len(s.since) > 0 {
+		<-s.since
+	}
+
+	data, err := ioutil.ReadFile(syncFile)
+	if err != nil || string(data) != "b3" {
+		t.Fatalf("expected b3 stored got %q %v", data, err)
+	}
+
+	// restarting resumes from the stored position with a token
+	p.token = "token"
+	p.rooms = nil
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if since := <-s.since; since != "b3" {
+		t.Fatalf("expected to resume from b3 got %q", since)
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	s := newTestServer()
+	defer s.Close()
+
+	p := NewInput().(*matrixInput)
+	p.homeserver = s.URL
+	p.token = "bad"
+
+	if err := p.Start(); err == nil || !strings.Contains(err.Error(), "M_UNKNOWN_TOKEN") {
+		t.Fatalf("expected M_UNKNOWN_TOKEN got %v", err)
+	}
+
+	p.token = "token"
+	p.rooms = []string{"#missing:test"}
+
+	if err := p.Start(); err == nil || !strings.Contains(err.Error(), "error joining matrix room #missing:test") {
+		t.Fatalf("expected a join error got %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	testData := []struct {
+		text      string
+		formatted string
+	}{
+		{"pong", ""},
+		{"one\ntwo", "<pre><code>one\ntwo</code></pre>"},
+		{"output:\n```go\nfmt.Println(\"<hi>\")\n```\ndone", "output:<pre><code>fmt.Println(&#34;&lt;hi&gt;&#34;)</code></pre>done"},
+	}
+
+	for _, d := range testData {
+		msg := format(d.text)
+		if msg.MsgType != "m.notice" || msg.Body != d.text || msg.FormattedBody != d.formatted {
+			t.Fatalf("%q: expected %q got %+v", d.text, d.formatted, msg)
+		}
+		if len(d.formatted) > 0 && msg.Format != "org.matrix.custom.html" {
+			t.Fatalf("%q: expected html format got %q", d.text, msg.Format)
+		}
+	}
+}
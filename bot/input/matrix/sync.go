@@ -0,0 +1,218 @@
+package matrix
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long a sync waits for events
+	pollTimeout = 30 * time.Second
+	// how long to wait after a failed sync
+	pollBackoff = 5 * time.Second
+)
+
+// roomEvent is a message received in a room
+type roomEvent struct {
+	event
+	room   string
+	direct bool
+}
+
+// batchStore keeps the sync position so restarts resume where the bot
+// left off rather than replaying room history
+type batchStore struct {
+	file string
+}
+
+func (b *batchStore) load() string {
+	if len(b.file) == 0 {
+		return ""
+	}
+
+	data, err := ioutil.ReadFile(b.file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Logf("[matrix] error reading sync position: %v", err)
+		}
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func (b *batchStore) save(batch string) {
+	if len(b.file) == 0 {
+		return
+	}
+
+	// write then rename so a crash can't leave half a token
+	tmp := filepath.Join(filepath.Dir(b.file), "."+filepath.Base(b.file)+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(batch), 0600); err != nil {
+		log.Logf("[matrix] error saving sync position: %v", err)
+		return
+	}
+
+	if err := os.Rename(tmp, b.file); err != nil {
+		log.Logf("[matrix] error saving sync position: %v", err)
+	}
+}
+
+// syncer syncs with the homeserver delivering the messages sent in the
+// rooms the bot is in
+type syncer struct {
+	client   *client
+	me       string
+	store    *batchStore
+	autoJoin bool
+	events   chan *roomEvent
+	exit     chan bool
+
+	sync.Mutex
+	// rooms listed as direct chats in the bot's m.direct account data
+	direct map[string]bool
+	// members of each room as of the last summary
+	members map[string]int
+}
+
+func newSyncer(c *client, me string, store *batchStore, autoJoin bool, exit chan bool) *syncer {
+	return &syncer{
+		client:   c,
+		me:       me,
+		store:    store,
+		autoJoin: autoJoin,
+		events:   make(chan *roomEvent),
+		exit:     exit,
+		direct:   make(map[string]bool),
+		members:  make(map[string]int),
+	}
+}
+
+// isDirect returns true if the room is a direct chat with the bot
+func (s *syncer) isDirect(room string) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.direct[room] || s.members[room] == 2
+}
+
+// update records the direct chats and room sizes in the response
+func (s *syncer) update(rsp *syncResponse) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, ev := range rsp.AccountData.Events {
+		if ev.Type != "m.direct" {
+			continue
+		}
+
+		// users mapped to their direct chats with the bot
+		var direct map[string][]string
+		if err := json.Unmarshal(ev.Content, &direct); err != nil {
+			log.Logf("[matrix] error decoding direct chats: %v", err)
+			continue
+		}
+
+		// the whole list is sent when it changes
+		s.direct = make(map[string]bool)
+		for _, rooms := range direct {
+			for _, room := range rooms {
+				s.direct[room] = true
+			}
+		}
+	}
+
+	for id, room := range rsp.Rooms.Join {
+		if n := room.Summary.JoinedMembers; n != nil {
+			s.members[id] = *n
+		}
+	}
+}
+
+// invites joins the rooms the bot's been invited to if auto joining
+func (s *syncer) invites(rsp *syncResponse) {
+	for id, room := range rsp.Rooms.Invite {
+		if !s.autoJoin {
+			log.Logf("[matrix] ignoring invite to %s, auto join is disabled", id)
+			continue
+		}
+
+		if _, err := s.client.join(id); err != nil {
+			log.Logf("[matrix] error joining %s: %v", id, err)
+			continue
+		}
+
+		log.Logf("[matrix] joined %s", id)
+
+		// invites to direct chats say so on the bot's member event
+		for _, ev := range room.InviteState.Events {
+			if ev.Type == "m.room.member" && ev.Content.IsDirect {
+				s.Lock()
+				s.direct[id] = true
+				s.Unlock()
+			}
+		}
+	}
+}
+
+// run syncs until exit is closed
+func (s *syncer) run() {
+	since := s.store.load()
+	// the first sync without a position returns recent history which
+	// mustn't be run again
+	skip := len(since) == 0
+
+	for {
+		select {
+		case <-s.exit:
+			return
+		default:
+		}
+
+		rsp, err := s.client.sync(since, pollTimeout)
+		if err != nil {
+			wait := pollBackoff
+			if aerr, ok := err.(*apiError); ok && aerr.RetryAfterMs > 0 {
+				wait = time.Duration(aerr.RetryAfterMs) * time.Millisecond
+			}
+
+			log.Logf("[matrix] error syncing, retrying in %v: %v", wait, err)
+
+			select {
+			case <-s.exit:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		s.update(rsp)
+		s.invites(rsp)
+
+		if !skip {
+			for id, room := range rsp.Rooms.Join {
+				for _, ev := range room.Timeline.Events {
+					if ev.Sender == s.me {
+						continue
+					}
+
+					select {
+					case <-s.exit:
+						return
+					case s.events <- &roomEvent{event: ev, room: id, direct: s.isDirect(id)}:
+					}
+				}
+			}
+		}
+
+		skip = false
+		since = rsp.NextBatch
+		s.store.save(since)
+	}
+}
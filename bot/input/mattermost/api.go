@@ -0,0 +1,201 @@
+package mattermost
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type user struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type post struct {
+	ID        string `json:"id,omitempty"`
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id,omitempty"`
+	// the first post of the thread, empty for top level posts
+	RootID  string `json:"root_id,omitempty"`
+	Message string `json:"message"`
+	// system messages such as joins have a type
+	Type string `json:"type,omitempty"`
+}
+
+// apiError is returned when the server refuses a request
+type apiError struct {
+	ID         string `json:"id"`
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("mattermost error %d: %s", e.StatusCode, e.Message)
+}
+
+// client calls the v4 api of a server
+type client struct {
+	url    string
+	token  string
+	http   *http.Client
+	dialer *websocket.Dialer
+}
+
+// loadCA returns the system roots along with the certificates in file
+func loadCA(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mattermost tls ca: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("mattermost tls ca contains no certificates")
+	}
+
+	return pool, nil
+}
+
+// newClient returns a client for the server trusting the extra CA if
+// given. Verification is skipped entirely if insecure is set.
+func newClient(server, token, ca string, insecure bool) (*client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mattermost url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid mattermost url %s: expected an http or https scheme", server)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid mattermost url %s: missing host", server)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+
+	if len(ca) > 0 {
+		pool, err := loadCA(ca)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &client{
+		url:   strings.TrimSuffix(server, "/"),
+		token: token,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				TLSClientConfig:     tlsConfig,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: 45 * time.Second,
+		},
+	}, nil
+}
+
+func (c *client) do(method, path string, body, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+"/api/v4"+path, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		e := &apiError{StatusCode: rsp.StatusCode}
+		json.NewDecoder(rsp.Body).Decode(e)
+		if len(e.Message) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		return e
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
+
+func (c *client) me() (*user, error) {
+	var u user
+	if err := c.do("GET", "/users/me", nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (c *client) team(name string) (*team, error) {
+	var t team
+	if err := c.do("GET", "/teams/name/"+url.PathEscape(name), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (c *client) createPost(p *post) error {
+	return c.do("POST", "/posts", p, nil)
+}
+
+// websocket connects to the events api
+func (c *client) websocket() (*websocket.Conn, error) {
+	u := "ws" + strings.TrimPrefix(c.url, "http") + "/api/v4/websocket"
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+
+	ws, _, err := c.dialer.Dial(u, header)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to mattermost websocket: %v", err)
+	}
+
+	return ws, nil
+}
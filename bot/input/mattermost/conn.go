@@ -0,0 +1,222 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaChannel and
+// MetaRootID on outgoing events falling back to Event.To.
+const (
+	// MetaChannel is the ID of the channel the post was made in
+	MetaChannel = "mattermost_channel"
+	// MetaUser is the ID of the user who posted
+	MetaUser = "mattermost_user"
+	// MetaPostID is the ID of the post
+	MetaPostID = "mattermost_post_id"
+	// MetaRootID is the thread the post was made in, empty for top level
+	// posts
+	MetaRootID = "mattermost_root_id"
+)
+
+var (
+	// max length of a post
+	maxPostSize = 16383
+	// how often the websocket is pinged
+	pingInterval = 30 * time.Second
+)
+
+// wsEvent is an event received over the websocket
+type wsEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		ChannelType string `json:"channel_type"`
+		// the post is JSON encoded within the event
+		Post   string `json:"post"`
+		TeamID string `json:"team_id"`
+	} `json:"data"`
+}
+
+// Satisfies the input.Conn interface
+type mattermostConn struct {
+	client *client
+	me     *user
+	team   *team
+	ws     *websocket.Conn
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, me *user, t *team, ws *websocket.Conn, exit chan bool) *mattermostConn {
+	conn := &mattermostConn{
+		client: c,
+		me:     me,
+		team:   t,
+		ws:     ws,
+		exit:   exit,
+		closed: make(chan bool),
+	}
+
+	// a dead connection fails reads so the bot reconnects
+	ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+
+	go conn.run()
+
+	return conn
+}
+
+// run pings the server until closed, closing the websocket on exit
+func (c *mattermostConn) run() {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.exit:
+			c.Close()
+			return
+		case <-c.closed:
+			return
+		case <-t.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				log.Logf("[mattermost] error pinging websocket: %v", err)
+			}
+		}
+	}
+}
+
+// addressed returns the command in the post if it's for the bot. Posts
+// must start with an @mention of the bot unless they're direct messages.
+func (c *mattermostConn) addressed(p *post, channelType string) (string, bool) {
+	if p.UserID == c.me.ID || len(p.Type) > 0 {
+		return "", false
+	}
+
+	text := strings.TrimSpace(p.Message)
+
+	mention := "@" + c.me.Username
+	if len(text) >= len(mention) && strings.EqualFold(text[:len(mention)], mention) {
+		rest := text[len(mention):]
+		if len(rest) == 0 || strings.ContainsAny(rest[:1], " ,:") {
+			return strings.TrimLeft(rest, " ,:"), true
+		}
+	}
+
+	if channelType == "D" {
+		return text, true
+	}
+
+	return "", false
+}
+
+func (c *mattermostConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		c.ws.Close()
+	})
+	return nil
+}
+
+func (c *mattermostConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		var ev wsEvent
+		if err := c.ws.ReadJSON(&ev); err != nil {
+			select {
+			case <-c.closed:
+				return errors.New("connection closed")
+			default:
+			}
+			return err
+		}
+
+		if ev.Event != "posted" {
+			continue
+		}
+
+		// posts in the other teams the bot belongs to
+		if len(ev.Data.TeamID) > 0 && c.team != nil && ev.Data.TeamID != c.team.ID {
+			continue
+		}
+
+		var p post
+		if err := json.Unmarshal([]byte(ev.Data.Post), &p); err != nil {
+			log.Logf("[mattermost] error decoding post: %v", err)
+			continue
+		}
+
+		text, ok := c.addressed(&p, ev.Data.ChannelType)
+		if !ok || len(text) == 0 {
+			continue
+		}
+
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = p.ChannelID + ":" + p.UserID
+		event.To = c.me.ID
+		event.Type = input.TextEvent
+		event.Data = []byte(text)
+		event.Meta["reply"] = &p
+		event.Meta[MetaChannel] = p.ChannelID
+		event.Meta[MetaUser] = p.UserID
+		event.Meta[MetaPostID] = p.ID
+		event.Meta[MetaRootID] = p.RootID
+
+		return nil
+	}
+}
+
+func (c *mattermostConn) Send(event *input.Event) error {
+	// To is channel:user as set by Recv
+	channel := strings.Split(event.To, ":")[0]
+
+	if ch, ok := event.Meta[MetaChannel].(string); ok && len(ch) > 0 {
+		channel = ch
+	}
+
+	if len(channel) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	// answer in the thread the command was posted in
+	var root string
+	if reply, ok := event.Meta["reply"].(*post); ok && reply.ChannelID == channel {
+		root = reply.RootID
+	}
+	if r, ok := event.Meta[MetaRootID].(string); ok && len(r) > 0 {
+		root = r
+	}
+
+	data := string(event.Data)
+	if len(data) == 0 {
+		data = "(no output)"
+	}
+
+	for _, text := range input.SplitMessage(data, maxPostSize) {
+		if err := c.client.createPost(&post{ChannelID: channel, RootID: root, Message: text}); err != nil {
+			log.Logf("[mattermost] error posting to %s: %v", channel, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Package mattermost is a mattermost input for the bot. Posts are
+// received over the websocket events api and replies made with the
+// REST api.
+package mattermost
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+type mattermostInput struct {
+	url      string
+	token    string
+	teamName string
+	// for on prem servers with self signed certificates
+	ca       string
+	insecure bool
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+	me      *user
+	team    *team
+}
+
+func init() {
+	input.Register("mattermost", NewInput())
+}
+
+func (p *mattermostInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "mattermost_url",
+			Usage: "Mattermost server url e.g https://mattermost.example.com",
+		},
+		cli.StringFlag{
+			Name:  "mattermost_token",
+			Usage: "Mattermost bot access token",
+		},
+		cli.StringFlag{
+			Name:  "mattermost_team",
+			Usage: "Name of the team to answer in; direct messages are always answered",
+		},
+		cli.StringFlag{
+			Name:  "mattermost_tls_ca",
+			Usage: "PEM file of extra CA certificates to trust, for self signed servers",
+		},
+		cli.BoolFlag{
+			Name:  "mattermost_insecure_skip_verify",
+			Usage: "Skip verifying the server's TLS certificate",
+		},
+	}
+}
+
+func (p *mattermostInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	url := cfg.String("mattermost_url", "")
+	token := cfg.String("mattermost_token", "")
+	teamName := cfg.String("mattermost_team", "")
+
+	if len(url) == 0 {
+		return errors.New("missing mattermost url")
+	}
+
+	if len(token) == 0 {
+		return errors.New("missing mattermost token")
+	}
+
+	if len(teamName) == 0 {
+		return errors.New("missing mattermost team")
+	}
+
+	p.url = url
+	p.token = token
+	p.teamName = teamName
+	p.ca = cfg.String("mattermost_tls_ca", "")
+	p.insecure = cfg.Bool("mattermost_insecure_skip_verify", false)
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	if p.insecure {
+		log.Logf("[mattermost] not verifying the server's TLS certificate")
+	}
+
+	// fail on a bad url or ca now rather than on start
+	_, err := newClient(p.url, p.token, p.ca, p.insecure)
+	return err
+}
+
+func (p *mattermostInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	ws, err := p.client.websocket()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(p.client, p.me, p.team, ws, p.exit), nil
+}
+
+func (p *mattermostInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	c, err := newClient(p.url, p.token, p.ca, p.insecure)
+	if err != nil {
+		return err
+	}
+
+	// fail fast on a bad token
+	me, err := c.me()
+	if err != nil {
+		return err
+	}
+
+	t, err := c.team(p.teamName)
+	if err != nil {
+		return err
+	}
+
+	log.Logf("[mattermost] connected to %s as @%s", t.Name, me.Username)
+
+	p.client = c
+	p.me = me
+	p.team = t
+	p.exit = make(chan bool)
+	p.running = true
+
+	return nil
+}
+
+func (p *mattermostInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+	p.running = false
+	return nil
+}
+
+func (p *mattermostInput) String() string {
+	return "mattermost"
+}
+
+func NewInput() input.Input {
+	return &mattermostInput{}
+}
@@ -0,0 +1,308 @@
+package mattermost
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/micro/bot/input"
+)
+
+var testBot = &user{ID: "bot", Username: "micro"}
+
+// testServer is a self signed mattermost serving the v4 api and events
+// websocket
+type testServer struct {
+	*httptest.Server
+
+	upgrader websocket.Upgrader
+	posts    chan post
+	conns    chan *websocket.Conn
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		posts: make(chan post, 10),
+		conns: make(chan *websocket.Conn, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(apiError{ID: "api.context.session_expired.app_error", Message: "Invalid or expired session", StatusCode: 401})
+			return
+		}
+		json.NewEncoder(w).Encode(testBot)
+	})
+	mux.HandleFunc("/api/v4/teams/name/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/api/v4/teams/name/")
+		if name != "eng" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(apiError{Message: "Unable to find the existing team", StatusCode: 404})
+			return
+		}
+		json.NewEncoder(w).Encode(team{ID: "T0ENG", Name: "eng"})
+	})
+	mux.HandleFunc("/api/v4/posts", func(w http.ResponseWriter, r *http.Request) {
+		var p post
+		json.NewDecoder(r.Body).Decode(&p)
+		s.posts <- p
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v4/websocket", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ws, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ws.WriteJSON(map[string]interface{}{"event": "hello", "data": map[string]string{"server_version": "5.30"}})
+		s.conns <- ws
+	})
+
+	s.Server = httptest.NewTLSServer(mux)
+
+	return s
+}
+
+// send delivers a posted event over the websocket
+func send(t *testing.T, ws *websocket.Conn, teamID, channelType string, p post) {
+	b, _ := json.Marshal(p)
+
+	if err := ws.WriteJSON(map[string]interface{}{
+		"event": "posted",
+		"data": map[string]string{
+			"channel_type": channelType,
+			"post":         string(b),
+			"team_id":      teamID,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeCA writes the server's certificate to a file in dir
+func writeCA(t *testing.T, srv *testServer, dir string) string {
+	ca := filepath.Join(dir, "ca.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(ca, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return ca
+}
+
+func TestAddressed(t *testing.T) {
+	conn := &mattermostConn{me: testBot}
+
+	testData := []struct {
+		post        post
+		channelType string
+		expect      string
+		ok          bool
+	}{
+		{post{UserID: "u", Message: "@micro ping"}, "O", "ping", true},
+		{post{UserID: "u", Message: "@Micro: ping now"}, "P", "ping now", true},
+		{post{UserID: "u", Message: "ping"}, "O", "", false},
+		{post{UserID: "u", Message: "@microbot ping"}, "O", "", false},
+		{post{UserID: "u", Message: "ping"}, "D", "ping", true},
+		{post{UserID: "u", Message: "@micro ping"}, "D", "ping", true},
+		{post{UserID: "bot", Message: "ping"}, "D", "", false},
+		{post{UserID: "u", Message: "@micro joined", Type: "system_join_channel"}, "O", "", false},
+	}
+
+	for _, d := range testData {
+		text, ok := conn.addressed(&d.post, d.channelType)
+		if ok != d.ok || text != d.expect {
+			t.Fatalf("%q in %s: expected %q %v got %q %v", d.post.Message, d.channelType, d.expect, d.ok, text, ok)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	testData := []struct {
+		url string
+		err bool
+	}{
+		{"https://mattermost.example.com", false},
+		{"http://localhost:8065/", false},
+		{"mattermost.example.com", true},
+		{"ftp://mattermost.example.com", true},
+		{"https://", true},
+	}
+
+	for _, d := range testData {
+		_, err := newClient(d.url, "token", "", false)
+		if d.err && err == nil {
+			t.Fatalf("%q: expected error", d.url)
+		}
+		if !d.err && err != nil {
+			t.Fatalf("%q: unexpected error %v", d.url, err)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "mattermost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := writeCA(t, srv, dir)
+
+	empty := filepath.Join(dir, "empty.pem")
+	ioutil.WriteFile(empty, []byte("nothing here"), 0600)
+
+	testData := []struct {
+		ca       string
+		insecure bool
+		ok       bool
+	}{
+		// self signed certificates aren't trusted by default
+		{"", false, false},
+		{ca, false, true},
+		{"", true, true},
+	}
+
+	for _, d := range testData {
+		c, err := newClient(srv.URL, "token", d.ca, d.insecure)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.me()
+		if d.ok && err != nil {
+			t.Fatalf("ca %q insecure %v: unexpected error %v", d.ca, d.insecure, err)
+		}
+		if !d.ok && err == nil {
+			t.Fatalf("ca %q insecure %v: expected error", d.ca, d.insecure)
+		}
+	}
+
+	for _, f := range []string{empty, filepath.Join(dir, "missing.pem")} {
+		if _, err := newClient(srv.URL, "token", f, false); err == nil {
+			t.Fatalf("%s: expected error", f)
+		}
+	}
+}
+
+func TestMattermost(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "mattermost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	io := NewInput().(*mattermostInput)
+	io.url = srv.URL
+	io.token = "token"
+	io.teamName = "eng"
+	io.ca = writeCA(t, srv, dir)
+
+	if err := io.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer io.Stop()
+
+	c, err := io.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var ws *websocket.Conn
+	select {
+	case ws = <-srv.conns:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the websocket")
+	}
+
+	// ignored: not a mention, another team
+	send(t, ws, "T0ENG", "O", post{ID: "p0", ChannelID: "C", UserID: "u", Message: "ping"})
+	send(t, ws, "T0OTHER", "O", post{ID: "p1", ChannelID: "C", UserID: "u", Message: "@micro ping"})
+	send(t, ws, "T0ENG", "O", post{ID: "p2", ChannelID: "C", UserID: "u", RootID: "p9", Message: "@micro ping"})
+
+	var ev input.Event
+	if err := c.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "ping" || ev.From != "C:u" || ev.Meta[MetaPostID] != "p2" || ev.Meta[MetaRootID] != "p9" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	// replies are threaded with the command
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := <-srv.posts; p.ChannelID != "C" || p.RootID != "p9" || p.Message != "pong" {
+		t.Fatalf("unexpected post %+v", p)
+	}
+
+	// top level direct messages get top level replies
+	send(t, ws, "", "D", post{ID: "p3", ChannelID: "D", UserID: "u", Message: "ping"})
+
+	if err := c.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := <-srv.posts; p.ChannelID != "D" || len(p.RootID) > 0 {
+		t.Fatalf("unexpected post %+v", p)
+	}
+
+	// stopping closes the websocket
+	io.Stop()
+
+	if err := c.Recv(&ev); err == nil {
+		t.Fatal("expected an error once stopped")
+	}
+}
+
+func TestStartErrors(t *testing.T) {
+	srv := newTestServer()
+	defer srv.Close()
+
+	testData := []struct {
+		token  string
+		team   string
+		expect string
+	}{
+		{"wrong", "eng", "Invalid or expired session"},
+		{"token", "sales", "Unable to find the existing team"},
+	}
+
+	for _, d := range testData {
+		io := NewInput().(*mattermostInput)
+		io.url = srv.URL
+		io.token = d.token
+		io.teamName = d.team
+		io.insecure = true
+
+		if err := io.Start(); err == nil || !strings.Contains(err.Error(), d.expect) {
+			t.Fatalf("expected %q got %v", d.expect, err)
+		}
+	}
+}
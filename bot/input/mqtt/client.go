@@ -0,0 +1,377 @@
+package mqtt
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long to wait before reconnecting, doubled on each failure
+	reconnectBackoff = time.Second
+	// how long the broker has to acknowledge a reply
+	ackTimeout = 30 * time.Second
+)
+
+// config is how the client connects
+type config struct {
+	// the broker host:port
+	server    string
+	tls       *tls.Config
+	clientID  string
+	username  string
+	password  string
+	keepAlive time.Duration
+	// the request topic subscribed to and the QoS it's subscribed and
+	// replied with
+	topic string
+	qos   byte
+}
+
+// outgoing is a reply waiting for the broker to acknowledge it
+type outgoing struct {
+	msg   *publish
+	acked chan bool
+}
+
+// client maintains the session with the broker, reconnecting with
+// backoff. Sessions aren't clean so requests published while the bot
+// is disconnected are delivered once it's back.
+type client struct {
+	config
+
+	events chan *publish
+	exit   chan bool
+	done   chan bool
+
+	sync.Mutex
+	conn   net.Conn
+	nextID uint16
+	// replies published but not yet acknowledged, resent on reconnect
+	inflight map[uint16]*outgoing
+	// requests handed to the bot by packet ID whose puback may not have
+	// reached the broker. It redelivers them flagged as duplicates
+	// after a reconnect and they mustn't run twice.
+	pending map[uint16][sha1.Size]byte
+}
+
+func newClient(c config, exit chan bool) *client {
+	return &client{
+		config:   c,
+		events:   make(chan *publish),
+		exit:     exit,
+		done:     make(chan bool),
+		inflight: make(map[uint16]*outgoing),
+		pending:  make(map[uint16][sha1.Size]byte),
+	}
+}
+
+func (c *client) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+
+	if c.tls == nil {
+		return d.Dial("tcp", c.server)
+	}
+
+	return tls.DialWithDialer(d, "tcp", c.server, c.tls)
+}
+
+// write sends a packet on the current connection
+func (c *client) write(p *packet) error {
+	b, err := p.bytes()
+	if err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err = c.conn.Write(b)
+	return err
+}
+
+// id returns an unused packet ID, c must be locked
+func (c *client) id() uint16 {
+	for {
+		c.nextID++
+		if c.nextID == 0 {
+			continue
+		}
+		if _, ok := c.inflight[c.nextID]; !ok {
+			return c.nextID
+		}
+	}
+}
+
+// publish sends the payload to the topic, waiting for the broker to
+// acknowledge it when published with QoS 1
+func (c *client) publish(topic string, payload []byte) error {
+	msg := &publish{topic: topic, qos: c.qos, payload: payload}
+
+	if c.qos == 0 {
+		return c.write(msg.packet())
+	}
+
+	o := &outgoing{msg: msg, acked: make(chan bool, 1)}
+
+	c.Lock()
+	msg.id = c.id()
+	c.inflight[msg.id] = o
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.inflight, msg.id)
+		c.Unlock()
+	}()
+
+	// while disconnected it's sent on reconnect
+	if err := c.write(msg.packet()); err != nil {
+		log.Logf("[mqtt] error publishing to %s, retrying once reconnected: %v", topic, err)
+	}
+
+	select {
+	case <-o.acked:
+		return nil
+	case <-c.exit:
+		return fmt.Errorf("stopped before publishing to %s", topic)
+	case <-time.After(ackTimeout):
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+}
+
+// run connects and reconnects with backoff until exit is closed. conn
+// is the first connection, dialed to check the broker is reachable.
+func (c *client) run(conn net.Conn) {
+	defer close(c.done)
+
+	backoff := reconnectBackoff
+
+	for {
+		start := time.Now()
+
+		err := c.session(conn)
+		conn = nil
+
+		select {
+		case <-c.exit:
+			return
+		default:
+		}
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) > time.Minute {
+			backoff = reconnectBackoff
+		}
+
+		log.Logf("[mqtt] connection to %s lost: %v, reconnecting in %v", c.server, err, backoff)
+
+		select {
+		case <-c.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+
+		conn, err = c.dial()
+		if err != nil {
+			log.Logf("[mqtt] error connecting to %s: %v", c.server, err)
+			continue
+		}
+	}
+}
+
+// session connects and subscribes on the connection then handles it
+// until it fails
+func (c *client) session(conn net.Conn) error {
+	if conn == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	closed := make(chan bool)
+	defer func() {
+		close(closed)
+		conn.Close()
+		c.Lock()
+		c.conn = nil
+		c.Unlock()
+	}()
+
+	// unblock reads once exiting
+	go func() {
+		select {
+		case <-c.exit:
+			c.write(&packet{header: typeDisconnect << 4})
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	c.Lock()
+	c.conn = conn
+	c.Unlock()
+
+	r := bufio.NewReader(conn)
+
+	keepAlive := uint16(c.keepAlive / time.Second)
+	if err := c.write(connectPacket(c.clientID, c.username, c.password, keepAlive)); err != nil {
+		return err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+
+	p, err := readPacket(r)
+	if err != nil {
+		return err
+	}
+
+	resumed, err := parseConnack(p)
+	if err != nil {
+		return err
+	}
+
+	log.Logf("[mqtt] connected to %s, session resumed: %v", c.server, resumed)
+
+	c.Lock()
+	sub := subscribePacket(c.id(), c.topic, c.qos)
+	var resend []*publish
+	for _, o := range c.inflight {
+		resend = append(resend, o.msg)
+	}
+	c.Unlock()
+
+	if err := c.write(sub); err != nil {
+		return err
+	}
+
+	// replies the broker never acknowledged
+	for _, msg := range resend {
+		dup := *msg
+		dup.dup = true
+		if err := c.write(dup.packet()); err != nil {
+			return err
+		}
+	}
+
+	go c.ping(closed)
+
+	for {
+		// the broker answers pings so it's never quiet for long
+		conn.SetReadDeadline(time.Now().Add(2 * c.keepAlive))
+
+		p, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+
+		if err := c.handle(p); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) handle(p *packet) error {
+	switch p.kind() {
+	case typePublish:
+		msg, err := parsePublish(p)
+		if err != nil {
+			return err
+		}
+		return c.receive(msg)
+	case typePuback:
+		id, err := parseID(p)
+		if err != nil {
+			return err
+		}
+		c.Lock()
+		o, ok := c.inflight[id]
+		c.Unlock()
+		if ok {
+			select {
+			case o.acked <- true:
+			default:
+			}
+		}
+	case typeSuback:
+		_, qos, err := parseSuback(p)
+		if err != nil {
+			return err
+		}
+		if qos == 0x80 {
+			return fmt.Errorf("subscription to %s refused", c.topic)
+		}
+		log.Logf("[mqtt] subscribed to %s with QoS %d", c.topic, qos)
+	case typePingresp:
+	default:
+		return fmt.Errorf("unexpected packet type %d", p.kind())
+	}
+
+	return nil
+}
+
+// receive hands the request to the bot, acknowledging it once it has
+func (c *client) receive(msg *publish) error {
+	if msg.qos == 0 {
+		select {
+		case <-c.exit:
+		case c.events <- msg:
+		}
+		return nil
+	}
+
+	sum := sha1.Sum(msg.payload)
+
+	c.Lock()
+	delivered := msg.dup && c.pending[msg.id] == sum
+	c.Unlock()
+
+	if delivered {
+		log.Logf("[mqtt] ignoring redelivered message %d", msg.id)
+		return c.write(pubackPacket(msg.id))
+	}
+
+	select {
+	case <-c.exit:
+		return nil
+	case c.events <- msg:
+	}
+
+	// kept until the broker reuses the ID, only then is it known to
+	// have had the puback
+	c.Lock()
+	c.pending[msg.id] = sum
+	c.Unlock()
+
+	return c.write(pubackPacket(msg.id))
+}
+
+// ping keeps the session alive
+func (c *client) ping(closed chan bool) {
+	t := time.NewTicker(c.keepAlive)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-t.C:
+			if err := c.write(&packet{header: typePingreq << 4}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events
+const (
+	// MetaTopic is the topic the reply is published to
+	MetaTopic = "mqtt_topic"
+	// MetaCorrelationID is the ID of the request, empty when it wasn't
+	// sent as JSON
+	MetaCorrelationID = "mqtt_correlation_id"
+)
+
+// envelope is the JSON form of a request and its reply
+type envelope struct {
+	ID      string `json:"id"`
+	ReplyTo string `json:"reply_to,omitempty"`
+	Command string `json:"command,omitempty"`
+	Text    string `json:"text"`
+}
+
+// request is a command published to the request topic
+type request struct {
+	id      string
+	replyTo string
+	text    string
+	// replies to JSON requests are JSON too
+	json bool
+}
+
+// parseRequest parses "replyto|command args..." or a JSON envelope
+func parseRequest(payload []byte) (*request, error) {
+	text := strings.TrimSpace(string(payload))
+
+	var r request
+
+	if strings.HasPrefix(text, "{") {
+		var e envelope
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, errors.New("invalid json request: " + err.Error())
+		}
+		r = request{id: e.ID, replyTo: e.ReplyTo, text: e.Command, json: true}
+	} else {
+		parts := strings.SplitN(text, "|", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("expected replyto|command")
+		}
+		r = request{replyTo: parts[0], text: parts[1]}
+	}
+
+	r.replyTo = strings.Trim(strings.TrimSpace(r.replyTo), "/")
+	r.text = strings.TrimSpace(r.text)
+
+	if len(r.replyTo) == 0 {
+		return nil, errors.New("missing reply topic")
+	}
+
+	// replies can't be published to a filter
+	if strings.ContainsAny(r.replyTo, "+#") {
+		return nil, errors.New("reply topic can't contain wildcards")
+	}
+
+	if len(r.text) == 0 {
+		return nil, errors.New("missing command")
+	}
+
+	return &r, nil
+}
+
+// Satisfies the input.Conn interface
+type mqttConn struct {
+	client *client
+	prefix string
+	// request IDs already run
+	processed *processed
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, prefix string, p *processed) *mqttConn {
+	return &mqttConn{
+		client:    c,
+		prefix:    strings.TrimRight(prefix, "/"),
+		processed: p,
+		closed:    make(chan bool),
+	}
+}
+
+// topic returns the topic replies to the request are published to
+func (c *mqttConn) topic(r *request) string {
+	if len(c.prefix) == 0 {
+		return r.replyTo
+	}
+	return c.prefix + "/" + r.replyTo
+}
+
+func (c *mqttConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *mqttConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.client.exit:
+			return errors.New("mqtt input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case msg := <-c.client.events:
+			r, err := parseRequest(msg.payload)
+			if err != nil {
+				log.Logf("[mqtt] ignoring request on %s: %v", msg.topic, err)
+				continue
+			}
+
+			if len(r.id) > 0 && !c.processed.first(r.id) {
+				log.Logf("[mqtt] ignoring duplicate request %s", r.id)
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			event.From = r.replyTo
+			event.To = msg.topic
+			event.Type = input.TextEvent
+			event.Data = []byte(r.text)
+			event.Meta["reply"] = r
+			event.Meta[MetaTopic] = c.topic(r)
+			event.Meta[MetaCorrelationID] = r.id
+
+			return nil
+		}
+	}
+}
+
+// Send publishes the reply to the topic of the request
+func (c *mqttConn) Send(event *input.Event) error {
+	r, ok := event.Meta["reply"].(*request)
+	if !ok {
+		return errors.New("mqtt input can only reply to requests")
+	}
+
+	payload := event.Data
+	if r.json {
+		b, err := json.Marshal(envelope{ID: r.id, Text: string(event.Data)})
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	if err := c.client.publish(c.topic(r), payload); err != nil {
+		log.Logf("[mqtt] error replying to %s: %v", c.topic(r), err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,257 @@
+// Package mqtt is an MQTT input for the bot. Commands published to the
+// request topic as "replyto|command args..." or a JSON envelope are run
+// and their output published to the reply topic.
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// how long Stop waits for the client to disconnect
+var stopTimeout = 5 * time.Second
+
+type mqttInput struct {
+	config
+	prefix string
+
+	sync.Mutex
+	running   bool
+	exit      chan bool
+	client    *client
+	processed *processed
+}
+
+func init() {
+	input.Register("mqtt", NewInput())
+}
+
+func (p *mqttInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "mqtt_broker",
+			Usage: "MQTT broker url e.g tcp://localhost:1883 or ssl://broker:8883",
+			Value: "tcp://localhost:1883",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_username",
+			Usage: "Username to connect to the broker with",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_password",
+			Usage: "Password to connect to the broker with",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_client_id",
+			Usage: "Client ID the broker keeps the bot's session under",
+			Value: "micro-bot",
+		},
+		cli.BoolFlag{
+			Name:  "mqtt_tls",
+			Usage: "Connect to the broker using TLS, implied by ssl:// urls",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_tls_ca",
+			Usage: "CA certificate file to verify the broker with",
+		},
+		cli.BoolFlag{
+			Name:  "mqtt_tls_insecure",
+			Usage: "Skip verifying the broker's certificate",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_request_topic",
+			Usage: "Topic commands are published to",
+			Value: "micro/bot/req",
+		},
+		cli.StringFlag{
+			Name:  "mqtt_response_prefix",
+			Usage: "Prefix of the topics replies are published to",
+			Value: "micro/bot/resp",
+		},
+		cli.IntFlag{
+			Name:  "mqtt_qos",
+			Usage: "QoS of the subscription and replies, 0 or 1",
+			Value: 1,
+		},
+	}
+}
+
+// parseBroker returns the host:port of the broker url and whether it
+// uses TLS
+func parseBroker(broker string) (string, bool, error) {
+	u, err := url.Parse(broker)
+	if err != nil || len(u.Host) == 0 {
+		return "", false, errors.New("invalid mqtt broker, expected a url e.g tcp://localhost:1883")
+	}
+
+	var secure bool
+	switch u.Scheme {
+	case "tcp", "mqtt":
+	case "ssl", "tls", "mqtts":
+		secure = true
+	default:
+		return "", false, fmt.Errorf("unsupported mqtt broker scheme %s", u.Scheme)
+	}
+
+	host := u.Host
+	if len(u.Port()) == 0 {
+		port := "1883"
+		if secure {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return host, secure, nil
+}
+
+func (p *mqttInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	server, secure, err := parseBroker(cfg.String("mqtt_broker", "tcp://localhost:1883"))
+	if err != nil {
+		return err
+	}
+
+	clientID := cfg.String("mqtt_client_id", "micro-bot")
+	if len(clientID) == 0 {
+		// the broker can't keep the session of an anonymous client
+		return errors.New("missing mqtt client id")
+	}
+
+	topic := cfg.String("mqtt_request_topic", "micro/bot/req")
+	if len(topic) == 0 {
+		return errors.New("missing mqtt request topic")
+	}
+
+	qos := cfg.Int("mqtt_qos", 1)
+	if qos != 0 && qos != 1 {
+		return errors.New("mqtt qos must be 0 or 1")
+	}
+
+	if secure || cfg.Bool("mqtt_tls", false) {
+		host, _, _ := net.SplitHostPort(server)
+		c := &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: cfg.Bool("mqtt_tls_insecure", false),
+		}
+
+		if ca := cfg.String("mqtt_tls_ca", ""); len(ca) > 0 {
+			b, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(b) {
+				return fmt.Errorf("no certificates found in %s", ca)
+			}
+			c.RootCAs = pool
+		}
+
+		p.tls = c
+	}
+
+	p.server = server
+	p.clientID = clientID
+	p.username = cfg.String("mqtt_username", "")
+	p.password = cfg.String("mqtt_password", "")
+	p.topic = topic
+	p.qos = byte(qos)
+	p.prefix = strings.TrimSpace(cfg.String("mqtt_response_prefix", "micro/bot/resp"))
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *mqttInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.prefix, p.processed), nil
+}
+
+func (p *mqttInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.server) == 0 || len(p.topic) == 0 {
+		return errors.New("missing mqtt configuration")
+	}
+
+	exit := make(chan bool)
+	c := newClient(p.config, exit)
+
+	// fail fast if the broker can't be reached
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	go c.run(conn)
+
+	p.exit = exit
+	p.client = c
+	p.running = true
+
+	return nil
+}
+
+func (p *mqttInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	select {
+	case <-p.client.done:
+	case <-time.After(stopTimeout):
+		log.Logf("[mqtt] timed out waiting to disconnect from %s", p.server)
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *mqttInput) String() string {
+	return "mqtt"
+}
+
+func NewInput() input.Input {
+	return &mqttInput{
+		config: config{
+			clientID:  "micro-bot",
+			keepAlive: time.Minute,
+			topic:     "micro/bot/req",
+			qos:       1,
+		},
+		prefix:    "micro/bot/resp",
+		processed: newProcessed(processedSize),
+	}
+}
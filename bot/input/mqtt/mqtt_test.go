@@ -0,0 +1,316 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// brokerConn is the bot's connection to the test broker
+type brokerConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (b *brokerConn) write(p *packet) {
+	data, err := p.bytes()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	if _, err := b.conn.Write(data); err != nil {
+		b.t.Fatal(err)
+	}
+}
+
+// read returns the next packet, answering pings
+func (b *brokerConn) read() *packet {
+	for {
+		b.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		p, err := readPacket(b.r)
+		if err != nil {
+			b.t.Fatalf("error reading packet: %v", err)
+		}
+		if p.kind() == typePingreq {
+			b.write(&packet{header: typePingresp << 4})
+			continue
+		}
+		return p
+	}
+}
+
+// accept expects the bot to connect with a persistent session and
+// subscribe to the request topic
+func accept(t *testing.T, l net.Listener, resumed bool) *brokerConn {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &brokerConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	p := b.read()
+	if p.kind() != typeConnect {
+		t.Fatalf("expected connect got %d", p.kind())
+	}
+
+	name, rest, _ := readString(p.body)
+	if name != "MQTT" || rest[0] != protocolLevel {
+		t.Fatalf("unexpected protocol %s %d", name, rest[0])
+	}
+
+	// username and password but not a clean session
+	if flags := rest[1]; flags != 0xc0 {
+		t.Fatalf("unexpected connect flags %08b", flags)
+	}
+
+	id, rest, _ := readString(rest[4:])
+	user, rest, _ := readString(rest)
+	pass, _, _ := readString(rest)
+	if id != "micro-bot" || user != "micro" || pass != "secret" {
+		t.Fatalf("unexpected credentials %s %s %s", id, user, pass)
+	}
+
+	var present byte
+	if resumed {
+		present = 1
+	}
+	b.write(&packet{header: typeConnack << 4, body: []byte{present, 0}})
+
+	p = b.read()
+	if p.kind() != typeSubscribe || p.flags() != 0x02 {
+		t.Fatalf("expected subscribe got %d", p.kind())
+	}
+	topic, rest, _ := readString(p.body[2:])
+	if topic != "micro/bot/req" || rest[0] != 1 {
+		t.Fatalf("unexpected subscription %s QoS %d", topic, rest[0])
+	}
+	b.write(&packet{header: typeSuback << 4, body: []byte{p.body[0], p.body[1], 1}})
+
+	return b
+}
+
+// request publishes the payload to the bot returning its reply once
+// the request's been acknowledged
+func (b *brokerConn) request(id uint16, dup bool, payload string) *publish {
+	b.write((&publish{id: id, topic: "micro/bot/req", qos: 1, dup: dup, payload: []byte(payload)}).packet())
+
+	var acked bool
+	var reply *publish
+
+	for !acked || reply == nil {
+		p := b.read()
+		switch p.kind() {
+		case typePuback:
+			if got, _ := parseID(p); got != id {
+				b.t.Fatalf("expected puback of %d got %d", id, got)
+			}
+			acked = true
+		case typePublish:
+			m, err := parsePublish(p)
+			if err != nil {
+				b.t.Fatal(err)
+			}
+			reply = m
+		default:
+			b.t.Fatalf("unexpected packet %d", p.kind())
+		}
+	}
+
+	return reply
+}
+
+// answer replies to each command with its text
+func answer(c input.Conn) {
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+		go c.Send(&input.Event{To: ev.From, Meta: ev.Meta, Type: input.TextEvent, Data: []byte(ev.From + " ran " + string(ev.Data))})
+	}
+}
+
+func TestPackets(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		p := &packet{header: typePublish << 4, body: make([]byte, n)}
+		b, err := p.bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil || len(got.body) != n || got.header != p.header {
+			t.Fatalf("%d bytes: unexpected packet %v", n, err)
+		}
+	}
+
+	m := &publish{id: 42, topic: "a/b", qos: 1, dup: true, payload: []byte("hello")}
+	got, err := parsePublish(m.packet())
+	if err != nil || got.id != 42 || got.topic != "a/b" || got.qos != 1 || !got.dup || string(got.payload) != "hello" {
+		t.Fatalf("unexpected publish %+v %v", got, err)
+	}
+
+	if _, err := parseConnack(&packet{header: typeConnack << 4, body: []byte{0, 4}}); err == nil {
+		t.Fatal("expected bad credentials to be refused")
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	testData := []struct {
+		payload string
+		expect  *request
+		err     bool
+	}{
+		{"device/1|deploy api", &request{replyTo: "device/1", text: "deploy api"}, false},
+		{"  ci | ping  ", &request{replyTo: "ci", text: "ping"}, false},
+		// pipes in the command are kept
+		{"ci|echo a|b", &request{replyTo: "ci", text: "echo a|b"}, false},
+		{`{"id":"abc","reply_to":"ci","command":"ping"}`, &request{id: "abc", replyTo: "ci", text: "ping", json: true}, false},
+		{"ping", nil, true},
+		{"|ping", nil, true},
+		{"ci|", nil, true},
+		{"ci/#|ping", nil, true},
+		{`{"id":"abc","command":"ping"}`, nil, true},
+		{`{"id":`, nil, true},
+	}
+
+	for _, d := range testData {
+		r, err := parseRequest([]byte(d.payload))
+		if d.err {
+			if err == nil {
+				t.Fatalf("%q: expected an error got %+v", d.payload, r)
+			}
+			continue
+		}
+		if err != nil || *r != *d.expect {
+			t.Fatalf("%q: expected %+v got %+v %v", d.payload, d.expect, r, err)
+		}
+	}
+}
+
+func TestParseBroker(t *testing.T) {
+	testData := []struct {
+		broker string
+		server string
+		tls    bool
+	}{
+		{"tcp://localhost:1883", "localhost:1883", false},
+		{"mqtt://broker", "broker:1883", false},
+		{"ssl://broker", "broker:8883", true},
+		{"mqtts://broker:8884", "broker:8884", true},
+	}
+
+	for _, d := range testData {
+		server, secure, err := parseBroker(d.broker)
+		if err != nil || server != d.server || secure != d.tls {
+			t.Fatalf("%s: expected %s %v got %s %v %v", d.broker, d.server, d.tls, server, secure, err)
+		}
+	}
+
+	for _, broker := range []string{"localhost:1883", "http://broker", ""} {
+		if _, _, err := parseBroker(broker); err == nil {
+			t.Fatalf("%s: expected an error", broker)
+		}
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	oldBackoff := reconnectBackoff
+	reconnectBackoff = 10 * time.Millisecond
+	defer func() { reconnectBackoff = oldBackoff }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	p := NewInput().(*mqttInput)
+	p.server = l.Addr().String()
+	p.username = "micro"
+	p.password = "secret"
+	p.keepAlive = time.Second
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c)
+
+	b := accept(t, l, false)
+
+	reply := b.request(1, false, "device/1|ping")
+	if reply.topic != "micro/bot/resp/device/1" || reply.qos != 1 || string(reply.payload) != "device/1 ran ping" {
+		t.Fatalf("unexpected reply %s %q", reply.topic, reply.payload)
+	}
+	b.write(pubackPacket(reply.id))
+
+	reply = b.request(2, false, `{"id":"abc","reply_to":"ci","command":"deploy api"}`)
+	var e envelope
+	json.Unmarshal(reply.payload, &e)
+	if reply.topic != "micro/bot/resp/ci" || e.ID != "abc" || e.Text != "ci ran deploy api" {
+		t.Fatalf("unexpected reply %s %q", reply.topic, reply.payload)
+	}
+	b.write(pubackPacket(reply.id))
+
+	// the client retrying its request isn't run again
+	b.write((&publish{id: 3, topic: "micro/bot/req", qos: 1, payload: []byte(`{"id":"abc","reply_to":"ci","command":"deploy api"}`)}).packet())
+	if p := b.read(); p.kind() != typePuback {
+		t.Fatalf("expected puback got %d", p.kind())
+	}
+
+	// the connection drops before the reply is acknowledged, as far as
+	// the broker knows the request wasn't either
+	reply = b.request(4, false, "device/1|restart")
+	b.conn.Close()
+
+	b = accept(t, l, true)
+
+	// the reply is resent
+	p2 := b.read()
+	resent, err := parsePublish(p2)
+	if err != nil || !resent.dup || resent.id != reply.id || string(resent.payload) != "device/1 ran restart" {
+		t.Fatalf("expected the reply to be resent got %+v %v", resent, err)
+	}
+	b.write(pubackPacket(resent.id))
+
+	// the redelivered request isn't run again
+	b.write((&publish{id: 4, topic: "micro/bot/req", qos: 1, dup: true, payload: []byte("device/1|restart")}).packet())
+	if p := b.read(); p.kind() != typePuback {
+		t.Fatalf("expected puback got %d", p.kind())
+	}
+
+	// the next reply is to the next request
+	reply = b.request(4, false, "device/1|status")
+	if string(reply.payload) != "device/1 ran status" {
+		t.Fatalf("unexpected reply %q", reply.payload)
+	}
+	b.write(pubackPacket(reply.id))
+
+	// keepalive pings are answered by read
+	time.Sleep(1500 * time.Millisecond)
+	reply = b.request(5, false, "device/1|ping")
+	if string(reply.payload) != "device/1 ran ping" {
+		t.Fatalf("unexpected reply %q", reply.payload)
+	}
+	b.write(pubackPacket(reply.id))
+
+	p.Stop()
+
+	if p := b.read(); p.kind() != typeDisconnect {
+		t.Fatalf("expected disconnect got %d", p.kind())
+	}
+}
@@ -0,0 +1,242 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	typeConnect     = 1
+	typeConnack     = 2
+	typePublish     = 3
+	typePuback      = 4
+	typeSubscribe   = 8
+	typeSuback      = 9
+	typePingreq     = 12
+	typePingresp    = 13
+	typeDisconnect  = 14
+	protocolLevel   = 4
+	maxRemainingLen = 268435455
+)
+
+// connack return codes
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad username or password",
+	5: "not authorized",
+}
+
+// packet is a control packet read from or written to the broker
+type packet struct {
+	// the packet type in the high nibble and its flags in the low
+	header byte
+	body   []byte
+}
+
+func (p *packet) kind() byte {
+	return p.header >> 4
+}
+
+func (p *packet) flags() byte {
+	return p.header & 0x0f
+}
+
+// readPacket reads the next control packet
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	// the remaining length is 7 bits per byte, least significant first
+	var length, shift uint
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length |= uint(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &packet{header: header, body: body}, nil
+}
+
+// bytes returns the packet as sent on the wire
+func (p *packet) bytes() ([]byte, error) {
+	if len(p.body) > maxRemainingLen {
+		return nil, fmt.Errorf("packet of %d bytes is too large", len(p.body))
+	}
+
+	b := []byte{p.header}
+	n := len(p.body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(b, p.body...), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)>>8), byte(len(s)))
+	return append(b, s...)
+}
+
+// readString returns the length prefixed string at the start of b and
+// what follows it
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("malformed string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("malformed string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// connectPacket asks the broker for a session. Sessions aren't clean
+// so the broker keeps subscriptions, and queues QoS 1 messages, while
+// the bot is disconnected.
+func connectPacket(clientID, username, password string, keepAlive uint16) *packet {
+	var flags byte
+	if len(username) > 0 {
+		flags |= 0x80
+	}
+	if len(password) > 0 {
+		flags |= 0x40
+	}
+
+	b := appendString(nil, "MQTT")
+	b = append(b, protocolLevel, flags, byte(keepAlive>>8), byte(keepAlive))
+	b = appendString(b, clientID)
+	if len(username) > 0 {
+		b = appendString(b, username)
+	}
+	if len(password) > 0 {
+		b = appendString(b, password)
+	}
+
+	return &packet{header: typeConnect << 4, body: b}
+}
+
+// parseConnack returns whether the broker had a session for the bot
+func parseConnack(p *packet) (bool, error) {
+	if p.kind() != typeConnack || len(p.body) != 2 {
+		return false, fmt.Errorf("expected connack got packet type %d", p.kind())
+	}
+
+	if code := p.body[1]; code != 0 {
+		if msg, ok := connackErrors[code]; ok {
+			return false, fmt.Errorf("connection refused: %s", msg)
+		}
+		return false, fmt.Errorf("connection refused: code %d", code)
+	}
+
+	return p.body[0]&0x01 == 1, nil
+}
+
+// subscribePacket subscribes to the topic filter with the max QoS
+func subscribePacket(id uint16, topic string, qos byte) *packet {
+	b := []byte{byte(id >> 8), byte(id)}
+	b = appendString(b, topic)
+	b = append(b, qos)
+	// the reserved bits of subscribe must be 0010
+	return &packet{header: typeSubscribe<<4 | 0x02, body: b}
+}
+
+// parseSuback returns the packet ID and QoS granted to the subscription
+func parseSuback(p *packet) (uint16, byte, error) {
+	if len(p.body) < 3 {
+		return 0, 0, errors.New("malformed suback")
+	}
+	return binary.BigEndian.Uint16(p.body), p.body[2], nil
+}
+
+// publish is a message published to or by the bot
+type publish struct {
+	id      uint16
+	topic   string
+	qos     byte
+	dup     bool
+	payload []byte
+}
+
+func (m *publish) packet() *packet {
+	header := byte(typePublish<<4) | m.qos<<1
+	if m.dup {
+		header |= 0x08
+	}
+
+	b := appendString(nil, m.topic)
+	if m.qos > 0 {
+		b = append(b, byte(m.id>>8), byte(m.id))
+	}
+
+	return &packet{header: header, body: append(b, m.payload...)}
+}
+
+func parsePublish(p *packet) (*publish, error) {
+	m := &publish{
+		qos: (p.flags() >> 1) & 0x03,
+		dup: p.flags()&0x08 != 0,
+	}
+
+	if m.qos > 1 {
+		return nil, fmt.Errorf("unsupported QoS %d", m.qos)
+	}
+
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return nil, err
+	}
+	m.topic = topic
+
+	if m.qos > 0 {
+		if len(rest) < 2 {
+			return nil, errors.New("malformed publish")
+		}
+		m.id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+
+	m.payload = rest
+	return m, nil
+}
+
+func pubackPacket(id uint16) *packet {
+	return &packet{header: typePuback << 4, body: []byte{byte(id >> 8), byte(id)}}
+}
+
+// parseID returns the packet ID of a puback
+func parseID(p *packet) (uint16, error) {
+	if len(p.body) < 2 {
+		return 0, errors.New("malformed packet")
+	}
+	return binary.BigEndian.Uint16(p.body), nil
+}
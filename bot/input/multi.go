@@ -0,0 +1,249 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetaInput is the key of the name of the input an event received
+// through a MultiConn came from
+const MetaInput = "input"
+
+// MultiConn is a conn over the conns of several inputs. Send fans out
+// to all of them and Recv merges the events they receive.
+//
+// Events from a conn are received in the order it received them but
+// there's no order across conns. Each conn is sent events in the order
+// of the calls to Send. A conn still sending the last event is skipped
+// rather than queued to, so a broken conn doesn't hold up the others.
+type MultiConn struct {
+	names []string
+	conns map[string]Conn
+	to    map[string]string
+
+	// how long Send waits for each conn, zero waits until they're done
+	Timeout time.Duration
+
+	mtx  sync.Mutex
+	busy map[string]bool
+	errs map[string]error
+	live int
+
+	events chan Event
+	// closed once every conn has failed to receive
+	dead chan bool
+	exit chan bool
+	once sync.Once
+}
+
+// MultiError is the errors of the conns of a MultiConn keyed by the
+// name of their input
+type MultiError map[string]error
+
+func (m MultiError) Error() string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []string
+	for _, name := range names {
+		errs = append(errs, name+": "+m[name].Error())
+	}
+	return strings.Join(errs, "; ")
+}
+
+// NewMultiConn returns a conn over the conns keyed by the name of
+// their input. An event sent to an input in to is sent to its
+// destination there, such as a channel, rather than the event's To.
+func NewMultiConn(conns map[string]Conn, to map[string]string) *MultiConn {
+	m := &MultiConn{
+		conns:  conns,
+		to:     to,
+		busy:   make(map[string]bool),
+		errs:   make(map[string]error),
+		live:   len(conns),
+		events: make(chan Event),
+		dead:   make(chan bool),
+		exit:   make(chan bool),
+	}
+
+	for name := range conns {
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+
+	for _, name := range m.names {
+		go m.recv(name, conns[name])
+	}
+
+	return m
+}
+
+// recv passes on the events the conn receives until it fails
+func (m *MultiConn) recv(name string, c Conn) {
+	for {
+		var ev Event
+		if err := c.Recv(&ev); err != nil {
+			m.mtx.Lock()
+			m.errs[name] = err
+			m.live--
+			if m.live == 0 {
+				close(m.dead)
+			}
+			m.mtx.Unlock()
+			return
+		}
+
+		meta := make(map[string]interface{}, len(ev.Meta)+1)
+		for k, v := range ev.Meta {
+			meta[k] = v
+		}
+		meta[MetaInput] = name
+		ev.Meta = meta
+
+		select {
+		case m.events <- ev:
+		case <-m.exit:
+			return
+		}
+	}
+}
+
+// Send sends the event through every conn, returning a MultiError of
+// those it couldn't be sent through
+func (m *MultiConn) Send(event *Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-m.exit:
+		return errors.New("connection closed")
+	default:
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	results := make(chan result, len(m.names))
+	errs := MultiError{}
+	pending := make(map[string]bool)
+
+	for _, name := range m.names {
+		m.mtx.Lock()
+		busy := m.busy[name]
+		m.busy[name] = true
+		m.mtx.Unlock()
+
+		if busy {
+			errs[name] = errors.New("still sending the last event")
+			continue
+		}
+
+		ev := *event
+		if to, ok := m.to[name]; ok {
+			ev.To = to
+		}
+		// conns may add to the meta
+		if event.Meta != nil {
+			ev.Meta = make(map[string]interface{}, len(event.Meta))
+			for k, v := range event.Meta {
+				ev.Meta[k] = v
+			}
+		}
+
+		pending[name] = true
+
+		go func(name string, c Conn) {
+			err := c.Send(&ev)
+
+			m.mtx.Lock()
+			m.busy[name] = false
+			m.mtx.Unlock()
+
+			results <- result{name, err}
+		}(name, m.conns[name])
+	}
+
+	var timeout <-chan time.Time
+	if m.Timeout > 0 {
+		t := time.NewTimer(m.Timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	for len(pending) > 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.name)
+			if r.err != nil {
+				errs[r.name] = r.err
+			}
+		case <-timeout:
+			// those yet to finish are left to it
+			for name := range pending {
+				errs[name] = fmt.Errorf("send timed out after %v", m.Timeout)
+			}
+			pending = nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Recv receives the next event from any of the conns, its input is
+// set in the meta by MetaInput. It fails once all of them have.
+func (m *MultiConn) Recv(event *Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case ev := <-m.events:
+		*event = ev
+		return nil
+	case <-m.dead:
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		errs := MultiError{}
+		for name, err := range m.errs {
+			errs[name] = err
+		}
+		return errs
+	case <-m.exit:
+		return errors.New("connection closed")
+	}
+}
+
+// Close closes every conn, returning a MultiError of those which failed
+func (m *MultiConn) Close() error {
+	errs := MultiError{}
+
+	m.once.Do(func() {
+		close(m.exit)
+
+		for _, name := range m.names {
+			if err := m.conns[name].Close(); err != nil {
+				errs[name] = err
+			}
+		}
+	})
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// chanConn sends to and receives from channels until closed
+type chanConn struct {
+	send chan *Event
+	recv chan *Event
+	exit chan bool
+	once sync.Once
+	// returned by send if set
+	err error
+}
+
+func newChanConn() *chanConn {
+	return &chanConn{
+		send: make(chan *Event, 10),
+		recv: make(chan *Event),
+		exit: make(chan bool),
+	}
+}
+
+func (c *chanConn) Close() error {
+	c.once.Do(func() {
+		close(c.exit)
+	})
+	return nil
+}
+
+func (c *chanConn) Send(ev *Event) error {
+	if c.err != nil {
+		return c.err
+	}
+	select {
+	case <-c.exit:
+		return errors.New("connection closed")
+	case c.send <- ev:
+		return nil
+	}
+}
+
+func (c *chanConn) Recv(ev *Event) error {
+	select {
+	case <-c.exit:
+		return errors.New("connection closed")
+	case e := <-c.recv:
+		*ev = *e
+		return nil
+	}
+}
+
+func TestMultiConnSend(t *testing.T) {
+	slack, telegram, broken := newChanConn(), newChanConn(), newChanConn()
+	broken.err = errors.New("boom")
+
+	m := NewMultiConn(map[string]Conn{
+		"slack":    slack,
+		"telegram": telegram,
+		"broken":   broken,
+	}, map[string]string{
+		"slack":    "C0OPS",
+		"telegram": "12345",
+	})
+	defer m.Close()
+
+	err := m.Send(&Event{Type: TextEvent, To: "nowhere", Data: []byte("deployed"), Meta: map[string]interface{}{}})
+	if err == nil || err.Error() != "broken: boom" {
+		t.Fatalf("expected the broken conn to fail got %v", err)
+	}
+	if errs, ok := err.(MultiError); !ok || len(errs) != 1 {
+		t.Fatalf("expected a MultiError got %#v", err)
+	}
+
+	var sent []*Event
+	for _, c := range []*chanConn{slack, telegram} {
+		sent = append(sent, <-c.send)
+	}
+
+	if sent[0].To != "C0OPS" || sent[1].To != "12345" || string(sent[0].Data) != "deployed" || string(sent[1].Data) != "deployed" {
+		t.Fatalf("unexpected events %+v %+v", sent[0], sent[1])
+	}
+
+	// each conn has its own copy of the meta
+	sent[0].Meta["ts"] = "1"
+	if _, ok := sent[1].Meta["ts"]; ok {
+		t.Fatal("expected the meta to be copied")
+	}
+}
+
+func TestMultiConnSendBlocked(t *testing.T) {
+	a, stuck := newChanConn(), newChanConn()
+	// sends block until the test receives them
+	stuck.send = make(chan *Event)
+
+	m := NewMultiConn(map[string]Conn{"a": a, "stuck": stuck}, nil)
+	m.Timeout = 50 * time.Millisecond
+	defer m.Close()
+
+	start := time.Now()
+	err := m.Send(&Event{Type: TextEvent, To: "C0", Data: []byte("1")})
+	if err == nil || !strings.Contains(err.Error(), "stuck: send timed out") {
+		t.Fatalf("expected stuck to time out got %v", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("send took %v", d)
+	}
+
+	// skipped while still sending rather than queued behind
+	err = m.Send(&Event{Type: TextEvent, To: "C0", Data: []byte("2")})
+	if err == nil || err.Error() != "stuck: still sending the last event" {
+		t.Fatalf("expected stuck to be skipped got %v", err)
+	}
+
+	for _, data := range []string{"1", "2"} {
+		select {
+		case ev := <-a.send:
+			if string(ev.Data) != data || ev.To != "C0" {
+				t.Fatalf("expected %s to C0 got %+v", data, ev)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s", data)
+		}
+	}
+
+	// once unblocked it's sent to again
+	<-stuck.send
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mtx.Lock()
+		busy := m.busy["stuck"]
+		m.mtx.Unlock()
+		if !busy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected stuck to finish sending")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	go func() { <-stuck.send }()
+	if err := m.Send(&Event{Type: TextEvent, Data: []byte("3")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultiConnRecv(t *testing.T) {
+	a, b := newChanConn(), newChanConn()
+
+	m := NewMultiConn(map[string]Conn{"a": a, "b": b}, nil)
+	defer m.Close()
+
+	n := 20
+
+	for _, c := range []*chanConn{a, b} {
+		go func(c *chanConn) {
+			for i := 0; i < n; i++ {
+				c.recv <- &Event{Type: TextEvent, Data: []byte(fmt.Sprint(i))}
+			}
+		}(c)
+	}
+
+	// in order for each conn, interleaved in any order
+	next := map[string]int{}
+	for i := 0; i < 2*n; i++ {
+		var ev Event
+		if err := m.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		name, _ := ev.Meta[MetaInput].(string)
+		if string(ev.Data) != fmt.Sprint(next[name]) {
+			t.Fatalf("expected %d from %q got %s", next[name], name, ev.Data)
+		}
+		next[name]++
+	}
+
+	if next["a"] != n || next["b"] != n {
+		t.Fatalf("unexpected events %v", next)
+	}
+
+	// one conn failing leaves the other
+	a.Close()
+	go func() {
+		b.recv <- &Event{Type: TextEvent, Data: []byte("still here")}
+	}()
+
+	var ev Event
+	if err := m.Recv(&ev); err != nil || string(ev.Data) != "still here" {
+		t.Fatalf("expected an event from b got %+v %v", ev, err)
+	}
+
+	// until they all have
+	b.Close()
+	err := m.Recv(&ev)
+	if errs, ok := err.(MultiError); !ok || len(errs) != 2 {
+		t.Fatalf("expected both conns to have failed got %v", err)
+	}
+}
+
+func TestMultiConnClose(t *testing.T) {
+	a, b := newChanConn(), newChanConn()
+
+	m := NewMultiConn(map[string]Conn{"a": a, "b": b}, nil)
+
+	done := make(chan error)
+	go func() {
+		var ev Event
+		done <- m.Recv(&ev)
+	}()
+
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected recv to fail once closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected recv to return once closed")
+	}
+
+	for _, c := range []*chanConn{a, b} {
+		select {
+		case <-c.exit:
+		default:
+			t.Fatal("expected the conns to be closed")
+		}
+	}
+
+	if err := m.Send(&Event{Type: TextEvent}); err == nil {
+		t.Fatal("expected send to fail once closed")
+	}
+}
@@ -0,0 +1,212 @@
+package rocketchat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type user struct {
+	ID       string `json:"_id"`
+	Username string `json:"username"`
+	Name     string `json:"name,omitempty"`
+}
+
+type room struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+	// c for channels, p for private groups and d for direct messages
+	Type string `json:"t"`
+}
+
+type message struct {
+	ID     string `json:"_id,omitempty"`
+	RoomID string `json:"rid"`
+	Text   string `json:"msg"`
+	// the first message of the thread, empty for top level messages
+	ThreadID string `json:"tmid,omitempty"`
+	// system messages such as joins have a type
+	Type string `json:"t,omitempty"`
+	User *user  `json:"u,omitempty"`
+}
+
+// apiError is returned when the server refuses a request
+type apiError struct {
+	StatusCode int    `json:"-"`
+	Err        string `json:"error"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	msg := e.Message
+	if len(msg) == 0 {
+		msg = e.Err
+	}
+	return fmt.Sprintf("rocketchat error %d: %s", e.StatusCode, msg)
+}
+
+// session is the result of logging in
+type session struct {
+	UserID    string `json:"userId"`
+	AuthToken string `json:"authToken"`
+	Me        user   `json:"me"`
+}
+
+// client calls the REST api of a server
+type client struct {
+	url  string
+	http *http.Client
+
+	// set once logged in
+	userID string
+	token  string
+}
+
+func newClient(server string) (*client, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rocketchat url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid rocketchat url %s: expected an http or https scheme", server)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid rocketchat url %s: missing host", server)
+	}
+
+	return &client{
+		url:  strings.TrimSuffix(server, "/"),
+		http: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *client) do(method, path string, body, v interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.url+"/api"+path, r)
+	if err != nil {
+		return err
+	}
+
+	if len(c.token) > 0 {
+		req.Header.Set("X-User-Id", c.userID)
+		req.Header.Set("X-Auth-Token", c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		e := &apiError{StatusCode: rsp.StatusCode}
+		json.NewDecoder(rsp.Body).Decode(e)
+		if len(e.Message) == 0 && len(e.Err) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		return e
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
+
+// login authenticates with a personal access token if given, otherwise
+// the username and password
+func (c *client) login(username, password, token string) (*session, error) {
+	body := map[string]string{"user": username, "password": password}
+	if len(token) > 0 {
+		body = map[string]string{"resume": token}
+	}
+
+	var rsp struct {
+		Data session `json:"data"`
+	}
+	if err := c.do("POST", "/v1/login", body, &rsp); err != nil {
+		return nil, err
+	}
+
+	c.userID = rsp.Data.UserID
+	c.token = rsp.Data.AuthToken
+
+	return &rsp.Data, nil
+}
+
+// threads returns true if the server is new enough to support threads
+func (c *client) threads() (bool, error) {
+	var rsp struct {
+		Version string `json:"version"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	if err := c.do("GET", "/info", nil, &rsp); err != nil {
+		return false, err
+	}
+
+	version := rsp.Version
+	if len(version) == 0 {
+		version = rsp.Info.Version
+	}
+
+	// threads arrived in 1.0
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return false, fmt.Errorf("unknown rocketchat version %q", version)
+	}
+
+	return major >= 1, nil
+}
+
+func (c *client) room(name string) (*room, error) {
+	var rsp struct {
+		Room room `json:"room"`
+	}
+	if err := c.do("GET", "/v1/rooms.info?roomName="+url.QueryEscape(name), nil, &rsp); err != nil {
+		return nil, err
+	}
+	return &rsp.Room, nil
+}
+
+func (c *client) user(id string) (*user, error) {
+	var rsp struct {
+		User user `json:"user"`
+	}
+	if err := c.do("GET", "/v1/users.info?userId="+url.QueryEscape(id), nil, &rsp); err != nil {
+		return nil, err
+	}
+	return &rsp.User, nil
+}
+
+func (c *client) sendMessage(m *message) error {
+	return c.do("POST", "/v1/chat.sendMessage", map[string]*message{"message": m}, nil)
+}
+
+// websocketURL is where the realtime api is served
+func (c *client) websocketURL() string {
+	return "ws" + strings.TrimPrefix(c.url, "http") + "/websocket"
+}
@@ -0,0 +1,248 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaRoom and
+// MetaThreadID on outgoing events falling back to Event.To.
+const (
+	// MetaRoom is the ID of the room the message was sent in
+	MetaRoom = "rocketchat_room"
+	// MetaUser is the ID of the user who sent the message
+	MetaUser = "rocketchat_user"
+	// MetaUsername is the username of the user who sent the message
+	MetaUsername = "rocketchat_username"
+	// MetaMessageID is the ID of the message
+	MetaMessageID = "rocketchat_message_id"
+	// MetaThreadID is the thread the message was sent in, empty for top
+	// level messages
+	MetaThreadID = "rocketchat_thread_id"
+)
+
+// max length of a message, the server's default limit
+var maxMessageSize = 5000
+
+// Satisfies the input.Conn interface
+type rocketchatConn struct {
+	client *client
+	me     *user
+	ddp    *ddp
+	// the channels to answer in, nil for every room the bot is in
+	rooms map[string]bool
+	// whether replies can be threaded
+	threads   bool
+	users     *userCache
+	processed *processed
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, me *user, d *ddp, rooms map[string]bool, threads bool, users *userCache, p *processed, exit chan bool) *rocketchatConn {
+	conn := &rocketchatConn{
+		client:    c,
+		me:        me,
+		ddp:       d,
+		rooms:     rooms,
+		threads:   threads,
+		users:     users,
+		processed: p,
+		exit:      exit,
+		closed:    make(chan bool),
+	}
+
+	go conn.run()
+
+	return conn
+}
+
+// run pings the server until closed, closing the session on exit
+func (c *rocketchatConn) run() {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.exit:
+			c.Close()
+			return
+		case <-c.closed:
+			return
+		case <-t.C:
+			if err := c.ddp.ping(); err != nil {
+				log.Logf("[rocketchat] error pinging server: %v", err)
+			}
+		}
+	}
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Messages must start with an @mention of the bot unless they're
+// direct messages.
+func (c *rocketchatConn) addressed(m *message, info *roomInfo) (string, bool) {
+	if m.User == nil || m.User.ID == c.me.ID || len(m.Type) > 0 {
+		return "", false
+	}
+
+	text := strings.TrimSpace(m.Text)
+
+	command, mentioned := text, false
+	mention := "@" + c.me.Username
+	if len(text) >= len(mention) && strings.EqualFold(text[:len(mention)], mention) {
+		rest := text[len(mention):]
+		if len(rest) == 0 || strings.ContainsAny(rest[:1], " ,:") {
+			command, mentioned = strings.TrimLeft(rest, " ,:"), true
+		}
+	}
+
+	if info.RoomType == "d" {
+		return command, true
+	}
+
+	// other channels the bot has been added to
+	if c.rooms != nil && !c.rooms[m.RoomID] {
+		return "", false
+	}
+
+	if mentioned {
+		return command, true
+	}
+
+	return "", false
+}
+
+// decode returns the message published to the bot's stream
+func decode(d *ddpMessage) (*message, *roomInfo, bool) {
+	if d.Msg != "changed" || d.Collection != "stream-room-messages" || len(d.Fields.Args) == 0 {
+		return nil, nil, false
+	}
+
+	var m message
+	if err := json.Unmarshal(d.Fields.Args[0], &m); err != nil {
+		log.Logf("[rocketchat] error decoding message: %v", err)
+		return nil, nil, false
+	}
+
+	var info roomInfo
+	if len(d.Fields.Args) > 1 {
+		json.Unmarshal(d.Fields.Args[1], &info)
+	}
+
+	return &m, &info, len(m.ID) > 0
+}
+
+func (c *rocketchatConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+		c.ddp.close()
+	})
+	return nil
+}
+
+func (c *rocketchatConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		d, err := c.ddp.read()
+		if err != nil {
+			select {
+			case <-c.closed:
+				return errors.New("connection closed")
+			default:
+			}
+			return err
+		}
+
+		m, info, ok := decode(d)
+		if !ok {
+			continue
+		}
+
+		// recent messages are replayed on subscribe and edits republished
+		if !c.processed.first(m.ID) {
+			continue
+		}
+
+		if m.User != nil && len(m.User.Username) > 0 {
+			c.users.set(m.User)
+		}
+
+		text, ok := c.addressed(m, info)
+		if !ok || len(text) == 0 {
+			continue
+		}
+
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = m.RoomID + ":" + m.User.ID
+		event.To = c.me.ID
+		event.Type = input.TextEvent
+		event.Data = []byte(text)
+		event.Meta["reply"] = m
+		event.Meta[MetaRoom] = m.RoomID
+		event.Meta[MetaUser] = m.User.ID
+		event.Meta[MetaUsername] = c.users.username(m.User.ID)
+		event.Meta[MetaMessageID] = m.ID
+		event.Meta[MetaThreadID] = m.ThreadID
+
+		return nil
+	}
+}
+
+// MessageLimit is the most characters sent in a message
+func (c *rocketchatConn) MessageLimit() int {
+	return maxMessageSize
+}
+
+func (c *rocketchatConn) Send(event *input.Event) error {
+	// To is room:user as set by Recv
+	room := strings.Split(event.To, ":")[0]
+
+	if r, ok := event.Meta[MetaRoom].(string); ok && len(r) > 0 {
+		room = r
+	}
+
+	if len(room) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	// answer in the thread the command was sent in
+	var thread string
+	if reply, ok := event.Meta["reply"].(*message); ok && reply.RoomID == room {
+		thread = reply.ThreadID
+	}
+	if t, ok := event.Meta[MetaThreadID].(string); ok && len(t) > 0 {
+		thread = t
+	}
+	if !c.threads {
+		thread = ""
+	}
+
+	data := string(event.Data)
+	if len(data) == 0 {
+		data = "(no output)"
+	}
+
+	for _, text := range input.SplitMessage(data, maxMessageSize) {
+		if err := c.client.sendMessage(&message{RoomID: room, ThreadID: thread, Text: text}); err != nil {
+			log.Logf("[rocketchat] error sending to %s: %v", room, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// how often the server is pinged, it's considered dead after two
+// intervals without hearing from it
+var pingInterval = 30 * time.Second
+
+// the stream every message in the rooms the bot is in is published to
+const myMessages = "__my_messages__"
+
+type ddpError struct {
+	Error   interface{} `json:"error"`
+	Reason  string      `json:"reason"`
+	Message string      `json:"message"`
+}
+
+func (e *ddpError) String() string {
+	if len(e.Reason) > 0 {
+		return e.Reason
+	}
+	if len(e.Message) > 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%v", e.Error)
+}
+
+// ddpMessage is a message of the DDP protocol the realtime api speaks
+type ddpMessage struct {
+	Msg        string          `json:"msg"`
+	ID         string          `json:"id,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      *ddpError       `json:"error,omitempty"`
+	Subs       []string        `json:"subs,omitempty"`
+	Fields     struct {
+		EventName string            `json:"eventName"`
+		Args      []json.RawMessage `json:"args"`
+	} `json:"fields"`
+}
+
+// roomInfo accompanies messages published to __my_messages__
+type roomInfo struct {
+	RoomType    string `json:"roomType"`
+	RoomName    string `json:"roomName"`
+	Participant bool   `json:"roomParticipant"`
+}
+
+// ddp is a logged in realtime session subscribed to the bot's messages
+type ddp struct {
+	ws *websocket.Conn
+
+	sync.Mutex
+	id int
+}
+
+// dialDDP connects, logs in with the auth token and subscribes to the
+// messages of every room the bot is in
+func dialDDP(u, token string) (*ddp, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to rocketchat realtime api: %v", err)
+	}
+
+	d := &ddp{ws: ws}
+
+	if err := d.setup(token); err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (d *ddp) setup(token string) error {
+	ws := d.ws
+	ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+
+	if err := d.send(map[string]interface{}{"msg": "connect", "version": "1", "support": []string{"1"}}); err != nil {
+		return err
+	}
+
+	if _, err := d.wait(func(m *ddpMessage) (bool, error) {
+		switch m.Msg {
+		case "connected":
+			return true, nil
+		case "failed":
+			return false, fmt.Errorf("rocketchat realtime api refused the protocol")
+		}
+		return false, nil
+	}); err != nil {
+		return err
+	}
+
+	id := d.nextID()
+	if err := d.send(map[string]interface{}{
+		"msg":    "method",
+		"method": "login",
+		"id":     id,
+		"params": []interface{}{map[string]string{"resume": token}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := d.wait(func(m *ddpMessage) (bool, error) {
+		if m.Msg != "result" || m.ID != id {
+			return false, nil
+		}
+		if m.Error != nil {
+			return false, fmt.Errorf("rocketchat login failed: %s", m.Error)
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	id = d.nextID()
+	if err := d.send(map[string]interface{}{
+		"msg":    "sub",
+		"id":     id,
+		"name":   "stream-room-messages",
+		"params": []interface{}{myMessages, false},
+	}); err != nil {
+		return err
+	}
+
+	_, err := d.wait(func(m *ddpMessage) (bool, error) {
+		switch m.Msg {
+		case "ready":
+			for _, sub := range m.Subs {
+				if sub == id {
+					return true, nil
+				}
+			}
+		case "nosub":
+			if m.ID != id {
+				break
+			}
+			if m.Error != nil {
+				return false, fmt.Errorf("error subscribing to rocketchat messages: %s", m.Error)
+			}
+			return false, fmt.Errorf("error subscribing to rocketchat messages")
+		}
+		return false, nil
+	})
+
+	return err
+}
+
+func (d *ddp) nextID() string {
+	d.Lock()
+	defer d.Unlock()
+	d.id++
+	return strconv.Itoa(d.id)
+}
+
+// send writes a message, it's safe to call concurrently with read
+func (d *ddp) send(v interface{}) error {
+	d.Lock()
+	defer d.Unlock()
+	d.ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return d.ws.WriteJSON(v)
+}
+
+// ping checks the server is still there
+func (d *ddp) ping() error {
+	return d.send(map[string]string{"msg": "ping", "id": d.nextID()})
+}
+
+// read returns the next message answering pings along the way
+func (d *ddp) read() (*ddpMessage, error) {
+	for {
+		var m ddpMessage
+		if err := d.ws.ReadJSON(&m); err != nil {
+			return nil, err
+		}
+
+		// anything from the server shows it's alive
+		d.ws.SetReadDeadline(time.Now().Add(2 * pingInterval))
+
+		switch m.Msg {
+		case "ping":
+			pong := map[string]string{"msg": "pong"}
+			if len(m.ID) > 0 {
+				pong["id"] = m.ID
+			}
+			if err := d.send(pong); err != nil {
+				return nil, err
+			}
+			continue
+		case "pong":
+			continue
+		}
+
+		return &m, nil
+	}
+}
+
+// wait reads until done returns true or an error
+func (d *ddp) wait(done func(*ddpMessage) (bool, error)) (*ddpMessage, error) {
+	for {
+		m, err := d.read()
+		if err != nil {
+			return nil, err
+		}
+		ok, err := done(m)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return m, nil
+		}
+	}
+}
+
+func (d *ddp) close() error {
+	d.Lock()
+	d.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	d.Unlock()
+	return d.ws.Close()
+}
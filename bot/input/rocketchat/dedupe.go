@@ -0,0 +1,49 @@
+package rocketchat
+
+import (
+	"container/list"
+	"sync"
+)
+
+// how many processed messages are remembered
+var processedSize = 1024
+
+// processed is a bounded LRU of messages already handled. The realtime
+// api replays recent messages on subscribe and republishes them when
+// edited or reacted to so it's kept per input and outlives the conn.
+type processed struct {
+	size int
+
+	sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newProcessed(size int) *processed {
+	return &processed{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+	}
+}
+
+// first returns true the first time it's called for the message ID
+func (p *processed) first(id string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if e, ok := p.keys[id]; ok {
+		p.order.MoveToFront(e)
+		return false
+	}
+
+	p.keys[id] = p.order.PushFront(id)
+
+	for p.order.Len() > p.size {
+		e := p.order.Back()
+		p.order.Remove(e)
+		delete(p.keys, e.Value.(string))
+	}
+
+	return true
+}
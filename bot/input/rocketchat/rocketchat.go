@@ -0,0 +1,198 @@
+// Package rocketchat is a Rocket.Chat input for the bot. Messages are
+// received over the realtime (DDP) api and replies made with the REST
+// api.
+package rocketchat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+type rocketchatInput struct {
+	url      string
+	username string
+	password string
+	token    string
+	channels []string
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+	me      *user
+	rooms   map[string]bool
+	threads bool
+	users   *userCache
+	// outlives conns as messages are replayed on every subscribe
+	processed *processed
+}
+
+func init() {
+	input.Register("rocketchat", NewInput())
+}
+
+func (p *rocketchatInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "rocketchat_url",
+			Usage: "Rocket.Chat server url e.g https://chat.example.com",
+		},
+		cli.StringFlag{
+			Name:  "rocketchat_user",
+			Usage: "Rocket.Chat username of the bot",
+		},
+		cli.StringFlag{
+			Name:  "rocketchat_password",
+			Usage: "Rocket.Chat password of the bot",
+		},
+		cli.StringFlag{
+			Name:  "rocketchat_token",
+			Usage: "Rocket.Chat personal access token, used instead of the username and password",
+		},
+		cli.StringFlag{
+			Name:  "rocketchat_channels",
+			Usage: "Comma separated list of channels to answer in, defaults to every room the bot is in; direct messages are always answered",
+		},
+	}
+}
+
+// parseChannels splits the channel list dropping any leading #
+func parseChannels(s string) []string {
+	var channels []string
+
+	for _, ch := range strings.Split(s, ",") {
+		ch = strings.TrimPrefix(strings.TrimSpace(ch), "#")
+		if len(ch) > 0 {
+			channels = append(channels, ch)
+		}
+	}
+
+	return channels
+}
+
+func (p *rocketchatInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	url := cfg.String("rocketchat_url", "")
+	username := cfg.String("rocketchat_user", "")
+	password := cfg.String("rocketchat_password", "")
+	token := cfg.String("rocketchat_token", "")
+
+	if len(url) == 0 {
+		return errors.New("missing rocketchat url")
+	}
+
+	if len(token) == 0 && (len(username) == 0 || len(password) == 0) {
+		return errors.New("missing rocketchat token or user and password")
+	}
+
+	// fail on a bad url now rather than on start
+	if _, err := newClient(url); err != nil {
+		return err
+	}
+
+	p.url = url
+	p.username = username
+	p.password = password
+	p.token = token
+	p.channels = parseChannels(cfg.String("rocketchat_channels", ""))
+
+	return nil
+}
+
+func (p *rocketchatInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	d, err := dialDDP(p.client.websocketURL(), p.client.token)
+	if err != nil {
+		return nil, err
+	}
+
+	return newConn(p.client, p.me, d, p.rooms, p.threads, p.users, p.processed, p.exit), nil
+}
+
+func (p *rocketchatInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	c, err := newClient(p.url)
+	if err != nil {
+		return err
+	}
+
+	// fail fast on bad credentials
+	s, err := c.login(p.username, p.password, p.token)
+	if err != nil {
+		return err
+	}
+
+	threads, err := c.threads()
+	if err != nil {
+		log.Logf("[rocketchat] not threading replies: %v", err)
+	}
+
+	var rooms map[string]bool
+	if len(p.channels) > 0 {
+		rooms = make(map[string]bool)
+		for _, name := range p.channels {
+			r, err := c.room(name)
+			if err != nil {
+				return fmt.Errorf("error finding rocketchat channel %s: %v", name, err)
+			}
+			rooms[r.ID] = true
+		}
+	}
+
+	me := &user{ID: s.UserID, Username: s.Me.Username, Name: s.Me.Name}
+
+	log.Logf("[rocketchat] connected to %s as @%s", p.url, me.Username)
+
+	p.client = c
+	p.me = me
+	p.rooms = rooms
+	p.threads = threads
+	p.users = newUserCache(c.user)
+	if p.processed == nil {
+		p.processed = newProcessed(processedSize)
+	}
+	p.exit = make(chan bool)
+	p.running = true
+
+	return nil
+}
+
+func (p *rocketchatInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+	p.running = false
+	return nil
+}
+
+func (p *rocketchatInput) String() string {
+	return "rocketchat"
+}
+
+func NewInput() input.Input {
+	return &rocketchatInput{}
+}
@@ -0,0 +1,349 @@
+package rocketchat
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/micro/bot/input"
+)
+
+// testServer is a rocketchat serving the REST and realtime apis
+type testServer struct {
+	*httptest.Server
+
+	version  string
+	upgrader websocket.Upgrader
+	messages chan message
+	conns    chan *websocket.Conn
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newTestServer(version string) *testServer {
+	s := &testServer{
+		version:  version,
+		messages: make(chan message, 10),
+		conns:    make(chan *websocket.Conn, 1),
+	}
+
+	authed := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-User-Id") != "BOT" || r.Header.Get("X-Auth-Token") != "auth" {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "message": "You must be logged in to do this."})
+				return
+			}
+			h(w, r)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["resume"] != "pat" && (body["user"] != "micro" || body["password"] != "secret") {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "error", "error": "Unauthorized", "message": "Unauthorized"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"status": "success",
+			"data": session{
+				UserID:    "BOT",
+				AuthToken: "auth",
+				Me:        user{ID: "BOT", Username: "micro"},
+			},
+		})
+	})
+	mux.HandleFunc("/api/info", authed(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"version": s.version, "success": true})
+	}))
+	mux.HandleFunc("/api/v1/rooms.info", authed(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("roomName") != "general" {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"success": false, "error": "The required \"roomId\" or \"roomName\" param provided does not match any channel"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"room": room{ID: "GENERAL", Name: "general", Type: "c"}, "success": true})
+	}))
+	mux.HandleFunc("/api/v1/users.info", authed(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user": user{ID: r.URL.Query().Get("userId"), Username: "jane"}, "success": true})
+	}))
+	mux.HandleFunc("/api/v1/chat.sendMessage", authed(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Message message `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.messages <- body.Message
+		writeJSON(w, http.StatusOK, map[string]interface{}{"success": true})
+	}))
+	mux.HandleFunc("/websocket", func(w http.ResponseWriter, r *http.Request) {
+		ws, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if err := handshake(ws); err != nil {
+			ws.Close()
+			return
+		}
+		s.conns <- ws
+	})
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// handshake answers connect, login and subscribe as the server would
+func handshake(ws *websocket.Conn) error {
+	for {
+		var m map[string]interface{}
+		if err := ws.ReadJSON(&m); err != nil {
+			return err
+		}
+
+		switch m["msg"] {
+		case "connect":
+			ws.WriteJSON(map[string]string{"msg": "connected", "session": "S1"})
+		case "method":
+			params := m["params"].([]interface{})
+			if params[0].(map[string]interface{})["resume"] != "auth" {
+				ws.WriteJSON(map[string]interface{}{"msg": "result", "id": m["id"], "error": map[string]interface{}{"error": 403, "reason": "You've been logged out by the server. Please log in again."}})
+				continue
+			}
+			ws.WriteJSON(map[string]interface{}{"msg": "result", "id": m["id"], "result": map[string]string{"id": "BOT", "token": "auth"}})
+		case "sub":
+			ws.WriteJSON(map[string]interface{}{"msg": "ready", "subs": []interface{}{m["id"]}})
+			return nil
+		}
+	}
+}
+
+// publish sends a message to the bot's stream
+func publish(ws *websocket.Conn, m message, roomType string) {
+	ws.WriteJSON(map[string]interface{}{
+		"msg":        "changed",
+		"collection": "stream-room-messages",
+		"id":         "id",
+		"fields": map[string]interface{}{
+			"eventName": myMessages,
+			"args":      []interface{}{m, roomInfo{RoomType: roomType, Participant: true}},
+		},
+	})
+}
+
+func (s *testServer) accept(t *testing.T) *websocket.Conn {
+	select {
+	case ws := <-s.conns:
+		return ws
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the realtime api")
+	}
+	return nil
+}
+
+func startInput(t *testing.T, s *testServer, channels ...string) *rocketchatInput {
+	p := NewInput().(*rocketchatInput)
+	p.url = s.URL
+	p.username = "micro"
+	p.password = "secret"
+	p.channels = channels
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestStartErrors(t *testing.T) {
+	s := newTestServer("3.0.0")
+	defer s.Close()
+
+	testData := []struct {
+		password string
+		channels []string
+		err      string
+	}{
+		{"wrong", nil, "rocketchat error 401: Unauthorized"},
+		{"secret", []string{"general", "missing"}, "error finding rocketchat channel missing"},
+	}
+
+	for _, d := range testData {
+		p := NewInput().(*rocketchatInput)
+		p.url = s.URL
+		p.username = "micro"
+		p.password = d.password
+		p.channels = d.channels
+
+		err := p.Start()
+		if err == nil || !strings.Contains(err.Error(), d.err) {
+			t.Fatalf("expected error %q got %v", d.err, err)
+		}
+	}
+
+	// personal access tokens are used instead of the password
+	p := NewInput().(*rocketchatInput)
+	p.url = s.URL
+	p.token = "pat"
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+}
+
+func TestParseChannels(t *testing.T) {
+	got := parseChannels(" #general, ops,, ")
+	if strings.Join(got, ",") != "general,ops" {
+		t.Fatalf("unexpected channels %q", got)
+	}
+}
+
+func TestRocketchat(t *testing.T) {
+	s := newTestServer("3.0.0")
+	defer s.Close()
+
+	p := startInput(t, s, "general")
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ws := s.accept(t)
+
+	// pings from the server are answered while receiving
+	ws.WriteJSON(map[string]string{"msg": "ping"})
+
+	john := &user{ID: "U1", Username: "john"}
+	cmd := message{ID: "M1", RoomID: "GENERAL", Text: "@micro ping", ThreadID: "T1", User: john}
+
+	publish(ws, cmd, "c")
+	// edits and replays are republished
+	publish(ws, cmd, "c")
+	// not mentioned, in a channel not listened on, joins and the bot's own
+	publish(ws, message{ID: "M2", RoomID: "GENERAL", Text: "ping", User: john}, "c")
+	publish(ws, message{ID: "M3", RoomID: "RANDOM", Text: "@micro ping", User: john}, "c")
+	publish(ws, message{ID: "M4", RoomID: "GENERAL", Text: "john", Type: "uj", User: john}, "c")
+	publish(ws, message{ID: "M5", RoomID: "GENERAL", Text: "@micro ping", User: &user{ID: "BOT", Username: "micro"}}, "c")
+	// direct messages need no mention and unknown users are looked up
+	publish(ws, message{ID: "M6", RoomID: "BOTU2", Text: "ping dm", User: &user{ID: "U2"}}, "d")
+
+	testData := []struct {
+		from     string
+		text     string
+		username string
+		thread   string
+	}{
+		{"GENERAL:U1", "ping", "john", "T1"},
+		{"BOTU2:U2", "ping dm", "jane", ""},
+	}
+
+	for i, d := range testData {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			var pong map[string]string
+			ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if err := ws.ReadJSON(&pong); err != nil || pong["msg"] != "pong" {
+				t.Fatalf("expected a pong got %v %v", pong, err)
+			}
+		}
+
+		if ev.From != d.from || string(ev.Data) != d.text || ev.Meta[MetaUsername] != d.username {
+			t.Fatalf("expected %s %q from %s got %+v", d.from, d.text, d.username, ev)
+		}
+
+		if err := c.Send(&input.Event{To: ev.From, Type: input.TextEvent, Data: []byte("pong"), Meta: ev.Meta}); err != nil {
+			t.Fatal(err)
+		}
+
+		m := <-s.messages
+		room := strings.Split(d.from, ":")[0]
+		if m.RoomID != room || m.ThreadID != d.thread || m.Text != "pong" {
+			t.Fatalf("unexpected reply %+v", m)
+		}
+	}
+
+	// messages are replayed when resubscribing
+	c.Close()
+
+	c, err = p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ws = s.accept(t)
+	publish(ws, cmd, "c")
+	publish(ws, message{ID: "M7", RoomID: "GENERAL", Text: "@micro: again", User: john}, "c")
+
+	var ev input.Event
+	if err := c.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "again" {
+		t.Fatalf("expected the replayed message to be skipped got %q", ev.Data)
+	}
+
+	// stopping the input closes the conn
+	p.Stop()
+
+	if err := c.Recv(&ev); err == nil {
+		t.Fatal("expected an error once stopped")
+	}
+}
+
+func TestNoThreads(t *testing.T) {
+	s := newTestServer("0.74.3")
+	defer s.Close()
+
+	p := startInput(t, s)
+	defer p.Stop()
+
+	if p.threads {
+		t.Fatal("expected threads to be unsupported")
+	}
+
+	c := &rocketchatConn{client: p.client, threads: p.threads}
+
+	reply := &message{ID: "M1", RoomID: "GENERAL", ThreadID: "T1"}
+	if err := c.Send(&input.Event{To: "GENERAL:U1", Data: []byte("pong"), Meta: map[string]interface{}{"reply": reply}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := <-s.messages; m.ThreadID != "" {
+		t.Fatalf("expected no thread got %q", m.ThreadID)
+	}
+}
+
+func TestProcessed(t *testing.T) {
+	p := newProcessed(2)
+
+	for _, id := range []string{"a", "b", "c"} {
+		if !p.first(id) {
+			t.Fatalf("expected %s to be first", id)
+		}
+	}
+
+	if p.first("c") || p.first("b") {
+		t.Fatal("expected recent messages to be remembered")
+	}
+
+	// the oldest was evicted
+	if !p.first("a") {
+		t.Fatal("expected a to have been forgotten")
+	}
+}
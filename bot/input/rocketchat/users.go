@@ -0,0 +1,86 @@
+package rocketchat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+// how long a cached user is trusted before it's looked up again in case
+// they were renamed
+var userTTL = time.Hour
+
+type cachedUser struct {
+	username string
+	name     string
+	expires  time.Time
+}
+
+// userCache holds the users seen in messages, looking up those it
+// doesn't know by ID
+type userCache struct {
+	lookup func(id string) (*user, error)
+
+	sync.Mutex
+	users map[string]cachedUser
+}
+
+func newUserCache(lookup func(id string) (*user, error)) *userCache {
+	return &userCache{
+		lookup: lookup,
+		users:  make(map[string]cachedUser),
+	}
+}
+
+// get returns the cached user looking it up if unknown or expired
+func (c *userCache) get(id string) (cachedUser, bool) {
+	if len(id) == 0 {
+		return cachedUser{}, false
+	}
+
+	c.Lock()
+	u, ok := c.users[id]
+	c.Unlock()
+
+	if ok && time.Now().Before(u.expires) {
+		return u, true
+	}
+
+	if c.lookup == nil {
+		return u, ok
+	}
+
+	// don't hold the lock while calling the server
+	usr, err := c.lookup(id)
+	if err != nil {
+		log.Logf("[rocketchat] error looking up user %s: %v", id, err)
+		// better a stale name than none
+		return u, ok
+	}
+
+	return c.set(usr), true
+}
+
+// set caches the user. Messages carry the sender's names so they're
+// cached as they arrive.
+func (c *userCache) set(usr *user) cachedUser {
+	u := cachedUser{
+		username: usr.Username,
+		name:     usr.Name,
+		expires:  time.Now().Add(userTTL),
+	}
+
+	c.Lock()
+	c.users[usr.ID] = u
+	c.Unlock()
+
+	return u
+}
+
+// username returns the user's username or an empty string if it can't
+// be found
+func (c *userCache) username(id string) string {
+	u, _ := c.get(id)
+	return u.username
+}
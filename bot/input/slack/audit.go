@@ -0,0 +1,176 @@
+package slack
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/tokenize"
+	"github.com/nlopes/slack"
+)
+
+// AuditRecord describes a command run through the bot
+type AuditRecord struct {
+	Time     time.Time     `json:"time"`
+	User     string        `json:"user"`
+	UserName string        `json:"user_name,omitempty"`
+	Channel  string        `json:"channel"`
+	Args     []string      `json:"args"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Auditor records executed commands e.g to a file or log service.
+// Records are written from a single goroutine.
+type Auditor interface {
+	Audit(*AuditRecord) error
+}
+
+var (
+	// set by SetAuditor, used in place of slack_audit_file
+	auditor Auditor
+	// records waiting to be written before new ones are dropped
+	auditBuffer = 1000
+)
+
+// SetAuditor sets the auditor commands are recorded with in place of the
+// slack_audit_file. It must be called before the input is started.
+func SetAuditor(a Auditor) {
+	auditor = a
+}
+
+// fileAuditor writes records as JSON lines
+type fileAuditor struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+func newFileAuditor(path string) (*fileAuditor, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &fileAuditor{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (a *fileAuditor) Audit(r *AuditRecord) error {
+	return a.enc.Encode(r)
+}
+
+func (a *fileAuditor) Close() error {
+	return a.f.Close()
+}
+
+// asyncAuditor writes records in the background so slow auditors don't
+// hold up the event loop
+type asyncAuditor struct {
+	auditor Auditor
+	// called once flushed, may be nil
+	closer  func() error
+	records chan *AuditRecord
+	done    chan bool
+	once    sync.Once
+}
+
+func newAsyncAuditor(a Auditor, closer func() error) *asyncAuditor {
+	aa := &asyncAuditor{
+		auditor: a,
+		closer:  closer,
+		records: make(chan *AuditRecord, auditBuffer),
+		done:    make(chan bool),
+	}
+	go aa.run()
+	return aa
+}
+
+// newAuditor returns the auditor set with SetAuditor or one writing to
+// path. It returns nil if neither is set.
+func newAuditor(path string) (*asyncAuditor, error) {
+	if auditor != nil {
+		return newAsyncAuditor(auditor, nil), nil
+	}
+
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	fa, err := newFileAuditor(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAsyncAuditor(fa, fa.Close), nil
+}
+
+func (a *asyncAuditor) run() {
+	defer close(a.done)
+
+	for r := range a.records {
+		if err := a.auditor.Audit(r); err != nil {
+			log.Logf("[slack] error writing audit record: %v", err)
+		}
+	}
+}
+
+// audit queues the record dropping it if the buffer is full
+func (a *asyncAuditor) audit(r *AuditRecord) {
+	defer func() {
+		// closed while a command was still running
+		if recover() != nil {
+			log.Logf("[slack] dropped audit record for %v, auditor closed", r.Args)
+		}
+	}()
+
+	select {
+	case a.records <- r:
+	default:
+		log.Logf("[slack] dropped audit record for %v, buffer full", r.Args)
+	}
+}
+
+// close flushes queued records and closes the auditor
+func (a *asyncAuditor) close() error {
+	var err error
+	a.once.Do(func() {
+		close(a.records)
+		<-a.done
+		if a.closer != nil {
+			err = a.closer()
+		}
+	})
+	return err
+}
+
+// record returns a func which audits the command once it completes
+func (s *slackConn) record(event input.Event, reply *slack.MessageEvent) func(error) {
+	if s.auditor == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+
+	text := string(event.Data)
+	args, err := tokenize.Split(text)
+	if err != nil {
+		args = strings.Fields(text)
+	}
+
+	return func(err error) {
+		r := &AuditRecord{
+			Time:     start,
+			User:     reply.User,
+			UserName: s.getName(reply.User),
+			Channel:  reply.Channel,
+			Args:     args,
+			Duration: time.Since(start),
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		s.auditor.audit(r)
+	}
+}
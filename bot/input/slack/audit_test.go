@@ -0,0 +1,143 @@
+package slack
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// testAuditor collects records
+type testAuditor struct {
+	records chan *AuditRecord
+}
+
+func (t *testAuditor) Audit(r *AuditRecord) error {
+	t.records <- r
+	return nil
+}
+
+func TestAudit(t *testing.T) {
+	ta := &testAuditor{records: make(chan *AuditRecord, 10)}
+
+	conn, _ := newTestConn()
+	conn.auditor = newAsyncAuditor(ta, nil)
+
+	testData := []struct {
+		text  string
+		slash bool
+		err   error
+		args  []string
+	}{
+		{`echo "my service" 8080`, false, nil, []string{"echo", "my service", "8080"}},
+		{"deploy web", false, errors.New("command 'deploy' timed out after 30s"), []string{"deploy", "web"}},
+		{`list "services`, true, nil, []string{"list", `"services`}},
+	}
+
+	for _, d := range testData {
+		ev := input.Event{
+			Data: []byte(d.text),
+			Meta: map[string]interface{}{
+				"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", User: "U0USER", Text: d.text}},
+			},
+		}
+		if d.slash {
+			ev.Meta["slash"] = &slashCommand{}
+		}
+
+		conn.Notify(ev)(d.err)
+	}
+
+	// flushes queued records
+	if err := conn.auditor.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range testData {
+		r := <-ta.records
+
+		if r.User != "U0USER" || r.UserName != "john" || r.Channel != "C0CHAN" {
+			t.Fatalf("expected U0USER john in C0CHAN got %+v", r)
+		}
+		if strings.Join(r.Args, "|") != strings.Join(d.args, "|") {
+			t.Fatalf("expected args %q got %q", d.args, r.Args)
+		}
+		if r.Time.IsZero() || r.Duration < 0 {
+			t.Fatalf("expected time and duration got %+v", r)
+		}
+
+		var expect string
+		if d.err != nil {
+			expect = d.err.Error()
+		}
+		if r.Error != expect {
+			t.Fatalf("expected error %q got %q", expect, r.Error)
+		}
+	}
+
+	// records after closing are dropped rather than panicking
+	conn.Notify(input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN"}},
+		},
+	})(nil)
+}
+
+func TestAuditFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+
+	// appended to across restarts
+	for i := 0; i < 2; i++ {
+		a, err := newAuditor(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		a.audit(&AuditRecord{User: "U0USER", Channel: "C0CHAN", Args: []string{"ping"}})
+
+		if err := a.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("expected a JSON record got %q: %v", scanner.Text(), err)
+		}
+		if r.User != "U0USER" || len(r.Args) != 1 || r.Args[0] != "ping" {
+			t.Fatalf("unexpected record %+v", r)
+		}
+		lines++
+	}
+
+	if lines != 2 {
+		t.Fatalf("expected 2 records got %d", lines)
+	}
+
+	// not audited without a file
+	if a, err := newAuditor(""); err != nil || a != nil {
+		t.Fatalf("expected no auditor got %v %v", a, err)
+	}
+}
@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"strings"
+)
+
+// privileged returns true if the command is restricted to admins
+func (s *slackConn) privileged(command string) bool {
+	for _, c := range s.adminCommands {
+		if strings.EqualFold(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAdmin returns true if the user ID or username is listed as an
+// admin. Display names aren't unique so they're never matched.
+func (s *slackConn) isAdmin(user string) bool {
+	name := s.users.handle(user)
+
+	for _, admin := range s.admins {
+		if admin == user {
+			return true
+		}
+		if len(name) > 0 && strings.TrimPrefix(admin, "@") == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authorized returns true if the user may run the command
+func (s *slackConn) authorized(user, command string) bool {
+	if !s.privileged(command) {
+		return true
+	}
+	return s.isAdmin(user)
+}
+
+// grouped returns true if the user is a member of one of the user groups
+// the command is restricted to, along with the groups
+func (s *slackConn) grouped(user, command string) ([]string, bool) {
+	groups, ok := s.commandGroups[command]
+	if !ok {
+		return nil, true
+	}
+
+	for _, g := range groups {
+		if s.groups.isMember(g, user) {
+			return groups, true
+		}
+	}
+
+	return groups, false
+}
+
+// scoped returns true if the command may run in the channel
+func (s *slackConn) scoped(command, channel string) bool {
+	channels, ok := s.commandChannels[command]
+	if !ok {
+		return true
+	}
+	return s.channels.contains(channels, channel)
+}
@@ -0,0 +1,101 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nlopes/slack"
+)
+
+// block is a Block Kit layout block. The slack client predates
+// Block Kit so blocks are posted through the web api directly.
+type block struct {
+	Type     string       `json:"type"`
+	BlockID  string       `json:"block_id,omitempty"`
+	Text     *textObject  `json:"text,omitempty"`
+	Fields   []textObject `json:"fields,omitempty"`
+	Elements []element    `json:"elements,omitempty"`
+}
+
+type textObject struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// element is an interactive element of an actions block
+type element struct {
+	Type     string      `json:"type"`
+	ActionID string      `json:"action_id,omitempty"`
+	Text     *textObject `json:"text,omitempty"`
+	Value    string      `json:"value,omitempty"`
+	Style    string      `json:"style,omitempty"`
+}
+
+func markdown(text string) *textObject {
+	return &textObject{Type: "mrkdwn", Text: text}
+}
+
+func plainText(text string) *textObject {
+	return &textObject{Type: "plain_text", Text: text}
+}
+
+// sectionBlock returns a section containing markdown text
+func sectionBlock(text string) block {
+	return block{Type: "section", Text: markdown(text)}
+}
+
+// button returns a button element
+func button(actionID, text, value, style string) element {
+	return element{
+		Type:     "button",
+		ActionID: actionID,
+		Text:     plainText(text),
+		Value:    value,
+		Style:    style,
+	}
+}
+
+// postBlocks calls a chat method such as chat.postMessage or chat.update
+// with blocks and returns the timestamp of the message
+func postBlocks(client *http.Client, token, method string, values url.Values, blocks []block) (string, error) {
+	values.Set("token", token)
+
+	if len(blocks) > 0 {
+		b, err := json.Marshal(blocks)
+		if err != nil {
+			return "", err
+		}
+		values.Set("blocks", string(b))
+	}
+
+	req, err := http.NewRequest("POST", slack.APIURL+method, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := httpClient(client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+
+	var res struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+
+	if !res.Ok {
+		return "", errors.New(res.Error)
+	}
+
+	return res.TS, nil
+}
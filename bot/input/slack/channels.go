@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nlopes/slack"
+)
+
+// channelCache maps channel names to IDs since events only carry IDs
+type channelCache struct {
+	sync.RWMutex
+	ids   map[string]string
+	names map[string]string
+}
+
+func newChannelCache() *channelCache {
+	return &channelCache{
+		ids:   make(map[string]string),
+		names: make(map[string]string),
+	}
+}
+
+// set replaces the cache with channels
+func (c *channelCache) set(channels []slack.Channel) {
+	ids := make(map[string]string)
+	names := make(map[string]string)
+
+	for _, ch := range channels {
+		ids[ch.Name] = ch.ID
+		names[ch.ID] = ch.Name
+	}
+
+	c.Lock()
+	c.ids = ids
+	c.names = names
+	c.Unlock()
+}
+
+// add caches a channel the bot has joined
+func (c *channelCache) add(id, name string) {
+	if len(name) == 0 {
+		return
+	}
+
+	c.Lock()
+	c.ids[name] = id
+	c.names[id] = name
+	c.Unlock()
+}
+
+// remove forgets a channel the bot has left
+func (c *channelCache) remove(id string) {
+	c.removeChannel(id)
+}
+
+func (c *channelCache) removeChannel(id string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	name, ok := c.names[id]
+	if !ok {
+		return 0
+	}
+
+	delete(c.ids, name)
+	delete(c.names, id)
+	return 1
+}
+
+// id resolves a channel name or ID to an ID
+func (c *channelCache) id(channel string) string {
+	channel = strings.TrimPrefix(channel, "#")
+
+	c.RLock()
+	defer c.RUnlock()
+
+	if id, ok := c.ids[channel]; ok {
+		return id
+	}
+
+	return channel
+}
+
+// name returns the name of the channel with the given ID
+func (c *channelCache) name(id string) string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.names[id]
+}
+
+// contains returns true if any of the channel names or IDs resolve to id
+func (c *channelCache) contains(channels []string, id string) bool {
+	for _, ch := range channels {
+		if c.id(ch) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchChannels retrieves all public and private channels visible to the bot
+func fetchChannels(api *slack.Client) ([]slack.Channel, error) {
+	var channels []slack.Channel
+
+	params := &slack.GetConversationsParameters{
+		ExcludeArchived: "true",
+		Limit:           1000,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+
+	for {
+		chans, cursor, err := api.GetConversations(params)
+		if err != nil {
+			return nil, err
+		}
+
+		channels = append(channels, chans...)
+
+		if len(cursor) == 0 {
+			return channels, nil
+		}
+
+		params.Cursor = cursor
+	}
+}
+
+// splitList splits a comma separated flag value
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// parseCommandChannels parses the channels commands are restricted to
+// e.g "deregister=#ops,#ops-prod;register=#ops"
+func parseCommandChannels(s string) (map[string][]string, error) {
+	return parseCommandLists("command channels", "channel", s)
+}
+
+// parseCommandLists parses lists keyed by command e.g "cmd=a,b;cmd2=c".
+// name and item describe the flag in errors.
+func parseCommandLists(name, item, s string) (map[string][]string, error) {
+	restricted := make(map[string][]string)
+
+	for _, entry := range strings.Split(s, ";") {
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid %s %q: expected command=%s,...", name, entry, item)
+		}
+
+		command := strings.ToLower(strings.TrimSpace(parts[0]))
+		if len(command) == 0 {
+			return nil, fmt.Errorf("invalid %s %q: missing command", name, entry)
+		}
+
+		list := splitList(parts[1])
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid %s %q: missing %ss", name, entry, item)
+		}
+
+		if _, ok := restricted[command]; ok {
+			return nil, fmt.Errorf("invalid %s %q: %s listed twice", name, entry, command)
+		}
+
+		restricted[command] = list
+	}
+
+	return restricted, nil
+}
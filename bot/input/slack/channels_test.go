@@ -0,0 +1,43 @@
+package slack
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandChannels(t *testing.T) {
+	testData := []struct {
+		value  string
+		expect map[string][]string
+		err    bool
+	}{
+		{"", map[string][]string{}, false},
+		{"deregister=#ops", map[string][]string{"deregister": {"#ops"}}, false},
+		{
+			" Deregister = #ops, #ops-prod ; register=C0OPS ;",
+			map[string][]string{"deregister": {"#ops", "#ops-prod"}, "register": {"C0OPS"}},
+			false,
+		},
+		{"deregister", nil, true},
+		{"=#ops", nil, true},
+		{"deregister=", nil, true},
+		{"deregister= , ", nil, true},
+		{"deregister=#ops;deregister=#dev", nil, true},
+	}
+
+	for _, d := range testData {
+		got, err := parseCommandChannels(d.value)
+		if d.err {
+			if err == nil {
+				t.Fatalf("%q: expected error", d.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.value, err)
+		}
+		if !reflect.DeepEqual(got, d.expect) {
+			t.Fatalf("%q: expected %v got %v", d.value, d.expect, got)
+		}
+	}
+}
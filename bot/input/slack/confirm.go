@@ -0,0 +1,289 @@
+package slack
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+const (
+	confirmAction = "confirm"
+	cancelAction  = "cancel"
+)
+
+// action is a button click from a block_actions interaction payload
+type action struct {
+	User      string
+	Channel   string
+	MessageTS string
+	ActionID  string
+	Value     string
+}
+
+// interaction is the subset of the interaction payload we use
+type interaction struct {
+	Type string `json:"type"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+	Container struct {
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+// pending is a command waiting on its requester to confirm it
+type pending struct {
+	event   input.Event
+	user    string
+	channel string
+	command string
+	// timestamp of the confirmation message
+	ts    string
+	timer *time.Timer
+}
+
+// confirmations tracks commands awaiting confirmation by ID
+type confirmations struct {
+	sync.Mutex
+	pending map[string]*pending
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{
+		pending: make(map[string]*pending),
+	}
+}
+
+// add tracks a pending command calling expire if it isn't answered
+// within timeout
+func (c *confirmations) add(id string, p *pending, timeout time.Duration, expire func()) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.pending[id] = p
+	p.timer = time.AfterFunc(timeout, func() {
+		if c.remove(id) {
+			expire()
+		}
+	})
+}
+
+func (c *confirmations) get(id string) (*pending, bool) {
+	c.Lock()
+	defer c.Unlock()
+	p, ok := c.pending[id]
+	return p, ok
+}
+
+// remove deletes a pending command. It returns false if it was already
+// removed by a response or the timeout.
+func (c *confirmations) remove(id string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	p, ok := c.pending[id]
+	if !ok {
+		return false
+	}
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	delete(c.pending, id)
+	return true
+}
+
+// removeChannel drops the commands pending in the channel without
+// expiring them
+func (c *confirmations) removeChannel(channel string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	var n int
+	for id, p := range c.pending {
+		if p.channel != channel {
+			continue
+		}
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		delete(c.pending, id)
+		n++
+	}
+
+	return n
+}
+
+// newID returns a random ID to correlate a confirmation with its command
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// actionHandler verifies interaction payloads and hands button clicks
+// to the conn. Slack only needs the request acknowledged.
+func actionHandler(secret string, route func(team string) chan *action, exit chan bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, ok := readForm(secret, w, r)
+		if !ok {
+			return
+		}
+
+		var payload interaction
+		if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		actions := route(payload.Team.ID)
+		if actions == nil {
+			http.Error(w, "unknown workspace", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+
+		if payload.Type != "block_actions" {
+			return
+		}
+
+		for _, a := range payload.Actions {
+			select {
+			case actions <- &action{
+				User:      payload.User.ID,
+				Channel:   payload.Channel.ID,
+				MessageTS: payload.Container.MessageTS,
+				ActionID:  a.ActionID,
+				Value:     a.Value,
+			}:
+			case <-exit:
+				return
+			}
+		}
+	}
+}
+
+// needsConfirm returns true if the command must be confirmed before it runs
+func (s *slackConn) needsConfirm(command string) bool {
+	for _, c := range s.confirmCommands {
+		if strings.EqualFold(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// confirm asks the user who sent ev to confirm the command before the
+// event is handed to the bot
+func (s *slackConn) confirm(event input.Event, ev *slack.MessageEvent) {
+	id := newID()
+	text := strings.TrimSpace(ev.Text)
+
+	values := url.Values{}
+	values.Set("channel", ev.Channel)
+	values.Set("text", fmt.Sprintf("Confirm running %s", text))
+	if thread := s.threadTimestamp(ev); len(thread) > 0 {
+		values.Set("thread_ts", thread)
+	}
+
+	ts, err := postBlocks(s.client, s.token, "chat.postMessage", values, []block{
+		sectionBlock(fmt.Sprintf("<@%s> are you sure you want to run `%s`?", ev.User, text)),
+		{
+			Type: "actions",
+			Elements: []element{
+				button(confirmAction, "Yes", id, "danger"),
+				button(cancelAction, "No", id, ""),
+			},
+		},
+	})
+	if err != nil {
+		log.Logf("[slack] error requesting confirmation: %v", err)
+		s.Send(&input.Event{
+			Meta: event.Meta,
+			From: event.To,
+			To:   event.From,
+			Type: input.TextEvent,
+			Data: []byte(i18n.Text("slack", ev.Channel, i18n.ConfirmFailed, i18n.Params{"command": text})),
+		})
+		return
+	}
+
+	p := &pending{
+		event:   event,
+		user:    ev.User,
+		channel: ev.Channel,
+		command: text,
+		ts:      ts,
+	}
+
+	s.confirms.add(id, p, s.confirmTimeout, func() {
+		s.resolve(p, fmt.Sprintf("Request to run `%s` expired", p.command))
+	})
+}
+
+// resolve replaces the confirmation buttons with text
+func (s *slackConn) resolve(p *pending, text string) {
+	values := url.Values{}
+	values.Set("channel", p.channel)
+	values.Set("ts", p.ts)
+	values.Set("text", text)
+
+	if _, err := postBlocks(s.client, s.token, "chat.update", values, []block{sectionBlock(text)}); err != nil {
+		log.Logf("[slack] error updating confirmation: %v", err)
+	}
+}
+
+// confirmed handles a button click. It returns the pending command's
+// event if the requester confirmed it.
+func (s *slackConn) confirmed(a *action) (input.Event, bool) {
+	p, ok := s.confirms.get(a.Value)
+	if !ok {
+		return input.Event{}, false
+	}
+
+	// only the requester may answer
+	if a.User != p.user {
+		if _, err := s.api.PostEphemeral(a.Channel, a.User, slack.MsgOptionText(
+			fmt.Sprintf("Only <@%s> can confirm this command", p.user), false),
+		); err != nil {
+			log.Logf("[slack] error posting ephemeral message to %s: %v", a.Channel, err)
+		}
+		return input.Event{}, false
+	}
+
+	// lost the race with the timeout
+	if !s.confirms.remove(a.Value) {
+		return input.Event{}, false
+	}
+
+	if a.ActionID != confirmAction {
+		s.resolve(p, fmt.Sprintf("Cancelled running `%s`", p.command))
+		return input.Event{}, false
+	}
+
+	s.resolve(p, fmt.Sprintf("<@%s> confirmed running `%s`", p.user, p.command))
+	return p.event, true
+}
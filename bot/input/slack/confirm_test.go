@@ -0,0 +1,192 @@
+package slack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+type testCall struct {
+	method string
+	form   url.Values
+}
+
+// testAPI records web api calls made by the conn
+func testAPI(t *testing.T) (chan testCall, func()) {
+	calls := make(chan testCall, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		calls <- testCall{strings.TrimPrefix(r.URL.Path, "/"), r.Form}
+		w.Write([]byte(`{"ok":true,"ts":"2.0","message_ts":"2.0"}`))
+	}))
+
+	apiURL := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+
+	return calls, func() {
+		slack.APIURL = apiURL
+		srv.Close()
+	}
+}
+
+func newConfirmConn() *slackConn {
+	conn, _ := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.token = "xoxb-test"
+	conn.actions = make(chan *action, 10)
+	conn.confirms = newConfirmations()
+	conn.confirmCommands = []string{"deregister"}
+	conn.confirmTimeout = time.Minute
+	return conn
+}
+
+// requestConfirm sends a command requiring confirmation and returns the
+// ID of the confirmation
+func requestConfirm(t *testing.T, conn *slackConn, calls chan testCall) string {
+	conn.events <- slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{Msg: slack.Msg{
+			Type:    "message",
+			Channel: "C0CHAN",
+			User:    "U0USER",
+			Text:    "<@U0BOT> deregister foo",
+		}},
+	}
+
+	var call testCall
+	select {
+	case call = <-calls:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for confirmation")
+	}
+
+	if call.method != "chat.postMessage" || call.form.Get("channel") != "C0CHAN" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	blocks := call.form.Get("blocks")
+	if !strings.Contains(blocks, `"action_id":"confirm"`) || !strings.Contains(blocks, "deregister foo") {
+		t.Fatalf("unexpected blocks %s", blocks)
+	}
+
+	i := strings.Index(blocks, `"value":"`) + len(`"value":"`)
+	return blocks[i : i+16]
+}
+
+func TestConfirm(t *testing.T) {
+	calls, stop := testAPI(t)
+	defer stop()
+
+	conn := newConfirmConn()
+
+	received := make(chan input.Event, 1)
+	go func() {
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Error(err)
+		}
+		received <- ev
+	}()
+
+	id := requestConfirm(t, conn, calls)
+
+	// other users are told they can't answer
+	conn.actions <- &action{User: "U0OTHER", Channel: "C0CHAN", ActionID: confirmAction, Value: id}
+
+	if call := <-calls; call.method != "chat.postEphemeral" || call.form.Get("user") != "U0OTHER" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	// unknown confirmations are ignored
+	conn.actions <- &action{User: "U0USER", Channel: "C0CHAN", ActionID: confirmAction, Value: "unknown"}
+
+	// the requester confirms
+	conn.actions <- &action{User: "U0USER", Channel: "C0CHAN", ActionID: confirmAction, Value: id}
+
+	if call := <-calls; call.method != "chat.update" || call.form.Get("ts") != "2.0" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	select {
+	case ev := <-received:
+		if string(ev.Data) != "deregister foo" || ev.From != "C0CHAN:U0USER" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the confirmed command")
+	}
+}
+
+func TestConfirmCancel(t *testing.T) {
+	calls, stop := testAPI(t)
+	defer stop()
+
+	conn := newConfirmConn()
+	conn.confirmTimeout = 50 * time.Millisecond
+
+	go conn.Recv(&input.Event{})
+	defer close(conn.exit)
+
+	// cancelled
+	id := requestConfirm(t, conn, calls)
+	conn.actions <- &action{User: "U0USER", Channel: "C0CHAN", ActionID: cancelAction, Value: id}
+
+	if call := <-calls; call.method != "chat.update" || !strings.Contains(call.form.Get("text"), "Cancelled") {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	// expired
+	requestConfirm(t, conn, calls)
+
+	select {
+	case call := <-calls:
+		if call.method != "chat.update" || !strings.Contains(call.form.Get("text"), "expired") {
+			t.Fatalf("unexpected call %+v", call)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for expiry")
+	}
+
+	if len(conn.confirms.pending) > 0 {
+		t.Fatal("expected no pending confirmations")
+	}
+}
+
+func TestActionHandler(t *testing.T) {
+	actions := make(chan *action, 1)
+	srv := httptest.NewServer(actionHandler(testSecret, func(string) chan *action { return actions }, make(chan bool)))
+	defer srv.Close()
+
+	body := url.Values{
+		"payload": {`{"type":"block_actions","user":{"id":"U0USER"},"channel":{"id":"C0CHAN"},"container":{"message_ts":"2.0"},"actions":[{"action_id":"confirm","value":"abc"}]}`},
+	}.Encode()
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", sign(testSecret, ts, body))
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", rsp.StatusCode)
+	}
+
+	a := <-actions
+	if a.User != "U0USER" || a.Channel != "C0CHAN" || a.MessageTS != "2.0" || a.ActionID != confirmAction || a.Value != "abc" {
+		t.Fatalf("unexpected action %+v", a)
+	}
+}
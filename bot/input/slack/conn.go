@@ -0,0 +1,895 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// Meta keys set on received events. Send honours MetaChannel, MetaUser
+// and MetaThreadTS on outgoing events falling back to Event.To.
+const (
+	// MetaChannel is the ID of the channel the message was sent in
+	MetaChannel = "slack_channel"
+	// MetaUser is the ID of the user who sent the message
+	MetaUser = "slack_user"
+	// MetaTS is the timestamp of the message, unique within the channel
+	MetaTS = "slack_ts"
+	// MetaThreadTS is the timestamp of the thread replies are posted in,
+	// empty to reply in the channel
+	MetaThreadTS = "slack_thread_ts"
+	// MetaRawText is the command text as slack sent it, before links and
+	// references were decoded
+	MetaRawText = "slack_raw_text"
+)
+
+// metaString returns the meta value for key if it's a string
+func metaString(meta map[string]interface{}, key string) string {
+	v, _ := meta[key].(string)
+	return v
+}
+
+// rtmClient is the part of the RTM connection used to send messages.
+// It is satisfied by *slack.RTM.
+type rtmClient interface {
+	NewOutgoingMessage(text string, channelID string, options ...slack.RTMsgOption) *slack.OutgoingMessage
+	SendMessage(msg *slack.OutgoingMessage)
+}
+
+// poster is implemented by connections which report delivery errors
+type poster interface {
+	PostMessage(msg *slack.OutgoingMessage) error
+}
+
+// typer is implemented by connections able to send typing indicators
+type typer interface {
+	NewTypingMessage(channelID string) *slack.OutgoingMessage
+}
+
+var (
+	// how often the typing indicator is refreshed
+	typingInterval = 3 * time.Second
+	// how long the typing indicator is shown for at most
+	typingTimeout = 30 * time.Second
+)
+
+// Satisfies the input.Conn interface
+type slackConn struct {
+	auth    *slack.AuthTestResponse
+	token   string
+	api     *slack.Client
+	rtm     rtmClient
+	events  chan slack.RTMEvent
+	slash   chan *slashCommand
+	actions chan *action
+	exit    chan bool
+
+	options
+
+	edits         *edits
+	processed     *processed
+	channels      *channelCache
+	conversations *conversationCache
+	confirms      *confirmations
+	users         *userCache
+	groups        *groupCache
+	stats         *stats
+	// paces outgoing messages, sent directly if nil
+	queue *sendQueue
+	// records executed commands, not audited if nil
+	auditor *asyncAuditor
+	// purges the state above when channels go away
+	state *channelRegistry
+}
+
+// setChannels resolves channel names used by the channel filters
+func (s *slackConn) setChannels() {
+	if len(s.allowChannels) == 0 && len(s.ignoreChannels) == 0 && len(s.commandChannels) == 0 {
+		return
+	}
+
+	channels, err := fetchChannels(s.api)
+	if err != nil {
+		log.Logf("[slack] error retrieving channels: %v", err)
+		return
+	}
+
+	s.channels.set(channels)
+}
+
+func (s *slackConn) run() {
+	t := time.NewTicker(time.Minute)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.exit:
+			return
+		case <-t.C:
+			s.setChannels()
+		}
+	}
+}
+
+// setConnected holds outgoing messages while the rtm is disconnected.
+// Messages posted through the web api don't need the connection.
+func (s *slackConn) setConnected(connected bool) {
+	s.stats.setConnected(connected)
+
+	if _, ok := s.rtm.(poster); ok || s.queue == nil {
+		return
+	}
+	s.queue.setConnected(connected)
+}
+
+// joined caches a channel the bot was added to and greets it
+func (s *slackConn) joined(id, name string, greet bool) {
+	s.conversations.remove(id)
+	s.state.revive(id)
+
+	if len(name) == 0 && s.api != nil {
+		if ch, err := s.api.GetConversationInfo(id, false); err != nil {
+			log.Logf("[slack] error looking up channel %s: %v", id, err)
+		} else {
+			name = ch.Name
+		}
+	}
+
+	s.channels.add(id, name)
+
+	if !greet || len(s.greeting) == 0 || !s.allowed(id) {
+		return
+	}
+
+	greeting := strings.Replace(s.greeting, "{bot}", fmt.Sprintf("<@%s>", s.auth.UserID), -1)
+
+	if err := s.Send(&input.Event{
+		Meta: map[string]interface{}{MetaChannel: id},
+		Type: input.TextEvent,
+		Data: []byte(greeting),
+	}); err != nil {
+		log.Logf("[slack] error greeting %s: %v", id, err)
+	}
+}
+
+// left forgets a channel the bot was removed from or which was archived
+func (s *slackConn) left(id, reason string) {
+	s.state.remove(id, reason)
+}
+
+// fromBot returns true if the message was sent by a bot, including us
+func (s *slackConn) fromBot(ev *slack.MessageEvent) bool {
+	if ev.User == s.auth.UserID {
+		return true
+	}
+
+	if !s.ignoreBots {
+		return false
+	}
+
+	if ev.SubType == "bot_message" || len(ev.BotID) > 0 {
+		return true
+	}
+
+	return s.users.isBot(ev.User)
+}
+
+func (s *slackConn) getName(id string) string {
+	return s.users.name(id)
+}
+
+// allowed returns true if the bot should answer messages in channel
+func (s *slackConn) allowed(channel string) bool {
+	if s.conversations.isDirect(channel) {
+		return s.allowDM
+	}
+
+	if s.channels.contains(s.ignoreChannels, channel) {
+		return false
+	}
+
+	if len(s.allowChannels) > 0 {
+		return s.channels.contains(s.allowChannels, channel)
+	}
+
+	return true
+}
+
+// observed returns true if what the user does in the channel is passed
+// on as an event, the bot's own doings aren't
+func (s *slackConn) observed(channel, user string) bool {
+	return user != s.auth.UserID && s.allowed(channel)
+}
+
+// threadTimestamp returns the thread a reply to ev should be posted in.
+// DMs are never threaded.
+func (s *slackConn) threadTimestamp(ev *slack.MessageEvent) string {
+	switch {
+	case s.conversations.isDirect(ev.Channel):
+		return ""
+	case len(ev.ThreadTimestamp) > 0:
+		return ev.ThreadTimestamp
+	case s.alwaysThread:
+		return ev.Timestamp
+	}
+	return ""
+}
+
+// upload posts data as a text snippet to the channel or thread
+func (s *slackConn) upload(channel, thread, command string, data []byte) error {
+	_, err := s.api.UploadFile(slack.FileUploadParameters{
+		Content:         string(data),
+		Filetype:        "text",
+		Filename:        snippetName(command, time.Now()),
+		Channels:        []string{channel},
+		ThreadTimestamp: thread,
+	})
+	return err
+}
+
+func (s *slackConn) Close() error {
+	select {
+	case <-s.exit:
+	default:
+		close(s.exit)
+	}
+
+	s.stats.setConnected(false)
+
+	// queued messages are abandoned
+	if s.queue != nil {
+		s.queue.wait()
+	}
+
+	return nil
+}
+
+func (s *slackConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-s.exit:
+			return errors.New("connection closed")
+		case e := <-s.events:
+			_, message := e.Data.(*slack.MessageEvent)
+			s.stats.event(message)
+
+			switch ev := e.Data.(type) {
+			case *slack.MessageEvent:
+				// only accept type message
+				if ev.Type != "message" {
+					continue
+				}
+
+				// drop messages from channels we don't answer in
+				if !s.allowed(ev.Channel) {
+					continue
+				}
+
+				// skip messages replayed after a reconnect
+				if len(ev.Timestamp) > 0 && ev.SubType != "message_changed" && !s.processed.first(ev.Channel, ev.Timestamp) {
+					continue
+				}
+
+				// run edited commands once
+				if ev.SubType == "message_changed" {
+					msg, ok := unwrapEdit(ev, s.editWindow)
+					if !ok || !s.edits.once(msg.Channel+":"+msg.Timestamp+":"+msg.Edited.Timestamp, s.editWindow) {
+						continue
+					}
+					ev = msg
+				}
+
+				// never answer bots to avoid reply loops
+				if s.fromBot(ev) {
+					continue
+				}
+
+				// Strip username from text
+				event.To = ""
+				event.Meta = nil
+				if text, ok := stripMention(ev.Text, fmt.Sprintf("<@%s>", s.auth.UserID)); ok {
+					ev.Text = text
+					event.To = s.auth.UserID
+				} else if text, ok := stripMention(ev.Text, s.auth.User); ok {
+					ev.Text = text
+					event.To = s.auth.User
+				} else if text, ok := findMention(ev.Text, fmt.Sprintf("<@%s>", s.auth.UserID)); ok && s.mentionAnywhere {
+					// the command is whatever follows the mention
+					ev.Text = text
+					event.To = s.auth.UserID
+				}
+
+				// strip the trigger prefix once, even if also mentioned
+				var prefixed bool
+				if text, ok := stripPrefix(ev.Text, s.prefix); ok {
+					ev.Text = text
+					if len(event.To) == 0 {
+						event.To = s.auth.UserID
+						prefixed = true
+					}
+				}
+
+				// only accept DMs or messages to me
+				if len(event.To) == 0 && !s.conversations.isDirect(ev.Channel) {
+					continue
+				}
+
+				if !s.accept(event, ev) {
+					continue
+				}
+
+				if prefixed {
+					event.Meta["prefixed"] = true
+				}
+
+				// hold destructive commands until they're confirmed
+				if s.needsConfirm(commandName(ev.Text)) {
+					s.confirm(*event, ev)
+					event.Meta = nil
+					continue
+				}
+
+				return nil
+			case *slack.ReactionAddedEvent:
+				msg, ok := s.reacted(ev)
+				if !ok {
+					if s.observed(ev.Item.Channel, ev.User) && ev.Item.Type == "message" {
+						*event = input.NewEvent(input.ReactionEvent, ev.Item.Channel, ev.User, &input.Reaction{
+							Channel: ev.Item.Channel,
+							User:    ev.User,
+							Message: ev.Item.Timestamp,
+							Emoji:   ev.Reaction,
+						})
+						return nil
+					}
+					continue
+				}
+
+				event.To = s.auth.UserID
+				event.Meta = nil
+
+				if !s.accept(event, msg) {
+					continue
+				}
+
+				if s.needsConfirm(commandName(msg.Text)) {
+					s.confirm(*event, msg)
+					event.Meta = nil
+					continue
+				}
+
+				return nil
+			case *slack.ReactionRemovedEvent:
+				if s.observed(ev.Item.Channel, ev.User) && ev.Item.Type == "message" {
+					*event = input.NewEvent(input.ReactionEvent, ev.Item.Channel, ev.User, &input.Reaction{
+						Channel: ev.Item.Channel,
+						User:    ev.User,
+						Message: ev.Item.Timestamp,
+						Emoji:   ev.Reaction,
+						Removed: true,
+					})
+					return nil
+				}
+			case *slack.UserChangeEvent:
+				s.users.set(&ev.User)
+			case *slack.TeamJoinEvent:
+				s.users.set(&ev.User)
+			case *slack.ChannelJoinedEvent:
+				s.joined(ev.Channel.ID, ev.Channel.Name, false)
+			case *slack.GroupJoinedEvent:
+				s.joined(ev.Channel.ID, ev.Channel.Name, false)
+			case *slack.MemberJoinedChannelEvent:
+				// greet once per invite, channel_joined may also be sent
+				if ev.User == s.auth.UserID {
+					s.joined(ev.Channel, "", true)
+				} else if s.observed(ev.Channel, ev.User) {
+					*event = input.NewEvent(input.JoinEvent, ev.Channel, ev.User, &input.Join{
+						Channel: ev.Channel,
+						User:    ev.User,
+					})
+					return nil
+				}
+			case *slack.ChannelLeftEvent:
+				s.left(ev.Channel, "bot left")
+			case *slack.GroupLeftEvent:
+				s.left(ev.Channel, "bot left")
+			case *slack.MemberLeftChannelEvent:
+				if ev.User == s.auth.UserID {
+					s.left(ev.Channel, "bot removed")
+				} else if s.observed(ev.Channel, ev.User) {
+					*event = input.NewEvent(input.LeaveEvent, ev.Channel, ev.User, &input.Leave{
+						Channel: ev.Channel,
+						User:    ev.User,
+					})
+					return nil
+				}
+			case *slack.ChannelArchiveEvent:
+				s.left(ev.Channel, "channel archived")
+			case *slack.GroupArchiveEvent:
+				s.left(ev.Channel, "channel archived")
+			case *slack.ChannelDeletedEvent:
+				s.left(ev.Channel, "channel deleted")
+			case *slack.ChannelUnarchiveEvent:
+				s.state.revive(ev.Channel)
+			case *slack.GroupUnarchiveEvent:
+				s.state.revive(ev.Channel)
+			case *slack.ConnectedEvent:
+				s.setConnected(true)
+			case *slack.DisconnectedEvent:
+				s.setConnected(false)
+			case *slack.ConnectionErrorEvent:
+				log.Logf("[slack] connection error on attempt %d: %v", ev.Attempt, ev.Error())
+			case *slack.InvalidAuthEvent:
+				return errors.New("invalid credentials")
+			default:
+				if id, ok := changedConversation(e.Data); ok {
+					s.conversations.remove(id)
+				}
+			}
+		case sc := <-s.slash:
+			if !s.allowed(sc.ChannelID) {
+				sc.reply("commands are not accepted in this channel")
+				continue
+			}
+
+			// slash commands are always addressed to the bot
+			ev := &slack.MessageEvent{
+				Msg: slack.Msg{
+					Type:    "message",
+					Channel: sc.ChannelID,
+					User:    sc.UserID,
+					Text:    sc.Text,
+				},
+			}
+
+			event.To = s.auth.UserID
+			event.Meta = map[string]interface{}{"slash": sc}
+
+			if !s.accept(event, ev) {
+				continue
+			}
+
+			if s.needsConfirm(commandName(ev.Text)) {
+				s.confirm(*event, ev)
+				continue
+			}
+
+			return nil
+		case a := <-s.actions:
+			if ev, ok := s.confirmed(a); ok {
+				*event = ev
+				return nil
+			}
+		}
+	}
+}
+
+// accept fills in the event for a message addressed to the bot. It
+// returns false if the user isn't allowed to run the command.
+func (s *slackConn) accept(event *input.Event, ev *slack.MessageEvent) bool {
+	if event.Meta == nil {
+		event.Meta = make(map[string]interface{})
+	}
+
+	// commands see the text as it was typed, raw text is kept for those
+	// wanting slack's formatting
+	event.Meta[MetaRawText] = ev.Text
+	ev.Text = decodeText(ev.Text, s.channels.name, s.users.handle)
+
+	// fill in the blanks
+	event.From = ev.Channel + ":" + ev.User
+	event.Type = input.TextEvent
+	event.Data = []byte(ev.Text)
+	event.Meta["reply"] = ev
+	event.Meta[MetaChannel] = ev.Channel
+	event.Meta[MetaUser] = ev.User
+	event.Meta[MetaTS] = ev.Timestamp
+	event.Meta[MetaThreadTS] = s.threadTimestamp(ev)
+
+	command := commandName(ev.Text)
+
+	// refuse admin commands from everyone else
+	if !s.authorized(ev.User, command) {
+		s.refuse(event, ev, i18n.Text("slack", ev.Channel, i18n.RequiresAdmin, i18n.Params{"name": command}))
+		return false
+	}
+
+	// and those restricted to user groups from non members
+	if groups, ok := s.grouped(ev.User, command); !ok {
+		s.refuse(event, ev, i18n.Text("slack", ev.Channel, i18n.RequiresGroup, i18n.Params{"name": command, "groups": strings.Join(groups, " or ")}))
+		return false
+	}
+
+	// and commands outside the channels they're restricted to
+	if !s.scoped(command, ev.Channel) {
+		s.refuse(event, ev, i18n.Text("slack", ev.Channel, i18n.NotInChannel, i18n.Params{"name": command}))
+		return false
+	}
+
+	return true
+}
+
+// refuse replies to a command which won't be run
+func (s *slackConn) refuse(event *input.Event, ev *slack.MessageEvent, reason string) {
+	meta := map[string]interface{}{"reply": ev}
+	if sc, ok := event.Meta["slash"]; ok {
+		meta["slash"] = sc
+	}
+
+	s.Send(&input.Event{
+		Meta: meta,
+		From: event.To,
+		To:   event.From,
+		Type: input.TextEvent,
+		Data: []byte(reason),
+	})
+}
+
+// react adds the acknowledgement reaction to a message and returns a
+// func which swaps it for a check mark or an x
+func (s *slackConn) react(reply *slack.MessageEvent) func(error) {
+	if len(reply.Timestamp) == 0 || len(s.reaction) == 0 || s.api == nil {
+		return func(error) {}
+	}
+
+	item := slack.NewRefToMessage(reply.Channel, reply.Timestamp)
+
+	if err := s.api.AddReaction(s.reaction, item); err != nil {
+		log.Logf("[slack] error adding reaction: %v", err)
+		return func(error) {}
+	}
+
+	return func(err error) {
+		if err := s.api.RemoveReaction(s.reaction, item); err != nil {
+			log.Logf("[slack] error removing reaction: %v", err)
+		}
+
+		result := "white_check_mark"
+		if err != nil {
+			result = "x"
+		}
+
+		if err := s.api.AddReaction(result, item); err != nil {
+			log.Logf("[slack] error adding reaction: %v", err)
+		}
+	}
+}
+
+// showTyping sends a typing indicator to the channel until stop is called
+// or the typing timeout passes
+func (s *slackConn) showTyping(channel string) func() {
+	t, ok := s.rtm.(typer)
+	if !s.typing || !ok {
+		return func() {}
+	}
+
+	stop := make(chan bool)
+
+	go func() {
+		timeout := time.After(typingTimeout)
+		ticker := time.NewTicker(typingInterval)
+		defer ticker.Stop()
+
+		for {
+			s.rtm.SendMessage(t.NewTypingMessage(channel))
+
+			select {
+			case <-stop:
+				return
+			case <-s.exit:
+				return
+			case <-timeout:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// Notify acknowledges a command by reacting to the message which
+// triggered it and showing the bot typing while it runs. The reaction
+// is swapped for a check mark or an x once the command completes and
+// the command is audited.
+func (s *slackConn) Notify(event input.Event) func(error) {
+	reply, _ := event.Meta["reply"].(*slack.MessageEvent)
+	if reply == nil {
+		return func(error) {}
+	}
+
+	s.stats.command()
+
+	audit := s.record(event, reply)
+
+	if _, ok := event.Meta["slash"]; ok {
+		return audit
+	}
+
+	stopTyping := s.showTyping(reply.Channel)
+	react := s.react(reply)
+
+	return func(err error) {
+		stopTyping()
+		react(err)
+		audit(err)
+	}
+}
+
+// isEphemeral returns true if replies to the command should only be
+// visible to the user who ran it
+func (s *slackConn) isEphemeral(command string) bool {
+	if s.ephemeral {
+		return true
+	}
+	for _, c := range s.ephemeralCommands {
+		if strings.EqualFold(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendRich posts a rich message as blocks
+func (s *slackConn) sendRich(channel, user, thread, prefix string, ephemeral bool, m *richMessage) error {
+	blocks, attachments := m.render(prefix)
+
+	values := url.Values{}
+	values.Set("channel", channel)
+	values.Set("text", prefix+m.plain())
+	if len(thread) > 0 {
+		values.Set("thread_ts", thread)
+	}
+
+	if len(attachments) > 0 {
+		b, err := json.Marshal(attachments)
+		if err != nil {
+			return err
+		}
+		values.Set("attachments", string(b))
+	}
+
+	method := "chat.postMessage"
+	if ephemeral {
+		method = "chat.postEphemeral"
+		values.Set("user", user)
+	}
+
+	_, err := postBlocks(s.client, s.token, method, values, blocks)
+	return err
+}
+
+// formatOutput returns data as it should be sent. Rich messages already
+// carry their own formatting.
+func (s *slackConn) formatOutput(data []byte, rich bool) string {
+	if rich {
+		return string(data)
+	}
+	return formatOutput(s.format, string(data))
+}
+
+// sendSlash replies to a slash command splitting long output
+func (s *slackConn) sendSlash(sc *slashCommand, data []byte, rich bool) error {
+	max := s.maxSize
+	if max <= 0 {
+		max = slack.MaxMessageTextLength
+	}
+
+	for _, message := range splitMessage(s.formatOutput(data, rich), max) {
+		if err := sc.reply(message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MessageLimit is the most characters sent in a message
+func (s *slackConn) MessageLimit() int {
+	if s.maxSize > 0 {
+		return s.maxSize
+	}
+	return slack.MaxMessageTextLength
+}
+
+func (s *slackConn) Send(event *input.Event) error {
+	var channel, user, thread, command string
+
+	// joins, reactions and the like are only received
+	if t := input.TypeOf(event); t != input.TextEvent {
+		return fmt.Errorf("slack can't send %s events, only text", t)
+	}
+
+	metaChannel := metaString(event.Meta, MetaChannel)
+
+	if len(event.To) == 0 && len(metaChannel) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	data := event.Data
+
+	// render structured output as blocks where we can
+	rich, isRich := richOutput(event)
+	if isRich {
+		data = []byte(rich.plain())
+	}
+
+	// keep the output of dm only commands out of channels
+	if reply, _ := event.Meta["reply"].(*slack.MessageEvent); s.redirect(event, reply) {
+		return s.sendDM(event, reply)
+	}
+
+	// answer slash commands through slack's response
+	if sc, ok := event.Meta["slash"].(*slashCommand); ok {
+		return s.sendSlash(sc, data, isRich)
+	}
+
+	parts := strings.Split(event.To, ":")
+	reply, _ := event.Meta["reply"].(*slack.MessageEvent)
+
+	if len(parts) == 2 {
+		channel = parts[0]
+		user = parts[1]
+		// try using reply meta
+	} else if reply != nil {
+		channel = reply.Channel
+		user = reply.User
+	}
+
+	// meta set by the consumer takes precedence
+	if len(metaChannel) > 0 {
+		channel = metaChannel
+		if u := metaString(event.Meta, MetaUser); len(u) > 0 {
+			user = u
+		}
+	}
+
+	// don't know where to send the message
+	if len(channel) == 0 {
+		return errors.New("could not determine who message is to")
+	}
+
+	// the channel was archived or deleted since
+	if s.state.isDead(channel) {
+		return fmt.Errorf("channel %s is gone", channel)
+	}
+
+	if reply != nil {
+		command = commandName(reply.Text)
+	}
+
+	// answer in the thread of the message we're replying to
+	if reply != nil && reply.Channel == channel {
+		thread = s.threadTimestamp(reply)
+	}
+	if ts := metaString(event.Meta, MetaThreadTS); len(ts) > 0 {
+		thread = ts
+	}
+
+	// prefix triggered commands can be answered without the name
+	_, prefixed := event.Meta["prefixed"]
+	plain := prefixed && s.prefixPlain
+
+	var prefix string
+	if len(user) > 0 && !plain && !s.conversations.isDirect(channel) {
+		prefix = fmt.Sprintf("<@%s>: ", user)
+	}
+
+	var opts []slack.RTMsgOption
+	if len(thread) > 0 {
+		opts = append(opts, slack.RTMsgOptionTS(thread))
+	}
+
+	post := func(text string) error {
+		msg := s.rtm.NewOutgoingMessage(text, channel, opts...)
+		if p, ok := s.rtm.(poster); ok {
+			return p.PostMessage(msg)
+		}
+		s.rtm.SendMessage(msg)
+		return nil
+	}
+
+	// only show the user the reply; dms are already private
+	ephemeral := len(user) > 0 && s.api != nil && s.conversations.kind(channel) != imConversation && s.isEphemeral(command)
+
+	if ephemeral {
+		prefix = ""
+		post = func(text string) error {
+			opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+			if len(thread) > 0 {
+				opts = append(opts, slack.MsgOptionTS(thread))
+			}
+			_, err := s.api.PostEphemeral(channel, user, opts...)
+			return err
+		}
+	}
+
+	// stop sending to channels which are gone rather than retrying
+	deliver := post
+	post = func(text string) error {
+		if s.state.isDead(channel) {
+			return nil
+		}
+		err := deliver(text)
+		if isGone(err) {
+			s.state.kill(channel, err)
+		}
+		return err
+	}
+
+	// pace messages to the channel when queued
+	send := func(text string) {
+		if s.queue == nil {
+			if err := post(text); err != nil {
+				log.Logf("[slack] error sending message to %s: %v", channel, err)
+			}
+			return
+		}
+
+		if !s.queue.push(channel, func() error { return post(text) }) {
+			log.Logf("[slack] dropped message to %s, connection closed", channel)
+		}
+	}
+
+	max := s.maxSize
+	if max <= 0 {
+		max = slack.MaxMessageTextLength
+	}
+
+	// large output is uploaded or split whether or not it's rich
+	snippet := !ephemeral && s.snippetSize > 0 && len(data) > s.snippetSize && s.api != nil
+
+	if isRich && s.api != nil && !snippet && len(data) <= max {
+		err := s.sendRich(channel, user, thread, prefix, ephemeral, rich)
+		if err == nil {
+			return nil
+		}
+		if isGone(err) {
+			s.state.kill(channel, err)
+			return err
+		}
+
+		// fall back to sending plain text
+		log.Logf("[slack] error sending blocks to %s: %v", channel, err)
+	}
+
+	// upload large output as a snippet unless it's private
+	if snippet {
+		err := s.upload(channel, thread, command, data)
+		if err == nil {
+			send(prefix + i18n.Text("slack", channel, i18n.OutputAttached, i18n.Params{"size": formatSize(len(data))}))
+			return nil
+		}
+		if isGone(err) {
+			s.state.kill(channel, err)
+			return err
+		}
+
+		// fall back to sending messages
+		log.Logf("[slack] error uploading snippet to %s: %v", channel, err)
+	}
+
+	// split long responses leaving room for the name
+	for i, message := range splitMessage(s.formatOutput(data, isRich), max-len(prefix)) {
+		if i == 0 {
+			message = prefix + message
+		}
+		send(message)
+	}
+
+	return nil
+}
@@ -0,0 +1,1075 @@
+package slack
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+type testRTM struct {
+	sent chan *slack.OutgoingMessage
+}
+
+func (t *testRTM) NewOutgoingMessage(text string, channel string, options ...slack.RTMsgOption) *slack.OutgoingMessage {
+	msg := &slack.OutgoingMessage{
+		Channel: channel,
+		Text:    text,
+		Type:    "message",
+	}
+	for _, o := range options {
+		o(msg)
+	}
+	return msg
+}
+
+func (t *testRTM) NewTypingMessage(channel string) *slack.OutgoingMessage {
+	return &slack.OutgoingMessage{
+		Channel: channel,
+		Type:    "typing",
+	}
+}
+
+func (t *testRTM) SendMessage(msg *slack.OutgoingMessage) {
+	t.sent <- msg
+}
+
+func testChannel(id, name string) slack.Channel {
+	var ch slack.Channel
+	ch.ID = id
+	ch.Name = name
+	return ch
+}
+
+func newTestConn() (*slackConn, *testRTM) {
+	rtm := &testRTM{
+		sent: make(chan *slack.OutgoingMessage, 10),
+	}
+
+	conn := &slackConn{
+		auth: &slack.AuthTestResponse{
+			User:   "micro",
+			UserID: "U0BOT",
+		},
+		rtm:    rtm,
+		events: make(chan slack.RTMEvent, 10),
+		slash:  make(chan *slashCommand, 10),
+		exit:   make(chan bool),
+		options: options{
+			allowDM:    true,
+			editWindow: time.Minute,
+			ignoreBots: true,
+		},
+		channels:      newChannelCache(),
+		conversations: newConversationCache(nil),
+		edits:         newEdits(),
+		processed:     newProcessed(processedSize),
+		users:         newUserCache(nil),
+		groups:        newGroupCache(nil, nil),
+		stats:         &stats{},
+	}
+	conn.users.set(&slack.User{ID: "U0USER", Name: "john"})
+	conn.state = newChannelRegistry(conn)
+
+	return conn, rtm
+}
+
+// exchange delivers msg to the conn and echoes the received event back
+// the way the bot replies, returning the message sent to slack.
+func exchange(t *testing.T, conn *slackConn, rtm *testRTM, msg slack.Msg) *slack.OutgoingMessage {
+	conn.events <- slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{Msg: msg},
+	}
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conn.Send(&input.Event{
+		Meta: ev.Meta,
+		From: ev.To,
+		To:   ev.From,
+		Type: input.TextEvent,
+		Data: []byte("pong"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case m := <-rtm.sent:
+		return m
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+	}
+
+	return nil
+}
+
+func TestSendThread(t *testing.T) {
+	testData := []struct {
+		name         string
+		alwaysThread bool
+		msg          slack.Msg
+		channel      string
+		thread       string
+		text         string
+	}{
+		{
+			name: "mention in thread",
+			msg: slack.Msg{
+				Type:            "message",
+				Channel:         "C0CHAN",
+				User:            "U0USER",
+				Text:            "<@U0BOT> ping",
+				Timestamp:       "1500000002.000200",
+				ThreadTimestamp: "1500000001.000100",
+			},
+			channel: "C0CHAN",
+			thread:  "1500000001.000100",
+			text:    "<@U0USER>: pong",
+		},
+		{
+			name: "top level mention",
+			msg: slack.Msg{
+				Type:      "message",
+				Channel:   "C0CHAN",
+				User:      "U0USER",
+				Text:      "<@U0BOT> ping",
+				Timestamp: "1500000002.000200",
+			},
+			channel: "C0CHAN",
+			text:    "<@U0USER>: pong",
+		},
+		{
+			name:         "always thread top level mention",
+			alwaysThread: true,
+			msg: slack.Msg{
+				Type:      "message",
+				Channel:   "C0CHAN",
+				User:      "U0USER",
+				Text:      "<@U0BOT> ping",
+				Timestamp: "1500000002.000200",
+			},
+			channel: "C0CHAN",
+			thread:  "1500000002.000200",
+			text:    "<@U0USER>: pong",
+		},
+		{
+			name:         "dm is never threaded",
+			alwaysThread: true,
+			msg: slack.Msg{
+				Type:            "message",
+				Channel:         "D0DIRECT",
+				User:            "U0USER",
+				Text:            "ping",
+				Timestamp:       "1500000002.000200",
+				ThreadTimestamp: "1500000001.000100",
+			},
+			channel: "D0DIRECT",
+			text:    "pong",
+		},
+	}
+
+	for _, d := range testData {
+		conn, rtm := newTestConn()
+		conn.alwaysThread = d.alwaysThread
+
+		msg := exchange(t, conn, rtm, d.msg)
+
+		if msg.Channel != d.channel {
+			t.Fatalf("%s: expected channel %s got %s", d.name, d.channel, msg.Channel)
+		}
+		if msg.ThreadTimestamp != d.thread {
+			t.Fatalf("%s: expected thread %q got %q", d.name, d.thread, msg.ThreadTimestamp)
+		}
+		if msg.Text != d.text {
+			t.Fatalf("%s: expected text %q got %q", d.name, d.text, msg.Text)
+		}
+	}
+}
+
+func TestSendSnippet(t *testing.T) {
+	uploads := make(chan *http.Request, 1)
+	fail := false
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth.test":
+			w.Write([]byte(`{"ok":true}`))
+		case "/files.upload":
+			r.ParseForm()
+			uploads <- r
+			if fail {
+				w.Write([]byte(`{"ok":false,"error":"missing_scope"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true,"file":{"id":"F0FILE"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = url }()
+
+	output := strings.Repeat("service\n", 20)
+
+	for _, f := range []bool{false, true} {
+		fail = f
+
+		conn, rtm := newTestConn()
+		conn.api = slack.New("xoxb-test")
+		conn.maxSize = 100
+		conn.snippetSize = 50
+
+		conn.events <- slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: "C0CHAN",
+				User:    "U0USER",
+				Text:    "<@U0BOT> list services",
+			}},
+		}
+
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conn.Send(&input.Event{
+			Meta: ev.Meta,
+			To:   ev.From,
+			Type: input.TextEvent,
+			Data: []byte(output),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		r := <-uploads
+		if r.Form.Get("content") != output {
+			t.Fatalf("unexpected upload content %q", r.Form.Get("content"))
+		}
+		if !strings.HasPrefix(r.Form.Get("filename"), "list-") {
+			t.Fatalf("expected filename to start with command name got %s", r.Form.Get("filename"))
+		}
+		if r.Form.Get("channels") != "C0CHAN" {
+			t.Fatalf("expected upload to C0CHAN got %s", r.Form.Get("channels"))
+		}
+
+		if !fail {
+			msg := <-rtm.sent
+			if msg.Text != "<@U0USER>: output attached (160 bytes)" {
+				t.Fatalf("unexpected summary %q", msg.Text)
+			}
+			continue
+		}
+
+		// failed uploads fall back to split messages, each in a code block
+		expect := strings.TrimSuffix(output, "\n")
+		var chunks []string
+		for len(strings.Join(chunks, "\n")) < len(expect) {
+			select {
+			case msg := <-rtm.sent:
+				chunk := strings.TrimPrefix(msg.Text, "<@U0USER>: ")
+				if !isFenced(chunk) {
+					t.Fatalf("expected fenced chunk got %q", chunk)
+				}
+				chunks = append(chunks, strings.Trim(strings.Trim(chunk, codeFence), "\n"))
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for fallback messages")
+			}
+		}
+		if text := strings.Join(chunks, "\n"); text != expect {
+			t.Fatalf("expected fallback output %q got %q", expect, text)
+		}
+	}
+}
+
+func TestRecvChannelFilter(t *testing.T) {
+	conn, _ := newTestConn()
+	conn.channels.set([]slack.Channel{
+		testChannel("C0OPS", "ops"),
+		testChannel("C0RANDOM", "random"),
+	})
+
+	message := func(channel string) slack.RTMEvent {
+		return slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: channel,
+				User:    "U0USER",
+				Text:    "<@U0BOT> ping",
+			}},
+		}
+	}
+
+	testData := []struct {
+		name    string
+		allow   []string
+		ignore  []string
+		allowDM bool
+		sent    []string
+		recv    string
+	}{
+		{
+			name:    "allowed by name",
+			allow:   []string{"#ops"},
+			allowDM: true,
+			sent:    []string{"C0RANDOM", "C0OTHER", "C0OPS"},
+			recv:    "C0OPS",
+		},
+		{
+			name:    "ignored by name and id",
+			ignore:  []string{"random", "C0OTHER"},
+			allowDM: true,
+			sent:    []string{"C0RANDOM", "C0OTHER", "C0OPS"},
+			recv:    "C0OPS",
+		},
+		{
+			name:    "ignore beats allow",
+			allow:   []string{"ops", "random"},
+			ignore:  []string{"random"},
+			allowDM: true,
+			sent:    []string{"C0RANDOM", "C0OPS"},
+			recv:    "C0OPS",
+		},
+		{
+			name:  "dms disabled",
+			allow: []string{"ops"},
+			sent:  []string{"D0DIRECT", "C0OPS"},
+			recv:  "C0OPS",
+		},
+	}
+
+	for _, d := range testData {
+		conn.allowChannels = d.allow
+		conn.ignoreChannels = d.ignore
+		conn.allowDM = d.allowDM
+
+		for _, ch := range d.sent {
+			conn.events <- message(ch)
+		}
+
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if channel := strings.Split(ev.From, ":")[0]; channel != d.recv {
+			t.Fatalf("%s: expected message from %s got %s", d.name, d.recv, channel)
+		}
+
+		if len(conn.events) > 0 {
+			t.Fatalf("%s: expected all events to be consumed", d.name)
+		}
+	}
+}
+
+func TestRecvAdminCommands(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.users.set(&slack.User{ID: "U0ADMIN", Name: "jane"})
+	conn.admins = []string{"@jane"}
+	conn.adminCommands = []string{"deregister"}
+
+	message := func(channel, user, text string) slack.RTMEvent {
+		return slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: channel,
+				User:    user,
+				Text:    text,
+			}},
+		}
+	}
+
+	// unauthorized attempts in a channel and a dm followed by an admin
+	conn.events <- message("C0CHAN", "U0USER", "<@U0BOT> deregister service foo")
+	conn.events <- message("D0DIRECT", "U0USER", "deregister service foo")
+	conn.events <- message("C0CHAN", "U0ADMIN", "<@U0BOT> deregister service foo")
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev.From != "C0CHAN:U0ADMIN" {
+		t.Fatalf("expected admin event got %s", ev.From)
+	}
+
+	for _, expect := range []string{
+		"<@U0USER>: permission denied: command 'deregister' requires admin",
+		"permission denied: command 'deregister' requires admin",
+	} {
+		select {
+		case msg := <-rtm.sent:
+			if msg.Text != expect {
+				t.Fatalf("expected %q got %q", expect, msg.Text)
+			}
+		default:
+			t.Fatal("expected permission denied message")
+		}
+	}
+}
+
+func TestRecvCommandChannels(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.channels.set([]slack.Channel{testChannel("C0OPS", "ops")})
+	conn.commandChannels = map[string][]string{"deregister": {"#ops"}}
+
+	message := func(channel, text string) slack.RTMEvent {
+		return slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: channel,
+				User:    "U0USER",
+				Text:    text,
+			}},
+		}
+	}
+
+	conn.events <- message("C0CHAN", "<@U0BOT> deregister service foo")
+	conn.events <- message("C0CHAN", "<@U0BOT> list services")
+	conn.events <- message("C0OPS", "<@U0BOT> deregister service foo")
+
+	for _, expect := range []string{"C0CHAN", "C0OPS"} {
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.From != expect+":U0USER" {
+			t.Fatalf("expected event from %s got %s", expect, ev.From)
+		}
+	}
+
+	select {
+	case msg := <-rtm.sent:
+		if msg.Text != "<@U0USER>: command 'deregister' is not allowed in this channel" {
+			t.Fatalf("unexpected message %q", msg.Text)
+		}
+	default:
+		t.Fatal("expected not allowed message")
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected a single refusal")
+	}
+}
+
+func TestRecvEdits(t *testing.T) {
+	conn, _ := newTestConn()
+
+	edit := func(text, posted, edited string) slack.RTMEvent {
+		sub := &slack.Msg{
+			Type:      "message",
+			User:      "U0USER",
+			Text:      text,
+			Timestamp: posted,
+		}
+		if len(edited) > 0 {
+			sub.Edited = &slack.Edited{User: "U0USER", Timestamp: edited}
+		}
+
+		ev := &slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				SubType: "message_changed",
+				Channel: "C0CHAN",
+			},
+			SubMessage: sub,
+		}
+
+		return slack.RTMEvent{Type: "message", Data: ev}
+	}
+
+	// edit outside of the window
+	conn.events <- edit("<@U0BOT> halp", "1500000000.000100", "1500000100.000100")
+	// change without an edit e.g unfurl
+	conn.events <- edit("<@U0BOT> help", "1500000000.000100", "")
+	// edit within the window delivered twice
+	conn.events <- edit("<@U0BOT> help", "1500000000.000100", "1500000010.000100")
+	conn.events <- edit("<@U0BOT> help", "1500000000.000100", "1500000010.000100")
+	// second edit
+	conn.events <- edit("<@U0BOT> ping", "1500000000.000100", "1500000020.000100")
+
+	for _, expect := range []string{"help", "ping"} {
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(ev.Data) != expect {
+			t.Fatalf("expected %s got %s", expect, string(ev.Data))
+		}
+		if ev.From != "C0CHAN:U0USER" {
+			t.Fatalf("expected event from C0CHAN:U0USER got %s", ev.From)
+		}
+	}
+
+	if len(conn.events) > 0 {
+		t.Fatal("expected all events to be consumed")
+	}
+}
+
+func TestRecvIgnoreBots(t *testing.T) {
+	conn, _ := newTestConn()
+	conn.users.set(&slack.User{ID: "U0OTHERBOT", Name: "other", IsBot: true})
+
+	message := func(msg slack.Msg) slack.RTMEvent {
+		msg.Type = "message"
+		msg.Channel = "C0CHAN"
+		msg.Text = "<@U0BOT> ping"
+		return slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: msg}}
+	}
+
+	conn.events <- message(slack.Msg{SubType: "bot_message", BotID: "B0BOT", Username: "other"})
+	conn.events <- message(slack.Msg{User: "U0OTHER", BotID: "B0BOT"})
+	conn.events <- message(slack.Msg{User: "U0OTHERBOT"})
+	conn.events <- message(slack.Msg{User: "U0BOT"})
+	conn.events <- message(slack.Msg{User: "U0USER"})
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev.From != "C0CHAN:U0USER" {
+		t.Fatalf("expected message from U0USER got %s", ev.From)
+	}
+
+	if len(conn.events) > 0 {
+		t.Fatal("expected all events to be consumed")
+	}
+
+	// other bots are answered when allowed
+	conn.ignoreBots = false
+	conn.events <- message(slack.Msg{User: "U0BOT"})
+	conn.events <- message(slack.Msg{User: "U0OTHERBOT"})
+
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev.From != "C0CHAN:U0OTHERBOT" {
+		t.Fatalf("expected message from U0OTHERBOT got %s", ev.From)
+	}
+}
+
+func TestRecvMentionAnywhere(t *testing.T) {
+	conn, _ := newTestConn()
+
+	message := func(text string) slack.RTMEvent {
+		return slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: "C0CHAN",
+				User:    "U0USER",
+				Text:    text,
+			}},
+		}
+	}
+
+	// mid sentence mentions are ignored by default
+	conn.events <- message("thanks <@U0BOT> health")
+	conn.events <- message("<@U0BOT> ping")
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "ping" {
+		t.Fatalf("expected ping got %q", string(ev.Data))
+	}
+
+	conn.mentionAnywhere = true
+	conn.events <- message("talking about micro")
+	conn.events <- message("hey can someone <@U0BOT>, run health")
+
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "run health" || ev.To != "U0BOT" {
+		t.Fatalf("expected run health to U0BOT got %q to %s", string(ev.Data), ev.To)
+	}
+
+	if len(conn.events) > 0 {
+		t.Fatal("expected all events to be consumed")
+	}
+}
+
+func TestRecvPrefix(t *testing.T) {
+	conn, rtm := newTestConn()
+
+	testData := []struct {
+		name   string
+		prefix string
+		plain  bool
+		text   string
+		expect string
+	}{
+		{"disabled", "", false, "!ping", ""},
+		{"prefixed", "!", false, "!ping", "<@U0USER>: pong"},
+		{"plain", "!", true, "! ping", "pong"},
+		{"mentioned and prefixed", "!", true, "<@U0BOT> !ping", "<@U0USER>: pong"},
+		{"bare prefix", "!", false, "!", ""},
+	}
+
+	for _, d := range testData {
+		conn.prefix = d.prefix
+		conn.prefixPlain = d.plain
+
+		msg := slack.Msg{
+			Type:    "message",
+			Channel: "C0CHAN",
+			User:    "U0USER",
+			Text:    d.text,
+		}
+
+		if len(d.expect) == 0 {
+			conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: msg}}
+			conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: "C0CHAN",
+				User:    "U0USER",
+				Text:    "<@U0BOT> next",
+			}}}
+
+			var ev input.Event
+			if err := conn.Recv(&ev); err != nil {
+				t.Fatal(err)
+			}
+			if string(ev.Data) != "next" {
+				t.Fatalf("%s: expected %q to be ignored got %q", d.name, d.text, string(ev.Data))
+			}
+			continue
+		}
+
+		sent := exchange(t, conn, rtm, msg)
+		if sent.Text != d.expect {
+			t.Fatalf("%s: expected %q got %q", d.name, d.expect, sent.Text)
+		}
+	}
+}
+
+func TestMeta(t *testing.T) {
+	conn, rtm := newTestConn()
+
+	conn.events <- slack.RTMEvent{
+		Type: "message",
+		Data: &slack.MessageEvent{Msg: slack.Msg{
+			Type:            "message",
+			Channel:         "C0CHAN",
+			User:            "U0USER",
+			Text:            "<@U0BOT> ping <@U0USER> <https://micro.mu|micro.mu>",
+			Timestamp:       "1500000001.000100",
+			ThreadTimestamp: "1500000000.000100",
+		}},
+	}
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	// commands get the decoded text, the raw text is in meta
+	if string(ev.Data) != "ping @john https://micro.mu" {
+		t.Fatalf("unexpected data %q", string(ev.Data))
+	}
+
+	for key, expect := range map[string]string{
+		MetaChannel:  "C0CHAN",
+		MetaUser:     "U0USER",
+		MetaTS:       "1500000001.000100",
+		MetaThreadTS: "1500000000.000100",
+		MetaRawText:  "ping <@U0USER> <https://micro.mu|micro.mu>",
+	} {
+		if v := metaString(ev.Meta, key); v != expect {
+			t.Fatalf("expected %s %q got %q", key, expect, v)
+		}
+	}
+
+	// meta directs a message without Event.To
+	if err := conn.Send(&input.Event{
+		Meta: map[string]interface{}{
+			MetaChannel:  "C0OTHER",
+			MetaThreadTS: "1400000000.000100",
+		},
+		Type: input.TextEvent,
+		Data: []byte("bridged"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-rtm.sent
+	if msg.Channel != "C0OTHER" || msg.ThreadTimestamp != "1400000000.000100" || msg.Text != "bridged" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// and takes precedence over it
+	if err := conn.Send(&input.Event{
+		Meta: map[string]interface{}{MetaChannel: "C0OTHER"},
+		To:   "C0CHAN:U0USER",
+		Type: input.TextEvent,
+		Data: []byte("pong"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	msg = <-rtm.sent
+	if msg.Channel != "C0OTHER" || msg.Text != "<@U0USER>: pong" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
+
+func TestRecvJoined(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.greeting = "Hi! Try {bot} help"
+	conn.allowChannels = []string{"#new"}
+
+	// events other than text received along the way
+	var others []input.Event
+
+	recv := func(events ...interface{}) {
+		for _, ev := range events {
+			conn.events <- slack.RTMEvent{Data: ev}
+		}
+
+		// a message to return once the events are handled
+		conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: slack.Msg{
+			Type:    "message",
+			Channel: "D0DIRECT",
+			User:    "U0USER",
+			Text:    "ping",
+		}}}
+
+		for {
+			var ev input.Event
+			if err := conn.Recv(&ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type == input.TextEvent {
+				return
+			}
+			others = append(others, ev)
+		}
+	}
+
+	joined := &slack.ChannelJoinedEvent{}
+	joined.Channel.ID = "C0NEW"
+	joined.Channel.Name = "new"
+
+	recv(
+		joined,
+		&slack.MemberJoinedChannelEvent{User: "U0BOT", Channel: "C0NEW"},
+		&slack.MemberJoinedChannelEvent{User: "U0OTHER", Channel: "C0NEW"},
+	)
+
+	if conn.channels.id("#new") != "C0NEW" {
+		t.Fatal("expected the channel to be cached")
+	}
+
+	select {
+	case msg := <-rtm.sent:
+		if msg.Channel != "C0NEW" || msg.Text != "Hi! Try <@U0BOT> help" {
+			t.Fatalf("unexpected greeting %+v", msg)
+		}
+	default:
+		t.Fatal("expected a greeting")
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected a single greeting")
+	}
+
+	// others joining is passed on
+	if len(others) != 1 || others[0].Type != input.JoinEvent || others[0].From != "C0NEW:U0OTHER" {
+		t.Fatalf("expected U0OTHER to join got %+v", others)
+	}
+	if j, ok := input.Payload(&others[0]).(*input.Join); !ok || *j != (input.Join{Channel: "C0NEW", User: "U0OTHER"}) {
+		t.Fatalf("unexpected join %+v", input.Payload(&others[0]))
+	}
+
+	recv(
+		&slack.MemberLeftChannelEvent{User: "U0OTHER", Channel: "C0NEW"},
+		&slack.MemberLeftChannelEvent{User: "U0BOT", Channel: "C0NEW"},
+	)
+
+	if len(others) != 2 || others[1].Type != input.LeaveEvent || others[1].From != "C0NEW:U0OTHER" {
+		t.Fatalf("expected U0OTHER to leave got %+v", others)
+	}
+
+	if len(conn.channels.name("C0NEW")) > 0 {
+		t.Fatal("expected the channel to be forgotten")
+	}
+
+	// no greeting where the bot doesn't answer
+	recv(&slack.MemberJoinedChannelEvent{User: "U0BOT", Channel: "C0RANDOM"})
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected no greeting")
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var calls []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/")+" "+r.Form.Get("name")+" "+r.Form.Get("timestamp"))
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = url }()
+
+	conn, _ := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.reaction = "hourglass"
+
+	ev := input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", Timestamp: "1.2"}},
+		},
+	}
+
+	testData := []struct {
+		err    error
+		result string
+	}{
+		{nil, "white_check_mark"},
+		{errors.New("failed"), "x"},
+	}
+
+	for _, d := range testData {
+		calls = nil
+
+		done := conn.Notify(ev)
+		if len(calls) != 1 || calls[0] != "reactions.add hourglass 1.2" {
+			t.Fatalf("expected hourglass reaction got %v", calls)
+		}
+
+		done(d.err)
+
+		expect := []string{
+			"reactions.add hourglass 1.2",
+			"reactions.remove hourglass 1.2",
+			"reactions.add " + d.result + " 1.2",
+		}
+
+		if strings.Join(calls, ",") != strings.Join(expect, ",") {
+			t.Fatalf("expected calls %v got %v", expect, calls)
+		}
+	}
+}
+
+func TestNotifyTyping(t *testing.T) {
+	interval := typingInterval
+	typingInterval = 10 * time.Millisecond
+	defer func() { typingInterval = interval }()
+
+	conn, rtm := newTestConn()
+	conn.typing = true
+
+	done := conn.Notify(input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", Timestamp: "1.2"}},
+		},
+	})
+
+	// expect the indicator to be refreshed
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-rtm.sent:
+			if msg.Type != "typing" || msg.Channel != "C0CHAN" {
+				t.Fatalf("expected typing in C0CHAN got %+v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for typing indicator")
+		}
+	}
+
+	done(nil)
+
+	// drain an indicator sent while stopping
+	time.Sleep(50 * time.Millisecond)
+	for len(rtm.sent) > 0 {
+		<-rtm.sent
+	}
+
+	select {
+	case msg := <-rtm.sent:
+		t.Fatalf("unexpected message after stopping %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// disabled indicator
+	conn.typing = false
+	conn.Notify(input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN"}},
+		},
+	})(nil)
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected no typing indicator")
+	}
+}
+
+func TestSendEphemeral(t *testing.T) {
+	posts := make(chan string, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		posts <- strings.TrimPrefix(r.URL.Path, "/") + " " + r.Form.Get("channel") + " " + r.Form.Get("user") + " " + r.Form.Get("text")
+		w.Write([]byte(`{"ok":true,"message_ts":"1.3"}`))
+	}))
+	defer srv.Close()
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = url }()
+
+	conn, rtm := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.ephemeralCommands = []string{"secret"}
+
+	send := func(channel, text string) {
+		if err := conn.Send(&input.Event{
+			Meta: map[string]interface{}{
+				"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: channel, User: "U0USER", Text: text}},
+			},
+			To:   channel + ":U0USER",
+			Type: input.TextEvent,
+			Data: []byte("s3cr3t"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ephemeral command in a channel
+	send("C0CHAN", "secret")
+
+	if post := <-posts; post != "chat.postEphemeral C0CHAN U0USER s3cr3t" {
+		t.Fatalf("unexpected post %q", post)
+	}
+
+	// dms fall back to normal messages
+	send("D0DIRECT", "secret")
+
+	if msg := <-rtm.sent; msg.Channel != "D0DIRECT" || msg.Text != "s3cr3t" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// other commands are public
+	send("C0CHAN", "ping")
+
+	if msg := <-rtm.sent; msg.Channel != "C0CHAN" || msg.Text != "<@U0USER>: s3cr3t" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// everything is ephemeral when enabled globally
+	conn.ephemeral = true
+	send("C0CHAN", "ping")
+
+	if post := <-posts; post != "chat.postEphemeral C0CHAN U0USER s3cr3t" {
+		t.Fatalf("unexpected post %q", post)
+	}
+
+	if len(posts) > 0 || len(rtm.sent) > 0 {
+		t.Fatal("unexpected messages sent")
+	}
+}
+
+func TestRecvSlash(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.adminCommands = []string{"deregister"}
+
+	slash := func(text string) *slashCommand {
+		sc := &slashCommand{
+			Command:   "/micro",
+			Text:      text,
+			UserID:    "U0USER",
+			ChannelID: "C0CHAN",
+			waiting:   true,
+			rsp:       make(chan string, 1),
+		}
+		conn.slash <- sc
+		return sc
+	}
+
+	// admin commands are refused before reaching the bot
+	sc := slash("deregister foo")
+	slash("ping")
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp := <-sc.rsp; rsp != "permission denied: command 'deregister' requires admin" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// no mention is required
+	if string(ev.Data) != "ping" || ev.From != "C0CHAN:U0USER" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	// the reply goes back through the slash command
+	sc = ev.Meta["slash"].(*slashCommand)
+	if err := conn.Send(&input.Event{
+		Meta: ev.Meta,
+		From: ev.To,
+		To:   ev.From,
+		Type: input.TextEvent,
+		Data: []byte("pong"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp := <-sc.rsp; rsp != "pong" {
+		t.Fatalf("expected pong got %q", rsp)
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("unexpected messages sent")
+	}
+}
+
+func TestSendEventTypes(t *testing.T) {
+	conn, rtm := newTestConn()
+
+	for _, typ := range []input.EventType{input.JoinEvent, input.LeaveEvent, input.ReactionEvent, input.EditEvent} {
+		err := conn.Send(&input.Event{Type: typ, To: "C0CHAN:U0USER", Data: []byte("hi")})
+		if err == nil || err.Error() != "slack can't send "+string(typ)+" events, only text" {
+			t.Fatalf("%s: unexpected error %v", typ, err)
+		}
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected nothing to be sent")
+	}
+
+	// events without a type are text
+	if err := conn.Send(&input.Event{To: "C0CHAN:U0USER", Data: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-rtm.sent; msg.Text != "<@U0USER>: hi" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+}
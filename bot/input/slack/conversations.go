@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/nlopes/slack"
+)
+
+// conversation types as named by the conversations api
+const (
+	imConversation      = "im"
+	mpimConversation    = "mpim"
+	privateConversation = "private_channel"
+	publicConversation  = "public_channel"
+)
+
+var (
+	// max conversations cached before the oldest are evicted
+	conversationCacheSize = 1000
+	// how long a conversation's type is trusted in case an event was missed
+	conversationTTL = time.Hour
+)
+
+type cachedConversation struct {
+	kind    string
+	expires time.Time
+}
+
+// conversationCache lazily resolves the type of conversations by ID.
+// Conversation IDs no longer reliably encode their type so it's looked
+// up with conversations.info.
+type conversationCache struct {
+	lookup func(id string) (*slack.Channel, error)
+
+	sync.Mutex
+	conversations map[string]cachedConversation
+}
+
+func newConversationCache(lookup func(id string) (*slack.Channel, error)) *conversationCache {
+	return &conversationCache{
+		lookup:        lookup,
+		conversations: make(map[string]cachedConversation),
+	}
+}
+
+// conversationKind returns the type of a conversation
+func conversationKind(ch *slack.Channel) string {
+	switch {
+	case ch.IsIM:
+		return imConversation
+	case ch.IsMpIM:
+		return mpimConversation
+	case ch.IsPrivate || ch.IsGroup:
+		return privateConversation
+	}
+	return publicConversation
+}
+
+// guessKind falls back to the prefix of the ID when a lookup fails
+func guessKind(id string) string {
+	switch {
+	case strings.HasPrefix(id, "D"):
+		return imConversation
+	case strings.HasPrefix(id, "G"):
+		return privateConversation
+	}
+	return publicConversation
+}
+
+// kind returns the type of the conversation looking it up if unknown
+func (c *conversationCache) kind(id string) string {
+	c.Lock()
+	cc, ok := c.conversations[id]
+	c.Unlock()
+
+	if ok && time.Now().Before(cc.expires) {
+		return cc.kind
+	}
+
+	if c.lookup == nil {
+		return guessKind(id)
+	}
+
+	ch, err := c.lookup(id)
+	if err != nil {
+		log.Logf("[slack] error looking up conversation %s: %v", id, err)
+		if ok {
+			return cc.kind
+		}
+		return guessKind(id)
+	}
+
+	kind := conversationKind(ch)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.conversations) >= conversationCacheSize {
+		c.evict()
+	}
+
+	c.conversations[id] = cachedConversation{
+		kind:    kind,
+		expires: time.Now().Add(conversationTTL),
+	}
+
+	return kind
+}
+
+// evict removes the conversation closest to expiring. Must be called
+// with the lock held.
+func (c *conversationCache) evict() {
+	var oldest string
+	var expires time.Time
+
+	for id, cc := range c.conversations {
+		if len(oldest) == 0 || cc.expires.Before(expires) {
+			oldest = id
+			expires = cc.expires
+		}
+	}
+
+	delete(c.conversations, oldest)
+}
+
+// remove drops a conversation so it's looked up again
+func (c *conversationCache) remove(id string) {
+	c.removeChannel(id)
+}
+
+func (c *conversationCache) removeChannel(id string) int {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.conversations[id]; !ok {
+		return 0
+	}
+
+	delete(c.conversations, id)
+	return 1
+}
+
+// isDirect returns true for direct and multi party direct messages
+func (c *conversationCache) isDirect(id string) bool {
+	switch c.kind(id) {
+	case imConversation, mpimConversation:
+		return true
+	}
+	return false
+}
+
+// changedConversation returns the ID of the conversation an event
+// changes, if it's one which could change its type or membership
+func changedConversation(data interface{}) (string, bool) {
+	switch ev := data.(type) {
+	case *slack.ChannelJoinedEvent:
+		return ev.Channel.ID, true
+	case *slack.GroupJoinedEvent:
+		return ev.Channel.ID, true
+	case *slack.ChannelRenameEvent:
+		return ev.Channel.ID, true
+	case *slack.GroupRenameEvent:
+		return ev.Group.ID, true
+	case *slack.ChannelLeftEvent:
+		return ev.Channel, true
+	case *slack.GroupLeftEvent:
+		return ev.Channel, true
+	case *slack.ChannelDeletedEvent:
+		return ev.Channel, true
+	case *slack.ChannelArchiveEvent:
+		return ev.Channel, true
+	case *slack.GroupArchiveEvent:
+		return ev.Channel, true
+	}
+	return "", false
+}
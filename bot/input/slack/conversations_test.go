@@ -0,0 +1,131 @@
+package slack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nlopes/slack"
+)
+
+// testConversations resolves conversations from a fixed set counting lookups
+func testConversations(lookups map[string]int) *conversationCache {
+	channels := map[string]slack.Channel{}
+
+	add := func(id string, set func(ch *slack.Channel)) {
+		ch := slack.Channel{}
+		ch.ID = id
+		set(&ch)
+		channels[id] = ch
+	}
+
+	add("C0IM", func(ch *slack.Channel) { ch.IsIM = true })
+	add("C0MPIM", func(ch *slack.Channel) { ch.IsMpIM = true })
+	add("C0PRIVATE", func(ch *slack.Channel) { ch.IsPrivate = true })
+	add("G0GROUP", func(ch *slack.Channel) { ch.IsGroup = true })
+	add("C0CHAN", func(ch *slack.Channel) { ch.IsChannel = true })
+
+	return newConversationCache(func(id string) (*slack.Channel, error) {
+		lookups[id]++
+		ch, ok := channels[id]
+		if !ok {
+			return nil, errors.New("channel_not_found")
+		}
+		return &ch, nil
+	})
+}
+
+func TestConversationCache(t *testing.T) {
+	lookups := map[string]int{}
+	c := testConversations(lookups)
+
+	testData := []struct {
+		id     string
+		kind   string
+		direct bool
+	}{
+		{"C0IM", imConversation, true},
+		{"C0MPIM", mpimConversation, true},
+		{"C0PRIVATE", privateConversation, false},
+		{"G0GROUP", privateConversation, false},
+		{"C0CHAN", publicConversation, false},
+		// unknown conversations fall back to the ID prefix
+		{"D0UNKNOWN", imConversation, true},
+		{"C0UNKNOWN", publicConversation, false},
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, d := range testData {
+			if kind := c.kind(d.id); kind != d.kind {
+				t.Fatalf("%s: expected %s got %s", d.id, d.kind, kind)
+			}
+			if direct := c.isDirect(d.id); direct != d.direct {
+				t.Fatalf("%s: expected direct %v got %v", d.id, d.direct, direct)
+			}
+		}
+	}
+
+	// known conversations are only looked up once
+	if lookups["C0IM"] != 1 {
+		t.Fatalf("expected 1 lookup got %d", lookups["C0IM"])
+	}
+
+	// events remove the conversation
+	if id, ok := changedConversation(&slack.ChannelLeftEvent{Channel: "C0IM"}); !ok || id != "C0IM" {
+		t.Fatalf("expected C0IM got %q", id)
+	}
+	c.remove("C0IM")
+	c.kind("C0IM")
+	if lookups["C0IM"] != 2 {
+		t.Fatalf("expected 2 lookups got %d", lookups["C0IM"])
+	}
+}
+
+func TestConversationCacheSize(t *testing.T) {
+	size := conversationCacheSize
+	conversationCacheSize = 2
+	defer func() {
+		conversationCacheSize = size
+	}()
+
+	c := testConversations(map[string]int{})
+
+	for _, id := range []string{"C0IM", "C0MPIM", "C0PRIVATE", "C0CHAN"} {
+		c.kind(id)
+	}
+
+	if len(c.conversations) != 2 {
+		t.Fatalf("expected 2 cached conversations got %d", len(c.conversations))
+	}
+	if _, ok := c.conversations["C0CHAN"]; !ok {
+		t.Fatal("expected the newest conversation to be cached")
+	}
+}
+
+func TestRecvMultiPartyDM(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.conversations = testConversations(map[string]int{})
+
+	// no mention is needed and replies aren't addressed
+	msg := exchange(t, conn, rtm, slack.Msg{
+		Type:    "message",
+		Channel: "C0MPIM",
+		User:    "U0USER",
+		Text:    "ping",
+	})
+
+	if msg.Text != "pong" || msg.Channel != "C0MPIM" {
+		t.Fatalf("expected pong in C0MPIM got %q in %s", msg.Text, msg.Channel)
+	}
+
+	// private channels still need a mention
+	msg = exchange(t, conn, rtm, slack.Msg{
+		Type:    "message",
+		Channel: "C0PRIVATE",
+		User:    "U0USER",
+		Text:    "<@U0BOT> ping",
+	})
+
+	if msg.Text != "<@U0USER>: pong" {
+		t.Fatalf("expected <@U0USER>: pong got %q", msg.Text)
+	}
+}
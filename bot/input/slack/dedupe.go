@@ -0,0 +1,52 @@
+package slack
+
+import (
+	"container/list"
+	"sync"
+)
+
+// how many processed messages are remembered
+var processedSize = 1024
+
+// processed is a bounded LRU of messages already handled. Slack replays
+// recent events when the RTM reconnects so it's kept per workspace and
+// outlives the conn.
+type processed struct {
+	size int
+
+	sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func newProcessed(size int) *processed {
+	return &processed{
+		size:  size,
+		order: list.New(),
+		keys:  make(map[string]*list.Element),
+	}
+}
+
+// first returns true the first time it's called for the message with
+// timestamp ts in channel. Timestamps are unique per channel.
+func (p *processed) first(channel, ts string) bool {
+	key := channel + ":" + ts
+
+	p.Lock()
+	defer p.Unlock()
+
+	if e, ok := p.keys[key]; ok {
+		p.order.MoveToFront(e)
+		return false
+	}
+
+	p.keys[key] = p.order.PushFront(key)
+
+	for p.order.Len() > p.size {
+		e := p.order.Back()
+		p.order.Remove(e)
+		delete(p.keys, e.Value.(string))
+	}
+
+	return true
+}
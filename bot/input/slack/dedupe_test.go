@@ -0,0 +1,88 @@
+package slack
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestProcessed(t *testing.T) {
+	p := newProcessed(2)
+
+	if !p.first("C0CHAN", "1.0") || p.first("C0CHAN", "1.0") {
+		t.Fatal("expected only the first call to succeed")
+	}
+
+	// timestamps are only unique per channel
+	if !p.first("C0OTHER", "1.0") {
+		t.Fatal("expected a message in another channel to be new")
+	}
+
+	// the oldest message is evicted
+	p.first("C0CHAN", "2.0")
+	if !p.first("C0CHAN", "1.0") {
+		t.Fatal("expected the oldest message to be evicted")
+	}
+
+	// concurrent callers only see a message once
+	p = newProcessed(processedSize)
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	var firsts int
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p.first("C0CHAN", "3.0") {
+				mtx.Lock()
+				firsts++
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firsts != 1 {
+		t.Fatalf("expected 1 first got %d", firsts)
+	}
+}
+
+func TestRecvReplayed(t *testing.T) {
+	conn, rtm := newTestConn()
+
+	msg := slack.Msg{
+		Type:      "message",
+		Channel:   "C0CHAN",
+		User:      "U0USER",
+		Text:      "<@U0BOT> ping",
+		Timestamp: "1500000000.000100",
+	}
+
+	// the replayed message is skipped on a new conn for the same workspace
+	exchange(t, conn, rtm, msg)
+
+	replay, rtm := newTestConn()
+	replay.processed = conn.processed
+
+	replay.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: msg}}
+	msg.Timestamp = "1500000001.000100"
+	msg.Text = "<@U0BOT> next"
+	replay.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: msg}}
+
+	var ev input.Event
+	if err := replay.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "next" {
+		t.Fatalf("expected the replayed message to be skipped got %q", string(ev.Data))
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatal("expected no messages to be sent")
+	}
+}
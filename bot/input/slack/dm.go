@@ -0,0 +1,81 @@
+package slack
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// marks a reply already redirected to a DM
+const redirectedMeta = "dm_redirected"
+
+// isDMOnly returns true if output of the command must only be sent as a
+// direct message
+func (s *slackConn) isDMOnly(command string) bool {
+	for _, c := range s.dmOnlyCommands {
+		if strings.EqualFold(c, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// redirect returns true if the reply must be sent to the user directly
+// rather than the channel the command was run in
+func (s *slackConn) redirect(event *input.Event, reply *slack.MessageEvent) bool {
+	if reply == nil || event.Meta[redirectedMeta] != nil {
+		return false
+	}
+	if !s.isDMOnly(commandName(reply.Text)) {
+		return false
+	}
+	return s.conversations.kind(reply.Channel) != imConversation
+}
+
+// sendDM delivers the reply in a direct message to the user who ran the
+// command, leaving a notice in the channel
+func (s *slackConn) sendDM(event *input.Event, reply *slack.MessageEvent) error {
+	// the notice goes wherever the reply would have
+	notice := make(map[string]interface{})
+	for k, v := range event.Meta {
+		notice[k] = v
+	}
+	notice[redirectedMeta] = true
+
+	var dm *slack.Channel
+	var err error
+
+	if s.api == nil {
+		err = errors.New("web api unavailable")
+	} else {
+		dm, _, _, err = s.api.OpenConversation(&slack.OpenConversationParameters{
+			Users: []string{reply.User},
+		})
+	}
+
+	text := "I've sent you a DM"
+	if err != nil {
+		text = "could not send you a DM, check you allow direct messages from apps: " + err.Error()
+	}
+
+	if err := s.Send(&input.Event{
+		Meta: notice,
+		To:   event.To,
+		Type: input.TextEvent,
+		Data: []byte(text),
+	}); err != nil || dm == nil {
+		return err
+	}
+
+	return s.Send(&input.Event{
+		Meta: map[string]interface{}{
+			MetaChannel:    dm.ID,
+			MetaUser:       reply.User,
+			redirectedMeta: true,
+		},
+		Type: event.Type,
+		Data: event.Data,
+	})
+}
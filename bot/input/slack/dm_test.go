@@ -0,0 +1,105 @@
+package slack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestSendDMOnly(t *testing.T) {
+	var fail bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.URL.Path != "/conversations.open" || r.Form.Get("users") != "U0USER" {
+			t.Errorf("unexpected call %s %v", r.URL.Path, r.Form)
+		}
+		if fail {
+			w.Write([]byte(`{"ok":false,"error":"cannot_dm_bot"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"channel":{"id":"D0DM"}}`))
+	}))
+	defer srv.Close()
+
+	apiURL := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = apiURL }()
+
+	conn, rtm := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.dmOnlyCommands = []string{"token"}
+
+	run := func(channel, text string) []*slack.OutgoingMessage {
+		conn.events <- slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: channel,
+				User:    "U0USER",
+				Text:    text,
+			}},
+		}
+
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := conn.Send(&input.Event{
+			Meta: ev.Meta,
+			From: ev.To,
+			To:   ev.From,
+			Type: input.TextEvent,
+			Data: []byte("secret-token"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		var sent []*slack.OutgoingMessage
+		for len(rtm.sent) > 0 {
+			sent = append(sent, <-rtm.sent)
+		}
+		return sent
+	}
+
+	testData := []struct {
+		name    string
+		fail    bool
+		channel string
+		text    string
+		expect  []string
+	}{
+		{"redirected", false, "C0CHAN", "<@U0BOT> token", []string{
+			"C0CHAN <@U0USER>: I've sent you a DM",
+			"D0DM secret-token",
+		}},
+		{"dm", false, "D0DIRECT", "token", []string{
+			"D0DIRECT secret-token",
+		}},
+		{"other commands", false, "C0CHAN", "<@U0BOT> list", []string{
+			"C0CHAN <@U0USER>: secret-token",
+		}},
+		{"dms disabled", true, "C0CHAN", "<@U0BOT> token", []string{
+			"C0CHAN <@U0USER>: could not send you a DM, check you allow direct messages from apps: cannot_dm_bot",
+		}},
+	}
+
+	for _, d := range testData {
+		fail = d.fail
+
+		// the channel only ever sees the notice
+		var got []string
+		for _, msg := range run(d.channel, d.text) {
+			got = append(got, msg.Channel+" "+msg.Text)
+		}
+
+		if strings.Join(got, "\n") != strings.Join(d.expect, "\n") {
+			t.Fatalf("%s: expected %q got %q", d.name, d.expect, got)
+		}
+	}
+}
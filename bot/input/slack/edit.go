@@ -0,0 +1,83 @@
+package slack
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// edits tracks edited messages already handled so redelivered
+// edits don't execute twice
+type edits struct {
+	sync.Mutex
+	seen map[string]time.Time
+}
+
+func newEdits() *edits {
+	return &edits{
+		seen: make(map[string]time.Time),
+	}
+}
+
+// once returns true the first time it's called for key
+func (e *edits) once(key string, window time.Duration) bool {
+	e.Lock()
+	defer e.Unlock()
+
+	now := time.Now()
+
+	// forget edits which can no longer be replayed within the window
+	for k, t := range e.seen {
+		if now.Sub(t) > 2*window {
+			delete(e.seen, k)
+		}
+	}
+
+	if _, ok := e.seen[key]; ok {
+		return false
+	}
+
+	e.seen[key] = now
+	return true
+}
+
+// parseTimestamp converts a slack timestamp such as 1500000000.000100
+func parseTimestamp(ts string) (time.Time, bool) {
+	f, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), true
+}
+
+// unwrapEdit returns the edited message carried by a message_changed
+// event if the edit was made within window of the original message
+func unwrapEdit(ev *slack.MessageEvent, window time.Duration) (*slack.MessageEvent, bool) {
+	sub := ev.SubMessage
+	// ignore changes that aren't edits e.g link unfurls
+	if sub == nil || sub.Edited == nil {
+		return nil, false
+	}
+
+	posted, ok := parseTimestamp(sub.Timestamp)
+	if !ok {
+		return nil, false
+	}
+
+	edited, ok := parseTimestamp(sub.Edited.Timestamp)
+	if !ok {
+		return nil, false
+	}
+
+	if edited.Sub(posted) > window {
+		return nil, false
+	}
+
+	msg := &slack.MessageEvent{Msg: *sub}
+	msg.Type = "message"
+	msg.Channel = ev.Channel
+	return msg, true
+}
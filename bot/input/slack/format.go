@@ -0,0 +1,157 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+const (
+	// max length of a section's text
+	maxSectionText = 3000
+	// max number of fields in a section
+	maxSectionFields = 10
+)
+
+// richMessage is structured command output. Commands can return it as
+// JSON to have it rendered as blocks rather than plain text e.g
+//
+//	{"text": "services", "fields": [{"title": "go.micro.srv.greeter", "value": "1 node"}]}
+type richMessage struct {
+	Text   string      `json:"text"`
+	Fields []richField `json:"fields"`
+	Color  string      `json:"color"`
+	Code   string      `json:"code"`
+}
+
+type richField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// attachment is a secondary attachment used to show a color bar
+type attachment struct {
+	Color  string  `json:"color,omitempty"`
+	Blocks []block `json:"blocks"`
+}
+
+// parseRich returns the rich message in data. Anything which isn't a
+// JSON object matching the schema is treated as plain text.
+func parseRich(data []byte) (*richMessage, bool) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var m richMessage
+	if err := dec.Decode(&m); err != nil || dec.More() {
+		return nil, false
+	}
+
+	if len(m.Text) == 0 && len(m.Fields) == 0 && len(m.Code) == 0 {
+		return nil, false
+	}
+
+	return &m, true
+}
+
+// richOutput returns the rich message to send for the event, the
+// response of a rich command or one returned as JSON
+func richOutput(event *input.Event) (*richMessage, bool) {
+	if rsp, ok := event.Meta[command.MetaResponse].(*command.Response); ok && rsp != nil {
+		return fromResponse(rsp), true
+	}
+	return parseRich(event.Data)
+}
+
+// fromResponse returns the rich message for the response of a command
+func fromResponse(rsp *command.Response) *richMessage {
+	m := &richMessage{
+		Text: rsp.Text,
+		Code: rsp.Code,
+	}
+
+	for _, f := range rsp.Fields {
+		m.Fields = append(m.Fields, richField{Title: f.Key, Value: f.Value})
+	}
+
+	switch rsp.Level {
+	case command.LevelWarn:
+		m.Color = "warning"
+	case command.LevelError:
+		m.Color = "danger"
+	}
+
+	return m
+}
+
+// render returns the blocks for the message. A colored message is
+// returned as an attachment since blocks can't be colored.
+func (m *richMessage) render(prefix string) ([]block, []attachment) {
+	var blocks []block
+
+	if text := prefix + m.Text; len(text) > 0 {
+		for _, chunk := range splitMessage(text, maxSectionText) {
+			blocks = append(blocks, sectionBlock(chunk))
+		}
+	}
+
+	for i := 0; i < len(m.Fields); i += maxSectionFields {
+		end := i + maxSectionFields
+		if end > len(m.Fields) {
+			end = len(m.Fields)
+		}
+
+		section := block{Type: "section"}
+		for _, f := range m.Fields[i:end] {
+			section.Fields = append(section.Fields, *markdown(formatField(f)))
+		}
+		blocks = append(blocks, section)
+	}
+
+	if len(m.Code) > 0 {
+		code := codeFence + "\n" + strings.Trim(m.Code, "\n") + "\n" + codeFence
+		for _, chunk := range splitMessage(code, maxSectionText) {
+			blocks = append(blocks, sectionBlock(chunk))
+		}
+	}
+
+	if len(m.Color) > 0 {
+		return nil, []attachment{{Color: m.Color, Blocks: blocks}}
+	}
+
+	return blocks, nil
+}
+
+// plain returns the message as plain text for notifications and
+// anywhere blocks can't be shown
+func (m *richMessage) plain() string {
+	var parts []string
+
+	if len(m.Text) > 0 {
+		parts = append(parts, m.Text)
+	}
+
+	for _, f := range m.Fields {
+		parts = append(parts, formatField(f))
+	}
+
+	if len(m.Code) > 0 {
+		parts = append(parts, codeFence+"\n"+strings.Trim(m.Code, "\n")+"\n"+codeFence)
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+func formatField(f richField) string {
+	if len(f.Title) == 0 {
+		return f.Value
+	}
+	return "*" + f.Title + "*\n" + f.Value
+}
@@ -0,0 +1,220 @@
+package slack
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestParseRich(t *testing.T) {
+	testData := []struct {
+		output string
+		ok     bool
+	}{
+		{`pong`, false},
+		{`{"text": "services"}`, true},
+		{`  {"code": "a\nb"}  `, true},
+		{`{"fields": [{"title": "a", "value": "b"}]}`, true},
+		// valid json but not a rich message
+		{`{"name": "go.micro.srv.greeter"}`, false},
+		{`{"text": "a"} {"text": "b"}`, false},
+		{`{}`, false},
+		{`["a", "b"]`, false},
+		{`{"text": `, false},
+	}
+
+	for _, d := range testData {
+		if _, ok := parseRich([]byte(d.output)); ok != d.ok {
+			t.Fatalf("%q: expected %v got %v", d.output, d.ok, ok)
+		}
+	}
+}
+
+func TestRenderRich(t *testing.T) {
+	testData := []struct {
+		name        string
+		output      string
+		prefix      string
+		blocks      string
+		attachments string
+	}{
+		{
+			name:   "text",
+			output: `{"text": "3 services"}`,
+			blocks: `[{"type":"section","text":{"type":"mrkdwn","text":"3 services"}}]`,
+		},
+		{
+			name:   "prefix",
+			output: `{"text": "3 services"}`,
+			prefix: "@john: ",
+			blocks: `[{"type":"section","text":{"type":"mrkdwn","text":"@john: 3 services"}}]`,
+		},
+		{
+			name:   "fields",
+			output: `{"text": "go.micro.srv.greeter", "fields": [{"title": "version", "value": "latest"}, {"value": "1 node"}]}`,
+			blocks: `[{"type":"section","text":{"type":"mrkdwn","text":"go.micro.srv.greeter"}},` +
+				`{"type":"section","fields":[{"type":"mrkdwn","text":"*version*\nlatest"},{"type":"mrkdwn","text":"1 node"}]}]`,
+		},
+		{
+			name:   "code",
+			output: `{"code": "line one\nline two\n"}`,
+			blocks: "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"```\\nline one\\nline two\\n```\"}}]",
+		},
+		{
+			name:        "color",
+			output:      `{"text": "deploy failed", "color": "danger"}`,
+			attachments: `[{"color":"danger","blocks":[{"type":"section","text":{"type":"mrkdwn","text":"deploy failed"}}]}]`,
+		},
+	}
+
+	for _, d := range testData {
+		m, ok := parseRich([]byte(d.output))
+		if !ok {
+			t.Fatalf("%s: expected rich message", d.name)
+		}
+
+		blocks, attachments := m.render(d.prefix)
+
+		if len(d.blocks) > 0 {
+			b, _ := json.Marshal(blocks)
+			if string(b) != d.blocks {
+				t.Fatalf("%s: expected blocks\n%s\ngot\n%s", d.name, d.blocks, b)
+			}
+		} else if blocks != nil {
+			t.Fatalf("%s: unexpected blocks %+v", d.name, blocks)
+		}
+
+		if len(d.attachments) > 0 {
+			b, _ := json.Marshal(attachments)
+			if string(b) != d.attachments {
+				t.Fatalf("%s: expected attachments\n%s\ngot\n%s", d.name, d.attachments, b)
+			}
+		} else if attachments != nil {
+			t.Fatalf("%s: unexpected attachments %+v", d.name, attachments)
+		}
+	}
+}
+
+func TestRenderRichLimits(t *testing.T) {
+	var fields []richField
+	for i := 0; i < 15; i++ {
+		fields = append(fields, richField{Value: "node"})
+	}
+
+	m := &richMessage{
+		Text:   strings.Repeat("a", maxSectionText+10),
+		Fields: fields,
+	}
+
+	blocks, _ := m.render("")
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks got %d", len(blocks))
+	}
+
+	for _, b := range blocks {
+		if b.Text != nil && len(b.Text.Text) > maxSectionText {
+			t.Fatalf("section text exceeds %d bytes", maxSectionText)
+		}
+		if len(b.Fields) > maxSectionFields {
+			t.Fatalf("section has more than %d fields", maxSectionFields)
+		}
+	}
+}
+
+func TestSendRich(t *testing.T) {
+	calls, stop := testAPI(t)
+	defer stop()
+
+	conn, rtm := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.token = "xoxb-test"
+
+	send := func(data string) {
+		if err := conn.Send(&input.Event{
+			Meta: map[string]interface{}{
+				"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", User: "U0USER", Text: "services", Timestamp: "1.0"}},
+			},
+			To:   "C0CHAN:U0USER",
+			Type: input.TextEvent,
+			Data: []byte(data),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send(`{"text": "3 services"}`)
+
+	call := <-calls
+	if call.method != "chat.postMessage" || call.form.Get("channel") != "C0CHAN" || call.form.Get("text") != "<@U0USER>: 3 services" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+	if !strings.Contains(call.form.Get("blocks"), `"text":"\u003c@U0USER\u003e: 3 services"`) {
+		t.Fatalf("unexpected blocks %s", call.form.Get("blocks"))
+	}
+
+	// responses of rich commands are rendered natively
+	if err := conn.Send(&input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", User: "U0USER", Text: "health", Timestamp: "2.0"}},
+			command.MetaResponse: &command.Response{
+				Text:   "1 node down",
+				Fields: []command.Field{{Key: "go.micro.srv.greeter", Value: "down"}},
+				Level:  command.LevelError,
+			},
+		},
+		To:   "C0CHAN:U0USER",
+		Type: input.TextEvent,
+		Data: []byte("error: 1 node down\ngo.micro.srv.greeter  down"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	call = <-calls
+	if call.form.Get("text") != "<@U0USER>: 1 node down\n*go.micro.srv.greeter*\ndown" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+	if a := call.form.Get("attachments"); !strings.Contains(a, `"color":"danger"`) || !strings.Contains(a, "*go.micro.srv.greeter*") {
+		t.Fatalf("unexpected attachments %s", a)
+	}
+
+	// plain text keeps using the rtm
+	send("pong")
+
+	if msg := <-rtm.sent; msg.Text != "<@U0USER>: pong" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// responses too large for a message are split instead
+	conn.maxSize = 100
+	if err := conn.Send(&input.Event{
+		Meta: map[string]interface{}{
+			"reply":              &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", User: "U0USER", Text: "call", Timestamp: "3.0"}},
+			command.MetaResponse: &command.Response{Text: "Example.Call", Code: strings.Repeat(`{"msg": "hello john"}`+"\n", 10)},
+		},
+		To:   "C0CHAN:U0USER",
+		Type: input.TextEvent,
+		Data: []byte("Example.Call"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-rtm.sent:
+			if len(msg.Text) > 100 {
+				t.Fatalf("message exceeds 100 bytes %q", msg.Text)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the response split into messages")
+		}
+	}
+
+	if len(calls) > 0 {
+		t.Fatal("unexpected api calls")
+	}
+}
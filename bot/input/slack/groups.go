@@ -0,0 +1,127 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/nlopes/slack"
+)
+
+var (
+	// how long group membership is trusted before it's fetched again
+	groupTTL = 10 * time.Minute
+	// minimum time between fetches when a user isn't a member, so
+	// repeated attempts don't hammer the api
+	groupRefresh = 30 * time.Second
+)
+
+type cachedGroup struct {
+	members map[string]bool
+	fetched time.Time
+}
+
+// groupCache resolves user group handles and caches their members.
+// Members are fetched again once stale or when a user isn't found in
+// case they were added since.
+type groupCache struct {
+	list    func() ([]slack.UserGroup, error)
+	members func(id string) ([]string, error)
+
+	sync.Mutex
+	// group IDs keyed by handle
+	handles map[string]string
+	groups  map[string]cachedGroup
+}
+
+func newGroupCache(list func() ([]slack.UserGroup, error), members func(id string) ([]string, error)) *groupCache {
+	return &groupCache{
+		list:    list,
+		members: members,
+		handles: make(map[string]string),
+		groups:  make(map[string]cachedGroup),
+	}
+}
+
+// resolve returns the ID of a group given its handle or ID
+func (c *groupCache) resolve(group string) (string, error) {
+	handle := strings.TrimPrefix(group, "@")
+
+	c.Lock()
+	id, ok := c.handles[handle]
+	c.Unlock()
+
+	if ok {
+		return id, nil
+	}
+
+	if c.list == nil {
+		return "", fmt.Errorf("unknown user group %s", group)
+	}
+
+	groups, err := c.list()
+	if err != nil {
+		return "", err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, g := range groups {
+		c.handles[g.Handle] = g.ID
+		c.handles[g.ID] = g.ID
+	}
+
+	if id, ok := c.handles[handle]; ok {
+		return id, nil
+	}
+
+	return "", fmt.Errorf("unknown user group %s", group)
+}
+
+// isMember returns true if the user is a member of the group
+func (c *groupCache) isMember(group, user string) bool {
+	id, err := c.resolve(group)
+	if err != nil {
+		log.Logf("[slack] error resolving user group %s: %v", group, err)
+		return false
+	}
+
+	c.Lock()
+	g, ok := c.groups[id]
+	c.Unlock()
+
+	age := time.Since(g.fetched)
+
+	switch {
+	case ok && age < groupTTL && g.members[user]:
+		return true
+	case ok && age < groupRefresh:
+		return false
+	case c.members == nil:
+		return g.members[user]
+	}
+
+	members, err := c.members(id)
+	if err != nil {
+		log.Logf("[slack] error retrieving members of user group %s: %v", group, err)
+		// fall back to what we knew
+		return g.members[user]
+	}
+
+	g = cachedGroup{
+		members: make(map[string]bool, len(members)),
+		fetched: time.Now(),
+	}
+	for _, m := range members {
+		g.members[m] = true
+	}
+
+	c.Lock()
+	c.groups[id] = g
+	c.Unlock()
+
+	return g.members[user]
+}
@@ -0,0 +1,136 @@
+package slack
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// testGroups serves the oncall group counting the api calls
+func testGroups(members map[string][]string, calls map[string]int) *groupCache {
+	return newGroupCache(func() ([]slack.UserGroup, error) {
+		calls["list"]++
+		return []slack.UserGroup{
+			{ID: "S0ONCALL", Handle: "oncall"},
+			{ID: "S0SRE", Handle: "sre"},
+		}, nil
+	}, func(id string) ([]string, error) {
+		calls[id]++
+		m, ok := members[id]
+		if !ok {
+			return nil, errors.New("no_such_subteam")
+		}
+		return m, nil
+	})
+}
+
+func TestGroupCache(t *testing.T) {
+	refresh := groupRefresh
+	defer func() { groupRefresh = refresh }()
+
+	members := map[string][]string{"S0ONCALL": {"U0ALICE"}}
+	calls := map[string]int{}
+	c := testGroups(members, calls)
+
+	testData := []struct {
+		group  string
+		user   string
+		member bool
+	}{
+		{"@oncall", "U0ALICE", true},
+		{"oncall", "U0ALICE", true},
+		{"S0ONCALL", "U0ALICE", true},
+		{"@oncall", "U0BOB", false},
+		{"@unknown", "U0ALICE", false},
+	}
+
+	for _, d := range testData {
+		if member := c.isMember(d.group, d.user); member != d.member {
+			t.Fatalf("%s %s: expected member %v got %v", d.group, d.user, d.member, member)
+		}
+	}
+
+	// handles are listed once, unknown ones again in case they were added
+	if calls["list"] != 2 {
+		t.Fatalf("expected 2 list calls got %d", calls["list"])
+	}
+
+	// misses inside the refresh interval are cached
+	if calls["S0ONCALL"] != 1 {
+		t.Fatalf("expected 1 members call got %d", calls["S0ONCALL"])
+	}
+
+	// new members are picked up when the check misses
+	groupRefresh = 0
+	members["S0ONCALL"] = []string{"U0ALICE", "U0BOB"}
+
+	if !c.isMember("@oncall", "U0BOB") {
+		t.Fatal("expected U0BOB to be refreshed as a member")
+	}
+	if calls["S0ONCALL"] != 2 {
+		t.Fatalf("expected 2 members calls got %d", calls["S0ONCALL"])
+	}
+
+	// and cached members aren't fetched again
+	c.isMember("@oncall", "U0ALICE")
+	if calls["S0ONCALL"] != 2 {
+		t.Fatalf("expected 2 members calls got %d", calls["S0ONCALL"])
+	}
+}
+
+func TestRecvCommandGroups(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.groups = testGroups(map[string][]string{
+		"S0ONCALL": {"U0ALICE", "U0BOB"},
+		"S0SRE":    {"U0CAROL"},
+	}, map[string]int{})
+	conn.commandGroups = map[string][]string{"deregister": {"@oncall", "@sre"}}
+	conn.admins = []string{"U0ALICE", "U0USER"}
+	conn.adminCommands = []string{"deregister"}
+
+	message := func(user, text string) slack.RTMEvent {
+		return slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{
+				Type:    "message",
+				Channel: "C0CHAN",
+				User:    user,
+				Text:    "<@U0BOT> " + text,
+			}},
+		}
+	}
+
+	conn.events <- message("U0ALICE", "deregister foo")
+	// admins must still be in a group
+	conn.events <- message("U0USER", "deregister foo")
+	// and group members must still be admins
+	conn.events <- message("U0BOB", "deregister foo")
+	conn.events <- message("U0USER", "list services")
+
+	for _, expect := range []string{"U0ALICE", "U0USER"} {
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.From != "C0CHAN:"+expect {
+			t.Fatalf("expected event from %s got %s", expect, ev.From)
+		}
+	}
+
+	for _, expect := range []string{
+		"sorry, command 'deregister' can only be run by members of @oncall or @sre",
+		"permission denied: command 'deregister' requires admin",
+	} {
+		select {
+		case msg := <-rtm.sent:
+			if !strings.HasSuffix(msg.Text, expect) {
+				t.Fatalf("expected %q got %q", expect, msg.Text)
+			}
+		default:
+			t.Fatalf("expected refusal %q", expect)
+		}
+	}
+}
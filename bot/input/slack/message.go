@@ -0,0 +1,239 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/micro/micro/bot/input"
+)
+
+const (
+	// codeFence marks a preformatted block in slack markdown
+	codeFence = "```"
+)
+
+// isFenced returns true if the whole text is a single code block
+func isFenced(text string) bool {
+	return len(text) >= 2*len(codeFence) &&
+		strings.HasPrefix(text, codeFence) &&
+		strings.HasSuffix(text, codeFence)
+}
+
+// output formats set by slack_format
+const (
+	formatAuto  = "auto"
+	formatPlain = "plain"
+	formatCode  = "code"
+)
+
+// looksLikeJSON returns true if text is a JSON object or array
+func looksLikeJSON(text string) bool {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "[") {
+		return false
+	}
+	return json.Valid([]byte(text))
+}
+
+// formatOutput wraps command output in a code block so slack doesn't
+// mangle it. In auto mode only multi-line output and JSON are wrapped so
+// conversational replies are left as they are.
+func formatOutput(format, text string) string {
+	if len(strings.TrimSpace(text)) == 0 || isFenced(text) {
+		return text
+	}
+
+	switch format {
+	case formatPlain:
+		return text
+	case formatAuto, "":
+		if !strings.Contains(strings.TrimSpace(text), "\n") && !looksLikeJSON(text) {
+			return text
+		}
+	}
+
+	return codeFence + "\n" + strings.Trim(text, "\n") + "\n" + codeFence
+}
+
+// splitMessage splits text into chunks of at most max runes as other
+// inputs do. Text wrapped in a code block has each chunk wrapped again so
+// the formatting survives.
+func splitMessage(text string, max int) []string {
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+
+	// room needed to rewrap a chunk as "```\n" + chunk + "\n```"
+	wrap := 2 * (len(codeFence) + 1)
+
+	if !isFenced(text) || max <= wrap {
+		return input.SplitMessage(text, max)
+	}
+
+	inner := strings.TrimPrefix(text, codeFence)
+	inner = strings.TrimSuffix(inner, codeFence)
+	inner = strings.Trim(inner, "\n")
+
+	var chunks []string
+	for _, chunk := range input.SplitMessage(inner, max-wrap) {
+		chunks = append(chunks, codeFence+"\n"+chunk+"\n"+codeFence)
+	}
+	return chunks
+}
+
+// stripMention removes a leading mention such as "<@U123>" or "<@U123>,"
+// from text. It returns false if the text doesn't start with the mention.
+func stripMention(text, mention string) (string, bool) {
+	if len(mention) == 0 || !strings.HasPrefix(text, mention) {
+		return text, false
+	}
+
+	rest := text[len(mention):]
+	if len(rest) > 0 && !strings.ContainsAny(rest[:1], " ,:") {
+		return text, false
+	}
+
+	return strings.TrimLeft(rest, " ,:"), true
+}
+
+// unescape reverses slack's escaping of & < and >
+var unescape = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+
+// decodeText undoes slack's formatting of message text. Links are
+// unwrapped from angle brackets, channel and user references replaced
+// by #channel and @user and & < > unescaped. Names are looked up with
+// channel and user, either may be nil, falling back to the label slack
+// sent or the ID. Malformed references are left as they are.
+func decodeText(text string, channel, user func(id string) string) string {
+	var b strings.Builder
+
+	for {
+		j := strings.Index(text, ">")
+		if j < 0 {
+			break
+		}
+		// the innermost reference when brackets are nested
+		i := strings.LastIndex(text[:j], "<")
+		if i < 0 {
+			b.WriteString(unescape.Replace(text[:j+1]))
+			text = text[j+1:]
+			continue
+		}
+
+		b.WriteString(unescape.Replace(text[:i]))
+
+		if ref, ok := decodeRef(text[i+1:j], channel, user); ok {
+			b.WriteString(ref)
+		} else {
+			b.WriteString(unescape.Replace(text[i : j+1]))
+		}
+
+		text = text[j+1:]
+	}
+
+	b.WriteString(unescape.Replace(text))
+
+	return b.String()
+}
+
+// decodeRef decodes the contents of a <...> reference
+func decodeRef(ref string, channel, user func(id string) string) (string, bool) {
+	id, label := ref, ""
+	if k := strings.Index(ref, "|"); k >= 0 {
+		id, label = ref[:k], unescape.Replace(ref[k+1:])
+	}
+
+	lookup := func(id string, fn func(string) string) string {
+		if fn != nil {
+			if name := fn(id); len(name) > 0 {
+				return name
+			}
+		}
+		if len(label) > 0 {
+			return label
+		}
+		return id
+	}
+
+	switch {
+	case strings.HasPrefix(id, "http://"), strings.HasPrefix(id, "https://"), strings.HasPrefix(id, "mailto:"):
+		// drop the label slack shows in place of the link
+		return unescape.Replace(id), true
+	case strings.HasPrefix(id, "#") && len(id) > 1:
+		return "#" + strings.TrimPrefix(lookup(id[1:], channel), "#"), true
+	case strings.HasPrefix(id, "@") && len(id) > 1:
+		return "@" + strings.TrimPrefix(lookup(id[1:], user), "@"), true
+	case id == "!here", id == "!channel", id == "!everyone":
+		return "@" + id[1:], true
+	case strings.HasPrefix(id, "!") && len(label) > 0:
+		// user groups and dates carry their own fallback text
+		return label, true
+	}
+
+	return "", false
+}
+
+// stripPrefix removes a trigger prefix such as "!" from text. It returns
+// false if the prefix is empty, missing or not followed by a command.
+func stripPrefix(text, prefix string) (string, bool) {
+	if len(prefix) == 0 || !strings.HasPrefix(text, prefix) {
+		return text, false
+	}
+
+	rest := strings.TrimLeft(text[len(prefix):], " ")
+	if len(rest) == 0 {
+		return text, false
+	}
+
+	return rest, true
+}
+
+// findMention returns the text following the first mention anywhere in
+// text. It returns false if the text doesn't contain the mention.
+func findMention(text, mention string) (string, bool) {
+	i := strings.Index(text, mention)
+	if len(mention) == 0 || i < 0 {
+		return text, false
+	}
+
+	return strings.TrimLeft(text[i+len(mention):], " ,:"), true
+}
+
+// commandName returns the command invoked by text, lowercased and
+// stripped of trailing punctuation as the bot matches it
+func commandName(text string) string {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		return strings.TrimRight(strings.ToLower(fields[0]), "?!.")
+	}
+	return ""
+}
+
+// snippetName returns a file name for command output uploaded at t
+func snippetName(command string, t time.Time) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			return r
+		}
+		return -1
+	}, command)
+
+	if len(name) == 0 {
+		name = "output"
+	}
+
+	return fmt.Sprintf("%s-%s.txt", name, t.Format("20060102-150405"))
+}
+
+// formatSize formats n with thousands separators e.g 12,431
+func formatSize(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
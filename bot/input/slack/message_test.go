@@ -0,0 +1,238 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	testData := []struct {
+		name   string
+		text   string
+		max    int
+		chunks []string
+	}{
+		{
+			name:   "under the limit",
+			text:   "hello",
+			max:    10,
+			chunks: []string{"hello"},
+		},
+		{
+			name:   "exactly at the limit",
+			text:   "0123456789",
+			max:    10,
+			chunks: []string{"0123456789"},
+		},
+		{
+			name:   "one rune over",
+			text:   "01234\n67890",
+			max:    10,
+			chunks: []string{"01234", "67890"},
+		},
+		{
+			name:   "breaks on the last newline",
+			text:   "ab\ncd\nefghij",
+			max:    6,
+			chunks: []string{"ab\ncd", "efghij"},
+		},
+		{
+			name:   "newline at the limit",
+			text:   "0123456789\nabc",
+			max:    10,
+			chunks: []string{"0123456789", "abc"},
+		},
+		{
+			name:   "single line longer than the limit",
+			text:   strings.Repeat("a", 25),
+			max:    10,
+			chunks: []string{strings.Repeat("a", 10), strings.Repeat("a", 10), strings.Repeat("a", 5)},
+		},
+		{
+			name:   "counts runes",
+			text:   "aaaaaaaaaébc",
+			max:    10,
+			chunks: []string{"aaaaaaaaaé", "bc"},
+		},
+		{
+			name:   "code blocks are rewrapped",
+			text:   "```\nline one\nline two\n```",
+			max:    20,
+			chunks: []string{"```\nline one\n```", "```\nline two\n```"},
+		},
+	}
+
+	for _, d := range testData {
+		chunks := splitMessage(d.text, d.max)
+
+		if len(chunks) != len(d.chunks) {
+			t.Fatalf("%s: expected %d chunks got %d: %q", d.name, len(d.chunks), len(chunks), chunks)
+		}
+
+		for i, chunk := range chunks {
+			if chunk != d.chunks[i] {
+				t.Fatalf("%s: expected chunk %d to be %q got %q", d.name, i, d.chunks[i], chunk)
+			}
+			if utf8.RuneCountInString(chunk) > d.max {
+				t.Fatalf("%s: chunk %d exceeds max %d: %q", d.name, i, d.max, chunk)
+			}
+		}
+	}
+}
+
+func TestFormatOutput(t *testing.T) {
+	testData := []struct {
+		format string
+		text   string
+		expect string
+	}{
+		{formatAuto, "pong", "pong"},
+		{formatAuto, "*bold* claim\n", "*bold* claim\n"},
+		{formatAuto, "a\nb", "```\na\nb\n```"},
+		{formatAuto, "\na\nb\n\n", "```\na\nb\n```"},
+		{formatAuto, `{"services":["go.micro.api"]}`, "```\n{\"services\":[\"go.micro.api\"]}\n```"},
+		{formatAuto, "[not json", "[not json"},
+		{formatAuto, "```\na\nb\n```", "```\na\nb\n```"},
+		{formatAuto, "", ""},
+		{formatPlain, "a\nb", "a\nb"},
+		{formatCode, "pong", "```\npong\n```"},
+		{formatCode, "  ", "  "},
+	}
+
+	for _, d := range testData {
+		if got := formatOutput(d.format, d.text); got != d.expect {
+			t.Fatalf("%s %q: expected %q got %q", d.format, d.text, d.expect, got)
+		}
+	}
+}
+
+func TestFormatSplit(t *testing.T) {
+	text := formatOutput(formatAuto, strings.Repeat("line\n", 10))
+
+	for _, chunk := range splitMessage(text, 30) {
+		if !isFenced(chunk) || utf8.RuneCountInString(chunk) > 30 {
+			t.Fatalf("expected fenced chunk within 30 runes got %q", chunk)
+		}
+	}
+}
+
+func TestStripMention(t *testing.T) {
+	testData := []struct {
+		text    string
+		mention string
+		result  string
+		ok      bool
+	}{
+		{"<@U0BOT> help", "<@U0BOT>", "help", true},
+		{"<@U0BOT>, help", "<@U0BOT>", "help", true},
+		{"<@U0BOT>: help", "<@U0BOT>", "help", true},
+		{"<@U0BOT>,help", "<@U0BOT>", "help", true},
+		{"<@U0BOT>    help me", "<@U0BOT>", "help me", true},
+		{"<@U0BOT>", "<@U0BOT>", "", true},
+		{"micro, Help?", "micro", "Help?", true},
+		{"microservices are great", "micro", "microservices are great", false},
+		{"hey <@U0BOT> help", "<@U0BOT>", "hey <@U0BOT> help", false},
+		{"help", "", "help", false},
+	}
+
+	for _, d := range testData {
+		result, ok := stripMention(d.text, d.mention)
+		if ok != d.ok || result != d.result {
+			t.Fatalf("%q: expected %q %v got %q %v", d.text, d.result, d.ok, result, ok)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	names := map[string]string{
+		"C0CHAN": "general",
+		"U0USER": "john",
+	}
+	lookup := func(id string) string {
+		return names[id]
+	}
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		{"health", "health"},
+		{"get <https://micro.mu>", "get https://micro.mu"},
+		{"get <https://micro.mu|micro.mu> now", "get https://micro.mu now"},
+		{"mail <mailto:a@b.com|a@b.com>", "mail mailto:a@b.com"},
+		{"call <@U0USER> in <#C0CHAN|general>", "call @john in #general"},
+		{"call <@U0OTHER|jane> in <#C0OTHER|random>", "call @jane in #random"},
+		{"call <@U0OTHER> in <#C0OTHER>", "call @U0OTHER in #C0OTHER"},
+		{"tell <!here> and <!channel>", "tell @here and @channel"},
+		{"page <!subteam^S0TEAM|@oncall>", "page @oncall"},
+		{"at <!date^1392734382^{date}|Feb 18, 2014>", "at Feb 18, 2014"},
+		{"echo a &amp;&amp; b &lt;c&gt;", "echo a && b <c>"},
+		{"echo &amp;lt;", "echo &lt;"},
+		{"echo <https://a.com?x=1&amp;y=2> &gt; file", "echo https://a.com?x=1&y=2 > file"},
+		{"echo <#C0OTHER|a&amp;b>", "echo #a&b"},
+		{"unclosed <https://micro.mu", "unclosed <https://micro.mu"},
+		{"nested <<https://micro.mu>>", "nested <https://micro.mu>"},
+		{"stray > <https://micro.mu>", "stray > https://micro.mu"},
+		{"empty <> <@> <#> <!>", "empty <> <@> <#> <!>"},
+		{"unknown <foo|bar>", "unknown <foo|bar>"},
+	}
+
+	for _, d := range testData {
+		if got := decodeText(d.text, lookup, lookup); got != d.expect {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, got)
+		}
+	}
+
+	// ids are kept without lookups
+	if got := decodeText("<@U0USER> <#C0CHAN>", nil, nil); got != "@U0USER #C0CHAN" {
+		t.Fatalf("unexpected %q", got)
+	}
+}
+
+func TestStripPrefix(t *testing.T) {
+	testData := []struct {
+		text   string
+		prefix string
+		result string
+		ok     bool
+	}{
+		{"!health foo", "!", "health foo", true},
+		{"! health", "!", "health", true},
+		{"!!health", "!!", "health", true},
+		{"!", "!", "!", false},
+		{"health!", "!", "health!", false},
+		{"health", "", "health", false},
+	}
+
+	for _, d := range testData {
+		result, ok := stripPrefix(d.text, d.prefix)
+		if ok != d.ok || result != d.result {
+			t.Fatalf("%q: expected %q %v got %q %v", d.text, d.result, d.ok, result, ok)
+		}
+	}
+}
+
+func TestFindMention(t *testing.T) {
+	testData := []struct {
+		text    string
+		mention string
+		result  string
+		ok      bool
+	}{
+		{"<@U0BOT> help", "<@U0BOT>", "help", true},
+		{"hey <@U0BOT> health", "<@U0BOT>", "health", true},
+		{"hey <@U0BOT>, list services please", "<@U0BOT>", "list services please", true},
+		{"can someone run health, <@U0BOT>?", "<@U0BOT>", "?", true},
+		{"thanks <@U0BOT>", "<@U0BOT>", "", true},
+		{"hey <@U0OTHER> help", "<@U0BOT>", "hey <@U0OTHER> help", false},
+		{"help", "", "help", false},
+	}
+
+	for _, d := range testData {
+		result, ok := findMention(d.text, d.mention)
+		if ok != d.ok || result != d.result {
+			t.Fatalf("%q: expected %q %v got %q %v", d.text, d.result, d.ok, result, ok)
+		}
+	}
+}
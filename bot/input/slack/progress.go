@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"strings"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// progressInterval is how often the progress of a command is updated,
+// within slack's limit of a message per second
+var progressInterval = time.Second
+
+// Progress shows the progress of a command in a single message edited
+// in place. Without the web api messages can't be edited, so progress
+// is posted in the command's thread instead.
+func (s *slackConn) Progress(event input.Event) (func([]string), time.Duration) {
+	reply, _ := event.Meta["reply"].(*slack.MessageEvent)
+	if reply == nil {
+		return nil, 0
+	}
+
+	// slash commands are only answered through their response url
+	if _, ok := event.Meta["slash"]; ok {
+		return nil, 0
+	}
+
+	channel := reply.Channel
+
+	if s.api == nil {
+		thread := reply.ThreadTimestamp
+		if len(thread) == 0 {
+			thread = reply.Timestamp
+		}
+
+		return func(lines []string) {
+			msg := s.rtm.NewOutgoingMessage(strings.Join(lines, "\n"), channel, slack.RTMsgOptionTS(thread))
+			if p, ok := s.rtm.(poster); ok {
+				if err := p.PostMessage(msg); err != nil {
+					log.Logf("[slack] error posting progress to %s: %v", channel, err)
+				}
+				return
+			}
+			s.rtm.SendMessage(msg)
+		}, progressInterval
+	}
+
+	thread := s.threadTimestamp(reply)
+
+	max := s.maxSize
+	if max <= 0 {
+		max = slack.MaxMessageTextLength
+	}
+
+	var shown []string
+	var ts string
+
+	return func(lines []string) {
+		shown = append(shown, lines...)
+
+		// keep the latest progress once it's too long for a message
+		for len(shown) > 1 && len(strings.Join(shown, "\n")) > max {
+			shown = shown[1:]
+		}
+		text := strings.Join(shown, "\n")
+
+		var err error
+		if len(ts) == 0 {
+			opts := []slack.MsgOption{slack.MsgOptionText(text, false)}
+			if len(thread) > 0 {
+				opts = append(opts, slack.MsgOptionTS(thread))
+			}
+			_, ts, err = s.api.PostMessage(channel, opts...)
+		} else {
+			_, _, _, err = s.api.UpdateMessage(channel, ts, slack.MsgOptionText(text, false))
+		}
+
+		if err != nil {
+			log.Logf("[slack] error updating progress in %s: %v", channel, err)
+		}
+	}, progressInterval
+}
@@ -0,0 +1,58 @@
+package slack
+
+import (
+	"testing"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestProgress(t *testing.T) {
+	calls, stop := testAPI(t)
+	defer stop()
+
+	event := input.Event{
+		Meta: map[string]interface{}{
+			"reply": &slack.MessageEvent{Msg: slack.Msg{Channel: "C0CHAN", User: "U0USER", Text: "deploy api", Timestamp: "1.0"}},
+		},
+	}
+
+	// the progress message is posted then edited in place
+	conn, _ := newTestConn()
+	conn.api = slack.New("xoxb-test")
+
+	update, interval := conn.Progress(event)
+	if update == nil || interval != progressInterval {
+		t.Fatalf("expected progress every %v got %v", progressInterval, interval)
+	}
+
+	update([]string{"building api"})
+
+	call := <-calls
+	if call.method != "chat.postMessage" || call.form.Get("channel") != "C0CHAN" || call.form.Get("text") != "building api" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	update([]string{"pushing image", "rolling out"})
+
+	call = <-calls
+	if call.method != "chat.update" || call.form.Get("ts") != "2.0" || call.form.Get("text") != "building api\npushing image\nrolling out" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	// the rtm can't edit so progress goes in the command's thread
+	conn, rtm := newTestConn()
+
+	update, _ = conn.Progress(event)
+	update([]string{"building api"})
+
+	if msg := <-rtm.sent; msg.Text != "building api" || msg.ThreadTimestamp != "1.0" || msg.Channel != "C0CHAN" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	// slash commands don't show progress
+	event.Meta["slash"] = &slashCommand{}
+	if update, _ := conn.Progress(event); update != nil {
+		t.Fatal("unexpected progress for a slash command")
+	}
+}
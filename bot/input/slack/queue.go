@@ -0,0 +1,225 @@
+package slack
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/nlopes/slack"
+)
+
+// minimum time between messages sent to a channel
+var sendInterval = time.Second
+
+// queued is a message waiting to be sent
+type queued struct {
+	send func() error
+	time time.Time
+}
+
+// sendQueue paces messages per channel so one busy channel doesn't hold
+// up the others. Sends which are rate limited are retried after the
+// delay slack asks for. While disconnected messages are held, up to size
+// per channel, and those older than maxAge are dropped rather than sent
+// late. Queued messages are abandoned once exit closes.
+type sendQueue struct {
+	exit     chan bool
+	interval time.Duration
+	attempts int
+	size     int
+	maxAge   time.Duration
+
+	sync.Mutex
+	channels map[string]chan queued
+	wg       sync.WaitGroup
+	// closed while connected
+	connected chan bool
+}
+
+func newSendQueue(exit chan bool, interval time.Duration, attempts, size int, maxAge time.Duration) *sendQueue {
+	if attempts <= 0 {
+		attempts = 1
+	}
+	if size <= 0 {
+		size = 1
+	}
+
+	connected := make(chan bool)
+	close(connected)
+
+	return &sendQueue{
+		exit:      exit,
+		interval:  interval,
+		attempts:  attempts,
+		size:      size,
+		maxAge:    maxAge,
+		channels:  make(map[string]chan queued),
+		connected: connected,
+	}
+}
+
+// setConnected holds messages while disconnected and flushes them once
+// connected again
+func (q *sendQueue) setConnected(connected bool) {
+	q.Lock()
+	defer q.Unlock()
+
+	select {
+	case <-q.connected:
+		if !connected {
+			q.connected = make(chan bool)
+		}
+	default:
+		if connected {
+			close(q.connected)
+		}
+	}
+}
+
+// waitConnected blocks until connected returning false if the queue is
+// closed first
+func (q *sendQueue) waitConnected() bool {
+	q.Lock()
+	connected := q.connected
+	q.Unlock()
+
+	select {
+	case <-connected:
+		return true
+	case <-q.exit:
+		return false
+	}
+}
+
+// push queues send for the channel. It returns false if the queue has
+// been closed or the channel's buffer is full.
+func (q *sendQueue) push(channel string, send func() error) bool {
+	select {
+	case <-q.exit:
+		return false
+	default:
+	}
+
+	q.Lock()
+	ch, ok := q.channels[channel]
+	if !ok {
+		ch = make(chan queued, q.size)
+		q.channels[channel] = ch
+		q.wg.Add(1)
+		go q.run(channel, ch)
+	}
+	q.Unlock()
+
+	select {
+	case <-q.exit:
+		return false
+	case ch <- queued{send, time.Now()}:
+		return true
+	default:
+		log.Logf("[slack] dropped message to %s, %d messages already queued", channel, q.size)
+		return false
+	}
+}
+
+// removeChannel drops the messages queued for the channel
+func (q *sendQueue) removeChannel(channel string) int {
+	q.Lock()
+	ch, ok := q.channels[channel]
+	q.Unlock()
+
+	if !ok {
+		return 0
+	}
+
+	var n int
+	for {
+		select {
+		case <-ch:
+			n++
+		default:
+			return n
+		}
+	}
+}
+
+// wait blocks until every channel has stopped sending
+func (q *sendQueue) wait() {
+	q.wg.Wait()
+}
+
+// sleep waits for d returning false if the queue is closed first
+func (q *sendQueue) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	select {
+	case <-q.exit:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (q *sendQueue) run(channel string, ch chan queued) {
+	defer q.wg.Done()
+
+	var last time.Time
+
+	for {
+		// leave messages buffered while disconnected
+		if !q.waitConnected() {
+			if n := len(ch); n > 0 {
+				log.Logf("[slack] abandoned %d messages to %s", n, channel)
+			}
+			return
+		}
+
+		select {
+		case <-q.exit:
+			if n := len(ch); n > 0 {
+				log.Logf("[slack] abandoned %d messages to %s", n, channel)
+			}
+			return
+		case msg := <-ch:
+			if !q.waitConnected() || !q.sleep(q.interval-time.Since(last)) {
+				log.Logf("[slack] abandoned %d messages to %s", len(ch)+1, channel)
+				return
+			}
+
+			// too late to be useful
+			if q.maxAge > 0 && time.Since(msg.time) > q.maxAge {
+				log.Logf("[slack] dropped message to %s queued %v ago", channel, time.Since(msg.time))
+				continue
+			}
+
+			q.send(channel, msg.send)
+			last = time.Now()
+		}
+	}
+}
+
+// send delivers a message retrying while it's rate limited
+func (q *sendQueue) send(channel string, send func() error) {
+	for i := 1; ; i++ {
+		err := send()
+		if err == nil {
+			return
+		}
+
+		rl, ok := err.(*slack.RateLimitedError)
+		if !ok {
+			log.Logf("[slack] error sending message to %s: %v", channel, err)
+			return
+		}
+
+		if i >= q.attempts {
+			log.Logf("[slack] giving up sending message to %s after %d attempts: %v", channel, i, err)
+			return
+		}
+
+		if !q.sleep(rl.RetryAfter) {
+			return
+		}
+	}
+}
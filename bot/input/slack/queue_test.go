@@ -0,0 +1,232 @@
+package slack
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// testSender records the messages sent to each channel. It returns the
+// queued errors before succeeding.
+type testSender struct {
+	sync.Mutex
+	errs []error
+	sent map[string][]time.Time
+	done chan string
+}
+
+func newTestSender(errs ...error) *testSender {
+	return &testSender{
+		errs: errs,
+		sent: make(map[string][]time.Time),
+		done: make(chan string, 10),
+	}
+}
+
+func (t *testSender) send(channel string) func() error {
+	return func() error {
+		t.Lock()
+		defer t.Unlock()
+
+		t.sent[channel] = append(t.sent[channel], time.Now())
+
+		if len(t.errs) > 0 {
+			err := t.errs[0]
+			t.errs = t.errs[1:]
+			return err
+		}
+
+		t.done <- channel
+		return nil
+	}
+}
+
+func (t *testSender) count(channel string) int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.sent[channel])
+}
+
+func (t *testSender) wait(tt *testing.T, n int) []string {
+	var channels []string
+	for i := 0; i < n; i++ {
+		select {
+		case ch := <-t.done:
+			channels = append(channels, ch)
+		case <-time.After(time.Second):
+			tt.Fatalf("timed out waiting for message %d", i+1)
+		}
+	}
+	return channels
+}
+
+func TestSendQueuePacing(t *testing.T) {
+	exit := make(chan bool)
+	defer close(exit)
+
+	interval := 50 * time.Millisecond
+	q := newSendQueue(exit, interval, 3, 10, 0)
+	s := newTestSender()
+
+	for i := 0; i < 3; i++ {
+		q.push("C0BUSY", s.send("C0BUSY"))
+	}
+	q.push("C0QUIET", s.send("C0QUIET"))
+
+	// the quiet channel isn't held up by the busy one
+	channels := s.wait(t, 4)
+	if channels[0] == channels[1] && channels[1] == channels[2] {
+		t.Fatalf("expected the quiet channel earlier got %v", channels)
+	}
+
+	s.Lock()
+	sent := s.sent["C0BUSY"]
+	s.Unlock()
+
+	for i := 1; i < len(sent); i++ {
+		if d := sent[i].Sub(sent[i-1]); d < interval {
+			t.Fatalf("expected messages %v apart got %v", interval, d)
+		}
+	}
+}
+
+func TestSendQueueRetry(t *testing.T) {
+	exit := make(chan bool)
+	defer close(exit)
+
+	limited := &slack.RateLimitedError{RetryAfter: 10 * time.Millisecond}
+
+	// retried until it succeeds
+	q := newSendQueue(exit, 0, 3, 10, 0)
+	s := newTestSender(limited, limited)
+	q.push("C0CHAN", s.send("C0CHAN"))
+	s.wait(t, 1)
+
+	if c := s.count("C0CHAN"); c != 3 {
+		t.Fatalf("expected 3 attempts got %d", c)
+	}
+
+	// gives up after the max attempts
+	q = newSendQueue(exit, 0, 2, 10, 0)
+	s = newTestSender(limited, limited, limited)
+	q.push("C0CHAN", s.send("C0CHAN"))
+	q.push("C0CHAN", s.send("C0CHAN"))
+
+	// the next message is sent once the first is dropped
+	s.wait(t, 1)
+	if c := s.count("C0CHAN"); c != 4 {
+		t.Fatalf("expected 4 attempts got %d", c)
+	}
+
+	// other errors aren't retried
+	q = newSendQueue(exit, 0, 3, 10, 0)
+	s = newTestSender(errors.New("channel_not_found"))
+	q.push("C0CHAN", s.send("C0CHAN"))
+	q.push("C0CHAN", s.send("C0CHAN"))
+
+	s.wait(t, 1)
+	if c := s.count("C0CHAN"); c != 2 {
+		t.Fatalf("expected 2 attempts got %d", c)
+	}
+}
+
+func TestSendQueueClose(t *testing.T) {
+	exit := make(chan bool)
+
+	q := newSendQueue(exit, time.Hour, 3, 10, 0)
+	s := newTestSender()
+
+	for i := 0; i < 3; i++ {
+		q.push("C0CHAN", s.send("C0CHAN"))
+	}
+	s.wait(t, 1)
+
+	close(exit)
+
+	done := make(chan bool)
+	go func() {
+		q.wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the queue to stop")
+	}
+
+	if c := s.count("C0CHAN"); c != 1 {
+		t.Fatalf("expected queued messages to be abandoned got %d sent", c)
+	}
+
+	if q.push("C0CHAN", s.send("C0CHAN")) {
+		t.Fatal("expected push to fail once closed")
+	}
+}
+
+func TestSendQueueDisconnected(t *testing.T) {
+	exit := make(chan bool)
+	defer close(exit)
+
+	sent := make(chan string, 10)
+	send := func(text string) func() error {
+		return func() error {
+			sent <- text
+			return nil
+		}
+	}
+
+	q := newSendQueue(exit, 0, 3, 2, time.Minute)
+	q.setConnected(false)
+
+	var pushed []string
+	for _, text := range []string{"first", "second", "third"} {
+		if q.push("C0CHAN", send(text)) {
+			pushed = append(pushed, text)
+		}
+	}
+
+	if len(pushed) != 2 {
+		t.Fatalf("expected the buffer to hold 2 messages got %v", pushed)
+	}
+
+	select {
+	case text := <-sent:
+		t.Fatalf("expected nothing sent while disconnected got %s", text)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// flushed in order once connected
+	q.setConnected(true)
+
+	for _, expect := range pushed {
+		select {
+		case text := <-sent:
+			if text != expect {
+				t.Fatalf("expected %s got %s", expect, text)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", expect)
+		}
+	}
+
+	// stale messages are dropped
+	q = newSendQueue(exit, 0, 3, 10, 10*time.Millisecond)
+	q.setConnected(false)
+	q.push("C0CHAN", send("stale"))
+	time.Sleep(20 * time.Millisecond)
+	q.push("C0CHAN", send("fresh"))
+	q.setConnected(true)
+
+	select {
+	case text := <-sent:
+		if text != "fresh" {
+			t.Fatalf("expected fresh got %s", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for fresh")
+	}
+}
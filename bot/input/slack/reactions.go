@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input/tokenize"
+	"github.com/nlopes/slack"
+)
+
+// parseReactionCommands parses the commands run by reactions
+// e.g "recycle=redeploy,eyes=status web"
+func parseReactionCommands(s string) (map[string]string, error) {
+	commands := make(map[string]string)
+
+	for _, entry := range splitList(s) {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid reaction command %q: expected emoji=command", entry)
+		}
+
+		emoji := strings.Trim(strings.TrimSpace(parts[0]), ":")
+		if len(emoji) == 0 {
+			return nil, fmt.Errorf("invalid reaction command %q: missing emoji", entry)
+		}
+
+		command := strings.TrimSpace(parts[1])
+		if len(command) == 0 {
+			return nil, fmt.Errorf("invalid reaction command %q: missing command", entry)
+		}
+
+		if _, ok := commands[emoji]; ok {
+			return nil, fmt.Errorf("invalid reaction command %q: %s listed twice", entry, emoji)
+		}
+
+		commands[emoji] = command
+	}
+
+	return commands, nil
+}
+
+// reacted returns the command a reaction triggers as if the user had
+// sent it in the thread of the message reacted to. The text of that
+// message is appended as arguments.
+func (s *slackConn) reacted(ev *slack.ReactionAddedEvent) (*slack.MessageEvent, bool) {
+	command, ok := s.reactionCommands[ev.Reaction]
+	if !ok || ev.Item.Type != "message" {
+		return nil, false
+	}
+
+	// ignore our own reactions and other bots
+	if ev.User == s.auth.UserID || s.users.isBot(ev.User) {
+		return nil, false
+	}
+
+	if !s.allowed(ev.Item.Channel) {
+		return nil, false
+	}
+
+	msg, err := s.message(ev.Item.Channel, ev.Item.Timestamp)
+	if err != nil {
+		log.Logf("[slack] error retrieving message reacted to with %s: %v", ev.Reaction, err)
+		return nil, false
+	}
+
+	thread := msg.ThreadTimestamp
+	if len(thread) == 0 {
+		thread = msg.Timestamp
+	}
+
+	text := command
+	if args := strings.TrimSpace(msg.Text); len(args) > 0 {
+		text += " " + tokenize.Escape(args)
+	}
+
+	return &slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:            "message",
+			Channel:         ev.Item.Channel,
+			User:            ev.User,
+			Text:            text,
+			Timestamp:       msg.Timestamp,
+			ThreadTimestamp: thread,
+		},
+	}, true
+}
+
+// message retrieves a message whether or not it's in a thread
+func (s *slackConn) message(channel, ts string) (*slack.Message, error) {
+	msgs, _, _, err := s.api.GetConversationReplies(&slack.GetConversationRepliesParameters{
+		ChannelID: channel,
+		Timestamp: ts,
+		Inclusive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// replies to a thread are listed after the parent
+	for i := range msgs {
+		if msgs[i].Timestamp == ts {
+			return &msgs[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("message %s not found", ts)
+}
@@ -0,0 +1,179 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/tokenize"
+	"github.com/nlopes/slack"
+)
+
+func TestParseReactionCommands(t *testing.T) {
+	testData := []struct {
+		value  string
+		expect map[string]string
+		err    bool
+	}{
+		{"", map[string]string{}, false},
+		{"recycle=redeploy", map[string]string{"recycle": "redeploy"}, false},
+		{":recycle:=redeploy, eyes=status web", map[string]string{"recycle": "redeploy", "eyes": "status web"}, false},
+		{"recycle", nil, true},
+		{"=redeploy", nil, true},
+		{"recycle=", nil, true},
+		{"recycle=redeploy,recycle=status", nil, true},
+	}
+
+	for _, d := range testData {
+		commands, err := parseReactionCommands(d.value)
+		if d.err {
+			if err == nil {
+				t.Fatalf("%q: expected error", d.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.value, err)
+		}
+		if !reflect.DeepEqual(commands, d.expect) {
+			t.Fatalf("%q: expected %v got %v", d.value, d.expect, commands)
+		}
+	}
+}
+
+func TestRecvReaction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("ts") {
+		case "1.0":
+			w.Write([]byte(`{"ok":true,"messages":[{"type":"message","ts":"1.0","text":"deploy web it's &lt;v2&gt;"}]}`))
+		case "3.0":
+			w.Write([]byte(`{"ok":true,"messages":[{"ts":"2.0","text":"parent"},{"ts":"3.0","thread_ts":"2.0","text":"deploy api"}]}`))
+		default:
+			w.Write([]byte(`{"ok":false,"error":"thread_not_found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = url }()
+
+	conn, rtm := newTestConn()
+	conn.api = slack.New("xoxb-test")
+	conn.reactionCommands = map[string]string{"recycle": "redeploy"}
+	conn.users.set(&slack.User{ID: "U0OTHERBOT", Name: "jenkins", IsBot: true})
+
+	// reaction items aren't exported so events are decoded as delivered
+	event := func(typ, user, emoji, ts string) slack.RTMEvent {
+		ev, ok := decodeEvent(json.RawMessage(fmt.Sprintf(`{"type":%q,"user":%q,"reaction":%q,"item":{"type":"message","channel":"C0CHAN","ts":%q}}`, typ, user, emoji, ts)))
+		if !ok {
+			t.Fatalf("error decoding %s", typ)
+		}
+		return ev
+	}
+
+	reaction := func(user, emoji, ts string) {
+		conn.events <- event("reaction_added", user, emoji, ts)
+	}
+
+	testData := []struct {
+		ts     string
+		args   []string
+		thread string
+	}{
+		{"1.0", []string{"redeploy", "deploy", "web", "it's", "<v2>"}, "1.0"},
+		// replies stay in the thread
+		{"3.0", []string{"redeploy", "deploy", "api"}, "2.0"},
+	}
+
+	for _, d := range testData {
+		reaction("U0USER", "recycle", d.ts)
+
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		// what the bot executes
+		args, err := tokenize.Split(string(ev.Data))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", d.ts, err)
+		}
+		if !reflect.DeepEqual(args, d.args) {
+			t.Fatalf("%s: expected %q got %q", d.ts, d.args, args)
+		}
+		if ev.From != "C0CHAN:U0USER" {
+			t.Fatalf("%s: expected from C0CHAN:U0USER got %s", d.ts, ev.From)
+		}
+
+		if err := conn.Send(&input.Event{
+			Meta: ev.Meta,
+			From: ev.To,
+			To:   ev.From,
+			Type: input.TextEvent,
+			Data: []byte("done"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		msg := <-rtm.sent
+		if msg.ThreadTimestamp != d.thread || msg.Text != "<@U0USER>: done" {
+			t.Fatalf("%s: expected <@U0USER>: done in thread %s got %q in %q", d.ts, d.thread, msg.Text, msg.ThreadTimestamp)
+		}
+	}
+
+	// nothing is run for unmapped emoji, bots, the bot itself, removed
+	// reactions or messages which can't be found, they're passed on as
+	// reactions other than the bot's own
+	go func() {
+		reaction("U0USER", "thumbsup", "1.0")
+		reaction("U0OTHERBOT", "recycle", "1.0")
+		reaction("U0BOT", "recycle", "1.0")
+		reaction("U0USER", "recycle", "9.0")
+		conn.events <- slack.RTMEvent{
+			Type: "reaction_removed",
+			Data: (*slack.ReactionRemovedEvent)(event("reaction_added", "U0USER", "recycle", "1.0").Data.(*slack.ReactionAddedEvent)),
+		}
+	}()
+
+	for _, expect := range []input.Reaction{
+		{Channel: "C0CHAN", User: "U0USER", Message: "1.0", Emoji: "thumbsup"},
+		{Channel: "C0CHAN", User: "U0OTHERBOT", Message: "1.0", Emoji: "recycle"},
+		{Channel: "C0CHAN", User: "U0USER", Message: "9.0", Emoji: "recycle"},
+		{Channel: "C0CHAN", User: "U0USER", Message: "1.0", Emoji: "recycle", Removed: true},
+	} {
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		r, ok := input.Payload(&ev).(*input.Reaction)
+		if ev.Type != input.ReactionEvent || !ok || *r != expect || len(ev.Data) > 0 {
+			t.Fatalf("expected reaction %+v got %+v", expect, ev)
+		}
+		if ev.From != "C0CHAN:"+expect.User {
+			t.Fatalf("expected from C0CHAN:%s got %s", expect.User, ev.From)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var ev input.Event
+		done <- conn.Recv(&ev)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("expected no command got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(conn.exit)
+	<-done
+}
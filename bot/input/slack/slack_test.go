@@ -0,0 +1,255 @@
+package slack
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/nlopes/slack"
+)
+
+// testAuth serves auth.test failing the first n calls
+func testAuth(n int) (*httptest.Server, func() int) {
+	var mtx sync.Mutex
+	var calls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		calls++
+		c := calls
+		mtx.Unlock()
+
+		if c <= n {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"user":"micro","user_id":"U0BOT"}`))
+	}))
+
+	return srv, func() int {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return calls
+	}
+}
+
+func TestConnect(t *testing.T) {
+	backoff := reconnectBackoff
+	reconnectBackoff = time.Millisecond
+	defer func() { reconnectBackoff = backoff }()
+
+	testData := []struct {
+		name        string
+		fail        int
+		maxFailures int
+		calls       int
+		running     bool
+	}{
+		{"first attempt", 0, 3, 1, true},
+		{"recovers", 2, 3, 3, true},
+		{"retries forever", 5, 0, 6, true},
+		{"gives up", 5, 3, 3, false},
+	}
+
+	for _, d := range testData {
+		srv, calls := testAuth(d.fail)
+
+		apiURL := slack.APIURL
+		slack.APIURL = srv.URL + "/"
+
+		w := &workspace{api: slack.New("xoxb-test")}
+		p := &slackInput{
+			exit:        make(chan bool),
+			running:     true,
+			workspaces:  []*workspace{w},
+			maxBackoff:  5 * time.Millisecond,
+			maxFailures: d.maxFailures,
+		}
+
+		auth, err := p.connect(w)
+		c := calls()
+		running := p.running
+
+		// stopping after giving up is a noop
+		if err := p.Stop(); err != nil {
+			t.Fatalf("%s: unexpected error stopping %v", d.name, err)
+		}
+
+		slack.APIURL = apiURL
+		srv.Close()
+
+		if d.running && (err != nil || auth.UserID != "U0BOT") {
+			t.Fatalf("%s: unexpected error %v", d.name, err)
+		}
+		if !d.running && err == nil {
+			t.Fatalf("%s: expected error", d.name)
+		}
+		if c != d.calls {
+			t.Fatalf("%s: expected %d auth tests got %d", d.name, d.calls, c)
+		}
+		if running != d.running {
+			t.Fatalf("%s: expected running %v got %v", d.name, d.running, running)
+		}
+		if d.running && w.failures != 0 {
+			t.Fatalf("%s: expected failures to reset got %d", d.name, w.failures)
+		}
+	}
+}
+
+func TestPresence(t *testing.T) {
+	calls, stop := testAPI(t)
+	defer stop()
+
+	p := &slackInput{
+		token:       "xoxb-test",
+		statusText:  "serving commands",
+		statusEmoji: ":robot_face:",
+	}
+
+	expect := func(method, key, value string) {
+		select {
+		case call := <-calls:
+			if call.method != method || !strings.Contains(call.form.Get(key), value) {
+				t.Fatalf("expected %s with %s %s got %+v", method, key, value, call)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", method)
+		}
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("auth.test", "token", "xoxb-test")
+	expect("users.setPresence", "presence", "auto")
+	expect("users.profile.set", "profile", "serving commands")
+
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect("users.setPresence", "presence", "away")
+}
+
+func TestLoadTokens(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(file, []byte("xoxb-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := os.Getenv("MICRO_SLACK_TOKEN")
+	defer os.Setenv("MICRO_SLACK_TOKEN", env)
+
+	testData := []struct {
+		name  string
+		flag  string
+		file  string
+		env   string
+		token string
+		err   string
+	}{
+		{"flag wins", "xoxb-flag", file, "xoxb-env", "xoxb-flag", ""},
+		{"file before env", "", file, "xoxb-env", "xoxb-file", ""},
+		{"env", "", "", "xoxb-env", "xoxb-env", ""},
+		{"missing file", "", filepath.Join(dir, "missing"), "xoxb-env", "", "error reading slack token file"},
+		{"missing", "", "", "", "", "missing slack token: set slack_token, slack_token_file or MICRO_SLACK_TOKEN"},
+	}
+
+	for _, d := range testData {
+		os.Setenv("MICRO_SLACK_TOKEN", d.env)
+
+		p := &slackInput{token: d.flag, tokenFile: d.file}
+		tokens, err := p.loadTokens()
+
+		if len(d.err) > 0 {
+			if err == nil || !strings.HasPrefix(err.Error(), d.err) {
+				t.Fatalf("%s: expected error %q got %v", d.name, d.err, err)
+			}
+			continue
+		}
+
+		if err != nil || len(tokens) != 1 || tokens[0] != d.token {
+			t.Fatalf("%s: expected %s got %v %v", d.name, d.token, tokens, err)
+		}
+	}
+
+	// rotated secrets are picked up on the next read
+	ioutil.WriteFile(file, []byte("xoxb-rotated"), 0600)
+
+	p := &slackInput{tokenFile: file}
+	if tokens, _ := p.loadTokens(); len(tokens) != 1 || tokens[0] != "xoxb-rotated" {
+		t.Fatalf("expected rotated token got %v", tokens)
+	}
+}
+
+func TestInitEnv(t *testing.T) {
+	env := map[string]string{
+		"MICRO_SLACK_TOKEN":       "xoxb-env",
+		"MICRO_SLACK_PREFIX":      "!",
+		"MICRO_SLACK_ALLOW_DM":    "false",
+		"MICRO_SLACK_EDIT_WINDOW": "30s",
+		"MICRO_SLACK_FORMAT":      "plain",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+		os.Unsetenv("MICRO_SLACK_IGNORE_BOTS")
+	}()
+
+	newContext := func(args ...string) *cli.Context {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, f := range (&slackInput{}).Flags() {
+			f.Apply(set)
+		}
+		if err := set.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return cli.NewContext(cli.NewApp(), set, nil)
+	}
+
+	// flags set on the command line win over the env
+	p := &slackInput{}
+	if err := p.Init(newContext("--slack_format=code")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.tokens) != 1 || p.tokens[0] != "xoxb-env" {
+		t.Fatalf("expected the token of the env got %v", p.tokens)
+	}
+	if p.prefix != "!" || p.allowDM || p.editWindow != 30*time.Second {
+		t.Fatalf("expected the settings of the env got %q %v %v", p.prefix, p.allowDM, p.editWindow)
+	}
+	if p.format != formatCode {
+		t.Fatalf("expected the flag's format got %s", p.format)
+	}
+	// those in neither keep their defaults
+	if !p.ignoreBots || p.reaction != "hourglass" || p.maxFailures != 10 {
+		t.Fatalf("expected the defaults got %v %q %d", p.ignoreBots, p.reaction, p.maxFailures)
+	}
+
+	os.Setenv("MICRO_SLACK_IGNORE_BOTS", "sometimes")
+
+	err := (&slackInput{}).Init(newContext())
+	if err == nil || err.Error() != "invalid MICRO_SLACK_IGNORE_BOTS=sometimes, expected true or false" {
+		t.Fatalf("expected the invalid env to be reported got %v", err)
+	}
+}
@@ -0,0 +1,394 @@
+// Package slacktest provides a fake slack server for testing the slack
+// input end to end. It implements the web api methods the input calls
+// on start and an rtm websocket events are delivered over.
+package slacktest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+const (
+	// BotID and BotName identify the bot the server authenticates
+	BotID   = "U0BOT"
+	BotName = "micro"
+	// TeamID is the workspace the bot belongs to
+	TeamID = "T0TEAM"
+)
+
+// Frame is a message sent by the bot over the rtm
+type Frame struct {
+	ID              int    `json:"id"`
+	Type            string `json:"type"`
+	Channel         string `json:"channel,omitempty"`
+	Text            string `json:"text,omitempty"`
+	ThreadTimestamp string `json:"thread_ts,omitempty"`
+}
+
+// conversation is the subset of a conversation the input reads. The
+// slack package's own types can't be built outside of it.
+type conversation struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	User      string `json:"user,omitempty"`
+	IsChannel bool   `json:"is_channel"`
+	IsIM      bool   `json:"is_im"`
+}
+
+// Call is a web api call made by the bot
+type Call struct {
+	Method string
+	Form   map[string][]string
+}
+
+// Server is a fake slack api. Set slack.APIURL to its URL so the input
+// talks to it rather than slack.
+type Server struct {
+	URL string
+
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+	frames   chan Frame
+	calls    chan Call
+
+	sync.Mutex
+	conns     []*websocket.Conn
+	users     map[string]slack.User
+	channels  map[string]conversation
+	handlers  map[string]http.HandlerFunc
+	connected chan bool
+	closed    bool
+}
+
+// NewServer starts a fake slack server
+func NewServer() *Server {
+	s := &Server{
+		// slack's client sends its own origin
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(*http.Request) bool { return true },
+		},
+		frames:    make(chan Frame, 100),
+		calls:     make(chan Call, 100),
+		users:     make(map[string]slack.User),
+		channels:  make(map[string]conversation),
+		handlers:  make(map[string]http.HandlerFunc),
+		connected: make(chan bool),
+	}
+
+	s.users[BotID] = slack.User{ID: BotID, Name: BotName, IsBot: true}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.serveWS)
+	mux.HandleFunc("/", s.serveAPI)
+
+	s.srv = httptest.NewServer(mux)
+	s.URL = s.srv.URL + "/"
+
+	return s
+}
+
+// AddUser adds a user returned by users.info and users.list
+func (s *Server) AddUser(user slack.User) {
+	s.Lock()
+	s.users[user.ID] = user
+	s.Unlock()
+}
+
+// AddChannel adds a public channel returned by conversations.info and
+// conversations.list
+func (s *Server) AddChannel(id, name string) {
+	s.Lock()
+	s.channels[id] = conversation{ID: id, Name: name, IsChannel: true}
+	s.Unlock()
+}
+
+// AddIM adds a direct message conversation with the user
+func (s *Server) AddIM(id, user string) {
+	s.Lock()
+	s.channels[id] = conversation{ID: id, User: user, IsIM: true}
+	s.Unlock()
+}
+
+// Handle overrides the response to a web api method
+func (s *Server) Handle(method string, h http.HandlerFunc) {
+	s.Lock()
+	s.handlers[method] = h
+	s.Unlock()
+}
+
+// WaitConnected blocks until the rtm connects
+func (s *Server) WaitConnected(timeout time.Duration) error {
+	s.Lock()
+	connected := s.connected
+	s.Unlock()
+
+	select {
+	case <-connected:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("timed out waiting for the rtm to connect")
+	}
+}
+
+// Send delivers an event to every rtm connection
+func (s *Server) Send(event interface{}) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.conns) == 0 {
+		return errors.New("rtm not connected")
+	}
+
+	for _, c := range s.conns {
+		if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SendMessage delivers a message from the user
+func (s *Server) SendMessage(channel, user, text string) error {
+	return s.Send(slack.Msg{
+		Type:      "message",
+		Channel:   channel,
+		User:      user,
+		Text:      text,
+		Timestamp: timestamp(),
+	})
+}
+
+// Message returns the next message the bot sent over the rtm, skipping
+// typing indicators and pings
+func (s *Server) Message(timeout time.Duration) (Frame, error) {
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case f := <-s.frames:
+			if f.Type != "message" {
+				continue
+			}
+			return f, nil
+		case <-deadline:
+			return Frame{}, errors.New("timed out waiting for a message")
+		}
+	}
+}
+
+// Call returns the next call to the web api method
+func (s *Server) Call(method string, timeout time.Duration) (Call, error) {
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case c := <-s.calls:
+			if c.Method != method {
+				continue
+			}
+			return c, nil
+		case <-deadline:
+			return Call{}, errors.New("timed out waiting for " + method)
+		}
+	}
+}
+
+// Connections returns the number of open rtm connections
+func (s *Server) Connections() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.conns)
+}
+
+// Close closes the rtm connections and stops the server
+func (s *Server) Close() {
+	s.Lock()
+	s.closed = true
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+	s.Unlock()
+
+	s.srv.Close()
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	c, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		c.Close()
+		return
+	}
+	s.conns = append(s.conns, c)
+	s.Unlock()
+
+	s.Send(map[string]string{"type": "hello"})
+
+	s.Lock()
+	select {
+	case <-s.connected:
+	default:
+		close(s.connected)
+	}
+	s.Unlock()
+
+	defer s.remove(c)
+
+	for {
+		_, b, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var f Frame
+		if err := json.Unmarshal(b, &f); err != nil {
+			continue
+		}
+
+		// keep the client's deadman timer happy
+		if f.Type == "ping" {
+			s.Send(map[string]interface{}{"type": "pong", "reply_to": f.ID})
+			continue
+		}
+
+		select {
+		case s.frames <- f:
+		default:
+		}
+	}
+}
+
+// remove drops a closed rtm connection, waiting for the next to connect
+func (s *Server) remove(c *websocket.Conn) {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, conn := range s.conns {
+		if conn == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(s.conns) == 0 {
+		s.connected = make(chan bool)
+	}
+}
+
+func (s *Server) serveAPI(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	method := strings.TrimPrefix(r.URL.Path, "/")
+
+	select {
+	case s.calls <- Call{method, r.Form}:
+	default:
+	}
+
+	s.Lock()
+	h, ok := s.handlers[method]
+	s.Unlock()
+
+	if ok {
+		h(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch method {
+	case "auth.test":
+		write(w, map[string]interface{}{
+			"ok":      true,
+			"team":    "test",
+			"team_id": TeamID,
+			"user":    BotName,
+			"user_id": BotID,
+		})
+	case "rtm.connect", "rtm.start":
+		write(w, map[string]interface{}{
+			"ok":   true,
+			"url":  "ws" + strings.TrimPrefix(s.srv.URL, "http") + "/ws",
+			"self": map[string]string{"id": BotID, "name": BotName},
+			"team": map[string]string{"id": TeamID, "name": "test"},
+		})
+	case "users.info":
+		s.Lock()
+		user, ok := s.users[r.Form.Get("user")]
+		s.Unlock()
+
+		if !ok {
+			write(w, map[string]interface{}{"ok": false, "error": "user_not_found"})
+			return
+		}
+		write(w, map[string]interface{}{"ok": true, "user": user})
+	case "users.list":
+		s.Lock()
+		var users []slack.User
+		for _, u := range s.users {
+			users = append(users, u)
+		}
+		s.Unlock()
+
+		write(w, map[string]interface{}{"ok": true, "members": users})
+	case "conversations.info":
+		s.Lock()
+		ch, ok := s.channels[r.Form.Get("channel")]
+		s.Unlock()
+
+		if !ok {
+			write(w, map[string]interface{}{"ok": false, "error": "channel_not_found"})
+			return
+		}
+		write(w, map[string]interface{}{"ok": true, "channel": ch})
+	case "conversations.list":
+		s.Lock()
+		var channels []conversation
+		for _, ch := range s.channels {
+			channels = append(channels, ch)
+		}
+		s.Unlock()
+
+		write(w, map[string]interface{}{"ok": true, "channels": channels})
+	default:
+		write(w, map[string]interface{}{"ok": true})
+	}
+}
+
+func write(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+var (
+	tsMu sync.Mutex
+	tsN  int
+)
+
+// timestamp returns a unique message timestamp
+func timestamp() string {
+	tsMu.Lock()
+	defer tsMu.Unlock()
+
+	tsN++
+	return fmt.Sprintf("%d.%06d", time.Now().Unix(), tsN)
+}
@@ -0,0 +1,205 @@
+package slack
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long a slash command is waited on before it's acknowledged
+	// and answered through the response url instead. slack allows 3s.
+	slashTimeout = 2500 * time.Millisecond
+	// how old a signed request may be before it's rejected
+	slashMaxSkew = 5 * time.Minute
+)
+
+// slashCommand is a slash command payload received over http
+type slashCommand struct {
+	Command     string
+	Text        string
+	UserID      string
+	ChannelID   string
+	ResponseURL string
+
+	// client the response url is posted with
+	client *http.Client
+
+	sync.Mutex
+	// the http request is still waiting on the reply
+	waiting bool
+	rsp     chan string
+}
+
+type slashResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// verifySignature checks the request was signed by slack with secret
+// as described in https://api.slack.com/docs/verifying-requests-from-slack
+func verifySignature(secret string, header http.Header, body []byte, now time.Time) error {
+	ts := header.Get("X-Slack-Request-Timestamp")
+	sig := header.Get("X-Slack-Signature")
+
+	if len(ts) == 0 || len(sig) == 0 {
+		return errors.New("missing signature")
+	}
+
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+
+	// reject old requests to prevent replays
+	if d := now.Sub(time.Unix(secs, 0)); d > slashMaxSkew || d < -slashMaxSkew {
+		return errors.New("stale timestamp")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return errors.New("invalid signature")
+	}
+
+	return nil
+}
+
+// reply answers the command inline if the request is still waiting
+// otherwise it's posted to the response url
+func (sc *slashCommand) reply(text string) error {
+	sc.Lock()
+	if sc.waiting {
+		// buffered so never blocks
+		sc.rsp <- text
+		sc.waiting = false
+		sc.Unlock()
+		return nil
+	}
+	sc.Unlock()
+
+	if len(sc.ResponseURL) == 0 {
+		return errors.New("missing response url")
+	}
+
+	b, err := json.Marshal(slashResponse{ResponseType: "in_channel", Text: text})
+	if err != nil {
+		return err
+	}
+
+	rsp, err := httpClient(sc.client).Post(sc.ResponseURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return errors.New("response url returned " + rsp.Status)
+	}
+
+	return nil
+}
+
+// readForm reads a form posted by slack, verifying its signature. An
+// error response is written if it returns false.
+func readForm(secret string, w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	if err := verifySignature(secret, r.Header, body, time.Now()); err != nil {
+		log.Logf("[slack] rejected request: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return form, true
+}
+
+// slashHandler verifies slash command requests and hands them to the
+// conn of their workspace. The reply is written inline if it arrives within the timeout.
+func slashHandler(secret string, client *http.Client, route func(team string) chan *slashCommand, exit chan bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		form, ok := readForm(secret, w, r)
+		if !ok {
+			return
+		}
+
+		commands := route(form.Get("team_id"))
+		if commands == nil {
+			http.Error(w, "unknown workspace", http.StatusNotFound)
+			return
+		}
+
+		sc := &slashCommand{
+			Command:     form.Get("command"),
+			Text:        form.Get("text"),
+			UserID:      form.Get("user_id"),
+			ChannelID:   form.Get("channel_id"),
+			ResponseURL: form.Get("response_url"),
+			client:      client,
+			waiting:     true,
+			rsp:         make(chan string, 1),
+		}
+
+		select {
+		case commands <- sc:
+		case <-exit:
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+			return
+		case <-time.After(slashTimeout):
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+
+		var text string
+
+		select {
+		case text = <-sc.rsp:
+		case <-time.After(slashTimeout):
+			sc.Lock()
+			// the reply arrived as we timed out
+			if !sc.waiting {
+				text = <-sc.rsp
+			}
+			sc.waiting = false
+			sc.Unlock()
+		}
+
+		// acknowledge and answer later through the response url
+		if len(text) == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(slashResponse{ResponseType: "in_channel", Text: text})
+	}
+}
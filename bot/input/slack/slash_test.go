@@ -0,0 +1,164 @@
+package slack
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixture from https://api.slack.com/docs/verifying-requests-from-slack
+const (
+	testSecret    = "8f742231b10e8888abcd99yyyzzz85a5"
+	testTimestamp = "1531420618"
+	testSignature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	testBody      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+)
+
+func sign(secret, ts, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	ts, _ := strconv.ParseInt(testTimestamp, 10, 64)
+	now := time.Unix(ts, 0).Add(time.Minute)
+
+	testData := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      string
+		now       time.Time
+		err       string
+	}{
+		{"valid", testTimestamp, testSignature, testBody, now, ""},
+		{"bad signature", testTimestamp, "v0=" + strings.Repeat("0", 64), testBody, now, "invalid signature"},
+		{"modified body", testTimestamp, testSignature, testBody + "&x=1", now, "invalid signature"},
+		{"missing signature", testTimestamp, "", testBody, now, "missing signature"},
+		{"invalid timestamp", "yesterday", testSignature, testBody, now, "invalid timestamp"},
+		{"stale timestamp", testTimestamp, testSignature, testBody, now.Add(time.Hour), "stale timestamp"},
+	}
+
+	for _, d := range testData {
+		header := http.Header{}
+		header.Set("X-Slack-Request-Timestamp", d.timestamp)
+		header.Set("X-Slack-Signature", d.signature)
+
+		err := verifySignature(testSecret, header, []byte(d.body), d.now)
+
+		if len(d.err) == 0 && err != nil {
+			t.Fatalf("%s: unexpected error %v", d.name, err)
+		}
+		if len(d.err) > 0 && (err == nil || err.Error() != d.err) {
+			t.Fatalf("%s: expected error %q got %v", d.name, d.err, err)
+		}
+	}
+}
+
+func TestSlashHandler(t *testing.T) {
+	timeout := slashTimeout
+	slashTimeout = 100 * time.Millisecond
+	defer func() {
+		slashTimeout = timeout
+	}()
+
+	// receives replies which take too long to answer inline
+	later := make(chan slashResponse, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp slashResponse
+		json.NewDecoder(r.Body).Decode(&rsp)
+		later <- rsp
+	}))
+	defer hook.Close()
+
+	commands := make(chan *slashCommand, 1)
+	srv := httptest.NewServer(slashHandler(testSecret, nil, func(string) chan *slashCommand { return commands }, make(chan bool)))
+	defer srv.Close()
+
+	post := func(text, signature string) *http.Response {
+		body := url.Values{
+			"command":      {"/micro"},
+			"text":         {text},
+			"user_id":      {"U0USER"},
+			"channel_id":   {"C0TEST"},
+			"response_url": {hook.URL},
+		}.Encode()
+
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		if len(signature) == 0 {
+			signature = sign(testSecret, ts, body)
+		}
+
+		req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Slack-Request-Timestamp", ts)
+		req.Header.Set("X-Slack-Signature", signature)
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	// unsigned requests are rejected
+	rsp := post("ping", "v0=bad")
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 got %d", rsp.StatusCode)
+	}
+
+	// fast commands are answered inline
+	go func() {
+		sc := <-commands
+		if sc.Text != "ping" || sc.UserID != "U0USER" || sc.ChannelID != "C0TEST" {
+			t.Errorf("unexpected command %+v", sc)
+		}
+		sc.reply("pong")
+	}()
+
+	rsp = post("ping", "")
+	var inline slashResponse
+	json.NewDecoder(rsp.Body).Decode(&inline)
+	rsp.Body.Close()
+
+	if inline.Text != "pong" || inline.ResponseType != "in_channel" {
+		t.Fatalf("expected inline pong got %+v", inline)
+	}
+
+	// slow commands are acknowledged then posted to the response url
+	go func() {
+		sc := <-commands
+		time.Sleep(2 * slashTimeout)
+		if err := sc.reply("done"); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}()
+
+	rsp = post("deploy", "")
+	b, _ := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK || len(b) > 0 {
+		t.Fatalf("expected empty acknowledgement got %d %q", rsp.StatusCode, b)
+	}
+
+	select {
+	case msg := <-later:
+		if msg.Text != "done" {
+			t.Fatalf("expected done got %q", msg.Text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the response url")
+	}
+}
@@ -0,0 +1,264 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-log"
+	"github.com/nlopes/slack"
+)
+
+// socketMode maintains a Slack Socket Mode connection using an app level
+// token. Events are delivered on the same channel type as the RTM client
+// so the conn processes them identically.
+type socketMode struct {
+	token  string
+	client *http.Client
+	dialer *websocket.Dialer
+	events chan slack.RTMEvent
+	exit   chan bool
+}
+
+// socketEnvelope is the frame sent by Slack over the socket
+type socketEnvelope struct {
+	Type       string `json:"type"`
+	EnvelopeID string `json:"envelope_id"`
+	Reason     string `json:"reason"`
+	Payload    struct {
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// webClient sends outgoing messages through the Web API since
+// Socket Mode connections are receive only.
+type webClient struct {
+	api *slack.Client
+}
+
+func newSocketMode(token string, exit chan bool) *socketMode {
+	return &socketMode{
+		token:  token,
+		events: make(chan slack.RTMEvent, 50),
+		exit:   exit,
+	}
+}
+
+// open requests a websocket url from apps.connections.open
+func (s *socketMode) open() (string, error) {
+	req, err := http.NewRequest("POST", slack.APIURL+"apps.connections.open", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	rsp, err := httpClient(s.client).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+
+	var res struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+		URL   string `json:"url"`
+	}
+
+	if err := json.NewDecoder(rsp.Body).Decode(&res); err != nil {
+		return "", err
+	}
+
+	if !res.Ok {
+		return "", errors.New(res.Error)
+	}
+
+	return res.URL, nil
+}
+
+// push hands an event to the conn unless we're exiting
+func (s *socketMode) push(ev slack.RTMEvent) bool {
+	select {
+	case <-s.exit:
+		return false
+	case s.events <- ev:
+		return true
+	}
+}
+
+func (s *socketMode) connect() error {
+	u, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	c, _, err := wsDialer(s.dialer).Dial(u, nil)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan bool)
+	defer close(done)
+
+	// close the socket when exiting so ReadJSON returns
+	go func() {
+		select {
+		case <-s.exit:
+		case <-done:
+		}
+		c.Close()
+	}()
+
+	for {
+		var env socketEnvelope
+		if err := c.ReadJSON(&env); err != nil {
+			return err
+		}
+
+		// acknowledge receipt
+		if len(env.EnvelopeID) > 0 {
+			if err := c.WriteJSON(map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+
+		switch env.Type {
+		case "hello":
+			s.push(slack.RTMEvent{Type: "connected", Data: &slack.ConnectedEvent{}})
+		case "disconnect":
+			return errors.New("disconnect requested: " + env.Reason)
+		case "events_api":
+			ev, ok := decodeEvent(env.Payload.Event)
+			if !ok {
+				continue
+			}
+			if !s.push(ev) {
+				return nil
+			}
+		}
+	}
+}
+
+// decodeEvent converts an Events API event into the RTM event the conn
+// handles. Other event types are dropped.
+func decodeEvent(data json.RawMessage) (slack.RTMEvent, bool) {
+	var typ struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &typ); err != nil {
+		log.Logf("[slack] error decoding event: %v", err)
+		return slack.RTMEvent{}, false
+	}
+
+	var ev interface{}
+
+	switch typ.Type {
+	case "message":
+		ev = &slack.MessageEvent{}
+	case "user_change":
+		ev = &slack.UserChangeEvent{}
+	case "team_join":
+		ev = &slack.TeamJoinEvent{}
+	case "member_joined_channel":
+		ev = &slack.MemberJoinedChannelEvent{}
+	case "member_left_channel":
+		ev = &slack.MemberLeftChannelEvent{}
+	case "reaction_added":
+		ev = &slack.ReactionAddedEvent{}
+	case "channel_archive":
+		ev = &slack.ChannelArchiveEvent{}
+	case "channel_unarchive":
+		ev = &slack.ChannelUnarchiveEvent{}
+	case "channel_deleted":
+		ev = &slack.ChannelDeletedEvent{}
+	case "channel_left":
+		ev = &slack.ChannelLeftEvent{}
+	case "group_archive":
+		ev = &slack.GroupArchiveEvent{}
+	case "group_unarchive":
+		ev = &slack.GroupUnarchiveEvent{}
+	case "group_left":
+		ev = &slack.GroupLeftEvent{}
+	default:
+		// mentions are also delivered as message events
+		return slack.RTMEvent{}, false
+	}
+
+	if err := json.Unmarshal(data, ev); err != nil {
+		log.Logf("[slack] error decoding event: %v", err)
+		return slack.RTMEvent{}, false
+	}
+
+	return slack.RTMEvent{Type: typ.Type, Data: ev}, true
+}
+
+// run connects and reconnects with backoff until exit is closed
+func (s *socketMode) run() {
+	backoff := time.Second
+
+	for {
+		start := time.Now()
+		err := s.connect()
+
+		select {
+		case <-s.exit:
+			return
+		default:
+		}
+
+		s.push(slack.RTMEvent{Type: "disconnected", Data: &slack.DisconnectedEvent{}})
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+
+		log.Logf("[slack] socket mode connection lost: %v, reconnecting in %v", err, backoff)
+
+		select {
+		case <-s.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+func (w *webClient) NewOutgoingMessage(text string, channel string, options ...slack.RTMsgOption) *slack.OutgoingMessage {
+	msg := &slack.OutgoingMessage{
+		Channel: channel,
+		Text:    text,
+		Type:    "message",
+	}
+	for _, o := range options {
+		o(msg)
+	}
+	return msg
+}
+
+func (w *webClient) SendMessage(msg *slack.OutgoingMessage) {
+	if err := w.PostMessage(msg); err != nil {
+		log.Logf("[slack] error posting message to %s: %v", msg.Channel, err)
+	}
+}
+
+// PostMessage posts the message returning any error so rate limited
+// messages can be retried
+func (w *webClient) PostMessage(msg *slack.OutgoingMessage) error {
+	opts := []slack.MsgOption{
+		slack.MsgOptionText(msg.Text, false),
+		slack.MsgOptionAsUser(true),
+	}
+
+	if len(msg.ThreadTimestamp) > 0 {
+		opts = append(opts, slack.MsgOptionTS(msg.ThreadTimestamp))
+	}
+
+	_, _, err := w.api.PostMessage(msg.Channel, opts...)
+	return err
+}
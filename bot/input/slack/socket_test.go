@@ -0,0 +1,93 @@
+package slack
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nlopes/slack"
+)
+
+func TestSocketMode(t *testing.T) {
+	acks := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xapp-test" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"url":"ws` + strings.TrimPrefix(srv.URL, "http") + `/ws"}`))
+	})
+
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello"}`))
+		c.WriteMessage(websocket.TextMessage, []byte(`{
+			"type": "events_api",
+			"envelope_id": "env-1",
+			"payload": {"event": {"type": "message", "channel": "C0CHAN", "user": "U0USER", "text": "<@U0BOT> ping", "ts": "1.2"}}
+		}`))
+
+		var ack map[string]string
+		if err := c.ReadJSON(&ack); err == nil {
+			acks <- ack["envelope_id"]
+		}
+
+		// hold the connection until the client goes away
+		c.ReadMessage()
+	})
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL + "/"
+	defer func() { slack.APIURL = url }()
+
+	exit := make(chan bool)
+	defer close(exit)
+
+	sm := newSocketMode("xapp-test", exit)
+	go sm.run()
+
+	next := func() slack.RTMEvent {
+		select {
+		case ev := <-sm.events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for event")
+		}
+		return slack.RTMEvent{}
+	}
+
+	if ev := next(); ev.Type != "connected" {
+		t.Fatalf("expected connected event got %s", ev.Type)
+	}
+
+	ev, ok := next().Data.(*slack.MessageEvent)
+	if !ok {
+		t.Fatal("expected message event")
+	}
+	if ev.Channel != "C0CHAN" || ev.Text != "<@U0BOT> ping" || ev.Timestamp != "1.2" {
+		t.Fatalf("unexpected message %+v", ev.Msg)
+	}
+
+	select {
+	case id := <-acks:
+		if id != "env-1" {
+			t.Fatalf("expected ack for env-1 got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for ack")
+	}
+}
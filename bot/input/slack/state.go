@@ -0,0 +1,108 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-log"
+)
+
+// channelState is state a conn holds per channel
+type channelState interface {
+	// removeChannel drops the channel returning the entries dropped
+	removeChannel(channel string) int
+}
+
+type namedState struct {
+	name  string
+	state channelState
+}
+
+// channelRegistry purges the per channel state of a conn in one place
+// once a channel is archived or the bot removed from it. Channels sends
+// failed for because they're gone are marked dead and not sent to again
+// until the bot rejoins.
+type channelRegistry struct {
+	states []namedState
+
+	sync.Mutex
+	dead map[string]bool
+}
+
+// newChannelRegistry registers the conn's per channel state
+func newChannelRegistry(s *slackConn) *channelRegistry {
+	r := &channelRegistry{
+		dead: make(map[string]bool),
+	}
+
+	r.register("cached channels", s.channels)
+	r.register("cached conversations", s.conversations)
+	if s.confirms != nil {
+		r.register("pending confirmations", s.confirms)
+	}
+	if s.queue != nil {
+		r.register("queued messages", s.queue)
+	}
+
+	return r
+}
+
+func (r *channelRegistry) register(name string, state channelState) {
+	r.states = append(r.states, namedState{name, state})
+}
+
+// remove drops the channel from every state logging what was dropped
+func (r *channelRegistry) remove(channel, reason string) {
+	var dropped []string
+
+	for _, s := range r.states {
+		if n := s.state.removeChannel(channel); n > 0 {
+			dropped = append(dropped, fmt.Sprintf("%d %s", n, s.name))
+		}
+	}
+
+	if len(dropped) == 0 {
+		log.Logf("[slack] removed channel %s, %s", channel, reason)
+		return
+	}
+
+	log.Logf("[slack] removed channel %s, %s: dropped %s", channel, reason, strings.Join(dropped, ", "))
+}
+
+// kill marks the channel dead and removes it
+func (r *channelRegistry) kill(channel string, err error) {
+	r.Lock()
+	r.dead[channel] = true
+	r.Unlock()
+
+	r.remove(channel, err.Error())
+}
+
+// revive allows sending to the channel again
+func (r *channelRegistry) revive(channel string) {
+	r.Lock()
+	delete(r.dead, channel)
+	r.Unlock()
+}
+
+func (r *channelRegistry) isDead(channel string) bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.dead[channel]
+}
+
+// isGone returns true if a send failed because the channel no longer
+// exists or was archived, so retrying is pointless
+func isGone(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch err.Error() {
+	case "channel_not_found", "is_archived":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,176 @@
+package slack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// postRTM reports errors posting to the channels in errs
+type postRTM struct {
+	*testRTM
+	errs map[string]error
+}
+
+func (p *postRTM) PostMessage(msg *slack.OutgoingMessage) error {
+	if err, ok := p.errs[msg.Channel]; ok {
+		return err
+	}
+	p.SendMessage(msg)
+	return nil
+}
+
+func TestRecvArchived(t *testing.T) {
+	conn, _ := newTestConn()
+	conn.confirms = newConfirmations()
+	conn.state = newChannelRegistry(conn)
+
+	recv := func(e interface{}) {
+		conn.events <- slack.RTMEvent{Data: e}
+		conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: slack.Msg{
+			Type:    "message",
+			Channel: "D0DIRECT",
+			User:    "U0USER",
+			Text:    "ping",
+		}}}
+
+		var ev input.Event
+		if err := conn.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archived := &slack.ChannelArchiveEvent{Channel: "C0OLD"}
+	deleted := &slack.ChannelDeletedEvent{Channel: "C0OLD"}
+	left := &slack.ChannelLeftEvent{Channel: "C0OLD"}
+
+	for _, ev := range []interface{}{archived, deleted, left} {
+		conn.channels.add("C0OLD", "old")
+		conn.channels.add("C0KEEP", "keep")
+		conn.confirms.add("a", &pending{channel: "C0OLD"}, time.Minute, func() {})
+		conn.confirms.add("b", &pending{channel: "C0KEEP"}, time.Minute, func() {})
+
+		recv(ev)
+
+		if len(conn.channels.name("C0OLD")) > 0 {
+			t.Fatalf("%T: expected the channel to be forgotten", ev)
+		}
+		if _, ok := conn.confirms.get("a"); ok {
+			t.Fatalf("%T: expected the confirmation to be dropped", ev)
+		}
+		if _, ok := conn.confirms.get("b"); !ok || conn.channels.name("C0KEEP") != "keep" {
+			t.Fatalf("%T: expected other channels to be kept", ev)
+		}
+	}
+}
+
+func TestChannelRegistry(t *testing.T) {
+	conn, _ := newTestConn()
+	conn.confirms = newConfirmations()
+
+	exit := make(chan bool)
+	defer close(exit)
+
+	// hold messages so they stay queued
+	conn.queue = newSendQueue(exit, time.Hour, 1, 10, 0)
+	conn.queue.setConnected(false)
+	conn.state = newChannelRegistry(conn)
+
+	conn.channels.add("C0OLD", "old")
+	conn.confirms.add("a", &pending{channel: "C0OLD"}, time.Minute, func() {})
+	conn.confirms.add("b", &pending{channel: "C0OLD"}, time.Minute, func() {})
+	for i := 0; i < 3; i++ {
+		conn.queue.push("C0OLD", func() error { return nil })
+	}
+
+	counts := map[string]int{}
+	for _, s := range conn.state.states {
+		counts[s.name] = s.state.removeChannel("C0OLD")
+	}
+
+	expect := map[string]int{
+		"cached channels":       1,
+		"cached conversations":  0,
+		"pending confirmations": 2,
+		"queued messages":       3,
+	}
+
+	for name, n := range expect {
+		if counts[name] != n {
+			t.Fatalf("expected %d %s dropped got %d", n, name, counts[name])
+		}
+	}
+}
+
+func TestSendGone(t *testing.T) {
+	conn, rtm := newTestConn()
+	conn.rtm = &postRTM{rtm, map[string]error{
+		"C0GONE":     errors.New("channel_not_found"),
+		"C0ARCHIVED": errors.New("is_archived"),
+		"C0BUSY":     errors.New("ratelimited"),
+	}}
+
+	send := func(channel string) error {
+		return conn.Send(&input.Event{
+			Meta: map[string]interface{}{MetaChannel: channel},
+			Type: input.TextEvent,
+			Data: []byte("hello"),
+		})
+	}
+
+	for _, channel := range []string{"C0GONE", "C0ARCHIVED", "C0BUSY"} {
+		conn.channels.add(channel, "")
+		send(channel)
+	}
+
+	for _, channel := range []string{"C0GONE", "C0ARCHIVED"} {
+		if !conn.state.isDead(channel) {
+			t.Fatalf("expected %s to be dead", channel)
+		}
+		if err := send(channel); err == nil {
+			t.Fatalf("expected sending to %s to fail", channel)
+		}
+	}
+
+	if conn.state.isDead("C0BUSY") {
+		t.Fatal("expected other errors to be retried")
+	}
+
+	// rejoining allows sending again
+	delete(conn.rtm.(*postRTM).errs, "C0GONE")
+	conn.joined("C0GONE", "gone", false)
+
+	if err := send("C0GONE"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-rtm.sent:
+		if msg.Channel != "C0GONE" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	default:
+		t.Fatal("expected a message")
+	}
+
+	// as does unarchiving
+	conn.events <- slack.RTMEvent{Data: &slack.ChannelUnarchiveEvent{Channel: "C0ARCHIVED"}}
+	conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: slack.Msg{
+		Type:    "message",
+		Channel: "D0DIRECT",
+		User:    "U0USER",
+		Text:    "ping",
+	}}}
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.state.isDead("C0ARCHIVED") {
+		t.Fatal("expected the channel to be revived")
+	}
+}
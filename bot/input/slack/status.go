@@ -0,0 +1,118 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/micro/bot/command"
+)
+
+// Status is the health of the input's workspace connections
+type Status struct {
+	Running    bool
+	Workspaces []WorkspaceStatus
+}
+
+// WorkspaceStatus is the health of a single workspace's connection
+type WorkspaceStatus struct {
+	Team       string
+	Connected  bool
+	LastEvent  time.Time
+	Reconnects int
+	// messages received and commands executed
+	Messages int
+	Commands int
+}
+
+func (s Status) String() string {
+	if !s.Running {
+		return "slack: not running"
+	}
+
+	lines := []string{fmt.Sprintf("slack: running, %d workspaces", len(s.Workspaces))}
+
+	for _, w := range s.Workspaces {
+		state := "disconnected"
+		if w.Connected {
+			state = "connected"
+		}
+
+		last := "never"
+		if !w.LastEvent.IsZero() {
+			last = time.Since(w.LastEvent).Round(time.Second).String() + " ago"
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: %s, last event %s, %d reconnects, %d messages, %d commands",
+			w.Team, state, last, w.Reconnects, w.Messages, w.Commands))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// stats counts the activity of a workspace across its conns
+type stats struct {
+	sync.Mutex
+	connected bool
+	lastEvent time.Time
+	messages  int
+	commands  int
+}
+
+// event records an event being received
+func (s *stats) event(message bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastEvent = time.Now()
+	if message {
+		s.messages++
+	}
+}
+
+func (s *stats) command() {
+	s.Lock()
+	s.commands++
+	s.Unlock()
+}
+
+func (s *stats) setConnected(connected bool) {
+	s.Lock()
+	s.connected = connected
+	s.Unlock()
+}
+
+// Status returns the health of the input's connections
+func (p *slackInput) Status() Status {
+	p.Lock()
+	defer p.Unlock()
+
+	status := Status{Running: p.running}
+
+	for _, w := range p.workspaces {
+		w.stats.Lock()
+		status.Workspaces = append(status.Workspaces, WorkspaceStatus{
+			Team:       w.team,
+			Connected:  w.stats.connected,
+			LastEvent:  w.stats.lastEvent,
+			Reconnects: w.reconnects,
+			Messages:   w.stats.messages,
+			Commands:   w.stats.commands,
+		})
+		w.stats.Unlock()
+	}
+
+	return status
+}
+
+// statusCommand prints the status of the input
+func statusCommand(p *slackInput) command.Command {
+	usage := "status"
+	desc := "Returns the status of the slack connection"
+
+	return command.NewContextCommand("status", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		return []byte(p.Status().String()), nil
+	})
+}
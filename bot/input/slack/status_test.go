@@ -0,0 +1,106 @@
+package slack
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestStatusRegistered(t *testing.T) {
+	if _, ok := command.Commands["^status$"]; ok {
+		t.Fatal("expected no status command until slack is initialised")
+	}
+
+	os.Setenv("MICRO_SLACK_TOKEN", "xoxb-env")
+	defer os.Unsetenv("MICRO_SLACK_TOKEN")
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	// initialising again doesn't register it twice
+	for i := 0; i < 2; i++ {
+		if err := (&slackInput{}).Init(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := command.Registered()["^status$"]; !ok {
+		t.Fatal("expected the status command registered on init")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	w := &workspace{team: "T0PROD", reconnects: 2}
+	p := &slackInput{workspaces: []*workspace{w}}
+
+	cmd := statusCommand(p)
+
+	rsp, err := cmd.Exec("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rsp) != "slack: not running" {
+		t.Fatalf("expected not running got %q", rsp)
+	}
+
+	p.running = true
+
+	conn, rtm := newTestConn()
+	conn.stats = &w.stats
+
+	conn.events <- slack.RTMEvent{Type: "connected", Data: &slack.ConnectedEvent{}}
+	conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: slack.Msg{
+		Type:    "message",
+		Channel: "C0CHAN",
+		User:    "U0USER",
+		Text:    "just chatting",
+	}}}
+
+	msg := slack.Msg{
+		Type:    "message",
+		Channel: "C0CHAN",
+		User:    "U0USER",
+		Text:    "<@U0BOT> ping",
+	}
+	conn.events <- slack.RTMEvent{Type: "message", Data: &slack.MessageEvent{Msg: msg}}
+
+	var ev input.Event
+	if err := conn.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+	conn.Notify(ev)(nil)
+
+	status := p.Status()
+	if !status.Running || len(status.Workspaces) != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	ws := status.Workspaces[0]
+	if !ws.Connected || ws.LastEvent.IsZero() || ws.Reconnects != 2 || ws.Messages != 2 || ws.Commands != 1 {
+		t.Fatalf("unexpected workspace status %+v", ws)
+	}
+
+	rsp, err = cmd.Exec("status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(rsp), "T0PROD: connected, last event 0s ago, 2 reconnects, 2 messages, 1 commands") {
+		t.Fatalf("unexpected status %q", rsp)
+	}
+
+	// closing the conn disconnects it
+	conn.Close()
+	if p.Status().Workspaces[0].Connected {
+		t.Fatal("expected disconnected once closed")
+	}
+
+	if len(rtm.sent) > 0 {
+		t.Fatalf("unexpected message %+v", <-rtm.sent)
+	}
+}
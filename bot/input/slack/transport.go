@@ -0,0 +1,115 @@
+package slack
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// parseProxy parses the proxy url, returning the environment's proxy
+// e.g HTTPS_PROXY if it's empty
+func parseProxy(proxy string) (func(*http.Request) (*url.URL, error), error) {
+	if len(proxy) == 0 {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid slack proxy url: %v", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid slack proxy url %s: expected an http or https scheme", u.Redacted())
+	}
+
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("invalid slack proxy url %s: missing host", u.Redacted())
+	}
+
+	return http.ProxyURL(u), nil
+}
+
+// loadCA returns the system roots along with the certificates in file
+func loadCA(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading slack tls ca: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("slack tls ca contains no certificates")
+	}
+
+	return pool, nil
+}
+
+// newTransport returns the http client and websocket dialer used to
+// reach slack. Both go through the proxy, the websocket using CONNECT,
+// and trust the extra CA if given.
+func newTransport(proxy, ca string) (*http.Client, *websocket.Dialer, error) {
+	proxyFunc, err := parseProxy(proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if len(ca) > 0 {
+		pool, err := loadCA(ca)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy: proxyFunc,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            proxyFunc,
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: 45 * time.Second,
+	}
+
+	return &http.Client{Transport: transport}, dialer, nil
+}
+
+// httpClient returns c or the default client if it's unset
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// wsDialer returns d or the default dialer if it's unset
+func wsDialer(d *websocket.Dialer) *websocket.Dialer {
+	if d == nil {
+		return websocket.DefaultDialer
+	}
+	return d
+}
@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/nlopes/slack"
+)
+
+// how long a cached user is trusted before it's looked up again in case
+// a change event was missed
+var userTTL = time.Hour
+
+type cachedUser struct {
+	// display name and legacy username
+	name    string
+	handle  string
+	bot     bool
+	expires time.Time
+}
+
+// userCache lazily looks up users by ID as they're seen. Entries are
+// refreshed by user_change and team_join events.
+type userCache struct {
+	lookup func(id string) (*slack.User, error)
+
+	sync.Mutex
+	users map[string]cachedUser
+}
+
+func newUserCache(lookup func(id string) (*slack.User, error)) *userCache {
+	return &userCache{
+		lookup: lookup,
+		users:  make(map[string]cachedUser),
+	}
+}
+
+// get returns the cached user looking it up if unknown or expired
+func (c *userCache) get(id string) (cachedUser, bool) {
+	if len(id) == 0 {
+		return cachedUser{}, false
+	}
+
+	c.Lock()
+	u, ok := c.users[id]
+	c.Unlock()
+
+	if ok && time.Now().Before(u.expires) {
+		return u, true
+	}
+
+	if c.lookup == nil {
+		return u, ok
+	}
+
+	// don't hold the lock while calling slack
+	user, err := c.lookup(id)
+	if err != nil {
+		log.Logf("[slack] error looking up user %s: %v", id, err)
+		// better a stale name than none
+		return u, ok
+	}
+
+	return c.set(user), true
+}
+
+// displayName returns the name people know the user by. The username
+// is deprecated and often not what they'd recognise.
+func displayName(user *slack.User) string {
+	switch {
+	case len(user.Profile.DisplayName) > 0:
+		return user.Profile.DisplayName
+	case len(user.RealName) > 0:
+		return user.RealName
+	case len(user.Profile.RealName) > 0:
+		return user.Profile.RealName
+	}
+	return user.Name
+}
+
+// set caches the user
+func (c *userCache) set(user *slack.User) cachedUser {
+	u := cachedUser{
+		name:    displayName(user),
+		handle:  user.Name,
+		bot:     user.IsBot,
+		expires: time.Now().Add(userTTL),
+	}
+
+	c.Lock()
+	c.users[user.ID] = u
+	c.Unlock()
+
+	return u
+}
+
+// name returns the user's display name or an empty string if it can't
+// be found
+func (c *userCache) name(id string) string {
+	u, _ := c.get(id)
+	return u.name
+}
+
+// handle returns the user's username or an empty string if it can't be
+// found
+func (c *userCache) handle(id string) string {
+	u, _ := c.get(id)
+	return u.handle
+}
+
+// isBot returns true if the user is a bot
+func (c *userCache) isBot(id string) bool {
+	u, _ := c.get(id)
+	return u.bot
+}
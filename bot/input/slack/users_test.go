@@ -0,0 +1,125 @@
+package slack
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+func TestUserCache(t *testing.T) {
+	lookups := map[string]int{}
+	fail := false
+
+	c := newUserCache(func(id string) (*slack.User, error) {
+		lookups[id]++
+		if fail || id == "U0MISSING" {
+			return nil, errors.New("user_not_found")
+		}
+		return &slack.User{ID: id, Name: "john", IsBot: id == "U0BOT"}, nil
+	})
+
+	// unknown users are looked up once
+	for i := 0; i < 3; i++ {
+		if name := c.name("U0USER"); name != "john" {
+			t.Fatalf("expected john got %q", name)
+		}
+	}
+	if lookups["U0USER"] != 1 {
+		t.Fatalf("expected 1 lookup got %d", lookups["U0USER"])
+	}
+
+	if !c.isBot("U0BOT") || c.isBot("U0USER") {
+		t.Fatal("expected only U0BOT to be a bot")
+	}
+
+	if name := c.name("U0MISSING"); len(name) > 0 {
+		t.Fatalf("expected no name got %q", name)
+	}
+
+	// events replace the cached user
+	c.set(&slack.User{ID: "U0USER", Name: "johnny"})
+	if name := c.name("U0USER"); name != "johnny" {
+		t.Fatalf("expected johnny got %q", name)
+	}
+
+	// expired users are looked up again, keeping the stale name on error
+	ttl := userTTL
+	userTTL = -time.Second
+	defer func() {
+		userTTL = ttl
+	}()
+
+	c.set(&slack.User{ID: "U0USER", Name: "johnny"})
+	fail = true
+
+	if name := c.name("U0USER"); name != "johnny" {
+		t.Fatalf("expected stale name johnny got %q", name)
+	}
+	if lookups["U0USER"] != 2 {
+		t.Fatalf("expected 2 lookups got %d", lookups["U0USER"])
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	testData := []struct {
+		user slack.User
+		name string
+	}{
+		{slack.User{Name: "jsmith4", RealName: "John Smith", Profile: slack.UserProfile{DisplayName: "John"}}, "John"},
+		{slack.User{Name: "jsmith4", RealName: "John Smith"}, "John Smith"},
+		{slack.User{Name: "jsmith4", Profile: slack.UserProfile{RealName: "John Smith"}}, "John Smith"},
+		{slack.User{Name: "jsmith4"}, "jsmith4"},
+	}
+
+	for _, d := range testData {
+		if name := displayName(&d.user); name != d.name {
+			t.Fatalf("expected %q got %q", d.name, name)
+		}
+	}
+
+	// the username is kept for matching admins
+	c := newUserCache(nil)
+	c.set(&slack.User{ID: "U0USER", Name: "jsmith4", Profile: slack.UserProfile{DisplayName: "John"}})
+
+	if name, handle := c.name("U0USER"), c.handle("U0USER"); name != "John" || handle != "jsmith4" {
+		t.Fatalf("expected John and jsmith4 got %q and %q", name, handle)
+	}
+}
+
+func TestRecvUserChange(t *testing.T) {
+	conn, rtm := newTestConn()
+
+	conn.events <- slack.RTMEvent{
+		Type: "user_change",
+		Data: &slack.UserChangeEvent{User: slack.User{ID: "U0USER", Name: "john", Profile: slack.UserProfile{DisplayName: "Johnny"}}},
+	}
+	conn.events <- slack.RTMEvent{
+		Type: "team_join",
+		Data: &slack.TeamJoinEvent{User: slack.User{ID: "U0NEW", Name: "jane"}},
+	}
+
+	testData := map[string]string{
+		"U0USER": "Johnny",
+		"U0NEW":  "jane",
+	}
+
+	for user, expect := range testData {
+		// replies mention the user rather than naming them
+		msg := exchange(t, conn, rtm, slack.Msg{
+			Type:    "message",
+			Channel: "C0CHAN",
+			User:    user,
+			Text:    "<@U0BOT> ping",
+		})
+
+		if msg.Text != "<@"+user+">: pong" {
+			t.Fatalf("%s: expected a mention got %q", user, msg.Text)
+		}
+
+		if name := conn.users.name(user); name != expect {
+			t.Fatalf("%s: expected %q got %q", user, expect, name)
+		}
+	}
+}
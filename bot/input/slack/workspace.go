@@ -0,0 +1,241 @@
+package slack
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+// workspace is the connection state of a single slack workspace
+type workspace struct {
+	token    string
+	appToken string
+	api      *slack.Client
+	// team ID of the workspace once authenticated
+	team string
+
+	// messages handled by any of the workspace's conns
+	processed *processed
+
+	// slash commands and interactions received for the workspace
+	slash   chan *slashCommand
+	actions chan *action
+
+	// connected is set once the first conn is streamed
+	connected  bool
+	reconnects int
+	failures   int
+
+	// activity reported by Status
+	stats stats
+}
+
+// announce marks the bot as active and sets its status if configured.
+// Failures are logged since the bot works without them.
+func (w *workspace) announce(text, emoji string) {
+	if err := w.api.SetUserPresence("auto"); err != nil {
+		log.Logf("[slack] error setting presence for %s: %v", w.team, err)
+	}
+
+	if len(text) == 0 && len(emoji) == 0 {
+		return
+	}
+
+	if err := w.api.SetUserCustomStatus(text, emoji); err != nil {
+		log.Logf("[slack] error setting status for %s: %v", w.team, err)
+	}
+}
+
+// away marks the bot as away
+func (w *workspace) away() {
+	if err := w.api.SetUserPresence("away"); err != nil {
+		log.Logf("[slack] error setting presence for %s: %v", w.team, err)
+	}
+}
+
+// multiConn multiplexes the conns of several workspaces. Received events
+// carry their conn in the meta so replies go back to the same workspace.
+type multiConn struct {
+	p    *slackInput
+	exit chan bool
+	recv chan received
+
+	sync.Mutex
+	conns map[*workspace]*slackConn
+}
+
+type received struct {
+	conn  *slackConn
+	event input.Event
+	err   error
+}
+
+// workspace returns the workspace for a team or the only workspace
+func (p *slackInput) workspace(team string) *workspace {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.workspaces) == 1 {
+		return p.workspaces[0]
+	}
+
+	for _, w := range p.workspaces {
+		if w.team == team {
+			return w
+		}
+	}
+
+	return nil
+}
+
+func (p *slackInput) routeSlash(team string) chan *slashCommand {
+	if w := p.workspace(team); w != nil {
+		return w.slash
+	}
+	return nil
+}
+
+func (p *slackInput) routeActions(team string) chan *action {
+	if w := p.workspace(team); w != nil {
+		return w.actions
+	}
+	return nil
+}
+
+// streamAll connects to every workspace
+func (p *slackInput) streamAll(workspaces []*workspace) (input.Conn, error) {
+	m := &multiConn{
+		p:     p,
+		exit:  make(chan bool),
+		recv:  make(chan received),
+		conns: make(map[*workspace]*slackConn),
+	}
+
+	for _, w := range workspaces {
+		conn, err := p.stream(w)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.conns[w] = conn
+	}
+
+	for w, conn := range m.conns {
+		go m.pump(w, conn)
+	}
+
+	return m, nil
+}
+
+// pump receives events from a workspace reconnecting it on failure
+func (m *multiConn) pump(w *workspace, conn *slackConn) {
+	for {
+		var ev input.Event
+		err := conn.Recv(&ev)
+
+		select {
+		case <-m.exit:
+			return
+		default:
+		}
+
+		if err != nil {
+			log.Logf("[slack] workspace %s error: %v", w.team, err)
+			conn.Close()
+
+			// reconnect this workspace leaving the others running
+			conn, err = m.p.stream(w)
+			if err != nil {
+				select {
+				case m.recv <- received{err: err}:
+				case <-m.exit:
+				}
+				return
+			}
+
+			m.Lock()
+			m.conns[w] = conn
+			m.Unlock()
+
+			// closed while we were reconnecting
+			select {
+			case <-m.exit:
+				conn.Close()
+				return
+			default:
+			}
+			continue
+		}
+
+		select {
+		case m.recv <- received{conn: conn, event: ev}:
+		case <-m.exit:
+			return
+		}
+	}
+}
+
+// conn returns the conn an event was received on
+func (m *multiConn) conn(event input.Event) (*slackConn, bool) {
+	c, ok := event.Meta["conn"].(*slackConn)
+	return c, ok
+}
+
+func (m *multiConn) Close() error {
+	m.Lock()
+	defer m.Unlock()
+
+	select {
+	case <-m.exit:
+		return nil
+	default:
+		close(m.exit)
+	}
+
+	for _, c := range m.conns {
+		c.Close()
+	}
+
+	return nil
+}
+
+func (m *multiConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-m.exit:
+		return errors.New("connection closed")
+	case r := <-m.recv:
+		if r.err != nil {
+			return r.err
+		}
+
+		*event = r.event
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+		event.Meta["conn"] = r.conn
+		return nil
+	}
+}
+
+func (m *multiConn) Send(event *input.Event) error {
+	c, ok := m.conn(*event)
+	if !ok {
+		return errors.New("could not determine which workspace message is to")
+	}
+	return c.Send(event)
+}
+
+// Notify passes through to the workspace's conn
+func (m *multiConn) Notify(event input.Event) func(error) {
+	if c, ok := m.conn(event); ok {
+		return c.Notify(event)
+	}
+	return func(error) {}
+}
@@ -0,0 +1,94 @@
+package slack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+	"github.com/nlopes/slack"
+)
+
+func TestWorkspaceRoute(t *testing.T) {
+	prod := &workspace{team: "T0PROD"}
+	community := &workspace{team: "T0COMMUNITY"}
+
+	p := &slackInput{workspaces: []*workspace{prod}}
+
+	// a single workspace answers everything
+	if w := p.workspace("T0OTHER"); w != prod {
+		t.Fatalf("expected the only workspace got %+v", w)
+	}
+
+	p.workspaces = append(p.workspaces, community)
+
+	if w := p.workspace("T0COMMUNITY"); w != community {
+		t.Fatalf("expected community workspace got %+v", w)
+	}
+	if w := p.workspace("T0OTHER"); w != nil {
+		t.Fatalf("expected no workspace got %+v", w)
+	}
+}
+
+func TestMultiConn(t *testing.T) {
+	prod, prodRTM := newTestConn()
+	community, communityRTM := newTestConn()
+
+	m := &multiConn{
+		exit: make(chan bool),
+		recv: make(chan received),
+		conns: map[*workspace]*slackConn{
+			&workspace{team: "T0PROD"}:      prod,
+			&workspace{team: "T0COMMUNITY"}: community,
+		},
+	}
+
+	for w, c := range m.conns {
+		go m.pump(w, c)
+	}
+	defer m.Close()
+
+	for _, d := range []struct {
+		conn *slackConn
+		rtm  *testRTM
+		text string
+	}{
+		{prod, prodRTM, "ping"},
+		{community, communityRTM, "help"},
+	} {
+		d.conn.events <- slack.RTMEvent{
+			Type: "message",
+			Data: &slack.MessageEvent{Msg: slack.Msg{Type: "message", Channel: "D0DIRECT", User: "U0USER", Text: d.text}},
+		}
+
+		var ev input.Event
+		if err := m.Recv(&ev); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(ev.Data) != d.text || ev.Meta["conn"] != d.conn {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+
+		// the reply goes out on the workspace it came from
+		if err := m.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case msg := <-d.rtm.sent:
+			if msg.Text != "pong" {
+				t.Fatalf("unexpected message %+v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for reply")
+		}
+	}
+
+	if len(prodRTM.sent) > 0 || len(communityRTM.sent) > 0 {
+		t.Fatal("unexpected messages sent")
+	}
+
+	if err := m.Send(&input.Event{To: "D0DIRECT:U0USER", Data: []byte("pong")}); err == nil {
+		t.Fatal("expected error sending without a workspace")
+	}
+}
@@ -0,0 +1,34 @@
+package input
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SplitMessage breaks text into chunks of at most max runes, preferring
+// to break on newlines, for inputs which limit the size of a message
+func SplitMessage(text string, max int) []string {
+	if max <= 0 {
+		return []string{text}
+	}
+
+	var chunks []string
+	for utf8.RuneCountInString(text) > max {
+		// byte offset of the max'th rune
+		end := 0
+		for n := 0; n < max; n++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		i := strings.LastIndex(text[:end], "\n")
+		if i <= 0 {
+			i = end
+		}
+		chunks = append(chunks, text[:i])
+		text = strings.TrimPrefix(text[i:], "\n")
+	}
+	if len(text) > 0 {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
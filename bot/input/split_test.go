@@ -0,0 +1,31 @@
+package input
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	testData := []struct {
+		text   string
+		max    int
+		expect []string
+	}{
+		{"", 10, nil},
+		{"hello", 10, []string{"hello"}},
+		{"0123456789", 10, []string{"0123456789"}},
+		{"hello\nworld", 8, []string{"hello", "world"}},
+		{"ab\ncd\nefghij", 6, []string{"ab\ncd", "efghij"}},
+		{"0123456789\nabc", 10, []string{"0123456789", "abc"}},
+		{"helloworld", 5, []string{"hello", "world"}},
+		{"héllo wörld", 5, []string{"héllo", " wörl", "d"}},
+		{"hello", 0, []string{"hello"}},
+	}
+
+	for _, d := range testData {
+		got := SplitMessage(d.text, d.max)
+		if strings.Join(got, "|") != strings.Join(d.expect, "|") || len(got) != len(d.expect) {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, got)
+		}
+	}
+}
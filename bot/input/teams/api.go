@@ -0,0 +1,129 @@
+package teams
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type channelAccount struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type conversation struct {
+	ID string `json:"id"`
+	// personal, groupChat or channel
+	ConversationType string `json:"conversationType,omitempty"`
+	IsGroup          bool   `json:"isGroup,omitempty"`
+	TenantID         string `json:"tenantId,omitempty"`
+}
+
+type entity struct {
+	Type      string          `json:"type"`
+	Mentioned *channelAccount `json:"mentioned,omitempty"`
+	// the mention as it appears in the text e.g <at>micro</at>
+	Text string `json:"text,omitempty"`
+}
+
+// activity is what the Bot Framework sends and receives
+type activity struct {
+	Type         string          `json:"type"`
+	ID           string          `json:"id,omitempty"`
+	ServiceURL   string          `json:"serviceUrl,omitempty"`
+	ChannelID    string          `json:"channelId,omitempty"`
+	From         *channelAccount `json:"from,omitempty"`
+	Conversation *conversation   `json:"conversation,omitempty"`
+	Recipient    *channelAccount `json:"recipient,omitempty"`
+	Text         string          `json:"text,omitempty"`
+	TextFormat   string          `json:"textFormat,omitempty"`
+	ReplyToID    string          `json:"replyToId,omitempty"`
+	Entities     []entity        `json:"entities,omitempty"`
+}
+
+// apiError is returned when the connector refuses a request
+type apiError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("teams error %d: %s %s", e.StatusCode, e.Code, e.Message)
+}
+
+// client posts activities to the connector service
+type client struct {
+	tokens *tokenSource
+	http   *http.Client
+}
+
+func newClient(appID, appPassword string) *client {
+	hc := &http.Client{Timeout: 30 * time.Second}
+
+	return &client{
+		tokens: &tokenSource{
+			appID:       appID,
+			appPassword: appPassword,
+			http:        hc,
+		},
+		http: hc,
+	}
+}
+
+// reply posts the activity to the conversation of the one it replies to
+func (c *client) reply(to, a *activity) error {
+	u := fmt.Sprintf("%s/v3/conversations/%s/activities",
+		strings.TrimSuffix(to.ServiceURL, "/"), url.PathEscape(to.Conversation.ID))
+	if len(to.ID) > 0 {
+		u += "/" + url.PathEscape(to.ID)
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.tokens.get()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		data, _ := ioutil.ReadAll(rsp.Body)
+		json.Unmarshal(data, &body)
+
+		e := &apiError{rsp.StatusCode, body.Error.Code, body.Error.Message}
+		if len(e.Code) == 0 && len(e.Message) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		return e
+	}
+
+	return nil
+}
@@ -0,0 +1,321 @@
+package teams
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// where the Bot Framework publishes the keys it signs requests with
+	openIDURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	// where tokens for calling the connector are issued
+	tokenURL = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	// how long signing keys are cached, they're rolled over days apart
+	keyTTL = 24 * time.Hour
+	// allowed difference between our clock and the Bot Framework's
+	clockSkew = 5 * time.Minute
+)
+
+// the issuer of tokens sent by the Bot Framework
+const issuer = "https://api.botframework.com"
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	// the channels such as msteams the key may sign requests for
+	Endorsements []string `json:"endorsements"`
+}
+
+type signingKey struct {
+	key          *rsa.PublicKey
+	endorsements []string
+}
+
+// keySet caches the Bot Framework's signing keys, refetching them when
+// they expire or a request is signed with one it doesn't know
+type keySet struct {
+	url  string
+	http *http.Client
+
+	sync.Mutex
+	keys    map[string]*signingKey
+	fetched time.Time
+}
+
+func newKeySet(u string) *keySet {
+	return &keySet{
+		url:  u,
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (k *keySet) getJSON(u string, v interface{}) error {
+	rsp, err := k.http.Get(u)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching %s: %s", u, rsp.Status)
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(v)
+}
+
+func (k *keySet) fetch() (map[string]*signingKey, error) {
+	var config struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := k.getJSON(k.url, &config); err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := k.getJSON(config.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*signingKey)
+
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = &signingKey{
+			key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			},
+			endorsements: jwk.Endorsements,
+		}
+	}
+
+	return keys, nil
+}
+
+// get returns the key with the ID
+func (k *keySet) get(kid string) (*signingKey, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	key, ok := k.keys[kid]
+	age := time.Since(k.fetched)
+
+	switch {
+	case ok && age < keyTTL:
+		return key, nil
+	case !ok && age < time.Minute:
+		// don't let unknown key IDs cause a fetch on every request
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	keys, err := k.fetch()
+	if err != nil {
+		if ok {
+			// better a stale key than rejecting every request
+			return key, nil
+		}
+		return nil, fmt.Errorf("error fetching signing keys: %v", err)
+	}
+
+	k.keys = keys
+	k.fetched = time.Now()
+
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return key, nil
+}
+
+// audience is a JWT audience, a string or list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+type claims struct {
+	Issuer     string   `json:"iss"`
+	Audience   audience `json:"aud"`
+	Expires    int64    `json:"exp"`
+	NotBefore  int64    `json:"nbf"`
+	ServiceURL string   `json:"serviceurl"`
+}
+
+// validator checks requests were sent by the Bot Framework to our app
+type validator struct {
+	appID string
+	keys  *keySet
+}
+
+// validate checks the Authorization header of a request carrying an
+// activity from channelID with serviceURL
+func (v *validator) validate(header, channelID, serviceURL string) error {
+	if !strings.HasPrefix(header, "Bearer ") {
+		return errors.New("missing bearer token")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(header, "Bearer "), ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return err
+	}
+
+	if head.Alg != "RS256" {
+		return fmt.Errorf("unexpected signing algorithm %s", head.Alg)
+	}
+
+	key, err := v.keys.get(head.Kid)
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.New("malformed token signature")
+	}
+
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key.key, crypto.SHA256, sum[:], sig); err != nil {
+		return errors.New("invalid token signature")
+	}
+
+	if len(key.endorsements) > 0 && !contains(key.endorsements, channelID) {
+		return fmt.Errorf("signing key not endorsed for channel %s", channelID)
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	switch {
+	case c.Issuer != issuer:
+		return fmt.Errorf("unexpected token issuer %s", c.Issuer)
+	case !contains(c.Audience, v.appID):
+		return errors.New("token not issued for this app")
+	case c.Expires == 0 || now.After(time.Unix(c.Expires, 0).Add(clockSkew)):
+		return errors.New("token expired")
+	case c.NotBefore > 0 && now.Before(time.Unix(c.NotBefore, 0).Add(-clockSkew)):
+		return errors.New("token not yet valid")
+	case c.ServiceURL != serviceURL:
+		// the service url replies are posted to can't be swapped out
+		return errors.New("token not issued for the activity's service url")
+	}
+
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenSource issues the tokens the bot calls the connector with,
+// caching them until shortly before they expire
+type tokenSource struct {
+	appID       string
+	appPassword string
+	http        *http.Client
+
+	sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (t *tokenSource) get() (string, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.token) > 0 && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+
+	rsp, err := t.http.PostForm(tokenURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {t.appID},
+		"client_secret": {t.appPassword},
+		"scope":         {"https://api.botframework.com/.default"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer rsp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	json.NewDecoder(rsp.Body).Decode(&body)
+
+	if rsp.StatusCode != http.StatusOK || len(body.AccessToken) == 0 {
+		if len(body.Error) == 0 {
+			body.Error = rsp.Status
+		}
+		return "", fmt.Errorf("error getting teams token: %s %s", body.Error, body.Description)
+	}
+
+	t.token = body.AccessToken
+	// refresh early so a token doesn't expire in flight
+	t.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - clockSkew)
+
+	return t.token, nil
+}
@@ -0,0 +1,231 @@
+package teams
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaConversation on
+// outgoing events falling back to Event.To.
+const (
+	// MetaConversation is the ID of the conversation the message was
+	// sent in
+	MetaConversation = "teams_conversation"
+	// MetaUser is the ID of the user who sent the message
+	MetaUser = "teams_user"
+	// MetaUserName is the display name of the user who sent the message
+	MetaUserName = "teams_user_name"
+	// MetaActivityID is the ID of the message activity
+	MetaActivityID = "teams_activity_id"
+)
+
+// max length of a message, teams refuses messages over about 28KB
+var maxMessageSize = 20000
+
+// conversations remembers the last activity received in each
+// conversation so replies can be sent knowing only its ID
+type conversations struct {
+	sync.Mutex
+	last map[string]*activity
+}
+
+func newConversations() *conversations {
+	return &conversations{last: make(map[string]*activity)}
+}
+
+func (c *conversations) set(a *activity) {
+	c.Lock()
+	c.last[a.Conversation.ID] = a
+	c.Unlock()
+}
+
+func (c *conversations) get(id string) (*activity, bool) {
+	c.Lock()
+	defer c.Unlock()
+	a, ok := c.last[id]
+	return a, ok
+}
+
+// Satisfies the input.Conn interface
+type teamsConn struct {
+	client        *client
+	activities    <-chan *activity
+	conversations *conversations
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, activities <-chan *activity, convs *conversations, exit chan bool) *teamsConn {
+	return &teamsConn{
+		client:        c,
+		activities:    activities,
+		conversations: convs,
+		exit:          exit,
+		closed:        make(chan bool),
+	}
+}
+
+// stripMentions removes the bot's mentions from the text. Teams only
+// delivers channel messages which mention the bot so the command is
+// what's left.
+func stripMentions(a *activity) string {
+	text := a.Text
+
+	for _, e := range a.Entities {
+		if e.Type != "mention" || e.Mentioned == nil || a.Recipient == nil || e.Mentioned.ID != a.Recipient.ID {
+			continue
+		}
+		if len(e.Text) > 0 {
+			text = strings.Replace(text, e.Text, "", -1)
+		}
+	}
+
+	text = strings.Replace(text, "&nbsp;", " ", -1)
+	return strings.TrimSpace(text)
+}
+
+func (c *teamsConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *teamsConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.exit:
+			return errors.New("teams input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case a := <-c.activities:
+			if a.Type != "message" || a.From == nil || a.Conversation == nil || a.Recipient == nil {
+				continue
+			}
+
+			text := stripMentions(a)
+			if len(text) == 0 {
+				continue
+			}
+
+			c.conversations.set(a)
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			// conversation and user IDs contain colons so From is
+			// just the conversation
+			event.From = a.Conversation.ID
+			event.To = a.Recipient.ID
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = a
+			event.Meta[MetaConversation] = a.Conversation.ID
+			event.Meta[MetaUser] = a.From.ID
+			event.Meta[MetaUserName] = a.From.Name
+			event.Meta[MetaActivityID] = a.ID
+
+			return nil
+		}
+	}
+}
+
+// replyTo returns the activity the event answers
+func (c *teamsConn) replyTo(event *input.Event) (*activity, error) {
+	id := event.To
+	if conv, ok := event.Meta[MetaConversation].(string); ok && len(conv) > 0 {
+		id = conv
+	}
+
+	if reply, ok := event.Meta["reply"].(*activity); ok && (len(id) == 0 || reply.Conversation.ID == id) {
+		return reply, nil
+	}
+
+	if len(id) == 0 {
+		return nil, errors.New("require Event.To")
+	}
+
+	// the service url is only known once the conversation's been seen
+	last, ok := c.conversations.get(id)
+	if !ok {
+		return nil, errors.New("unknown teams conversation " + id)
+	}
+
+	return &activity{
+		ServiceURL:   last.ServiceURL,
+		Conversation: last.Conversation,
+		From:         last.From,
+		Recipient:    last.Recipient,
+	}, nil
+}
+
+// Notify shows the bot typing while the command runs
+func (c *teamsConn) Notify(event input.Event) func(error) {
+	reply, ok := event.Meta["reply"].(*activity)
+	if !ok {
+		return func(error) {}
+	}
+
+	typing := &activity{
+		Type:         "typing",
+		From:         reply.Recipient,
+		Recipient:    reply.From,
+		Conversation: reply.Conversation,
+	}
+
+	go func() {
+		if err := c.client.reply(&activity{ServiceURL: reply.ServiceURL, Conversation: reply.Conversation}, typing); err != nil {
+			log.Logf("[teams] error sending typing to %s: %v", reply.Conversation.ID, err)
+		}
+	}()
+
+	return func(error) {}
+}
+
+// MessageLimit is the most characters sent in a message
+func (c *teamsConn) MessageLimit() int {
+	return maxMessageSize
+}
+
+func (c *teamsConn) Send(event *input.Event) error {
+	to, err := c.replyTo(event)
+	if err != nil {
+		return err
+	}
+
+	data := string(event.Data)
+	if len(data) == 0 {
+		data = "(no output)"
+	}
+
+	for _, text := range input.SplitMessage(data, maxMessageSize) {
+		a := &activity{
+			Type:         "message",
+			From:         to.Recipient,
+			Recipient:    to.From,
+			Conversation: to.Conversation,
+			Text:         text,
+			TextFormat:   "plain",
+			ReplyToID:    to.ID,
+		}
+
+		if err := c.client.reply(to, a); err != nil {
+			log.Logf("[teams] error replying in %s: %v", to.Conversation.ID, err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package teams is a Microsoft Teams input for the bot built on the Bot
+// Framework. Activities are received on an HTTP endpoint and replies
+// posted back through the connector service.
+package teams
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// the largest activity accepted
+var maxBodySize int64 = 1 << 20
+
+type teamsInput struct {
+	appID       string
+	appPassword string
+	address     string
+
+	sync.Mutex
+	running       bool
+	exit          chan bool
+	client        *client
+	activities    chan *activity
+	conversations *conversations
+	server        *http.Server
+	// where the endpoint is listening, useful when the port is 0
+	addr net.Addr
+}
+
+func init() {
+	input.Register("teams", NewInput())
+}
+
+func (p *teamsInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "teams_app_id",
+			Usage: "Microsoft app ID of the bot's Bot Framework registration",
+		},
+		cli.StringFlag{
+			Name:  "teams_app_password",
+			Usage: "Microsoft app password of the bot's Bot Framework registration",
+		},
+		cli.StringFlag{
+			Name:  "teams_address",
+			Usage: "Address the messaging endpoint /api/messages listens on",
+			Value: ":3978",
+		},
+	}
+}
+
+func (p *teamsInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	appID := cfg.String("teams_app_id", "")
+	appPassword := cfg.String("teams_app_password", "")
+
+	if len(appID) == 0 {
+		return errors.New("missing teams app id")
+	}
+
+	if len(appPassword) == 0 {
+		return errors.New("missing teams app password")
+	}
+
+	p.appID = appID
+	p.appPassword = appPassword
+	p.address = cfg.String("teams_address", ":3978")
+
+	return nil
+}
+
+// endpoint receives activities from the Bot Framework
+func endpoint(v *validator, activities chan *activity, exit chan bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// the token is checked against the activity so read it first
+		b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "invalid activity", http.StatusBadRequest)
+			return
+		}
+
+		var a activity
+		if err := json.Unmarshal(b, &a); err != nil {
+			http.Error(w, "invalid activity", http.StatusBadRequest)
+			return
+		}
+
+		if err := v.validate(r.Header.Get("Authorization"), a.ChannelID, a.ServiceURL); err != nil {
+			log.Logf("[teams] rejected activity: %v", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// conversation updates and the like are acknowledged and ignored
+		if a.Type == "message" {
+			select {
+			case <-exit:
+				// the Bot Framework retries activities which weren't accepted
+				http.Error(w, "stopped", http.StatusServiceUnavailable)
+				return
+			case activities <- &a:
+			}
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func (p *teamsInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.activities, p.conversations, p.exit), nil
+}
+
+func (p *teamsInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.appID) == 0 || len(p.appPassword) == 0 {
+		return errors.New("missing teams app id or password")
+	}
+
+	c := newClient(p.appID, p.appPassword)
+
+	// fail fast on bad credentials
+	if _, err := c.tokens.get(); err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", p.address)
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+	activities := make(chan *activity)
+	v := &validator{appID: p.appID, keys: newKeySet(openIDURL)}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/messages", endpoint(v, activities, exit))
+	p.server = &http.Server{Handler: mux}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Logf("[teams] messaging endpoint error: %v", err)
+		}
+	}(p.server)
+
+	log.Logf("[teams] listening for activities on %s", l.Addr())
+
+	p.client = c
+	p.exit = exit
+	p.activities = activities
+	p.addr = l.Addr()
+	if p.conversations == nil {
+		p.conversations = newConversations()
+	}
+	p.running = true
+
+	return nil
+}
+
+func (p *teamsInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	if p.server != nil {
+		p.server.Close()
+		p.server = nil
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *teamsInput) String() string {
+	return "teams"
+}
+
+func NewInput() input.Input {
+	return &teamsInput{}
+}
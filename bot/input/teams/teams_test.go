@@ -0,0 +1,295 @@
+package teams
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// testServer is the Bot Framework: the openid metadata, token issuer and
+// the connector service replies are posted to
+type testServer struct {
+	*httptest.Server
+
+	key     *rsa.PrivateKey
+	replies chan reply
+}
+
+type reply struct {
+	path  string
+	auth  string
+	value activity
+}
+
+func newTestServer(t *testing.T) *testServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServer{key: key, replies: make(chan reply, 10)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openid", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": s.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []jsonWebKey{{
+				Kid:          "k1",
+				Kty:          "RSA",
+				N:            base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:            base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				Endorsements: []string{"msteams"},
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "app" || r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "Invalid client secret provided."})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "outgoing", "expires_in": 3600})
+	})
+	mux.HandleFunc("/v3/conversations/", func(w http.ResponseWriter, r *http.Request) {
+		var a activity
+		json.NewDecoder(r.Body).Decode(&a)
+		s.replies <- reply{r.URL.EscapedPath(), r.Header.Get("Authorization"), a}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"R1"}`))
+	})
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// sign returns a token for the claims signed with key
+func sign(t *testing.T, key *rsa.PrivateKey, c map[string]interface{}) string {
+	head, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	body, _ := json.Marshal(c)
+
+	data := base64.RawURLEncoding.EncodeToString(head) + "." + base64.RawURLEncoding.EncodeToString(body)
+	sum := sha256.Sum256([]byte(data))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return "Bearer " + data + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (s *testServer) claims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":        issuer,
+		"aud":        "app",
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"nbf":        time.Now().Add(-time.Minute).Unix(),
+		"serviceurl": s.URL,
+	}
+}
+
+func setURLs(s *testServer) func() {
+	openID, token := openIDURL, tokenURL
+	openIDURL, tokenURL = s.URL+"/openid", s.URL+"/token"
+	return func() { openIDURL, tokenURL = openID, token }
+}
+
+func TestValidate(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &validator{appID: "app", keys: newKeySet(s.URL + "/openid")}
+
+	with := func(k string, val interface{}) map[string]interface{} {
+		c := s.claims()
+		c[k] = val
+		return c
+	}
+
+	testData := []struct {
+		header  string
+		channel string
+		err     string
+	}{
+		{sign(t, s.key, s.claims()), "msteams", ""},
+		{sign(t, s.key, with("aud", []string{"other", "app"})), "msteams", ""},
+		{"", "msteams", "missing bearer token"},
+		{"Bearer abc", "msteams", "malformed token"},
+		{sign(t, other, s.claims()), "msteams", "invalid token signature"},
+		{sign(t, s.key, s.claims()), "slack", "not endorsed"},
+		{sign(t, s.key, with("aud", "other")), "msteams", "not issued for this app"},
+		{sign(t, s.key, with("iss", "https://evil.example.com")), "msteams", "unexpected token issuer"},
+		{sign(t, s.key, with("exp", time.Now().Add(-time.Hour).Unix())), "msteams", "token expired"},
+		{sign(t, s.key, with("nbf", time.Now().Add(time.Hour).Unix())), "msteams", "not yet valid"},
+		{sign(t, s.key, with("serviceurl", "https://evil.example.com")), "msteams", "service url"},
+	}
+
+	for i, d := range testData {
+		err := v.validate(d.header, d.channel, s.URL)
+		if len(d.err) == 0 && err != nil {
+			t.Fatalf("%d: unexpected error %v", i, err)
+		}
+		if len(d.err) > 0 && (err == nil || !strings.Contains(err.Error(), d.err)) {
+			t.Fatalf("%d: expected error %q got %v", i, d.err, err)
+		}
+	}
+}
+
+func TestStripMentions(t *testing.T) {
+	bot := &channelAccount{ID: "28:bot", Name: "micro"}
+
+	testData := []struct {
+		text     string
+		entities []entity
+		expect   string
+	}{
+		{"<at>micro</at> deploy api", []entity{{Type: "mention", Mentioned: bot, Text: "<at>micro</at>"}}, "deploy api"},
+		{"<at>micro</at>&nbsp;ping <at>john</at>", []entity{
+			{Type: "mention", Mentioned: bot, Text: "<at>micro</at>"},
+			{Type: "mention", Mentioned: &channelAccount{ID: "29:john"}, Text: "<at>john</at>"},
+		}, "ping <at>john</at>"},
+		{" ping ", nil, "ping"},
+	}
+
+	for _, d := range testData {
+		got := stripMentions(&activity{Text: d.text, Entities: d.entities, Recipient: bot})
+		if got != d.expect {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, got)
+		}
+	}
+}
+
+func TestTeams(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	defer setURLs(s)()
+
+	// bad credentials fail on start
+	p := NewInput().(*teamsInput)
+	p.appID, p.appPassword, p.address = "app", "wrong", "127.0.0.1:0"
+	if err := p.Start(); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected invalid_client got %v", err)
+	}
+
+	p.appPassword = "secret"
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	endpoint := "http://" + p.addr.String() + "/api/messages"
+
+	post := func(auth string, body []byte) int {
+		req, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+		req.Header.Set("Authorization", auth)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		return rsp.StatusCode
+	}
+
+	bot := &channelAccount{ID: "28:bot", Name: "micro"}
+	msg := activity{
+		Type:         "message",
+		ID:           "1234",
+		ServiceURL:   s.URL,
+		ChannelID:    "msteams",
+		From:         &channelAccount{ID: "29:john", Name: "John"},
+		Conversation: &conversation{ID: "19:abc@thread.skype;messageid=1234", ConversationType: "channel"},
+		Recipient:    bot,
+		Text:         "<at>micro</at> ping",
+		Entities:     []entity{{Type: "mention", Mentioned: bot, Text: "<at>micro</at>"}},
+	}
+	body, _ := json.Marshal(msg)
+
+	if code := post("", body); code != http.StatusUnauthorized {
+		t.Fatalf("expected unsigned activities to be rejected got %d", code)
+	}
+
+	if code := post(sign(t, s.key, s.claims()), []byte("{")); code != http.StatusBadRequest {
+		t.Fatalf("expected malformed activities to be rejected got %d", code)
+	}
+
+	// delivered once received
+	done := make(chan int)
+	go func() {
+		done <- post(sign(t, s.key, s.claims()), body)
+	}()
+
+	var ev input.Event
+	if err := c.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := <-done; code != http.StatusAccepted {
+		t.Fatalf("expected the activity to be accepted got %d", code)
+	}
+
+	if ev.From != msg.Conversation.ID || string(ev.Data) != "ping" || ev.Meta[MetaUser] != "29:john" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	// the bot shows it's typing while the command runs
+	c.(*teamsConn).Notify(ev)(nil)
+
+	if r := <-s.replies; r.value.Type != "typing" {
+		t.Fatalf("expected typing got %+v", r.value)
+	}
+
+	if err := c.Send(&input.Event{To: ev.From, Meta: ev.Meta, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-s.replies
+	if r.auth != "Bearer outgoing" {
+		t.Fatalf("unexpected authorization %q", r.auth)
+	}
+	if r.path != "/v3/conversations/19:abc@thread.skype%3Bmessageid=1234/activities/1234" {
+		t.Fatalf("unexpected path %s", r.path)
+	}
+	if r.value.Text != "pong" || r.value.ReplyToID != "1234" || r.value.Recipient.ID != "29:john" || r.value.From.ID != bot.ID {
+		t.Fatalf("unexpected reply %+v", r.value)
+	}
+
+	// conversations seen can be posted to by ID
+	if err := c.Send(&input.Event{To: ev.From, Type: input.TextEvent, Data: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := <-s.replies; r.path != "/v3/conversations/19:abc@thread.skype%3Bmessageid=1234/activities" || r.value.Text != "hello" {
+		t.Fatalf("unexpected message %s %+v", r.path, r.value)
+	}
+
+	if err := c.Send(&input.Event{To: "19:unknown", Data: []byte("hello")}); err == nil {
+		t.Fatal("expected an error sending to an unknown conversation")
+	}
+}
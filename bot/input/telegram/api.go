@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// apiURL is the Bot API endpoint, overridden in tests
+var apiURL = "https://api.telegram.org"
+
+// user is a telegram user or bot
+type user struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	Username  string `json:"username,omitempty"`
+}
+
+// chat is a private chat, group, supergroup or channel
+type chat struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+// entity marks a command, mention or link in the message text
+type entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	User   *user  `json:"user,omitempty"`
+}
+
+type message struct {
+	MessageID      int64    `json:"message_id"`
+	From           *user    `json:"from,omitempty"`
+	Chat           chat     `json:"chat"`
+	Date           int64    `json:"date"`
+	Text           string   `json:"text,omitempty"`
+	Entities       []entity `json:"entities,omitempty"`
+	ReplyToMessage *message `json:"reply_to_message,omitempty"`
+	// set on topic messages in forum supergroups
+	MessageThreadID int64 `json:"message_thread_id,omitempty"`
+}
+
+type update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *message `json:"message,omitempty"`
+}
+
+type response struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// apiError is returned when the Bot API refuses a call
+type apiError struct {
+	Code        int
+	Description string
+	// set when rate limited
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("telegram error %d: %s", e.Code, e.Description)
+}
+
+// client calls the Bot API
+type client struct {
+	token string
+	http  *http.Client
+}
+
+func newClient(token string) *client {
+	return &client{
+		token: token,
+		// long enough to outlast a long poll
+		http: &http.Client{Timeout: pollTimeout + 30*time.Second},
+	}
+}
+
+// call posts params as JSON to the method decoding the result into v
+func (c *client) call(method string, params, v interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := c.http.Post(apiURL+"/bot"+c.token+"/"+method, "application/json", bytes.NewReader(b))
+	if err != nil {
+		// the token is part of the url, keep it out of logs
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("error calling telegram %s: %v", method, err)
+	}
+	defer rsp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(rsp.Body).Decode(&r); err != nil {
+		return fmt.Errorf("error decoding telegram %s response: %v", method, err)
+	}
+
+	if !r.OK {
+		return &apiError{
+			Code:        r.ErrorCode,
+			Description: r.Description,
+			RetryAfter:  time.Duration(r.Parameters.RetryAfter) * time.Second,
+		}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(r.Result, v)
+}
+
+func (c *client) getMe() (*user, error) {
+	var u user
+	if err := c.call("getMe", struct{}{}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// getUpdates long polls for updates after offset
+func (c *client) getUpdates(offset int64, timeout time.Duration) ([]update, error) {
+	var updates []update
+	err := c.call("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	return updates, err
+}
+
+type sendParams struct {
+	ChatID           int64  `json:"chat_id"`
+	Text             string `json:"text"`
+	ReplyToMessageID int64  `json:"reply_to_message_id,omitempty"`
+	MessageThreadID  int64  `json:"message_thread_id,omitempty"`
+	// reply even if the quoted message was deleted
+	AllowSendingWithoutReply bool `json:"allow_sending_without_reply,omitempty"`
+}
+
+func (c *client) sendMessage(p *sendParams) error {
+	return c.call("sendMessage", p, nil)
+}
+
+func (c *client) setWebhook(hook, secret string) error {
+	params := map[string]interface{}{
+		"url":             hook,
+		"allowed_updates": []string{"message"},
+	}
+	if len(secret) > 0 {
+		params["secret_token"] = secret
+	}
+	return c.call("setWebhook", params, nil)
+}
+
+// deleteWebhook switches back to polling which fails while a webhook is set
+func (c *client) deleteWebhook() error {
+	return c.call("deleteWebhook", struct{}{}, nil)
+}
@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events. Send honours MetaChat on outgoing
+// events falling back to Event.To.
+const (
+	// MetaChat is the int64 ID of the chat the message was sent in
+	MetaChat = "telegram_chat"
+	// MetaUser is the int64 ID of the user who sent the message
+	MetaUser = "telegram_user"
+	// MetaMessageID is the int64 ID of the message, unique within the chat
+	MetaMessageID = "telegram_message_id"
+)
+
+var (
+	// max length of a message
+	maxMessageSize = 4096
+	// how many times a rate limited message is sent
+	sendAttempts = 3
+)
+
+// Satisfies the input.Conn interface
+type telegramConn struct {
+	client  *client
+	me      *user
+	updates <-chan *message
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client, me *user, updates <-chan *message, exit chan bool) *telegramConn {
+	return &telegramConn{
+		client:  c,
+		me:      me,
+		updates: updates,
+		exit:    exit,
+		closed:  make(chan bool),
+	}
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Commands such as /ping or /ping@bot, messages starting with @bot and
+// replies to the bot are accepted in groups, anything in private chats.
+// Groups with privacy mode disabled deliver every message so the rest
+// are ignored.
+func (c *telegramConn) addressed(msg *message) (string, bool) {
+	if msg.From == nil || msg.From.IsBot || len(msg.Text) == 0 {
+		return "", false
+	}
+
+	text := strings.TrimSpace(msg.Text)
+
+	if strings.HasPrefix(text, "/") {
+		fields := strings.SplitN(text[1:], " ", 2)
+		cmd := fields[0]
+
+		// commands can name the bot they're for
+		if i := strings.Index(cmd, "@"); i >= 0 {
+			if !strings.EqualFold(cmd[i+1:], c.me.Username) {
+				return "", false
+			}
+			cmd = cmd[:i]
+		}
+
+		if len(cmd) == 0 {
+			return "", false
+		}
+
+		if len(fields) == 2 {
+			return cmd + " " + strings.TrimSpace(fields[1]), true
+		}
+		return cmd, true
+	}
+
+	if mention := "@" + c.me.Username; len(c.me.Username) > 0 && len(text) >= len(mention) && strings.EqualFold(text[:len(mention)], mention) {
+		rest := text[len(mention):]
+		if len(rest) == 0 || strings.ContainsAny(rest[:1], " ,:") {
+			return strings.TrimLeft(rest, " ,:"), true
+		}
+	}
+
+	if msg.Chat.Type == "private" {
+		return text, true
+	}
+
+	if r := msg.ReplyToMessage; r != nil && r.From != nil && r.From.ID == c.me.ID {
+		return text, true
+	}
+
+	return "", false
+}
+
+func (c *telegramConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *telegramConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.exit:
+			return errors.New("telegram input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case msg := <-c.updates:
+			text, ok := c.addressed(msg)
+			if !ok || len(text) == 0 {
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			event.From = fmt.Sprintf("%d:%d", msg.Chat.ID, msg.From.ID)
+			event.To = c.me.Username
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = msg
+			event.Meta[MetaChat] = msg.Chat.ID
+			event.Meta[MetaUser] = msg.From.ID
+			event.Meta[MetaMessageID] = msg.MessageID
+
+			return nil
+		}
+	}
+}
+
+// send delivers the message retrying while it's rate limited
+func (c *telegramConn) send(p *sendParams) error {
+	for i := 1; ; i++ {
+		err := c.client.sendMessage(p)
+		aerr, ok := err.(*apiError)
+		if !ok || aerr.RetryAfter == 0 || i >= sendAttempts {
+			return err
+		}
+
+		select {
+		case <-c.exit:
+			return err
+		case <-time.After(aerr.RetryAfter):
+		}
+	}
+}
+
+// MessageLimit is the most characters sent in a message
+func (c *telegramConn) MessageLimit() int {
+	return maxMessageSize
+}
+
+func (c *telegramConn) Send(event *input.Event) error {
+	chat, ok := event.Meta[MetaChat].(int64)
+	if !ok {
+		// To is chat:user as set by Recv
+		id := strings.Split(event.To, ":")[0]
+		if len(id) == 0 {
+			return errors.New("require Event.To")
+		}
+
+		var err error
+		chat, err = strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid telegram chat %s", id)
+		}
+	}
+
+	var replyTo, thread int64
+
+	// quote the message we're replying to
+	if reply, ok := event.Meta["reply"].(*message); ok && reply.Chat.ID == chat {
+		replyTo = reply.MessageID
+		thread = reply.MessageThreadID
+	}
+
+	for i, text := range splitMessage(string(event.Data), maxMessageSize) {
+		p := &sendParams{
+			ChatID:          chat,
+			Text:            text,
+			MessageThreadID: thread,
+		}
+		if i == 0 && replyTo > 0 {
+			p.ReplyToMessageID = replyTo
+			p.AllowSendingWithoutReply = true
+		}
+
+		if err := c.send(p); err != nil {
+			log.Logf("[telegram] error sending message to %d: %v", chat, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitMessage splits text as other inputs do, telegram refuses to
+// send empty messages
+func splitMessage(text string, max int) []string {
+	if len(text) == 0 {
+		return []string{"(no output)"}
+	}
+	return input.SplitMessage(text, max)
+}
@@ -0,0 +1,264 @@
+// Package telegram is a telegram input for the bot. It long polls the
+// Bot API for updates by default or receives them on a webhook.
+package telegram
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+var (
+	// how long a getUpdates call waits for updates
+	pollTimeout = 30 * time.Second
+	// how long to wait after a failed poll
+	pollBackoff = 5 * time.Second
+)
+
+type telegramInput struct {
+	token string
+	// updates are received on the webhook if set, polled otherwise
+	webhookURL     string
+	webhookAddress string
+	webhookSecret  string
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+	me      *user
+	updates chan *message
+	server  *http.Server
+}
+
+func init() {
+	input.Register("telegram", NewInput())
+}
+
+func (p *telegramInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "telegram_token",
+			Usage: "Telegram bot token from BotFather",
+		},
+		cli.StringFlag{
+			Name:  "telegram_webhook_url",
+			Usage: "Public https url telegram delivers updates to; long polling is used if empty",
+		},
+		cli.StringFlag{
+			Name:  "telegram_webhook_address",
+			Usage: "Address the webhook listens on",
+			Value: ":8443",
+		},
+		cli.StringFlag{
+			Name:  "telegram_webhook_secret",
+			Usage: "Secret telegram sends with webhook updates to authenticate them",
+		},
+	}
+}
+
+func (p *telegramInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	token := cfg.String("telegram_token", "")
+	if len(token) == 0 {
+		return errors.New("missing telegram token")
+	}
+
+	p.token = token
+	p.webhookURL = cfg.String("telegram_webhook_url", "")
+	p.webhookAddress = cfg.String("telegram_webhook_address", ":8443")
+	p.webhookSecret = cfg.String("telegram_webhook_secret", "")
+
+	if len(p.webhookURL) > 0 && len(p.webhookAddress) == 0 {
+		return errors.New("telegram webhook requires an address to listen on")
+	}
+
+	return nil
+}
+
+// poll long polls for updates until exit is closed
+func (p *telegramInput) poll(c *client, updates chan *message, exit chan bool) {
+	var offset int64
+
+	for {
+		select {
+		case <-exit:
+			return
+		default:
+		}
+
+		batch, err := c.getUpdates(offset, pollTimeout)
+		if err != nil {
+			wait := pollBackoff
+			if aerr, ok := err.(*apiError); ok && aerr.RetryAfter > 0 {
+				wait = aerr.RetryAfter
+			}
+
+			log.Logf("[telegram] error getting updates, retrying in %v: %v", wait, err)
+
+			select {
+			case <-exit:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		for _, u := range batch {
+			// acknowledged by the next poll
+			offset = u.UpdateID + 1
+
+			if u.Message == nil {
+				continue
+			}
+
+			select {
+			case <-exit:
+				return
+			case updates <- u.Message:
+			}
+		}
+	}
+}
+
+// webhook receives updates posted by telegram
+func webhook(secret string, updates chan *message, exit chan bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if len(secret) > 0 && subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var u update
+		if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		if u.Message != nil {
+			select {
+			case <-exit:
+				// telegram redelivers updates which weren't accepted
+				http.Error(w, "stopped", http.StatusServiceUnavailable)
+				return
+			case updates <- u.Message:
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (p *telegramInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client, p.me, p.updates, p.exit), nil
+}
+
+func (p *telegramInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.token) == 0 {
+		return errors.New("missing telegram token")
+	}
+
+	c := newClient(p.token)
+
+	// fail fast on a bad token
+	me, err := c.getMe()
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+	updates := make(chan *message)
+
+	if len(p.webhookURL) > 0 {
+		l, err := net.Listen("tcp", p.webhookAddress)
+		if err != nil {
+			return err
+		}
+
+		if err := c.setWebhook(p.webhookURL, p.webhookSecret); err != nil {
+			l.Close()
+			return err
+		}
+
+		p.server = &http.Server{Handler: webhook(p.webhookSecret, updates, exit)}
+
+		go func(srv *http.Server) {
+			if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+				log.Logf("[telegram] webhook server error: %v", err)
+			}
+		}(p.server)
+	} else {
+		// updates can't be polled while a webhook is set
+		if err := c.deleteWebhook(); err != nil {
+			return err
+		}
+
+		go p.poll(c, updates, exit)
+	}
+
+	log.Logf("[telegram] connected as @%s", me.Username)
+
+	p.client = c
+	p.me = me
+	p.exit = exit
+	p.updates = updates
+	p.running = true
+
+	return nil
+}
+
+func (p *telegramInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	if p.server != nil {
+		p.server.Close()
+		p.server = nil
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *telegramInput) String() string {
+	return "telegram"
+}
+
+func NewInput() input.Input {
+	return &telegramInput{}
+}
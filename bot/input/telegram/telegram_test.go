@@ -0,0 +1,284 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+var testBot = &user{ID: 100, IsBot: true, FirstName: "Micro", Username: "micro_bot"}
+
+// testServer is a fake Bot API delivering updates to getUpdates. Set
+// apiURL to its URL to use it.
+type testServer struct {
+	*httptest.Server
+
+	updates chan update
+	sent    chan sendParams
+
+	sync.Mutex
+	calls []string
+}
+
+func newTestServer() *testServer {
+	s := &testServer{
+		updates: make(chan update, 10),
+		sent:    make(chan sendParams, 10),
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		method := parts[len(parts)-1]
+
+		if parts[1] != "bottoken" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 401, "description": "Unauthorized"})
+			return
+		}
+
+		s.Lock()
+		s.calls = append(s.calls, method)
+		s.Unlock()
+
+		var result interface{} = true
+
+		switch method {
+		case "getMe":
+			result = testBot
+		case "getUpdates":
+			var updates []update
+			select {
+			case u := <-s.updates:
+				updates = append(updates, u)
+			case <-time.After(50 * time.Millisecond):
+			}
+			result = updates
+		case "sendMessage":
+			var p sendParams
+			json.NewDecoder(r.Body).Decode(&p)
+			s.sent <- p
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}))
+
+	return s
+}
+
+// startServer points the client at a fake Bot API
+func startServer() (*testServer, func()) {
+	s := newTestServer()
+
+	url := apiURL
+	apiURL = s.URL
+
+	return s, func() {
+		apiURL = url
+		s.Close()
+	}
+}
+
+func (s *testServer) called(method string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, c := range s.calls {
+		if c == method {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAddressed(t *testing.T) {
+	conn := newConn(nil, testBot, nil, nil)
+
+	john := &user{ID: 1, FirstName: "John", Username: "john"}
+
+	testData := []struct {
+		msg    message
+		expect string
+		ok     bool
+	}{
+		{message{From: john, Chat: chat{Type: "private"}, Text: "ping"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "private"}, Text: "/ping"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "/ping"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "/ping@micro_bot now"}, "ping now", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "/ping@Micro_Bot"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "/ping@other_bot"}, "", false},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "@micro_bot ping"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "@micro_bot: ping"}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "@micro_botty ping"}, "", false},
+		{message{From: john, Chat: chat{Type: "supergroup"}, Text: "ping"}, "", false},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "ping", ReplyToMessage: &message{From: testBot}}, "ping", true},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "ping", ReplyToMessage: &message{From: john}}, "", false},
+		{message{From: john, Chat: chat{Type: "group"}, Text: "/"}, "", false},
+		{message{From: &user{ID: 2, IsBot: true}, Chat: chat{Type: "private"}, Text: "ping"}, "", false},
+		{message{Chat: chat{Type: "channel"}, Text: "ping"}, "", false},
+	}
+
+	for _, d := range testData {
+		text, ok := conn.addressed(&d.msg)
+		if ok != d.ok || text != d.expect {
+			t.Fatalf("%q in %s: expected %q %v got %q %v", d.msg.Text, d.msg.Chat.Type, d.expect, d.ok, text, ok)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	testData := []struct {
+		text   string
+		max    int
+		expect []string
+	}{
+		{"", 10, []string{"(no output)"}},
+		{"hello", 10, []string{"hello"}},
+		{"hello\nworld", 8, []string{"hello", "world"}},
+		{"helloworld", 5, []string{"hello", "world"}},
+		{"héllo wörld", 5, []string{"héllo", " wörl", "d"}},
+	}
+
+	for _, d := range testData {
+		got := splitMessage(d.text, d.max)
+		if strings.Join(got, "|") != strings.Join(d.expect, "|") {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, got)
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	srv, stop := startServer()
+	defer stop()
+
+	io := NewInput().(*telegramInput)
+	io.token = "token"
+
+	if err := io.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer io.Stop()
+
+	if !srv.called("deleteWebhook") {
+		t.Fatal("expected the webhook to be deleted before polling")
+	}
+
+	c, err := io.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	john := &user{ID: 1, FirstName: "John", Username: "john"}
+
+	// not for the bot
+	srv.updates <- update{UpdateID: 1, Message: &message{MessageID: 10, From: john, Chat: chat{ID: -5, Type: "group"}, Text: "hello"}}
+	srv.updates <- update{UpdateID: 2, Message: &message{MessageID: 11, From: john, Chat: chat{ID: -5, Type: "group"}, Text: "/ping@micro_bot"}}
+
+	var ev input.Event
+	if err := c.Recv(&ev); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ev.Data) != "ping" || ev.From != "-5:1" || ev.Meta[MetaChat] != int64(-5) || ev.Meta[MetaMessageID] != int64(11) {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	// replies quote the command
+	if err := c.Send(&input.Event{Meta: ev.Meta, To: ev.From, Type: input.TextEvent, Data: []byte("pong")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case p := <-srv.sent:
+		if p.ChatID != -5 || p.Text != "pong" || p.ReplyToMessageID != 11 {
+			t.Fatalf("unexpected message %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the reply")
+	}
+
+	// and can be addressed without meta
+	if err := c.Send(&input.Event{To: "-5:1", Type: input.TextEvent, Data: []byte("hi")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := <-srv.sent; p.ChatID != -5 || p.ReplyToMessageID != 0 {
+		t.Fatalf("unexpected message %+v", p)
+	}
+
+	io.Stop()
+
+	if err := c.Recv(&ev); err == nil {
+		t.Fatal("expected an error once stopped")
+	}
+}
+
+func TestStartInvalidToken(t *testing.T) {
+	_, stop := startServer()
+	defer stop()
+
+	io := NewInput().(*telegramInput)
+	io.token = "wrong"
+
+	if err := io.Start(); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Fatalf("expected unauthorized got %v", err)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	updates := make(chan *message, 1)
+	exit := make(chan bool)
+
+	h := webhook("s3cr3t", updates, exit)
+
+	body := `{"update_id":1,"message":{"message_id":10,"from":{"id":1},"chat":{"id":1,"type":"private"},"text":"ping"}}`
+
+	testData := []struct {
+		method string
+		secret string
+		code   int
+	}{
+		{"GET", "s3cr3t", http.StatusMethodNotAllowed},
+		{"POST", "", http.StatusUnauthorized},
+		{"POST", "wrong", http.StatusUnauthorized},
+		{"POST", "s3cr3t", http.StatusOK},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest(d.method, "/", strings.NewReader(body))
+		r.Header.Set("X-Telegram-Bot-Api-Secret-Token", d.secret)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != d.code {
+			t.Fatalf("%s with %q: expected %d got %d", d.method, d.secret, d.code, w.Code)
+		}
+	}
+
+	select {
+	case msg := <-updates:
+		if msg.Text != "ping" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	default:
+		t.Fatal("expected the update to be delivered")
+	}
+
+	// updates aren't accepted once stopped so they're redelivered
+	close(exit)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("X-Telegram-Bot-Api-Secret-Token", "s3cr3t")
+	updates <- &message{}
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d got %d", http.StatusServiceUnavailable, w.Code)
+	}
+}
@@ -0,0 +1,176 @@
+// Package tokenize splits command text into arguments so every input
+// parses commands the same way
+package tokenize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Error is returned for text which can't be split
+type Error struct {
+	// Quote is the unbalanced quote
+	Quote rune
+	// Pos is the byte offset of the opening quote
+	Pos int
+}
+
+func (e *Error) Error() string {
+	name := "double"
+	if e.Quote == '\'' {
+		name = "single"
+	}
+	return fmt.Sprintf("unbalanced %s quote at position %d", name, e.Pos+1)
+}
+
+// PipeError is returned for a pipeline missing a command
+type PipeError struct {
+	// Pos is the byte offset of the pipe without a command either side
+	Pos int
+}
+
+func (e *PipeError) Error() string {
+	return fmt.Sprintf("missing command in pipeline at position %d", e.Pos+1)
+}
+
+// Split splits text into arguments like a shell. Whitespace separates
+// arguments unless inside double or single quotes. A backslash escapes
+// the following character outside of quotes and a quote or backslash
+// inside double quotes. Single quotes are taken literally.
+func Split(text string) ([]string, error) {
+	cmds, _, err := split(text, false, 0)
+	if err != nil || len(cmds) == 0 {
+		return nil, err
+	}
+	return cmds[0], nil
+}
+
+// SplitPipeline splits text into the commands of a pipeline at each
+// pipe which isn't quoted or escaped, and each command into arguments
+// as Split does. Text without a pipe is a single command.
+func SplitPipeline(text string) ([][]string, error) {
+	cmds, _, err := split(text, true, 0)
+	return cmds, err
+}
+
+// Cut splits the first n arguments off text as Split does and returns
+// the rest of the text as it is, such as a JSON body whose quotes
+// matter. The rest is empty when there are n arguments or fewer.
+func Cut(text string, n int) ([]string, string, error) {
+	cmds, end, err := split(text, false, n)
+	if err != nil || len(cmds) == 0 {
+		return nil, "", err
+	}
+	return cmds[0], strings.TrimSpace(text[end:]), nil
+}
+
+// split splits text into commands, at pipes if set, of arguments. It
+// stops after max arguments if max is set, returning the byte offset
+// it stopped at.
+func split(text string, pipes bool, max int) ([][]string, int, error) {
+	var (
+		cmds   [][]string
+		args   []string
+		arg    strings.Builder
+		inArg  bool
+		quote  rune
+		start  int
+		escape bool
+		pipe   int
+	)
+
+	for i, r := range text {
+		switch {
+		case escape:
+			// only quotes and backslashes are escaped in double quotes
+			if quote == '"' && r != '"' && r != '\\' {
+				arg.WriteRune('\\')
+			}
+			arg.WriteRune(r)
+			escape = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escape = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			start = i
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			if max > 0 && len(args) == max {
+				return [][]string{args}, i, nil
+			}
+		case r == '|' && pipes:
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			if len(args) == 0 {
+				return nil, 0, &PipeError{Pos: i}
+			}
+			cmds = append(cmds, args)
+			args = nil
+			pipe = i
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, 0, &Error{Quote: quote, Pos: start}
+	}
+
+	// keep a trailing backslash rather than dropping it
+	if escape {
+		arg.WriteRune('\\')
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	if len(args) == 0 {
+		// nothing after the last pipe
+		if len(cmds) > 0 {
+			return nil, 0, &PipeError{Pos: pipe}
+		}
+		return nil, len(text), nil
+	}
+
+	return append(cmds, args), len(text), nil
+}
+
+// Escape escapes quotes, backslashes and pipes in text so Split and
+// SplitPipeline return its words as they are
+func Escape(text string) string {
+	var b strings.Builder
+
+	for _, r := range text {
+		switch r {
+		case '"', '\'', '\\', '|':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,160 @@
+package tokenize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	testData := []struct {
+		text string
+		args []string
+		err  string
+	}{
+		{"", nil, ""},
+		{"   ", nil, ""},
+		{"health", []string{"health"}, ""},
+		{"  list   services ", []string{"list", "services"}, ""},
+		{"tab\tand\nnewline", []string{"tab", "and", "newline"}, ""},
+		{`register "my service" 8080`, []string{"register", "my service", "8080"}, ""},
+		{`register 'my service' 8080`, []string{"register", "my service", "8080"}, ""},
+		{`echo "it's"`, []string{"echo", "it's"}, ""},
+		{`echo 'say "hi"'`, []string{"echo", `say "hi"`}, ""},
+		{`echo my\ service`, []string{"echo", "my service"}, ""},
+		{`echo \"quoted\"`, []string{"echo", `"quoted"`}, ""},
+		{`echo "a \"b\" c"`, []string{"echo", `a "b" c`}, ""},
+		{`echo "back\\slash"`, []string{"echo", `back\slash`}, ""},
+		{`echo "C:\path"`, []string{"echo", `C:\path`}, ""},
+		{`echo 'no\escape'`, []string{"echo", `no\escape`}, ""},
+		{`echo trailing\`, []string{"echo", `trailing\`}, ""},
+		{`echo ""`, []string{"echo", ""}, ""},
+		{`echo '' x`, []string{"echo", "", "x"}, ""},
+		{`echo pre"fix suf"fix`, []string{"echo", "prefix suffix"}, ""},
+		{`echo "héllo wörld"`, []string{"echo", "héllo wörld"}, ""},
+		{`echo "unbalanced`, nil, "unbalanced double quote at position 6"},
+		{`echo it's`, nil, "unbalanced single quote at position 8"},
+		{`echo "a" 'b`, nil, "unbalanced single quote at position 10"},
+		{`echo "escaped end\"`, nil, "unbalanced double quote at position 6"},
+	}
+
+	for _, d := range testData {
+		args, err := Split(d.text)
+
+		if len(d.err) > 0 {
+			if err == nil || err.Error() != d.err {
+				t.Fatalf("%q: expected error %q got %v", d.text, d.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.text, err)
+		}
+
+		if !reflect.DeepEqual(args, d.args) {
+			t.Fatalf("%q: expected %q got %q", d.text, d.args, args)
+		}
+	}
+}
+
+func TestSplitPipeline(t *testing.T) {
+	testData := []struct {
+		text string
+		cmds [][]string
+		err  string
+	}{
+		{"", nil, ""},
+		{"health", [][]string{{"health"}}, ""},
+		{"list services | grep auth | count", [][]string{{"list", "services"}, {"grep", "auth"}, {"count"}}, ""},
+		{"list services|grep auth|count", [][]string{{"list", "services"}, {"grep", "auth"}, {"count"}}, ""},
+		// quoted and escaped pipes are arguments
+		{`echo 'a|b'`, [][]string{{"echo", "a|b"}}, ""},
+		{`echo "a | b" | count`, [][]string{{"echo", "a | b"}, {"count"}}, ""},
+		{`echo a\|b`, [][]string{{"echo", "a|b"}}, ""},
+		{`| count`, nil, "missing command in pipeline at position 1"},
+		{`list ||count`, nil, "missing command in pipeline at position 7"},
+		{`list | `, nil, "missing command in pipeline at position 6"},
+		{`echo 'a | count`, nil, "unbalanced single quote at position 6"},
+	}
+
+	for _, d := range testData {
+		cmds, err := SplitPipeline(d.text)
+		if len(d.err) > 0 {
+			if err == nil || err.Error() != d.err {
+				t.Fatalf("%q: expected error %q got %v", d.text, d.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.text, err)
+		}
+		if !reflect.DeepEqual(cmds, d.cmds) {
+			t.Fatalf("%q: expected %q got %q", d.text, d.cmds, cmds)
+		}
+	}
+
+	// Split leaves pipes in the arguments
+	if args, err := Split("a | b"); err != nil || !reflect.DeepEqual(args, []string{"a", "|", "b"}) {
+		t.Fatalf("unexpected args %q %v", args, err)
+	}
+}
+
+func TestCut(t *testing.T) {
+	testData := []struct {
+		text string
+		n    int
+		args []string
+		rest string
+	}{
+		{`call greeter Say.Hello {"name": "john"}`, 3, []string{"call", "greeter", "Say.Hello"}, `{"name": "john"}`},
+		{`call  "greeter"   Say.Hello   {"it's": 1}  `, 3, []string{"call", "greeter", "Say.Hello"}, `{"it's": 1}`},
+		{`call greeter Say.Hello`, 3, []string{"call", "greeter", "Say.Hello"}, ""},
+		{`call greeter`, 3, []string{"call", "greeter"}, ""},
+		{``, 3, nil, ""},
+	}
+
+	for _, d := range testData {
+		args, rest, err := Cut(d.text, d.n)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.text, err)
+		}
+		if !reflect.DeepEqual(args, d.args) || rest != d.rest {
+			t.Fatalf("%q: expected %q %q got %q %q", d.text, d.args, d.rest, args, rest)
+		}
+	}
+
+	// only the arguments cut off need to be balanced
+	if _, _, err := Cut(`call "greeter Say.Hello`, 3); err == nil {
+		t.Fatal("expected an unbalanced quote error")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	testData := []struct {
+		text string
+		args []string
+	}{
+		{`it's deployed`, []string{"it's", "deployed"}},
+		{`say "hi" \o/`, []string{"say", `"hi"`, `\o/`}},
+		{`  spaced   out `, []string{"spaced", "out"}},
+		{`a|b | c`, []string{"a|b", "|", "c"}},
+	}
+
+	for _, d := range testData {
+		args, err := Split(Escape(d.text))
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.text, err)
+		}
+		if !reflect.DeepEqual(args, d.args) {
+			t.Fatalf("%q: expected %q got %q", d.text, d.args, args)
+		}
+
+		cmds, err := SplitPipeline(Escape(d.text))
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", d.text, err)
+		}
+		if !reflect.DeepEqual(cmds, [][]string{d.args}) {
+			t.Fatalf("%q: expected one command %q got %q", d.text, d.args, cmds)
+		}
+	}
+}
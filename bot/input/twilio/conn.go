@@ -0,0 +1,145 @@
+package twilio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events
+const (
+	// MetaFrom is the number the message was sent from
+	MetaFrom = "twilio_from"
+	// MetaTo is the twilio number the message was sent to
+	MetaTo = "twilio_to"
+	// MetaMessageSid is the SID of the received message
+	MetaMessageSid = "twilio_message_sid"
+)
+
+// message is an SMS received by the webhook waiting for its reply
+type message struct {
+	sid  string
+	from string
+	to   string
+	body string
+
+	// sends the reply once the webhook has responded
+	client      *client
+	maxSegments int
+
+	sync.Mutex
+	// the reply when answered with TwiML
+	done chan string
+	// set once the webhook has responded without the reply
+	async   bool
+	replied bool
+}
+
+// answer delivers the reply in the webhook response if it's still being
+// waited for, otherwise sends it with the REST api
+func (m *message) answer(data []byte) error {
+	m.Lock()
+	if m.replied {
+		m.Unlock()
+		return fmt.Errorf("message %s already answered", m.sid)
+	}
+	m.replied = true
+	async := m.async
+	m.Unlock()
+
+	text := truncate(string(data), m.maxSegments)
+
+	if !async {
+		m.done <- text
+		return nil
+	}
+
+	if m.client == nil {
+		return errors.New("missing account sid to reply with")
+	}
+
+	return m.client.send(m.to, m.from, text)
+}
+
+// detach switches the message to replying with the REST api, returning
+// false if it's already been answered
+func (m *message) detach() bool {
+	m.Lock()
+	defer m.Unlock()
+	if m.replied {
+		return false
+	}
+	m.async = true
+	return true
+}
+
+// Satisfies the input.Conn interface
+type twilioConn struct {
+	messages <-chan *message
+	// closed when the input stops
+	exit chan bool
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(messages <-chan *message, exit chan bool) *twilioConn {
+	return &twilioConn{
+		messages: messages,
+		exit:     exit,
+		closed:   make(chan bool),
+	}
+}
+
+func (c *twilioConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *twilioConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	select {
+	case <-c.exit:
+		return errors.New("twilio input stopped")
+	case <-c.closed:
+		return errors.New("connection closed")
+	case m := <-c.messages:
+		if event.Meta == nil {
+			event.Meta = make(map[string]interface{})
+		}
+
+		event.From = m.from
+		event.To = m.to
+		event.Type = input.TextEvent
+		event.Data = []byte(m.body)
+		event.Meta["reply"] = m
+		event.Meta[MetaFrom] = m.from
+		event.Meta[MetaTo] = m.to
+		event.Meta[MetaMessageSid] = m.sid
+
+		return nil
+	}
+}
+
+func (c *twilioConn) Send(event *input.Event) error {
+	m, ok := event.Meta["reply"].(*message)
+	if !ok {
+		// only allowed numbers which messaged the bot are replied to
+		return errors.New("twilio input can only reply to messages")
+	}
+
+	if err := m.answer(event.Data); err != nil {
+		log.Logf("[twilio] error replying to message %s: %v", m.sid, err)
+		return err
+	}
+
+	return nil
+}
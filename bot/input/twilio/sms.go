@@ -0,0 +1,198 @@
+package twilio
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the REST api replies are sent with when they miss the webhook
+var apiURL = "https://api.twilio.com/2010-04-01"
+
+// signature returns the X-Twilio-Signature of a request to u with the
+// form params: the base64 HMAC-SHA1 of the url followed by each param
+// name and value sorted by name
+func signature(authToken, u string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(u))
+
+	for _, k := range keys {
+		for _, v := range params[k] {
+			mac.Write([]byte(k + v))
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validSignature checks the request was signed by twilio
+func validSignature(authToken, u string, params url.Values, sig string) bool {
+	if len(sig) == 0 {
+		return false
+	}
+	expect := signature(authToken, u, params)
+	return hmac.Equal([]byte(expect), []byte(sig))
+}
+
+// normalize strips the formatting people put in phone numbers
+func normalize(number string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '+':
+			return r
+		}
+		return -1
+	}, number)
+}
+
+// gsm is the GSM 03.38 basic character set. Text using only these is
+// sent in 7 bit segments, anything else needs UCS-2.
+const gsm = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+
+// characters of the extension table which take two septets
+const gsmExtended = "^{}\\[~]|€"
+
+// encoding returns whether text fits the 7 bit encoding along with the
+// characters per segment of a text spanning several segments
+func encoding(text string) (bool, int) {
+	for _, r := range text {
+		if !strings.ContainsRune(gsm, r) && !strings.ContainsRune(gsmExtended, r) {
+			return false, 67
+		}
+	}
+	return true, 153
+}
+
+// width returns the characters r takes up in a segment
+func width(r rune, gsm7 bool) int {
+	switch {
+	case gsm7 && strings.ContainsRune(gsmExtended, r):
+		// escaped by a septet
+		return 2
+	case !gsm7 && r > 0xFFFF:
+		// a UTF-16 surrogate pair
+		return 2
+	}
+	return 1
+}
+
+// truncate cuts text to fit in maxSegments SMS segments
+func truncate(text string, maxSegments int) string {
+	gsm7, size := encoding(text)
+
+	// a single segment has room for the header the others need
+	single := 160
+	if !gsm7 {
+		single = 70
+	}
+
+	n := 0
+	for _, r := range text {
+		n += width(r, gsm7)
+	}
+
+	if n <= single || n <= size*maxSegments {
+		return text
+	}
+
+	const marker = "..."
+	limit := size*maxSegments - len(marker)
+
+	var b strings.Builder
+	n = 0
+	for _, r := range text {
+		if n+width(r, gsm7) > limit {
+			break
+		}
+		b.WriteRune(r)
+		n += width(r, gsm7)
+	}
+
+	return strings.TrimRight(b.String(), " \n") + marker
+}
+
+// twiml returns the response which replies with the message, or none
+// if it's empty
+func twiml(message string) string {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><Response>`)
+	if len(message) > 0 {
+		b.WriteString("<Message>")
+		xml.EscapeText(&b, []byte(message))
+		b.WriteString("</Message>")
+	}
+	b.WriteString("</Response>")
+	return b.String()
+}
+
+// apiError is returned when the REST api refuses a request
+type apiError struct {
+	StatusCode int    `json:"status"`
+	Code       int    `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("twilio error %d: %d %s", e.StatusCode, e.Code, e.Message)
+}
+
+// client sends messages with the REST api
+type client struct {
+	accountSID string
+	authToken  string
+	http       *http.Client
+}
+
+func newClient(accountSID, authToken string) *client {
+	return &client{
+		accountSID: accountSID,
+		authToken:  authToken,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *client) send(from, to, body string) error {
+	form := url.Values{"From": {from}, "To": {to}, "Body": {body}}
+	u := apiURL + "/Accounts/" + url.PathEscape(c.accountSID) + "/Messages.json"
+
+	req, err := http.NewRequest("POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(c.accountSID, c.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode >= 300 {
+		e := &apiError{StatusCode: rsp.StatusCode}
+		json.NewDecoder(rsp.Body).Decode(e)
+		if len(e.Message) == 0 {
+			e.Message = http.StatusText(rsp.StatusCode)
+		}
+		return e
+	}
+
+	return nil
+}
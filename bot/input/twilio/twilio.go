@@ -0,0 +1,310 @@
+// Package twilio is an SMS input for the bot. Twilio posts incoming
+// messages to a webhook which runs them as commands for an allowlist of
+// numbers, replying with TwiML or, when commands are slow, the REST api.
+package twilio
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/tokenize"
+)
+
+// SignatureHeader is the header twilio signs webhook requests in
+const SignatureHeader = "X-Twilio-Signature"
+
+// the largest body accepted, twilio's are a few kilobytes
+var maxBodySize int64 = 64 << 10
+
+type twilioInput struct {
+	accountSID  string
+	authToken   string
+	address     string
+	webhookURL  string
+	allowed     map[string]bool
+	maxSegments int
+	replyWait   time.Duration
+
+	sync.Mutex
+	running  bool
+	exit     chan bool
+	messages chan *message
+	server   *http.Server
+	// where the webhook is listening, useful when the port is 0
+	addr net.Addr
+}
+
+func init() {
+	input.Register("twilio", NewInput())
+}
+
+func (p *twilioInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "twilio_account_sid",
+			Usage: "Twilio account SID, replies are sent by its REST api",
+		},
+		cli.StringFlag{
+			Name:  "twilio_auth_token",
+			Usage: "Twilio auth token used to validate webhook signatures",
+		},
+		cli.StringFlag{
+			Name:  "twilio_address",
+			Usage: "Address the webhook listens on",
+			Value: ":8091",
+		},
+		cli.StringFlag{
+			Name:  "twilio_webhook_url",
+			Usage: "Public url of the webhook as configured in twilio, needed for signatures when behind a proxy",
+		},
+		cli.StringSliceFlag{
+			Name:  "twilio_allowed_numbers",
+			Usage: "Phone numbers allowed to run commands, messages from any other are ignored",
+		},
+		cli.IntFlag{
+			Name:  "twilio_max_segments",
+			Usage: "SMS segments replies are truncated to",
+			Value: 4,
+		},
+		cli.DurationFlag{
+			Name:  "twilio_reply_wait",
+			Usage: "How long to wait for output before replying with the REST api instead",
+			Value: 10 * time.Second,
+		},
+	}
+}
+
+func (p *twilioInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	sid := cfg.String("twilio_account_sid", "")
+	if len(sid) == 0 {
+		return errors.New("missing twilio account sid")
+	}
+
+	token := cfg.String("twilio_auth_token", "")
+	if len(token) == 0 {
+		return errors.New("missing twilio auth token")
+	}
+
+	allowed := make(map[string]bool)
+	for _, numbers := range cfg.StringSlice("twilio_allowed_numbers", nil) {
+		for _, n := range strings.Split(numbers, ",") {
+			if n = normalize(n); len(n) > 0 {
+				allowed[n] = true
+			}
+		}
+	}
+
+	if len(allowed) == 0 {
+		return errors.New("missing twilio allowed numbers")
+	}
+
+	segments := cfg.Int("twilio_max_segments", 4)
+	if segments < 1 {
+		return errors.New("twilio max segments must be at least 1")
+	}
+
+	p.accountSID = sid
+	p.authToken = token
+	p.address = cfg.String("twilio_address", ":8091")
+	p.webhookURL = cfg.String("twilio_webhook_url", "")
+	p.allowed = allowed
+	p.maxSegments = segments
+	p.replyWait = cfg.Duration("twilio_reply_wait", 10*time.Second)
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// requestURL returns the url twilio signed the request for
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) > 0 {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
+
+func writeTwiML(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write([]byte(twiml(message)))
+}
+
+// handler receives messages from twilio, answering with TwiML unless the
+// command runs longer than p.replyWait
+func (p *twilioInput) handler(messages chan *message, exit chan bool) http.Handler {
+	var c *client
+	if len(p.accountSID) > 0 {
+		c = newClient(p.accountSID, p.authToken)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid form body", http.StatusBadRequest)
+			return
+		}
+
+		u := p.webhookURL
+		if len(u) == 0 {
+			u = requestURL(r)
+		}
+
+		if !validSignature(p.authToken, u, r.PostForm, r.Header.Get(SignatureHeader)) {
+			log.Logf("[twilio] rejected request with an invalid signature for %s", u)
+			http.Error(w, "invalid signature", http.StatusForbidden)
+			return
+		}
+
+		m := &message{
+			sid:         r.PostForm.Get("MessageSid"),
+			from:        r.PostForm.Get("From"),
+			to:          r.PostForm.Get("To"),
+			body:        strings.TrimSpace(r.PostForm.Get("Body")),
+			client:      c,
+			maxSegments: p.maxSegments,
+			done:        make(chan string, 1),
+		}
+
+		// unknown numbers get no reply at all, not even an error which
+		// would tell them the number is a bot
+		if !p.allowed[normalize(m.from)] {
+			log.Logf("[twilio] ignoring message %s from unknown number %s", m.sid, m.from)
+			writeTwiML(w, "")
+			return
+		}
+
+		// the bot would never answer text which can't be split into a
+		// command
+		if args, err := tokenize.Split(m.body); err != nil || len(args) == 0 {
+			msg := "expected a command"
+			if err != nil {
+				msg = "invalid command: " + err.Error()
+			}
+			writeTwiML(w, msg)
+			return
+		}
+
+		select {
+		case <-exit:
+			http.Error(w, "stopped", http.StatusServiceUnavailable)
+			return
+		case <-r.Context().Done():
+			return
+		case messages <- m:
+		}
+
+		select {
+		case text := <-m.done:
+			writeTwiML(w, text)
+		case <-time.After(p.replyWait):
+			if !m.detach() {
+				// answered just now
+				writeTwiML(w, <-m.done)
+				return
+			}
+			writeTwiML(w, "")
+		case <-exit:
+			http.Error(w, "stopped", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+func (p *twilioInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.messages, p.exit), nil
+}
+
+func (p *twilioInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.authToken) == 0 {
+		return errors.New("missing twilio auth token")
+	}
+
+	l, err := net.Listen("tcp", p.address)
+	if err != nil {
+		return err
+	}
+
+	exit := make(chan bool)
+	messages := make(chan *message)
+
+	p.server = &http.Server{Handler: p.handler(messages, exit)}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+			log.Logf("[twilio] webhook server error: %v", err)
+		}
+	}(p.server)
+
+	log.Logf("[twilio] listening for messages on %s", l.Addr())
+
+	p.exit = exit
+	p.messages = messages
+	p.addr = l.Addr()
+	p.running = true
+
+	return nil
+}
+
+func (p *twilioInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	if p.server != nil {
+		p.server.Close()
+		p.server = nil
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *twilioInput) String() string {
+	return "twilio"
+}
+
+func NewInput() input.Input {
+	return &twilioInput{
+		maxSegments: 4,
+		replyWait:   10 * time.Second,
+	}
+}
@@ -0,0 +1,280 @@
+package twilio
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// fixture is a webhook request recorded in testdata
+type fixture struct {
+	URL       string            `json:"url"`
+	AuthToken string            `json:"auth_token"`
+	Params    map[string]string `json:"params"`
+	Signature string            `json:"signature"`
+	Valid     bool              `json:"valid"`
+}
+
+func (f fixture) form() url.Values {
+	v := url.Values{}
+	for k, p := range f.Params {
+		v.Set(k, p)
+	}
+	return v
+}
+
+func loadFixtures(t *testing.T) map[string]fixture {
+	files, err := filepath.Glob("testdata/*.json")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("missing fixtures: %v", err)
+	}
+
+	fixtures := make(map[string]fixture)
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var f fixture
+		if err := json.Unmarshal(b, &f); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		fixtures[file] = f
+	}
+	return fixtures
+}
+
+func startInput(t *testing.T, token, webhookURL string, allowed ...string) *twilioInput {
+	p := NewInput().(*twilioInput)
+	p.accountSID = "AC123"
+	p.authToken = token
+	p.address = "127.0.0.1:0"
+	p.webhookURL = webhookURL
+	p.replyWait = 50 * time.Millisecond
+	p.allowed = make(map[string]bool)
+	for _, n := range allowed {
+		p.allowed[normalize(n)] = true
+	}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func post(t *testing.T, p *twilioInput, sig string, form url.Values) (int, string) {
+	req, _ := http.NewRequest("POST", "http://"+p.addr.String()+"/sms", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(SignatureHeader, sig)
+
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	b, _ := ioutil.ReadAll(rsp.Body)
+	return rsp.StatusCode, string(b)
+}
+
+// signed posts a message signed as twilio would
+func signed(t *testing.T, p *twilioInput, from, body string) (int, string) {
+	form := url.Values{
+		"MessageSid": {"SM123"},
+		"AccountSid": {"AC123"},
+		"From":       {from},
+		"To":         {"+14155550100"},
+		"Body":       {body},
+	}
+	return post(t, p, signature(p.authToken, p.webhookURL, form), form)
+}
+
+// answer replies to each command with its text after the delay
+func answer(c input.Conn, delay time.Duration) {
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+
+		go func(ev input.Event) {
+			time.Sleep(delay)
+			c.Send(&input.Event{To: ev.From, Meta: ev.Meta, Type: input.TextEvent, Data: []byte(ev.From + " ran " + string(ev.Data))})
+		}(ev)
+	}
+}
+
+func TestSignature(t *testing.T) {
+	for file, f := range loadFixtures(t) {
+		if got := validSignature(f.AuthToken, f.URL, f.form(), f.Signature); got != f.Valid {
+			t.Fatalf("%s: expected valid %v got %v", file, f.Valid, got)
+		}
+
+		// the same request replayed against the webhook
+		p := startInput(t, f.AuthToken, f.URL)
+		status, _ := post(t, p, f.Signature, f.form())
+		p.Stop()
+
+		expect := http.StatusOK
+		if !f.Valid {
+			expect = http.StatusForbidden
+		}
+
+		if status != expect {
+			t.Fatalf("%s: expected status %d got %d", file, expect, status)
+		}
+	}
+
+	if validSignature("12345", "https://example.com", url.Values{}, "") {
+		t.Fatal("expected a missing signature to be invalid")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	testData := []struct {
+		text     string
+		segments int
+		expect   int
+	}{
+		// a single segment
+		{strings.Repeat("a", 160), 1, 160},
+		{strings.Repeat("a", 161), 1, 153},
+		{strings.Repeat("a", 306), 2, 306},
+		{strings.Repeat("a", 400), 2, 306},
+		// extended characters take two septets
+		{strings.Repeat("{", 100), 1, 153},
+		{strings.Repeat("{", 80), 1, 160},
+		// anything outside GSM is sent as UCS-2
+		{strings.Repeat("ü", 160), 1, 160},
+		{strings.Repeat("→", 70), 1, 70},
+		{strings.Repeat("→", 71), 1, 67},
+		{strings.Repeat("→", 200), 2, 134},
+	}
+
+	for _, d := range testData {
+		got := truncate(d.text, d.segments)
+		gsm7, _ := encoding(d.text)
+
+		n := 0
+		for _, r := range got {
+			n += width(r, gsm7)
+		}
+
+		if n != d.expect {
+			t.Fatalf("%.10q... in %d segments: expected %d characters got %d", d.text, d.segments, d.expect, n)
+		}
+
+		if len(got) < len(d.text) && !strings.HasSuffix(got, "...") {
+			t.Fatalf("expected truncated text to end with ... got %q", got)
+		}
+	}
+}
+
+func TestTwiML(t *testing.T) {
+	if got := twiml(""); got != `<?xml version="1.0" encoding="UTF-8"?><Response></Response>` {
+		t.Fatalf("unexpected empty response %s", got)
+	}
+
+	if got := twiml("a < b & c"); !strings.Contains(got, "<Message>a &lt; b &amp; c</Message>") {
+		t.Fatalf("expected the message to be escaped got %s", got)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	p := startInput(t, "secret", "https://bot.example.com/sms", "+1 (415) 555-0123")
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c, 0)
+
+	testData := []struct {
+		from   string
+		body   string
+		expect string
+	}{
+		{"+14155550123", "deploy api", "<Message>+14155550123 ran deploy api</Message>"},
+		{"+14155550123", "  ", "<Message>expected a command</Message>"},
+		{"+14155550123", `echo "hi`, "<Message>invalid command: unbalanced double quote"},
+		// nothing at all for numbers not allowed
+		{"+14155550999", "deploy api", "<Response></Response>"},
+	}
+
+	for _, d := range testData {
+		status, rsp := signed(t, p, d.from, d.body)
+		if status != http.StatusOK || !strings.Contains(rsp, d.expect) {
+			t.Fatalf("%s %q: expected %q got %d %s", d.from, d.body, d.expect, status, rsp)
+		}
+	}
+
+	rsp, err := http.Get("http://" + p.addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected GET to be rejected got %d", rsp.StatusCode)
+	}
+}
+
+func TestRESTReply(t *testing.T) {
+	sent := make(chan url.Values, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "AC123" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/Accounts/AC123/Messages.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.ParseForm()
+		sent <- r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	oldURL := apiURL
+	apiURL = srv.URL
+	defer func() { apiURL = oldURL }()
+
+	p := startInput(t, "secret", "https://bot.example.com/sms", "+14155550123")
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c, 200*time.Millisecond)
+
+	status, rsp := signed(t, p, "+14155550123", "slow")
+	if status != http.StatusOK || strings.Contains(rsp, "<Message>") {
+		t.Fatalf("expected an empty response got %d %s", status, rsp)
+	}
+
+	select {
+	case form := <-sent:
+		if form.Get("From") != "+14155550100" || form.Get("To") != "+14155550123" || form.Get("Body") != "+14155550123 ran slow" {
+			t.Fatalf("unexpected message %v", form)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reply")
+	}
+}
@@ -0,0 +1,352 @@
+package xmpp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long to wait before reconnecting, doubled on each failure
+	reconnectBackoff = time.Second
+	// how often rooms the bot isn't in are rejoined
+	rejoinInterval = time.Minute
+	// how often the server is pinged, the connection fails if nothing
+	// is read for twice as long
+	pingInterval = time.Minute
+)
+
+// config is how the client connects
+type config struct {
+	// the server host:port
+	server   string
+	jid      jid
+	password string
+	nick     string
+	rooms    []string
+	// allows authenticating without TLS
+	insecure bool
+	// verifies the server, nil for the system roots
+	tls *tls.Config
+}
+
+func (c config) tlsConfig() *tls.Config {
+	if c.tls != nil {
+		return c.tls
+	}
+	return &tls.Config{ServerName: c.jid.domain}
+}
+
+// client maintains the connection to the server, reconnecting with
+// backoff and keeping the bot in its rooms
+type client struct {
+	config
+
+	events chan *stanza
+	exit   chan bool
+	done   chan bool
+
+	sync.Mutex
+	stream *stream
+	// the jid the server bound
+	bound string
+	// the nick the bot has in each room, the configured one may be
+	// taken
+	nicks  map[string]string
+	joined map[string]bool
+	pings  int
+}
+
+func newClient(c config, exit chan bool) *client {
+	return &client{
+		config: c,
+		events: make(chan *stanza),
+		exit:   exit,
+		done:   make(chan bool),
+		nicks:  make(map[string]string),
+		joined: make(map[string]bool),
+	}
+}
+
+func (c *client) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	return d.Dial("tcp", c.server)
+}
+
+// isRoom returns true if the jid is one of the configured rooms
+func (c *client) isRoom(j string) bool {
+	j = strings.ToLower(bareJID(j))
+	for _, r := range c.rooms {
+		if strings.ToLower(r) == j {
+			return true
+		}
+	}
+	return false
+}
+
+// nickIn returns the nick the bot has in the room
+func (c *client) nickIn(room string) string {
+	c.Lock()
+	defer c.Unlock()
+	if n, ok := c.nicks[strings.ToLower(room)]; ok {
+		return n
+	}
+	return c.nick
+}
+
+// write sends a stanza immediately
+func (c *client) write(format string, args ...interface{}) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.stream == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	c.stream.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := fmt.Fprintf(c.stream.conn, format, args...)
+	return err
+}
+
+// run connects and reconnects with backoff until exit is closed. conn
+// is the first connection, dialed to check the server is reachable.
+func (c *client) run(conn net.Conn) {
+	defer close(c.done)
+
+	backoff := reconnectBackoff
+
+	for {
+		start := time.Now()
+
+		err := c.session(conn)
+		conn = nil
+
+		select {
+		case <-c.exit:
+			return
+		default:
+		}
+
+		// reset backoff if the connection was healthy for a while
+		if time.Since(start) > time.Minute {
+			backoff = reconnectBackoff
+		}
+
+		log.Logf("[xmpp] connection to %s lost: %v, reconnecting in %v", c.server, err, backoff)
+
+		select {
+		case <-c.exit:
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < 5*time.Minute {
+			backoff *= 2
+		}
+
+		conn, err = c.dial()
+		if err != nil {
+			log.Logf("[xmpp] error connecting to %s: %v", c.server, err)
+			continue
+		}
+	}
+}
+
+// session negotiates the stream and handles it until it fails
+func (c *client) session(conn net.Conn) error {
+	if conn == nil {
+		return fmt.Errorf("not connected to %s", c.server)
+	}
+
+	closed := make(chan bool)
+	var wg sync.WaitGroup
+
+	defer func() {
+		close(closed)
+		conn.Close()
+		wg.Wait()
+		c.Lock()
+		if c.stream != nil {
+			c.stream.conn.Close()
+		}
+		c.stream = nil
+		c.Unlock()
+	}()
+
+	// unblock negotiation and reads once exiting
+	go func() {
+		select {
+		case <-c.exit:
+			c.write("<presence type='unavailable'/></stream:stream>")
+			conn.Close()
+		case <-closed:
+		}
+	}()
+
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	s, bound, err := negotiate(conn, c.config)
+	if err != nil {
+		return err
+	}
+
+	s.conn.SetDeadline(time.Time{})
+
+	log.Logf("[xmpp] connected to %s as %s", c.server, bound)
+
+	c.Lock()
+	c.stream = s
+	c.bound = bound
+	c.nicks = make(map[string]string)
+	c.joined = make(map[string]bool)
+	c.Unlock()
+
+	if err := c.write("<presence/>"); err != nil {
+		return err
+	}
+
+	c.join()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.ping(closed)
+	}()
+	go func() {
+		defer wg.Done()
+		c.rejoin(closed)
+	}()
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+
+		st, err := s.read()
+		if err != nil {
+			return err
+		}
+
+		if err := c.handle(st); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) handle(st *stanza) error {
+	switch st.XMLName.Local {
+	case "iq":
+		switch {
+		case st.Type == "get" && st.Ping != nil:
+			return c.write("<iq type='result' id='%s' to='%s'/>", escape(st.ID), escape(st.From))
+		case st.Type == "get" || st.Type == "set":
+			return c.write("<iq type='error' id='%s' to='%s'><error type='cancel'><service-unavailable xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></iq>", escape(st.ID), escape(st.From))
+		}
+	case "presence":
+		c.presence(st)
+	case "message":
+		select {
+		case <-c.exit:
+		case c.events <- st:
+		}
+	}
+
+	return nil
+}
+
+// presence tracks the rooms the bot is in
+func (c *client) presence(st *stanza) {
+	room := bareJID(st.From)
+	if !c.isRoom(room) {
+		return
+	}
+
+	key := strings.ToLower(room)
+	nick := c.nickIn(room)
+
+	switch {
+	case st.Type == "error" && st.errorCondition() == "conflict":
+		// nick in use, take the next one
+		c.Lock()
+		c.nicks[key] = nick + "_"
+		c.Unlock()
+		c.joinRoom(room)
+	case st.Type == "error":
+		log.Logf("[xmpp] error joining %s: %s", room, st.errorCondition())
+	case resourceOf(st.From) != nick && !st.hasStatus("110"):
+		// someone else
+	case st.Type == "unavailable":
+		if st.hasStatus("307") {
+			log.Logf("[xmpp] kicked from %s", room)
+		}
+		c.Lock()
+		delete(c.joined, key)
+		c.Unlock()
+	default:
+		c.Lock()
+		c.joined[key] = true
+		// the server may have changed the nick
+		c.nicks[key] = resourceOf(st.From)
+		c.Unlock()
+	}
+}
+
+// joinRoom joins the room without its history, old commands mustn't
+// run again
+func (c *client) joinRoom(room string) error {
+	return c.write("<presence to='%s/%s'><x xmlns='%s'><history maxstanzas='0'/></x></presence>", escape(room), escape(c.nickIn(room)), nsMUC)
+}
+
+// join joins the configured rooms the bot isn't in
+func (c *client) join() {
+	for _, r := range c.rooms {
+		c.Lock()
+		joined := c.joined[strings.ToLower(r)]
+		c.Unlock()
+
+		if !joined {
+			c.joinRoom(r)
+		}
+	}
+}
+
+// rejoin periodically joins rooms the bot was kicked from
+func (c *client) rejoin(closed chan bool) {
+	t := time.NewTicker(rejoinInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-t.C:
+			c.join()
+		}
+	}
+}
+
+// ping keeps the connection alive, the server answering resets the
+// read deadline
+func (c *client) ping(closed chan bool) {
+	t := time.NewTicker(pingInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-t.C:
+			c.Lock()
+			c.pings++
+			id := c.pings
+			c.Unlock()
+
+			if err := c.write("<iq type='get' id='ping%d' to='%s'><ping xmlns='%s'/></iq>", id, escape(c.jid.domain), nsPing); err != nil {
+				return
+			}
+		}
+	}
+}
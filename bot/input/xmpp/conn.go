@@ -0,0 +1,163 @@
+package xmpp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// Meta keys set on received events
+const (
+	// MetaRoom is the room the message was sent in, empty for direct
+	// chats
+	MetaRoom = "xmpp_room"
+	// MetaJID is the jid of the sender, their occupant jid in rooms
+	MetaJID = "xmpp_jid"
+	// MetaNick is the nick of the sender in the room
+	MetaNick = "xmpp_nick"
+)
+
+// Satisfies the input.Conn interface
+type xmppConn struct {
+	client *client
+
+	once   sync.Once
+	closed chan bool
+}
+
+func newConn(c *client) *xmppConn {
+	return &xmppConn{
+		client: c,
+		closed: make(chan bool),
+	}
+}
+
+// addressed returns the command in the message if it's for the bot.
+// Room messages must start with "nick:" or "nick,", direct chats are
+// always for the bot.
+func (c *xmppConn) addressed(st *stanza) (string, bool) {
+	text := strings.TrimSpace(st.Body)
+	if len(text) == 0 || st.Type == "error" {
+		return "", false
+	}
+
+	// room history and offline messages, they were answered or are
+	// too old to act on
+	if st.delayed() {
+		return "", false
+	}
+
+	if st.Type != "groupchat" {
+		return text, true
+	}
+
+	room := bareJID(st.From)
+	if !c.client.isRoom(room) {
+		return "", false
+	}
+
+	nick := c.client.nickIn(room)
+
+	// our own messages are echoed back
+	if resourceOf(st.From) == nick {
+		return "", false
+	}
+
+	if len(text) > len(nick) && strings.EqualFold(text[:len(nick)], nick) && strings.ContainsAny(text[len(nick):len(nick)+1], ",:") {
+		return strings.TrimLeft(text[len(nick):], " ,:"), true
+	}
+
+	return "", false
+}
+
+func (c *xmppConn) Close() error {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *xmppConn) Recv(event *input.Event) error {
+	if event == nil {
+		return errors.New("event cannot be nil")
+	}
+
+	for {
+		select {
+		case <-c.client.exit:
+			return errors.New("xmpp input stopped")
+		case <-c.closed:
+			return errors.New("connection closed")
+		case st := <-c.client.events:
+			text, ok := c.addressed(st)
+			if !ok || len(text) == 0 {
+				continue
+			}
+
+			if event.Meta == nil {
+				event.Meta = make(map[string]interface{})
+			}
+
+			event.Meta[MetaRoom] = ""
+			event.Meta[MetaNick] = ""
+
+			if st.Type == "groupchat" {
+				event.Meta[MetaRoom] = bareJID(st.From)
+				event.Meta[MetaNick] = resourceOf(st.From)
+			}
+
+			// occupant jids answer in their room, others directly
+			event.From = st.From
+			event.To = st.To
+			event.Type = input.TextEvent
+			event.Data = []byte(text)
+			event.Meta["reply"] = st
+			event.Meta[MetaJID] = st.From
+
+			return nil
+		}
+	}
+}
+
+// Notify shows the bot composing a reply in direct chats
+func (c *xmppConn) Notify(event input.Event) func(error) {
+	st, ok := event.Meta["reply"].(*stanza)
+	if !ok || st.Type == "groupchat" {
+		return func(error) {}
+	}
+
+	c.client.write("<message to='%s' type='chat'><composing xmlns='%s'/></message>", escape(st.From), nsChatStates)
+
+	return func(error) {}
+}
+
+// Send replies to the room or the jid. To is the occupant jid
+// room@server/nick to answer in a room, any other jid for a direct chat.
+func (c *xmppConn) Send(event *input.Event) error {
+	st, _ := event.Meta["reply"].(*stanza)
+
+	to := event.To
+	if len(to) == 0 && st != nil {
+		to = st.From
+	}
+
+	if len(to) == 0 {
+		return errors.New("require Event.To")
+	}
+
+	text := strings.TrimRight(string(event.Data), "\n")
+
+	// a private message from a room occupant isn't answered in the room
+	private := st != nil && st.Type != "groupchat"
+
+	if !private && c.client.isRoom(to) {
+		if nick := resourceOf(to); len(nick) > 0 {
+			text = nick + ": " + text
+		}
+		return c.client.write("<message to='%s' type='groupchat'><body>%s</body></message>", escape(bareJID(to)), escape(text))
+	}
+
+	return c.client.write("<message to='%s' type='chat'><body>%s</body><active xmlns='%s'/></message>", escape(to), escape(text), nsChatStates)
+}
@@ -0,0 +1,66 @@
+package xmpp
+
+import (
+	"errors"
+	"strings"
+)
+
+// jid is an XMPP address local@domain/resource
+type jid struct {
+	local    string
+	domain   string
+	resource string
+}
+
+func parseJID(s string) (jid, error) {
+	var j jid
+
+	if i := strings.Index(s, "/"); i >= 0 {
+		j.resource = s[i+1:]
+		s = s[:i]
+	}
+
+	if i := strings.Index(s, "@"); i >= 0 {
+		j.local = s[:i]
+		s = s[i+1:]
+	}
+
+	j.domain = s
+
+	if len(j.domain) == 0 || strings.ContainsAny(j.local, "\"&'/:<>@ ") {
+		return jid{}, errors.New("invalid jid " + s)
+	}
+
+	return j, nil
+}
+
+// bare returns the address without the resource
+func (j jid) bare() string {
+	if len(j.local) == 0 {
+		return j.domain
+	}
+	return j.local + "@" + j.domain
+}
+
+func (j jid) String() string {
+	if len(j.resource) == 0 {
+		return j.bare()
+	}
+	return j.bare() + "/" + j.resource
+}
+
+// bareJID returns s without the resource
+func bareJID(s string) string {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// resourceOf returns the resource of s, for room occupants their nick
+func resourceOf(s string) string {
+	if i := strings.Index(s, "/"); i >= 0 {
+		return s[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,313 @@
+package xmpp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// XML namespaces of the stream and its negotiation
+const (
+	nsClient  = "jabber:client"
+	nsStream  = "http://etherx.jabber.org/streams"
+	nsTLS     = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSASL    = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind    = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsSession = "urn:ietf:params:xml:ns:xmpp-session"
+	nsMUC     = "http://jabber.org/protocol/muc"
+	nsMUCUser = "http://jabber.org/protocol/muc#user"
+	nsPing    = "urn:xmpp:ping"
+	nsDelay   = "urn:xmpp:delay"
+	// the delayed delivery namespace older servers use
+	nsLegacyDelay = "jabber:x:delay"
+	nsChatStates  = "http://jabber.org/protocol/chatstates"
+)
+
+// features advertised by the server after opening a stream
+type features struct {
+	StartTLS   *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-tls starttls"`
+	Mechanisms []string  `xml:"urn:ietf:params:xml:ns:xmpp-sasl mechanisms>mechanism"`
+	Bind       *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-bind bind"`
+	Session    *struct{} `xml:"urn:ietf:params:xml:ns:xmpp-session session"`
+}
+
+// delay marks a stanza which was stored and delivered late, such as
+// room history
+type delay struct {
+	Stamp string `xml:"stamp,attr"`
+}
+
+// stanza is a message, presence or iq received from the server
+type stanza struct {
+	XMLName xml.Name
+	From    string `xml:"from,attr"`
+	To      string `xml:"to,attr"`
+	Type    string `xml:"type,attr"`
+	ID      string `xml:"id,attr"`
+
+	Body        string `xml:"body"`
+	Subject     string `xml:"subject"`
+	Delay       *delay `xml:"urn:xmpp:delay delay"`
+	LegacyDelay *delay `xml:"jabber:x:delay x"`
+
+	// presence in rooms
+	MUC *struct {
+		Status []struct {
+			Code string `xml:"code,attr"`
+		} `xml:"status"`
+	} `xml:"http://jabber.org/protocol/muc#user x"`
+
+	Ping *struct{} `xml:"urn:xmpp:ping ping"`
+
+	Error *struct {
+		Type      string     `xml:"type,attr"`
+		Condition []xml.Name `xml:",any"`
+	} `xml:"error"`
+}
+
+// delayed returns true if the stanza was stored and delivered late
+func (s *stanza) delayed() bool {
+	return s.Delay != nil || s.LegacyDelay != nil
+}
+
+// hasStatus returns true if the room presence has the status code
+func (s *stanza) hasStatus(code string) bool {
+	if s.MUC == nil {
+		return false
+	}
+	for _, st := range s.MUC.Status {
+		if st.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCondition returns the defined condition of an error stanza
+func (s *stanza) errorCondition() string {
+	if s.Error == nil {
+		return ""
+	}
+	for _, c := range s.Error.Condition {
+		if c.Local != "text" {
+			return c.Local
+		}
+	}
+	return s.Error.Type
+}
+
+// escape returns s escaped for use in XML text and attributes
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// stream is an XML stream to the server
+type stream struct {
+	conn net.Conn
+	dec  *xml.Decoder
+}
+
+// open starts a new stream on the connection, returning the features
+// the server offers
+func (s *stream) open(domain string) (*features, error) {
+	s.dec = xml.NewDecoder(s.conn)
+
+	_, err := fmt.Fprintf(s.conn, "<?xml version='1.0'?><stream:stream to='%s' xmlns='%s' xmlns:stream='%s' version='1.0'>", escape(domain), nsClient, nsStream)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		se, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case se.Name.Space == nsStream && se.Name.Local == "stream":
+			// the stream header, the features follow
+		case se.Name.Space == nsStream && se.Name.Local == "features":
+			var f features
+			if err := s.dec.DecodeElement(&f, se); err != nil {
+				return nil, err
+			}
+			return &f, nil
+		default:
+			return nil, fmt.Errorf("unexpected %s opening stream", se.Name.Local)
+		}
+	}
+}
+
+// next returns the next element started, skipping anything else
+func (s *stream) next() (*xml.StartElement, error) {
+	for {
+		t, err := s.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.EndElement:
+			if t.Name.Space == nsStream && t.Name.Local == "stream" {
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+// expect returns an error unless the next element is want in ns
+func (s *stream) expect(ns, want string) error {
+	se, err := s.next()
+	if err != nil {
+		return err
+	}
+
+	if err := s.dec.Skip(); err != nil {
+		return err
+	}
+
+	if se.Name.Space != ns || se.Name.Local != want {
+		return fmt.Errorf("expected %s got %s", want, se.Name.Local)
+	}
+
+	return nil
+}
+
+// negotiate secures the stream, authenticates and binds a resource,
+// returning the jid the server bound
+func negotiate(conn net.Conn, c config) (*stream, string, error) {
+	s := &stream{conn: conn}
+
+	f, err := s.open(c.jid.domain)
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, secure := conn.(*tls.Conn)
+
+	if f.StartTLS != nil && !secure {
+		if _, err := fmt.Fprintf(conn, "<starttls xmlns='%s'/>", nsTLS); err != nil {
+			return nil, "", err
+		}
+
+		if err := s.expect(nsTLS, "proceed"); err != nil {
+			return nil, "", err
+		}
+
+		tc := tls.Client(conn, c.tlsConfig())
+		if err := tc.Handshake(); err != nil {
+			return nil, "", err
+		}
+
+		s.conn = tc
+		secure = true
+
+		if f, err = s.open(c.jid.domain); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// the password is sent as is
+	if !secure && !c.insecure {
+		return nil, "", errors.New("server doesn't support STARTTLS")
+	}
+
+	var plain bool
+	for _, m := range f.Mechanisms {
+		if m == "PLAIN" {
+			plain = true
+		}
+	}
+
+	if !plain {
+		return nil, "", fmt.Errorf("server doesn't support PLAIN authentication, offered %v", f.Mechanisms)
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00" + c.jid.local + "\x00" + c.password))
+	if _, err := fmt.Fprintf(s.conn, "<auth xmlns='%s' mechanism='PLAIN'>%s</auth>", nsSASL, auth); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.expect(nsSASL, "success"); err != nil {
+		return nil, "", fmt.Errorf("authentication failed: %v", err)
+	}
+
+	if f, err = s.open(c.jid.domain); err != nil {
+		return nil, "", err
+	}
+
+	if f.Bind == nil {
+		return nil, "", errors.New("server doesn't support resource binding")
+	}
+
+	if _, err := fmt.Fprintf(s.conn, "<iq type='set' id='bind'><bind xmlns='%s'><resource>%s</resource></bind></iq>", nsBind, escape(c.jid.resource)); err != nil {
+		return nil, "", err
+	}
+
+	se, err := s.next()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var bound struct {
+		Type string `xml:"type,attr"`
+		JID  string `xml:"urn:ietf:params:xml:ns:xmpp-bind bind>jid"`
+	}
+
+	if err := s.dec.DecodeElement(&bound, se); err != nil {
+		return nil, "", err
+	}
+
+	if bound.Type != "result" || len(bound.JID) == 0 {
+		return nil, "", errors.New("resource binding failed")
+	}
+
+	// only required by old servers
+	if f.Session != nil {
+		if _, err := fmt.Fprintf(s.conn, "<iq type='set' id='session'><session xmlns='%s'/></iq>", nsSession); err != nil {
+			return nil, "", err
+		}
+		if err := s.expect(nsClient, "iq"); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return s, bound.JID, nil
+}
+
+// read returns the next stanza
+func (s *stream) read() (*stanza, error) {
+	for {
+		se, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if se.Name.Space == nsStream && se.Name.Local == "error" {
+			var e struct {
+				Condition []xml.Name `xml:",any"`
+			}
+			s.dec.DecodeElement(&e, se)
+			if len(e.Condition) > 0 {
+				return nil, fmt.Errorf("stream error: %s", e.Condition[0].Local)
+			}
+			return nil, errors.New("stream error")
+		}
+
+		var st stanza
+		if err := s.dec.DecodeElement(&st, se); err != nil {
+			return nil, err
+		}
+
+		return &st, nil
+	}
+}
@@ -0,0 +1,192 @@
+// Package xmpp is an XMPP input for the bot. It answers direct chats
+// and messages addressed to its nick in the rooms it joins.
+package xmpp
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/input"
+)
+
+// how long Stop waits for the client to disconnect
+var stopTimeout = 5 * time.Second
+
+type xmppInput struct {
+	config
+
+	sync.Mutex
+	running bool
+	exit    chan bool
+	client  *client
+}
+
+func init() {
+	input.Register("xmpp", NewInput())
+}
+
+func (p *xmppInput) Flags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "xmpp_server",
+			Usage: "XMPP server host:port, the domain of the jid on port 5222 if empty",
+		},
+		cli.StringFlag{
+			Name:  "xmpp_jid",
+			Usage: "Jid of the bot e.g micro@example.com",
+		},
+		cli.StringFlag{
+			Name:  "xmpp_password",
+			Usage: "Password of the bot",
+		},
+		cli.StringFlag{
+			Name:  "xmpp_rooms",
+			Usage: "Comma separated list of rooms to join e.g ops@conference.example.com",
+		},
+		cli.StringFlag{
+			Name:  "xmpp_nick",
+			Usage: "Nick of the bot in rooms",
+			Value: "micro",
+		},
+		cli.BoolFlag{
+			Name:  "xmpp_insecure",
+			Usage: "Allow authenticating with servers which don't support STARTTLS",
+		},
+	}
+}
+
+// parseRooms splits the room list, dropping any nick given
+func parseRooms(s string) []string {
+	var rooms []string
+
+	for _, r := range strings.Split(s, ",") {
+		r = bareJID(strings.TrimSpace(r))
+		if len(r) == 0 {
+			continue
+		}
+		rooms = append(rooms, r)
+	}
+
+	return rooms
+}
+
+func (p *xmppInput) Init(ctx *cli.Context) error {
+	cfg := input.NewConfig(ctx)
+
+	j, err := parseJID(cfg.String("xmpp_jid", ""))
+	if err != nil || len(j.local) == 0 {
+		return errors.New("invalid xmpp jid, expected user@domain")
+	}
+
+	if len(j.resource) == 0 {
+		j.resource = "micro-bot"
+	}
+
+	password := cfg.String("xmpp_password", "")
+	if len(password) == 0 {
+		return errors.New("missing xmpp password")
+	}
+
+	server := cfg.String("xmpp_server", "")
+	if len(server) == 0 {
+		server = net.JoinHostPort(j.domain, "5222")
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return errors.New("invalid xmpp server, expected host:port")
+	}
+
+	nick := cfg.String("xmpp_nick", "micro")
+	if len(nick) == 0 {
+		return errors.New("missing xmpp nick")
+	}
+
+	p.server = server
+	p.jid = j
+	p.password = password
+	p.nick = nick
+	p.rooms = parseRooms(cfg.String("xmpp_rooms", ""))
+	p.insecure = cfg.Bool("xmpp_insecure", false)
+
+	if err := cfg.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *xmppInput) Stream() (input.Conn, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil, errors.New("not running")
+	}
+
+	return newConn(p.client), nil
+}
+
+func (p *xmppInput) Start() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.running {
+		return nil
+	}
+
+	if len(p.server) == 0 || len(p.jid.domain) == 0 {
+		return errors.New("missing xmpp configuration")
+	}
+
+	exit := make(chan bool)
+	c := newClient(p.config, exit)
+
+	// fail fast if the server can't be reached
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+
+	go c.run(conn)
+
+	p.exit = exit
+	p.client = c
+	p.running = true
+
+	return nil
+}
+
+func (p *xmppInput) Stop() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.running {
+		return nil
+	}
+
+	close(p.exit)
+
+	select {
+	case <-p.client.done:
+	case <-time.After(stopTimeout):
+		log.Logf("[xmpp] timed out waiting to disconnect from %s", p.server)
+	}
+
+	p.running = false
+	return nil
+}
+
+func (p *xmppInput) String() string {
+	return "xmpp"
+}
+
+func NewInput() input.Input {
+	return &xmppInput{
+		config: config{nick: "micro"},
+	}
+}
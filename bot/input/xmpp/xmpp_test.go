@@ -0,0 +1,304 @@
+package xmpp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// certificate returns a self signed certificate for example.com
+func certificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// element is a stanza read by the test server
+type element struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+func (e *element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// serverConn is the bot's connection to the test server
+type serverConn struct {
+	t    *testing.T
+	conn net.Conn
+	dec  *xml.Decoder
+}
+
+func (s *serverConn) write(format string, args ...interface{}) {
+	if _, err := fmt.Fprintf(s.conn, format, args...); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// open reads the stream header the bot sends and offers the features
+func (s *serverConn) open(features string) {
+	s.dec = xml.NewDecoder(s.conn)
+
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			s.t.Fatalf("error reading stream header: %v", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			if se.Name.Local != "stream" {
+				s.t.Fatalf("expected a stream got %s", se.Name.Local)
+			}
+			break
+		}
+	}
+
+	s.write("<?xml version='1.0'?><stream:stream from='example.com' id='1' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'><stream:features>%s</stream:features>", features)
+}
+
+// read returns the next element, failing unless it's named name
+func (s *serverConn) read(name string) *element {
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	for {
+		tok, err := s.dec.Token()
+		if err != nil {
+			s.t.Fatalf("error reading %s: %v", name, err)
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var e element
+		if err := s.dec.DecodeElement(&e, &se); err != nil {
+			s.t.Fatal(err)
+		}
+
+		if e.XMLName.Local != name {
+			s.t.Fatalf("expected %s got %s %s", name, e.XMLName.Local, e.Inner)
+		}
+
+		return &e
+	}
+}
+
+// accept negotiates a stream with the bot and expects it to join the
+// room as nick
+func accept(t *testing.T, l net.Listener, cert tls.Certificate) *serverConn {
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &serverConn{t: t, conn: conn}
+
+	s.open("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls>")
+	s.read("starttls")
+	s.write("<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+
+	s.conn = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	s.open("<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'><mechanism>SCRAM-SHA-1</mechanism><mechanism>PLAIN</mechanism></mechanisms>")
+
+	auth := s.read("auth")
+	if b, _ := base64.StdEncoding.DecodeString(auth.Inner); string(b) != "\x00micro\x00secret" || auth.attr("mechanism") != "PLAIN" {
+		t.Fatalf("unexpected auth %q", b)
+	}
+	s.write("<success xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>")
+
+	s.open("<bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'/>")
+
+	bind := s.read("iq")
+	if !strings.Contains(bind.Inner, "<resource>micro-bot</resource>") {
+		t.Fatalf("unexpected bind %s", bind.Inner)
+	}
+	s.write("<iq type='result' id='%s'><bind xmlns='urn:ietf:params:xml:ns:xmpp-bind'><jid>micro@example.com/micro-bot</jid></bind></iq>", bind.attr("id"))
+
+	if p := s.read("presence"); len(p.attr("to")) > 0 {
+		t.Fatalf("expected initial presence got %s", p.attr("to"))
+	}
+
+	return s
+}
+
+// join expects the bot to join the room without history as nick
+func (s *serverConn) join(nick string) {
+	p := s.read("presence")
+	if p.attr("to") != "ops@conference.example.com/"+nick || !strings.Contains(p.Inner, "maxstanzas='0'") {
+		s.t.Fatalf("unexpected join %s %s", p.attr("to"), p.Inner)
+	}
+}
+
+// answer replies to each command with its text
+func answer(c input.Conn) {
+	for {
+		var ev input.Event
+		if err := c.Recv(&ev); err != nil {
+			return
+		}
+		c.Send(&input.Event{To: ev.From, Meta: ev.Meta, Type: input.TextEvent, Data: []byte("ran " + string(ev.Data) + "\n")})
+	}
+}
+
+func TestParseJID(t *testing.T) {
+	testData := []struct {
+		jid    string
+		expect jid
+		err    bool
+	}{
+		{"micro@example.com/bot", jid{"micro", "example.com", "bot"}, false},
+		{"micro@example.com", jid{"micro", "example.com", ""}, false},
+		{"example.com", jid{"", "example.com", ""}, false},
+		{"room@conference.example.com/nick/with/slashes", jid{"room", "conference.example.com", "nick/with/slashes"}, false},
+		{"@example.com", jid{"", "example.com", ""}, false},
+		{"", jid{}, true},
+		{"mi:cro@example.com", jid{}, true},
+	}
+
+	for _, d := range testData {
+		j, err := parseJID(d.jid)
+		if d.err != (err != nil) || j != d.expect {
+			t.Fatalf("%s: expected %+v got %+v %v", d.jid, d.expect, j, err)
+		}
+	}
+}
+
+func TestXMPP(t *testing.T) {
+	oldBackoff := reconnectBackoff
+	reconnectBackoff = 10 * time.Millisecond
+	defer func() { reconnectBackoff = oldBackoff }()
+
+	cert, pool := certificate(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	p := NewInput().(*xmppInput)
+	p.server = l.Addr().String()
+	p.jid = jid{"micro", "example.com", "micro-bot"}
+	p.password = "secret"
+	p.rooms = []string{"ops@conference.example.com"}
+	p.tls = &tls.Config{RootCAs: pool, ServerName: "example.com"}
+
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	c, err := p.Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	go answer(c)
+
+	s := accept(t, l, cert)
+	s.join("micro")
+
+	// the nick is taken
+	s.write("<presence from='ops@conference.example.com/micro' type='error'><error type='cancel'><conflict xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></presence>")
+	s.join("micro_")
+	s.write("<presence from='ops@conference.example.com/micro_'><x xmlns='http://jabber.org/protocol/muc#user'><status code='110'/></x></presence>")
+
+	// history isn't run again, nor are messages for someone else
+	s.write("<message from='ops@conference.example.com/alice' type='groupchat'><body>micro_: deploy old</body><delay xmlns='urn:xmpp:delay' stamp='2019-01-01T00:00:00Z'/></message>")
+	s.write("<message from='ops@conference.example.com/alice' type='groupchat'><body>bob: deploy</body></message>")
+	s.write("<message from='ops@conference.example.com/alice' type='groupchat'><subject>deploys</subject></message>")
+	s.write("<message from='ops@conference.example.com/alice' type='groupchat'><body>micro_: deploy api</body></message>")
+
+	m := s.read("message")
+	if m.attr("to") != "ops@conference.example.com" || m.attr("type") != "groupchat" || !strings.Contains(m.Inner, "<body>alice: ran deploy api</body>") {
+		t.Fatalf("unexpected reply %s %s", m.attr("to"), m.Inner)
+	}
+
+	// direct chats and private messages from occupants
+	for _, from := range []string{"bob@example.com/phone", "ops@conference.example.com/alice"} {
+		s.write("<message from='%s' type='chat'><body>status &amp; more</body></message>", from)
+
+		m = s.read("message")
+		if m.attr("to") != from || m.attr("type") != "chat" || !strings.Contains(m.Inner, "<body>ran status &amp; more</body>") {
+			t.Fatalf("unexpected reply %s %s", m.attr("to"), m.Inner)
+		}
+	}
+
+	// offline messages are delivered delayed too
+	s.write("<message from='bob@example.com/phone' type='chat'><body>restart</body><x xmlns='jabber:x:delay' stamp='20190101T00:00:00'/></message>")
+
+	s.write("<iq from='example.com' type='get' id='p1'><ping xmlns='urn:xmpp:ping'/></iq>")
+	if iq := s.read("iq"); iq.attr("id") != "p1" || iq.attr("type") != "result" {
+		t.Fatalf("unexpected ping reply %+v", iq)
+	}
+
+	// reconnects and rejoins
+	s.conn.Close()
+
+	s = accept(t, l, cert)
+	s.join("micro")
+	s.write("<presence from='ops@conference.example.com/micro'><x xmlns='http://jabber.org/protocol/muc#user'><status code='110'/></x></presence>")
+
+	s.write("<message from='ops@conference.example.com/alice' type='groupchat'><body>micro, ping</body></message>")
+	m = s.read("message")
+	if !strings.Contains(m.Inner, "<body>alice: ran ping</body>") {
+		t.Fatalf("unexpected reply %s", m.Inner)
+	}
+
+	p.Stop()
+
+	if pr := s.read("presence"); pr.attr("type") != "unavailable" {
+		t.Fatalf("expected unavailable presence got %+v", pr)
+	}
+}
@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// inputsPattern matches inputs alone or followed by a subcommand
+const inputsPattern = "^inputs( |$)"
+
+// inputLoop is a running input, its loop exits once exit is closed and
+// closes done when it has
+type inputLoop struct {
+	exit chan bool
+	done chan bool
+}
+
+// startInput initializes and starts the input, which is one the bot
+// was started with or any registered, and receives commands from it.
+// Inputs are configured by their flags and environment variables,
+// which the bot takes for every registered input.
+func (b *bot) startInput(name string) error {
+	b.toggle.Lock()
+	defer b.toggle.Unlock()
+
+	b.RLock()
+	io, ok := b.inputs[name]
+	_, running := b.loops[name]
+	b.RUnlock()
+
+	if running {
+		return fmt.Errorf("input %s is already running", name)
+	}
+
+	if !ok {
+		if io, ok = input.Lookup(name); !ok {
+			return fmt.Errorf("unknown input %s", name)
+		}
+	}
+
+	log.Logf("[bot] starting input %s", io.String())
+
+	if err := io.Init(b.ctx); err != nil {
+		return err
+	}
+
+	if err := io.Start(); err != nil {
+		return err
+	}
+
+	// commands registered on init run like the rest
+	b.adopt()
+
+	l := &inputLoop{
+		exit: make(chan bool),
+		done: make(chan bool),
+	}
+
+	b.Lock()
+	b.inputs[name] = io
+	b.loops[name] = l
+	b.Unlock()
+
+	go b.loop(io, l)
+
+	return nil
+}
+
+// stopInput stops the running input once the commands it received
+// reply, waiting for those and its loop up to the stop timeout. Other
+// inputs are unaffected.
+func (b *bot) stopInput(name string) error {
+	b.toggle.Lock()
+	defer b.toggle.Unlock()
+
+	b.Lock()
+	l, ok := b.loops[name]
+	io := b.inputs[name]
+	delete(b.loops, name)
+	b.Unlock()
+
+	if !ok {
+		return fmt.Errorf("input %s isn't running", name)
+	}
+
+	log.Logf("[bot] stopping input %s", io.String())
+
+	// the loop stops dispatching events, those dispatched reply before
+	// the input is stopped
+	close(l.exit)
+
+	timeout := b.stopTimeout()
+	drained := true
+
+	c, running := b.conn(io.String())
+	if sc, ok := c.(*serialConn); running && ok {
+		drained = sc.drain(timeout)
+	}
+
+	err := io.Stop()
+
+	// unblocks the loop if the input left its conn receiving
+	if running {
+		c.Close()
+	}
+
+	select {
+	case <-l.done:
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for input %s to stop", name)
+	}
+
+	if !drained {
+		return fmt.Errorf("timed out waiting for the commands of input %s to finish", name)
+	}
+
+	return err
+}
+
+// adopt adds the commands registered since the bot started, such as by
+// an input on init. Those already registered are skipped so inputs may
+// start any number of times.
+func (b *bot) adopt() {
+	b.Lock()
+	defer b.Unlock()
+
+	static := make(map[string]command.Command, len(b.static))
+	registered := make(map[string]bool)
+
+	for pattern, cmd := range b.static {
+		static[pattern] = cmd
+		registered[command.FullName(cmd)] = true
+	}
+
+	var added []string
+
+	add := func(pattern string, cmd command.Command) {
+		if _, ok := static[pattern]; ok || registered[command.FullName(cmd)] {
+			return
+		}
+		static[pattern] = command.Wrap(cmd)
+		registered[command.FullName(cmd)] = true
+		added = append(added, pattern)
+	}
+
+	for pattern, cmd := range command.Commands {
+		add(command.Pattern(command.Group(cmd), pattern), cmd)
+	}
+	for pattern, cmd := range command.Registered() {
+		add(pattern, cmd)
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	// advertised commands are kept unless now shadowed
+	commands := make(map[string]command.Command, len(b.commands)+len(added))
+	for pattern, cmd := range b.commands {
+		commands[pattern] = cmd
+	}
+	for _, pattern := range added {
+		commands[pattern] = static[pattern]
+	}
+
+	commands[helpPattern] = command.Wrap(help(commands, b.services))
+
+	b.static = static
+	b.commands = commands
+	b.names, b.groups = nameIndex(commands)
+	b.reindex(commands)
+}
+
+// inputsCommand returns the command listing, starting and stopping the
+// inputs of the bot
+func inputsCommand(b *bot) command.Command {
+	usage := "inputs [list|start <input>|stop <input>]"
+	desc := "Lists the inputs or starts and stops one"
+
+	return command.NewContextCommand("inputs", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) < 2 {
+			return []byte("usage: " + usage), nil
+		}
+
+		switch args[1] {
+		case "list":
+			b.RLock()
+			names := input.List()
+			for name := range b.inputs {
+				if _, ok := input.Lookup(name); !ok {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+
+			var lines []string
+			for _, name := range names {
+				state := "stopped"
+				if _, ok := b.loops[name]; ok {
+					state = "running"
+				}
+				lines = append(lines, fmt.Sprintf("%s  %s", name, state))
+			}
+			b.RUnlock()
+
+			return command.Reply(ctx, &command.Response{
+				Text: fmt.Sprintf("%d inputs", len(names)),
+				Code: strings.Join(lines, "\n"),
+			}), nil
+		case "start", "stop":
+			if len(args) != 3 {
+				return []byte("usage: inputs " + args[1] + " <input>"), nil
+			}
+
+			name := args[2]
+
+			if args[1] == "start" {
+				if err := b.startInput(name); err != nil {
+					return nil, err
+				}
+				return []byte("started input " + name), nil
+			}
+
+			// the reply couldn't be sent
+			if name == command.Input(ctx) {
+				return nil, fmt.Errorf("can't stop input %s from itself, use another input", name)
+			}
+
+			if err := b.stopInput(name); err != nil {
+				return nil, err
+			}
+			return []byte("stopped input " + name), nil
+		}
+
+		return []byte("usage: " + usage), nil
+	})
+}
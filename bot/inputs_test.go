@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"flag"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// switchInput streams a new conn each time it's started, registering a
+// command on init
+type switchInput struct {
+	sync.Mutex
+	inits, stops int
+	conn         *testInput
+}
+
+func (s *switchInput) Flags() []cli.Flag {
+	return nil
+}
+
+func (s *switchInput) Init(*cli.Context) error {
+	s.Lock()
+	defer s.Unlock()
+	s.inits++
+	command.Commands["^switch$"] = command.WithAliases(command.NewCommand("switch", "switch", "returns on", func(args ...string) ([]byte, error) {
+		return []byte("on"), nil
+	}), "sw")
+	return nil
+}
+
+func (s *switchInput) Start() error {
+	s.Lock()
+	defer s.Unlock()
+	s.conn = &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+	return nil
+}
+
+func (s *switchInput) Stop() error {
+	s.Lock()
+	defer s.Unlock()
+	s.stops++
+	return s.conn.Close()
+}
+
+func (s *switchInput) Stream() (input.Conn, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.conn, nil
+}
+
+func (s *switchInput) String() string {
+	return "switch"
+}
+
+// ping sends ping through the conn of the running input
+func (s *switchInput) ping(t *testing.T) {
+	s.Lock()
+	c := s.conn
+	s.Unlock()
+
+	select {
+	case c.recv <- &input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte("ping")}:
+	case <-time.After(time.Second):
+		t.Fatal("timed out sending ping")
+	}
+
+	select {
+	case ev := <-c.send:
+		if string(ev.Data) != "pong" {
+			t.Fatalf("expected pong got %q", string(ev.Data))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out receiving pong")
+	}
+}
+
+func TestInputs(t *testing.T) {
+	sw := &switchInput{}
+	input.Register("switch", sw)
+	defer input.Deregister("switch")
+	defer delete(command.Commands, "^switch$")
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("acl", "allow inputs = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, commands, service)
+	c := &serialConn{Conn: io, input: "test"}
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	// waits for the goroutines to exit
+	settle := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for runtime.NumGoroutine() > n {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				t.Fatalf("leaked %d goroutines\n%s", runtime.NumGoroutine()-n, buf[:runtime.Stack(buf, true)])
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if rsp := send("C0:U2", "inputs list"); !strings.HasPrefix(rsp, "permission denied") {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	n := runtime.NumGoroutine()
+
+	for i := 1; i <= 2; i++ {
+		if rsp := send("C0:U1", "inputs start switch"); rsp != "started input switch" {
+			t.Fatalf("unexpected response %q", rsp)
+		}
+		sw.ping(t)
+
+		if rsp := send("C0:U1", "inputs list"); !strings.Contains(rsp, "switch  running") || !strings.Contains(rsp, "test  stopped") {
+			t.Fatalf("unexpected list %q", rsp)
+		}
+
+		// the command registered on init is added once
+		if rsp := send("C0:U1", "switch"); rsp != "on" {
+			t.Fatalf("unexpected response %q", rsp)
+		}
+		// as are its aliases
+		if rsp := send("C0:U1", "sw"); rsp != "on" {
+			t.Fatalf("unexpected alias response %q", rsp)
+		}
+		if rsp := send("C0:U1", "help"); strings.Count(rsp, "returns on") != 1 {
+			t.Fatalf("unexpected help %q", rsp)
+		}
+
+		if rsp := send("C0:U1", "inputs stop switch"); rsp != "stopped input switch" {
+			t.Fatalf("unexpected response %q", rsp)
+		}
+		settle(n)
+
+		sw.Lock()
+		inits, stops := sw.inits, sw.stops
+		sw.Unlock()
+		if inits != i || stops != i {
+			t.Fatalf("expected %d inits and stops got %d and %d", i, inits, stops)
+		}
+
+		// commands stay registered with the input stopped
+		if rsp := send("C0:U1", "switch"); rsp != "on" {
+			t.Fatalf("unexpected response %q", rsp)
+		}
+	}
+
+	for _, text := range []string{
+		"inputs start nope",
+		"inputs stop switch",
+		// the reply would be lost too
+		"inputs stop test",
+	} {
+		if rsp := send("C0:U1", text); !strings.HasPrefix(rsp, "error executing cmd: ") {
+			t.Fatalf("%q: unexpected response %q", text, rsp)
+		}
+	}
+
+	if rsp := send("C0:U1", "inputs start switch"); rsp != "started input switch" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U1", "inputs start switch"); rsp != "error executing cmd: input switch is already running" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// stopping the bot stops the inputs started since
+	if err := bot.stop(); err != nil {
+		t.Fatal(err)
+	}
+	settle(n)
+
+	if sw.stops != 3 {
+		t.Fatalf("expected 3 stops got %d", sw.stops)
+	}
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+)
+
+// loadCatalog returns the catalog of the language flags, the built in
+// languages and those of the language file
+func loadCatalog(ctx *cli.Context) (*i18n.Catalog, error) {
+	c := i18n.New()
+
+	if path := ctx.String("language_file"); len(path) > 0 {
+		if err := c.LoadFile(path); err != nil {
+			return nil, fmt.Errorf("error loading language file: %v", err)
+		}
+	}
+
+	if lang := ctx.String("bot_language"); len(lang) > 0 {
+		if err := c.SetLanguage(lang); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range ctx.StringSlice("channel_language") {
+		parts := strings.SplitN(l, "=", 2)
+		channel := strings.SplitN(parts[0], ":", 2)
+		if len(parts) != 2 || len(channel) != 2 || len(channel[0]) == 0 || len(channel[1]) == 0 {
+			return nil, fmt.Errorf("channel language %q must be of the form <input>:<channel>=<language>", l)
+		}
+		if err := c.SetChannelLanguage(channel[0], channel[1], parts[1]); err != nil {
+			return nil, fmt.Errorf("channel language %q: %v", l, err)
+		}
+	}
+
+	return c, nil
+}
+
+// origin is the input and channel a command came from, replies are in
+// its language
+type origin struct {
+	input   string
+	channel string
+}
+
+func originOf(c input.Conn, ev input.Event) origin {
+	var o origin
+	if sc, ok := c.(*serialConn); ok {
+		o.input = sc.input
+	}
+	_, o.channel = requester(ev)
+	return o
+}
+
+func originOfContext(ctx context.Context) origin {
+	return origin{command.Input(ctx), command.Channel(ctx)}
+}
+
+// say returns the message of the catalog in the origin's language
+func (o origin) say(key i18n.Key, params i18n.Params) []byte {
+	return []byte(i18n.Text(o.input, o.channel, key, params))
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestLanguage(t *testing.T) {
+	defer i18n.Use(i18n.New())
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("bot_language", "es-MX", "")
+	flagSet.Var(&cli.StringSlice{"test:C0EN=en"}, "channel_language", "")
+	flagSet.String("acl", "allow deploy = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	deploy := command.NewCommand("deploy", "deploy <service>", "deploys a service", func(args ...string) ([]byte, error) {
+		panic("boom")
+	})
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, map[string]command.Command{"^deploy ": deploy}, service)
+	c := &serialConn{Conn: io, input: "test"}
+
+	for _, d := range []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C0OPS:U1", "deplyo foo", "comando desconocido 'deplyo', ¿quisiste decir deploy? ejecuta help para ver la lista de comandos"},
+		{"C0OPS:U2", "deploy foo", "permiso denegado: test:U2 no puede ejecutar 'deploy'"},
+		{"C0OPS:U1", "deploy foo", "el comando 'deploy' falló: boom"},
+		{"C0OPS:U1", "deploy 'foo", "error al interpretar el comando: unbalanced single quote at position 8"},
+		{"C0OPS:U1", "nope | deploy", "la cadena falló en el comando 1 (nope): comando desconocido 'nope', ejecuta help para ver la lista de comandos"},
+		// the channel's language overrides the bot's
+		{"C0EN:U1", "deplyo foo", "unknown command 'deplyo', did you mean deploy? run help for a list of commands"},
+		{"C0EN:U2", "deploy foo", "permission denied: test:U2 may not run 'deploy'"},
+	} {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: d.from, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != d.expect {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", d.text)
+		}
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	for _, d := range []struct {
+		lang     string
+		channels []string
+		file     string
+		err      string
+	}{
+		{"xx", nil, "", `no messages in language "xx"`},
+		{"", []string{"C0OPS=es"}, "", `channel language "C0OPS=es" must be of the form <input>:<channel>=<language>`},
+		{"", []string{"slack:C0OPS=xx"}, "", `channel language "slack:C0OPS=xx": no messages in language "xx"`},
+		{"", nil, "/nonexistent/messages.json", "error loading language file: open /nonexistent/messages.json: no such file or directory"},
+	} {
+		flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+		flagSet.String("bot_language", d.lang, "")
+		flagSet.String("language_file", d.file, "")
+		slice := cli.StringSlice(d.channels)
+		flagSet.Var(&slice, "channel_language", "")
+		ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+		if _, err := loadCatalog(ctx); err == nil || err.Error() != d.err {
+			t.Fatalf("expected error %q got %v", d.err, err)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	goplugin "plugin"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// The symbols a plugin built with -buildmode=plugin exports for the bot
+// to load, any of which it may export:
+//
+//	var Input input.Input                       // registered by its String
+//	func NewInput() input.Input
+//	var Command command.Command                 // matched by its name
+//	func NewCommand() command.Command
+//	var Commands map[string]command.Command     // keyed by pattern
+//
+// Commands are put in the group they have, if any. Plugins are loaded
+// after the command line is parsed so the flags of their inputs are
+// never set, they're configured through the environment instead.
+const (
+	InputSymbol      = "Input"
+	NewInputSymbol   = "NewInput"
+	CommandSymbol    = "Command"
+	NewCommandSymbol = "NewCommand"
+	CommandsSymbol   = "Commands"
+)
+
+// loadPlugins loads the plugins of the bot_plugins directory, skipping
+// those which fail to load unless bot_plugins_strict is set
+func loadPlugins(ctx *cli.Context) error {
+	dir := ctx.String("bot_plugins")
+	if len(dir) == 0 {
+		return nil
+	}
+	strict := ctx.Bool("bot_plugins_strict")
+
+	paths, err := pluginPaths(dir)
+	if err != nil {
+		if strict {
+			return err
+		}
+		log.Logf("[bot] not loading plugins: %v", err)
+		return nil
+	}
+
+	for _, path := range paths {
+		if err := loadPlugin(path); err != nil {
+			if strict {
+				return err
+			}
+			log.Logf("[bot] skipping plugin: %v", err)
+			continue
+		}
+		log.Logf("[bot] loaded plugin %s", path)
+	}
+
+	return nil
+}
+
+// pluginPaths returns the .so files of the directory in order
+func pluginPaths(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plugins directory: %v", err)
+	}
+
+	var paths []string
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".so" {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, f.Name()))
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// openError explains why the plugin couldn't be opened, the most likely
+// reason being it wasn't built against the bot's packages
+func openError(path string, err error) error {
+	if strings.Contains(err.Error(), "different version of package") {
+		return fmt.Errorf("plugin %s was built with other versions of the bot's packages, rebuild it against this version of micro: %v", path, err)
+	}
+	return fmt.Errorf("error opening plugin %s: %v", path, err)
+}
+
+// loadPlugin opens the plugin and registers the inputs and commands it
+// exports. Nothing's registered if any of them is malformed.
+func loadPlugin(path string) error {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return openError(path, err)
+	}
+
+	lookup := func(name string) goplugin.Symbol {
+		// missing symbols aren't an error, the plugin needn't have all
+		sym, err := p.Lookup(name)
+		if err != nil {
+			return nil
+		}
+		return sym
+	}
+
+	var inputs []input.Input
+	commands := make(map[string]command.Command)
+
+	malformed := func(name string, sym goplugin.Symbol, want string) error {
+		return fmt.Errorf("plugin %s: %s is a %T, not %s", path, name, sym, want)
+	}
+
+	if sym := lookup(InputSymbol); sym != nil {
+		i, ok := sym.(*input.Input)
+		if !ok || *i == nil {
+			return malformed(InputSymbol, sym, "an input.Input")
+		}
+		inputs = append(inputs, *i)
+	}
+
+	if sym := lookup(NewInputSymbol); sym != nil {
+		fn, ok := sym.(func() input.Input)
+		if !ok {
+			return malformed(NewInputSymbol, sym, "a func() input.Input")
+		}
+		i := fn()
+		if i == nil {
+			return fmt.Errorf("plugin %s: %s returned no input", path, NewInputSymbol)
+		}
+		inputs = append(inputs, i)
+	}
+
+	addCommand := func(pattern string, cmd command.Command) {
+		if len(pattern) == 0 {
+			pattern = "^" + regexp.QuoteMeta(strings.ToLower(cmd.String())) + "( |$)"
+		}
+		commands[pattern] = cmd
+	}
+
+	if sym := lookup(CommandSymbol); sym != nil {
+		c, ok := sym.(*command.Command)
+		if !ok || *c == nil {
+			return malformed(CommandSymbol, sym, "a command.Command")
+		}
+		addCommand("", *c)
+	}
+
+	if sym := lookup(NewCommandSymbol); sym != nil {
+		fn, ok := sym.(func() command.Command)
+		if !ok {
+			return malformed(NewCommandSymbol, sym, "a func() command.Command")
+		}
+		c := fn()
+		if c == nil {
+			return fmt.Errorf("plugin %s: %s returned no command", path, NewCommandSymbol)
+		}
+		addCommand("", c)
+	}
+
+	if sym := lookup(CommandsSymbol); sym != nil {
+		m, ok := sym.(*map[string]command.Command)
+		if !ok {
+			return malformed(CommandsSymbol, sym, "a map[string]command.Command")
+		}
+		for pattern, c := range *m {
+			if len(pattern) == 0 || c == nil {
+				return fmt.Errorf("plugin %s: %s has a command without a pattern", path, CommandsSymbol)
+			}
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("plugin %s: command %s has an invalid pattern: %v", path, c.String(), err)
+			}
+			addCommand(pattern, c)
+		}
+	}
+
+	if len(inputs) == 0 && len(commands) == 0 {
+		return fmt.Errorf("plugin %s exports none of %s, %s, %s, %s or %s", path,
+			InputSymbol, NewInputSymbol, CommandSymbol, NewCommandSymbol, CommandsSymbol)
+	}
+
+	// the registries panic registering a name twice
+	for _, i := range inputs {
+		if _, ok := input.Lookup(i.String()); ok {
+			return fmt.Errorf("plugin %s: input %s is already registered", path, i.String())
+		}
+	}
+	registered := make(map[string]bool)
+	for _, c := range command.Registered() {
+		registered[command.FullName(c)] = true
+	}
+	for _, c := range commands {
+		if registered[command.FullName(c)] {
+			return fmt.Errorf("plugin %s: command %s is already registered", path, command.FullName(c))
+		}
+		registered[command.FullName(c)] = true
+	}
+
+	for _, i := range inputs {
+		input.Register(i.String(), i)
+	}
+	for pattern, c := range commands {
+		command.Register(command.Group(c), pattern, c)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// pluginDir holds the fixture plugins of testdata/plugins built by
+// TestMain, empty if they couldn't be built
+var pluginDir string
+
+func TestMain(m *testing.M) {
+	dir, err := buildPlugins()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "not testing plugins: %v\n", err)
+	}
+	pluginDir = dir
+
+	code := m.Run()
+
+	if len(dir) > 0 {
+		os.RemoveAll(dir)
+	}
+	os.Exit(code)
+}
+
+func buildPlugins() (string, error) {
+	fixtures, err := ioutil.ReadDir(filepath.Join("testdata", "plugins"))
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := ioutil.TempDir("", "bot-plugins")
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range fixtures {
+		out := filepath.Join(dir, f.Name()+".so")
+		cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", out, "./"+filepath.Join("testdata", "plugins", f.Name()))
+		if b, err := cmd.CombinedOutput(); err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("error building plugin %s: %v\n%s", f.Name(), err, b)
+		}
+	}
+
+	// not a plugin at all
+	if err := ioutil.WriteFile(filepath.Join(dir, "garbage.so"), []byte("garbage"), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func pluginContext(dir string, strict bool) *cli.Context {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("bot_plugins", dir, "")
+	flagSet.Bool("bot_plugins_strict", strict, "")
+	return cli.NewContext(cli.NewApp(), flagSet, nil)
+}
+
+func TestLoadPlugins(t *testing.T) {
+	if len(pluginDir) == 0 {
+		t.Skip("plugins weren't built")
+	}
+
+	// a plugin failing doesn't stop the others loading
+	if err := loadPlugins(pluginContext(pluginDir, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := input.Lookup("plugin"); !ok {
+		// the test binary and plugins built differently, e.g. with
+		// -race, don't share packages
+		err := loadPlugin(filepath.Join(pluginDir, "good.so"))
+		if err != nil && strings.Contains(err.Error(), "rebuild it against") {
+			t.Skipf("plugins can't be loaded by this test binary: %v", err)
+		}
+		t.Fatalf("expected the input of the plugin to be registered: %v", err)
+	}
+
+	names := make(map[string]command.Command)
+	for pattern, c := range command.Registered() {
+		names[command.FullName(c)] = c
+		if command.FullName(c) == "greet" && pattern != "^greet( |$)" {
+			t.Fatalf("unexpected pattern %s of greet", pattern)
+		}
+	}
+
+	greet, ok := names["greet"]
+	if !ok {
+		t.Fatal("expected the command of the plugin to be registered")
+	}
+	if rsp, err := greet.Exec("greet", "bob"); err != nil || string(rsp) != "hello bob" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+	if _, ok := names["net ping"]; !ok {
+		t.Fatal("expected the grouped command of the plugin to be registered")
+	}
+
+	// the failing plugins stop the bot when strict, linked as a plugin
+	// is loaded once
+	for _, d := range []struct {
+		plugin string
+		err    string
+	}{
+		{"malformed", "plugin %s: Command is a *string, not a command.Command"},
+		{"empty", "plugin %s exports none of Input, NewInput, Command, NewCommand or Commands"},
+		{"garbage", "error opening plugin %s: "},
+	} {
+		dir, err := ioutil.TempDir("", "bot-plugins")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, d.plugin+".so")
+		if err := os.Symlink(filepath.Join(pluginDir, d.plugin+".so"), path); err != nil {
+			t.Fatal(err)
+		}
+
+		expect := fmt.Sprintf(d.err, path)
+		if err := loadPlugins(pluginContext(dir, true)); err == nil || !strings.HasPrefix(err.Error(), expect) {
+			t.Fatalf("%s: expected error %q got %v", d.plugin, expect, err)
+		}
+		if err := loadPlugins(pluginContext(dir, false)); err != nil {
+			t.Fatalf("%s: unexpected error %v", d.plugin, err)
+		}
+	}
+}
+
+func TestLoadPluginsDir(t *testing.T) {
+	missing := filepath.Join("testdata", "missing")
+
+	if err := loadPlugins(pluginContext(missing, false)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := loadPlugins(pluginContext(missing, true)); err == nil || !strings.HasPrefix(err.Error(), "error reading plugins directory: ") {
+		t.Fatalf("expected error reading the directory got %v", err)
+	}
+	if err := loadPlugins(pluginContext("", true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestOpenError(t *testing.T) {
+	err := openError("old.so", errors.New(`plugin.Open("old"): plugin was built with a different version of package github.com/micro/micro/bot/input`))
+	if !strings.HasPrefix(err.Error(), "plugin old.so was built with other versions of the bot's packages, rebuild it against this version of micro: ") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = openError("bad.so", errors.New("invalid ELF header"))
+	if err.Error() != "error opening plugin bad.so: invalid ELF header" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/micro/go-log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// CommandDurationBuckets are the upper bounds in seconds of the command
+// duration histogram, up to commands taking a minute
+var CommandDurationBuckets = []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// metrics are the counters of the commands executed and events received
+// by the bot. Each bot has its own registry so the inputs, and bots,
+// running in a process never register the same metric twice.
+type metrics struct {
+	registry *prometheus.Registry
+
+	executed    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	received    *prometheus.CounterVec
+	connections *prometheus.GaugeVec
+
+	// serving the metrics, nil unless there's an address
+	server *http.Server
+	addr   net.Addr
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		executed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "bot",
+			Name:      "commands_executed_total",
+			Help:      "Commands executed by input, command and status, one of ok, error, timeout, crashed or unavailable.",
+		}, []string{"input", "command", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "micro",
+			Subsystem: "bot",
+			Name:      "command_duration_seconds",
+			Help:      "How long commands took to execute by input and command.",
+			Buckets:   CommandDurationBuckets,
+		}, []string{"input", "command"}),
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "micro",
+			Subsystem: "bot",
+			Name:      "events_received_total",
+			Help:      "Events received by input.",
+		}, []string{"input"}),
+		connections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "micro",
+			Subsystem: "bot",
+			Name:      "connections",
+			Help:      "Connections of the inputs which are active.",
+		}, []string{"input"}),
+	}
+
+	m.registry.MustRegister(
+		m.executed,
+		m.duration,
+		m.received,
+		m.connections,
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// status returns the status commands failing with err are counted by
+func status(err error) string {
+	switch err.(type) {
+	case nil:
+		return "ok"
+	case timeoutError:
+		return "timeout"
+	case crashError:
+		return "crashed"
+	case unavailableError:
+		return "unavailable"
+	}
+	return "error"
+}
+
+// observe counts the command of the input executed since start
+func (m *metrics) observe(in, name string, start time.Time, err error) {
+	m.executed.WithLabelValues(in, name, status(err)).Inc()
+	m.duration.WithLabelValues(in, name).Observe(time.Since(start).Seconds())
+}
+
+// receive counts the event received by the input
+func (m *metrics) receive(in string) {
+	m.received.WithLabelValues(in).Inc()
+}
+
+// connect counts the input connected until the returned func is called
+func (m *metrics) connect(in string) func() {
+	g := m.connections.WithLabelValues(in)
+	g.Inc()
+	return g.Dec
+}
+
+// serve exposes the metrics on /metrics of the address until closed
+func (m *metrics) serve(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	m.server = &http.Server{Handler: mux}
+	m.addr = l.Addr()
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Logf("[bot] error serving metrics on %s: %v", addr, err)
+		}
+	}(m.server)
+
+	log.Logf("[bot] serving metrics on %s/metrics", m.addr)
+	return nil
+}
+
+func (m *metrics) close() {
+	if m.server != nil {
+		m.server.Close()
+	}
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// namedInput is a testInput of another name
+type namedInput struct {
+	*testInput
+	name string
+}
+
+func (n *namedInput) Stream() (input.Conn, error) {
+	return n.testInput, nil
+}
+
+func (n *namedInput) String() string {
+	return n.name
+}
+
+func TestMetrics(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("bot_metrics_address", "127.0.0.1:0", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	newInput := func() *testInput {
+		return &testInput{
+			send: make(chan *input.Event),
+			recv: make(chan *input.Event),
+			exit: make(chan bool),
+		}
+	}
+
+	test, other := newInput(), newInput()
+
+	inputs := map[string]input.Input{
+		"test":  test,
+		"other": &namedInput{other, "other"},
+	}
+
+	commands := map[string]command.Command{
+		"^echo ": command.NewCommand("echo", "echo <text>", "echoes the text", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+		"^fail$": command.NewCommand("fail", "fail", "fails", func(args ...string) ([]byte, error) {
+			return nil, errors.New("failed")
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	// the metrics of bots in the same process don't collide
+	newBot(ctx, nil, map[string]command.Command{}, service)
+
+	bot := newBot(ctx, inputs, commands, service)
+	if err := bot.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(io *testInput, text string) {
+		select {
+		case io.recv <- &input.Event{Meta: map[string]interface{}{}, Type: input.TextEvent, From: "C0:U1", Data: []byte(text)}:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out sending %q", text)
+		}
+		select {
+		case <-io.send:
+		case <-time.After(time.Second):
+			t.Fatalf("timed out receiving the reply to %q", text)
+		}
+	}
+
+	send(test, "echo foo")
+	send(test, "echo bar")
+	send(other, "fail")
+	send(other, "nope")
+
+	scrape := func() (string, error) {
+		rsp, err := http.Get("http://" + bot.metrics.addr.String() + "/metrics")
+		if err != nil {
+			return "", err
+		}
+		defer rsp.Body.Close()
+		b, err := ioutil.ReadAll(rsp.Body)
+		return string(b), err
+	}
+
+	body, err := scrape()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expect := range []string{
+		`micro_bot_commands_executed_total{command="echo",input="test",status="ok"} 2`,
+		`micro_bot_commands_executed_total{command="fail",input="other",status="error"} 1`,
+		`micro_bot_command_duration_seconds_count{command="echo",input="test"} 2`,
+		`micro_bot_command_duration_seconds_count{command="fail",input="other"} 1`,
+		`micro_bot_events_received_total{input="test"} 2`,
+		`micro_bot_events_received_total{input="other"} 2`,
+		`micro_bot_connections{input="test"} 1`,
+		`micro_bot_connections{input="other"} 1`,
+	} {
+		if !strings.Contains(body, expect+"\n") {
+			t.Fatalf("expected %s in the metrics\n%s", expect, body)
+		}
+	}
+
+	// unknown commands aren't executed
+	if strings.Contains(body, `command="nope"`) {
+		t.Fatalf("unexpected metrics of an unknown command\n%s", body)
+	}
+
+	if err := bot.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := scrape(); err == nil {
+		t.Fatal("expected the metrics not to be served once stopped")
+	}
+}
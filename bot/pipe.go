@@ -0,0 +1,179 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/i18n"
+	"github.com/micro/micro/bot/input"
+)
+
+const (
+	// grepPattern matches grep followed by its pattern
+	grepPattern = "^grep "
+	// headPattern matches head alone or followed by its args
+	headPattern = "^head( |$)"
+	// countPattern matches count alone or followed by its text
+	countPattern = "^count( |$)"
+)
+
+// DefaultHeadLines is how many lines head keeps unless told otherwise
+var DefaultHeadLines = 10
+
+// pipeConn keeps the reply to a command whose output is piped on
+type pipeConn struct {
+	data string
+	// set once the command is executed, which isn't the case for
+	// unknown commands, bad usage or commands the sender can't run
+	executed bool
+	err      error
+}
+
+func (p *pipeConn) Close() error {
+	return nil
+}
+
+func (p *pipeConn) Recv(*input.Event) error {
+	return errors.New("pipe can't receive")
+}
+
+func (p *pipeConn) Send(ev *input.Event) error {
+	p.data = string(ev.Data)
+	return nil
+}
+
+func (p *pipeConn) Notify(input.Event) func(error) {
+	p.executed = true
+	return func(err error) {
+		p.err = err
+	}
+}
+
+// pipe runs the commands of a pipeline in turn, each passed the output
+// of the one before as its last arg. The last replies as if run alone,
+// the first to fail replies saying which it was.
+func (b *bot) pipe(c input.Conn, ev input.Event, cmds [][]string) error {
+	var output string
+
+	// the text is of the whole pipeline rather than any one command
+	ev.Data = nil
+
+	for i, args := range cmds {
+		if i > 0 {
+			args = append(args, output)
+		}
+
+		if i == len(cmds)-1 {
+			return b.runCommand(c, ev, args)
+		}
+
+		name := normalize(args[0])
+
+		// replies go to the pipe through a conn of the same input
+		p := &pipeConn{}
+		var pc input.Conn = p
+		if sc, ok := c.(*serialConn); ok {
+			pc = &serialConn{Conn: p, input: sc.input}
+		}
+
+		if err := b.runCommand(pc, ev, args); err != nil {
+			return err
+		}
+
+		if p.executed && p.err == nil {
+			output = strings.TrimRight(p.data, "\n")
+			continue
+		}
+
+		if p.executed {
+			notify(c, ev)(p.err)
+		}
+
+		o := originOf(c, ev)
+		reason := p.data
+		if len(reason) == 0 {
+			reason = string(o.say(i18n.PipelineNotRun, nil))
+		}
+		return respond(c, ev, o.say(i18n.PipelineFailed, i18n.Params{"position": i + 1, "name": name, "reason": reason}))
+	}
+
+	return nil
+}
+
+// splitLines splits text into lines, none if it's empty
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// grepCommand keeps the lines of the text piped to it matching a pattern
+func grepCommand() command.Command {
+	desc := "Returns the lines of the text matching the regular expression, e.g. list services | grep auth"
+	args := []command.Arg{
+		{Name: "pattern", Required: true},
+		{Name: "text", Required: true},
+	}
+
+	return command.NewCommandWithArgs("grep", desc, args, func(args ...string) ([]byte, error) {
+		re, err := regexp.Compile(args[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %v", err)
+		}
+
+		var matched []string
+		for _, l := range splitLines(strings.Join(args[2:], " ")) {
+			if re.MatchString(l) {
+				matched = append(matched, l)
+			}
+		}
+
+		return []byte(strings.Join(matched, "\n")), nil
+	})
+}
+
+// headCommand keeps the first lines of the text piped to it
+func headCommand() command.Command {
+	usage := "head [lines] <text>"
+	desc := fmt.Sprintf("Returns the first lines of the text, %d unless given, e.g. list services | head 5", DefaultHeadLines)
+
+	return command.NewCommand("head", usage, desc, func(args ...string) ([]byte, error) {
+		n := DefaultHeadLines
+
+		switch len(args) {
+		case 2:
+		case 3:
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid number of lines %q", args[1])
+			}
+			n = v
+		default:
+			return nil, errors.New("usage: " + usage)
+		}
+
+		l := splitLines(args[len(args)-1])
+		if len(l) > n {
+			l = l[:n]
+		}
+
+		return []byte(strings.Join(l, "\n")), nil
+	})
+}
+
+// countCommand counts the lines of the text piped to it
+func countCommand() command.Command {
+	desc := "Returns the number of lines of the text, e.g. list services | count"
+	args := []command.Arg{
+		{Name: "text", Required: true},
+	}
+
+	return command.NewCommandWithArgs("count", desc, args, func(args ...string) ([]byte, error) {
+		return []byte(strconv.Itoa(len(splitLines(strings.Join(args[1:], " "))))), nil
+	})
+}
@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestPipe(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^list ": command.NewCommand("list", "list services", "lists services", func(args ...string) ([]byte, error) {
+			return []byte("go.micro.srv.auth\ngo.micro.srv.config\ngo.micro.api.auth\n"), nil
+		}),
+		"^echo ": command.NewCommand("echo", "echo [text]", "echoes", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+		"^fail": command.NewCommand("fail", "fail", "fails", func(args ...string) ([]byte, error) {
+			return nil, errors.New("boom")
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	c := &serialConn{Conn: io, input: "test"}
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		{"list services | grep auth | count", "2"},
+		{"list services | grep auth", "go.micro.srv.auth\ngo.micro.api.auth"},
+		{"list services | head 1", "go.micro.srv.auth"},
+		{"list services | head", "go.micro.srv.auth\ngo.micro.srv.config\ngo.micro.api.auth"},
+		{"list services | grep nope | count", "0"},
+		{"list services|grep api|count", "1"},
+		// quoted pipes aren't pipes
+		{"echo 'a|b'", "a|b"},
+		{`echo "a | b" | count`, "1"},
+		{`echo a\|b`, "a|b"},
+		{"echo a b | echo c", "c a b"},
+		// the first command to fail stops the pipeline
+		{"fail | count", "pipeline failed at command 1 (fail): error executing cmd: boom"},
+		{"list services | fail | count", "pipeline failed at command 2 (fail): error executing cmd: boom"},
+		{"list services | nope | count", "pipeline failed at command 2 (nope): unknown command 'nope', run help for a list of commands"},
+		{"list services | grep [ | count", "pipeline failed at command 2 (grep): error executing cmd: invalid pattern: error parsing regexp: missing closing ]: `[`"},
+		// the last replies as it would alone
+		{"list services | fail", "error executing cmd: boom"},
+		{"list services | head x", `error executing cmd: invalid number of lines "x"`},
+		{"list services |", "error parsing command: missing command in pipeline at position 15"},
+		// filters need something to filter
+		{"grep auth", "missing text\nusage: grep <pattern> <text>"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if string(ev.Data) != d.expect {
+				t.Fatalf("%q: expected %q got %q", d.text, d.expect, string(ev.Data))
+			}
+		default:
+			t.Fatalf("%q: expected a response", d.text)
+		}
+
+		// only the last command replies
+		select {
+		case ev := <-io.send:
+			t.Fatalf("%q: unexpected response %q", d.text, string(ev.Data))
+		default:
+		}
+	}
+}
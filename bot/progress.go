@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/micro/bot/input"
+)
+
+// progresser is implemented by conns which show the progress of a
+// command while it executes. update is called with the lines reported
+// since it was last called, no more often than interval.
+type progresser interface {
+	Progress(ev input.Event) (update func(lines []string), interval time.Duration)
+}
+
+// progress rate limits the progress of a command to the conn, batching
+// the lines reported in the meantime
+type progress struct {
+	sync.Mutex
+	update   func(lines []string)
+	interval time.Duration
+
+	pending []string
+	last    time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+// newProgress returns the progress of the command for ev, nil if the
+// conn doesn't show it
+func newProgress(c input.Conn, ev input.Event) *progress {
+	p, ok := c.(progresser)
+	if !ok {
+		return nil
+	}
+
+	update, interval := p.Progress(ev)
+	if update == nil {
+		return nil
+	}
+
+	return &progress{
+		update:   update,
+		interval: interval,
+	}
+}
+
+// report sends the line now or with the next update
+func (p *progress) report(line string) {
+	p.Lock()
+	defer p.Unlock()
+
+	// the command finished or was abandoned
+	if p.closed {
+		return
+	}
+
+	p.pending = append(p.pending, line)
+
+	wait := p.interval - time.Since(p.last)
+	if wait <= 0 {
+		p.flush()
+		return
+	}
+
+	if p.timer == nil {
+		p.timer = time.AfterFunc(wait, func() {
+			p.Lock()
+			defer p.Unlock()
+			p.timer = nil
+			if !p.closed {
+				p.flush()
+			}
+		})
+	}
+}
+
+// flush sends the pending lines, the lock must be held
+func (p *progress) flush() {
+	if len(p.pending) == 0 {
+		return
+	}
+
+	lines := p.pending
+	p.pending = nil
+	p.last = time.Now()
+
+	p.update(lines)
+}
+
+// close sends the lines still pending ahead of the reply and discards
+// any reported later
+func (p *progress) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+
+	p.flush()
+	p.closed = true
+}
@@ -12,6 +12,10 @@ It has these top-level messages:
 	HelpResponse
 	ExecRequest
 	ExecResponse
+	RegisterRequest
+	RegisterResponse
+	DeregisterRequest
+	DeregisterResponse
 */
 package go_micro_bot
 
@@ -46,6 +50,8 @@ var _ server.Option
 type CommandService interface {
 	Help(ctx context.Context, in *HelpRequest, opts ...client.CallOption) (*HelpResponse, error)
 	Exec(ctx context.Context, in *ExecRequest, opts ...client.CallOption) (*ExecResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...client.CallOption) (*RegisterResponse, error)
+	Deregister(ctx context.Context, in *DeregisterRequest, opts ...client.CallOption) (*DeregisterResponse, error)
 }
 
 type commandService struct {
@@ -86,17 +92,41 @@ func (c *commandService) Exec(ctx context.Context, in *ExecRequest, opts ...clie
 	return out, nil
 }
 
+func (c *commandService) Register(ctx context.Context, in *RegisterRequest, opts ...client.CallOption) (*RegisterResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "Command.Register", in)
+	out := new(RegisterResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commandService) Deregister(ctx context.Context, in *DeregisterRequest, opts ...client.CallOption) (*DeregisterResponse, error) {
+	req := c.c.NewRequest(c.serviceName, "Command.Deregister", in)
+	out := new(DeregisterResponse)
+	err := c.c.Call(ctx, req, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Command service
 
 type CommandHandler interface {
 	Help(context.Context, *HelpRequest, *HelpResponse) error
 	Exec(context.Context, *ExecRequest, *ExecResponse) error
+	Register(context.Context, *RegisterRequest, *RegisterResponse) error
+	Deregister(context.Context, *DeregisterRequest, *DeregisterResponse) error
 }
 
 func RegisterCommandHandler(s server.Server, hdlr CommandHandler, opts ...server.HandlerOption) {
 	type command interface {
 		Help(ctx context.Context, in *HelpRequest, out *HelpResponse) error
 		Exec(ctx context.Context, in *ExecRequest, out *ExecResponse) error
+		Register(ctx context.Context, in *RegisterRequest, out *RegisterResponse) error
+		Deregister(ctx context.Context, in *DeregisterRequest, out *DeregisterResponse) error
 	}
 	type Command struct {
 		command
@@ -116,3 +146,11 @@ func (h *commandHandler) Help(ctx context.Context, in *HelpRequest, out *HelpRes
 func (h *commandHandler) Exec(ctx context.Context, in *ExecRequest, out *ExecResponse) error {
 	return h.CommandHandler.Exec(ctx, in, out)
 }
+
+func (h *commandHandler) Register(ctx context.Context, in *RegisterRequest, out *RegisterResponse) error {
+	return h.CommandHandler.Register(ctx, in, out)
+}
+
+func (h *commandHandler) Deregister(ctx context.Context, in *DeregisterRequest, out *DeregisterResponse) error {
+	return h.CommandHandler.Deregister(ctx, in, out)
+}
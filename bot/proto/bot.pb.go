@@ -12,6 +12,10 @@ It has these top-level messages:
 	HelpResponse
 	ExecRequest
 	ExecResponse
+	RegisterRequest
+	RegisterResponse
+	DeregisterRequest
+	DeregisterResponse
 */
 package go_micro_bot
 
@@ -107,11 +111,95 @@ func (m *ExecResponse) GetError() string {
 	return ""
 }
 
+type RegisterRequest struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Usage       string `protobuf:"bytes,2,opt,name=usage" json:"usage,omitempty"`
+	Description string `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+	Service     string `protobuf:"bytes,4,opt,name=service" json:"service,omitempty"`
+	Endpoint    string `protobuf:"bytes,5,opt,name=endpoint" json:"endpoint,omitempty"`
+}
+
+func (m *RegisterRequest) Reset()                    { *m = RegisterRequest{} }
+func (m *RegisterRequest) String() string            { return proto.CompactTextString(m) }
+func (*RegisterRequest) ProtoMessage()               {}
+func (*RegisterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *RegisterRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetUsage() string {
+	if m != nil {
+		return m.Usage
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetService() string {
+	if m != nil {
+		return m.Service
+	}
+	return ""
+}
+
+func (m *RegisterRequest) GetEndpoint() string {
+	if m != nil {
+		return m.Endpoint
+	}
+	return ""
+}
+
+type RegisterResponse struct {
+}
+
+func (m *RegisterResponse) Reset()                    { *m = RegisterResponse{} }
+func (m *RegisterResponse) String() string            { return proto.CompactTextString(m) }
+func (*RegisterResponse) ProtoMessage()               {}
+func (*RegisterResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type DeregisterRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *DeregisterRequest) Reset()                    { *m = DeregisterRequest{} }
+func (m *DeregisterRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeregisterRequest) ProtoMessage()               {}
+func (*DeregisterRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *DeregisterRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+type DeregisterResponse struct {
+}
+
+func (m *DeregisterResponse) Reset()                    { *m = DeregisterResponse{} }
+func (m *DeregisterResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeregisterResponse) ProtoMessage()               {}
+func (*DeregisterResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
 func init() {
 	proto.RegisterType((*HelpRequest)(nil), "go.micro.bot.HelpRequest")
 	proto.RegisterType((*HelpResponse)(nil), "go.micro.bot.HelpResponse")
 	proto.RegisterType((*ExecRequest)(nil), "go.micro.bot.ExecRequest")
 	proto.RegisterType((*ExecResponse)(nil), "go.micro.bot.ExecResponse")
+	proto.RegisterType((*RegisterRequest)(nil), "go.micro.bot.RegisterRequest")
+	proto.RegisterType((*RegisterResponse)(nil), "go.micro.bot.RegisterResponse")
+	proto.RegisterType((*DeregisterRequest)(nil), "go.micro.bot.DeregisterRequest")
+	proto.RegisterType((*DeregisterResponse)(nil), "go.micro.bot.DeregisterResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -127,6 +215,8 @@ const _ = grpc.SupportPackageIsVersion4
 type CommandClient interface {
 	Help(ctx context.Context, in *HelpRequest, opts ...grpc.CallOption) (*HelpResponse, error)
 	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ExecResponse, error)
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error)
 }
 
 type commandClient struct {
@@ -155,11 +245,31 @@ func (c *commandClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *commandClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := grpc.Invoke(ctx, "/go.micro.bot.Command/Register", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *commandClient) Deregister(ctx context.Context, in *DeregisterRequest, opts ...grpc.CallOption) (*DeregisterResponse, error) {
+	out := new(DeregisterResponse)
+	err := grpc.Invoke(ctx, "/go.micro.bot.Command/Deregister", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Command service
 
 type CommandServer interface {
 	Help(context.Context, *HelpRequest) (*HelpResponse, error)
 	Exec(context.Context, *ExecRequest) (*ExecResponse, error)
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Deregister(context.Context, *DeregisterRequest) (*DeregisterResponse, error)
 }
 
 func RegisterCommandServer(s *grpc.Server, srv CommandServer) {
@@ -202,6 +312,42 @@ func _Command_Exec_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Command_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommandServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/go.micro.bot.Command/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommandServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Command_Deregister_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CommandServer).Deregister(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/go.micro.bot.Command/Deregister",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CommandServer).Deregister(ctx, req.(*DeregisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Command_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "go.micro.bot.Command",
 	HandlerType: (*CommandServer)(nil),
@@ -214,6 +360,14 @@ var _Command_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Exec",
 			Handler:    _Command_Exec_Handler,
 		},
+		{
+			MethodName: "Register",
+			Handler:    _Command_Register_Handler,
+		},
+		{
+			MethodName: "Deregister",
+			Handler:    _Command_Deregister_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "github.com/micro/micro/bot/proto/bot.proto",
@@ -222,21 +376,27 @@ var _Command_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("github.com/micro/micro/bot/proto/bot.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 241 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x50, 0xcb, 0x4a, 0xc4, 0x30,
-	0x14, 0x9d, 0xea, 0x38, 0x32, 0xb7, 0x75, 0x13, 0x44, 0xea, 0xac, 0x6a, 0x56, 0x83, 0x8b, 0x0c,
-	0xe8, 0x56, 0x70, 0x21, 0x8a, 0xeb, 0xfe, 0x41, 0xdb, 0xb9, 0xd4, 0xc2, 0xb4, 0x37, 0xde, 0x24,
-	0xe0, 0x3f, 0xf8, 0xd3, 0x92, 0xc7, 0x22, 0x0c, 0x6e, 0xc2, 0x39, 0x39, 0xe1, 0x3c, 0x02, 0x8f,
-	0xe3, 0x64, 0xbf, 0x5c, 0xaf, 0x06, 0x9a, 0x0f, 0xf3, 0x34, 0x30, 0xa5, 0xb3, 0x27, 0x7b, 0xd0,
-	0x4c, 0x36, 0x20, 0x15, 0x90, 0xa8, 0x46, 0x52, 0x41, 0x55, 0x3d, 0x59, 0x79, 0x03, 0xe5, 0x27,
-	0x9e, 0x74, 0x8b, 0xdf, 0x0e, 0x8d, 0x95, 0x1f, 0x50, 0x45, 0x6a, 0x34, 0x2d, 0x06, 0xc5, 0x2d,
-	0x5c, 0x39, 0xd3, 0x8d, 0x58, 0x17, 0x4d, 0xb1, 0xdf, 0xb6, 0x91, 0x88, 0x06, 0xca, 0x23, 0x9a,
-	0x81, 0x27, 0x6d, 0x27, 0x5a, 0xea, 0x8b, 0xa0, 0xe5, 0x57, 0xf2, 0x01, 0xca, 0xf7, 0x1f, 0x1c,
-	0x92, 0xad, 0x10, 0xb0, 0xee, 0x78, 0x34, 0x75, 0xd1, 0x5c, 0xee, 0xb7, 0x6d, 0xc0, 0xf2, 0x05,
-	0xaa, 0xf8, 0x24, 0x45, 0xdd, 0xc1, 0x86, 0xd1, 0xb8, 0x93, 0x0d, 0x59, 0x55, 0x9b, 0x98, 0xaf,
-	0x80, 0xcc, 0xc4, 0x29, 0x26, 0x92, 0xa7, 0xdf, 0x02, 0xae, 0xdf, 0x68, 0x9e, 0xbb, 0xe5, 0x28,
-	0x5e, 0x61, 0xed, 0x4b, 0x8b, 0x7b, 0x95, 0x4f, 0x53, 0xd9, 0xae, 0xdd, 0xee, 0x3f, 0x29, 0x06,
-	0xcb, 0x95, 0x37, 0xf0, 0x55, 0xce, 0x0d, 0xb2, 0x05, 0xe7, 0x06, 0x79, 0x73, 0xb9, 0xea, 0x37,
-	0xe1, 0x6b, 0x9f, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0xc8, 0x15, 0x59, 0x33, 0x88, 0x01, 0x00,
-	0x00,
+	// 352 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x52, 0xcd, 0x4e, 0xb4, 0x40,
+	0x10, 0xfc, 0xd8, 0xff, 0xed, 0xe5, 0x8b, 0x3a, 0xd9, 0x18, 0x9c, 0x44, 0xc5, 0xb9, 0xb8, 0xf1,
+	0xc0, 0x26, 0x7a, 0x35, 0xf1, 0xe0, 0x4f, 0x4c, 0x3c, 0xc9, 0x1b, 0x00, 0xdb, 0xc1, 0x49, 0x16,
+	0x06, 0x67, 0x06, 0xe3, 0x7b, 0xf8, 0x18, 0xbe, 0xa4, 0x61, 0x80, 0x85, 0xfd, 0xd3, 0x0b, 0xe9,
+	0xa2, 0x9a, 0xea, 0xae, 0x2e, 0xe0, 0x2a, 0xe6, 0xfa, 0x2d, 0x0f, 0xbd, 0x48, 0x24, 0xf3, 0x84,
+	0x47, 0x52, 0x54, 0xcf, 0x50, 0xe8, 0x79, 0x26, 0x85, 0x36, 0x95, 0x67, 0x2a, 0x62, 0xc7, 0xc2,
+	0x33, 0xac, 0x17, 0x0a, 0xcd, 0xfe, 0xc3, 0xe4, 0x19, 0x97, 0x99, 0x8f, 0xef, 0x39, 0x2a, 0xcd,
+	0x9e, 0xc0, 0x2e, 0xa1, 0xca, 0x44, 0xaa, 0x90, 0x4c, 0xa1, 0x9f, 0xab, 0x20, 0x46, 0xc7, 0x72,
+	0xad, 0xd9, 0xd8, 0x2f, 0x01, 0x71, 0x61, 0xb2, 0x40, 0x15, 0x49, 0x9e, 0x69, 0x2e, 0x52, 0xa7,
+	0x63, 0xb8, 0xf6, 0x2b, 0x76, 0x01, 0x93, 0xc7, 0x4f, 0x8c, 0x2a, 0x59, 0x42, 0xa0, 0x17, 0xc8,
+	0x58, 0x39, 0x96, 0xdb, 0x9d, 0x8d, 0x7d, 0x53, 0xb3, 0x5b, 0xb0, 0xcb, 0x96, 0x6a, 0xd4, 0x31,
+	0x0c, 0x24, 0xaa, 0x7c, 0xa9, 0xcd, 0x2c, 0xdb, 0xaf, 0x50, 0xb1, 0x02, 0x4a, 0x29, 0x64, 0x35,
+	0xa6, 0x04, 0xec, 0xcb, 0x82, 0x03, 0x1f, 0x63, 0xae, 0x34, 0xca, 0xd6, 0x94, 0x34, 0x48, 0xea,
+	0x5d, 0x4d, 0xdd, 0x18, 0xe8, 0xfc, 0x62, 0xa0, 0xbb, 0x65, 0x80, 0x38, 0x30, 0x54, 0x28, 0x3f,
+	0x78, 0x84, 0x4e, 0xcf, 0xb0, 0x35, 0x24, 0x14, 0x46, 0x98, 0x2e, 0x32, 0xc1, 0x53, 0xed, 0xf4,
+	0x0d, 0xb5, 0xc2, 0x8c, 0xc0, 0x61, 0xb3, 0x54, 0xe9, 0x8b, 0x5d, 0xc2, 0xd1, 0x03, 0xca, 0xbf,
+	0x57, 0x65, 0x53, 0x20, 0xed, 0xc6, 0xf2, 0xf3, 0xeb, 0xef, 0x0e, 0x0c, 0xef, 0x45, 0x92, 0x04,
+	0xe9, 0x82, 0xdc, 0x41, 0xaf, 0x48, 0x87, 0x9c, 0x78, 0xed, 0x0c, 0xbd, 0x56, 0x80, 0x94, 0xee,
+	0xa2, 0xaa, 0x4d, 0xfe, 0x15, 0x02, 0xc5, 0xcd, 0x37, 0x05, 0x5a, 0x51, 0x51, 0xba, 0x8b, 0x5a,
+	0x09, 0xbc, 0xc0, 0xa8, 0x36, 0x48, 0x4e, 0xd7, 0x3b, 0x37, 0xd2, 0xa0, 0x67, 0xfb, 0xe8, 0x95,
+	0xd8, 0x2b, 0x40, 0x63, 0x98, 0x9c, 0xaf, 0xf7, 0x6f, 0xdd, 0x8c, 0xba, 0xfb, 0x1b, 0x6a, 0xc9,
+	0x70, 0x60, 0xfe, 0xf1, 0x9b, 0x9f, 0x01, 0x00, 0x9c, 0x1f, 0x46, 0xef, 0x11, 0x03, 0x00, 0x00,
 }
@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/i18n"
+)
+
+var (
+	// RateLimitQuiet is how long further commands over the limit are
+	// dropped without a reply after the user was told to slow down
+	RateLimitQuiet = 10 * time.Second
+	// how often idle buckets are removed
+	rateLimitSweep = time.Minute
+)
+
+// rate is the most commands run in a period
+type rate struct {
+	n   int
+	per time.Duration
+}
+
+// parseRate parses a rate such as "5/m", "5/1m" or "1/5m"
+func parseRate(s string) (rate, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return rate{}, fmt.Errorf("rate %q must be of the form <n>/<period> e.g. 5/1m", s)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 {
+		return rate{}, fmt.Errorf("rate %q must allow at least one command", s)
+	}
+
+	period := strings.TrimSpace(parts[1])
+	if len(period) > 0 && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return rate{}, fmt.Errorf("rate %q has an invalid period", s)
+	}
+
+	return rate{n, per}, nil
+}
+
+// bucket holds the commands a user may still run, refilled over time
+type bucket struct {
+	rate   rate
+	tokens float64
+	last   time.Time
+	// commands are dropped without a reply until then
+	quiet time.Time
+}
+
+// refill adds the tokens earned since the bucket was last used
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += float64(b.rate.n) * float64(elapsed) / float64(b.rate.per)
+		if b.tokens > float64(b.rate.n) {
+			b.tokens = float64(b.rate.n)
+		}
+		b.last = now
+	}
+}
+
+// rateLimiter limits the commands each user runs, globally or by the
+// rate of the command if it has its own
+type rateLimiter struct {
+	global   rate
+	commands map[string]rate
+	now      func() time.Time
+
+	sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+func newRateLimiter(global rate, commands map[string]rate) *rateLimiter {
+	return &rateLimiter{
+		global:   global,
+		commands: commands,
+		now:      time.Now,
+		buckets:  make(map[string]*bucket),
+	}
+}
+
+// loadRateLimiter returns the limiter of the rate_limit and
+// rate_limit_commands flags, nil if neither limits commands
+func loadRateLimiter(ctx *cli.Context) (*rateLimiter, error) {
+	var global rate
+
+	if s := ctx.String("rate_limit"); len(s) > 0 {
+		r, err := parseRate(s)
+		if err != nil {
+			return nil, err
+		}
+		global = r
+	}
+
+	commands := make(map[string]rate)
+
+	for _, s := range strings.Split(ctx.String("rate_limit_commands"), ",") {
+		if len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(s, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("command rate %q must be of the form <command>=<n>/<period>", s)
+		}
+
+		r, err := parseRate(parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		// commands are limited by their normalized namespaced name
+		commands[strings.ToLower(strings.Join(strings.Fields(parts[0]), " "))] = r
+	}
+
+	if global.n == 0 && len(commands) == 0 {
+		return nil, nil
+	}
+
+	return newRateLimiter(global, commands), nil
+}
+
+// take uses up one of the commands the user may run. If none are left
+// it returns how long until one is and whether to tell the user, which
+// is only done once in the quiet period.
+func (r *rateLimiter) take(user, name string) (bool, time.Duration, bool) {
+	if r == nil {
+		return true, 0, false
+	}
+
+	rt, key := r.global, user
+	if c, ok := r.commands[name]; ok {
+		rt, key = c, user+"\x00"+name
+	}
+
+	// no limit
+	if rt.n == 0 {
+		return true, 0, false
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.now()
+	r.sweep(now)
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{rate: rt, tokens: float64(rt.n), last: now}
+		r.buckets[key] = b
+	}
+
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, false
+	}
+
+	// rounded since the tokens are fractional
+	wait := time.Duration((1 - b.tokens) * float64(rt.per) / float64(rt.n)).Round(time.Millisecond)
+
+	if now.Before(b.quiet) {
+		return false, wait, false
+	}
+
+	quiet := RateLimitQuiet
+	if wait < quiet {
+		quiet = wait
+	}
+	b.quiet = now.Add(quiet)
+
+	return false, wait, true
+}
+
+// sweep removes the buckets which have refilled since they're the same
+// as new ones, the lock must be held
+func (r *rateLimiter) sweep(now time.Time) {
+	if now.Sub(r.swept) < rateLimitSweep {
+		return
+	}
+	r.swept = now
+
+	for key, b := range r.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.n) && !now.Before(b.quiet) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// slowDown returns the reply to a user over the rate limit
+func slowDown(o origin, wait time.Duration) []byte {
+	// round up so the user isn't told to try again in 0s
+	wait = (wait + time.Second - 1).Truncate(time.Second)
+	return o.say(i18n.SlowDown, i18n.Params{"wait": wait})
+}
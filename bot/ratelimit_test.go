@@ -0,0 +1,264 @@
+package bot
+
+import (
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// fakeClock is advanced by the test
+type fakeClock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	f.Lock()
+	defer f.Unlock()
+	return f.t
+}
+
+func (f *fakeClock) advance(d time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+	f.t = f.t.Add(d)
+}
+
+func TestParseRate(t *testing.T) {
+	testData := []struct {
+		s   string
+		n   int
+		per time.Duration
+		err bool
+	}{
+		{"5/1m", 5, time.Minute, false},
+		{"5/m", 5, time.Minute, false},
+		{"1/5m", 1, 5 * time.Minute, false},
+		{" 10 / 30s ", 10, 30 * time.Second, false},
+		{"5", 0, 0, true},
+		{"0/1m", 0, 0, true},
+		{"five/1m", 0, 0, true},
+		{"5/forever", 0, 0, true},
+		{"5/-1m", 0, 0, true},
+	}
+
+	for _, d := range testData {
+		r, err := parseRate(d.s)
+		if (err != nil) != d.err {
+			t.Fatalf("%q: expected error %t got %v", d.s, d.err, err)
+		}
+		if !d.err && (r.n != d.n || r.per != d.per) {
+			t.Fatalf("%q: expected %d/%v got %d/%v", d.s, d.n, d.per, r.n, r.per)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+
+	r := newRateLimiter(rate{5, time.Minute}, map[string]rate{"deploy": {1, 5 * time.Minute}})
+	r.now = clock.now
+
+	// the first five in a minute are allowed
+	for i := 0; i < 5; i++ {
+		if ok, _, _ := r.take("slack:U1", "echo"); !ok {
+			t.Fatalf("expected command %d to be allowed", i+1)
+		}
+	}
+
+	// the user is told once when to try again
+	ok, wait, reply := r.take("slack:U1", "echo")
+	if ok || !reply || wait != 12*time.Second {
+		t.Fatalf("expected a reply to wait 12s got %t %v %t", ok, wait, reply)
+	}
+	if s := string(slowDown(origin{}, wait)); s != "slow down, try again in 12s" {
+		t.Fatalf("unexpected reply %q", s)
+	}
+
+	// further attempts are dropped quietly
+	clock.advance(5 * time.Second)
+	if ok, wait, reply := r.take("slack:U1", "ping"); ok || reply || wait != 7*time.Second {
+		t.Fatalf("expected a quiet drop got %t %v %t", ok, wait, reply)
+	}
+
+	// other users have their own limit
+	if ok, _, _ := r.take("slack:U2", "echo"); !ok {
+		t.Fatal("expected another user to be allowed")
+	}
+
+	// a command is earned back every 12s
+	clock.advance(7 * time.Second)
+	if ok, _, _ := r.take("slack:U1", "echo"); !ok {
+		t.Fatal("expected a command to be allowed after waiting")
+	}
+	if ok, _, reply := r.take("slack:U1", "echo"); ok || !reply {
+		t.Fatal("expected a reply once the quiet period passed")
+	}
+
+	// commands with their own rate don't use up the global one
+	if ok, _, _ := r.take("slack:U2", "deploy"); !ok {
+		t.Fatal("expected deploy to be allowed")
+	}
+	ok, wait, reply = r.take("slack:U2", "deploy")
+	if ok || !reply || wait != 5*time.Minute {
+		t.Fatalf("expected a reply to wait 5m got %t %v %t", ok, wait, reply)
+	}
+	if s := string(slowDown(origin{}, wait-500*time.Millisecond)); s != "slow down, try again in 5m0s" {
+		t.Fatalf("unexpected reply %q", s)
+	}
+	for i := 0; i < 4; i++ {
+		if ok, _, _ := r.take("slack:U2", "echo"); !ok {
+			t.Fatalf("expected echo %d to be allowed", i+1)
+		}
+	}
+
+	// a long wait is only quiet for a while
+	clock.advance(RateLimitQuiet)
+	if ok, _, reply := r.take("slack:U2", "deploy"); ok || !reply {
+		t.Fatal("expected a reply after the quiet period")
+	}
+
+	// without limits anything goes
+	var unlimited *rateLimiter
+	if ok, _, _ := unlimited.take("slack:U1", "echo"); !ok {
+		t.Fatal("expected no limit")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+
+	r := newRateLimiter(rate{2, time.Minute}, nil)
+	r.now = clock.now
+
+	r.take("slack:U1", "echo")
+	r.take("slack:U2", "echo")
+	r.take("slack:U2", "echo")
+	r.take("slack:U2", "echo")
+
+	if len(r.buckets) != 2 {
+		t.Fatalf("expected 2 buckets got %d", len(r.buckets))
+	}
+
+	// U1 has refilled, U2 hasn't
+	clock.advance(rateLimitSweep)
+	r.take("slack:U3", "echo")
+	r.take("slack:U2", "echo")
+
+	r.Lock()
+	_, u1 := r.buckets["slack:U1"]
+	_, u2 := r.buckets["slack:U2"]
+	r.Unlock()
+
+	if u1 || !u2 {
+		t.Fatalf("expected only the idle bucket removed got %v", r.buckets)
+	}
+
+	// everything idle is removed eventually
+	clock.advance(2 * rateLimitSweep)
+	r.take("slack:U4", "echo")
+	if len(r.buckets) != 1 {
+		t.Fatalf("expected 1 bucket got %d", len(r.buckets))
+	}
+}
+
+func TestRateLimiterConcurrent(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+
+	r := newRateLimiter(rate{50, time.Hour}, nil)
+	r.now = clock.now
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if ok, _, _ := r.take("slack:U1", "echo"); ok {
+					mtx.Lock()
+					allowed++
+					mtx.Unlock()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if allowed != 50 {
+		t.Fatalf("expected 50 commands allowed got %d", allowed)
+	}
+}
+
+func TestProcessRateLimit(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("rate_limit", "2/1h", "")
+	flagSet.String("rate_limit_commands", "deploy=1/1h", "")
+	app := cli.NewApp()
+	ctx := cli.NewContext(app, flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 1),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	commands := map[string]command.Command{
+		"^deploy ": command.NewCommand("deploy", "deploy [service]", "deploys", func(args ...string) ([]byte, error) {
+			return []byte("deployed"), nil
+		}),
+		"^echo ": command.NewCommand("echo", "echo [text]", "echoes", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, nil, commands, service)
+	c := &serialConn{Conn: io, input: "slack"}
+
+	testData := []struct {
+		from   string
+		text   string
+		expect string
+	}{
+		{"C1:U1", "echo one", "one"},
+		{"C1:U1", "deploy api", "deployed"},
+		{"C1:U1", "echo two", "two"},
+		{"C1:U1", "echo three", "slow down, try again in 30m0s"},
+		{"C1:U1", "echo four", ""},
+		{"C1:U1", "deploy api", "slow down, try again in 1h0m0s"},
+		{"C2:U1", "deploy web", ""},
+		{"C1:U2", "echo one", "one"},
+	}
+
+	for _, d := range testData {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: d.from, Data: []byte(d.text)}); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case ev := <-io.send:
+			if len(d.expect) == 0 || !strings.HasPrefix(string(ev.Data), d.expect) {
+				t.Fatalf("%s %q: expected %q got %q", d.from, d.text, d.expect, string(ev.Data))
+			}
+		default:
+			if len(d.expect) > 0 {
+				t.Fatalf("%s %q: expected a response", d.from, d.text)
+			}
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/micro/go-log"
+	merrors "github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	proto "github.com/micro/micro/bot/proto"
+)
+
+// DefaultCommandEndpoint is the endpoint executing a command registered
+// without one. It's passed the args as services advertising commands
+// are.
+var DefaultCommandEndpoint = "Command.Exec"
+
+// unavailableError is returned when the service which registered a
+// command isn't running
+type unavailableError struct {
+	name string
+}
+
+func (u unavailableError) Error() string {
+	return fmt.Sprintf("command '%s' temporarily unavailable", u.name)
+}
+
+// registration is a command a service registered, executed by calling
+// its endpoint
+type registration struct {
+	name     string
+	service  string
+	endpoint string
+	pattern  string
+	cmd      command.Command
+	// the service isn't registered so the command isn't executed
+	suspended bool
+}
+
+// registrations are the commands services registered by name
+type registrations struct {
+	sync.RWMutex
+	byName map[string]*registration
+}
+
+func newRegistrations() *registrations {
+	return &registrations{
+		byName: make(map[string]*registration),
+	}
+}
+
+// add registers the command unless another service registered it. The
+// service registering it again replaces it.
+func (r *registrations) add(reg *registration) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if old, ok := r.byName[reg.name]; ok && old.service != reg.service {
+		return fmt.Errorf("command %s is registered by %s", reg.name, old.service)
+	}
+	r.byName[reg.name] = reg
+	return nil
+}
+
+func (r *registrations) remove(name string) (*registration, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	reg, ok := r.byName[name]
+	delete(r.byName, name)
+	return reg, ok
+}
+
+// commands returns the commands registered by pattern
+func (r *registrations) commands() map[string]command.Command {
+	r.RLock()
+	defer r.RUnlock()
+
+	commands := make(map[string]command.Command, len(r.byName))
+	for _, reg := range r.byName {
+		commands[reg.pattern] = reg.cmd
+	}
+	return commands
+}
+
+func (r *registrations) suspended(reg *registration) bool {
+	r.RLock()
+	defer r.RUnlock()
+	return reg.suspended
+}
+
+// check suspends the commands of the services which aren't running and
+// resumes those of the services back
+func (r *registrations) check(running func(service string) bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, reg := range r.byName {
+		suspended := !running(reg.service)
+		if suspended == reg.suspended {
+			continue
+		}
+		reg.suspended = suspended
+
+		if suspended {
+			log.Logf("[bot] suspending command %s, %s isn't running", reg.name, reg.service)
+		} else {
+			log.Logf("[bot] resuming command %s, %s is running", reg.name, reg.service)
+		}
+	}
+}
+
+// running returns whether the service has nodes registered. It's taken
+// to be if the registry can't be queried.
+func (b *bot) running(service string) bool {
+	reg := b.service.Client().Options().Registry
+
+	var versions []*registry.Service
+	err := queryRegistry(context.Background(), reg, func() error {
+		var err error
+		versions, err = reg.GetService(service)
+		return err
+	})
+	if err == registry.ErrNotFound {
+		return false
+	}
+	if err != nil {
+		log.Logf("[bot] error getting service %s: %v", service, err)
+		return true
+	}
+
+	for _, v := range versions {
+		if len(v.Nodes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newRegistration returns the command of the request, executed by
+// calling the endpoint of the service with its args
+func (b *bot) newRegistration(req *proto.RegisterRequest) (*registration, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if len(name) == 0 {
+		return nil, errors.New("missing command name")
+	}
+	if strings.ContainsAny(name, " \t\n") {
+		return nil, fmt.Errorf("command name %q must be a single word", req.Name)
+	}
+	if len(req.Service) == 0 {
+		return nil, errors.New("missing service")
+	}
+
+	usage := strings.TrimSpace(req.Usage)
+	if len(usage) == 0 {
+		usage = name
+	}
+	uname, args := parseUsage(usage)
+	if strings.ToLower(uname) != name {
+		return nil, fmt.Errorf("usage %q must start with the command name %s", usage, name)
+	}
+
+	r := &registration{
+		name:     name,
+		service:  req.Service,
+		endpoint: req.Endpoint,
+		pattern:  "^" + regexp.QuoteMeta(name) + "( |$)",
+	}
+	if len(r.endpoint) == 0 {
+		r.endpoint = DefaultCommandEndpoint
+	}
+
+	desc := req.Description
+	if len(desc) == 0 {
+		desc = fmt.Sprintf("Calls %s %s", r.service, r.endpoint)
+	}
+
+	c := b.service.Client()
+
+	exec := func(ctx context.Context, args ...string) ([]byte, error) {
+		if b.remote.suspended(r) {
+			return nil, unavailableError{name}
+		}
+
+		rsp := &proto.ExecResponse{}
+		req := c.NewRequest(r.service, r.endpoint, &proto.ExecRequest{Args: args})
+		if err := c.Call(ctx, req, rsp); err != nil {
+			// timed out or cancelled rather than the call failing
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, callError(r.service, r.endpoint, b.commandTimeout(name), err)
+		}
+		if len(rsp.Error) > 0 {
+			return nil, errors.New(rsp.Error)
+		}
+		return rsp.Result, nil
+	}
+
+	if len(args) > 0 {
+		r.cmd = command.NewContextCommandWithArgs(name, desc, args, exec)
+	} else {
+		r.cmd = command.NewContextCommand(name, usage, desc, exec)
+	}
+	r.cmd = command.Wrap(r.cmd)
+
+	return r, nil
+}
+
+// rpcHandler is the Command handler of the bot's service, executing
+// the bot's commands for services and registering theirs
+type rpcHandler struct {
+	bot *bot
+}
+
+func (h *rpcHandler) Help(ctx context.Context, req *proto.HelpRequest, rsp *proto.HelpResponse) error {
+	rsp.Usage = Name
+	rsp.Description = "Executes the commands of the bot, which services register theirs with"
+	return nil
+}
+
+// Exec runs the command of the args as if sent by an unknown user of
+// the rpc input, replying with its response or why it failed
+func (h *rpcHandler) Exec(ctx context.Context, req *proto.ExecRequest, rsp *proto.ExecResponse) error {
+	if len(req.Args) == 0 {
+		return merrors.BadRequest(Name, "missing command")
+	}
+
+	p := &pipeConn{}
+	c := &serialConn{Conn: p, input: "rpc"}
+	ev := input.Event{
+		Type: input.TextEvent,
+		Meta: map[string]interface{}{},
+		Data: []byte(strings.Join(req.Args, " ")),
+	}
+
+	if err := h.bot.runCommand(c, ev, append([]string{}, req.Args...)); err != nil {
+		return merrors.InternalServerError(Name, "%v", err)
+	}
+
+	if !p.executed || p.err != nil {
+		rsp.Error = p.data
+		return nil
+	}
+
+	rsp.Result = []byte(p.data)
+	return nil
+}
+
+func (h *rpcHandler) Register(ctx context.Context, req *proto.RegisterRequest, rsp *proto.RegisterResponse) error {
+	b := h.bot
+
+	r, err := b.newRegistration(req)
+	if err != nil {
+		return merrors.BadRequest(Name, "%v", err)
+	}
+
+	// built in commands can't be replaced
+	b.RLock()
+	for _, cmd := range b.static {
+		if strings.ToLower(command.FullName(cmd)) == r.name {
+			b.RUnlock()
+			return merrors.Conflict(Name, "command %s is built in", r.name)
+		}
+	}
+	b.RUnlock()
+
+	r.suspended = !b.running(r.service)
+
+	if err := b.remote.add(r); err != nil {
+		return merrors.Conflict(Name, "%v", err)
+	}
+
+	b.Lock()
+	b.rebuild()
+	b.Unlock()
+
+	log.Logf("[bot] %s registered command %s executed by %s", r.service, r.name, r.endpoint)
+	return nil
+}
+
+func (h *rpcHandler) Deregister(ctx context.Context, req *proto.DeregisterRequest, rsp *proto.DeregisterResponse) error {
+	b := h.bot
+
+	r, ok := b.remote.remove(strings.ToLower(strings.TrimSpace(req.Name)))
+	if !ok {
+		return merrors.NotFound(Name, "command %s isn't registered", req.Name)
+	}
+
+	b.Lock()
+	b.rebuild()
+	b.Unlock()
+
+	log.Logf("[bot] %s deregistered command %s", r.service, r.name)
+	return nil
+}
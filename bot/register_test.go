@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	proto "github.com/micro/micro/bot/proto"
+)
+
+// execClient answers calls to Uptime.Exec of the uptime service
+type execClient struct {
+	client.Client
+
+	sync.Mutex
+	args []string
+}
+
+func (c *execClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if req.Service() != "go.micro.srv.uptime" || req.Method() != "Uptime.Exec" {
+		return errors.InternalServerError("go.micro.client", "service %s: not found", req.Service())
+	}
+
+	args := req.Body().(*proto.ExecRequest).Args
+
+	c.Lock()
+	c.args = args
+	c.Unlock()
+
+	out := rsp.(*proto.ExecResponse)
+	if len(args) > 1 && args[1] == "fail" {
+		out.Error = "uptime unknown"
+		return nil
+	}
+	out.Result = []byte("up 5m")
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	reg := memory.NewRegistry()
+	uptime := &registry.Service{Name: "go.micro.srv.uptime", Version: "latest", Nodes: []*registry.Node{{Id: "uptime-1"}}}
+	reg.Register(uptime)
+
+	c := &execClient{Client: client.NewClient(client.Registry(reg))}
+	service := micro.NewService(
+		micro.Registry(reg),
+		micro.Client(c),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, map[string]command.Command{}, service)
+	conn := &serialConn{Conn: io, input: "test"}
+	h := &rpcHandler{bot}
+
+	send := func(text string) string {
+		if err := bot.process(conn, input.Event{Type: input.TextEvent, From: "C0:U1", Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	register := func(req *proto.RegisterRequest) error {
+		return h.Register(context.Background(), req, &proto.RegisterResponse{})
+	}
+
+	for _, d := range []struct {
+		req *proto.RegisterRequest
+		err string
+	}{
+		{&proto.RegisterRequest{Service: "go.micro.srv.uptime"}, "missing command name"},
+		{&proto.RegisterRequest{Name: "up time", Service: "go.micro.srv.uptime"}, `command name "up time" must be a single word`},
+		{&proto.RegisterRequest{Name: "uptime"}, "missing service"},
+		{&proto.RegisterRequest{Name: "uptime", Usage: "up [seconds]", Service: "go.micro.srv.uptime"}, `usage "up [seconds]" must start with the command name uptime`},
+		{&proto.RegisterRequest{Name: "help", Service: "go.micro.srv.uptime"}, "command help is built in"},
+	} {
+		if err := register(d.req); err == nil || errors.Parse(err.Error()).Detail != d.err {
+			t.Fatalf("%+v: expected error %q got %v", d.req, d.err, err)
+		}
+	}
+
+	err := register(&proto.RegisterRequest{
+		Name:        "Uptime",
+		Usage:       "uptime <format>",
+		Description: "returns the uptime",
+		Service:     "go.micro.srv.uptime",
+		Endpoint:    "Uptime.Exec",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same command from another service conflicts
+	err = register(&proto.RegisterRequest{Name: "uptime", Service: "go.micro.srv.other"})
+	if e := errors.Parse(err.Error()); e.Code != 409 || e.Detail != "command uptime is registered by go.micro.srv.uptime" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// the service registers it again as it restarts
+	err = register(&proto.RegisterRequest{
+		Name:        "uptime",
+		Usage:       "uptime [format]",
+		Description: "returns the uptime",
+		Service:     "go.micro.srv.uptime",
+		Endpoint:    "Uptime.Exec",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rsp := send("uptime human"); rsp != "up 5m" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if strings.Join(c.args, " ") != "uptime human" {
+		t.Fatalf("unexpected args %v", c.args)
+	}
+	if rsp := send("uptime fail"); rsp != "error executing cmd: uptime unknown" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("help uptime"); !strings.Contains(rsp, "uptime [format]") {
+		t.Fatalf("expected the command in help got %q", rsp)
+	}
+
+	// services execute the bot's commands too
+	for _, d := range []struct {
+		args   []string
+		result string
+		err    string
+	}{
+		{[]string{"uptime"}, "up 5m", ""},
+		{[]string{"uptime", "fail"}, "", "error executing cmd: uptime unknown"},
+		{[]string{"uptme"}, "", "unknown command 'uptme', did you mean uptime? run help for a list of commands"},
+	} {
+		rsp := &proto.ExecResponse{}
+		if err := h.Exec(context.Background(), &proto.ExecRequest{Args: d.args}, rsp); err != nil {
+			t.Fatal(err)
+		}
+		if string(rsp.Result) != d.result || rsp.Error != d.err {
+			t.Fatalf("%v: unexpected response %q %q", d.args, rsp.Result, rsp.Error)
+		}
+	}
+
+	// the command is suspended while the service is gone
+	go bot.watch()
+	defer close(bot.exit)
+
+	waitFor := func(text, expect string) {
+		var rsp string
+		for i := 0; i < 100; i++ {
+			if rsp = send(text); rsp == expect {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("%q: expected %q got %q", text, expect, rsp)
+	}
+
+	reg.Deregister(uptime)
+	waitFor("uptime", "command 'uptime' temporarily unavailable")
+
+	reg.Register(uptime)
+	waitFor("uptime", "up 5m")
+
+	deregister := func(name string) error {
+		return h.Deregister(context.Background(), &proto.DeregisterRequest{Name: name}, &proto.DeregisterResponse{})
+	}
+
+	if err := deregister("uptime"); err != nil {
+		t.Fatal(err)
+	}
+	if rsp := send("uptime"); rsp != "unknown command 'uptime', run help for a list of commands" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if err := deregister("uptime"); err == nil || errors.Parse(err.Error()).Code != 404 {
+		t.Fatalf("expected not found got %v", err)
+	}
+}
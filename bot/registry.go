@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/micro/bot/command"
+)
+
+const (
+	// servicesPattern matches services alone or followed by a page
+	servicesPattern = "^services( |$)"
+	// servicePattern matches service followed by its name
+	servicePattern = "^service "
+)
+
+var (
+	// ServicesPageSize is the most services listed on a page
+	ServicesPageSize = 50
+	// RegistryTimeout is how long the registry commands wait for the
+	// registry to answer
+	RegistryTimeout = 5 * time.Second
+)
+
+// queryRegistry runs the query, giving up on a registry which doesn't
+// answer in time or once the command is cancelled
+func queryRegistry(ctx context.Context, reg registry.Registry, query func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- query()
+	}()
+
+	t := time.NewTimer(RegistryTimeout)
+	defer t.Stop()
+
+	select {
+	case err := <-done:
+		if err == registry.ErrNotFound {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("registry %s unavailable: %v", reg.String(), err)
+		}
+		return nil
+	case <-t.C:
+		return fmt.Errorf("registry %s didn't answer within %v", reg.String(), RegistryTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// table aligns the cells of the rows in columns
+func table(rows [][]string) string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		var line string
+		for i, cell := range row {
+			line += cell + strings.Repeat(" ", widths[i]-len(cell)+2)
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// servicesCommand lists the services registered in the registry
+func servicesCommand(reg registry.Registry) command.Command {
+	usage := "services [page]"
+	desc := "Lists the services registered right now"
+
+	return command.NewContextCommand("services", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		page := 1
+		switch len(args) {
+		case 1:
+		case 2:
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid page %q", args[1])
+			}
+			page = n
+		default:
+			return nil, errors.New("usage: " + usage)
+		}
+
+		var services []*registry.Service
+		err := queryRegistry(ctx, reg, func() error {
+			var err error
+			services, err = reg.ListServices()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// services are listed once per version
+		seen := make(map[string]bool)
+		var names []string
+		for _, s := range services {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				names = append(names, s.Name)
+			}
+		}
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return []byte("no services registered"), nil
+		}
+
+		size := ServicesPageSize
+		if size <= 0 {
+			size = len(names)
+		}
+		pages := (len(names) + size - 1) / size
+
+		if page < 1 || page > pages {
+			return []byte(fmt.Sprintf("no page %d of services, there are %d", page, pages)), nil
+		}
+
+		text := fmt.Sprintf("%d services registered", len(names))
+		if pages > 1 {
+			text += fmt.Sprintf(" (page %d of %d, run services [page] for more)", page, pages)
+		}
+
+		end := page * size
+		if end > len(names) {
+			end = len(names)
+		}
+
+		return command.Reply(ctx, &command.Response{
+			Text: text,
+			Code: strings.Join(names[(page-1)*size:end], "\n"),
+		}), nil
+	})
+}
+
+// serviceCommand describes a service registered in the registry
+func serviceCommand(reg registry.Registry) command.Command {
+	usage := "service <name>"
+	desc := "Returns the versions, nodes and endpoints of a registered service"
+	args := []command.Arg{
+		{Name: "name", Required: true, Description: "name of the service e.g. go.micro.srv.greeter"},
+	}
+
+	return command.NewContextCommandWithArgs("service", desc, args, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) != 2 {
+			return nil, errors.New("usage: " + usage)
+		}
+		name := args[1]
+
+		var versions []*registry.Service
+		err := queryRegistry(ctx, reg, func() error {
+			var err error
+			versions, err = reg.GetService(name)
+			return err
+		})
+		if err == registry.ErrNotFound || (err == nil && len(versions) == 0) {
+			return nil, fmt.Errorf("service %s not found", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Version < versions[j].Version
+		})
+
+		var vs []string
+		seen := make(map[string]bool)
+		var endpoints []string
+		rows := [][]string{{"VERSION", "NODE", "ADDRESS", "PORT"}}
+
+		for _, s := range versions {
+			vs = append(vs, s.Version)
+
+			for _, e := range s.Endpoints {
+				if !seen[e.Name] {
+					seen[e.Name] = true
+					endpoints = append(endpoints, e.Name)
+				}
+			}
+
+			nodes := append([]*registry.Node(nil), s.Nodes...)
+			sort.Slice(nodes, func(i, j int) bool {
+				return nodes[i].Id < nodes[j].Id
+			})
+			for _, n := range nodes {
+				rows = append(rows, []string{s.Version, n.Id, n.Address, strconv.Itoa(n.Port)})
+			}
+		}
+		sort.Strings(endpoints)
+
+		if len(endpoints) == 0 {
+			endpoints = []string{"none"}
+		}
+
+		rsp := &command.Response{
+			Text: name,
+			Fields: []command.Field{
+				{Key: "versions", Value: strings.Join(vs, ", ")},
+				{Key: "endpoints", Value: strings.Join(endpoints, "\n")},
+			},
+			Code: "no nodes",
+		}
+		if len(rows) > 1 {
+			rsp.Code = table(rows)
+		}
+
+		return command.Reply(ctx, rsp), nil
+	})
+}
@@ -0,0 +1,209 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+)
+
+// brokenRegistry fails or hangs listing and getting services
+type brokenRegistry struct {
+	registry.Registry
+	err  error
+	hang chan bool
+}
+
+func (r *brokenRegistry) query() error {
+	if r.hang != nil {
+		<-r.hang
+	}
+	return r.err
+}
+
+func (r *brokenRegistry) ListServices() ([]*registry.Service, error) {
+	return nil, r.query()
+}
+
+func (r *brokenRegistry) GetService(string) ([]*registry.Service, error) {
+	return nil, r.query()
+}
+
+func (r *brokenRegistry) String() string {
+	return "consul"
+}
+
+// execRegistry executes the command returning its response as text
+// and the rich response
+func execRegistry(cmd command.Command, args ...string) (string, *command.Response, error) {
+	ctx, rich := command.Capture(context.Background())
+	rsp, err := command.ExecContext(ctx, cmd, args...)
+	return string(rsp), rich(), err
+}
+
+func TestServicesCommand(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	services := servicesCommand(reg)
+
+	if rsp, _, err := execRegistry(services, "services"); err != nil || rsp != "no services registered" {
+		t.Fatalf("unexpected response %q %v", rsp, err)
+	}
+
+	for _, s := range []*registry.Service{
+		{Name: "go.micro.srv.greeter", Version: "1.0.0"},
+		{Name: "go.micro.srv.greeter", Version: "1.1.0"},
+		{Name: "go.micro.api.auth", Version: "latest"},
+		{Name: "go.micro.srv.auth", Version: "latest"},
+	} {
+		reg.Register(s)
+	}
+
+	rsp, rich, err := execRegistry(services, "services")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "3 services registered\ngo.micro.api.auth\ngo.micro.srv.auth\ngo.micro.srv.greeter"; rsp != expect {
+		t.Fatalf("expected %q got %q", expect, rsp)
+	}
+	if rich == nil || rich.Code != "go.micro.api.auth\ngo.micro.srv.auth\ngo.micro.srv.greeter" {
+		t.Fatalf("expected the services in a code block got %+v", rich)
+	}
+
+	size := ServicesPageSize
+	ServicesPageSize = 2
+	defer func() { ServicesPageSize = size }()
+
+	testData := []struct {
+		args   []string
+		expect string
+		err    string
+	}{
+		{[]string{"services"}, "3 services registered (page 1 of 2, run services [page] for more)\ngo.micro.api.auth\ngo.micro.srv.auth", ""},
+		{[]string{"services", "2"}, "3 services registered (page 2 of 2, run services [page] for more)\ngo.micro.srv.greeter", ""},
+		{[]string{"services", "3"}, "no page 3 of services, there are 2", ""},
+		{[]string{"services", "next"}, "", `invalid page "next"`},
+		{[]string{"services", "1", "2"}, "", "usage: services [page]"},
+	}
+
+	for _, d := range testData {
+		rsp, _, err := execRegistry(services, d.args...)
+		if len(d.err) > 0 {
+			if err == nil || err.Error() != d.err {
+				t.Fatalf("%q: expected error %q got %v", d.args, d.err, err)
+			}
+			continue
+		}
+		if err != nil || rsp != d.expect {
+			t.Fatalf("%q: expected %q got %q %v", d.args, d.expect, rsp, err)
+		}
+	}
+}
+
+func TestServiceCommand(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	reg.Register(&registry.Service{
+		Name:      "go.micro.srv.greeter",
+		Version:   "1.1.0",
+		Endpoints: []*registry.Endpoint{{Name: "Say.Hello"}, {Name: "Say.Stream"}},
+		Nodes: []*registry.Node{
+			{Id: "greeter-2", Address: "10.0.0.12", Port: 8080},
+		},
+	})
+	reg.Register(&registry.Service{
+		Name:      "go.micro.srv.greeter",
+		Version:   "1.0.0",
+		Endpoints: []*registry.Endpoint{{Name: "Say.Hello"}},
+		Nodes: []*registry.Node{
+			{Id: "greeter-1b", Address: "10.0.0.11", Port: 9090},
+			{Id: "greeter-1a", Address: "10.0.0.10", Port: 9090},
+		},
+	})
+	reg.Register(&registry.Service{Name: "go.micro.srv.idle", Version: "latest"})
+
+	service := serviceCommand(reg)
+
+	rsp, rich, err := execRegistry(service, "service", "go.micro.srv.greeter")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := strings.Join([]string{
+		"VERSION  NODE        ADDRESS    PORT",
+		"1.0.0    greeter-1a  10.0.0.10  9090",
+		"1.0.0    greeter-1b  10.0.0.11  9090",
+		"1.1.0    greeter-2   10.0.0.12  8080",
+	}, "\n")
+
+	expect := "go.micro.srv.greeter\n" +
+		"versions   1.0.0, 1.1.0\n" +
+		"endpoints  Say.Hello\n" +
+		"           Say.Stream\n" +
+		nodes
+	if rsp != expect {
+		t.Fatalf("expected %q got %q", expect, rsp)
+	}
+	if rich == nil || rich.Code != nodes || len(rich.Fields) != 2 {
+		t.Fatalf("expected the nodes in a code block got %+v", rich)
+	}
+
+	rsp, _, err = execRegistry(service, "service", "go.micro.srv.idle")
+	if expect := "go.micro.srv.idle\nversions   latest\nendpoints  none\nno nodes"; err != nil || rsp != expect {
+		t.Fatalf("expected %q got %q %v", expect, rsp, err)
+	}
+
+	if _, _, err := execRegistry(service, "service", "go.micro.srv.nope"); err == nil || err.Error() != "service go.micro.srv.nope not found" {
+		t.Fatalf("expected not found got %v", err)
+	}
+
+	// missing the name the bot replies with the usage
+	if err := command.Validate(service, []string{"service"}); err == nil || !strings.Contains(err.Error(), "usage: service <name>") {
+		t.Fatalf("expected the usage got %v", err)
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	reg := &brokenRegistry{err: errors.New("dial tcp 127.0.0.1:8500: connection refused")}
+
+	for _, args := range [][]string{{"services"}, {"service", "go.micro.srv.greeter"}} {
+		cmd := servicesCommand(reg)
+		if args[0] == "service" {
+			cmd = serviceCommand(reg)
+		}
+
+		_, _, err := execRegistry(cmd, args...)
+		if expect := "registry consul unavailable: dial tcp 127.0.0.1:8500: connection refused"; err == nil || err.Error() != expect {
+			t.Fatalf("%q: expected %q got %v", args, expect, err)
+		}
+	}
+
+	timeout := RegistryTimeout
+	RegistryTimeout = 20 * time.Millisecond
+	defer func() { RegistryTimeout = timeout }()
+
+	reg = &brokenRegistry{hang: make(chan bool)}
+	defer close(reg.hang)
+
+	start := time.Now()
+	_, _, err := execRegistry(servicesCommand(reg), "services")
+	if expect := fmt.Sprintf("registry consul didn't answer within %v", RegistryTimeout); err == nil || err.Error() != expect {
+		t.Fatalf("expected %q got %v", expect, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("took %v to time out", d)
+	}
+
+	// or the command is cancelled first
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := command.ExecContext(ctx, serviceCommand(reg), "service", "go.micro.srv.greeter"); err != context.Canceled {
+		t.Fatalf("expected the command to be cancelled got %v", err)
+	}
+}
@@ -0,0 +1,397 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-log"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// schedulePattern matches schedule alone or followed by a subcommand
+const schedulePattern = "^schedule( |$)"
+
+// how often the scheduler checks for commands to run
+var scheduleTick = time.Second
+
+// Schedule is a command run at the times of its cron expression with
+// the reply sent to the channel of an input
+type Schedule struct {
+	Id      string `json:"id"`
+	Cron    string `json:"cron"`
+	Command string `json:"command"`
+	Input   string `json:"input"`
+	Channel string `json:"channel"`
+	// who added it, the command runs as them
+	User string `json:"user,omitempty"`
+}
+
+// String returns the schedule in the form it's added in
+func (s *Schedule) String() string {
+	return fmt.Sprintf("%s %s:%s %s", s.Cron, s.Input, s.Channel, s.Command)
+}
+
+// entry is a schedule with when it next runs
+type entry struct {
+	*Schedule
+	spec *cronSpec
+	next time.Time
+	// set by the schedule flag rather than added in chat
+	static bool
+	// the command is still executing, it's not run again until done
+	running bool
+}
+
+// scheduler runs the commands of the schedules through the bot.
+// Schedules added in chat are persisted to path if it's set.
+type scheduler struct {
+	bot  *bot
+	path string
+	now  func() time.Time
+
+	sync.Mutex
+	entries map[string]*entry
+	lastId  int
+}
+
+// parseSchedule parses a schedule of the form
+// "<cron> <input>:<channel> <command>", the cron expression being five
+// fields or a descriptor such as @daily. A channel of "here" is where
+// the schedule was added from.
+func parseSchedule(ctx context.Context, s string) (*Schedule, error) {
+	fields := strings.Fields(s)
+
+	n := 5
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		n = 1
+	}
+
+	if len(fields) < n+2 {
+		return nil, fmt.Errorf("schedule %q must be of the form <cron> <input>:<channel> <command>", s)
+	}
+
+	cron := strings.Join(fields[:n], " ")
+	if _, err := parseCron(cron); err != nil {
+		return nil, err
+	}
+
+	sc := &Schedule{
+		Cron:    cron,
+		Command: strings.Join(fields[n+1:], " "),
+	}
+
+	if fields[n] == "here" {
+		sc.Input, sc.Channel = command.Input(ctx), command.Channel(ctx)
+	} else if parts := strings.SplitN(fields[n], ":", 2); len(parts) == 2 {
+		sc.Input, sc.Channel = parts[0], parts[1]
+	}
+
+	if len(sc.Input) == 0 || len(sc.Channel) == 0 {
+		return nil, fmt.Errorf("schedule destination %q must be <input>:<channel> or here", fields[n])
+	}
+
+	return sc, nil
+}
+
+// loadScheduler returns the scheduler of the schedules set by the
+// schedule flag and those persisted to the schedule_file, nil if
+// neither is set
+func loadScheduler(ctx *cli.Context) (*scheduler, error) {
+	if len(ctx.StringSlice("schedule")) == 0 && len(ctx.String("schedule_file")) == 0 {
+		return nil, nil
+	}
+
+	s := &scheduler{
+		path:    ctx.String("schedule_file"),
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+
+	for i, line := range ctx.StringSlice("schedule") {
+		sc, err := parseSchedule(context.Background(), line)
+		if err != nil {
+			return nil, err
+		}
+		sc.Id = "f" + strconv.Itoa(i+1)
+		s.add(sc, true)
+	}
+
+	if len(s.path) == 0 {
+		return s, nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var schedules []*Schedule
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path, err)
+	}
+
+	for _, sc := range schedules {
+		if _, err := parseCron(sc.Cron); err != nil {
+			return nil, fmt.Errorf("%s: schedule %s: %v", s.path, sc.Id, err)
+		}
+		s.add(sc, false)
+	}
+
+	return s, nil
+}
+
+// add adds the schedule first running after now, missed runs aren't
+// made up for. It's given an id if it has none.
+func (s *scheduler) add(sc *Schedule, static bool) *entry {
+	spec, _ := parseCron(sc.Cron)
+
+	if n, err := strconv.Atoi(sc.Id); err == nil && n > s.lastId {
+		s.lastId = n
+	}
+	if len(sc.Id) == 0 {
+		s.lastId++
+		sc.Id = strconv.Itoa(s.lastId)
+	}
+
+	e := &entry{
+		Schedule: sc,
+		spec:     spec,
+		next:     spec.next(s.now()),
+		static:   static,
+	}
+	s.entries[sc.Id] = e
+
+	return e
+}
+
+// save persists the schedules added in chat, the lock must be held
+func (s *scheduler) save() error {
+	if len(s.path) == 0 {
+		return nil
+	}
+
+	schedules := []*Schedule{}
+	for _, e := range s.sorted() {
+		if !e.static {
+			schedules = append(schedules, e.Schedule)
+		}
+	}
+
+	data, err := json.MarshalIndent(schedules, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// replaced in one go so a crash doesn't lose the schedules
+	f, err := ioutil.TempFile(filepath.Dir(s.path), ".schedules")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
+
+// sorted returns the entries in order of id, the lock must be held
+func (s *scheduler) sorted() []*entry {
+	var entries []*entry
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, _ := strconv.Atoi(entries[i].Id)
+		b, _ := strconv.Atoi(entries[j].Id)
+		if a != b {
+			return a < b
+		}
+		return entries[i].Id < entries[j].Id
+	})
+
+	return entries
+}
+
+// run checks for commands to run until the bot exits
+func (s *scheduler) run() {
+	t := time.NewTicker(scheduleTick)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.bot.exit:
+			return
+		case <-t.C:
+			s.tick()
+		}
+	}
+}
+
+// tick runs the commands due. A command still executing from its last
+// run is skipped.
+func (s *scheduler) tick() {
+	now := s.now()
+
+	s.Lock()
+	var due []*entry
+	for _, e := range s.entries {
+		if e.next.IsZero() || now.Before(e.next) {
+			continue
+		}
+
+		e.next = e.spec.next(now)
+
+		if e.running {
+			log.Logf("[bot] skipping schedule %s, its last run is still executing", e.Id)
+			continue
+		}
+
+		e.running = true
+		due = append(due, e)
+	}
+	s.Unlock()
+
+	for _, e := range due {
+		if !s.exec(e) {
+			s.done(e)
+		}
+	}
+}
+
+// exec runs the command of the schedule through the input's conn,
+// returning false if it couldn't be started
+func (s *scheduler) exec(e *entry) bool {
+	c, ok := s.bot.conn(e.Input)
+	if !ok {
+		log.Logf("[bot] skipping schedule %s, input %s isn't connected", e.Id, e.Input)
+		return false
+	}
+
+	ev := input.Event{
+		Type: input.TextEvent,
+		// replies go to the channel as if the user ran the command
+		From: e.Channel + ":" + e.User,
+		Data: []byte(e.Command),
+		Meta: map[string]interface{}{},
+	}
+
+	return s.bot.work("schedule "+e.Id, func() {
+		defer s.done(e)
+		if err := s.bot.process(c, ev); err != nil {
+			log.Logf("[bot] error running schedule %s: %v", e.Id, err)
+		}
+	})
+}
+
+// done lets the schedule run again
+func (s *scheduler) done(e *entry) {
+	s.Lock()
+	e.running = false
+	s.Unlock()
+}
+
+// scheduleCommand returns the command managing the schedules
+func scheduleCommand(s *scheduler) command.Command {
+	usage := "schedule [add <cron> <input>:<channel> <command>|list|remove <id>]"
+	desc := "Runs commands on a schedule posting the reply to a channel"
+
+	return command.NewContextCommand("schedule", usage, desc, func(ctx context.Context, args ...string) ([]byte, error) {
+		if len(args) < 2 {
+			return []byte("usage: " + usage), nil
+		}
+
+		s.Lock()
+		defer s.Unlock()
+
+		switch args[1] {
+		case "add":
+			sc, err := parseSchedule(ctx, strings.Join(args[2:], " "))
+			if err != nil {
+				return nil, err
+			}
+
+			s.bot.RLock()
+			_, ok := s.bot.inputs[sc.Input]
+			s.bot.RUnlock()
+			if !ok {
+				return nil, fmt.Errorf("unknown input %s", sc.Input)
+			}
+
+			// the command runs as the user in their own input
+			if sc.Input == command.Input(ctx) {
+				sc.User = command.User(ctx)
+			}
+
+			e := s.add(sc, false)
+			if err := s.save(); err != nil {
+				delete(s.entries, sc.Id)
+				return nil, fmt.Errorf("error saving schedules: %v", err)
+			}
+
+			return []byte(fmt.Sprintf("added schedule %s: %s, next run at %s", sc.Id, sc.String(), e.next.Format(time.RFC1123))), nil
+		case "list":
+			entries := s.sorted()
+			if len(entries) == 0 {
+				return []byte("no schedules"), nil
+			}
+
+			var lines []string
+			for _, e := range entries {
+				line := fmt.Sprintf("%s  %s  next %s", e.Id, e.String(), e.next.Format(time.RFC1123))
+				if e.static {
+					line += "  (flag)"
+				}
+				lines = append(lines, line)
+			}
+
+			return command.Reply(ctx, &command.Response{
+				Text: fmt.Sprintf("%d schedules", len(entries)),
+				Code: strings.Join(lines, "\n"),
+			}), nil
+		case "remove":
+			if len(args) != 3 {
+				return []byte("usage: schedule remove <id>"), nil
+			}
+
+			e, ok := s.entries[args[2]]
+			if !ok {
+				return nil, fmt.Errorf("no schedule %s", args[2])
+			}
+			if e.static {
+				return nil, fmt.Errorf("schedule %s is set by a flag and can't be removed", e.Id)
+			}
+
+			delete(s.entries, e.Id)
+			if err := s.save(); err != nil {
+				s.entries[e.Id] = e
+				return nil, fmt.Errorf("error saving schedules: %v", err)
+			}
+
+			return []byte(fmt.Sprintf("removed schedule %s", e.Id)), nil
+		}
+
+		return []byte("usage: " + usage), nil
+	})
+}
@@ -0,0 +1,239 @@
+package bot
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+func TestParseSchedule(t *testing.T) {
+	ctx := command.NewContext(context.Background(), "slack", "U1", "C0OPS")
+
+	testData := []struct {
+		text   string
+		expect string
+	}{
+		{"0 9 * * * slack:C0OPS health all", "0 9 * * * slack:C0OPS health all"},
+		{"@daily irc:#ops list services", "@daily irc:#ops list services"},
+		{"*/5 * * * 1-5 here ping", "*/5 * * * 1-5 slack:C0OPS ping"},
+	}
+
+	for _, d := range testData {
+		sc, err := parseSchedule(ctx, d.text)
+		if err != nil {
+			t.Fatalf("%q: %v", d.text, err)
+		}
+		if sc.String() != d.expect {
+			t.Fatalf("%q: expected %q got %q", d.text, d.expect, sc.String())
+		}
+	}
+
+	for _, text := range []string{
+		"0 9 * * * slack:C0OPS",
+		"0 9 * * slack:C0OPS health",
+		"0 9 * * * C0OPS health",
+		"@sometimes slack:C0OPS health",
+	} {
+		if _, err := parseSchedule(ctx, text); err == nil {
+			t.Fatalf("%q: expected an error", text)
+		}
+	}
+
+	// nowhere is here without a context
+	if _, err := parseSchedule(context.Background(), "@daily here ping"); err == nil {
+		t.Fatal("expected an error for here")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schedule")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "schedules.json")
+
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("schedule_file", path, "")
+	flagSet.Var(&cli.StringSlice{"0 8 * * * test:C0DEV ping"}, "schedule", "")
+	flagSet.String("acl", "allow schedule = test:U1", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	release := make(chan bool)
+	started := make(chan bool, 10)
+
+	commands := map[string]command.Command{
+		"^health ": command.NewCommand("health", "health [service]", "returns health", func(args ...string) ([]byte, error) {
+			started <- true
+			<-release
+			return []byte("all healthy"), nil
+		}),
+		"^ping$": command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	bot := newBot(ctx, map[string]input.Input{"test": io}, commands, service)
+	defer close(bot.exit)
+
+	clock := &fakeClock{t: time.Date(2026, 10, 14, 8, 30, 0, 0, time.UTC)}
+	bot.schedules.now = clock.now
+	for _, e := range bot.schedules.entries {
+		e.next = e.spec.next(clock.now())
+	}
+
+	// replies by who they're to
+	replies := func(n int) map[string]string {
+		rsp := make(map[string]string)
+		for i := 0; i < n; i++ {
+			select {
+			case ev := <-io.send:
+				rsp[ev.To] = string(ev.Data)
+			case <-time.After(time.Second):
+				t.Fatalf("expected %d replies got %v", n, rsp)
+			}
+		}
+		return rsp
+	}
+
+	c := &serialConn{Conn: io, input: "test"}
+	bot.conns["test"] = c
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	// only admins allowed by name manage schedules
+	if rsp := send("C0DEV:U2", "schedule list"); !strings.HasPrefix(rsp, "permission denied") {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	rsp := send("C0DEV:U1", "schedule add 0 9 * * * test:C0OPS health all")
+	if rsp != "added schedule 1: 0 9 * * * test:C0OPS health all, next run at Wed, 14 Oct 2026 09:00:00 UTC" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	for _, text := range []string{
+		"schedule add 0 9 * * * nope:C0OPS health all",
+		"schedule add 0 25 * * * test:C0OPS health all",
+		"schedule remove 9",
+		"schedule remove f1",
+	} {
+		if rsp := send("C0DEV:U1", text); !strings.HasPrefix(rsp, "error executing cmd: ") {
+			t.Fatalf("%q: unexpected response %q", text, rsp)
+		}
+	}
+
+	rsp = send("C0DEV:U1", "schedule list")
+	if !strings.Contains(rsp, "1  0 9 * * * test:C0OPS health all  next Wed, 14 Oct 2026 09:00:00 UTC") ||
+		!strings.Contains(rsp, "f1  0 8 * * * test:C0DEV ping  next Thu, 15 Oct 2026 08:00:00 UTC  (flag)") {
+		t.Fatalf("unexpected list %q", rsp)
+	}
+
+	// nothing is due yet
+	bot.schedules.tick()
+	select {
+	case ev := <-io.send:
+		t.Fatalf("unexpected reply %q", string(ev.Data))
+	default:
+	}
+
+	// runs as the user who added it, replying to the channel
+	clock.advance(30 * time.Minute)
+	bot.schedules.tick()
+	<-started
+
+	// still running the next day, the run is skipped
+	clock.advance(24 * time.Hour)
+	bot.schedules.tick()
+
+	release <- true
+
+	// the flag schedule ran too
+	if rsp := replies(2); rsp["C0OPS:U1"] != "all healthy" || rsp["C0DEV:"] != "pong" {
+		t.Fatalf("unexpected replies %v", rsp)
+	}
+
+	select {
+	case <-started:
+		t.Fatal("expected the overlapping run to be skipped")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// missed runs aren't replayed, there's a single run after a week away
+	clock.advance(7 * 24 * time.Hour)
+	bot.schedules.tick()
+	<-started
+	release <- true
+
+	if rsp := replies(2); len(rsp) != 2 || len(io.send) > 0 {
+		t.Fatalf("unexpected replies %v", rsp)
+	}
+
+	bot.schedules.Lock()
+	next := bot.schedules.entries["1"].next
+	bot.schedules.Unlock()
+	if !next.Equal(time.Date(2026, 10, 23, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next run %v", next)
+	}
+
+	// schedules added in chat survive a restart, flags aren't persisted
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"command": "health all"`) || strings.Contains(string(data), "ping") {
+		t.Fatalf("unexpected schedule file %s", data)
+	}
+
+	s, err := loadScheduler(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := s.entries["1"]; !ok || e.String() != "0 9 * * * test:C0OPS health all" || e.User != "U1" || len(s.entries) != 2 {
+		t.Fatalf("unexpected schedules %+v", s.entries)
+	}
+
+	if rsp := send("C0DEV:U1", "schedule remove 1"); rsp != "removed schedule 1" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if s, err := loadScheduler(ctx); err != nil || len(s.entries) != 1 {
+		t.Fatalf("expected only the flag schedule got %+v %v", s, err)
+	}
+
+	// new schedules don't reuse ids
+	if rsp := send("C0DEV:U1", "schedule add @hourly here ping"); !strings.HasPrefix(rsp, "added schedule 2: @hourly test:C0DEV ping") {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+}
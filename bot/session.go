@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+// DefaultSessionTimeout is how long a prompt waits for an answer
+var DefaultSessionTimeout = 5 * time.Minute
+
+// cancelSession is the answer ending a conversation without running
+// the command
+const cancelSession = "cancel"
+
+// sessionKey is who a conversation is with and where
+type sessionKey struct {
+	input   string
+	channel string
+	user    string
+}
+
+// session is a conversation waiting for the user's answer to a prompt
+type session struct {
+	cmd command.Command
+	// namespaced name of the command
+	name    string
+	state   interface{}
+	expires time.Time
+}
+
+// sessions hold the conversations commands are having with users
+type sessions struct {
+	ttl time.Duration
+	now func() time.Time
+
+	sync.Mutex
+	m map[sessionKey]*session
+}
+
+func newSessions(ttl time.Duration) *sessions {
+	if ttl <= 0 {
+		ttl = DefaultSessionTimeout
+	}
+	return &sessions{
+		ttl: ttl,
+		now: time.Now,
+		m:   make(map[sessionKey]*session),
+	}
+}
+
+// sessionKeyOf returns the key of the conversation with the sender of
+// ev, false if the sender is unknown
+func sessionKeyOf(c input.Conn, ev input.Event) (sessionKey, bool) {
+	var name string
+	if sc, ok := c.(*serialConn); ok {
+		name = sc.input
+	}
+
+	user, channel := requester(ev)
+	if len(user) == 0 {
+		return sessionKey{}, false
+	}
+
+	return sessionKey{name, channel, user}, true
+}
+
+// claim waits for the user's answer to the command's prompt
+func (s *sessions) claim(key sessionKey, cmd command.Command, name string, state interface{}) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+
+	// expired sessions go whenever another is claimed
+	for k, sess := range s.m {
+		if !now.Before(sess.expires) {
+			delete(s.m, k)
+		}
+	}
+
+	s.m[key] = &session{
+		cmd:     cmd,
+		name:    name,
+		state:   state,
+		expires: now.Add(s.ttl),
+	}
+}
+
+// take ends and returns the conversation waiting for the user's answer,
+// nil if there's none or it expired
+func (s *sessions) take(key sessionKey) *session {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, ok := s.m[key]
+	if !ok {
+		return nil
+	}
+
+	delete(s.m, key)
+
+	if !s.now().Before(sess.expires) {
+		return nil
+	}
+
+	return sess
+}
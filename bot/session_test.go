@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/console"
+)
+
+// syncBuffer is written by the console and read by the test
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.Buffer.String()
+}
+
+// deployCommand asks which service and version to deploy
+func deployCommand() command.Command {
+	return command.NewContextCommand("deploy", "deploy", "deploys a service", func(ctx context.Context, args ...string) ([]byte, error) {
+		state, ok := command.SessionState(ctx)
+		if !ok {
+			return command.Prompt(ctx, "which service?", []string(nil)), nil
+		}
+
+		answers := append(state.([]string), args[1:]...)
+		if len(answers) < 2 {
+			return command.Prompt(ctx, "which version?", answers), nil
+		}
+
+		return []byte("deployed " + strings.Join(answers, " ")), nil
+	})
+}
+
+func newSessionBot(inputs map[string]input.Input) *bot {
+	flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+	flagSet.String("console_prompt", "> ", "")
+	ctx := cli.NewContext(cli.NewApp(), flagSet, nil)
+
+	commands := map[string]command.Command{
+		"^deploy$": deployCommand(),
+		"^echo ": command.NewCommand("echo", "echo [text]", "echoes", func(args ...string) ([]byte, error) {
+			return []byte(strings.Join(args[1:], " ")), nil
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	return newBot(ctx, inputs, commands, service)
+}
+
+func TestSessionConsole(t *testing.T) {
+	script := strings.Join([]string{
+		"deploy",
+		"API",
+		"v2",
+		// answers aren't commands
+		"deploy",
+		"echo",
+		"v3",
+		"deploy",
+		"cancel",
+		"echo done",
+	}, "\n")
+
+	out := &syncBuffer{}
+	code := make(chan int, 1)
+
+	io := console.NewScriptInput(strings.NewReader(script), out, func(c int) { code <- c })
+	bot := newSessionBot(map[string]input.Input{"console": io})
+
+	if err := bot.startInput("console"); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.stopInput("console")
+
+	select {
+	case c := <-code:
+		if c != 0 {
+			t.Fatalf("expected exit 0 got %d\n%s", c, out.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out, output so far %q", out.String())
+	}
+
+	expect := strings.Join([]string{
+		"> deploy", "which service?",
+		"> API", "which version?",
+		"> v2", "deployed API v2",
+		"> deploy", "which service?",
+		"> echo", "which version?",
+		"> v3", "deployed echo v3",
+		"> deploy", "which service?",
+		"> cancel", "cancelled deploy",
+		"> echo done", "done",
+		"9 commands run, 0 failed",
+	}, "\n") + "\n"
+
+	if got := out.String(); got != expect {
+		t.Fatalf("expected %q got %q", expect, got)
+	}
+}
+
+func TestSessionUsers(t *testing.T) {
+	io := &testInput{
+		send: make(chan *input.Event, 10),
+		recv: make(chan *input.Event),
+		exit: make(chan bool),
+	}
+
+	bot := newSessionBot(nil)
+	c := &serialConn{Conn: io, input: "test"}
+
+	now := time.Now()
+	bot.sessions.now = func() time.Time { return now }
+
+	send := func(from, text string) string {
+		if err := bot.process(c, input.Event{Type: input.TextEvent, From: from, Data: []byte(text)}); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case ev := <-io.send:
+			return string(ev.Data)
+		case <-time.After(time.Second):
+			t.Fatalf("%q: expected a response", text)
+		}
+		return ""
+	}
+
+	if rsp := send("C0:U1", "deploy"); rsp != "which service?" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// other users and channels aren't answering
+	if rsp := send("C0:U2", "echo hi"); rsp != "hi" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C1:U1", "echo there"); rsp != "there" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// nor are unknown users
+	if rsp := send("", "echo anon"); rsp != "anon" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	if rsp := send("C0:U1", "api"); rsp != "which version?" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// an unanswered prompt expires
+	now = now.Add(DefaultSessionTimeout)
+	if rsp := send("C0:U1", "echo late"); rsp != "late" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	// each user has their own conversation
+	send("C0:U1", "deploy")
+	send("C0:U2", "deploy")
+	if rsp := send("C0:U2", "web"); rsp != "which version?" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U1", "api"); rsp != "which version?" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U2", "v1"); rsp != "deployed web v1" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+	if rsp := send("C0:U1", "Cancel"); rsp != "cancelled deploy" {
+		t.Fatalf("unexpected response %q", rsp)
+	}
+
+	bot.sessions.Lock()
+	n := len(bot.sessions.m)
+	bot.sessions.Unlock()
+	if n != 0 {
+		t.Fatalf("expected no sessions left got %d", n)
+	}
+}
@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/micro/cli"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+	"github.com/micro/micro/bot/input/slack/slacktest"
+	"github.com/nlopes/slack"
+)
+
+// newSlackBot starts a bot with the slack input connected to srv
+func newSlackBot(t *testing.T, srv *slacktest.Server, args ...string) *bot {
+	io, ok := input.Lookup("slack")
+	if !ok {
+		t.Fatal("slack input not registered")
+	}
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, f := range io.Flags() {
+		f.Apply(set)
+	}
+	if err := set.Parse(append([]string{"--slack_token=xoxb-test"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	ctx := cli.NewContext(cli.NewApp(), set, nil)
+
+	commands := map[string]command.Command{
+		"^ping$": command.WithAliases(command.NewCommand("ping", "ping", "returns pong", func(args ...string) ([]byte, error) {
+			return []byte("pong"), nil
+		}), "p"),
+		"^fail$": command.NewCommand("fail", "fail", "returns an error", func(args ...string) ([]byte, error) {
+			return nil, errors.New("boom")
+		}),
+	}
+
+	service := micro.NewService(
+		micro.Registry(memory.NewRegistry()),
+	)
+
+	b := newBot(ctx, map[string]input.Input{"slack": io}, commands, service)
+	if err := b.start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.WaitConnected(5 * time.Second); err != nil {
+		b.stop()
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// startSlack points the slack client at a fake server
+func startSlack(t *testing.T) (*slacktest.Server, func()) {
+	srv := slacktest.NewServer()
+	srv.AddUser(slack.User{ID: "U0USER", Name: "john"})
+	srv.AddIM("D0DM", "U0USER")
+
+	url := slack.APIURL
+	slack.APIURL = srv.URL
+
+	return srv, func() {
+		slack.APIURL = url
+		srv.Close()
+	}
+}
+
+func TestSlackEndToEnd(t *testing.T) {
+	srv, stop := startSlack(t)
+	defer stop()
+
+	b := newSlackBot(t, srv)
+	defer b.stop()
+
+	testData := []struct {
+		name    string
+		channel string
+		text    string
+		reply   string
+	}{
+		{"mention", "C0CHAN", "<@U0BOT> ping", "<@U0USER>: pong"},
+		{"name mention", "C0CHAN", "micro: Ping?", "<@U0USER>: pong"},
+		{"dm", "D0DM", "ping", "pong"},
+		{"alias", "C0CHAN", "<@U0BOT> P", "<@U0USER>: pong"},
+		{"error", "C0CHAN", "<@U0BOT> fail", "<@U0USER>: error executing cmd: boom"},
+		{"unknown", "D0DM", "nope", "unknown command 'nope', run help for a list of commands"},
+	}
+
+	for _, d := range testData {
+		// messages which aren't addressed to the bot are ignored
+		if err := srv.SendMessage("C0CHAN", "U0USER", "ping"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := srv.SendMessage(d.channel, "U0USER", d.text); err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := srv.Message(5 * time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+
+		if msg.Channel != d.channel || msg.Text != d.reply {
+			t.Fatalf("%s: expected %q in %s got %q in %s", d.name, d.reply, d.channel, msg.Text, msg.Channel)
+		}
+	}
+}
+
+func TestSlackStop(t *testing.T) {
+	srv, stop := startSlack(t)
+	defer stop()
+
+	b := newSlackBot(t, srv)
+
+	if err := b.stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bot is marked away and the rtm disconnected
+	call, err := srv.Call("users.setPresence", 5*time.Second)
+	for err == nil && call.Form["presence"][0] != "away" {
+		call, err = srv.Call("users.setPresence", 5*time.Second)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.Connections() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the rtm to disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// nothing is answered once stopped
+	srv.SendMessage("D0DM", "U0USER", "ping")
+
+	if msg, err := srv.Message(100 * time.Millisecond); err == nil {
+		t.Fatalf("unexpected message %q", msg.Text)
+	}
+}
@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggest returns up to n of the names closest to name. Names which
+// start with name come first, then those within a small edit distance.
+func suggest(name string, names []string, n int) []string {
+	type match struct {
+		name     string
+		prefix   bool
+		distance int
+	}
+
+	// allow roughly one typo for every two characters
+	max := len(name) / 2
+	if max < 1 {
+		max = 1
+	}
+
+	var matches []match
+	seen := make(map[string]bool)
+
+	for _, c := range names {
+		if c == name || seen[c] {
+			continue
+		}
+		seen[c] = true
+
+		m := match{
+			name:     c,
+			prefix:   len(name) > 0 && strings.HasPrefix(c, name),
+			distance: levenshtein(name, c),
+		}
+
+		if m.prefix || m.distance <= max {
+			matches = append(matches, m)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].prefix != matches[j].prefix {
+			return matches[i].prefix
+		}
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	var suggestions []string
+	for i := 0; i < len(matches) && i < n; i++ {
+		suggestions = append(suggestions, matches[i].name)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			// cheapest of deleting, inserting or substituting
+			d := prev[j] + 1
+			if v := curr[j-1] + 1; v < d {
+				d = v
+			}
+			if v := prev[j-1] + cost; v < d {
+				d = v
+			}
+			curr[j] = d
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
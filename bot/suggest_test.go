@@ -0,0 +1,50 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	testData := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"help", "help", 0},
+		{"", "help", 4},
+		{"halth", "health", 1},
+		{"pnig", "ping", 2},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, d := range testData {
+		if got := levenshtein(d.a, d.b); got != d.distance {
+			t.Fatalf("%q %q: expected %d got %d", d.a, d.b, d.distance, got)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	names := []string{"call", "deregister", "echo", "get", "health", "hello", "help", "list", "ping", "register", "time"}
+
+	testData := []struct {
+		name        string
+		suggestions string
+	}{
+		// prefix matches come before closer edit distances
+		{"he", "help,hello,health"},
+		{"reg", "register"},
+		{"halth", "health"},
+		{"pnig", "ping"},
+		{"hepl", "hello,help"},
+		{"xyzzy", ""},
+		{"help", "hello"},
+	}
+
+	for _, d := range testData {
+		if got := strings.Join(suggest(d.name, names, 3), ","); got != d.suggestions {
+			t.Fatalf("%q: expected %q got %q", d.name, d.suggestions, got)
+		}
+	}
+}
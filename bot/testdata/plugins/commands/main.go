@@ -0,0 +1,12 @@
+// Package main is a plugin exporting grouped commands by pattern
+package main
+
+import (
+	"github.com/micro/micro/bot/command"
+)
+
+var Commands = map[string]command.Command{
+	"^ping$": command.WithGroup(command.NewCommand("ping", "ping", "Replies pong", func(args ...string) ([]byte, error) {
+		return []byte("pong"), nil
+	}), "net"),
+}
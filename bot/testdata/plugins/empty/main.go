@@ -0,0 +1,6 @@
+// Package main is a plugin exporting none of the symbols the bot loads
+package main
+
+func Greet() string {
+	return "hello"
+}
@@ -0,0 +1,30 @@
+// Package main is a plugin exporting an input and a command
+package main
+
+import (
+	"errors"
+
+	"github.com/micro/cli"
+	"github.com/micro/micro/bot/command"
+	"github.com/micro/micro/bot/input"
+)
+
+type pluginInput struct{}
+
+func (p *pluginInput) Flags() []cli.Flag           { return nil }
+func (p *pluginInput) Init(*cli.Context) error     { return nil }
+func (p *pluginInput) Stream() (input.Conn, error) { return nil, errors.New("not streaming") }
+func (p *pluginInput) Start() error                { return nil }
+func (p *pluginInput) Stop() error                 { return nil }
+func (p *pluginInput) String() string              { return "plugin" }
+
+var Input input.Input = &pluginInput{}
+
+func NewCommand() command.Command {
+	return command.NewCommand("greet", "greet <name>", "Greets the name", func(args ...string) ([]byte, error) {
+		if len(args) < 2 {
+			return []byte("hello"), nil
+		}
+		return []byte("hello " + args[1]), nil
+	})
+}
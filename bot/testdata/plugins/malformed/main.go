@@ -0,0 +1,4 @@
+// Package main is a plugin exporting a command of the wrong type
+package main
+
+var Command = "greet"
@@ -76,6 +76,17 @@ func setup(app *ccli.App) {
 			Usage:  "Set the namespace used by the API e.g. com.example.api",
 			EnvVar: "MICRO_API_NAMESPACE",
 		},
+		ccli.IntFlag{
+			Name:   "api_max_body_size",
+			Usage:  "Largest size in bytes of request bodies to the API, 0 for no limit",
+			EnvVar: "MICRO_API_MAX_BODY_SIZE",
+			Value:  10 << 20,
+		},
+		ccli.IntFlag{
+			Name:   "api_max_proxy_body_size",
+			Usage:  "Largest size in bytes of request bodies proxied by the API's http, proxy and web handlers, 0 for no limit",
+			EnvVar: "MICRO_API_MAX_PROXY_BODY_SIZE",
+		},
 		ccli.StringFlag{
 			Name:   "web_namespace",
 			Usage:  "Set the namespace used by the Web proxy e.g. com.example.web",
@@ -3,8 +3,10 @@ module github.com/micro/micro
 require (
 	github.com/chzyer/readline v0.0.0-20180603132655-2972be24d48e
 	github.com/golang/protobuf v1.2.0
+	github.com/google/uuid v1.1.0
 	github.com/gorilla/handlers v1.4.0 // indirect
 	github.com/gorilla/mux v1.7.0
+	github.com/gorilla/websocket v1.4.0
 	github.com/joncalhoun/qson v0.0.0-20170526102502-8a9cab3a62b1 // indirect
 	github.com/micro/cli v0.1.0
 	github.com/micro/go-api v0.5.0
@@ -12,9 +14,13 @@ require (
 	github.com/micro/go-log v0.1.0
 	github.com/micro/go-micro v0.24.0
 	github.com/micro/go-proxy v0.1.0
+	github.com/nlopes/slack v0.5.0
 	github.com/pborman/uuid v1.2.0 // indirect
+	github.com/prometheus/client_golang v0.9.2
 	github.com/serenize/snaker v0.0.0-20171204205717-a683aaf2d516
 	github.com/xlab/treeprint v0.0.0-20181112141820-a009c3971eca
+	golang.org/x/crypto v0.0.0-20190130090550-b01c7a725664
 	golang.org/x/net v0.0.0-20190125091013-d26f9f9a57f3
+	google.golang.org/genproto v0.0.0-20180817151627-c66870c02cf8
 	google.golang.org/grpc v1.18.0
 )
@@ -0,0 +1,202 @@
+// Package auth authenticates requests to the api by their bearer JWT
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	merrors "github.com/micro/go-micro/errors"
+)
+
+var (
+	// DefaultSkew is the clock skew tolerated checking token times
+	DefaultSkew = time.Minute
+	// Headers the claims of a token are forwarded in, those sent by
+	// clients are removed
+	SubjectHeader = "X-Micro-Auth-Subject"
+	ScopesHeader  = "X-Micro-Auth-Scopes"
+	IssuerHeader  = "X-Micro-Auth-Issuer"
+)
+
+type Options struct {
+	// JWKSURL serves the keys tokens are signed with
+	JWKSURL string
+	// KeyFile is a PEM encoded public key or certificate tokens are
+	// signed with, used instead of a JWKS
+	KeyFile string
+	// Refresh is how often the JWKS is fetched
+	Refresh time.Duration
+	// Issuer tokens must be issued by if set
+	Issuer string
+	// Audience tokens must be for if set
+	Audience string
+	// Skew is the clock skew tolerated checking token times
+	Skew time.Duration
+	// Public paths requests to aren't authenticated, along with those
+	// beneath them e.g. /health and /health/live but not /healthz
+	Public []string
+	// Id of the errors returned
+	Id string
+}
+
+type auth struct {
+	opts Options
+	keys keySet
+	now  func() time.Time
+}
+
+// New returns the authentication of the options
+func New(opts Options) (*auth, error) {
+	a := &auth{opts: opts, now: time.Now}
+
+	switch {
+	case len(opts.JWKSURL) > 0 && len(opts.KeyFile) > 0:
+		return nil, errors.New("auth: use either a JWKS url or public key file")
+	case len(opts.JWKSURL) > 0:
+		a.keys = newJWKS(opts.JWKSURL, opts.Refresh)
+	case len(opts.KeyFile) > 0:
+		key, err := loadKey(opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		a.keys = key
+	default:
+		return nil, errors.New("auth: a JWKS url or public key file is required")
+	}
+
+	if a.opts.Skew <= 0 {
+		a.opts.Skew = DefaultSkew
+	}
+	if len(a.opts.Id) == 0 {
+		a.opts.Id = "go.micro.api"
+	}
+
+	return a, nil
+}
+
+// Verify returns the claims of the token if it's valid
+func (a *auth) Verify(token string) (*Claims, error) {
+	h, signed, payload, sig, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := a.keys.Key(h.Kid, h.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(h.Alg, key, signed, sig); err != nil {
+		return nil, err
+	}
+
+	c, err := claimsOf(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now()
+
+	if c.Expires.IsZero() {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(c.Expires.Add(a.opts.Skew)) {
+		return nil, errors.New("token expired")
+	}
+	if !c.NotBefore.IsZero() && now.Before(c.NotBefore.Add(-a.opts.Skew)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if len(a.opts.Issuer) > 0 && c.Issuer != a.opts.Issuer {
+		return nil, fmt.Errorf("token issued by %q", c.Issuer)
+	}
+	if len(a.opts.Audience) > 0 && !contains(c.Audience, a.opts.Audience) {
+		return nil, errors.New("token not for this audience")
+	}
+
+	return c, nil
+}
+
+func contains(list []string, v string) bool {
+	for _, l := range list {
+		if l == v {
+			return true
+		}
+	}
+	return false
+}
+
+// public returns whether requests to the path aren't authenticated,
+// matching whole segments of the cleaned path
+func (a *auth) public(p string) bool {
+	clean := path.Clean("/" + p)
+	// a trailing slash is kept, it's matched as given
+	if strings.HasSuffix(p, "/") && clean != "/" {
+		clean += "/"
+	}
+
+	for _, pub := range a.opts.Public {
+		if clean == pub || strings.HasPrefix(clean, strings.TrimSuffix(pub, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler passes the requests with valid tokens to h, along with their
+// claims in the auth headers. Others get 401 Unauthorized unless they're
+// to a public path.
+func (a *auth) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only ever the api's
+		r.Header.Del(SubjectHeader)
+		r.Header.Del(ScopesHeader)
+		r.Header.Del(IssuerHeader)
+
+		if a.public(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		unauthorized := func(detail string) {
+			e := merrors.Unauthorized(a.opts.Id, "%s", detail)
+			challenge := "Bearer"
+			// tokens were sent but aren't valid
+			if len(r.Header.Get("Authorization")) > 0 {
+				challenge = `Bearer error="invalid_token"`
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("WWW-Authenticate", challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(e.Error()))
+		}
+
+		authz := r.Header.Get("Authorization")
+		if len(authz) == 0 {
+			unauthorized("missing bearer token")
+			return
+		}
+
+		parts := strings.SplitN(authz, " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || len(strings.TrimSpace(parts[1])) == 0 {
+			unauthorized("authorization must be a bearer token")
+			return
+		}
+
+		c, err := a.Verify(strings.TrimSpace(parts[1]))
+		if err != nil {
+			unauthorized(err.Error())
+			return
+		}
+
+		r.Header.Set(SubjectHeader, c.Subject)
+		r.Header.Set(IssuerHeader, c.Issuer)
+		if len(c.Scopes) > 0 {
+			r.Header.Set(ScopesHeader, strings.Join(c.Scopes, " "))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
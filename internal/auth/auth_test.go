@@ -0,0 +1,394 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+)
+
+var now = time.Unix(1500000000, 0)
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sign returns the token of the claims signed by the key
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	h, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	c, _ := json.Marshal(claims)
+	signed := encode(h) + "." + encode(c)
+
+	a := algorithms[alg]
+	hasher := a.hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, a.hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		s.FillBytes(sig[size:])
+	}
+
+	return signed + "." + encode(sig)
+}
+
+// claims are valid ones with the changes
+func claims(changes map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"iss":   "https://issuer.example.com",
+		"sub":   "user-1",
+		"aud":   "micro",
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Hour).Unix(),
+		"scope": "read write",
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// writeKey writes the PEM encoded public key to a file of the dir
+func writeKey(t *testing.T, dir string, key crypto.PublicKey) string {
+	b, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := New(Options{
+		KeyFile:  writeKey(t, dir, &rsaKey.PublicKey),
+		Issuer:   "https://issuer.example.com",
+		Audience: "micro",
+		Skew:     30 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+
+	testData := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"valid", sign(t, "RS256", "", rsaKey, claims(nil)), ""},
+		{"RS512", sign(t, "RS512", "", rsaKey, claims(nil)), ""},
+		{"audiences", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"aud": []string{"other", "micro"}})), ""},
+		{"expired within skew", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"exp": now.Add(-20 * time.Second).Unix()})), ""},
+		{"valid within skew", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"nbf": now.Add(20 * time.Second).Unix()})), ""},
+		{"expired", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"exp": now.Add(-time.Minute).Unix()})), "token expired"},
+		{"not valid yet", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"nbf": now.Add(time.Minute).Unix()})), "token not valid yet"},
+		{"no expiry", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"exp": nil})), "token has no expiry"},
+		{"issuer", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"iss": "https://evil.com"})), `token issued by "https://evil.com"`},
+		{"audience", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"aud": "other"})), "token not for this audience"},
+		{"no audience", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"aud": nil})), "token not for this audience"},
+		{"other key", sign(t, "RS256", "", otherKey, claims(nil)), "invalid token signature"},
+		{"other algorithm", sign(t, "ES256", "", ecKey, claims(nil)), "key can't verify ES256 tokens"},
+		{"none", encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(`{"sub":"admin"}`)) + ".", `unsupported signing algorithm "none"`},
+		{"HS256", encode([]byte(`{"alg":"HS256"}`)) + "." + encode([]byte(`{"sub":"admin"}`)) + "." + encode([]byte("sig")), `unsupported signing algorithm "HS256"`},
+		{"tampered", func() string {
+			parts := strings.Split(sign(t, "RS256", "", rsaKey, claims(nil)), ".")
+			parts[1] = encode([]byte(`{"sub":"admin","exp":9999999999}`))
+			return strings.Join(parts, ".")
+		}(), "invalid token signature"},
+		{"malformed", "not.a-token", "malformed token"},
+		{"malformed header", "!!.e30.sig", "malformed token header"},
+		{"malformed claims", sign(t, "RS256", "", rsaKey, claims(map[string]interface{}{"exp": "tomorrow"})), "malformed token claim exp"},
+	}
+
+	for _, d := range testData {
+		c, err := a.Verify(d.token)
+		if len(d.err) > 0 {
+			if err == nil || err.Error() != d.err {
+				t.Fatalf("%s: expected error %q got %v", d.name, d.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", d.name, err)
+		}
+		if c.Subject != "user-1" || strings.Join(c.Scopes, " ") != "read write" {
+			t.Fatalf("%s: unexpected claims %+v", d.name, c)
+		}
+	}
+}
+
+// keyServer serves a JWKS of the keys set
+type keyServer struct {
+	sync.Mutex
+	keys    map[string]*ecdsa.PublicKey
+	fetches int
+}
+
+func (k *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k.Lock()
+	defer k.Unlock()
+	k.fetches++
+
+	var keys []map[string]string
+	for kid, key := range k.keys {
+		keys = append(keys, map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"use": "sig",
+			"alg": "ES256",
+			"crv": "P-256",
+			"x":   encode(key.X.Bytes()),
+			"y":   encode(key.Y.Bytes()),
+		})
+	}
+	// keys which can't be used are skipped
+	keys = append(keys, map[string]string{"kty": "oct", "kid": "secret"})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (k *keyServer) set(keys map[string]*ecdsa.PublicKey) {
+	k.Lock()
+	defer k.Unlock()
+	k.keys = keys
+}
+
+func TestJWKS(t *testing.T) {
+	first, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	second, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	ks := &keyServer{keys: map[string]*ecdsa.PublicKey{"first": &first.PublicKey}}
+	srv := httptest.NewServer(ks)
+	defer srv.Close()
+
+	a, err := New(Options{JWKSURL: srv.URL, Refresh: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := now
+	a.now = func() time.Time { return now }
+	a.keys.(*jwks).now = func() time.Time { return clock }
+
+	verify := func(token, expect string) {
+		_, err := a.Verify(token)
+		if len(expect) == 0 && err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(expect) > 0 && (err == nil || err.Error() != expect) {
+			t.Fatalf("expected error %q got %v", expect, err)
+		}
+	}
+
+	firstToken := sign(t, "ES256", "first", first, claims(nil))
+	secondToken := sign(t, "ES256", "second", second, claims(nil))
+
+	verify(firstToken, "")
+	verify(firstToken, "")
+	if ks.fetches != 1 {
+		t.Fatalf("expected the keys fetched once got %d", ks.fetches)
+	}
+
+	// the key's alg must match
+	verify(sign(t, "ES384", "first", first, claims(nil)), `key "first" can't verify ES384 tokens`)
+
+	// keys rotated in are picked up for tokens of unknown keys
+	ks.set(map[string]*ecdsa.PublicKey{"first": &first.PublicKey, "second": &second.PublicKey})
+
+	verify(secondToken, `unknown signing key "second"`)
+	clock = clock.Add(MinRefresh)
+	verify(secondToken, "")
+
+	// but not fetched again for every one
+	fetches := ks.fetches
+	verify(sign(t, "ES256", "third", second, claims(nil)), `unknown signing key "third"`)
+	if ks.fetches != fetches {
+		t.Fatalf("expected the keys not fetched again got %d fetches", ks.fetches)
+	}
+
+	// keys rotated out are dropped once refreshed
+	ks.set(map[string]*ecdsa.PublicKey{"second": &second.PublicKey})
+	clock = clock.Add(time.Hour)
+	verify(firstToken, `unknown signing key "first"`)
+	verify(secondToken, "")
+
+	// the keys are kept while the JWKS can't be fetched
+	srv.Close()
+	clock = clock.Add(time.Hour)
+	verify(secondToken, "")
+}
+
+func TestHandler(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := New(Options{
+		KeyFile: writeKey(t, dir, &key.PublicKey),
+		Public:  []string{"/health", "/login/"},
+		Id:      "go.micro.api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+
+	var forwarded http.Header
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header
+	}))
+
+	request := func(path, authz string) *httptest.ResponseRecorder {
+		forwarded = nil
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set(SubjectHeader, "spoofed")
+		if len(authz) > 0 {
+			r.Header.Set("Authorization", authz)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	token := sign(t, "ES256", "", key, claims(map[string]interface{}{
+		"scope":  nil,
+		"scopes": []string{"greeter:read", "greeter:write"},
+	}))
+
+	w := request("/greeter/hello", "Bearer "+token)
+	if w.Code != http.StatusOK || forwarded == nil {
+		t.Fatalf("expected the request forwarded got %d", w.Code)
+	}
+	for k, v := range map[string]string{
+		SubjectHeader: "user-1",
+		ScopesHeader:  "greeter:read greeter:write",
+		IssuerHeader:  "https://issuer.example.com",
+	} {
+		if got := forwarded.Get(k); got != v {
+			t.Fatalf("expected %s %q got %q", k, v, got)
+		}
+	}
+
+	// public paths are forwarded without the claims of clients
+	for _, path := range []string{"/health", "/health/live", "/login/", "/login/google", "//login/./google"} {
+		if w := request(path, ""); w.Code != http.StatusOK || forwarded == nil || len(forwarded.Get(SubjectHeader)) > 0 {
+			t.Fatalf("%s: expected the request forwarded without a subject got %d %v", path, w.Code, forwarded)
+		}
+	}
+
+	for _, d := range []struct {
+		path      string
+		authz     string
+		challenge string
+		detail    string
+	}{
+		{"/greeter/hello", "", "Bearer", "missing bearer token"},
+		{"/login", "", "Bearer", "missing bearer token"},
+		// public paths are whole segments
+		{"/healthcare/records", "", "Bearer", "missing bearer token"},
+		{"/health-admin/users", "", "Bearer", "missing bearer token"},
+		{"/loginx", "", "Bearer", "missing bearer token"},
+		{"/health/../admin", "", "Bearer", "missing bearer token"},
+		{"/greeter/hello", "Basic dXNlcjpwYXNz", `Bearer error="invalid_token"`, "authorization must be a bearer token"},
+		{"/greeter/hello", "Bearer garbage", `Bearer error="invalid_token"`, "malformed token"},
+		{"/greeter/hello", "Bearer " + sign(t, "ES256", "", key, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), `Bearer error="invalid_token"`, "token expired"},
+	} {
+		w := request(d.path, d.authz)
+		if w.Code != http.StatusUnauthorized || forwarded != nil {
+			t.Fatalf("%s %q: expected 401 got %d", d.path, d.authz, w.Code)
+		}
+		if c := w.Header().Get("WWW-Authenticate"); c != d.challenge {
+			t.Fatalf("%s %q: expected challenge %q got %q", d.path, d.authz, d.challenge, c)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("expected a json error got %q", ct)
+		}
+		e := errors.Parse(w.Body.String())
+		if e.Id != "go.micro.api" || e.Code != 401 || e.Detail != d.detail {
+			t.Fatalf("%s %q: unexpected error %+v", d.path, d.authz, e)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	garbage := filepath.Join(dir, "garbage.pem")
+	ioutil.WriteFile(garbage, []byte("garbage"), 0600)
+
+	for _, d := range []struct {
+		opts Options
+		err  string
+	}{
+		{Options{}, "auth: a JWKS url or public key file is required"},
+		{Options{JWKSURL: "http://localhost", KeyFile: "key.pem"}, "auth: use either a JWKS url or public key file"},
+		{Options{KeyFile: garbage}, "auth: no PEM encoded key in " + garbage},
+	} {
+		if _, err := New(d.opts); err == nil || err.Error() != d.err {
+			t.Fatalf("%+v: expected error %q got %v", d.opts, d.err, err)
+		}
+	}
+}
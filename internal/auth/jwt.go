@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Claims are those of a token the api checks and forwards
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	Scopes    []string
+	Expires   time.Time
+	NotBefore time.Time
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// algorithm is how tokens are signed, only public key ones are accepted
+type algorithm struct {
+	hash crypto.Hash
+	// the curve of ecdsa keys, nil for rsa
+	curve elliptic.Curve
+}
+
+var algorithms = map[string]algorithm{
+	"RS256": {crypto.SHA256, nil},
+	"RS384": {crypto.SHA384, nil},
+	"RS512": {crypto.SHA512, nil},
+	"ES256": {crypto.SHA256, elliptic.P256()},
+	"ES384": {crypto.SHA384, elliptic.P384()},
+	"ES512": {crypto.SHA512, elliptic.P521()},
+}
+
+func decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// parse splits the token returning its header, the signed part and the
+// signature
+func parse(token string) (*header, []byte, []byte, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, nil, errors.New("malformed token")
+	}
+
+	hb, err := decode(parts[0])
+	if err != nil {
+		return nil, nil, nil, nil, errors.New("malformed token header")
+	}
+	var h header
+	if err := json.Unmarshal(hb, &h); err != nil {
+		return nil, nil, nil, nil, errors.New("malformed token header")
+	}
+
+	payload, err := decode(parts[1])
+	if err != nil {
+		return nil, nil, nil, nil, errors.New("malformed token claims")
+	}
+
+	sig, err := decode(parts[2])
+	if err != nil {
+		return nil, nil, nil, nil, errors.New("malformed token signature")
+	}
+
+	return &h, []byte(parts[0] + "." + parts[1]), payload, sig, nil
+}
+
+// verify checks the signature of the signed part was made with the key
+// by the algorithm
+func verify(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	a, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+
+	hasher := a.hash.New()
+	hasher.Write(signed)
+	digest := hasher.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if a.curve != nil {
+			return fmt.Errorf("key can't verify %s tokens", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, a.hash, digest, sig); err != nil {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if a.curve == nil || k.Curve != a.curve {
+			return fmt.Errorf("key can't verify %s tokens", alg)
+		}
+		// r and s of the curve's size
+		size := (a.curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("key can't verify %s tokens", alg)
+}
+
+// stringsOf returns the claim which is a string or list of strings
+func stringsOf(v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{t}, nil
+	case []interface{}:
+		var list []string
+		for _, i := range t {
+			s, ok := i.(string)
+			if !ok {
+				return nil, errors.New("not a string")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, errors.New("not a string")
+}
+
+// timeOf returns the claim in seconds since the epoch
+func timeOf(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case json.Number:
+		f, err := t.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		sec := int64(f)
+		return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+	}
+	return time.Time{}, errors.New("not a number")
+}
+
+// claimsOf decodes the claims of the payload
+func claimsOf(payload []byte) (*Claims, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+
+	c := &Claims{}
+	var err error
+
+	invalid := func(name string) error {
+		return fmt.Errorf("malformed token claim %s", name)
+	}
+
+	if v, ok := raw["iss"].(string); ok {
+		c.Issuer = v
+	} else if raw["iss"] != nil {
+		return nil, invalid("iss")
+	}
+	if v, ok := raw["sub"].(string); ok {
+		c.Subject = v
+	} else if raw["sub"] != nil {
+		return nil, invalid("sub")
+	}
+	if c.Audience, err = stringsOf(raw["aud"]); err != nil {
+		return nil, invalid("aud")
+	}
+	if c.Expires, err = timeOf(raw["exp"]); err != nil {
+		return nil, invalid("exp")
+	}
+	if c.NotBefore, err = timeOf(raw["nbf"]); err != nil {
+		return nil, invalid("nbf")
+	}
+
+	// scopes are a space separated scope, or a list in scp or scopes
+	if s, ok := raw["scope"].(string); ok {
+		c.Scopes = strings.Fields(s)
+	} else if raw["scope"] != nil {
+		return nil, invalid("scope")
+	}
+	for _, name := range []string{"scp", "scopes"} {
+		if s, ok := raw[name].(string); ok {
+			c.Scopes = append(c.Scopes, strings.Fields(s)...)
+			continue
+		}
+		scopes, err := stringsOf(raw[name])
+		if err != nil {
+			return nil, invalid(name)
+		}
+		c.Scopes = append(c.Scopes, scopes...)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/micro/go-log"
+)
+
+var (
+	// how long a JWKS is used before it's fetched again
+	DefaultRefresh = time.Hour
+	// the least time between fetching a JWKS for tokens of unknown keys
+	MinRefresh = 30 * time.Second
+)
+
+// keySet returns the key a token was signed with by its id
+type keySet interface {
+	Key(kid, alg string) (crypto.PublicKey, error)
+}
+
+// staticKey is a public key read from a file, used whatever the id
+type staticKey struct {
+	key crypto.PublicKey
+}
+
+func (s *staticKey) Key(kid, alg string) (crypto.PublicKey, error) {
+	return s.key, nil
+}
+
+// loadKey reads the PEM encoded public key or certificate of the file
+func loadKey(file string) (*staticKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key in %s", file)
+	}
+
+	var key interface{}
+
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing certificate %s: %v", file, err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key %s: %v", file, err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("public key %s must be rsa or ecdsa", file)
+	}
+
+	return &staticKey{key}, nil
+}
+
+// jwk is a key of a JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// rsa
+	N string `json:"n"`
+	E string `json:"e"`
+	// ecdsa
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey returns the key, an error if it's not a signing key
+func (j *jwk) publicKey() (crypto.PublicKey, error) {
+	if len(j.Use) > 0 && j.Use != "sig" {
+		return nil, fmt.Errorf("key %s isn't used for signatures", j.Kid)
+	}
+
+	number := func(s string) (*big.Int, error) {
+		b, err := decode(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("malformed key %s", j.Kid)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch j.Kty {
+	case "RSA":
+		n, err := number(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := number(j.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("malformed key %s", j.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("key %s has unsupported curve %q", j.Kid, j.Crv)
+		}
+		x, err := number(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := number(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("malformed key %s", j.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("key %s has unsupported type %q", j.Kid, j.Kty)
+}
+
+// jwks are the keys served by the url, fetched again once they're old
+// or a token is signed with a key which isn't one of them
+type jwks struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	sync.Mutex
+	keys    map[string]crypto.PublicKey
+	algs    map[string]string
+	fetched time.Time
+}
+
+func newJWKS(url string, refresh time.Duration) *jwks {
+	if refresh <= 0 {
+		refresh = DefaultRefresh
+	}
+	return &jwks{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// fetch gets the keys of the url, the lock must be held
+func (j *jwks) fetch() error {
+	rsp, err := j.client.Get(j.url)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", j.url, rsp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("malformed JWKS from %s: %v", j.url, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	algs := make(map[string]string)
+
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			// the others are still usable
+			log.Logf("[auth] skipping key of %s: %v", j.url, err)
+			continue
+		}
+		keys[k.Kid] = key
+		algs[k.Kid] = k.Alg
+	}
+
+	j.keys = keys
+	j.algs = algs
+	return nil
+}
+
+func (j *jwks) Key(kid, alg string) (crypto.PublicKey, error) {
+	j.Lock()
+	defer j.Unlock()
+
+	now := j.now()
+	_, known := j.keys[kid]
+
+	// refetched when old, or unknown keys may have been rotated in
+	if age := now.Sub(j.fetched); age >= j.refresh || (!known && age >= MinRefresh) {
+		if err := j.fetch(); err != nil {
+			log.Logf("[auth] error fetching JWKS: %v", err)
+		}
+		// failures aren't retried on every request
+		j.fetched = now
+	}
+
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if a := j.algs[kid]; len(a) > 0 && a != alg {
+		return nil, fmt.Errorf("key %q can't verify %s tokens", kid, alg)
+	}
+
+	return key, nil
+}
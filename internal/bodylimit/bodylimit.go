@@ -0,0 +1,142 @@
+// Package bodylimit limits the size of the bodies of requests to the api,
+// so clients can't exhaust its memory
+package bodylimit
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	merrors "github.com/micro/go-micro/errors"
+)
+
+// DefaultMax size of request bodies
+var DefaultMax int64 = 10 << 20
+
+type Options struct {
+	// Max size of request bodies in bytes
+	Max int64
+	// Id of the errors returned
+	Id string
+}
+
+type limit struct {
+	opts Options
+}
+
+// New returns the limit of the options
+func New(opts Options) *limit {
+	if opts.Max <= 0 {
+		opts.Max = DefaultMax
+	}
+	return &limit{opts: opts}
+}
+
+// body records whether the limit's been exceeded
+type body struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// writer replaces the response with the limit's error once the body of
+// the request has exceeded it, handlers mostly fail reading it
+type writer struct {
+	http.ResponseWriter
+	l    *limit
+	body *body
+	// the response's the limit's error
+	failed bool
+	wrote  bool
+}
+
+func (w *writer) check() bool {
+	if !w.wrote {
+		w.wrote = true
+		if w.body.exceeded {
+			w.failed = true
+			w.l.tooLarge(w.ResponseWriter)
+		}
+	}
+	return !w.failed
+}
+
+func (w *writer) WriteHeader(code int) {
+	if w.check() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if !w.check() {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.check() {
+		f.Flush()
+	}
+}
+
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("bodylimit: response can't be hijacked")
+	}
+	w.wrote = true
+	return hj.Hijack()
+}
+
+func (l *limit) tooLarge(w http.ResponseWriter) {
+	e := merrors.New(l.opts.Id, "request body larger than "+strconv.FormatInt(l.opts.Max, 10)+" bytes", http.StatusRequestEntityTooLarge)
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	w.Write([]byte(e.Error()))
+}
+
+// Handler responds 413 Request Entity Too Large to requests with bodies
+// larger than the limit. Those stating their length are refused before
+// being read, the rest once h has read past the limit.
+func (l *limit) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// websockets are streams, not bodies
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > l.opts.Max {
+			l.tooLarge(w)
+			return
+		}
+		if r.Body == nil || r.Body == http.NoBody {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		b := &body{ReadCloser: http.MaxBytesReader(w, r.Body, l.opts.Max)}
+		r.Body = b
+
+		lw := &writer{ResponseWriter: w, l: l, body: b}
+		h.ServeHTTP(lw, r)
+
+		// handlers which never responded
+		lw.check()
+	})
+}
@@ -0,0 +1,159 @@
+package bodylimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// endless is a body which never ends, counting what's read of it
+type endless struct {
+	n int64
+}
+
+func (e *endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'x'
+	}
+	atomic.AddInt64(&e.n, int64(len(p)))
+	return len(p), nil
+}
+
+// echo responds with the request body as the rpc handler does, failing if
+// it can't be read
+func echo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte(`{"detail":"` + err.Error() + `"}`))
+		return
+	}
+	w.Write(b)
+}
+
+func tooLarge(t *testing.T, rsp *http.Response) {
+	if rsp.StatusCode != http.StatusRequestEntityTooLarge || rsp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected 413 got %d %s", rsp.StatusCode, rsp.Header.Get("Content-Type"))
+	}
+	var e struct {
+		Id     string
+		Code   int
+		Detail string
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != "go.micro.api" || e.Code != 413 || e.Detail != "request body larger than 1024 bytes" {
+		t.Fatalf("expected the api's error got %+v", e)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(New(Options{Max: 1024, Id: "go.micro.api"}).Handler(http.HandlerFunc(echo)))
+	defer srv.Close()
+
+	testData := []struct {
+		size int
+		// the length isn't sent
+		chunked bool
+		ok      bool
+	}{
+		{0, false, true},
+		{1023, false, true},
+		{1024, false, true},
+		{1025, false, false},
+		{1024, true, true},
+		{1025, true, false},
+		{1 << 20, true, false},
+	}
+
+	for _, d := range testData {
+		var body io.Reader = bytes.NewReader(bytes.Repeat([]byte("x"), d.size))
+		if d.chunked {
+			// hides its length from the client
+			body = ioutil.NopCloser(body)
+		}
+
+		req, _ := http.NewRequest("POST", srv.URL, body)
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !d.ok {
+			tooLarge(t, rsp)
+			rsp.Body.Close()
+			continue
+		}
+
+		b, _ := ioutil.ReadAll(rsp.Body)
+		rsp.Body.Close()
+		if rsp.StatusCode != 200 || len(b) != d.size {
+			t.Fatalf("expected a body of %d bytes echoed got %d of %d", d.size, rsp.StatusCode, len(b))
+		}
+	}
+}
+
+func TestEndless(t *testing.T) {
+	var read int64
+	srv := httptest.NewServer(New(Options{Max: 1024, Id: "go.micro.api"}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		atomic.StoreInt64(&read, n)
+		w.Write([]byte("read"))
+	})))
+	defer srv.Close()
+
+	// stated lengths are refused before being read
+	body := &endless{}
+	req, _ := http.NewRequest("POST", srv.URL, io.LimitReader(body, 1<<30))
+	req.ContentLength = 1 << 30
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooLarge(t, rsp)
+	rsp.Body.Close()
+	if n := atomic.LoadInt64(&read); n != 0 {
+		t.Fatalf("expected the body unread got %d bytes read", n)
+	}
+
+	// the rest once read past the limit, the connection's closed rather
+	// than the body read to its end
+	body = &endless{}
+	req, _ = http.NewRequest("POST", srv.URL, body)
+	rsp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tooLarge(t, rsp)
+	rsp.Body.Close()
+	if n := atomic.LoadInt64(&read); n > 1024 {
+		t.Fatalf("expected at most the limit read got %d bytes", n)
+	}
+	if !rsp.Close {
+		t.Fatal("expected the connection closed")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	h := New(Options{Max: 1}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+
+	// upgrades aren't limited, their body is the stream
+	r := httptest.NewRequest("GET", "/ws", strings.NewReader("hello"))
+	r.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 || w.Body.String() != "hello" {
+		t.Fatalf("expected the websocket passed on got %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,211 @@
+// Package certs provides the certificates the api serves HTTPS with,
+// either a key pair reloaded on SIGHUP or those issued by ACME
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/micro/go-log"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	// DefaultCacheDir is where certificates issued by ACME are kept
+	DefaultCacheDir = cacheDir()
+	// DefaultCA is the directory of the ACME CA certificates are issued by
+	DefaultCA = acme.LetsEncryptURL
+)
+
+type Options struct {
+	// CertFile and KeyFile are the key pair served
+	CertFile string
+	KeyFile  string
+	// ClientCAFile verifies client certificates of the key pair if set
+	ClientCAFile string
+	// ACME issues certificates for the hosts instead of a key pair
+	ACME     bool
+	Hosts    []string
+	CacheDir string
+	Email    string
+	CA       string
+	// Address HTTPS is served on, plain HTTP is redirected to its port
+	Address string
+}
+
+type certs struct {
+	opts    Options
+	config  *tls.Config
+	manager *autocert.Manager
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "micro", "acme")
+}
+
+// New returns the certificates of the options
+func New(opts Options) (*certs, error) {
+	c := &certs{opts: opts}
+
+	switch {
+	case opts.ACME && (len(opts.CertFile) > 0 || len(opts.KeyFile) > 0):
+		return nil, errors.New("certs: use either ACME or a TLS key pair")
+	case opts.ACME:
+		if len(opts.Hosts) == 0 {
+			return nil, errors.New("certs: ACME needs the hosts to issue certificates for")
+		}
+		if len(opts.CacheDir) == 0 {
+			opts.CacheDir = DefaultCacheDir
+		}
+		if len(opts.CA) == 0 {
+			opts.CA = DefaultCA
+		}
+		c.manager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.Hosts...),
+			Cache:      autocert.DirCache(opts.CacheDir),
+			Email:      opts.Email,
+			Client:     &acme.Client{DirectoryURL: opts.CA},
+		}
+		c.config = c.manager.TLSConfig()
+		return c, nil
+	case len(opts.CertFile) == 0 || len(opts.KeyFile) == 0:
+		return nil, errors.New("certs: TLS certificate and key files not specified")
+	}
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	c.config = &tls.Config{
+		GetCertificate: c.getCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if len(opts.ClientCAFile) > 0 {
+		b, err := ioutil.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("certs: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("certs: no certificates in %s", opts.ClientCAFile)
+		}
+		c.config.ClientCAs = pool
+		c.config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return c, nil
+}
+
+func (c *certs) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.cert, nil
+}
+
+// TLSConfig is the config HTTPS is served with
+func (c *certs) TLSConfig() *tls.Config {
+	return c.config
+}
+
+// Reload loads the key pair again, the one loaded before is still served
+// if it fails. Certificates issued by ACME are renewed by themselves.
+func (c *certs) Reload() error {
+	if c.manager != nil {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.opts.CertFile, c.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("certs: error loading key pair %s: %v", c.opts.CertFile, err)
+	}
+
+	c.Lock()
+	c.cert = &cert
+	c.Unlock()
+	return nil
+}
+
+// Notify reloads the key pair whenever the process gets SIGHUP, until the
+// returned stop is called
+func (c *certs) Notify() (stop func()) {
+	if c.manager != nil {
+		return func() {}
+	}
+
+	ch := make(chan os.Signal, 1)
+	exit := make(chan bool)
+	signal.Notify(ch, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				if err := c.Reload(); err != nil {
+					log.Logf("Error reloading the TLS certificate, still serving the previous one: %v", err)
+					continue
+				}
+				log.Logf("Reloaded the TLS certificate %s", c.opts.CertFile)
+			case <-exit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(exit)
+	}
+}
+
+// HTTPHandler serves plain HTTP, answering ACME challenges and redirecting
+// everything else to HTTPS if redirect is set
+func (c *certs) HTTPHandler(redirect bool) http.Handler {
+	h := http.NotFoundHandler()
+	if redirect {
+		h = http.HandlerFunc(c.redirect)
+	}
+	if c.manager != nil {
+		h = c.manager.HTTPHandler(h)
+	}
+	return h
+}
+
+// redirect sends the request to the same url over HTTPS on the port it's
+// served on
+func (c *certs) redirect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Use HTTPS", http.StatusBadRequest)
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, port, err := net.SplitHostPort(c.opts.Address); err == nil && len(port) > 0 && port != "443" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusFound)
+}
@@ -0,0 +1,183 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self signed certificate of the serial and its key
+// to the dir
+func writeKeyPair(t *testing.T, dir string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kb, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600)
+	return certFile, keyFile
+}
+
+// serial returns the serial of the certificate served
+func serial(t *testing.T, c *certs) int64 {
+	cert, err := c.TLSConfig().GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeKeyPair(t, dir, 1)
+
+	testData := []struct {
+		opts Options
+		err  string
+	}{
+		{Options{ACME: true, Hosts: []string{"example.com"}}, ""},
+		{Options{CertFile: certFile, KeyFile: keyFile}, ""},
+		{Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, ""},
+		{Options{ACME: true}, "certs: ACME needs the hosts to issue certificates for"},
+		{Options{ACME: true, Hosts: []string{"example.com"}, CertFile: certFile, KeyFile: keyFile}, "certs: use either ACME or a TLS key pair"},
+		{Options{}, "certs: TLS certificate and key files not specified"},
+		{Options{CertFile: certFile}, "certs: TLS certificate and key files not specified"},
+		{Options{CertFile: keyFile, KeyFile: keyFile}, "certs: error loading key pair " + keyFile + ": "},
+		{Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, "certs: no certificates in " + keyFile},
+	}
+
+	for _, d := range testData {
+		_, err := New(d.opts)
+		if len(d.err) == 0 && err != nil {
+			t.Fatalf("%+v: unexpected error %v", d.opts, err)
+		}
+		// errors are matched by prefix, those of the key pair vary
+		if len(d.err) > 0 && (err == nil || !strings.HasPrefix(err.Error(), d.err)) {
+			t.Fatalf("%+v: expected error %q got %v", d.opts, d.err, err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeKeyPair(t, dir, 1)
+
+	c, err := New(Options{CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := serial(t, c); s != 1 {
+		t.Fatalf("expected certificate 1 served got %d", s)
+	}
+
+	writeKeyPair(t, dir, 2)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if s := serial(t, c); s != 2 {
+		t.Fatalf("expected the reloaded certificate 2 served got %d", s)
+	}
+
+	// a bad key pair isn't served
+	ioutil.WriteFile(certFile, []byte("garbage"), 0600)
+	if err := c.Reload(); err == nil {
+		t.Fatal("expected an error reloading a bad key pair")
+	}
+	if s := serial(t, c); s != 2 {
+		t.Fatalf("expected certificate 2 still served got %d", s)
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeKeyPair(t, dir, 1)
+
+	acme, err := New(Options{ACME: true, Hosts: []string{"example.com"}, CacheDir: dir, Address: ":443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := New(Options{CertFile: certFile, KeyFile: keyFile, Address: ":8443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testData := []struct {
+		name     string
+		handler  http.Handler
+		method   string
+		url      string
+		code     int
+		location string
+	}{
+		{"redirect", acme.HTTPHandler(true), "GET", "http://example.com/foo?bar=baz", http.StatusFound, "https://example.com/foo?bar=baz"},
+		{"redirect port", pair.HTTPHandler(true), "GET", "http://example.com:8080/foo", http.StatusFound, "https://example.com:8443/foo"},
+		{"redirect ipv6", pair.HTTPHandler(true), "HEAD", "http://[::1]/foo", http.StatusFound, "https://[::1]:8443/foo"},
+		{"redirect post", pair.HTTPHandler(true), "POST", "http://example.com/foo", http.StatusBadRequest, ""},
+		{"no redirect", acme.HTTPHandler(false), "GET", "http://example.com/foo", http.StatusNotFound, ""},
+		{"challenge", acme.HTTPHandler(true), "GET", "http://example.com/.well-known/acme-challenge/token", http.StatusNotFound, ""},
+		{"challenge of other host", acme.HTTPHandler(true), "GET", "http://evil.com/.well-known/acme-challenge/token", http.StatusForbidden, ""},
+		{"no challenges", pair.HTTPHandler(false), "GET", "http://example.com/.well-known/acme-challenge/token", http.StatusNotFound, ""},
+	}
+
+	for _, d := range testData {
+		w := httptest.NewRecorder()
+		d.handler.ServeHTTP(w, httptest.NewRequest(d.method, d.url, nil))
+		if w.Code != d.code {
+			t.Fatalf("%s: expected %d got %d", d.name, d.code, w.Code)
+		}
+		if l := w.Header().Get("Location"); l != d.location {
+			t.Fatalf("%s: expected location %q got %q", d.name, d.location, l)
+		}
+	}
+}
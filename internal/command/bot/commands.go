@@ -1,11 +1,12 @@
 package bot
 
 import (
+	"context"
 	"strings"
 	"time"
 
 	"github.com/micro/cli"
-	"github.com/micro/go-bot/command"
+	"github.com/micro/micro/bot/command"
 	clic "github.com/micro/micro/internal/command/cli"
 )
 
@@ -14,12 +15,12 @@ func Echo(ctx *cli.Context) command.Command {
 	usage := "echo [text]"
 	desc := "Returns the [text]"
 
-	return command.NewCommand("echo", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("echo", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		if len(args) < 2 {
 			return []byte("echo what?"), nil
 		}
 		return []byte(strings.Join(args[1:], " ")), nil
-	})
+	}), "general")
 }
 
 // Hello returns a greeting
@@ -27,9 +28,9 @@ func Hello(ctx *cli.Context) command.Command {
 	usage := "hello"
 	desc := "Returns a greeting"
 
-	return command.NewCommand("hello", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("hello", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		return []byte("hey what's up?"), nil
-	})
+	}), "general")
 }
 
 // Ping returns pong
@@ -37,9 +38,9 @@ func Ping(ctx *cli.Context) command.Command {
 	usage := "ping"
 	desc := "Returns pong"
 
-	return command.NewCommand("ping", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("ping", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		return []byte("pong"), nil
-	})
+	}), "general")
 }
 
 // Get service returns a service
@@ -47,7 +48,7 @@ func Get(ctx *cli.Context) command.Command {
 	usage := "get service [name]"
 	desc := "Returns a registered service"
 
-	return command.NewCommand("get", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("get", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		if len(args) < 2 {
 			return []byte("get what?"), nil
 		}
@@ -64,24 +65,7 @@ func Get(ctx *cli.Context) command.Command {
 		default:
 			return []byte("unknown command...\nsupported commands: \nget service [name]"), nil
 		}
-	})
-}
-
-// Health returns the health of a service
-func Health(ctx *cli.Context) command.Command {
-	usage := "health [service]"
-	desc := "Returns health of a service"
-
-	return command.NewCommand("health", usage, desc, func(args ...string) ([]byte, error) {
-		if len(args) < 2 {
-			return []byte("health of what?"), nil
-		}
-		rsp, err := clic.QueryHealth(ctx, args[1:])
-		if err != nil {
-			return nil, err
-		}
-		return rsp, nil
-	})
+	}), "registry")
 }
 
 // List returns a list of services
@@ -89,7 +73,7 @@ func List(ctx *cli.Context) command.Command {
 	usage := "list services"
 	desc := "Returns a list of registered services"
 
-	return command.NewCommand("list", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("list", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		if len(args) < 2 {
 			return []byte("list what?"), nil
 		}
@@ -103,34 +87,7 @@ func List(ctx *cli.Context) command.Command {
 		default:
 			return []byte("unknown command...\nsupported commands: \nlist services"), nil
 		}
-	})
-}
-
-// Call returns a service call
-func Call(ctx *cli.Context) command.Command {
-	usage := "call [service] [endpoint] [request]"
-	desc := "Returns the response for a service call"
-
-	return command.NewCommand("call", usage, desc, func(args ...string) ([]byte, error) {
-		var cargs []string
-
-		for _, arg := range args {
-			if len(strings.TrimSpace(arg)) == 0 {
-				continue
-			}
-			cargs = append(cargs, arg)
-		}
-
-		if len(cargs) < 2 {
-			return []byte("call what?"), nil
-		}
-
-		rsp, err := clic.CallService(ctx, cargs[1:])
-		if err != nil {
-			return nil, err
-		}
-		return rsp, nil
-	})
+	}), "registry")
 }
 
 // Register registers a service
@@ -138,7 +95,7 @@ func Register(ctx *cli.Context) command.Command {
 	usage := "register service [definition]"
 	desc := "Registers a service"
 
-	return command.NewCommand("register", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("register", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		if len(args) < 2 {
 			return []byte("register what?"), nil
 		}
@@ -155,7 +112,7 @@ func Register(ctx *cli.Context) command.Command {
 		default:
 			return []byte("unknown command...\nsupported commands: \nregister service [definition]"), nil
 		}
-	})
+	}), "registry")
 }
 
 // Deregister registers a service
@@ -163,7 +120,7 @@ func Deregister(ctx *cli.Context) command.Command {
 	usage := "deregister service [definition]"
 	desc := "Deregisters a service"
 
-	return command.NewCommand("deregister", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("deregister", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		if len(args) < 2 {
 			return []byte("deregister what?"), nil
 		}
@@ -180,7 +137,7 @@ func Deregister(ctx *cli.Context) command.Command {
 		default:
 			return []byte("unknown command...\nsupported commands: \nderegister service [definition]"), nil
 		}
-	})
+	}), "registry")
 }
 
 // Laws of robotics
@@ -188,14 +145,14 @@ func ThreeLaws(ctx *cli.Context) command.Command {
 	usage := "the three laws"
 	desc := "Returns the three laws of robotics"
 
-	return command.NewCommand("the three laws", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("the three laws", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		laws := []string{
 			"1. A robot may not injure a human being or, through inaction, allow a human being to come to harm.",
 			"2. A robot must obey the orders given it by human beings except where such orders would conflict with the First Law.",
 			"3. A robot must protect its own existence as long as such protection does not conflict with the First or Second Laws.",
 		}
 		return []byte("\n" + strings.Join(laws, "\n")), nil
-	})
+	}), "general")
 }
 
 // Time returns the time
@@ -203,8 +160,8 @@ func Time(ctx *cli.Context) command.Command {
 	usage := "time"
 	desc := "Returns the server time"
 
-	return command.NewCommand("time", usage, desc, func(args ...string) ([]byte, error) {
+	return command.WithGroup(command.NewContextCommand("time", usage, desc, func(c context.Context, args ...string) ([]byte, error) {
 		t := time.Now().Format(time.RFC1123)
 		return []byte("Server time is: " + t), nil
-	})
+	}), "general")
 }
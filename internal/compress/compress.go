@@ -0,0 +1,314 @@
+// Package compress encodes the responses of the api with gzip or deflate
+// for clients which accept them
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// DefaultMinSize of responses compressed, smaller ones aren't worth it
+	DefaultMinSize = 1024
+	// DefaultTypes of responses compressed, others are likely compressed
+	// already e.g. images
+	DefaultTypes = []string{
+		"application/javascript",
+		"application/json",
+		"application/xml",
+		"image/svg+xml",
+		"text/*",
+	}
+)
+
+type Options struct {
+	// Level of compression, gzip.DefaultCompression if zero
+	Level int
+	// MinSize of responses compressed, the default if zero
+	MinSize int
+	// Types of responses compressed e.g. application/json or text/*, the
+	// defaults if empty
+	Types []string
+	// Deflate is used for clients which accept it but not gzip
+	Deflate bool
+}
+
+type compress struct {
+	opts Options
+
+	gzip sync.Pool
+	zlib sync.Pool
+}
+
+// New returns the compression of the options
+func New(opts Options) (*compress, error) {
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultMinSize
+	}
+	if len(opts.Types) == 0 {
+		opts.Types = DefaultTypes
+	}
+
+	// checks the level is valid
+	if _, err := gzip.NewWriterLevel(nil, opts.Level); err != nil {
+		return nil, errors.New("compress: invalid level " + strconv.Itoa(opts.Level))
+	}
+
+	c := &compress{opts: opts}
+	c.gzip.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, opts.Level)
+		return w
+	}
+	c.zlib.New = func() interface{} {
+		w, _ := zlib.NewWriterLevel(nil, opts.Level)
+		return w
+	}
+	return c, nil
+}
+
+// encoding returns the encoding of the response the client accepts, the
+// most preferred
+func (c *compress) encoding(accept string) string {
+	var enc string
+	var best float64
+
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		q := 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			param := strings.TrimSpace(part[i+1:])
+			part = strings.TrimSpace(part[:i])
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					continue
+				}
+				q = v
+			}
+		}
+
+		var e string
+		switch strings.ToLower(part) {
+		case "gzip", "*":
+			e = "gzip"
+		case "deflate":
+			if !c.opts.Deflate {
+				continue
+			}
+			e = "deflate"
+		default:
+			continue
+		}
+
+		// gzip is preferred when they're as acceptable
+		if q > best || (q == best && e == "gzip") {
+			enc, best = e, q
+		}
+	}
+
+	if best <= 0 {
+		return ""
+	}
+	return enc
+}
+
+// compressible returns whether responses of the content type are
+func (c *compress) compressible(ct string) bool {
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	ct = strings.ToLower(strings.TrimSpace(ct))
+
+	for _, t := range c.opts.Types {
+		if t == ct || (strings.HasSuffix(t, "/*") && strings.HasPrefix(ct, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// writer buffers the response until it's decided whether it's compressed,
+// when it's larger than the minimum size, flushed or finished
+type writer struct {
+	http.ResponseWriter
+	c        *compress
+	encoding string
+	head     bool
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	// the compressor of the response, nil if it isn't compressed
+	w io.WriteCloser
+}
+
+func (w *writer) WriteHeader(code int) {
+	// informational responses precede the response
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+	// there's no body to compress
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf.Write(b)
+
+		// responses known to be small aren't buffered
+		if n, err := strconv.Atoi(w.Header().Get("Content-Length")); err == nil && n < w.c.opts.MinSize {
+			w.decide(false)
+			return len(b), nil
+		}
+		if w.buf.Len() >= w.c.opts.MinSize {
+			w.decide(true)
+		}
+		return len(b), nil
+	}
+
+	if w.w != nil {
+		return w.w.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the headers and buffered response, compressing it if big
+// enough and the client accepts it
+func (w *writer) decide(big bool) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	hdr := w.Header()
+	if len(hdr.Get("Content-Type")) == 0 && w.buf.Len() > 0 {
+		hdr.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	// responses encoded already, partial and without bodies aren't
+	// compressed
+	eligible := len(hdr.Get("Content-Encoding")) == 0 &&
+		len(hdr.Get("Content-Range")) == 0 &&
+		w.status != http.StatusPartialContent &&
+		w.status >= 200 && w.status != http.StatusNoContent && w.status != http.StatusNotModified &&
+		w.c.compressible(hdr.Get("Content-Type"))
+
+	if eligible {
+		hdr.Add("Vary", "Accept-Encoding")
+	}
+
+	if eligible && big && len(w.encoding) > 0 && !w.head {
+		hdr.Set("Content-Encoding", w.encoding)
+		// the length is of the compressed body, which isn't known
+		hdr.Del("Content-Length")
+
+		if w.encoding == "gzip" {
+			gz := w.c.gzip.Get().(*gzip.Writer)
+			gz.Reset(w.ResponseWriter)
+			w.w = gz
+		} else {
+			zw := w.c.zlib.Get().(*zlib.Writer)
+			zw.Reset(w.ResponseWriter)
+			w.w = zw
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return
+	}
+	if w.w != nil {
+		w.w.Write(w.buf.Bytes())
+	} else {
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+}
+
+// Flush writes what's been written so far, streamed responses are
+// compressed without knowing their size
+func (w *writer) Flush() {
+	w.decide(true)
+
+	switch zw := w.w.(type) {
+	case *gzip.Writer:
+		zw.Flush()
+	case *zlib.Writer:
+		zw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes the connection on, websockets aren't compressed
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("compress: response can't be hijacked")
+	}
+	if w.decided || w.buf.Len() > 0 {
+		return nil, nil, errors.New("compress: response written before being hijacked")
+	}
+	w.decided = true
+	return hj.Hijack()
+}
+
+// finish writes the rest of the response
+func (w *writer) finish() {
+	if !w.decided {
+		// the whole response is buffered so its length is known
+		if len(w.Header().Get("Content-Length")) == 0 && w.buf.Len() > 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(w.buf.Len()))
+		}
+		w.decide(false)
+	}
+
+	switch zw := w.w.(type) {
+	case *gzip.Writer:
+		zw.Close()
+		w.c.gzip.Put(zw)
+	case *zlib.Writer:
+		zw.Close()
+		w.c.zlib.Put(zw)
+	}
+}
+
+// Handler compresses the responses of h for clients which accept them
+func (c *compress) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &writer{
+			ResponseWriter: w,
+			c:              c,
+			encoding:       c.encoding(r.Header.Get("Accept-Encoding")),
+			head:           r.Method == "HEAD",
+		}
+		defer cw.finish()
+
+		h.ServeHTTP(cw, r)
+	})
+}
@@ -0,0 +1,302 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listing is a large JSON response
+var listing = func() []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"services":[`)
+	for i := 0; i < 500; i++ {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"name":"go.micro.srv.example-%d","version":"latest"}`, i)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}()
+
+func decode(t *testing.T, enc string, body io.Reader) []byte {
+	var r io.Reader
+	var err error
+
+	switch enc {
+	case "gzip":
+		r, err = gzip.NewReader(body)
+	case "deflate":
+		r, err = zlib.NewReader(body)
+	default:
+		r = body
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHandler(t *testing.T) {
+	testData := []struct {
+		name    string
+		method  string
+		accept  string
+		ct      string
+		length  bool
+		status  int
+		encoded string
+		body    []byte
+		// the response varies by the encodings accepted
+		vary bool
+		// the response's encoded by the handler
+		encoding string
+	}{
+		{name: "gzip", accept: "gzip, deflate", ct: "application/json", encoded: "gzip", body: listing, vary: true},
+		{name: "gzip with length", accept: "gzip", ct: "application/json", length: true, encoded: "gzip", body: listing, vary: true},
+		{name: "deflate", accept: "deflate", ct: "application/json; charset=utf-8", encoded: "deflate", body: listing, vary: true},
+		{name: "preferred", accept: "gzip;q=0.5, deflate", ct: "text/plain", encoded: "deflate", body: listing, vary: true},
+		{name: "any", accept: "*", ct: "text/html", encoded: "gzip", body: listing, vary: true},
+		{name: "not accepted", accept: "gzip;q=0, br", ct: "application/json", body: listing, vary: true},
+		{name: "no accept", ct: "application/json", body: listing, vary: true},
+		{name: "small", accept: "gzip", ct: "application/json", body: []byte(`{"ok":true}`), vary: true},
+		{name: "small with length", accept: "gzip", ct: "application/json", length: true, body: []byte(`{"ok":true}`), vary: true},
+		{name: "sniffed", accept: "gzip", encoded: "gzip", body: listing, vary: true},
+		{name: "image", accept: "gzip", ct: "image/png", body: listing},
+		{name: "encoded", accept: "gzip", ct: "application/json", body: listing, encoding: "br"},
+		{name: "head", method: "HEAD", accept: "gzip", ct: "application/json", length: true, vary: true},
+		{name: "error", accept: "gzip", ct: "application/json", status: 500, encoded: "gzip", body: listing, vary: true},
+		{name: "no content", accept: "gzip", status: 204},
+	}
+
+	c, err := New(Options{Deflate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range testData {
+		srv := httptest.NewServer(c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(d.ct) > 0 {
+				w.Header().Set("Content-Type", d.ct)
+			}
+			if len(d.encoding) > 0 {
+				w.Header().Set("Content-Encoding", d.encoding)
+			}
+			if d.length {
+				n := len(d.body)
+				if d.method == "HEAD" {
+					n = len(listing)
+				}
+				w.Header().Set("Content-Length", strconv.Itoa(n))
+			}
+			if d.status > 0 {
+				w.WriteHeader(d.status)
+			}
+			// written in parts as handlers may
+			for b := d.body; len(b) > 0; {
+				n := 100
+				if n > len(b) {
+					n = len(b)
+				}
+				w.Write(b[:n])
+				b = b[n:]
+			}
+		})))
+
+		method := d.method
+		if len(method) == 0 {
+			method = "GET"
+		}
+		req, _ := http.NewRequest(method, srv.URL, nil)
+		if len(d.accept) > 0 {
+			req.Header.Set("Accept-Encoding", d.accept)
+		}
+
+		// the transport mustn't decode responses itself
+		tr := &http.Transport{DisableCompression: true}
+		rsp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		enc := rsp.Header.Get("Content-Encoding")
+		if len(d.encoding) > 0 {
+			if enc != d.encoding {
+				t.Fatalf("%s: expected the handler's encoding %s got %s", d.name, d.encoding, enc)
+			}
+		} else if enc != d.encoded {
+			t.Fatalf("%s: expected the response encoded %q got %q", d.name, d.encoded, enc)
+		}
+
+		if vary := rsp.Header.Get("Vary") == "Accept-Encoding"; vary != d.vary {
+			t.Fatalf("%s: expected varying by the encoding %v got %q", d.name, d.vary, rsp.Header.Get("Vary"))
+		}
+
+		status := d.status
+		if status == 0 {
+			status = 200
+		}
+		if rsp.StatusCode != status {
+			t.Fatalf("%s: expected status %d got %d", d.name, status, rsp.StatusCode)
+		}
+
+		raw, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// lengths are of what's sent, compressed responses are chunked
+		// unless the server buffered all of it
+		switch {
+		case d.method == "HEAD":
+			if rsp.ContentLength != int64(len(listing)) {
+				t.Fatalf("%s: expected the handler's length got %d", d.name, rsp.ContentLength)
+			}
+		case len(d.encoded) > 0:
+			chunked := len(rsp.TransferEncoding) > 0 && rsp.TransferEncoding[0] == "chunked"
+			if rsp.ContentLength != int64(len(raw)) && !chunked {
+				t.Fatalf("%s: expected the compressed length %d or chunked got %d %v", d.name, len(raw), rsp.ContentLength, rsp.TransferEncoding)
+			}
+		case status != 204 && len(d.body) < DefaultMinSize:
+			if rsp.ContentLength != int64(len(d.body)) {
+				t.Fatalf("%s: expected the length %d got %d", d.name, len(d.body), rsp.ContentLength)
+			}
+		}
+
+		if d.method != "HEAD" {
+			if b := decode(t, d.encoded, bytes.NewReader(raw)); !bytes.Equal(b, d.body) {
+				t.Fatalf("%s: expected the response decoded to the original got %d bytes", d.name, len(b))
+			}
+			if len(d.encoded) > 0 && len(raw) >= len(d.body) {
+				t.Fatalf("%s: expected the response compressed got %d of %d bytes", d.name, len(raw), len(d.body))
+			}
+		}
+
+		rsp.Body.Close()
+		srv.Close()
+	}
+}
+
+func TestStream(t *testing.T) {
+	c, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := make(chan bool)
+	srv := httptest.NewServer(c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(http.Flusher).Flush()
+			<-next
+		}
+	})))
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rsp, err := (&http.Client{Transport: &http.Transport{DisableCompression: true}}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected the stream gzipped got %q", rsp.Header.Get("Content-Encoding"))
+	}
+
+	gz, err := gzip.NewReader(rsp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(gz)
+
+	// each event's received as it's flushed, before the next is written
+	for i := 0; i < 3; i++ {
+		got := make(chan string, 1)
+		go func() {
+			l, _ := r.ReadString('\n')
+			r.ReadString('\n')
+			got <- l
+		}()
+
+		select {
+		case l := <-got:
+			if l != fmt.Sprintf("data: %d\n", i) {
+				t.Fatalf("expected event %d got %q", i, l)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected event %d flushed", i)
+		}
+		next <- true
+	}
+
+	if b, err := ioutil.ReadAll(r); err != nil || len(b) > 0 {
+		t.Fatalf("expected the stream to end got %q %v", b, err)
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	testData := []struct {
+		accept   string
+		deflate  bool
+		encoding string
+	}{
+		{"", true, ""},
+		{"gzip", false, "gzip"},
+		{"GZIP", false, "gzip"},
+		{"deflate", false, ""},
+		{"deflate", true, "deflate"},
+		{"deflate, gzip", true, "gzip"},
+		{"deflate;q=1.0, gzip;q=0.8", true, "deflate"},
+		{"gzip;q=0", true, ""},
+		{"gzip;q=bad", true, ""},
+		{"*;q=0.1", false, "gzip"},
+		{"br, identity", true, ""},
+	}
+
+	for _, d := range testData {
+		c, _ := New(Options{Deflate: d.deflate})
+		if e := c.encoding(d.accept); e != d.encoding {
+			t.Fatalf("expected %q to accept %q got %q", d.accept, d.encoding, e)
+		}
+	}
+
+	if _, err := New(Options{Level: 10}); err == nil || !strings.Contains(err.Error(), "invalid level") {
+		t.Fatalf("expected an invalid level error got %v", err)
+	}
+}
+
+func BenchmarkHandler(b *testing.B) {
+	c, _ := New(Options{})
+	h := c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(listing)
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(listing)))
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
@@ -0,0 +1,211 @@
+// Package cors answers cross origin requests so browsers may call the
+// api from other origins
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	DefaultMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	DefaultHeaders = []string{"Accept", "Authorization", "Content-Type"}
+)
+
+type Options struct {
+	// Origins allowed e.g. https://example.com, https://*.example.com
+	// or * for any
+	Origins []string
+	// Methods allowed, the defaults if empty
+	Methods []string
+	// Headers allowed, the defaults if empty or * for any requested
+	Headers []string
+	// Exposed headers of responses scripts may read
+	Exposed []string
+	// MaxAge preflights are cached for, not sent if zero
+	MaxAge time.Duration
+	// Credentials allows cookies and auth to be sent
+	Credentials bool
+}
+
+type cors struct {
+	opts Options
+	// any origin is allowed
+	any bool
+	// any header is allowed
+	anyHeader bool
+}
+
+// New returns the cors handling of the options. Credentials can't be
+// allowed for any origin, browsers reject those responses.
+func New(opts Options) (*cors, error) {
+	if len(opts.Origins) == 0 {
+		return nil, errors.New("cors: no origins allowed")
+	}
+	if len(opts.Methods) == 0 {
+		opts.Methods = DefaultMethods
+	}
+	if len(opts.Headers) == 0 {
+		opts.Headers = DefaultHeaders
+	}
+	opts.Headers = unique(opts.Headers)
+	opts.Exposed = unique(opts.Exposed)
+
+	c := &cors{opts: opts}
+
+	for _, o := range opts.Origins {
+		if o == "*" {
+			c.any = true
+		}
+		if strings.Count(o, "*") > 1 || (o != "*" && strings.Contains(o, "*") && !strings.Contains(o, "://*.")) {
+			return nil, errors.New("cors: origin " + o + " may only have a wildcard subdomain e.g. https://*.example.com")
+		}
+	}
+	for _, h := range opts.Headers {
+		if h == "*" {
+			c.anyHeader = true
+		}
+	}
+
+	if c.any && opts.Credentials {
+		return nil, errors.New("cors: credentials can't be allowed for any origin, list the origins instead")
+	}
+
+	return c, nil
+}
+
+// match returns whether the origin is allowed
+func match(pattern, origin string) bool {
+	if pattern == "*" {
+		return true
+	}
+
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return strings.EqualFold(pattern, origin)
+	}
+
+	prefix, suffix := strings.ToLower(pattern[:i]), strings.ToLower(pattern[i+1:])
+	origin = strings.ToLower(origin)
+
+	if len(origin) <= len(prefix)+len(suffix) {
+		return false
+	}
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	// the subdomain mustn't be a path or port
+	sub := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(sub, "/:")
+}
+
+func (c *cors) allowed(origin string) bool {
+	for _, o := range c.opts.Origins {
+		if match(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// unique returns the headers without those repeated
+func unique(headers []string) []string {
+	var list []string
+	for _, h := range headers {
+		if !contains(list, h) {
+			list = append(list, h)
+		}
+	}
+	return list
+}
+
+// preflight returns whether the requested method and headers are allowed
+func (c *cors) preflight(r *http.Request) bool {
+	if !contains(c.opts.Methods, r.Header.Get("Access-Control-Request-Method")) {
+		return false
+	}
+	if c.anyHeader {
+		return true
+	}
+	for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+		if h = strings.TrimSpace(h); len(h) > 0 && !contains(c.opts.Headers, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// Handler answers preflight requests rather than passing them to h and
+// adds the headers allowing the origin to the responses of h
+func (c *cors) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == "OPTIONS" && len(r.Header.Get("Access-Control-Request-Method")) > 0
+
+		if preflight {
+			w.Header().Add("Vary", "Origin")
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		} else if !c.any || c.opts.Credentials {
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if len(origin) == 0 || !c.allowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if c.any {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		if c.opts.Credentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if len(c.opts.Exposed) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.opts.Exposed, ", "))
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if !c.preflight(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.opts.Methods, ", "))
+		if c.anyHeader {
+			if hdrs := r.Header.Get("Access-Control-Request-Headers"); len(hdrs) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", hdrs)
+			}
+		} else {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.opts.Headers, ", "))
+		}
+		if c.opts.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.opts.MaxAge/time.Second)))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,207 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	testData := []struct {
+		pattern string
+		origin  string
+		match   bool
+	}{
+		{"*", "https://example.com", true},
+		{"https://example.com", "https://example.com", true},
+		{"https://example.com", "https://EXAMPLE.com", true},
+		{"https://example.com", "http://example.com", false},
+		{"https://example.com", "https://example.com:8080", false},
+		{"https://example.com", "https://api.example.com", false},
+		{"https://*.example.com", "https://api.example.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://.example.com", false},
+		{"https://*.example.com", "https://evil.com/.example.com", false},
+		{"https://*.example.com", "https://evil.com:1.example.com", false},
+		{"https://*.example.com", "https://api.example.com.evil.com", false},
+		{"https://*.example.com", "http://api.example.com", false},
+	}
+
+	for _, d := range testData {
+		if m := match(d.pattern, d.origin); m != d.match {
+			t.Fatalf("%s %s: expected match %v got %v", d.pattern, d.origin, d.match, m)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	testData := []struct {
+		opts Options
+		err  string
+	}{
+		{Options{}, "cors: no origins allowed"},
+		// the spec forbids credentials with a wildcard origin
+		{Options{Origins: []string{"https://example.com", "*"}, Credentials: true}, "cors: credentials can't be allowed for any origin, list the origins instead"},
+		{Options{Origins: []string{"https://*.*.example.com"}}, "cors: origin https://*.*.example.com may only have a wildcard subdomain e.g. https://*.example.com"},
+		{Options{Origins: []string{"https://example.*"}}, "cors: origin https://example.* may only have a wildcard subdomain e.g. https://*.example.com"},
+		{Options{Origins: []string{"*"}}, ""},
+		{Options{Origins: []string{"https://*.example.com"}, Credentials: true}, ""},
+	}
+
+	for _, d := range testData {
+		_, err := New(d.opts)
+		if len(d.err) == 0 && err != nil {
+			t.Fatalf("%+v: unexpected error %v", d.opts, err)
+		}
+		if len(d.err) > 0 && (err == nil || err.Error() != d.err) {
+			t.Fatalf("%+v: expected error %q got %v", d.opts, d.err, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var proxied int
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied++
+		w.Write([]byte("ok"))
+	})
+
+	request := func(c *cors, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/foo/bar", nil)
+		if len(origin) > 0 {
+			r.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		c.Handler(backend).ServeHTTP(w, r)
+		return w
+	}
+
+	creds, err := New(Options{
+		Origins:     []string{"https://app.example.com", "https://*.example.org"},
+		MaxAge:      10 * time.Minute,
+		Credentials: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	preflight := map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, authorization",
+	}
+
+	// preflights are answered rather than proxied
+	w := request(creds, "OPTIONS", "https://app.example.com", preflight)
+	if w.Code != http.StatusNoContent || proxied != 0 {
+		t.Fatalf("expected the preflight answered got %d, proxied %d", w.Code, proxied)
+	}
+	for k, v := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers":     "Accept, Authorization, Content-Type",
+		"Access-Control-Max-Age":           "600",
+	} {
+		if got := w.Header().Get(k); got != v {
+			t.Fatalf("expected %s %q got %q", k, v, got)
+		}
+	}
+
+	// those of other origins, methods or headers are refused
+	for _, d := range []struct {
+		origin  string
+		headers map[string]string
+	}{
+		{"https://evil.com", preflight},
+		{"https://app.example.com", map[string]string{"Access-Control-Request-Method": "CONNECT"}},
+		{"https://app.example.com", map[string]string{"Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"}},
+	} {
+		w := request(creds, "OPTIONS", d.origin, d.headers)
+		if w.Code != http.StatusForbidden || proxied != 0 {
+			t.Fatalf("%s %v: expected the preflight refused got %d, proxied %d", d.origin, d.headers, w.Code, proxied)
+		}
+		if len(w.Header().Get("Access-Control-Allow-Methods")) > 0 {
+			t.Fatalf("%s %v: unexpected methods allowed", d.origin, d.headers)
+		}
+	}
+
+	// allowed origins are echoed rather than the wildcard with credentials
+	w = request(creds, "POST", "https://api.example.org", nil)
+	if w.Code != http.StatusOK || proxied != 1 || w.Body.String() != "ok" {
+		t.Fatalf("expected the request proxied got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://api.example.org" || w.Header().Get("Vary") != "Origin" {
+		t.Fatalf("unexpected headers %v", w.Header())
+	}
+
+	// other origins and same origin requests are proxied without them
+	for _, origin := range []string{"https://evil.com", ""} {
+		w = request(creds, "GET", origin, nil)
+		if w.Code != http.StatusOK || len(w.Header().Get("Access-Control-Allow-Origin")) > 0 {
+			t.Fatalf("%q: unexpected response %d %v", origin, w.Code, w.Header())
+		}
+	}
+
+	// plain OPTIONS requests aren't preflights
+	proxied = 0
+	if w = request(creds, "OPTIONS", "https://app.example.com", nil); proxied != 1 {
+		t.Fatalf("expected the request proxied got %d", w.Code)
+	}
+
+	any, err := New(Options{Origins: []string{"*"}, Headers: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w = request(any, "OPTIONS", "https://anywhere.com", map[string]string{
+		"Access-Control-Request-Method":  "PUT",
+		"Access-Control-Request-Headers": "X-Custom",
+	})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected the preflight answered got %d", w.Code)
+	}
+	for k, v := range map[string]string{
+		"Access-Control-Allow-Origin":      "*",
+		"Access-Control-Allow-Credentials": "",
+		"Access-Control-Allow-Headers":     "X-Custom",
+		"Access-Control-Max-Age":           "",
+	} {
+		if got := w.Header().Get(k); got != v {
+			t.Fatalf("expected %s %q got %q", k, v, got)
+		}
+	}
+
+	exposed, err := New(Options{
+		Origins: []string{"*"},
+		Headers: []string{"Content-Type", "X-Grpc-Web", "content-type"},
+		Exposed: []string{"Grpc-Status", "Grpc-Message", "grpc-status"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exposed headers are only sent with responses, once each
+	w = request(exposed, "OPTIONS", "https://anywhere.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "x-grpc-web",
+	})
+	if w.Code != http.StatusNoContent || len(w.Header().Get("Access-Control-Expose-Headers")) > 0 {
+		t.Fatalf("unexpected preflight response %d %v", w.Code, w.Header())
+	}
+	if h := w.Header().Get("Access-Control-Allow-Headers"); h != "Content-Type, X-Grpc-Web" {
+		t.Fatalf("expected the headers allowed once got %q", h)
+	}
+
+	w = request(exposed, "POST", "https://anywhere.com", nil)
+	if h := w.Header().Get("Access-Control-Expose-Headers"); h != "Grpc-Status, Grpc-Message" {
+		t.Fatalf("expected the headers exposed got %q", h)
+	}
+	if w = request(exposed, "POST", "", nil); len(w.Header().Get("Access-Control-Expose-Headers")) > 0 {
+		t.Fatal("expected no headers exposed to same origin requests")
+	}
+}
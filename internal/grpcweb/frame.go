@@ -0,0 +1,169 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// flags of frames, the body of trailer frames are the trailers
+	dataFrame       byte = 0x00
+	compressedFrame byte = 0x01
+	trailerFrame    byte = 0x80
+
+	// the largest message accepted, as gRPC's default
+	maxMessageSize = 4 << 20
+)
+
+// codec passes messages through as they're framed, they're never decoded
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("grpcweb: can't marshal %T", v)
+	}
+	return *b, nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("grpcweb: can't unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (codec) String() string {
+	return "proto"
+}
+
+// frame returns the message framed with the flag
+func frame(flag byte, msg []byte) []byte {
+	b := make([]byte, 5+len(msg))
+	b[0] = flag
+	binary.BigEndian.PutUint32(b[1:5], uint32(len(msg)))
+	copy(b[5:], msg)
+	return b
+}
+
+// unframe returns the messages of the request body
+func unframe(body []byte) ([][]byte, error) {
+	var msgs [][]byte
+
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, status.Error(codes.Internal, "malformed message frame")
+		}
+		flag := body[0]
+		size := binary.BigEndian.Uint32(body[1:5])
+		if size > maxMessageSize {
+			return nil, status.Errorf(codes.ResourceExhausted, "message larger than %d bytes", maxMessageSize)
+		}
+		if uint32(len(body)-5) < size {
+			return nil, status.Error(codes.Internal, "malformed message frame")
+		}
+
+		switch {
+		case flag&trailerFrame != 0:
+			return nil, status.Error(codes.Internal, "requests can't have trailers")
+		case flag&compressedFrame != 0:
+			return nil, status.Error(codes.Unimplemented, "compressed messages aren't supported")
+		}
+
+		msgs = append(msgs, body[5:5+size])
+		body = body[5+size:]
+	}
+
+	return msgs, nil
+}
+
+// decodeText decodes the base64 body of a grpc-web-text request, which
+// may be several padded chunks
+func decodeText(body []byte) ([]byte, error) {
+	body = bytes.Join(bytes.Fields(body), nil)
+
+	var out []byte
+	for len(body) > 0 {
+		// a chunk ends after its padding
+		n := bytes.IndexByte(body, '=')
+		if n < 0 {
+			n = len(body)
+		}
+		for n < len(body) && body[n] == '=' {
+			n++
+		}
+
+		b := make([]byte, base64.StdEncoding.DecodedLen(n))
+		m, err := base64.StdEncoding.Decode(b, body[:n])
+		if err != nil {
+			return nil, status.Error(codes.Internal, "malformed base64 body")
+		}
+		out = append(out, b[:m]...)
+		body = body[n:]
+	}
+
+	return out, nil
+}
+
+// trailers returns the body of the trailer frame
+func trailers(kv [][2]string) []byte {
+	var b bytes.Buffer
+	for _, t := range kv {
+		b.WriteString(strings.ToLower(t[0]))
+		b.WriteString(": ")
+		b.WriteString(t[1])
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// percentEncode encodes a grpc-message as the protocol requires
+func percentEncode(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// timeout parses a grpc-timeout e.g. 100m
+func timeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", v)
+	}
+
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+
+	unit, ok := units[v[len(v)-1]]
+	if !ok {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", v)
+	}
+
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,366 @@
+// Package grpcweb translates grpc-web requests from browsers to the gRPC
+// services they're for, so they needn't be fronted by another proxy
+package grpcweb
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/micro/go-api/resolver"
+	"github.com/micro/go-api/resolver/grpc"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/selector"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// Headers grpc-web clients send, cross origin requests must allow them
+	Headers = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout"}
+	// ExposedHeaders grpc-web clients read, cross origin requests must
+	// expose them
+	ExposedHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// headers of requests which aren't forwarded as metadata
+var skipHeaders = map[string]bool{
+	"accept-encoding":   true,
+	"connection":        true,
+	"content-length":    true,
+	"content-type":      true,
+	"host":              true,
+	"keep-alive":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"user-agent":        true,
+	"x-grpc-web":        true,
+	"x-user-agent":      true,
+}
+
+type Options struct {
+	// Selector picks the node of the service a request is forwarded to
+	Selector selector.Selector
+	// Resolver resolves the service of a request, by its gRPC method if
+	// not set
+	Resolver resolver.Resolver
+}
+
+type grpcweb struct {
+	opts Options
+
+	sync.Mutex
+	conns map[string]*ggrpc.ClientConn
+}
+
+// New returns the grpc-web handler of the options
+func New(opts Options) *grpcweb {
+	if opts.Selector == nil {
+		opts.Selector = selector.DefaultSelector
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = grpc.NewResolver()
+	}
+
+	return &grpcweb{
+		opts:  opts,
+		conns: make(map[string]*ggrpc.ClientConn),
+	}
+}
+
+// conn returns the connection to the address, connections are reused
+func (g *grpcweb) conn(addr string) (*ggrpc.ClientConn, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if c, ok := g.conns[addr]; ok {
+		return c, nil
+	}
+
+	c, err := ggrpc.Dial(addr, ggrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	g.conns[addr] = c
+	return c, nil
+}
+
+// Close closes the connections to services
+func (g *grpcweb) Close() error {
+	g.Lock()
+	defer g.Unlock()
+
+	for addr, c := range g.conns {
+		c.Close()
+		delete(g.conns, addr)
+	}
+	return nil
+}
+
+// contentType returns whether the request's content type is grpc-web and
+// its messages base64 encoded
+func contentType(ct string) (bool, bool) {
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+
+	switch strings.TrimSpace(strings.ToLower(ct)) {
+	case "application/grpc-web", "application/grpc-web+proto":
+		return true, false
+	case "application/grpc-web-text", "application/grpc-web-text+proto":
+		return true, true
+	}
+	return false, false
+}
+
+// outgoing returns the context of the call, with the metadata and
+// deadline of the request's headers
+func outgoing(r *http.Request) (context.Context, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+
+	md := metadata.MD{}
+	for k, v := range r.Header {
+		k = strings.ToLower(k)
+
+		switch {
+		case k == "grpc-timeout":
+			d, err := timeout(r.Header.Get(k))
+			if err != nil {
+				cancel()
+				return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			cancel()
+			ctx, cancel = context.WithTimeout(r.Context(), d)
+			continue
+		case skipHeaders[k], strings.HasPrefix(k, "grpc-"), strings.HasPrefix(k, "proxy-"), strings.HasPrefix(k, "access-control-"):
+			continue
+		}
+
+		// binary values are base64 encoded in headers, but not metadata
+		if strings.HasSuffix(k, "-bin") {
+			for _, s := range v {
+				b, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					b, err = base64.RawStdEncoding.DecodeString(s)
+				}
+				if err != nil {
+					cancel()
+					return nil, nil, status.Errorf(codes.InvalidArgument, "malformed binary header %s", k)
+				}
+				md.Append(k, string(b))
+			}
+			continue
+		}
+
+		md.Append(k, v...)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md), cancel, nil
+}
+
+// response writes the messages and trailers of a call to the client
+type response struct {
+	w    http.ResponseWriter
+	text bool
+	// the headers were written
+	wrote bool
+}
+
+func (rsp *response) writeHeader(md metadata.MD) {
+	if rsp.wrote {
+		return
+	}
+	rsp.wrote = true
+
+	for k, v := range md {
+		for _, s := range v {
+			if strings.HasSuffix(k, "-bin") {
+				s = base64.StdEncoding.EncodeToString([]byte(s))
+			}
+			rsp.w.Header().Add(k, s)
+		}
+	}
+
+	if rsp.text {
+		rsp.w.Header().Set("Content-Type", "application/grpc-web-text+proto")
+	} else {
+		rsp.w.Header().Set("Content-Type", "application/grpc-web+proto")
+	}
+	// grpc-web responses are all 200 OK, errors are in the trailers
+	rsp.w.WriteHeader(http.StatusOK)
+}
+
+func (rsp *response) write(flag byte, msg []byte) error {
+	b := frame(flag, msg)
+	if rsp.text {
+		b = []byte(base64.StdEncoding.EncodeToString(b))
+	}
+	if _, err := rsp.w.Write(b); err != nil {
+		return err
+	}
+	if f, ok := rsp.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// finish writes the status and trailers of the call
+func (rsp *response) finish(st *status.Status, md metadata.MD) {
+	rsp.writeHeader(nil)
+
+	kv := [][2]string{
+		{"grpc-status", strconv.Itoa(int(st.Code()))},
+		{"grpc-message", percentEncode(st.Message())},
+	}
+	if len(st.Details()) > 0 {
+		if b, err := proto.Marshal(st.Proto()); err == nil {
+			kv = append(kv, [2]string{"grpc-status-details-bin", base64.StdEncoding.EncodeToString(b)})
+		}
+	}
+	for k, v := range md {
+		for _, s := range v {
+			if strings.HasSuffix(k, "-bin") {
+				s = base64.StdEncoding.EncodeToString([]byte(s))
+			}
+			kv = append(kv, [2]string{k, s})
+		}
+	}
+
+	rsp.write(trailerFrame, trailers(kv))
+}
+
+// ServeHTTP forwards the grpc-web request to the service of its method,
+// writing the messages it responds with as they're received
+func (g *grpcweb) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "grpc-web requests must be POST", http.StatusMethodNotAllowed)
+		return
+	}
+	ok, text := contentType(r.Header.Get("Content-Type"))
+	if !ok {
+		http.Error(w, "grpc-web requests must be application/grpc-web or application/grpc-web-text", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	rsp := &response{w: w, text: text}
+
+	md, err := g.serve(rsp, r)
+	if err != nil {
+		rsp.finish(status.Convert(err), md)
+		return
+	}
+	rsp.finish(status.New(codes.OK, ""), md)
+}
+
+// serve calls the method of the request, returning the trailers of the
+// call and its error
+func (g *grpcweb) serve(rsp *response, r *http.Request) (metadata.MD, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2*maxMessageSize+1))
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error reading request: %v", err)
+	}
+	if len(body) > 2*maxMessageSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "request larger than %d bytes", 2*maxMessageSize)
+	}
+	if rsp.text {
+		if body, err = decodeText(body); err != nil {
+			return nil, err
+		}
+	}
+
+	msgs, err := unframe(body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(msgs) == 0:
+		return nil, status.Error(codes.Internal, "no request message")
+	case len(msgs) > 1:
+		return nil, status.Error(codes.Unimplemented, "client streaming isn't supported")
+	}
+
+	// /package.Service/Method
+	method := r.URL.Path
+	if strings.Count(method, "/") != 2 || !strings.Contains(method, ".") {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	endpoint, err := g.opts.Resolver.Resolve(r)
+	if err != nil {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+
+	next, err := g.opts.Selector.Select(endpoint.Name)
+	if err == selector.ErrNotFound || err == registry.ErrNotFound {
+		return nil, status.Errorf(codes.Unimplemented, "unknown service %s", endpoint.Name)
+	} else if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error selecting %s node: %v", endpoint.Name, err)
+	}
+	node, err := next()
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error selecting %s node: %v", endpoint.Name, err)
+	}
+
+	conn, err := g.conn(fmt.Sprintf("%s:%d", node.Address, node.Port))
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error connecting to %s: %v", endpoint.Name, err)
+	}
+
+	ctx, cancel, err := outgoing(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+
+	// unary calls are server streams of one message
+	desc := &ggrpc.StreamDesc{ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, method, ggrpc.CallCustomCodec(codec{}))
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(&msgs[0]); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	for {
+		var msg []byte
+		err := stream.RecvMsg(&msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if md, herr := stream.Header(); herr == nil {
+				rsp.writeHeader(md)
+			}
+			return stream.Trailer(), err
+		}
+
+		if md, err := stream.Header(); err == nil {
+			rsp.writeHeader(md)
+		}
+		if err := rsp.write(dataFrame, msg); err != nil {
+			// the client's gone
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+	}
+
+	if md, err := stream.Header(); err == nil {
+		rsp.writeHeader(md)
+	}
+	return stream.Trailer(), nil
+}
@@ -0,0 +1,358 @@
+package grpcweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/go-micro/selector"
+	"github.com/micro/micro/internal/cors"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// calls of the client streaming method, which mustn't be reached
+var collected int64
+
+// echo is a gRPC service of raw messages
+var echo = ggrpc.ServiceDesc{
+	ServiceName: "test.Echo",
+	HandlerType: (*interface{})(nil),
+	Methods: []ggrpc.MethodDesc{
+		{MethodName: "Unary", Handler: unary},
+		{MethodName: "Fail", Handler: fail},
+		{MethodName: "Wait", Handler: wait},
+	},
+	Streams: []ggrpc.StreamDesc{
+		{StreamName: "Stream", Handler: stream, ServerStreams: true},
+		{StreamName: "Collect", Handler: collect, ClientStreams: true},
+	},
+}
+
+// unary echoes the message, the x-test header and binary metadata
+func unary(srv interface{}, ctx context.Context, dec func(interface{}) error, _ ggrpc.UnaryServerInterceptor) (interface{}, error) {
+	var msg []byte
+	if err := dec(&msg); err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	ggrpc.SetHeader(ctx, metadata.Pairs("x-echo", strings.Join(md["x-test"], ","), "x-echo-bin", strings.Join(md["x-data-bin"], ",")))
+	ggrpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "done", "x-trailer-bin", "\x00\x01"))
+	return &msg, nil
+}
+
+func fail(srv interface{}, ctx context.Context, dec func(interface{}) error, _ ggrpc.UnaryServerInterceptor) (interface{}, error) {
+	st, _ := status.New(codes.InvalidArgument, "bad request: 100%\n").WithDetails(&wrappers.StringValue{Value: "detail"})
+	return nil, st.Err()
+}
+
+func wait(srv interface{}, ctx context.Context, dec func(interface{}) error, _ ggrpc.UnaryServerInterceptor) (interface{}, error) {
+	<-ctx.Done()
+	return nil, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+}
+
+// stream sends the message three times
+func stream(srv interface{}, s ggrpc.ServerStream) error {
+	var msg []byte
+	if err := s.RecvMsg(&msg); err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		rsp := []byte(fmt.Sprintf("%d:%s", i, msg))
+		if err := s.SendMsg(&rsp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collect(srv interface{}, s ggrpc.ServerStream) error {
+	atomic.AddInt64(&collected, 1)
+	return nil
+}
+
+// testHandler returns the handler of a registry with the echo service
+func testHandler(t *testing.T) (*grpcweb, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := ggrpc.NewServer(ggrpc.CustomCodec(codec{}))
+	srv.RegisterService(&echo, struct{}{})
+	go srv.Serve(l)
+
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:  "test",
+		Nodes: []*registry.Node{{Id: "test-1", Address: host, Port: p}},
+	})
+
+	g := New(Options{Selector: selector.NewSelector(selector.Registry(reg))})
+
+	return g, func() {
+		g.Close()
+		srv.Stop()
+	}
+}
+
+// call makes the grpc-web request of the frames, returning the messages
+// and trailers of the response
+func call(t *testing.T, h http.Handler, path string, text bool, frames [][]byte, headers map[string]string) (*httptest.ResponseRecorder, []string, http.Header) {
+	body := bytes.Join(frames, nil)
+	ct := "application/grpc-web+proto"
+	if text {
+		ct = "application/grpc-web-text"
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+
+	r := httptest.NewRequest("POST", path, bytes.NewReader(body))
+	r.Header.Set("Content-Type", ct)
+	r.Header.Set("X-Grpc-Web", "1")
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	rsp := w.Body.Bytes()
+	if text {
+		b, err := decodeText(rsp)
+		if err != nil {
+			t.Fatalf("%s: malformed text response %q", path, rsp)
+		}
+		rsp = b
+	}
+
+	var msgs []string
+	trailer := http.Header{}
+
+	for len(rsp) > 0 {
+		if len(rsp) < 5 {
+			t.Fatalf("%s: malformed response %q", path, w.Body.String())
+		}
+		size := binary.BigEndian.Uint32(rsp[1:5])
+		msg := rsp[5 : 5+size]
+
+		if rsp[0] == trailerFrame {
+			for _, line := range strings.Split(string(msg), "\r\n") {
+				if kv := strings.SplitN(line, ": ", 2); len(kv) == 2 {
+					trailer.Add(kv[0], kv[1])
+				}
+			}
+		} else {
+			if len(trailer) > 0 {
+				t.Fatalf("%s: message after the trailers", path)
+			}
+			msgs = append(msgs, string(msg))
+		}
+		rsp = rsp[5+size:]
+	}
+
+	return w, msgs, trailer
+}
+
+func TestHandler(t *testing.T) {
+	g, stop := testHandler(t)
+	defer stop()
+
+	hello := frame(dataFrame, []byte("hello"))
+
+	testData := []struct {
+		name    string
+		path    string
+		text    bool
+		frames  [][]byte
+		headers map[string]string
+		msgs    []string
+		status  codes.Code
+		message string
+	}{
+		{"unary", "/test.Echo/Unary", false, [][]byte{hello}, nil, []string{"hello"}, codes.OK, ""},
+		{"unary text", "/test.Echo/Unary", true, [][]byte{hello}, nil, []string{"hello"}, codes.OK, ""},
+		{"empty message", "/test.Echo/Unary", false, [][]byte{frame(dataFrame, nil)}, nil, []string{""}, codes.OK, ""},
+		{"server stream", "/test.Echo/Stream", false, [][]byte{frame(dataFrame, []byte("hi"))}, nil, []string{"0:hi", "1:hi", "2:hi"}, codes.OK, ""},
+		{"server stream text", "/test.Echo/Stream", true, [][]byte{frame(dataFrame, []byte("hi"))}, nil, []string{"0:hi", "1:hi", "2:hi"}, codes.OK, ""},
+		{"error", "/test.Echo/Fail", false, [][]byte{hello}, nil, nil, codes.InvalidArgument, "bad request: 100%25%0A"},
+		{"deadline", "/test.Echo/Wait", false, [][]byte{hello}, map[string]string{"Grpc-Timeout": "50m"}, nil, codes.DeadlineExceeded, ""},
+		{"bad deadline", "/test.Echo/Wait", false, [][]byte{hello}, map[string]string{"Grpc-Timeout": "soon"}, nil, codes.InvalidArgument, `malformed grpc-timeout "soon"`},
+		{"client stream", "/test.Echo/Collect", false, [][]byte{hello, hello}, nil, nil, codes.Unimplemented, "client streaming isn't supported"},
+		{"no message", "/test.Echo/Unary", false, nil, nil, nil, codes.Internal, "no request message"},
+		{"malformed", "/test.Echo/Unary", false, [][]byte{hello[:7]}, nil, nil, codes.Internal, "malformed message frame"},
+		{"compressed", "/test.Echo/Unary", false, [][]byte{frame(compressedFrame, []byte("hello"))}, nil, nil, codes.Unimplemented, "compressed messages aren't supported"},
+		{"unknown method", "/test.Echo/Nope", false, [][]byte{hello}, nil, nil, codes.Unimplemented, ""},
+		{"unknown service", "/other.Echo/Unary", false, [][]byte{hello}, nil, nil, codes.Unimplemented, "unknown service other"},
+		{"not a method", "/foo", false, [][]byte{hello}, nil, nil, codes.Unimplemented, "unknown method /foo"},
+	}
+
+	for _, d := range testData {
+		w, msgs, trailer := call(t, g, d.path, d.text, d.frames, d.headers)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200 OK got %d", d.name, w.Code)
+		}
+		ct := "application/grpc-web+proto"
+		if d.text {
+			ct = "application/grpc-web-text+proto"
+		}
+		if got := w.Header().Get("Content-Type"); got != ct {
+			t.Fatalf("%s: expected content type %s got %s", d.name, ct, got)
+		}
+		if fmt.Sprint(msgs) != fmt.Sprint(d.msgs) {
+			t.Fatalf("%s: expected messages %q got %q", d.name, d.msgs, msgs)
+		}
+		if s := trailer.Get("grpc-status"); s != strconv.Itoa(int(d.status)) {
+			t.Fatalf("%s: expected status %d got %s %s", d.name, d.status, s, trailer.Get("grpc-message"))
+		}
+		if len(d.message) > 0 && trailer.Get("grpc-message") != d.message {
+			t.Fatalf("%s: expected message %q got %q", d.name, d.message, trailer.Get("grpc-message"))
+		}
+	}
+
+	if n := atomic.LoadInt64(&collected); n != 0 {
+		t.Fatalf("expected client streams not forwarded got %d", n)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	g, stop := testHandler(t)
+	defer stop()
+
+	w, msgs, trailer := call(t, g, "/test.Echo/Unary", false, [][]byte{frame(dataFrame, []byte("hello"))}, map[string]string{
+		"X-Test":     "abc",
+		"X-Data-Bin": base64.StdEncoding.EncodeToString([]byte("\xff\x00")),
+	})
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected a message got %q", msgs)
+	}
+
+	// headers are metadata, binary ones base64 encoded
+	if h := w.Header().Get("X-Echo"); h != "abc" {
+		t.Fatalf("expected the header forwarded got %q", h)
+	}
+	if h := w.Header().Get("X-Echo-Bin"); h != base64.StdEncoding.EncodeToString([]byte("\xff\x00")) {
+		t.Fatalf("expected the binary header forwarded got %q", h)
+	}
+	if s := trailer.Get("x-trailer"); s != "done" {
+		t.Fatalf("expected the trailer in the body got %q", s)
+	}
+	if s := trailer.Get("x-trailer-bin"); s != "AAE=" {
+		t.Fatalf("expected the binary trailer base64 encoded got %q", s)
+	}
+
+	// error details are sent whole
+	_, _, trailer = call(t, g, "/test.Echo/Fail", false, [][]byte{frame(dataFrame, nil)}, nil)
+
+	b, err := base64.StdEncoding.DecodeString(trailer.Get("grpc-status-details-bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st spb.Status
+	if err := proto.Unmarshal(b, &st); err != nil {
+		t.Fatal(err)
+	}
+	var detail wrappers.StringValue
+	if len(st.Details) != 1 || ptypes.UnmarshalAny(st.Details[0], &detail) != nil || detail.Value != "detail" {
+		t.Fatalf("unexpected status details %+v", st)
+	}
+}
+
+func TestTextChunks(t *testing.T) {
+	g, stop := testHandler(t)
+	defer stop()
+
+	// clients may send the body base64 encoded in padded chunks
+	f := frame(dataFrame, []byte("hello"))
+	body := base64.StdEncoding.EncodeToString(f[:4]) + base64.StdEncoding.EncodeToString(f[4:])
+
+	r := httptest.NewRequest("POST", "/test.Echo/Unary", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/grpc-web-text")
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+
+	rsp, err := decodeText(w.Body.Bytes())
+	if err != nil || !bytes.HasPrefix(rsp, f) {
+		t.Fatalf("expected the message echoed got %q", w.Body.String())
+	}
+}
+
+func TestRequests(t *testing.T) {
+	g := New(Options{})
+
+	for _, d := range []struct {
+		method string
+		ct     string
+		code   int
+	}{
+		{"GET", "application/grpc-web", http.StatusMethodNotAllowed},
+		{"POST", "application/json", http.StatusUnsupportedMediaType},
+		{"POST", "application/grpc", http.StatusUnsupportedMediaType},
+		{"POST", "application/grpc-web+json", http.StatusUnsupportedMediaType},
+	} {
+		r := httptest.NewRequest(d.method, "/test.Echo/Unary", nil)
+		r.Header.Set("Content-Type", d.ct)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		if w.Code != d.code {
+			t.Fatalf("%s %s: expected %d got %d", d.method, d.ct, d.code, w.Code)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	g, stop := testHandler(t)
+	defer stop()
+
+	c, err := cors.New(cors.Options{
+		Origins: []string{"https://app.example.com"},
+		Headers: append(cors.DefaultHeaders, Headers...),
+		Exposed: ExposedHeaders,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Handler(g)
+
+	// the preflight of what grpc-web clients send
+	r := httptest.NewRequest("OPTIONS", "/test.Echo/Unary", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web,x-user-agent,grpc-timeout")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected the preflight allowed got %d", w.Code)
+	}
+
+	w, msgs, trailer := call(t, h, "/test.Echo/Unary", false, [][]byte{frame(dataFrame, []byte("hello"))}, map[string]string{
+		"Origin": "https://app.example.com",
+	})
+	if len(msgs) != 1 || trailer.Get("grpc-status") != "0" {
+		t.Fatalf("expected the call to succeed got %q %v", msgs, trailer)
+	}
+	if e := w.Header().Get("Access-Control-Expose-Headers"); e != "Grpc-Status, Grpc-Message, Grpc-Status-Details-Bin" {
+		t.Fatalf("expected the grpc-web headers exposed got %q", e)
+	}
+}
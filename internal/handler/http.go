@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/micro/go-api"
+	"github.com/micro/go-api/router"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/selector"
+)
+
+type httpHandler struct {
+	s micro.Service
+	r router.Router
+	// routed by the meta handler if set
+	service *api.Service
+	// how long either direction of a websocket may be idle
+	timeout time.Duration
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	namespace := h.r.Options().Namespace
+
+	service := h.service
+	if service == nil {
+		s, err := h.r.Route(r)
+		if err != nil {
+			writeError(w, errors.InternalServerError(namespace, "%s", err.Error()))
+			return
+		}
+		service = s
+	}
+
+	node, err := h.next(service)
+	if err != nil {
+		writeError(w, errors.NotFound(namespace, "%s: %v", service.Name, err))
+		return
+	}
+
+	host := fmt.Sprintf("%s:%d", node.Address, node.Port)
+
+	if isWebSocket(r) {
+		h.serveWebSocket(host, w, r)
+		return
+	}
+
+	httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: host}).ServeHTTP(w, r)
+}
+
+// next selects the node of the service with the selector's strategy
+func (h *httpHandler) next(service *api.Service) (*registry.Node, error) {
+	strategy := selector.Random
+	if sel := h.s.Client().Options().Selector; sel != nil && sel.Options().Strategy != nil {
+		strategy = sel.Options().Strategy
+	}
+	return strategy(service.Services)()
+}
+
+// serveWebSocket dials the host, replays the handshake to it and copies
+// the connections to each other until either is closed
+func (h *httpHandler) serveWebSocket(host string, w http.ResponseWriter, r *http.Request) {
+	namespace := h.r.Options().Namespace
+
+	req := new(http.Request)
+	*req = *r
+	req.Header = make(http.Header)
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+
+	// set x-forward-for
+	if clientIP, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if ips, ok := req.Header["X-Forwarded-For"]; ok {
+			clientIP = strings.Join(ips, ", ") + ", " + clientIP
+		}
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeError(w, errors.InternalServerError(namespace, "websockets not supported"))
+		return
+	}
+
+	// connect to the backend host
+	conn, err := net.DialTimeout("tcp", host, 10*time.Second)
+	if err != nil {
+		writeError(w, errors.New(namespace, err.Error(), http.StatusBadGateway))
+		return
+	}
+	defer conn.Close()
+
+	nc, brw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer nc.Close()
+
+	if err := req.Write(conn); err != nil {
+		return
+	}
+
+	errCh := make(chan error, 2)
+
+	// frames the client sent with the handshake may be buffered
+	go func() { errCh <- h.copy(conn, nc, brw.Reader) }()
+	go func() { errCh <- h.copy(nc, conn, conn) }()
+
+	// either side closing closes the other
+	<-errCh
+}
+
+// copy writes what's read from r of the src to dst until either fails,
+// reads and writes must complete within the timeout if set
+func (h *httpHandler) copy(dst, src net.Conn, r io.Reader) error {
+	buf := make([]byte, 32*1024)
+
+	for {
+		if h.timeout > 0 {
+			src.SetReadDeadline(time.Now().Add(h.timeout))
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			if h.timeout > 0 {
+				dst.SetWriteDeadline(time.Now().Add(h.timeout))
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func isWebSocket(r *http.Request) bool {
+	contains := func(key, val string) bool {
+		vv := strings.Split(r.Header.Get(key), ",")
+		for _, v := range vv {
+			if val == strings.ToLower(strings.TrimSpace(v)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return contains("Connection", "upgrade") && contains("Upgrade", "websocket")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	e := errors.Parse(err.Error())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(e.Code))
+	w.Write([]byte(e.Error()))
+}
+
+// HTTP is a http.Handler that reverse proxies requests, websockets
+// included, to the services the router routes them to. Websockets idle
+// in either direction for the timeout are closed, if it's set.
+func HTTP(s micro.Service, r router.Router, timeout time.Duration) http.Handler {
+	return &httpHandler{
+		s:       s,
+		r:       r,
+		timeout: timeout,
+	}
+}
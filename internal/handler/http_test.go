@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-api/resolver/path"
+	"github.com/micro/go-api/router"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+)
+
+// echo serves websockets echoing messages, closing them when sent bye
+func echo(closed chan error) http.Handler {
+	upgrader := websocket.Upgrader{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			w.Write([]byte("hello " + r.URL.Path))
+			return
+		}
+
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			if string(msg) == "bye" {
+				c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4000, "bye"))
+				continue
+			}
+			if err := c.WriteMessage(typ, msg); err != nil {
+				closed <- err
+				return
+			}
+		}
+	})
+}
+
+// testAPI returns the url of an api proxying to a websocket echo service
+func testAPI(t *testing.T, timeout time.Duration) (string, chan error, func()) {
+	closed := make(chan error, 10)
+	srv := httptest.NewServer(echo(closed))
+
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	reg := memory.NewRegistry()
+	reg.Register(&registry.Service{
+		Name:  "echo",
+		Nodes: []*registry.Node{{Id: "echo-1", Address: host, Port: p}},
+	})
+
+	rt := router.NewRouter(
+		router.WithNamespace("go.micro.api"),
+		router.WithHandler("http"),
+		router.WithResolver(path.NewResolver()),
+		router.WithRegistry(reg),
+	)
+
+	api := httptest.NewServer(HTTP(micro.NewService(), rt, timeout))
+
+	return strings.TrimPrefix(api.URL, "http://"), closed, func() {
+		api.Close()
+		rt.Close()
+		srv.Close()
+	}
+}
+
+func TestHTTPHandler(t *testing.T) {
+	addr, _, stop := testAPI(t, 0)
+	defer stop()
+
+	rsp, err := http.Get("http://" + addr + "/echo/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+	b, _ := ioutil.ReadAll(rsp.Body)
+	if rsp.StatusCode != 200 || string(b) != "hello /echo/foo" {
+		t.Fatalf("expected the request proxied got %d %q", rsp.StatusCode, b)
+	}
+
+	rsp, err = http.Get("http://" + addr + "/unknown/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != 500 || rsp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected an error for an unknown service got %d", rsp.StatusCode)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	addr, closed, stop := testAPI(t, 0)
+	defer stop()
+
+	dial := func() *websocket.Conn {
+		c, rsp, err := websocket.DefaultDialer.Dial("ws://"+addr+"/echo/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("expected the handshake replayed got %d", rsp.StatusCode)
+		}
+		return c
+	}
+
+	// frames round trip
+	c := dial()
+	for i, msg := range []string{"hello", strings.Repeat("x", 100000), "world"} {
+		typ := websocket.TextMessage
+		if i == 1 {
+			typ = websocket.BinaryMessage
+		}
+		if err := c.WriteMessage(typ, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		rtyp, got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rtyp != typ || string(got) != msg {
+			t.Fatalf("expected message %d echoed got type %d of %d bytes", i, rtyp, len(got))
+		}
+	}
+
+	// the service closing is propagated to the client
+	c.WriteMessage(websocket.TextMessage, []byte("bye"))
+	if _, _, err := c.ReadMessage(); !websocket.IsCloseError(err, 4000) {
+		t.Fatalf("expected the service's close got %v", err)
+	}
+	c.Close()
+
+	// which the client answers
+	select {
+	case err := <-closed:
+		if !websocket.IsCloseError(err, 4000) {
+			t.Fatalf("expected the client's answer to the close got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service to see the client answer the close")
+	}
+
+	// and the client closing to the service
+	c = dial()
+	c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	select {
+	case err := <-closed:
+		if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Fatalf("expected the client's close got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service to see the client close")
+	}
+	c.Close()
+
+	// as is the client going away
+	c = dial()
+	c.UnderlyingConn().Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service to see the client's connection closed")
+	}
+}
+
+func TestWebSocketTimeout(t *testing.T) {
+	addr, closed, stop := testAPI(t, 100*time.Millisecond)
+	defer stop()
+
+	c, _, err := websocket.DefaultDialer.Dial("ws://"+addr+"/echo/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// traffic keeps it open
+	for i := 0; i < 5; i++ {
+		time.Sleep(50 * time.Millisecond)
+		c.WriteMessage(websocket.TextMessage, []byte("ping"))
+		if _, _, err := c.ReadMessage(); err != nil {
+			t.Fatalf("expected the active websocket kept open got %v", err)
+		}
+	}
+
+	// but it's closed once idle
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := c.ReadMessage(); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected the idle websocket closed by the api got %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service's websocket closed")
+	}
+}
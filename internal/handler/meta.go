@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/micro/go-api/handler"
 	"github.com/micro/go-api/handler/event"
@@ -19,6 +20,8 @@ import (
 type metaHandler struct {
 	s micro.Service
 	r router.Router
+	// of the websockets proxied
+	timeout time.Duration
 }
 
 func (m *metaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,9 +41,13 @@ func (m *metaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		aweb.WithService(service, handler.WithService(m.s)).ServeHTTP(w, r)
 	// proxy handler
 	case "proxy", ahttp.Handler:
-		ahttp.WithService(service, handler.WithService(m.s)).ServeHTTP(w, r)
+		h := &httpHandler{s: m.s, r: m.r, service: service, timeout: m.timeout}
+		h.ServeHTTP(w, r)
 	// rpcx handler
 	case arpc.Handler:
+		if stream(w, r, m.s, m.r.Options().Namespace, service) {
+			return
+		}
 		arpc.WithService(service, handler.WithService(m.s)).ServeHTTP(w, r)
 	// event handler
 	case event.Handler:
@@ -54,14 +61,19 @@ func (m *metaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		aapi.WithService(service, handler.WithService(m.s)).ServeHTTP(w, r)
 	// default handler: rpc
 	default:
+		if stream(w, r, m.s, m.r.Options().Namespace, service) {
+			return
+		}
 		arpc.WithService(service, handler.WithService(m.s)).ServeHTTP(w, r)
 	}
 }
 
-// Meta is a http.Handler that routes based on endpoint metadata
-func Meta(s micro.Service, r router.Router) http.Handler {
+// Meta is a http.Handler that routes based on endpoint metadata, the
+// timeout is that of websockets proxied as with HTTP
+func Meta(s micro.Service, r router.Router, timeout time.Duration) http.Handler {
 	return &metaHandler{
-		s: s,
-		r: r,
+		s:       s,
+		r:       r,
+		timeout: timeout,
 	}
 }
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/micro/go-micro/errors"
+)
+
+// MaxUploadSize is the largest size in bytes of the files of multipart
+// requests to the rpc handler, and of the form kept in memory parsing them
+var MaxUploadSize int64 = 10 << 20
+
+// uploads adds the files of the form to the request, base64 encoded as
+// bytes fields are in JSON, with their filename and content type in the
+// sibling keys <name>_filename and <name>_content_type. As with values
+// only the first file of each name is passed on.
+func uploads(request interface{}, form *multipart.Form) (interface{}, error) {
+	if len(form.File) == 0 {
+		return request, nil
+	}
+
+	var size int64
+	for _, files := range form.File {
+		for _, fh := range files {
+			size += fh.Size
+		}
+	}
+	if size > MaxUploadSize {
+		return nil, errors.New("go.micro.rpc", fmt.Sprintf("uploaded files larger than %d bytes", MaxUploadSize), http.StatusRequestEntityTooLarge)
+	}
+
+	req, ok := request.(map[string]interface{})
+	if !ok {
+		if request != nil {
+			return nil, errors.BadRequest("go.micro.rpc", "request must be an object to upload files")
+		}
+		req = make(map[string]interface{})
+	}
+
+	for name, files := range form.File {
+		if len(files) == 0 {
+			continue
+		}
+		fh := files[0]
+
+		f, err := fh.Open()
+		if err != nil {
+			return nil, errors.BadRequest("go.micro.rpc", "error reading file %s: %v", name, err)
+		}
+		b, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.BadRequest("go.micro.rpc", "error reading file %s: %v", name, err)
+		}
+
+		req[name] = base64.StdEncoding.EncodeToString(b)
+		req[name+"_filename"] = fh.Filename
+		req[name+"_content_type"] = fh.Header.Get("Content-Type")
+	}
+
+	return req, nil
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/cmd"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/go-micro/selector"
+	"github.com/micro/go-micro/server"
+)
+
+type Echo struct{}
+
+func (e *Echo) Echo(ctx context.Context, req *map[string]interface{}, rsp *map[string]interface{}) error {
+	*rsp = *req
+	return nil
+}
+
+var (
+	echoing sync.Once
+	echoR   = memory.NewRegistry()
+)
+
+// testUpload posts the form to the rpc handler, calling an echo service
+func testUpload(t *testing.T, fields map[string]string, files map[string]string) *httptest.ResponseRecorder {
+	echoing.Do(func() {
+		srv := server.NewServer(
+			server.Name("go.micro.srv.echo"),
+			server.Address("127.0.0.1:0"),
+			server.Registry(echoR),
+		)
+		srv.Handle(srv.NewHandler(&Echo{}))
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	(*cmd.DefaultOptions().Client).Init(
+		client.Registry(echoR),
+		client.Selector(selector.NewSelector(selector.Registry(echoR))),
+	)
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for name, content := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+name+`"; filename="`+name+`.txt"`)
+		h.Set("Content-Type", "text/plain")
+		p, _ := mw.CreatePart(h)
+		p.Write([]byte(content))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/rpc", &b)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	RPC(w, r)
+	return w
+}
+
+func TestMultipart(t *testing.T) {
+	w := testUpload(t, map[string]string{
+		"service":  "go.micro.srv.echo",
+		"endpoint": "Echo.Echo",
+		"request":  `{"name": "john", "age": 30}`,
+	}, map[string]string{
+		"avatar": "hello world",
+	})
+	if w.Code != 200 {
+		t.Fatalf("expected 200 got %d %s", w.Code, w.Body.String())
+	}
+
+	var rsp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"name":                "john",
+		"age":                 float64(30),
+		"avatar":              "aGVsbG8gd29ybGQ=",
+		"avatar_filename":     "avatar.txt",
+		"avatar_content_type": "text/plain",
+	}
+	if len(rsp) != len(expect) {
+		t.Fatalf("expected the request %v got %v", expect, rsp)
+	}
+	for k, v := range expect {
+		if rsp[k] != v {
+			t.Fatalf("expected %s of %v got %v", k, v, rsp[k])
+		}
+	}
+
+	// the request's optional, fields alone are as urlencoded forms
+	w = testUpload(t, map[string]string{
+		"service":  "go.micro.srv.echo",
+		"endpoint": "Echo.Echo",
+	}, map[string]string{
+		"file": "hi",
+	})
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"file":"aGk="`) {
+		t.Fatalf("expected the file passed on got %d %s", w.Code, w.Body.String())
+	}
+
+	w = testUpload(t, map[string]string{
+		"service":  "go.micro.srv.echo",
+		"endpoint": "Echo.Echo",
+		"request":  `{"name": "john"}`,
+	}, nil)
+	if w.Code != 200 || strings.TrimSpace(w.Body.String()) != `{"name":"john"}` {
+		t.Fatalf("expected the request passed on got %d %s", w.Code, w.Body.String())
+	}
+
+	w = testUpload(t, map[string]string{
+		"service":  "go.micro.srv.echo",
+		"endpoint": "Echo.Echo",
+		"request":  `["john"]`,
+	}, map[string]string{
+		"file": "hi",
+	})
+	if w.Code != 400 || !strings.Contains(w.Body.String(), "request must be an object") {
+		t.Fatalf("expected the request refused got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMultipartTooLarge(t *testing.T) {
+	size := MaxUploadSize
+	MaxUploadSize = 8
+	defer func() {
+		MaxUploadSize = size
+	}()
+
+	w := testUpload(t, map[string]string{
+		"service":  "go.micro.srv.echo",
+		"endpoint": "Echo.Echo",
+	}, map[string]string{
+		"a": "hello",
+		"b": "world",
+	})
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 got %d %s", w.Code, w.Body.String())
+	}
+
+	var e struct {
+		Id     string
+		Code   int
+		Detail string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != "go.micro.rpc" || e.Code != 413 || e.Detail != "uploaded files larger than 8 bytes" {
+		t.Fatalf("expected the rpc handler's error got %+v", e)
+	}
+}
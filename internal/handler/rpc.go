@@ -21,8 +21,8 @@ type rpcRequest struct {
 	Request  interface{}
 }
 
-// RPC Handler passes on a JSON or form encoded RPC request to
-// a service.
+// RPC Handler passes on a JSON, form or multipart encoded RPC request
+// to a service, the files of multipart requests included.
 func RPC(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -79,6 +79,14 @@ func RPC(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
+	case "multipart/form-data":
+		// what doesn't fit in memory is kept on disk
+		if err := r.ParseMultipartForm(MaxUploadSize); err != nil {
+			badRequest("error parsing multipart form: " + err.Error())
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		fallthrough
 	default:
 		r.ParseForm()
 		service = r.Form.Get("service")
@@ -88,13 +96,29 @@ func RPC(w http.ResponseWriter, r *http.Request) {
 			endpoint = r.Form.Get("method")
 		}
 
-		d := json.NewDecoder(strings.NewReader(r.Form.Get("request")))
+		req := r.Form.Get("request")
+		// uploads needn't have a request
+		if len(req) == 0 && r.MultipartForm != nil && len(r.MultipartForm.File) > 0 {
+			req = "{}"
+		}
+
+		d := json.NewDecoder(strings.NewReader(req))
 		d.UseNumber()
 
 		if err := d.Decode(&request); err != nil {
 			badRequest("error decoding request string: " + err.Error())
 			return
 		}
+
+		if r.MultipartForm != nil {
+			var err error
+			if request, err = uploads(request, r.MultipartForm); err != nil {
+				ce := errors.Parse(err.Error())
+				w.WriteHeader(int(ce.Code))
+				w.Write([]byte(ce.Error()))
+				return
+			}
+		}
 	}
 
 	if len(service) == 0 {
@@ -107,6 +131,21 @@ func RPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// streamed to clients which accept it
+	if format := streamFormat(r); len(format) > 0 {
+		b, err := json.Marshal(request)
+		if err != nil {
+			badRequest(err.Error())
+			return
+		}
+		var opts []client.CallOption
+		if len(address) > 0 {
+			opts = append(opts, client.WithAddress(address))
+		}
+		serveStream(w, r, *cmd.DefaultOptions().Client, service, endpoint, b, format, opts...)
+		return
+	}
+
 	// create request/response
 	var response json.RawMessage
 	var err error
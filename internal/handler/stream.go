@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro/go-api"
+	"github.com/micro/go-api/router"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/selector"
+	"github.com/micro/micro/internal/helper"
+)
+
+// Heartbeat is how often idle event streams are sent a comment, so
+// intermediaries don't time them out. Zero sends none.
+var Heartbeat = 15 * time.Second
+
+// streamTimeout is the deadline of streams without a timeout, clients of
+// event streams reconnect after
+const streamTimeout = 24 * time.Hour
+
+const (
+	eventStream = "text/event-stream"
+	ndjson      = "application/x-ndjson"
+)
+
+// streamFormat returns the streamed content type the request accepts
+func streamFormat(r *http.Request) string {
+	for _, a := range strings.Split(r.Header.Get("Accept"), ",") {
+		if i := strings.Index(a, ";"); i >= 0 {
+			a = a[:i]
+		}
+		switch strings.ToLower(strings.TrimSpace(a)) {
+		case eventStream:
+			return eventStream
+		case ndjson:
+			return ndjson
+		}
+	}
+	return ""
+}
+
+// isStream returns whether the endpoint of the services streams its
+// responses, as the server records in its metadata
+func isStream(services []*registry.Service, endpoint string) bool {
+	for _, s := range services {
+		for _, ep := range s.Endpoints {
+			if ep.Name == endpoint && ep.Metadata["stream"] == "true" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streaming returns the content type the response to the request for the
+// service is streamed as, if it's streamed. Events are sent to clients
+// which don't ask for either.
+func streaming(r *http.Request, service *api.Service) (string, bool) {
+	if f := streamFormat(r); len(f) > 0 {
+		return f, true
+	}
+	if service != nil && service.Endpoint != nil && isStream(service.Services, service.Endpoint.Name) {
+		return eventStream, true
+	}
+	return "", false
+}
+
+// payload returns the request of the stream, the query of GET requests as
+// event sources can't send a body
+func payload(r *http.Request) (json.RawMessage, error) {
+	if r.Method == "GET" {
+		q := make(map[string]string)
+		for k, v := range r.URL.Query() {
+			q[k] = v[0]
+		}
+		return json.Marshal(q)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+	return b, nil
+}
+
+// streamWriter writes the messages of a stream in its format
+type streamWriter struct {
+	w      http.ResponseWriter
+	format string
+}
+
+func (s *streamWriter) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *streamWriter) message(msg json.RawMessage) error {
+	// a message's one line of either format
+	var b bytes.Buffer
+	if err := json.Compact(&b, msg); err != nil {
+		return err
+	}
+
+	var err error
+	if s.format == eventStream {
+		_, err = fmt.Fprintf(s.w, "data: %s\n\n", b.Bytes())
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s\n", b.Bytes())
+	}
+	s.flush()
+	return err
+}
+
+func (s *streamWriter) error(e *errors.Error) {
+	if s.format == eventStream {
+		fmt.Fprintf(s.w, "event: error\ndata: %s\n\n", e.Error())
+	} else {
+		fmt.Fprintf(s.w, "{\"error\":%s}\n", e.Error())
+	}
+	s.flush()
+}
+
+func (s *streamWriter) heartbeat() error {
+	_, err := io.WriteString(s.w, ": heartbeat\n\n")
+	s.flush()
+	return err
+}
+
+// parseError returns the error of a call, as the rpc handler does
+func parseError(err error) *errors.Error {
+	e := errors.Parse(err.Error())
+	if e.Code == 0 {
+		e.Code = 500
+		e.Id = "go.micro.rpc"
+		e.Status = http.StatusText(500)
+		e.Detail = "error during request: " + e.Detail
+	}
+	return e
+}
+
+// serveStream streams the responses of the endpoint to the request, each
+// flushed as it's received, until either the service or client is done.
+// Clients going away close the stream.
+func serveStream(w http.ResponseWriter, r *http.Request, c client.Client, service, endpoint string, request json.RawMessage, format string, opts ...client.CallOption) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// headers are passed on as with calls, the stream ends with the request
+	md, _ := metadata.FromContext(helper.RequestToContext(r))
+	ctx = metadata.NewContext(ctx, md)
+
+	// the timeout's the deadline of the service's stream, which otherwise
+	// lasts as long as the client's
+	timeout := streamTimeout
+	if t, _ := strconv.Atoi(r.Header.Get("Timeout")); t > 0 {
+		timeout = time.Duration(t) * time.Second
+	}
+	opts = append(opts, client.WithRequestTimeout(timeout))
+
+	req := c.NewRequest(service, endpoint, &request, client.WithContentType("application/json"), client.StreamingRequest())
+	stream, err := c.Stream(ctx, req, opts...)
+	if err != nil {
+		writeError(w, parseError(err))
+		return
+	}
+	defer stream.Close()
+
+	// servers discard the body of the request opening the stream, it's sent
+	// after as the generated clients do
+	if err := stream.Send(&request); err != nil {
+		writeError(w, parseError(err))
+		return
+	}
+
+	// closing the stream ends a receive waiting on it
+	go func() {
+		<-ctx.Done()
+		stream.Close()
+	}()
+
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx buffers responses otherwise
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sw := &streamWriter{w: w, format: format}
+	sw.flush()
+
+	msgs := make(chan json.RawMessage)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			var msg json.RawMessage
+			if err := stream.Recv(&msg); err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var heartbeat <-chan time.Time
+	if format == eventStream && Heartbeat > 0 {
+		t := time.NewTicker(Heartbeat)
+		defer t.Stop()
+		heartbeat = t.C
+	}
+
+	for {
+		select {
+		case msg := <-msgs:
+			if err := sw.message(msg); err != nil {
+				return
+			}
+		case err := <-errs:
+			if err != io.EOF && ctx.Err() == nil {
+				sw.error(parseError(err))
+			}
+			return
+		case <-heartbeat:
+			if err := sw.heartbeat(); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// servicesStrategy selects the nodes of the routed services
+func servicesStrategy(services []*registry.Service) selector.SelectOption {
+	return selector.WithStrategy(func(_ []*registry.Service) selector.Next {
+		return selector.Random(services)
+	})
+}
+
+// stream streams the response of the routed service if it streams, or the
+// client accepts it, returning whether it did
+func stream(w http.ResponseWriter, r *http.Request, s micro.Service, namespace string, service *api.Service) bool {
+	format, ok := streaming(r, service)
+	if !ok {
+		return false
+	}
+
+	request, err := payload(r)
+	if err != nil {
+		writeError(w, errors.BadRequest(namespace, "%s", err.Error()))
+		return true
+	}
+	serveStream(w, r, s.Client(), service.Name, service.Endpoint.Name, request, format, client.WithSelectOption(servicesStrategy(service.Services)))
+	return true
+}
+
+type streamHandler struct {
+	s micro.Service
+	r router.Router
+	h http.Handler
+}
+
+func (s *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	service, err := s.r.Route(r)
+	if err != nil {
+		s.h.ServeHTTP(w, r)
+		return
+	}
+
+	if !stream(w, r, s.s, s.r.Options().Namespace, service) {
+		s.h.ServeHTTP(w, r)
+	}
+}
+
+// Stream is a http.Handler that streams the responses of endpoints which
+// stream, or of any for clients which accept event streams or newline
+// delimited JSON, passing the rest to h
+func Stream(s micro.Service, r router.Router, h http.Handler) http.Handler {
+	return &streamHandler{
+		s: s,
+		r: r,
+		h: h,
+	}
+}
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro/go-api"
+	"github.com/micro/go-api/router"
+	"github.com/micro/go-micro"
+	"github.com/micro/go-micro/client"
+	"github.com/micro/go-micro/cmd"
+	"github.com/micro/go-micro/errors"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+	"github.com/micro/go-micro/selector"
+	"github.com/micro/go-micro/server"
+)
+
+type CountRequest struct {
+	// Count of messages sent, forever if zero
+	Count int
+	// Fail after sending the count
+	Fail bool
+}
+
+type CountResponse struct {
+	Count int
+}
+
+type Counter struct {
+	// the error the stream ended with, if it didn't finish
+	done chan error
+}
+
+func (c *Counter) Count(ctx context.Context, stream server.Stream) error {
+	var req CountRequest
+	if err := stream.Recv(&req); err != nil {
+		return err
+	}
+
+	for i := 0; req.Count == 0 || i < req.Count; i++ {
+		if err := stream.Send(&CountResponse{Count: i}); err != nil {
+			c.done <- err
+			return err
+		}
+		if req.Count > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			c.done <- ctx.Err()
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if req.Fail {
+		return errors.BadRequest("go.micro.api.counter", "counted")
+	}
+	return nil
+}
+
+var (
+	// the counting service is started once, servers share the router
+	// handlers are registered with
+	counting sync.Once
+	counter  = &Counter{done: make(chan error, 10)}
+	counterR = memory.NewRegistry()
+)
+
+// testStream returns an api streaming the counting service
+func testStream(t *testing.T) (*httptest.Server, *Counter, func()) {
+	counting.Do(func() {
+		srv := server.NewServer(
+			server.Name("go.micro.api.counter"),
+			server.Address("127.0.0.1:0"),
+			server.Registry(counterR),
+		)
+		srv.Handle(srv.NewHandler(counter))
+		if err := srv.Start(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// started servers register themselves
+	r := counterR
+	if s, err := r.GetService("go.micro.api.counter"); err != nil || len(s) == 0 {
+		t.Fatalf("expected the service registered got %v", err)
+	}
+
+	cl := client.NewClient(
+		client.Registry(r),
+		client.Selector(selector.NewSelector(selector.Registry(r))),
+	)
+	(*cmd.DefaultOptions().Client).Init(
+		client.Registry(r),
+		client.Selector(selector.NewSelector(selector.Registry(r))),
+	)
+
+	rt := router.NewRouter(
+		router.WithNamespace("go.micro.api"),
+		router.WithRegistry(r),
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", RPC)
+	mux.Handle("/", Meta(micro.NewService(micro.Client(cl), micro.Registry(r)), rt, 0))
+	ts := httptest.NewServer(mux)
+
+	return ts, counter, func() {
+		ts.Close()
+		rt.Close()
+	}
+}
+
+// lines reads the lines of the response as they're received
+func lines(rsp *http.Response) chan string {
+	ch := make(chan string, 100)
+	go func() {
+		defer close(ch)
+		r := bufio.NewReader(rsp.Body)
+		for {
+			l, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			ch <- strings.TrimSuffix(l, "\n")
+		}
+	}()
+	return ch
+}
+
+func next(t *testing.T, ch chan string) string {
+	select {
+	case l, ok := <-ch:
+		if !ok {
+			t.Fatal("expected a line, the stream ended")
+		}
+		return l
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a line flushed")
+	}
+	return ""
+}
+
+func TestStream(t *testing.T) {
+	ts, _, stop := testStream(t)
+	defer stop()
+
+	testData := []struct {
+		name   string
+		path   string
+		body   string
+		accept string
+		ct     string
+		expect []string
+	}{
+		{
+			name:   "streamed endpoint",
+			path:   "/counter/count",
+			body:   `{"count":2}`,
+			ct:     eventStream,
+			expect: []string{`data: {"Count":0}`, "", `data: {"Count":1}`, ""},
+		},
+		{
+			name:   "ndjson",
+			path:   "/counter/count",
+			body:   `{"count":3}`,
+			accept: ndjson,
+			ct:     ndjson,
+			expect: []string{`{"Count":0}`, `{"Count":1}`, `{"Count":2}`},
+		},
+		{
+			name:   "error event",
+			path:   "/counter/count",
+			body:   `{"count":1, "fail":true}`,
+			accept: "text/event-stream",
+			ct:     eventStream,
+			expect: []string{
+				`data: {"Count":0}`, "",
+				"event: error", `data: {"id":"go.micro.api.counter","code":400,"detail":"counted","status":"Bad Request"}`, "",
+			},
+		},
+		{
+			name:   "ndjson error",
+			path:   "/counter/count",
+			body:   `{"count":1, "fail":true}`,
+			accept: ndjson,
+			ct:     ndjson,
+			expect: []string{
+				`{"Count":0}`,
+				`{"error":{"id":"go.micro.api.counter","code":400,"detail":"counted","status":"Bad Request"}}`,
+			},
+		},
+		{
+			name:   "rpc",
+			path:   "/rpc",
+			body:   `{"service":"go.micro.api.counter","endpoint":"Counter.Count","request":{"count":2}}`,
+			accept: "application/x-ndjson; q=0.9",
+			ct:     ndjson,
+			expect: []string{`{"Count":0}`, `{"Count":1}`},
+		},
+	}
+
+	for _, d := range testData {
+		req, _ := http.NewRequest("POST", ts.URL+d.path, strings.NewReader(d.body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(d.accept) > 0 {
+			req.Header.Set("Accept", d.accept)
+		}
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != 200 || rsp.Header.Get("Content-Type") != d.ct {
+			b, _ := ioutil.ReadAll(rsp.Body)
+			t.Fatalf("%s: expected 200 %s got %d %s %s", d.name, d.ct, rsp.StatusCode, rsp.Header.Get("Content-Type"), b)
+		}
+		if rsp.Header.Get("Cache-Control") != "no-cache" {
+			t.Fatalf("%s: expected the stream uncached got %q", d.name, rsp.Header.Get("Cache-Control"))
+		}
+
+		ch := lines(rsp)
+		for _, e := range d.expect {
+			if l := next(t, ch); l != e {
+				t.Fatalf("%s: expected %q got %q", d.name, e, l)
+			}
+		}
+		// the stream ends with the service's
+		select {
+		case l, ok := <-ch:
+			if ok {
+				t.Fatalf("%s: expected the stream to end got %q", d.name, l)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: expected the stream to end", d.name)
+		}
+		rsp.Body.Close()
+	}
+}
+
+func TestStreamDisconnect(t *testing.T) {
+	heartbeat := Heartbeat
+	Heartbeat = 50 * time.Millisecond
+	defer func() {
+		Heartbeat = heartbeat
+	}()
+
+	ts, c, stop := testStream(t)
+	defer stop()
+
+	// event sources only GET, the stream's endless
+	req, _ := http.NewRequest("GET", ts.URL+"/counter/count", nil)
+	req.Header.Set("Accept", eventStream)
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := lines(rsp)
+	var heartbeats, events int
+	for heartbeats == 0 || events < 3 {
+		switch l := next(t, ch); {
+		case l == ": heartbeat":
+			heartbeats++
+		case strings.HasPrefix(l, "data: "):
+			var msg CountResponse
+			if err := json.Unmarshal([]byte(l[6:]), &msg); err != nil || msg.Count != events {
+				t.Fatalf("expected event %d got %q", events, l)
+			}
+			events++
+		}
+	}
+
+	// the service's stream ends with the client's
+	rsp.Body.Close()
+	select {
+	case <-c.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the service's stream closed")
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	services := []*registry.Service{{
+		Name: "go.micro.api.counter",
+		Endpoints: []*registry.Endpoint{
+			{Name: "Counter.Count", Metadata: map[string]string{"stream": "true"}},
+			{Name: "Counter.Get"},
+		},
+	}}
+
+	testData := []struct {
+		accept   string
+		endpoint string
+		format   string
+	}{
+		{"", "Counter.Count", eventStream},
+		{"application/x-ndjson", "Counter.Count", ndjson},
+		{"", "Counter.Get", ""},
+		{"application/json", "Counter.Get", ""},
+		{"text/html, text/event-stream;q=0.9", "Counter.Get", eventStream},
+		{"application/X-NDJSON", "Counter.Get", ndjson},
+	}
+
+	for _, d := range testData {
+		r := httptest.NewRequest("GET", "/counter", nil)
+		if len(d.accept) > 0 {
+			r.Header.Set("Accept", d.accept)
+		}
+		format, ok := streaming(r, &api.Service{
+			Name:     "go.micro.api.counter",
+			Endpoint: &api.Endpoint{Name: d.endpoint},
+			Services: services,
+		})
+		if ok != (len(d.format) > 0) || format != d.format {
+			t.Fatalf("expected %s accepting %q streamed as %q got %q %v", d.endpoint, d.accept, d.format, format, ok)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/micro/go-api"
+	"github.com/micro/go-micro/registry"
+)
+
+var (
+	// as the micro resolver's
+	proxyRe   = regexp.MustCompile("^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)*$")
+	versionRe = regexp.MustCompilePOSIX("^v[0-9]+$")
+
+	// errors are those of go-micro
+	errorSchema = &schema{
+		Type: "object",
+		Properties: map[string]*schema{
+			"id":     {Type: "string"},
+			"code":   {Type: "integer", Format: "int32"},
+			"detail": {Type: "string"},
+			"status": {Type: "string"},
+		},
+	}
+	errorRef = &schema{Ref: "#/components/schemas/Error"}
+)
+
+type document struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       info                   `json:"info"`
+	Paths      map[string]*pathItem   `json:"paths"`
+	Components map[string]interface{} `json:"components"`
+}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type pathItem struct {
+	Get    *operation `json:"get,omitempty"`
+	Put    *operation `json:"put,omitempty"`
+	Post   *operation `json:"post,omitempty"`
+	Delete *operation `json:"delete,omitempty"`
+	Patch  *operation `json:"patch,omitempty"`
+}
+
+type operation struct {
+	OperationId string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags"`
+	RequestBody *body                `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type body struct {
+	Content map[string]*media `json:"content"`
+}
+
+type response struct {
+	Description string            `json:"description"`
+	Content     map[string]*media `json:"content,omitempty"`
+}
+
+type media struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties bool               `json:"additionalProperties,omitempty"`
+}
+
+// generic is the schema of values the registry doesn't describe
+func generic() *schema {
+	return &schema{Type: "object", AdditionalProperties: true}
+}
+
+// scalar returns the schema of a go type by its name
+func scalar(typ string) *schema {
+	switch typ {
+	case "string":
+		return &schema{Type: "string"}
+	case "bool":
+		return &schema{Type: "boolean"}
+	case "int", "int64", "uint", "uint64":
+		return &schema{Type: "integer", Format: "int64"}
+	case "int8", "int16", "int32", "uint8", "uint16", "uint32":
+		return &schema{Type: "integer", Format: "int32"}
+	case "float32":
+		return &schema{Type: "number", Format: "float"}
+	case "float64":
+		return &schema{Type: "number", Format: "double"}
+	}
+	return nil
+}
+
+// valueSchema returns the schema of a value the registry describes
+func valueSchema(v *registry.Value) *schema {
+	if v == nil {
+		return generic()
+	}
+
+	if strings.HasPrefix(v.Type, "[]") {
+		// bytes are base64 encoded
+		if v.Type == "[]uint8" {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		s := &schema{Type: "array"}
+		if len(v.Values) > 0 {
+			s.Items = valueSchema(v.Values[0])
+		} else if s.Items = scalar(strings.TrimPrefix(v.Type, "[]")); s.Items == nil {
+			s.Items = generic()
+		}
+		return s
+	}
+
+	if s := scalar(v.Type); s != nil {
+		return s
+	}
+
+	var props map[string]*schema
+	for _, f := range v.Values {
+		// fields which aren't encoded
+		if f.Name == "-" || strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+		if props == nil {
+			props = make(map[string]*schema)
+		}
+		props[f.Name] = valueSchema(f)
+	}
+	if props == nil {
+		return generic()
+	}
+	return &schema{Type: "object", Properties: props}
+}
+
+// toCamel is the micro resolver's, it maps paths to methods
+func toCamel(s string) string {
+	words := strings.Split(s, "-")
+	var out string
+	for _, word := range words {
+		out += strings.Title(word)
+	}
+	return out
+}
+
+// lowerFirst reverses toCamel, Title leaves the rest of a word be
+func lowerFirst(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// rpcPath returns the path the micro resolver routes to the method of the
+// service, the reverse of /foo/bar => service foo method Foo.Bar
+func rpcPath(name, method string) string {
+	parts := strings.Split(name, ".")
+	m := strings.Split(method, ".")
+	if len(m) != 2 {
+		return ""
+	}
+
+	switch {
+	// /foo/bar => foo Foo.Bar
+	case len(parts) == 1 && toCamel(parts[0]) == m[0]:
+		return "/" + parts[0] + "/" + lowerFirst(m[1])
+	// /v1/foo/bar => v1.foo Foo.Bar
+	case len(parts) == 2 && versionRe.MatchString(parts[0]) && toCamel(parts[1]) == m[0]:
+		return "/" + parts[0] + "/" + parts[1] + "/" + lowerFirst(m[1])
+	// /v1/foo/bar would be v1.foo
+	case len(parts) == 1 && versionRe.MatchString(parts[0]):
+		return ""
+	}
+
+	// /foo/bar/baz => foo Bar.Baz
+	return "/" + strings.Join(parts, "/") + "/" + lowerFirst(m[0]) + "/" + lowerFirst(m[1])
+}
+
+// proxyPath returns the path the micro resolver proxies to the service
+// from, as well as the paths under it
+func proxyPath(name string) string {
+	parts := strings.Split(name, ".")
+
+	switch {
+	case len(parts) == 1 && proxyRe.MatchString(parts[0]):
+		return "/" + parts[0]
+	case len(parts) == 2 && versionRe.MatchString(parts[0]) && proxyRe.MatchString(parts[1]):
+		return "/" + parts[0] + "/" + parts[1]
+	}
+	return ""
+}
+
+// literal returns the path an endpoint's POSIX regex matches, if it
+// matches just the one
+func literal(p string) string {
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "^"), "$")
+	if !strings.HasPrefix(p, "/") || regexp.QuoteMeta(p) != p {
+		return ""
+	}
+	return p
+}
+
+// proxied returns whether requests for the handler are proxied to the
+// service rather than decoded for its endpoints
+func proxied(handler string) bool {
+	switch handler {
+	case "http", "proxy", "web":
+		return true
+	}
+	return false
+}
+
+type builder struct {
+	opts  Options
+	paths map[string]*pathItem
+	ids   map[string]bool
+}
+
+// add adds the operation of the method at the path, the first operation
+// added for a path and method is kept
+func (b *builder) add(path, method string, op *operation) {
+	item, ok := b.paths[path]
+	if !ok {
+		item = &pathItem{}
+	}
+
+	var o **operation
+	switch strings.ToUpper(method) {
+	case "GET":
+		o = &item.Get
+	case "PUT":
+		o = &item.Put
+	case "POST":
+		o = &item.Post
+	case "DELETE":
+		o = &item.Delete
+	case "PATCH":
+		o = &item.Patch
+	default:
+		return
+	}
+	if *o != nil {
+		return
+	}
+
+	// operations are unique across the document
+	id := op.OperationId
+	for i := 2; b.ids[op.OperationId]; i++ {
+		op.OperationId = fmt.Sprintf("%s.%d", id, i)
+	}
+	b.ids[op.OperationId] = true
+
+	*o = op
+	b.paths[path] = item
+}
+
+// rpc adds the endpoint of the service, its request decoded from the body
+func (b *builder) rpc(service string, ep *registry.Endpoint, desc, path string, methods []string, handler string) {
+	req, rsp := valueSchema(ep.Request), valueSchema(ep.Response)
+	// the api handler passes the http request and response as they are
+	if handler == "api" {
+		req, rsp = generic(), generic()
+	}
+
+	for _, m := range methods {
+		id := service + "." + ep.Name
+		if len(methods) > 1 {
+			id += "." + strings.ToLower(m)
+		}
+
+		op := &operation{
+			OperationId: id,
+			Summary:     ep.Name,
+			Description: desc,
+			Tags:        []string{service},
+			Responses: map[string]*response{
+				"200": {
+					Description: "OK",
+					Content:     map[string]*media{"application/json": {Schema: rsp}},
+				},
+				"default": {
+					Description: "Error",
+					Content:     map[string]*media{"application/json": {Schema: errorRef}},
+				},
+			},
+		}
+		if m := strings.ToUpper(m); m != "GET" && m != "DELETE" {
+			op.RequestBody = &body{
+				Content: map[string]*media{"application/json": {Schema: req}},
+			}
+		}
+		b.add(path, m, op)
+	}
+}
+
+// proxy adds the service requests are proxied to at the path
+func (b *builder) proxy(service, desc, path string, methods []string) {
+	if len(desc) == 0 {
+		desc = "Proxied to " + service + " as are the paths under " + path
+	}
+
+	for _, m := range methods {
+		b.add(path, m, &operation{
+			OperationId: service + "." + strings.ToLower(m),
+			Description: desc,
+			Tags:        []string{service},
+			Responses: map[string]*response{
+				"default": {Description: "The response of " + service},
+			},
+		})
+	}
+}
+
+// service adds the endpoints of the versions of the service
+func (b *builder) service(versions []*registry.Service) {
+	if len(versions) == 0 {
+		return
+	}
+	service := versions[0].Name
+	name := service
+	if len(b.opts.Namespace) > 0 {
+		name = strings.TrimPrefix(service, b.opts.Namespace+".")
+	}
+
+	// endpoints of the versions, those of the first listed are kept
+	var endpoints []*registry.Endpoint
+	seen := make(map[string]bool)
+	for _, v := range versions {
+		for _, ep := range v.Endpoints {
+			if !seen[ep.Name] {
+				seen[ep.Name] = true
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Name < endpoints[j].Name
+	})
+
+	// services the api proxies to are routed by their name, not endpoints
+	routed := proxied(b.opts.Handler)
+	// and events to topics rather than services
+	events := b.opts.Handler == "event"
+
+	for _, ep := range endpoints {
+		// routed by the endpoint's metadata
+		if end := api.Decode(ep.Metadata); api.Validate(end) == nil {
+			methods := end.Method
+			var paths []string
+			for _, p := range end.Path {
+				if p = literal(p); len(p) > 0 {
+					paths = append(paths, p)
+				}
+			}
+
+			if len(paths) > 0 {
+				if proxied(end.Handler) {
+					if len(methods) == 0 {
+						methods = []string{"GET", "POST"}
+					}
+					for _, p := range paths {
+						b.proxy(service, end.Description, p, methods)
+					}
+					continue
+				}
+
+				if len(methods) == 0 {
+					methods = []string{"POST"}
+				}
+				for _, p := range paths {
+					b.rpc(service, ep, end.Description, p, methods, end.Handler)
+				}
+				continue
+			}
+		}
+
+		// routed by the resolver
+		if routed || events {
+			continue
+		}
+		if p := rpcPath(name, ep.Name); len(p) > 0 {
+			b.rpc(service, ep, "", p, []string{"POST"}, b.opts.Handler)
+		}
+	}
+
+	if routed {
+		if p := proxyPath(name); len(p) > 0 {
+			b.proxy(service, "", p, []string{"GET", "POST"})
+		}
+	}
+}
+
+// build returns the document of the versions of each service
+func build(opts Options, services [][]*registry.Service) *document {
+	b := &builder{
+		opts:  opts,
+		paths: make(map[string]*pathItem),
+		ids:   make(map[string]bool),
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i][0].Name < services[j][0].Name
+	})
+	for _, versions := range services {
+		b.service(versions)
+	}
+
+	return &document{
+		OpenAPI: "3.0.3",
+		Info: info{
+			Title:   opts.Title,
+			Version: opts.Version,
+		},
+		Paths: b.paths,
+		Components: map[string]interface{}{
+			"schemas": map[string]*schema{"Error": errorSchema},
+		},
+	}
+}
@@ -0,0 +1,233 @@
+// Package openapi documents the endpoints of the services the api routes
+// to as an OpenAPI 3 document, rebuilt as services come and go
+package openapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/registry"
+)
+
+// defaultWindow is the window in which changes of services are coalesced
+const defaultWindow = time.Second
+
+type Options struct {
+	// Registry the services are listed from
+	Registry registry.Registry
+	// Namespace of the services the api routes to
+	Namespace string
+	// Handler of the api e.g. meta, rpc, api, http, proxy
+	Handler string
+	// Title of the document, the namespace if empty
+	Title string
+	// Version of the document
+	Version string
+}
+
+type openapi struct {
+	opts Options
+
+	sync.RWMutex
+	json []byte
+	yaml []byte
+
+	// changes of services within the window are coalesced
+	window time.Duration
+	exit   chan bool
+}
+
+// New returns the document of the options' services, which is built on
+// Start
+func New(opts Options) *openapi {
+	if opts.Registry == nil {
+		opts.Registry = registry.DefaultRegistry
+	}
+	if len(opts.Handler) == 0 {
+		opts.Handler = "meta"
+	}
+	if len(opts.Title) == 0 {
+		opts.Title = opts.Namespace
+	}
+	if len(opts.Version) == 0 {
+		opts.Version = "latest"
+	}
+
+	return &openapi{
+		opts:   opts,
+		window: defaultWindow,
+		exit:   make(chan bool),
+	}
+}
+
+// routed returns whether the api routes to the service
+func (o *openapi) routed(name string) bool {
+	return len(o.opts.Namespace) == 0 || strings.HasPrefix(name, o.opts.Namespace+".")
+}
+
+// refresh rebuilds the document from the registry
+func (o *openapi) refresh() error {
+	list, err := o.opts.Registry.ListServices()
+	if err != nil {
+		return err
+	}
+
+	var services [][]*registry.Service
+	seen := make(map[string]bool)
+	for _, s := range list {
+		// each version is listed
+		if seen[s.Name] || !o.routed(s.Name) {
+			continue
+		}
+		seen[s.Name] = true
+
+		versions, err := o.opts.Registry.GetService(s.Name)
+		if err != nil {
+			continue
+		}
+		services = append(services, versions)
+	}
+
+	doc := build(o.opts, services)
+
+	j, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	y, err := toYAML(j)
+	if err != nil {
+		return err
+	}
+
+	o.Lock()
+	o.json = append(j, '\n')
+	o.yaml = y
+	o.Unlock()
+	return nil
+}
+
+// watch signals services changing until stopped
+func (o *openapi) watch(changed chan bool) {
+	var attempts int
+
+	for {
+		w, err := o.opts.Registry.Watch()
+		if err != nil {
+			attempts++
+			log.Println("Error watching services", err)
+			select {
+			case <-time.After(time.Duration(attempts) * time.Second):
+				continue
+			case <-o.exit:
+				return
+			}
+		}
+		attempts = 0
+
+		// changes before watching may have been missed
+		select {
+		case changed <- true:
+		default:
+		}
+
+		done := make(chan bool)
+		go func() {
+			select {
+			case <-o.exit:
+			case <-done:
+			}
+			w.Stop()
+		}()
+
+		for {
+			res, err := w.Next()
+			if err != nil {
+				break
+			}
+			if res.Service == nil || !o.routed(res.Service.Name) {
+				continue
+			}
+			select {
+			case changed <- true:
+			default:
+			}
+		}
+		close(done)
+
+		select {
+		case <-o.exit:
+			return
+		default:
+		}
+	}
+}
+
+// run refreshes the document as services change
+func (o *openapi) run() {
+	changed := make(chan bool, 1)
+	go o.watch(changed)
+
+	for {
+		select {
+		case <-changed:
+		case <-o.exit:
+			return
+		}
+
+		// changes come in bursts as services start and may be seen before
+		// they're listed, so they're coalesced
+		select {
+		case <-time.After(o.window):
+		case <-o.exit:
+			return
+		}
+
+		if err := o.refresh(); err != nil {
+			log.Println("Error refreshing openapi document", err)
+		}
+	}
+}
+
+// Start builds the document and watches for services changing
+func (o *openapi) Start() error {
+	if err := o.refresh(); err != nil {
+		return err
+	}
+	go o.run()
+	return nil
+}
+
+// Stop stops watching the services
+func (o *openapi) Stop() {
+	close(o.exit)
+}
+
+func (o *openapi) serve(w http.ResponseWriter, r *http.Request, ct string, b []byte) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Write(b)
+}
+
+// JSONHandler serves the document as JSON
+func (o *openapi) JSONHandler(w http.ResponseWriter, r *http.Request) {
+	o.RLock()
+	b := o.json
+	o.RUnlock()
+	o.serve(w, r, "application/json", b)
+}
+
+// YAMLHandler serves the document as YAML
+func (o *openapi) YAMLHandler(w http.ResponseWriter, r *http.Request) {
+	o.RLock()
+	b := o.yaml
+	o.RUnlock()
+	o.serve(w, r, "application/yaml", b)
+}
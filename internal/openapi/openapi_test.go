@@ -0,0 +1,282 @@
+package openapi
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro/go-api"
+	"github.com/micro/go-micro/registry"
+	"github.com/micro/go-micro/registry/memory"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+func value(name, typ string, values ...*registry.Value) *registry.Value {
+	return &registry.Value{Name: name, Type: typ, Values: values}
+}
+
+// fixture returns a registry of services routed in each way the api does
+func fixture() registry.Registry {
+	reg := memory.NewRegistry()
+
+	services := []*registry.Service{
+		// routed by the resolver
+		{
+			Name:    "go.micro.api.greeter",
+			Version: "1",
+			Endpoints: []*registry.Endpoint{
+				{
+					Name: "Greeter.Hello",
+					Request: value("Request", "Request",
+						value("name", "string"),
+						value("-", "[]uint8"),
+					),
+					Response: value("Response", "Response",
+						value("msg", "string"),
+						value("count", "int32"),
+						value("tags", "[]string"),
+						value("avatar", "[]uint8"),
+					),
+				},
+			},
+		},
+		// a version with endpoints the registry doesn't describe
+		{
+			Name:    "go.micro.api.greeter",
+			Version: "2",
+			Endpoints: []*registry.Endpoint{
+				{Name: "Greeter.Hello"},
+				{Name: "Greeter.Goodbye"},
+			},
+		},
+		// versioned
+		{
+			Name: "go.micro.api.v1.users",
+			Endpoints: []*registry.Endpoint{
+				{
+					Name:     "Users.Read",
+					Request:  value("ReadRequest", "ReadRequest", value("id", "string")),
+					Response: value("ReadResponse", "ReadResponse", value("name", "string"), value("age", "int64")),
+				},
+				{
+					Name:    "Accounts.List",
+					Request: value("ListRequest", "ListRequest"),
+					Response: value("ListResponse", "ListResponse",
+						value("accounts", "[]Account",
+							value("Account", "Account", value("id", "string"), value("balance", "float64")),
+						),
+					),
+				},
+			},
+		},
+		// routed by metadata
+		{
+			Name: "go.micro.api.store",
+			Endpoints: []*registry.Endpoint{
+				{
+					Name:     "Store.Read",
+					Request:  value("ReadRequest", "ReadRequest", value("key", "string")),
+					Response: value("ReadResponse", "ReadResponse", value("value", "[]uint8")),
+					Metadata: api.Encode(&api.Endpoint{
+						Name:        "Store.Read",
+						Description: "Reads a key",
+						Handler:     "rpc",
+						Method:      []string{"GET", "POST"},
+						Path:        []string{"^/store/read$"},
+					}),
+				},
+				{
+					Name:     "Store.Upload",
+					Request:  value("Request", "Request", value("method", "string")),
+					Response: value("Response", "Response", value("body", "string")),
+					Metadata: api.Encode(&api.Endpoint{
+						Name:    "Store.Upload",
+						Handler: "api",
+						Method:  []string{"PUT"},
+						Path:    []string{"/upload"},
+					}),
+				},
+				{
+					Name: "Store.Files",
+					Metadata: api.Encode(&api.Endpoint{
+						Name:    "Store.Files",
+						Handler: "proxy",
+						Path:    []string{"/files"},
+					}),
+				},
+				// not a path of its own, so routed by the resolver
+				{
+					Name:     "Store.Search",
+					Request:  value("SearchRequest", "SearchRequest", value("query", "string")),
+					Response: value("SearchResponse", "SearchResponse", value("keys", "[]string")),
+					Metadata: api.Encode(&api.Endpoint{
+						Name:    "Store.Search",
+						Handler: "rpc",
+						Path:    []string{"^/store/search/.*$"},
+					}),
+				},
+			},
+		},
+		// services without endpoints are only proxied to
+		{
+			Name: "go.micro.api.web",
+		},
+		// the api doesn't route to other namespaces
+		{
+			Name: "go.micro.srv.greeter",
+			Endpoints: []*registry.Endpoint{
+				{Name: "Greeter.Hello"},
+			},
+		},
+	}
+
+	for _, s := range services {
+		reg.Register(s)
+	}
+	return reg
+}
+
+func TestDocument(t *testing.T) {
+	testData := []struct {
+		golden  string
+		handler string
+	}{
+		{"meta", "meta"},
+		{"api", "api"},
+		{"http", "http"},
+	}
+
+	for _, d := range testData {
+		o := New(Options{
+			Registry:  fixture(),
+			Namespace: "go.micro.api",
+			Handler:   d.handler,
+		})
+		if err := o.refresh(); err != nil {
+			t.Fatal(err)
+		}
+
+		for ext, got := range map[string][]byte{"json": o.json, "yaml": o.yaml} {
+			golden := filepath.Join("testdata", d.golden+"."+ext)
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s handler: expected the document of %s got\n%s", d.handler, golden, got)
+			}
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	testData := []struct {
+		name   string
+		method string
+		rpc    string
+		proxy  string
+	}{
+		{"foo", "Foo.Bar", "/foo/bar", "/foo"},
+		{"foo-bar", "FooBar.Baz", "/foo-bar/baz", "/foo-bar"},
+		{"foo", "Bar.Baz", "/foo/bar/baz", "/foo"},
+		{"v1.foo", "Foo.Bar", "/v1/foo/bar", "/v1/foo"},
+		{"v1.foo", "Bar.Baz", "/v1/foo/bar/baz", "/v1/foo"},
+		{"foo.bar", "Baz.Zool", "/foo/bar/baz/zool", ""},
+		{"v1", "Foo.Bar", "", "/v1"},
+		{"foo", "Bar", "", "/foo"},
+	}
+
+	for _, d := range testData {
+		if p := rpcPath(d.name, d.method); p != d.rpc {
+			t.Fatalf("expected %s %s routed from %q got %q", d.name, d.method, d.rpc, p)
+		}
+		if p := proxyPath(d.name); p != d.proxy {
+			t.Fatalf("expected %s proxied from %q got %q", d.name, d.proxy, p)
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	testData := []struct {
+		json string
+		yaml string
+	}{
+		{`{}`, "{}\n"},
+		{`{"b": 1, "a": [true, null, "x"]}`, "b: 1\na:\n  - true\n  - null\n  - \"x\"\n"},
+		{`{"a b": {"c": []}, "$ref": "y\n"}`, "\"a b\":\n  c: []\n\"$ref\": \"y\\n\"\n"},
+		{`[{"a": 1, "b": {"c": 2}}, [1]]`, "- a: 1\n  b:\n    c: 2\n- - 1\n"},
+	}
+
+	for _, d := range testData {
+		b, err := toYAML([]byte(d.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != d.yaml {
+			t.Fatalf("expected %s as\n%sgot\n%s", d.json, d.yaml, b)
+		}
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	reg := fixture()
+	o := New(Options{Registry: reg, Namespace: "go.micro.api"})
+	o.window = 10 * time.Millisecond
+	if err := o.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer o.Stop()
+
+	get := func(path string) (string, string) {
+		w := httptest.NewRecorder()
+		if strings.HasSuffix(path, ".json") {
+			o.JSONHandler(w, httptest.NewRequest("GET", path, nil))
+		} else {
+			o.YAMLHandler(w, httptest.NewRequest("GET", path, nil))
+		}
+		return w.Header().Get("Content-Type"), w.Body.String()
+	}
+
+	if ct, b := get("/openapi.json"); ct != "application/json" || !strings.Contains(b, `"/greeter/hello"`) {
+		t.Fatalf("expected the json document got %s %s", ct, b)
+	}
+	if ct, b := get("/openapi.yaml"); ct != "application/yaml" || !strings.Contains(b, "/greeter/hello:") {
+		t.Fatalf("expected the yaml document got %s %s", ct, b)
+	}
+
+	w := httptest.NewRecorder()
+	o.JSONHandler(w, httptest.NewRequest("POST", "/openapi.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected posts not allowed got %d", w.Code)
+	}
+
+	// the document follows the registry
+	reg.Register(&registry.Service{
+		Name:      "go.micro.api.weather",
+		Endpoints: []*registry.Endpoint{{Name: "Weather.Forecast"}},
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, b := get("/openapi.json")
+		if strings.Contains(b, `"/weather/forecast"`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the registered service documented got %s", b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// keys which needn't be quoted
+var plainRe = regexp.MustCompile(`^[a-zA-Z_/][a-zA-Z0-9_./{}-]*$`)
+
+// node is a JSON value, objects keep the order of their keys
+type node struct {
+	// the scalar as YAML, an object or array if empty
+	scalar string
+	object bool
+	keys   []string
+	values []*node
+}
+
+func decode(d *json.Decoder) (*node, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := t.(type) {
+	case json.Delim:
+		n := &node{object: v == '{'}
+		for d.More() {
+			if n.object {
+				t, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, t.(string))
+			}
+			c, err := decode(d)
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, c)
+		}
+		// the closing delimiter
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return &node{scalar: strconv.Quote(v)}, nil
+	case json.Number:
+		return &node{scalar: v.String()}, nil
+	case bool:
+		return &node{scalar: strconv.FormatBool(v)}, nil
+	case nil:
+		return &node{scalar: "null"}, nil
+	}
+
+	return nil, errors.New("openapi: unexpected JSON token")
+}
+
+// inline returns the node if it's written on the line of its key
+func (n *node) inline() (string, bool) {
+	switch {
+	case len(n.scalar) > 0:
+		return n.scalar, true
+	case len(n.values) > 0:
+		return "", false
+	case n.object:
+		return "{}", true
+	}
+	return "[]", true
+}
+
+func (n *node) write(b *bytes.Buffer, indent int) {
+	pad := strings.Repeat("  ", indent)
+
+	for i, c := range n.values {
+		if n.object {
+			k := n.keys[i]
+			if !plainRe.MatchString(k) {
+				k = strconv.Quote(k)
+			}
+			b.WriteString(pad + k + ":")
+		} else {
+			b.WriteString(pad + "-")
+		}
+
+		if s, ok := c.inline(); ok {
+			b.WriteString(" " + s + "\n")
+			continue
+		}
+
+		// items of arrays start on the line of their dash
+		if !n.object {
+			var nested bytes.Buffer
+			c.write(&nested, indent+1)
+			b.WriteString(" " + strings.TrimPrefix(nested.String(), pad+"  "))
+			continue
+		}
+
+		b.WriteString("\n")
+		c.write(b, indent+1)
+	}
+}
+
+// toYAML converts the JSON document to YAML, keeping the order of its keys
+func toYAML(j []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+
+	n, err := decode(d)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := n.inline(); ok {
+		return []byte(s + "\n"), nil
+	}
+
+	var b bytes.Buffer
+	n.write(&b, 0)
+	return b.Bytes(), nil
+}
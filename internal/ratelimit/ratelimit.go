@@ -0,0 +1,191 @@
+// Package ratelimit limits the requests of each client to the api
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+)
+
+var (
+	// DefaultMaxKeys is the most clients tracked at once
+	DefaultMaxKeys = 100000
+	// how often buckets which have refilled are removed
+	sweepInterval = time.Minute
+)
+
+type Options struct {
+	// Rate is the requests per second each client may make
+	Rate float64
+	// Burst is the requests a client may make at once, at least one
+	Burst int
+	// Header keys clients by its value rather than their IP e.g. an
+	// API key. Requests without it are keyed by IP.
+	Header string
+	// Id of the errors returned
+	Id string
+	// MaxKeys is the most clients tracked, the least recently seen are
+	// forgotten beyond it
+	MaxKeys int
+}
+
+// bucket holds the requests a client may still make, refilled over time
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	opts Options
+	now  func() time.Time
+
+	sync.Mutex
+	// buckets by key, their elements in lru most recently used first
+	buckets map[string]*list.Element
+	lru     *list.List
+	swept   time.Time
+}
+
+// New returns the limiter of the options
+func New(opts Options) *limiter {
+	if opts.Burst < 1 {
+		opts.Burst = int(math.Ceil(opts.Rate))
+		if opts.Burst < 1 {
+			opts.Burst = 1
+		}
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = DefaultMaxKeys
+	}
+	if len(opts.Id) == 0 {
+		opts.Id = "go.micro.api"
+	}
+
+	return &limiter{
+		opts:    opts,
+		now:     time.Now,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// refill adds the tokens earned since the bucket was last used
+func (l *limiter) refill(b *bucket, now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += l.opts.Rate * elapsed.Seconds()
+		if max := float64(l.opts.Burst); b.tokens > max {
+			b.tokens = max
+		}
+		b.last = now
+	}
+}
+
+// take uses up one of the requests of the client, returning how long
+// until there is one if none are left
+func (l *limiter) take(key string) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+	} else {
+		if len(l.buckets) >= l.opts.MaxKeys {
+			l.evict()
+		}
+		b = &bucket{key: key, tokens: float64(l.opts.Burst), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	l.refill(b, now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.opts.Rate * float64(time.Second))
+}
+
+// sweep removes the buckets which have refilled since they're the same
+// as new ones, the lock must be held
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+
+	for e := l.lru.Front(); e != nil; {
+		next := e.Next()
+		b := e.Value.(*bucket)
+		l.refill(b, now)
+		if b.tokens >= float64(l.opts.Burst) {
+			l.remove(e)
+		}
+		e = next
+	}
+}
+
+// evict makes room for another bucket, removing the least recently used.
+// The lock must be held.
+func (l *limiter) evict() {
+	if e := l.lru.Back(); e != nil {
+		l.remove(e)
+	}
+}
+
+// remove forgets the bucket of the element, the lock must be held
+func (l *limiter) remove(e *list.Element) {
+	l.lru.Remove(e)
+	delete(l.buckets, e.Value.(*bucket).key)
+}
+
+// key returns the client of the request
+func (l *limiter) key(r *http.Request) string {
+	if len(l.opts.Header) > 0 {
+		if v := r.Header.Get(l.opts.Header); len(v) > 0 {
+			return "header:" + v
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Handler rejects the requests of clients over the limit with 429 Too
+// Many Requests, passing the rest to h
+func (l *limiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := l.take(l.key(r))
+		if ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// whole seconds, rounded up so retrying then succeeds
+		secs := int(math.Ceil(wait.Seconds()))
+		if secs < 1 {
+			secs = 1
+		}
+
+		e := errors.New(l.opts.Id, "rate limit exceeded, retry in "+strconv.Itoa(secs)+"s", http.StatusTooManyRequests)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(e.Error()))
+	})
+}
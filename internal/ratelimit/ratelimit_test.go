@@ -0,0 +1,184 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/errors"
+)
+
+// clock is the time of a limiter moved on by tests
+type clock struct {
+	sync.Mutex
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.t
+}
+
+func (c *clock) add(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestHandler(t *testing.T) {
+	l := New(Options{Rate: 5, Burst: 20, Header: "X-Api-Key", Id: "go.micro.api"})
+	c := &clock{t: time.Unix(1e9, 0)}
+	l.now = c.now
+
+	var served int64
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&served, 1)
+	}))
+
+	request := func(addr, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = addr
+		if len(key) > 0 {
+			r.Header.Set("X-Api-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// a flood of concurrent requests only gets the burst through
+	var wg sync.WaitGroup
+	var limited int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if request("10.0.0.1:1234", "").Code == http.StatusTooManyRequests {
+					atomic.AddInt64(&limited, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if served != 20 || limited != 480 {
+		t.Fatalf("expected 20 requests served and 480 limited got %d and %d", served, limited)
+	}
+
+	w := request("10.0.0.1:5678", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client limited on any port got %d", w.Code)
+	}
+	if ra := w.Header().Get("Retry-After"); ra != "1" {
+		t.Fatalf("expected to retry in a second got %q", ra)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a json error got %q", ct)
+	}
+
+	var e errors.Error
+	if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Id != "go.micro.api" || e.Code != 429 || e.Status != "Too Many Requests" || e.Detail != "rate limit exceeded, retry in 1s" {
+		t.Fatalf("unexpected error %+v", e)
+	}
+
+	// other clients, by IP or key, have their own limit
+	if w := request("10.0.0.2:1234", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected another IP served got %d", w.Code)
+	}
+	if w := request("10.0.0.1:1234", "key"); w.Code != http.StatusOK {
+		t.Fatalf("expected the api key served got %d", w.Code)
+	}
+
+	// the limit recovers at the rate
+	c.add(time.Second)
+	served = 0
+	for i := 0; i < 10; i++ {
+		request("10.0.0.1:1234", "")
+	}
+	if served != 5 {
+		t.Fatalf("expected 5 requests served a second later got %d", served)
+	}
+
+	// and to the burst once idle
+	c.add(time.Minute)
+	served = 0
+	for i := 0; i < 30; i++ {
+		request("10.0.0.1:1234", "")
+	}
+	if served != 20 {
+		t.Fatalf("expected the burst served once idle got %d", served)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	l := New(Options{Rate: 0.1})
+	c := &clock{t: time.Unix(1e9, 0)}
+	l.now = c.now
+
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		return w
+	}
+
+	if w := request(); w.Code != http.StatusOK {
+		t.Fatalf("expected the burst of one served got %d", w.Code)
+	}
+
+	c.add(2500 * time.Millisecond)
+	if ra := request().Header().Get("Retry-After"); ra != "8" {
+		t.Fatalf("expected to retry in 8s got %s", ra)
+	}
+
+	c.add(7500 * time.Millisecond)
+	if w := request(); w.Code != http.StatusOK {
+		t.Fatalf("expected the request served after waiting got %d", w.Code)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	l := New(Options{Rate: 1, Burst: 1, MaxKeys: 10})
+	c := &clock{t: time.Unix(1e9, 0)}
+	l.now = c.now
+
+	for i := 0; i < 100; i++ {
+		l.take(fmt.Sprintf("client-%d", i))
+		c.add(time.Millisecond)
+		// it's used again so it's kept
+		l.take("client-0")
+	}
+
+	if n := len(l.buckets); n != 10 {
+		t.Fatalf("expected 10 clients tracked got %d", n)
+	}
+
+	// the most recent clients are kept
+	if ok, _ := l.take("client-99"); ok {
+		t.Fatal("expected the latest client still limited")
+	}
+	if _, ok := l.buckets["client-0"]; !ok {
+		t.Fatal("expected the recently used client kept")
+	}
+	if _, ok := l.buckets["client-1"]; ok {
+		t.Fatal("expected the least recently used client forgotten")
+	}
+
+	// idle clients are swept
+	c.add(2 * sweepInterval)
+	l.take("client-100")
+	if n := len(l.buckets); n != 1 {
+		t.Fatalf("expected the refilled clients swept got %d", n)
+	}
+}
@@ -0,0 +1,139 @@
+// Package requestid gives each request through the api an ID, passed to
+// services in the request's metadata and returned in the response so a
+// request may be traced from the edge
+package requestid
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro/go-log"
+)
+
+// DefaultHeader the ID is read from and written to
+var DefaultHeader = "X-Request-Id"
+
+// the longest ID accepted from clients
+const maxLength = 128
+
+// logf logs the requests handled
+var logf = log.Logf
+
+type Options struct {
+	// Header of the ID, the default if empty e.g. X-Correlation-Id
+	Header string
+	// Log logs each request with its ID
+	Log bool
+}
+
+type requestId struct {
+	opts Options
+}
+
+type contextKey struct{}
+
+// New returns the request IDs of the options
+func New(opts Options) *requestId {
+	if len(opts.Header) == 0 {
+		opts.Header = DefaultHeader
+	}
+	return &requestId{opts: opts}
+}
+
+// FromContext returns the ID of the request of the context
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok
+}
+
+// NewContext returns the context with the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// valid returns whether a client's ID can be passed on, those which
+// aren't are replaced rather than logged or forwarded
+func valid(id string) bool {
+	if len(id) == 0 || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// writer records the status written, it's still hijacked by websockets
+// and flushed by streams
+type writer struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *writer) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("requestid: response can't be hijacked")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hj.Hijack()
+}
+
+// Handler sets the ID of requests to h, the client's if valid or a new
+// one, and writes it in the response headers. Services receive it in the
+// request metadata as handlers pass on the request headers.
+func (i *requestId) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(i.opts.Header)
+		if !valid(id) {
+			id = uuid.New().String()
+		}
+
+		r.Header.Set(i.opts.Header, id)
+		w.Header().Set(i.opts.Header, id)
+		r = r.WithContext(NewContext(r.Context(), id))
+
+		if !i.opts.Log {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := &writer{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+		logf("%s %s %s %d %v %s", r.RemoteAddr, r.Method, r.URL.RequestURI(), rw.status, time.Since(start), id)
+	})
+}
@@ -0,0 +1,151 @@
+package requestid
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/micro/go-micro/metadata"
+	"github.com/micro/micro/internal/helper"
+)
+
+// backend returns the ID of the request's metadata, as rpc handlers pass
+// it to services, and its context
+func backend(t *testing.T, header string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md, ok := metadata.FromContext(helper.RequestToContext(r))
+		if !ok {
+			t.Fatal("expected the request's metadata")
+		}
+		id, _ := FromContext(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprintf(w, "%s %s", md[http.CanonicalHeaderKey(header)], id)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	testData := []struct {
+		header string
+		sent   string
+		// the sent ID is passed on
+		passed bool
+	}{
+		{"", "abc-123", true},
+		{"", "", false},
+		{"", "has space", false},
+		{"", strings.Repeat("x", maxLength+1), false},
+		{"X-Correlation-Id", "abc-123", true},
+		{"X-Correlation-Id", "", false},
+	}
+
+	var logged []string
+	defer func(f func(string, ...interface{})) {
+		logf = f
+	}(logf)
+	logf = func(format string, v ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	for _, d := range testData {
+		header := d.header
+		if len(header) == 0 {
+			header = DefaultHeader
+		}
+
+		h := New(Options{Header: d.header, Log: true}).Handler(backend(t, header))
+
+		r := httptest.NewRequest("GET", "/foo/bar?a=b", nil)
+		if len(d.sent) > 0 {
+			r.Header.Set(header, d.sent)
+		}
+		w := httptest.NewRecorder()
+		logged = nil
+		h.ServeHTTP(w, r)
+
+		id := w.Header().Get(header)
+		if d.passed && id != d.sent {
+			t.Fatalf("expected %q passed on got %q", d.sent, id)
+		}
+		if !d.passed {
+			if _, err := uuid.Parse(id); err != nil {
+				t.Fatalf("expected an ID generated for %q got %q", d.sent, id)
+			}
+		}
+
+		// the service received it
+		if b := w.Body.String(); b != id+" "+id {
+			t.Fatalf("expected the service to receive %s got %s", id, b)
+		}
+
+		if len(logged) != 1 || !strings.Contains(logged[0], "GET /foo/bar?a=b 418 ") || !strings.HasSuffix(logged[0], " "+id) {
+			t.Fatalf("expected the request logged with %s got %v", id, logged)
+		}
+	}
+
+	// IDs are unique
+	logged = nil
+	h := New(Options{}).Handler(backend(t, DefaultHeader))
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		id := w.Header().Get(DefaultHeader)
+		if seen[id] {
+			t.Fatalf("expected unique IDs got %s twice", id)
+		}
+		seen[id] = true
+	}
+	if logged != nil {
+		t.Fatalf("expected requests not logged got %v", logged)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	logged := make(chan string, 1)
+	defer func(f func(string, ...interface{})) {
+		logf = f
+	}(logf)
+	logf = func(format string, v ...interface{}) {
+		logged <- fmt.Sprintf(format, v...)
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(New(Options{Log: true}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(r.Header.Get(DefaultHeader)))
+		c.Close()
+	})))
+	defer srv.Close()
+
+	// the logged response can still be hijacked
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, msg, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uuid.Parse(string(msg)); err != nil {
+		t.Fatalf("expected the websocket's ID got %q", msg)
+	}
+
+	select {
+	case l := <-logged:
+		if !strings.Contains(l, " 101 ") {
+			t.Fatalf("expected the websocket logged as switching protocols got %s", l)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the websocket logged")
+	}
+}